@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bytedance/sonic"
+
+	"govid/internal/ffmpeg"
+	"govid/internal/models"
+	"govid/internal/presets"
+	"govid/pkg/logger"
+)
+
+// cliCommand is a subcommand that processes local files with FFmpeg, without starting the servers
+type cliCommand struct {
+	usage string
+	run   func(ctx context.Context, executor *ffmpeg.Executor, args []string) error
+}
+
+// Usage lines of the subcommands
+const (
+	mergeUsage   = "govid merge [flags] -o output.mp4 input1.mp4 input2.mp4 ...\n  govid merge [flags] -o output.mp4 -request merge.json"
+	overlayUsage = "govid overlay [flags] -o output.mp4 -image logo.png input.mp4\n  govid overlay [flags] -o output.mp4 -request overlay.json"
+	probeUsage   = "govid probe file"
+)
+
+// cliCommands are the subcommands of the govid binary
+var cliCommands = map[string]cliCommand{
+	"merge":   {usage: mergeUsage, run: runMerge},
+	"overlay": {usage: overlayUsage, run: runOverlay},
+	"probe":   {usage: probeUsage, run: runProbe},
+}
+
+// runCLI runs a subcommand and returns the exit code of the process. The FFmpeg binaries are taken from
+// FFMPEG_BINARY and FFPROBE_BINARY; no other configuration is needed.
+func runCLI(command cliCommand, args []string) int {
+	// Stdout carries the results of commands such as probe, so logs go to stderr
+	logger.SetOutput(os.Stderr)
+	if err := logger.Configure(envOr("LOG_LEVEL", "info"), "console"); err != nil {
+		logger.Error("%v", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	executor, err := ffmpeg.NewExecutor(ffmpeg.Config{
+		Binary:        envOr("FFMPEG_BINARY", "ffmpeg"),
+		ProbeBinary:   envOr("FFPROBE_BINARY", "ffprobe"),
+		Timeout:       24 * time.Hour, // the command runs until it finishes or is interrupted
+		MaxConcurrent: 1,
+	})
+	if err == nil {
+		err = command.run(ctx, executor, args)
+	}
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		logger.Error("%v", err)
+		return 1
+	}
+	return 0
+}
+
+// envOr returns the environment variable key, or fallback if it is unset or empty
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// commandFlags are the flags shared by the processing subcommands
+type commandFlags struct {
+	set     *flag.FlagSet
+	output  *string
+	request *string
+	preset  *string
+	verbose *bool
+}
+
+// newCommandFlags creates the flag set of a processing subcommand
+func newCommandFlags(name, usage string) *commandFlags {
+	set := flag.NewFlagSet(name, flag.ContinueOnError)
+	set.Usage = func() {
+		fmt.Fprintf(set.Output(), "Usage:\n  %s\n\nFlags:\n", usage)
+		set.PrintDefaults()
+	}
+	return &commandFlags{
+		set:     set,
+		output:  set.String("o", "", "output file; its extension sets the output format unless the request sets one"),
+		request: set.String("request", "", "JSON file with the body of the HTTP API request, or - for stdin"),
+		preset:  set.String("preset", "", "encoding preset, from the built-ins and PRESETS_FILE"),
+		verbose: set.Bool("v", false, "log the output of FFmpeg"),
+	}
+}
+
+// parse parses the arguments, reads the request file into req if one is given, and returns the positional
+// arguments
+func (f *commandFlags) parse(args []string, req any) ([]string, error) {
+	if err := f.set.Parse(args); err != nil {
+		return nil, err
+	}
+	if *f.output == "" {
+		f.set.Usage()
+		return nil, fmt.Errorf("-o is required")
+	}
+	if *f.verbose {
+		if err := logger.Configure("debug", "console"); err != nil {
+			return nil, err
+		}
+	}
+	if *f.request != "" {
+		if err := readRequest(*f.request, req); err != nil {
+			return nil, err
+		}
+	}
+	return f.set.Args(), nil
+}
+
+// resolve applies -preset and the format of the output file to opts and resolves the preset as the HTTP API
+// does, then checks that FFmpeg can run the command. Callers validate their request first.
+func (f *commandFlags) resolve(ctx context.Context, executor *ffmpeg.Executor, opts *models.OutputOptions) error {
+	if *f.preset != "" {
+		opts.PresetName = *f.preset
+	}
+	if opts.OutputFormat == "" {
+		if ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(*f.output)), "."); ext != "" {
+			opts.OutputFormat = models.OutputFormat(ext)
+		}
+	}
+	registry, err := presets.Load(os.Getenv("PRESETS_FILE"))
+	if err != nil {
+		return err
+	}
+	if err := registry.Resolve(opts); err != nil {
+		return err
+	}
+	// As at server startup, check that FFmpeg has the encoders and filters GoVid needs
+	_, err = executor.DetectCapabilities(ctx)
+	return err
+}
+
+// readRequest decodes a JSON request from path, or from stdin for -
+func readRequest(path string, req any) error {
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
+	}
+	if err := sonic.Unmarshal(content, req); err != nil {
+		return fmt.Errorf("invalid request %s: %w", path, err)
+	}
+	return nil
+}
+
+// runMerge merges the input videos, given as arguments or as the segments of a request, into one
+func runMerge(ctx context.Context, executor *ffmpeg.Executor, args []string) error {
+	flags := newCommandFlags("merge", mergeUsage)
+	var req models.MergeVideoRequest
+	inputs, err := flags.parse(args, &req)
+	if err != nil {
+		return err
+	}
+	for _, input := range inputs {
+		req.Segments = append(req.Segments, models.VideoSegment{FilePath: input})
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	if err := flags.resolve(ctx, executor, &req.OutputOptions); err != nil {
+		return err
+	}
+
+	if err := executor.MergeVideos(ctx, req.Segments, req.OutputOptions, *flags.output); err != nil {
+		return err
+	}
+	logger.Info("Merged %d segments into %s", len(req.Segments), *flags.output)
+	return nil
+}
+
+// runOverlay adds an image overlay given by flags, or the overlays of a request, to a video. The flags apply
+// to the single overlay of a request, not to a list of overlays.
+func runOverlay(ctx context.Context, executor *ffmpeg.Executor, args []string) error {
+	flags := newCommandFlags("overlay", overlayUsage)
+	image := flags.set.String("image", "", "overlay image")
+	position := flags.set.String("position", string(models.PositionTopRight), "top-left, top-right, bottom-left, bottom-right, or center")
+	start := flags.set.Float64("start", 0, "second the overlay appears at")
+	end := flags.set.Float64("end", 0, "second the overlay disappears at; 0 keeps it to the end")
+	req := models.OverlayRequest{Overlay: models.ImageOverlay{Position: models.PositionTopRight}}
+	inputs, err := flags.parse(args, &req)
+	if err != nil {
+		return err
+	}
+
+	// Flags that were given override the single overlay of the request
+	var overlayFlag string
+	flags.set.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "image":
+			req.Overlay.FilePath = *image
+		case "position":
+			req.Overlay.Position = models.OverlayPosition(*position)
+		case "start":
+			req.Overlay.StartTime = *start
+		case "end":
+			req.Overlay.EndTime = *end
+		default:
+			return
+		}
+		overlayFlag = f.Name
+	})
+	if overlayFlag != "" && len(req.Overlays) > 0 {
+		return fmt.Errorf("-%s cannot be combined with the overlays of a request", overlayFlag)
+	}
+	switch len(inputs) {
+	case 0:
+	case 1:
+		req.VideoPath = inputs[0]
+	default:
+		return fmt.Errorf("overlay takes one input video")
+	}
+	if req.VideoPath == "" || (len(req.Overlays) == 0 && req.Overlay.FilePath == "") {
+		flags.set.Usage()
+		return fmt.Errorf("an input video and an overlay image are required")
+	}
+	// A single overlay without an end stays to the end of the video
+	if len(req.Overlays) == 0 && req.Overlay.EndTime == 0 {
+		duration, err := executor.MediaDuration(ctx, req.VideoPath)
+		if err != nil {
+			return err
+		}
+		req.Overlay.EndTime = duration
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	if err := flags.resolve(ctx, executor, &req.OutputOptions); err != nil {
+		return err
+	}
+
+	overlays := req.ImageOverlays()
+	if err := executor.AddMultipleOverlays(ctx, req.VideoPath, overlays, req.OutputOptions, *flags.output); err != nil {
+		return err
+	}
+	logger.Info("Added %d overlays to %s in %s", len(overlays), req.VideoPath, *flags.output)
+	return nil
+}
+
+// runProbe prints the format and streams of a media file as JSON
+func runProbe(ctx context.Context, executor *ffmpeg.Executor, args []string) error {
+	set := flag.NewFlagSet("probe", flag.ContinueOnError)
+	set.Usage = func() {
+		fmt.Fprintf(set.Output(), "Usage:\n  %s\n", probeUsage)
+	}
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	if set.NArg() != 1 {
+		set.Usage()
+		return fmt.Errorf("probe takes one file")
+	}
+
+	probe, err := executor.Probe(ctx, set.Arg(0))
+	if err != nil {
+		return err
+	}
+	content, err := sonic.MarshalIndent(probe, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(content))
+	return nil
+}
@@ -11,10 +11,14 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -24,71 +28,270 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 
 	"govid/internal/api"
+	"govid/internal/delivery"
 	"govid/internal/ffmpeg"
 	"govid/internal/mcp"
 	"govid/internal/models"
+	"govid/internal/presets"
+	"govid/internal/scheduler"
+	"govid/internal/templates"
 	"govid/pkg/auth"
 	"govid/pkg/cleanup"
 	"govid/pkg/config"
 	"govid/pkg/logger"
+	"govid/pkg/queue"
+	"govid/pkg/tracing"
 )
 
+// expirySchedule is the name of the job scheduler's schedule that expires job outputs past their TTL
+const expirySchedule = "job-expiry"
+
+// leaderLease is the name of the lease held by the API instance that runs the background tasks
+const leaderLease = "background-tasks"
+
 func main() {
+	// Subcommands process local files without the servers
+	if len(os.Args) > 1 {
+		if command, ok := cliCommands[os.Args[1]]; ok {
+			os.Exit(runCLI(command, os.Args[2:]))
+		}
+	}
+
+	mcpStdio := flag.Bool("mcp-stdio", false, "serve MCP over stdio instead of running the HTTP listeners")
+	mode := flag.String("mode", "", "run mode: api to accept jobs, worker to run them, or all for both; overrides RUN_MODE")
+	flag.Parse()
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
 		logger.Error("Failed to load configuration: %v", err)
 		os.Exit(1)
 	}
+	if *mcpStdio {
+		cfg.MCPTransport = "stdio"
+	}
+	if *mode != "" {
+		if err := config.CheckRunMode(*mode); err != nil {
+			logger.Error("Invalid --mode: %v", err)
+			os.Exit(1)
+		}
+		cfg.RunMode = *mode
+		if err := cfg.CheckSharedSettings(); err != nil {
+			logger.Error("Invalid --mode: %v", err)
+			os.Exit(1)
+		}
+	}
+	workerMode := cfg.RunMode == config.ModeWorker
+
+	if err := logger.Configure(cfg.LogLevel, cfg.LogFormat); err != nil {
+		logger.Error("Failed to configure logging: %v", err)
+		os.Exit(1)
+	}
+
+	// Stdout carries MCP messages in stdio mode, so logs go to stderr
+	stdioMode := cfg.MCPTransport == "stdio"
+	if stdioMode {
+		logger.SetOutput(os.Stderr)
+	}
 
 	logger.Info("Starting GoVid application...")
-	logger.Info("HTTP API Port: %s", cfg.HTTPPort)
-	logger.Info("MCP Server Port: %s", cfg.MCPPort)
+	logger.Info("Run mode: %s", cfg.RunMode)
+	switch {
+	case workerMode:
+		logger.Info("Health check port: %s", cfg.HTTPPort)
+	case stdioMode:
+		logger.Info("MCP transport: stdio")
+	default:
+		logger.Info("HTTP API Port: %s", cfg.HTTPPort)
+		logger.Info("MCP Server Port: %s", cfg.MCPPort)
+	}
 
 	// Create shutdown context
 	shutdownCtx, shutdownCancel := context.WithCancel(context.Background())
 
+	// Initialize tracing
+	shutdownTracing, err := tracing.Setup(shutdownCtx, tracing.Config{
+		Enabled:     cfg.TracingEnabled,
+		Endpoint:    cfg.OTLPEndpoint,
+		ServiceName: cfg.TracingServiceName,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		logger.Error("Failed to initialize tracing: %v", err)
+		os.Exit(1)
+	}
+	if cfg.TracingEnabled {
+		logger.Info("Tracing enabled (sample ratio: %v)", cfg.TracingSampleRatio)
+	}
+
 	// Initialize shared components
 	var jobWG sync.WaitGroup
-	executor := ffmpeg.NewExecutor(cfg.FFmpegBinary, time.Duration(cfg.JobTimeout)*time.Second, int64(cfg.MaxConcurrentJobs))
+	executor, err := ffmpeg.NewExecutor(ffmpeg.Config{
+		Binary:                 cfg.FFmpegBinary,
+		ProbeBinary:            cfg.FFprobeBinary,
+		Timeout:                time.Duration(cfg.MaxJobTimeout) * time.Second,
+		MaxConcurrent:          int64(cfg.MaxConcurrentJobs),
+		MaxInterpolateDuration: float64(cfg.MaxInterpolateSeconds),
+		Limits: ffmpeg.Limits{
+			Threads:   cfg.FFmpegThreads,
+			Nice:      cfg.FFmpegNice,
+			IOClass:   cfg.FFmpegIOClass,
+			CgroupDir: cfg.FFmpegCgroupDir,
+			MemoryMax: int64(cfg.FFmpegMemoryMaxMB) << 20,
+			CPUs:      cfg.FFmpegCPUs,
+		},
+		InputLimits: ffmpeg.InputLimits{
+			MaxVideos:   cfg.MaxInputVideos,
+			MaxDuration: float64(cfg.MaxInputDurationSeconds),
+			MaxWidth:    cfg.MaxInputWidth,
+			MaxHeight:   cfg.MaxInputHeight,
+		},
+	})
+	if err != nil {
+		logger.Error("Failed to apply FFmpeg resource limits: %v", err)
+		os.Exit(1)
+	}
+	capabilities, err := executor.DetectCapabilities(context.Background())
+	if err != nil {
+		logger.Error("FFmpeg is not usable: %v", err)
+		os.Exit(1)
+	}
+	logger.Info("FFmpeg %s detected", capabilities.Version)
+	if len(capabilities.Disabled) > 0 {
+		logger.Warn("FFmpeg lacks optional encoders, disabled: %s", strings.Join(capabilities.Disabled, ", "))
+	}
 	jobStore := models.NewJobStoreWithPersistence(cfg.JobsDir)
+	if cfg.RunMode != config.ModeAll {
+		// API instances and workers see each other's jobs through JOBS_DIR
+		jobStore.Share()
+	}
+
+	// Load encoding presets
+	presetRegistry, err := presets.Load(cfg.PresetsFile)
+	if err != nil {
+		logger.Error("Failed to load encoding presets: %v", err)
+		os.Exit(1)
+	}
+	presetRegistry.SetMaxTimeout(cfg.MaxJobTimeout)
 
-	// Initialize validators
-	httpValidator := auth.NewValidator(cfg.HTTPAPIKey)
-	mcpValidator := auth.NewValidator(cfg.MCPAPIKey)
+	// Load job templates
+	jobTemplates, err := templates.Load(cfg.TemplatesDir)
+	if err != nil {
+		logger.Error("Failed to load job templates: %v", err)
+		os.Exit(1)
+	}
+
+	// Initialize API key stores
+	httpKeys, err := auth.Load(cfg.HTTPAPIKey, cfg.APIKeys, cfg.APIKeysFile)
+	if err != nil {
+		logger.Error("Failed to load API keys: %v", err)
+		os.Exit(1)
+	}
+	// Keys created through the admin API are stored alongside the job records
+	if err := httpKeys.LoadManaged(filepath.Join(cfg.JobsDir, "keys", "api_keys.json")); err != nil {
+		logger.Error("Failed to load managed API keys: %v", err)
+		os.Exit(1)
+	}
+	mcpKeys, err := auth.NewKeyStore([]auth.Key{{Name: "mcp", Key: cfg.MCPAPIKey, Scope: auth.ScopeAdmin}})
+	if err != nil {
+		logger.Error("Failed to load MCP API key: %v", err)
+		os.Exit(1)
+	}
 
-	// Start cleanup scheduler if enabled
+	// API instances sharing the job store run the cleanup, output expiry and template schedules only while
+	// they lead
+	var leader *models.Leader
+	if cfg.RunMode == config.ModeAPI {
+		leader = jobStore.NewLeader(leaderLease, time.Duration(cfg.LeaderLeaseSeconds)*time.Second)
+		leader.Start(shutdownCtx)
+	}
+
+	// Start cleanup scheduler if enabled. Workers leave cleanup to the API instances.
 	var cleanupScheduler *cleanup.Scheduler
-	if cfg.CleanupEnabled {
+	if cfg.CleanupEnabled && !workerMode {
 		cleanupScheduler = cleanup.NewScheduler(
 			cfg.OutputDir,
 			cfg.UploadDir,
 			cfg.TempDir,
 			jobStore,
 			cfg.CleanupRetentionDays,
+			cleanup.Quotas{
+				OutputBytes: int64(cfg.OutputDirMaxGB) << 30,
+				UploadBytes: int64(cfg.UploadDirMaxGB) << 30,
+			},
 		)
+		if leader != nil {
+			cleanupScheduler.FollowLeader(leader.IsLeader)
+		}
 		cleanupScheduler.Start()
 		logger.Info("Cleanup scheduler enabled (retention: %d days)", cfg.CleanupRetentionDays)
 	} else {
 		logger.Info("Cleanup scheduler disabled")
 	}
 
-	// Start HTTP API server
-	go startHTTPServer(shutdownCtx, cfg, executor, jobStore, httpValidator, &jobWG)
+	// Connect to the job queue, if one is configured
+	broker, err := queue.New(cfg.QueueConfig())
+	if err != nil {
+		logger.Error("Failed to connect to the %s job queue: %v", cfg.QueueBackend, err)
+		os.Exit(1)
+	}
+
+	// The storage uploads, webhooks and queue events of job results, shared by the API and MCP servers and
+	// the worker
+	deliverer := delivery.New(cfg, executor, broker)
+
+	// Start the job scheduler, which starts jobs with a run_at, runs templates on their schedules, and
+	// expires the outputs of jobs past their TTL every minute. Workers only run the jobs API instances start.
+	jobScheduler := scheduler.New()
+	if leader != nil {
+		jobScheduler.FollowLeader(leader.IsLeader)
+	}
+	if !workerMode {
+		everyMinute, _ := scheduler.ParseCron("* * * * *")
+		jobScheduler.Every(expirySchedule, everyMinute, func() {
+			go deliverer.ExpireJobs(shutdownCtx, jobStore)
+		})
+	}
+	jobScheduler.Start()
+
+	// Draining stops the HTTP API and the MCP server alike from taking in jobs
+	var drain models.Drain
+
+	// stdioDone is closed when the stdio client disconnects
+	stdioDone := make(chan struct{})
+	if workerMode {
+		// Run the jobs in the job store, answering health checks only
+		go startWorker(shutdownCtx, cfg, executor, jobStore, deliverer, presetRegistry, jobTemplates, jobScheduler, &drain, httpKeys, &jobWG)
+	} else if stdioMode {
+		// Serve MCP over stdio only; the client launches and owns this process
+		go func() {
+			defer close(stdioDone)
+			startMCPStdioServer(shutdownCtx, cfg, executor, jobStore, deliverer, presetRegistry, &drain, &jobWG)
+		}()
+	} else {
+		// Start HTTP API server
+		go startHTTPServer(shutdownCtx, cfg, executor, jobStore, deliverer, broker, presetRegistry, jobTemplates, jobScheduler, &drain, httpKeys, &jobWG)
 
-	// Start MCP server
-	go startMCPServer(shutdownCtx, cfg, executor, jobStore, mcpValidator, &jobWG)
+		// Start MCP server
+		go startMCPServer(shutdownCtx, cfg, executor, jobStore, deliverer, presetRegistry, &drain, mcpKeys, &jobWG)
+	}
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal or stdio client disconnect
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	select {
+	case <-quit:
+	case <-stdioDone:
+	}
 
 	logger.Info("Shutting down servers...")
 
 	// Cancel shutdown context to signal servers to stop
 	shutdownCancel()
 
+	// Stop the job scheduler; scheduled jobs that are not due yet do not run
+	jobScheduler.Stop()
+
 	// Stop cleanup scheduler if running
 	if cleanupScheduler != nil {
 		cleanupScheduler.Stop()
@@ -115,10 +318,24 @@ func main() {
 			logger.Info("shutdown timeout reached; exiting")
 		}
 	}
+
+	// Publish the pending events of the jobs that finished
+	if broker != nil {
+		if err := broker.Close(); err != nil {
+			logger.Error("Failed to close the job queue: %v", err)
+		}
+	}
+
+	// Flush spans of the jobs that finished
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Error("Failed to flush traces: %v", err)
+	}
 }
 
-// startHTTPServer starts the HTTP API server
-func startHTTPServer(ctx context.Context, cfg *config.Config, executor *ffmpeg.Executor, jobStore *models.JobStore, validator *auth.Validator, jobWG *sync.WaitGroup) {
+// startHTTPServer starts the HTTP API server, and the consumer of the job queue if broker is not nil
+func startHTTPServer(ctx context.Context, cfg *config.Config, executor *ffmpeg.Executor, jobStore *models.JobStore, deliverer *delivery.Service, broker queue.Broker, presetRegistry *presets.Registry, jobTemplates *templates.Store, jobScheduler *scheduler.Scheduler, drain *models.Drain, keys *auth.KeyStore, jobWG *sync.WaitGroup) {
 	app := fiber.New(fiber.Config{
 		AppName:           "GoVid API v1.0.0",
 		ServerHeader:      "GoVid",
@@ -129,10 +346,15 @@ func startHTTPServer(ctx context.Context, cfg *config.Config, executor *ffmpeg.E
 	})
 
 	// Initialize handler
-	handler := api.NewHandler(executor, jobStore, cfg, jobWG)
+	handler := api.NewHandler(executor, jobStore, deliverer, presetRegistry, jobTemplates, jobScheduler, drain, keys, cfg, jobWG)
 
 	// Setup routes
-	api.SetupRoutes(app, handler, validator)
+	api.SetupRoutes(app, handler, keys)
+
+	// Jobs can also be requested through the job queue
+	if broker != nil {
+		go handler.ConsumeQueue(ctx, broker)
+	}
 
 	logger.Info("HTTP API server starting on port %s", cfg.HTTPPort)
 
@@ -152,10 +374,43 @@ func startHTTPServer(ctx context.Context, cfg *config.Config, executor *ffmpeg.E
 	}
 }
 
+// startWorker claims and runs the jobs that API instances leave in the job store, and serves the health
+// checks on the HTTP API port
+func startWorker(ctx context.Context, cfg *config.Config, executor *ffmpeg.Executor, jobStore *models.JobStore, deliverer *delivery.Service, presetRegistry *presets.Registry, jobTemplates *templates.Store, jobScheduler *scheduler.Scheduler, drain *models.Drain, keys *auth.KeyStore, jobWG *sync.WaitGroup) {
+	app := fiber.New(fiber.Config{
+		AppName:      "GoVid Worker v1.0.0",
+		ServerHeader: "GoVid",
+		ErrorHandler: api.ErrorHandlerMiddleware,
+		JSONEncoder:  sonic.Marshal,
+		JSONDecoder:  sonic.Unmarshal,
+	})
+
+	handler := api.NewHandler(executor, jobStore, deliverer, presetRegistry, jobTemplates, jobScheduler, drain, keys, cfg, jobWG)
+	api.SetupWorkerRoutes(app, handler)
+	go handler.RunWorker(ctx)
+
+	logger.Info("Worker health checks starting on port %s", cfg.HTTPPort)
+
+	// Shutdown goroutine
+	go func() {
+		<-ctx.Done()
+		logger.Info("Shutting down worker...")
+		_ = app.ShutdownWithContext(ctx)
+	}()
+
+	if err := app.Listen(":"+cfg.HTTPPort, fiber.ListenConfig{
+		DisableStartupMessage: true,
+		EnablePrefork:         false,
+	}); err != nil {
+		logger.Error("Worker health check server error: %v", err)
+		os.Exit(1)
+	}
+}
+
 // startMCPServer starts the MCP server
-func startMCPServer(ctx context.Context, cfg *config.Config, executor *ffmpeg.Executor, jobStore *models.JobStore, validator *auth.Validator, jobWG *sync.WaitGroup) {
+func startMCPServer(ctx context.Context, cfg *config.Config, executor *ffmpeg.Executor, jobStore *models.JobStore, deliverer *delivery.Service, presetRegistry *presets.Registry, drain *models.Drain, keys *auth.KeyStore, jobWG *sync.WaitGroup) {
 	// Create MCP server
-	mcpServer := mcp.NewMCPServer(executor, jobStore, cfg, jobWG)
+	mcpServer := mcp.NewMCPServer(executor, jobStore, deliverer, presetRegistry, drain, cfg, jobWG)
 
 	// Create StreamableHTTP server
 	httpServer := server.NewStreamableHTTPServer(
@@ -167,7 +422,8 @@ func startMCPServer(ctx context.Context, cfg *config.Config, executor *ffmpeg.Ex
 	mux := http.NewServeMux()
 
 	// Wrap MCP handler with auth middleware
-	mcpHandler := mcp.AuthMiddleware(validator)(httpServer)
+	mcpHandler := mcp.BodyLimitMiddleware(int64(cfg.MaxUploadSizeMB) << 20)(httpServer)
+	mcpHandler = mcp.AuthMiddleware(keys)(mcpHandler)
 	mcpHandler = mcp.LoggingMiddleware(mcpHandler)
 	mcpHandler = mcp.CORSMiddleware(mcpHandler)
 
@@ -210,3 +466,18 @@ func startMCPServer(ctx context.Context, cfg *config.Config, executor *ffmpeg.Ex
 		os.Exit(1)
 	}
 }
+
+// startMCPStdioServer serves the MCP tools over stdin/stdout until the client closes stdin or ctx is cancelled.
+// The client spawning the process is trusted, so no API key is checked.
+func startMCPStdioServer(ctx context.Context, cfg *config.Config, executor *ffmpeg.Executor, jobStore *models.JobStore, deliverer *delivery.Service, presetRegistry *presets.Registry, drain *models.Drain, jobWG *sync.WaitGroup) {
+	mcpServer := mcp.NewMCPServer(executor, jobStore, deliverer, presetRegistry, drain, cfg, jobWG)
+
+	stdioServer := server.NewStdioServer(mcpServer.GetServer())
+	stdioServer.SetErrorLogger(log.New(os.Stderr, "", log.LstdFlags))
+
+	logger.Info("MCP server listening on stdio")
+
+	if err := stdioServer.Listen(ctx, os.Stdin, os.Stdout); err != nil && err != context.Canceled {
+		logger.Error("MCP stdio server error: %v", err)
+	}
+}
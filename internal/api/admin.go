@@ -0,0 +1,178 @@
+package api
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"govid/internal/models"
+	"govid/pkg/health"
+)
+
+// dashboardHTML is the admin dashboard page. It holds no data: it asks for an admin key and calls the admin API.
+//
+//go:embed static/dashboard.html
+var dashboardHTML string
+
+// Dashboard serves the admin dashboard page
+func (h *Handler) Dashboard(c fiber.Ctx) error {
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return c.SendString(dashboardHTML)
+}
+
+// ListJobs godoc
+// @Summary List jobs
+// @Description List all jobs, newest first, optionally only those with a status or labels (admin scope)
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param status query string false "Only jobs with this status"
+// @Param label query []string false "Only jobs with this label, as key:value, or key for any value; repeat for jobs with all of them" collectionFormat(multi)
+// @Param limit query int false "Maximum number of jobs to return (default 100)"
+// @Success 200 {object} models.AdminJobsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/jobs [get]
+func (h *Handler) ListJobs(c fiber.Ctx) error {
+	limit := 100
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: "limit must be a positive integer",
+			})
+		}
+		limit = n
+	}
+	status := models.JobStatus(c.Query("status"))
+	labels := make(map[string]string)
+	for _, label := range c.RequestCtx().QueryArgs().PeekMulti("label") {
+		key, value, _ := strings.Cut(string(label), ":")
+		labels[key] = value
+	}
+
+	jobs := h.jobStore.List()
+	response := models.AdminJobsResponse{
+		Jobs:  make([]models.AdminJob, 0, min(limit, len(jobs))),
+		Total: len(jobs),
+	}
+	queue := h.executor.Queue()
+	for _, job := range jobs {
+		if len(response.Jobs) == limit {
+			break
+		}
+		if !job.HasLabels(labels) {
+			continue
+		}
+		jobStatus := h.jobStore.QueueStatus(job, queue, h.executor.Slots())
+		if status != "" && jobStatus.Status != status {
+			continue
+		}
+		response.Jobs = append(response.Jobs, models.AdminJob{
+			JobStatusResponse: jobStatus,
+			Retryable:         job.RetryWork() != nil,
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// GetStats godoc
+// @Summary Get queue and disk statistics
+// @Description Job counts by status, the concurrency limit, and free space of the storage directories (admin scope)
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.AdminStatsResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/stats [get]
+func (h *Handler) GetStats(c fiber.Ctx) error {
+	response := models.AdminStatsResponse{
+		Jobs:              make(map[models.JobStatus]int),
+		MaxConcurrentJobs: h.cfg.MaxConcurrentJobs,
+	}
+	for _, job := range h.jobStore.List() {
+		response.Jobs[job.GetStatus().Status]++
+	}
+
+	dirs := []struct{ name, path string }{
+		{"upload_dir", h.cfg.UploadDir},
+		{"output_dir", h.cfg.OutputDir},
+		{"temp_dir", h.cfg.TempDir},
+	}
+	for _, dir := range dirs {
+		stats := models.DiskStats{Name: dir.name, Path: dir.path}
+		if free, err := health.FreeSpace(dir.path); err != nil {
+			stats.Error = err.Error()
+		} else {
+			stats.FreeMB = free >> 20
+		}
+		response.Disk = append(response.Disk, stats)
+	}
+
+	return c.JSON(response)
+}
+
+// RetryJob godoc
+// @Summary Retry a job
+// @Description Run a failed or cancelled job again as a new job with the same inputs and webhook (admin scope).
+// @Description Combine jobs from uploaded files cannot be retried.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 202 {object} models.JobResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/jobs/{id}/retry [post]
+func (h *Handler) RetryJob(c fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	original, exists := h.jobStore.Get(jobID)
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Job not found",
+			Message: fmt.Sprintf("Job with ID %s does not exist", jobID),
+		})
+	}
+
+	work := original.RetryWork()
+	if work == nil {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error:   "Job not retryable",
+			Message: fmt.Sprintf("Job is %s; only failed or cancelled jobs can be retried, except combine jobs from uploaded files", original.GetStatus().Status),
+		})
+	}
+
+	job := models.NewJob(uuid.New().String())
+	job.CreatedBy = original.CreatedBy
+	job.RequestID = requestID(c)
+	job.TraceContext = requestSpanContext(c)
+	job.RetryOf = original.ID
+	job.WebhookURL = original.WebhookURL
+	job.WebhookHeaders = original.WebhookHeaders
+	job.Upload = original.Upload
+	job.DependsOn = original.DependsOn
+	job.Labels = original.Labels
+	job.TTL = original.TTL
+	h.jobStore.Add(job)
+	h.startWork(job, work)
+
+	job.Logger().Info("Job %s retries job %s (requested by %s)", job.ID, original.ID, requestKey(c).Name)
+
+	return c.Status(fiber.StatusAccepted).JSON(models.JobResponse{
+		JobID:     job.ID,
+		Status:    models.JobStatusPending,
+		Message:   "Retry job created successfully",
+		CreatedAt: job.CreatedAt,
+	})
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v3"
+
+	"govid/internal/models"
+)
+
+// jobParentsLocal is the locals key holding the IDs of the jobs whose outputs a request uses as inputs
+const jobParentsLocal = "job_parents"
+
+// jobOutputPollInterval is how often a chained job checks whether the jobs it waits for have finished
+const jobOutputPollInterval = 500 * time.Millisecond
+
+// JobOutputMiddleware resolves the inputs of a JSON request body given as {"job_output": "<id>"}, the output of
+// an earlier job. The input of a completed job is replaced by the job's output file. With wait, the input of a
+// scheduled, pending or processing job becomes job:<id>, and the job created by the request waits for it with
+// awaitJobOutputs; without wait, for requests answered right away, such an input is refused, as are the
+// outputs of failed, cancelled and unknown jobs.
+func JobOutputMiddleware(jobs *models.JobStore, wait bool) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		body := c.Body()
+		if !bytes.Contains(body, []byte(`"job_output"`)) {
+			return c.Next()
+		}
+		if !strings.HasPrefix(string(c.Request().Header.ContentType()), fiber.MIMEApplicationJSON) {
+			return c.Next()
+		}
+		var value any
+		if err := sonic.Unmarshal(body, &value); err != nil {
+			return c.Next() // the handler refuses the body
+		}
+
+		var parents []string
+		var failStatus int
+		var failure models.ErrorResponse
+		fail := func(status int, title, format string, args ...any) string {
+			if failStatus == 0 {
+				failStatus = status
+				failure = models.ErrorResponse{Error: title, Message: fmt.Sprintf(format, args...)}
+			}
+			return ""
+		}
+		value = replaceJobOutputs(value, func(id string) string {
+			job, exists := jobs.Get(id)
+			if !exists {
+				return fail(fiber.StatusNotFound, "Job not found", "job_output %s: job does not exist", id)
+			}
+			if !slices.Contains(parents, id) {
+				parents = append(parents, id)
+			}
+
+			status := job.GetStatus()
+			switch status.Status {
+			case models.JobStatusCompleted:
+				if status.OutputPath == "" {
+					return fail(fiber.StatusConflict, "Job output not available", "job_output %s: the job has no local output; it was moved to the storage backend", id)
+				}
+				if _, err := os.Stat(status.OutputPath); err != nil {
+					return fail(fiber.StatusConflict, "Job output not available", "job_output %s: the job's output no longer exists", id)
+				}
+				return status.OutputPath
+			case models.JobStatusScheduled, models.JobStatusPending, models.JobStatusProcessing:
+				if !wait {
+					return fail(fiber.StatusConflict, "Job not finished", "job_output %s: the job is %s", id, status.Status)
+				}
+				return models.JobOutputPrefix + id
+			default:
+				return fail(fiber.StatusConflict, "Job output not available", "job_output %s: the job is %s", id, status.Status)
+			}
+		})
+		if failStatus != 0 {
+			return c.Status(failStatus).JSON(failure)
+		}
+
+		data, err := sonic.Marshal(value)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Failed to resolve job outputs",
+				Message: err.Error(),
+			})
+		}
+		c.Request().SetBody(data)
+		c.Locals(jobParentsLocal, parents)
+		return c.Next()
+	}
+}
+
+// replaceJobOutputs returns a copy of a decoded JSON value with each {"job_output": "<id>"} object replaced by
+// the result of resolve
+func replaceJobOutputs(value any, resolve func(id string) string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		if id, ok := v["job_output"].(string); ok && len(v) == 1 {
+			return resolve(id)
+		}
+		copied := make(map[string]any, len(v))
+		for key, item := range v {
+			copied[key] = replaceJobOutputs(item, resolve)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(v))
+		for i, item := range v {
+			copied[i] = replaceJobOutputs(item, resolve)
+		}
+		return copied
+	default:
+		return v
+	}
+}
+
+// jobParents returns the IDs of the jobs whose outputs the request uses, as found by JobOutputMiddleware
+func jobParents(c fiber.Ctx) []string {
+	parents, _ := c.Locals(jobParentsLocal).([]string)
+	return parents
+}
+
+// awaitJobOutputs waits until the jobs referred to as job:<id> among paths have completed, then replaces
+// each reference with the output of its job. The job stays pending meanwhile. It fails the job and returns
+// false if one of those jobs fails, is cancelled or has no output, and returns false if the job is cancelled
+// while it waits.
+func (h *Handler) awaitJobOutputs(job *models.Job, paths ...*string) bool {
+	var ticker *time.Ticker
+	for _, path := range paths {
+		id, ok := models.JobOutputID(*path)
+		if !ok {
+			continue
+		}
+
+		for {
+			if job.GetStatus().Status == models.JobStatusCancelled {
+				return false
+			}
+			parent, exists := h.jobStore.Get(id)
+			if !exists {
+				return h.failChainedJob(job, fmt.Sprintf("job %s, whose output is an input, no longer exists", id))
+			}
+			status := parent.GetStatus()
+			if status.Status == models.JobStatusCompleted {
+				if status.OutputPath == "" {
+					return h.failChainedJob(job, fmt.Sprintf("job %s, whose output is an input, has no local output", id))
+				}
+				*path = status.OutputPath
+				break
+			}
+			if status.Status == models.JobStatusFailed || status.Status == models.JobStatusCancelled || status.Status == models.JobStatusExpired {
+				return h.failChainedJob(job, fmt.Sprintf("job %s, whose output is an input, is %s", id, status.Status))
+			}
+
+			if ticker == nil {
+				job.Logger().Info("Job %s is waiting for job %s", job.ID, id)
+				ticker = time.NewTicker(jobOutputPollInterval)
+				defer ticker.Stop()
+			}
+			<-ticker.C
+		}
+	}
+	return true
+}
+
+// failChainedJob fails a job whose inputs cannot be resolved by awaitJobOutputs, returning false
+func (h *Handler) failChainedJob(job *models.Job, reason string) bool {
+	job.Logger().Error("Job %s failed: %s", job.ID, reason)
+	job.SetError(reason)
+	_ = h.jobStore.Update(job)
+	h.delivery.Notify(context.Background(), job)
+	return false
+}
+
+// segmentPathRefs returns pointers to the file paths of segments, for awaitJobOutputs
+func segmentPathRefs(segments []models.VideoSegment) []*string {
+	refs := make([]*string, len(segments))
+	for i := range segments {
+		refs[i] = &segments[i].FilePath
+	}
+	return refs
+}
+
+// overlayInputRefs returns pointers to the video and overlay images of an overlay request, for awaitJobOutputs
+func overlayInputRefs(req *models.OverlayRequest) []*string {
+	if len(req.Overlays) == 0 {
+		return []*string{&req.VideoPath, &req.Overlay.FilePath}
+	}
+	refs := []*string{&req.VideoPath}
+	for i := range req.Overlays {
+		refs = append(refs, &req.Overlays[i].FilePath)
+	}
+	return refs
+}
+
+// audioInputRefs returns pointers to the music files of audio, for awaitJobOutputs
+func audioInputRefs(audio *models.AudioConfig) []*string {
+	refs := []*string{&audio.FilePath}
+	for i := range audio.Playlist {
+		refs = append(refs, &audio.Playlist[i])
+	}
+	return refs
+}
+
+// pipelineInputRefs returns pointers to the inputs of the steps of a pipeline, for awaitJobOutputs
+func pipelineInputRefs(req *models.PipelineRequest) []*string {
+	var refs []*string
+	for i := range req.Steps {
+		step := &req.Steps[i]
+		for j := range step.Inputs {
+			refs = append(refs, &step.Inputs[j])
+		}
+		if step.Overlay != nil {
+			refs = append(refs, &step.Overlay.FilePath)
+		}
+		if step.Audio != nil {
+			refs = append(refs, audioInputRefs(step.Audio)...)
+		}
+		if step.Subtitles != nil {
+			refs = append(refs, &step.Subtitles.FilePath)
+		}
+	}
+	return refs
+}
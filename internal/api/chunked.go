@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"govid/internal/models"
+	"govid/internal/uploads"
+	"govid/pkg/downloader"
+	"govid/pkg/logger"
+)
+
+// InitChunkedUpload godoc
+// @Summary Start a chunked upload
+// @Description Start uploading a large file in chunks. Send the chunks with PUT /api/v1/upload/{id}/chunk/{n}, numbered from 0, then assemble them with POST /api/v1/upload/{id}/complete. Every chunk but the last must have chunk_size bytes.
+// @Tags Upload
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.ChunkedUploadInitRequest true "File to upload"
+// @Success 201 {object} models.ChunkedUploadResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/upload/init [post]
+func (h *Handler) InitChunkedUpload(c fiber.Ctx) error {
+	var req models.ChunkedUploadInitRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+
+	maxChunkSize := int64(h.cfg.MaxChunkSizeMB) << 20
+	if err := req.Validate(maxChunkSize, int64(h.cfg.MaxUploadSizeMB)<<20); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if _, err := downloader.ParseChecksum(req.Checksum); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+
+	if err := h.fileTypes.CheckName(req.FileName); err != nil {
+		return uploadErrorResponse(c, err)
+	}
+
+	chunkSize := req.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = maxChunkSize
+	}
+
+	session, err := h.chunks.Create(filepath.Base(req.FileName), req.FileSize, chunkSize, req.Checksum, req.TTLSeconds)
+	if err != nil {
+		logger.Error("Failed to start chunked upload: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to start upload",
+			Message: err.Error(),
+		})
+	}
+
+	logger.Info("Chunked upload %s started: %s (%d bytes in %d chunks)", session.ID, session.FileName, session.FileSize, session.TotalChunks)
+
+	return c.Status(fiber.StatusCreated).JSON(chunkedUploadResponse(session, []int{}))
+}
+
+// UploadChunk godoc
+// @Summary Upload a chunk
+// @Description Upload chunk n of a chunked upload as the raw request body. Sending a chunk again replaces it, so failed chunks can be retried.
+// @Tags Upload
+// @Security ApiKeyAuth
+// @Accept application/octet-stream
+// @Produce json
+// @Param id path string true "Upload ID"
+// @Param n path int true "Chunk number, from 0"
+// @Success 200 {object} models.ChunkedUploadResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/upload/{id}/chunk/{n} [put]
+func (h *Handler) UploadChunk(c fiber.Ctx) error {
+	session, err := h.chunks.Get(c.Params("id"))
+	if err != nil {
+		return chunkedUploadError(c, err)
+	}
+
+	n, err := strconv.Atoi(c.Params("n"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "chunk number must be an integer",
+		})
+	}
+
+	body := c.Request().BodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+	if err := h.chunks.WriteChunk(session, n, body); err != nil {
+		return chunkedUploadError(c, err)
+	}
+
+	received, err := h.chunks.Received(session)
+	if err != nil {
+		return chunkedUploadError(c, err)
+	}
+	return c.JSON(chunkedUploadResponse(session, received))
+}
+
+// CompleteChunkedUpload godoc
+// @Summary Complete a chunked upload
+// @Description Assemble the chunks of an upload into a file in the upload directory and verify its checksum, given at init or in this request. The returned file_path can be used like those of /api/v1/upload. If chunks are missing the upload stays open.
+// @Tags Upload
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Upload ID"
+// @Param request body models.ChunkedUploadCompleteRequest false "Checksum of the file"
+// @Success 200 {object} models.UploadResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /api/v1/upload/{id}/complete [post]
+func (h *Handler) CompleteChunkedUpload(c fiber.Ctx) error {
+	// The body with the checksum is optional
+	var req models.ChunkedUploadCompleteRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request body",
+				Message: err.Error(),
+			})
+		}
+		if _, err := downloader.ParseChecksum(req.Checksum); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	session, err := h.chunks.Get(c.Params("id"))
+	if err != nil {
+		return chunkedUploadError(c, err)
+	}
+
+	filename := uuid.New().String() + filepath.Ext(session.FileName)
+	savePath := filepath.Join(h.cfg.UploadDir, filename)
+	sum, err := h.chunks.Complete(session, req.Checksum, savePath)
+	if err != nil {
+		return chunkedUploadError(c, err)
+	}
+	if err := h.fileTypes.CheckFile(session.FileName, savePath); err != nil {
+		os.Remove(savePath)
+		return uploadErrorResponse(c, err)
+	}
+
+	storedPath, existed, err := uploads.Dedup(savePath, sum, session.FileName)
+	if err != nil {
+		os.Remove(savePath)
+		return chunkedUploadError(c, err)
+	}
+	savePath, filename = storedPath, filepath.Base(storedPath)
+	scans, err := h.scanUploads(c.Context(), []string{savePath}, []bool{existed})
+	if err != nil {
+		return uploadErrorResponse(c, err)
+	}
+	ttl, _ := h.uploadTTL(session.TTLSeconds) // validated at init
+
+	logger.Info("Chunked upload %s completed: %s (%d bytes)", session.ID, filename, session.FileSize)
+
+	checksum := session.Checksum
+	if checksum == "" {
+		checksum = req.Checksum
+	}
+	return c.JSON(models.UploadResponse{
+		FileName:   filename,
+		FilePath:   savePath,
+		FileSize:   session.FileSize,
+		SHA256:     sum,
+		Existing:   existed,
+		Checksum:   checksum,
+		ExpiresAt:  h.expireUpload(savePath, ttl, existed),
+		ScanStatus: scans[0],
+	})
+}
+
+// chunkedUploadResponse describes a session and the chunks received so far
+func chunkedUploadResponse(session *uploads.Session, received []int) models.ChunkedUploadResponse {
+	return models.ChunkedUploadResponse{
+		UploadID:       session.ID,
+		FileName:       session.FileName,
+		FileSize:       session.FileSize,
+		ChunkSize:      session.ChunkSize,
+		TotalChunks:    session.TotalChunks,
+		ReceivedChunks: received,
+	}
+}
+
+// chunkedUploadError sends the error response for a failed chunked upload operation
+func chunkedUploadError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, uploads.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Upload not found",
+			Message: fmt.Sprintf("Upload with ID %s does not exist", c.Params("id")),
+		})
+	case errors.Is(err, uploads.ErrBadChunk):
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid chunk",
+			Message: err.Error(),
+		})
+	case errors.Is(err, downloader.ErrChecksumMismatch):
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Checksum mismatch",
+			Message: err.Error(),
+		})
+	case errors.Is(err, uploads.ErrIncomplete):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error:   "Upload incomplete",
+			Message: err.Error(),
+		})
+	case errors.Is(err, uploads.ErrBusy):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error:   "Upload busy",
+			Message: err.Error(),
+		})
+	default:
+		logger.Error("Chunked upload %s failed: %v", c.Params("id"), err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Upload failed",
+			Message: err.Error(),
+		})
+	}
+}
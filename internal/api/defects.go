@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v3"
+
+	"govid/internal/models"
+)
+
+// DetectDefects godoc
+// @Summary Find black and frozen ranges of a video
+// @Description Find the ranges of black frames (blackdetect) and frozen picture (freezedetect) of a video, to reject broken source material before encoding it. The job's output is a JSON report of the ranges; with fail_on_defects, a video with any fails the job instead.
+// @Tags Video
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.DefectsRequest true "Defect detection request"
+// @Success 202 {object} models.JobResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/video/defects [post]
+func (h *Handler) DetectDefects(c fiber.Ctx) error {
+	var req models.DefectsRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+	if req.VideoPath == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "video_path is required",
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := h.checkVideoPath(req.VideoPath); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+
+	if err := h.checkInputs(localPaths(req.VideoPath)...); err != nil {
+		return uploadInputError(c, err)
+	}
+	if err := h.executor.CheckInputLimits(c.Context(), pathSegments(req.VideoPath)); err != nil {
+		return inputLimitError(c, err)
+	}
+
+	if err := h.checkDelivery(&req.DeliveryOptions); err != nil {
+		return deliveryError(c, err)
+	}
+
+	job, response := h.createAndStartJob(c, req.OutputOptions, req.DeliveryOptions)
+	h.startJob(job, models.WorkDefects, req)
+
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// processDefectsJob finds the black and frozen ranges of a video and writes the report as the job's output
+func (h *Handler) processDefectsJob(job *models.Job, req models.DefectsRequest) {
+	if !h.awaitJobOutputs(job, &req.VideoPath) {
+		return
+	}
+	input := req.VideoPath
+	h.processVideoJob(job, "defect detection", req.ReportOptions(), req.VideoPath, func(ctx context.Context, videoPath, outputPath string) error {
+		req.VideoPath = videoPath
+		return h.executor.ReportDefects(ctx, req, outputPath)
+	})
+	h.cleanupInputs(job, req.CleanupInputs, input)
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"govid/internal/models"
+	"govid/pkg/logger"
+	"govid/pkg/storage"
+)
+
+// CreateDirectUpload godoc
+// @Summary Upload a file straight to the storage bucket
+// @Description Get a presigned URL that uploads a file to the s3 or gcs bucket without passing through the API, for inputs too large to send here. PUT the file to upload_url with the returned headers, then use input_url as the file_path or video_path of a job. Upload size limits do not apply; the download of the input is limited by MAX_DOWNLOAD_SIZE_MB.
+// @Tags Upload
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.DirectUploadRequest true "File to upload"
+// @Success 200 {object} models.DirectUploadResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 501 {object} models.ErrorResponse
+// @Router /api/v1/upload/direct [post]
+func (h *Handler) CreateDirectUpload(c fiber.Ctx) error {
+	var req models.DirectUploadRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+	if req.FileName == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "file_name is required",
+		})
+	}
+	if err := h.fileTypes.CheckName(req.FileName); err != nil {
+		return uploadErrorResponse(c, err)
+	}
+
+	if h.uploader == nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Storage not configured",
+			Message: "Storage configuration is missing or invalid",
+		})
+	}
+
+	id := uuid.New().String()
+	ext := strings.ToLower(filepath.Ext(req.FileName))
+	key, err := storage.ObjectKey(h.cfg.S3UploadKeyTemplate, storage.KeyFields{
+		JobID:        id,
+		APIKey:       requestKey(c).Name,
+		OriginalName: strings.TrimSuffix(filepath.Base(req.FileName), filepath.Ext(req.FileName)),
+		FilePath:     id + ext,
+		Time:         time.Now(),
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to create upload",
+			Message: err.Error(),
+		})
+	}
+
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = storage.ContentType(req.FileName)
+	}
+	ttl := time.Duration(h.cfg.StorageUploadTTLSeconds) * time.Second
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	uploadURL, err := h.uploader.PresignUpload(c.Context(), key, contentType, ttl)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotSupported) {
+			return c.Status(fiber.StatusNotImplemented).JSON(models.ErrorResponse{
+				Error:   "Direct uploads not supported",
+				Message: fmt.Sprintf("Direct uploads need the s3 or gcs storage backend; use /api/v1/upload/init for large files with the %s backend", h.cfg.StorageBackend),
+			})
+		}
+		logger.Error("Failed to presign upload of %s: %v", key, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to create upload",
+			Message: err.Error(),
+		})
+	}
+
+	logger.Info("Direct upload of %s created: %s", req.FileName, key)
+
+	return c.JSON(models.DirectUploadResponse{
+		UploadURL: uploadURL,
+		Method:    fiber.MethodPut,
+		Headers:   map[string]string{"Content-Type": contentType},
+		Key:       key,
+		InputURL:  fmt.Sprintf("%s://%s/%s", h.uploader.Scheme(), h.uploader.Bucket(), key),
+		ExpiresAt: expires,
+	})
+}
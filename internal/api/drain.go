@@ -0,0 +1,74 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"govid/internal/models"
+)
+
+// drainStatus returns the progress of the drain: the jobs this instance still runs, and those scheduled here
+// that it will not run
+func (h *Handler) drainStatus() models.DrainResponse {
+	since := h.drain.Since()
+	response := models.DrainResponse{Draining: !since.IsZero()}
+	if response.Draining {
+		response.Since = &since
+	}
+	// In the api run mode, the jobs run on the workers
+	if !h.leftToWorkers() {
+		for _, job := range h.jobStore.List() {
+			switch job.GetStatus().Status {
+			case models.JobStatusPending, models.JobStatusProcessing:
+				response.ActiveJobs++
+			case models.JobStatusScheduled:
+				response.ScheduledJobs++
+			}
+		}
+	}
+	response.Drained = response.Draining && response.ActiveJobs == 0
+	return response
+}
+
+// RejectWhileDraining creates a middleware that refuses requests creating jobs once the instance drains, so
+// that they are sent to another instance
+func RejectWhileDraining(handler *Handler) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if handler.drain.Draining() {
+			c.Set(fiber.HeaderRetryAfter, "5")
+			return c.Status(fiber.StatusServiceUnavailable).JSON(models.ErrorResponse{
+				Error:   "Draining",
+				Message: models.ErrDraining.Error(),
+			})
+		}
+		return c.Next()
+	}
+}
+
+// Drain godoc
+// @Summary Drain the instance
+// @Description Stop accepting jobs ahead of a shutdown (admin scope). Requests that create jobs get 503 and MCP tools creating jobs fail, the job queue is no longer consumed, and readiness fails, while the running jobs finish. Poll GET /api/v1/admin/drain until drained is true. A drain lasts until the process restarts.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 202 {object} models.DrainResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/drain [post]
+func (h *Handler) Drain(c fiber.Ctx) error {
+	h.drain.Start(requestKey(c).Name)
+	return c.Status(fiber.StatusAccepted).JSON(h.drainStatus())
+}
+
+// GetDrain godoc
+// @Summary Get drain progress
+// @Description Whether the instance drains, and how many of its jobs are still pending or processing (admin scope). Scheduled jobs whose run_at has not come are counted apart, as the instance does not wait for them.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.DrainResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/drain [get]
+func (h *Handler) GetDrain(c fiber.Ctx) error {
+	return c.JSON(h.drainStatus())
+}
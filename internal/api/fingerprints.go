@@ -0,0 +1,192 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v3"
+
+	"govid/internal/fingerprint"
+	"govid/internal/models"
+	"govid/pkg/logger"
+)
+
+// FingerprintVideo godoc
+// @Summary Fingerprint a video and check it for duplicates
+// @Description Hash one frame per second of the first 10 minutes of a video and compare the fingerprint with the saved ones, to detect re-uploads of content already processed. With save, the fingerprint is saved after the lookup. Runs synchronously.
+// @Tags Video
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.FingerprintRequest true "Fingerprint request"
+// @Success 200 {object} models.FingerprintResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/video/fingerprint [post]
+func (h *Handler) FingerprintVideo(c fiber.Ctx) error {
+	var req models.FingerprintRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := h.checkInputs(req.FilePath); err != nil {
+		return uploadInputError(c, err)
+	}
+
+	hashes, err := h.executor.Fingerprint(c.Context(), req.FilePath)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Fingerprinting failed",
+			Message: err.Error(),
+		})
+	}
+	matches, err := h.fingerprints.Search(hashes, models.DuplicateThreshold(req.Threshold))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Fingerprint lookup failed",
+			Message: err.Error(),
+		})
+	}
+
+	response := models.FingerprintResponse{
+		FilePath:    req.FilePath,
+		Seconds:     len(hashes),
+		Fingerprint: hashes.String(),
+		Duplicate:   len(matches) > 0,
+		Matches:     matches,
+	}
+	if req.Save {
+		entry, err := h.fingerprints.Add(hashes, req.FilePath, req.Name, requestKey(c).Name)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Failed to save fingerprint",
+				Message: err.Error(),
+			})
+		}
+		response.ID = entry.ID
+	}
+
+	return c.JSON(response)
+}
+
+// CompareVideos godoc
+// @Summary Compare two videos for duplicate content
+// @Description Fingerprint two videos and report their similarity at the alignment where they match best, so that a trimmed or re-encoded copy is still found. Runs synchronously.
+// @Tags Video
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.CompareRequest true "Compare request"
+// @Success 200 {object} models.CompareResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/video/compare [post]
+func (h *Handler) CompareVideos(c fiber.Ctx) error {
+	var req models.CompareRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := h.checkInputs(req.FilePath, req.OtherPath); err != nil {
+		return uploadInputError(c, err)
+	}
+
+	hashes := make([]fingerprint.Fingerprint, 2)
+	for i, path := range []string{req.FilePath, req.OtherPath} {
+		var err error
+		if hashes[i], err = h.executor.Fingerprint(c.Context(), path); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Fingerprinting failed",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	match := fingerprint.Compare(hashes[0], hashes[1])
+	return c.JSON(models.CompareResponse{
+		FilePath:   req.FilePath,
+		OtherPath:  req.OtherPath,
+		Similarity: match.Similarity,
+		Offset:     match.Offset,
+		Overlap:    match.Overlap,
+		Duplicate:  match.Similarity >= models.DuplicateThreshold(req.Threshold),
+	})
+}
+
+// ListFingerprints godoc
+// @Summary List saved fingerprints
+// @Description List the saved video fingerprints that /video/fingerprint checks videos against, newest first
+// @Tags Video
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.FingerprintsResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/fingerprints [get]
+func (h *Handler) ListFingerprints(c fiber.Ctx) error {
+	entries, err := h.fingerprints.List()
+	if err != nil {
+		logger.Error("Failed to list fingerprints: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to list fingerprints",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(models.FingerprintsResponse{Fingerprints: entries})
+}
+
+// DeleteFingerprint godoc
+// @Summary Delete a saved fingerprint
+// @Description Delete a saved video fingerprint, so that videos are no longer reported as duplicates of it
+// @Tags Video
+// @Security ApiKeyAuth
+// @Param id path string true "Fingerprint ID"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/fingerprints/{id} [delete]
+func (h *Handler) DeleteFingerprint(c fiber.Ctx) error {
+	if err := h.fingerprints.Delete(c.Params("id")); err != nil {
+		if errors.Is(err, fingerprint.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "Fingerprint not found",
+				Message: fmt.Sprintf("Fingerprint with ID %s does not exist", c.Params("id")),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to delete fingerprint",
+			Message: err.Error(),
+		})
+	}
+	logger.Info("Fingerprint %s deleted by %s", c.Params("id"), requestKey(c).Name)
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+
+	"govid/internal/fonts"
+	"govid/internal/models"
+	"govid/pkg/logger"
+)
+
+// maxFontSize limits the size of an uploaded font file
+const maxFontSize = 50 << 20
+
+// ListFonts godoc
+// @Summary List fonts
+// @Description List the fonts uploaded for text overlays, which refer to them by name in their font field
+// @Tags Fonts
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.FontsResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/fonts [get]
+func (h *Handler) ListFonts(c fiber.Ctx) error {
+	list, err := h.fonts.List()
+	if err != nil {
+		logger.Error("Failed to list fonts: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to list fonts",
+			Message: err.Error(),
+		})
+	}
+	return c.JSON(models.FontsResponse{Fonts: list})
+}
+
+// PutFont godoc
+// @Summary Upload a font
+// @Description Upload a TrueType or OpenType font (.ttf, .otf or .ttc, up to 50 MB) under the name in the path, replacing the font of that name. The font is written to FONTS_DIR.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param name path string true "Font name"
+// @Param file formData file true "Font file"
+// @Success 200 {object} models.Font
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/admin/fonts/{name} [put]
+func (h *Handler) PutFont(c fiber.Ctx) error {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "A font file is required in the file field",
+		})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	defer file.Close()
+
+	name := strings.Clone(c.Params("name")) // the parameter's memory is reused after the request
+	font, err := h.fonts.Put(name, file, maxFontSize)
+	if err != nil {
+		return fontErrorResponse(c, err)
+	}
+
+	logger.Info("Font %s saved by %s", name, requestKey(c).Name)
+	return c.JSON(font)
+}
+
+// DeleteFont godoc
+// @Summary Delete a font
+// @Description Delete an uploaded font and its file in FONTS_DIR. Text overlays using it can no longer be drawn.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param name path string true "Font name"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/admin/fonts/{name} [delete]
+func (h *Handler) DeleteFont(c fiber.Ctx) error {
+	if err := h.fonts.Delete(c.Params("name")); err != nil {
+		return fontErrorResponse(c, err)
+	}
+	logger.Info("Font %s deleted by %s", c.Params("name"), requestKey(c).Name)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// fontErrorResponse sends the error response for a failed font operation
+func fontErrorResponse(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, fonts.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Font not found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, fonts.ErrStorage):
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to store font",
+			Message: err.Error(),
+		})
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid font",
+			Message: err.Error(),
+		})
+	}
+}
@@ -2,66 +2,146 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"mime/multipart"
+	neturl "net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/bytedance/sonic"
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
 
+	"govid/internal/delivery"
 	"govid/internal/ffmpeg"
+	"govid/internal/fingerprint"
+	"govid/internal/fonts"
 	"govid/internal/models"
+	"govid/internal/presets"
+	"govid/internal/scheduler"
+	"govid/internal/templates"
+	"govid/internal/uploads"
+	"govid/internal/usage"
+	"govid/pkg/auth"
 	"govid/pkg/config"
 	"govid/pkg/downloader"
+	"govid/pkg/filetype"
 	"govid/pkg/logger"
+	"govid/pkg/pathpolicy"
+	"govid/pkg/scanner"
 	"govid/pkg/storage"
-	"govid/pkg/webhook"
+	"govid/pkg/transcribe"
 )
 
 // Handler contains dependencies for API handlers
 type Handler struct {
-	executor   *ffmpeg.Executor
-	jobStore   *models.JobStore
-	cfg        *config.Config
-	s3Uploader *storage.S3Uploader
-	downloader *downloader.VideoDownloader
-	webhook    *webhook.Client
-	jobWG      *sync.WaitGroup
+	executor     *ffmpeg.Executor
+	jobStore     *models.JobStore
+	presets      *presets.Registry
+	templates    *templates.Store
+	keys         *auth.KeyStore
+	cfg          *config.Config
+	uploader     storage.Uploader // nil when the storage backend failed to initialize
+	delivery     *delivery.Service
+	downloader   *downloader.VideoDownloader
+	urls         *downloader.URLPolicy
+	usage        *usage.Tracker
+	fingerprints *fingerprint.Store
+	fonts        *fonts.Store
+	chunks       *uploads.Store
+	fileTypes    *filetype.Policy
+	paths        *pathpolicy.Policy
+	outputs      *pathpolicy.Policy
+	scanner      scanner.Scanner        // nil when uploads are not scanned
+	transcriber  transcribe.Transcriber // nil when transcription is disabled
+	scheduler    *scheduler.Scheduler
+	jobWG        *sync.WaitGroup
+	drain        *models.Drain
 }
 
 // NewHandler creates a new API handler
-func NewHandler(executor *ffmpeg.Executor, jobStore *models.JobStore, cfg *config.Config, jobWG *sync.WaitGroup) *Handler {
-	// Initialize S3 uploader
-	s3Uploader, err := storage.NewS3Uploader(storage.S3Config{
-		Endpoint:  cfg.S3Endpoint,
-		AccessKey: cfg.S3AccessKey,
-		SecretKey: cfg.S3SecretKey,
-		Bucket:    cfg.S3Bucket,
-		Region:    cfg.S3Region,
-		UseSSL:    cfg.S3UseSSL,
-	})
+func NewHandler(executor *ffmpeg.Executor, jobStore *models.JobStore, deliverer *delivery.Service, presetRegistry *presets.Registry, jobTemplates *templates.Store, jobScheduler *scheduler.Scheduler, drain *models.Drain, keys *auth.KeyStore, cfg *config.Config, jobWG *sync.WaitGroup) *Handler {
+	uploader := deliverer.Uploader()
+
+	// The settings were validated by config.Load
+	uploadScanner, err := scanner.New(cfg.ScannerConfig())
+	if err != nil {
+		logger.Error("Failed to initialize the upload scanner: %v", err)
+	}
+	transcriber, err := transcribe.New(cfg.TranscribeConfig())
 	if err != nil {
-		logger.Error("Failed to initialize S3 uploader: %v", err)
+		logger.Error("Failed to initialize the transcriber: %v", err)
+	}
+
+	urls := downloader.NewURLPolicy(cfg.DownloadAllowPrivate, cfg.DownloadAllowedHosts)
+	downloads := downloader.Options{
+		URLs: urls,
+		Retry: downloader.RetryPolicy{
+			MaxRetries: cfg.DownloadRetries,
+			Delay:      time.Duration(cfg.DownloadRetryDelayMS) * time.Millisecond,
+		},
+		MaxFileBytes:      int64(cfg.MaxDownloadSizeMB) << 20,
+		MaxTotalBytes:     int64(cfg.MaxDownloadTotalMB) << 20,
+		MaxParallel:       int64(cfg.MaxParallelDownloads),
+		MaxBytesPerSecond: cfg.DownloadMaxBytesPerSecond,
+	}
+	if uploader != nil {
+		downloads.Objects = uploader
+	}
+	if cfg.YTDLPBinary != "" {
+		downloads.YTDLP = &downloader.YTDLP{
+			Binary: cfg.YTDLPBinary,
+			Format: cfg.YTDLPFormat,
+			Hosts:  cfg.YTDLPHosts,
+		}
 	}
 
-	return &Handler{
-		executor:   executor,
-		jobStore:   jobStore,
-		cfg:        cfg,
-		s3Uploader: s3Uploader,
-		downloader: downloader.NewVideoDownloader(cfg.TempDir),
-		webhook:    webhook.NewClient(),
-		jobWG:      jobWG,
+	h := &Handler{
+		executor:     executor,
+		jobStore:     jobStore,
+		presets:      presetRegistry,
+		templates:    jobTemplates,
+		keys:         keys,
+		cfg:          cfg,
+		uploader:     uploader,
+		delivery:     deliverer,
+		downloader:   downloader.NewVideoDownloader(cfg.TempDir, downloads),
+		urls:         urls,
+		usage:        usage.NewTracker(cfg.UsageDir),
+		fingerprints: fingerprint.NewStore(cfg.FingerprintsDir),
+		fonts:        fonts.NewStore(cfg.FontsDir),
+		chunks:       uploads.NewStore(filepath.Join(cfg.TempDir, "chunks")),
+		fileTypes:    filetype.NewPolicy(cfg.UploadAllowedExtensions),
+		paths:        pathpolicy.NewPolicy(cfg.InputRoots()...),
+		outputs:      pathpolicy.NewPolicy(cfg.OutputDir),
+		scanner:      uploadScanner,
+		transcriber:  transcriber,
+		scheduler:    jobScheduler,
+		jobWG:        jobWG,
+		drain:        drain,
+	}
+	// Workers leave the template schedules to the API instances, which create the jobs
+	if cfg.RunMode != config.ModeWorker {
+		h.scheduleTemplates()
 	}
+	// A single instance owns every job in the store, so it picks up those it left unfinished. Instances sharing
+	// the store cannot tell the jobs of a stopped instance from those running elsewhere.
+	if cfg.RunMode == config.ModeAll {
+		h.recoverJobs()
+	}
+	return h
 }
 
 // HealthCheck godoc
 // @Summary Health check endpoint
-// @Description Check if the service is running
+// @Description Check if the service is running. Also served at /api/v1/health/live for liveness probes.
 // @Tags Health
 // @Produce json
 // @Success 200 {object} models.HealthResponse
@@ -75,17 +155,26 @@ func (h *Handler) HealthCheck(c fiber.Ctx) error {
 
 // MergeVideos godoc
 // @Summary Merge multiple videos with timeframes
-// @Description Merge multiple video segments. Supports both JSON (with file paths) and multipart/form-data (direct upload, max 10 files)
+// @Description Merge multiple video segments. Supports both JSON (with file paths or http, https, or s3://bucket/key URLs) and multipart/form-data (direct upload, up to MAX_MERGE_FILES files)
 // @Tags Video
 // @Security ApiKeyAuth
 // @Accept json,multipart/form-data
 // @Produce json
 // @Param request body models.MergeVideoRequest false "Video merge request (JSON)"
-// @Param videos formData file false "Video files to upload (multipart, 2-10 files)"
+// @Param videos formData file false "Video files to upload (multipart, at least 2 files)"
+// @Param output_format formData string false "Output container: mp4, mkv, webm, or mov (multipart)"
 // @Success 202 {object} models.JobResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
 // @Router /api/v1/video/merge [post]
 func (h *Handler) MergeVideos(c fiber.Ctx) error {
 	contentType := string(c.Request().Header.ContentType())
@@ -94,43 +183,27 @@ func (h *Handler) MergeVideos(c fiber.Ctx) error {
 
 	// Handle multipart/form-data
 	if len(contentType) >= len(fiber.MIMEMultipartForm) && contentType[:len(fiber.MIMEMultipartForm)] == fiber.MIMEMultipartForm {
-		form, err := c.MultipartForm()
+		upload, err := h.streamMultipartUpload(c, "videos", h.cfg.UploadDir, h.cfg.MaxMergeFiles, func(_ int, filename string) string {
+			return uuid.New().String() + filepath.Ext(filename)
+		})
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-				Error:   "Invalid multipart form",
-				Message: err.Error(),
-			})
+			return uploadErrorResponse(c, err)
 		}
 
-		files := form.File["videos"]
-		if len(files) < 2 {
+		if len(upload.paths) < 2 {
+			upload.remove()
 			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 				Error:   "Invalid request",
 				Message: "At least 2 video files required",
 			})
 		}
-
-		if len(files) > 10 {
-			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-				Error:   "Too many files",
-				Message: "Maximum 10 videos allowed per merge",
-			})
+		if _, err := h.scanUploads(c.Context(), upload.paths, nil); err != nil {
+			return uploadErrorResponse(c, err)
 		}
 
-		// Save uploaded files and build segments
-		segments := make([]models.VideoSegment, 0, len(files))
-		for _, file := range files {
-			ext := filepath.Ext(file.Filename)
-			filename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
-			savePath := filepath.Join(h.cfg.UploadDir, filename)
-
-			if err := c.SaveFile(file, savePath); err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-					Error:   "Failed to save uploaded file",
-					Message: err.Error(),
-				})
-			}
-
+		// Build full-length segments from the saved files
+		segments := make([]models.VideoSegment, 0, len(upload.paths))
+		for _, savePath := range upload.paths {
 			segments = append(segments, models.VideoSegment{
 				FilePath:  savePath,
 				StartTime: 0,
@@ -139,6 +212,17 @@ func (h *Handler) MergeVideos(c fiber.Ctx) error {
 		}
 
 		req.Segments = segments
+		outputOptions, err := outputOptionsFromForm(upload.form)
+		if err == nil {
+			req.DeliveryOptions, err = deliveryOptionsFromForm(upload.form)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: err.Error(),
+			})
+		}
+		req.OutputOptions = outputOptions
 	} else {
 		// Handle JSON
 		if err := c.Bind().JSON(&req); err != nil {
@@ -150,26 +234,49 @@ func (h *Handler) MergeVideos(c fiber.Ctx) error {
 	}
 
 	// Validate request
-	if len(req.Segments) < 2 {
+	if err := req.Validate(); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Error:   "Invalid request",
-			Message: "At least 2 video segments required",
+			Message: err.Error(),
 		})
 	}
+	for _, seg := range req.Segments {
+		if downloader.IsURL(seg.FilePath) {
+			if err := h.downloader.CheckURL(seg.FilePath); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+					Error:   "Invalid request",
+					Message: err.Error(),
+				})
+			}
+		}
+	}
 
-	job, response := h.createAndStartJob()
-	h.jobWG.Add(1)
-	go func() {
-		defer h.jobWG.Done()
-		h.processMergeJob(job, req)
-	}()
+	if err := h.presets.Resolve(&req.OutputOptions); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := h.checkDelivery(&req.DeliveryOptions); err != nil {
+		return deliveryError(c, err)
+	}
+
+	if err := h.checkInputs(localPaths(segmentPaths(req.Segments)...)...); err != nil {
+		return uploadInputError(c, err)
+	}
+	if err := h.executor.CheckInputLimits(c.Context(), req.Segments); err != nil {
+		return inputLimitError(c, err)
+	}
+
+	job, response := h.createAndStartJob(c, req.OutputOptions, req.DeliveryOptions)
+	h.startJob(job, models.WorkMerge, req)
 
 	return c.Status(fiber.StatusAccepted).JSON(response)
 }
 
 // AddImageOverlay godoc
-// @Summary Add image overlay to video
-// @Description Add an image overlay. Supports both JSON (with file paths) and multipart/form-data (direct upload)
+// @Summary Add image overlays to video
+// @Description Add an image overlay, or several with overlays, laid in order in a single encode. Supports both JSON (with file paths) and multipart/form-data (direct upload, one image)
 // @Tags Video
 // @Security ApiKeyAuth
 // @Accept json,multipart/form-data
@@ -178,10 +285,19 @@ func (h *Handler) MergeVideos(c fiber.Ctx) error {
 // @Param video formData file false "Video file (multipart)"
 // @Param image formData file false "Image file for overlay (multipart)"
 // @Param overlay_config formData string false "JSON string of overlay configuration (multipart)"
+// @Param output_format formData string false "Output container: mp4, mkv, webm, or mov (multipart)"
 // @Success 202 {object} models.JobResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
 // @Router /api/v1/video/overlay [post]
 func (h *Handler) AddImageOverlay(c fiber.Ctx) error {
 	contentType := string(c.Request().Header.ContentType())
@@ -216,6 +332,10 @@ func (h *Handler) AddImageOverlay(c fiber.Ctx) error {
 			})
 		}
 
+		if err := h.checkFormFiles(videoFiles[0], imageFiles[0]); err != nil {
+			return uploadErrorResponse(c, err)
+		}
+
 		// Save video file
 		videoFile := videoFiles[0]
 		videoExt := filepath.Ext(videoFile.Filename)
@@ -239,6 +359,9 @@ func (h *Handler) AddImageOverlay(c fiber.Ctx) error {
 				Message: err.Error(),
 			})
 		}
+		if _, err := h.scanUploads(c.Context(), []string{videoPath, imagePath}, nil); err != nil {
+			return uploadErrorResponse(c, err)
+		}
 
 		// Build request with default overlay settings
 		req.VideoPath = videoPath
@@ -246,6 +369,17 @@ func (h *Handler) AddImageOverlay(c fiber.Ctx) error {
 			FilePath: imagePath,
 			Position: models.PositionTopRight,
 		}
+		outputOptions, err := outputOptionsFromForm(form)
+		if err == nil {
+			req.DeliveryOptions, err = deliveryOptionsFromForm(form)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: err.Error(),
+			})
+		}
+		req.OutputOptions = outputOptions
 	} else {
 		// Handle JSON
 		if err := c.Bind().JSON(&req); err != nil {
@@ -254,14 +388,40 @@ func (h *Handler) AddImageOverlay(c fiber.Ctx) error {
 				Message: err.Error(),
 			})
 		}
+		if err := req.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: err.Error(),
+			})
+		}
 	}
 
-	job, response := h.createAndStartJob()
-	h.jobWG.Add(1)
-	go func() {
-		defer h.jobWG.Done()
-		h.processOverlayJob(job, req)
-	}()
+	if err := h.checkVideoPath(req.VideoPath); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+
+	if err := h.presets.Resolve(&req.OutputOptions); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := h.checkDelivery(&req.DeliveryOptions); err != nil {
+		return deliveryError(c, err)
+	}
+
+	if err := h.checkInputs(append(localPaths(req.VideoPath), req.InputPaths()[1:]...)...); err != nil {
+		return uploadInputError(c, err)
+	}
+	if err := h.executor.CheckInputLimits(c.Context(), pathSegments(req.VideoPath)); err != nil {
+		return inputLimitError(c, err)
+	}
+
+	job, response := h.createAndStartJob(c, req.OutputOptions, req.DeliveryOptions)
+	h.startJob(job, models.WorkOverlay, req)
 
 	return c.Status(fiber.StatusAccepted).JSON(response)
 }
@@ -277,10 +437,21 @@ func (h *Handler) AddImageOverlay(c fiber.Ctx) error {
 // @Param video formData file false "Video file (multipart)"
 // @Param audio formData file false "Audio file (multipart)"
 // @Param audio_config formData string false "JSON string of audio configuration (multipart)"
+// @Param duck formData string false "Set to true to duck the music under the original audio (multipart)"
+// @Param mode formData string false "mix (default) or replace the original audio with the music (multipart)"
+// @Param output_format formData string false "Output container: mp4, mkv, webm, or mov (multipart)"
 // @Success 202 {object} models.JobResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
 // @Router /api/v1/video/audio [post]
 func (h *Handler) AddBackgroundMusic(c fiber.Ctx) error {
 	contentType := string(c.Request().Header.ContentType())
@@ -315,6 +486,10 @@ func (h *Handler) AddBackgroundMusic(c fiber.Ctx) error {
 			})
 		}
 
+		if err := h.checkFormFiles(videoFiles[0], audioFiles[0]); err != nil {
+			return uploadErrorResponse(c, err)
+		}
+
 		// Save video file
 		videoFile := videoFiles[0]
 		videoExt := filepath.Ext(videoFile.Filename)
@@ -338,6 +513,9 @@ func (h *Handler) AddBackgroundMusic(c fiber.Ctx) error {
 				Message: err.Error(),
 			})
 		}
+		if _, err := h.scanUploads(c.Context(), []string{videoPath, audioPath}, nil); err != nil {
+			return uploadErrorResponse(c, err)
+		}
 
 		// Build request with default audio settings
 		req.VideoPath = videoPath
@@ -345,6 +523,21 @@ func (h *Handler) AddBackgroundMusic(c fiber.Ctx) error {
 			FilePath: audioPath,
 			Volume:   0.3,
 		}
+		if formValue(form, "duck") == "true" {
+			req.Audio.Duck = &models.DuckingConfig{}
+		}
+		req.Audio.Mode = models.AudioMode(formValue(form, "mode"))
+		outputOptions, err := outputOptionsFromForm(form)
+		if err == nil {
+			req.DeliveryOptions, err = deliveryOptionsFromForm(form)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: err.Error(),
+			})
+		}
+		req.OutputOptions = outputOptions
 	} else {
 		// Handle JSON
 		if err := c.Bind().JSON(&req); err != nil {
@@ -355,19 +548,55 @@ func (h *Handler) AddBackgroundMusic(c fiber.Ctx) error {
 		}
 	}
 
-	job, response := h.createAndStartJob()
-	h.jobWG.Add(1)
-	go func() {
-		defer h.jobWG.Done()
-		h.processAudioJob(job, req)
-	}()
+	if err := h.checkVideoPath(req.VideoPath); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+
+	if err := req.Audio.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid audio",
+			Message: err.Error(),
+		})
+	}
+
+	if req.NormalizeAudio != nil {
+		if err := req.NormalizeAudio.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid normalize_audio",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if err := h.presets.Resolve(&req.OutputOptions); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := h.checkDelivery(&req.DeliveryOptions); err != nil {
+		return deliveryError(c, err)
+	}
+
+	if err := h.checkInputs(append(localPaths(req.VideoPath), req.Audio.Tracks()...)...); err != nil {
+		return uploadInputError(c, err)
+	}
+	if err := h.executor.CheckInputLimits(c.Context(), pathSegments(req.VideoPath)); err != nil {
+		return inputLimitError(c, err)
+	}
+
+	job, response := h.createAndStartJob(c, req.OutputOptions, req.DeliveryOptions)
+	h.startJob(job, models.WorkAudio, req)
 
 	return c.Status(fiber.StatusAccepted).JSON(response)
 }
 
 // ProcessComplete godoc
 // @Summary Complete video processing
-// @Description Process video with merge, overlay, and audio in one operation
+// @Description Process video with merge, overlay, audio, and optional loudness normalization in one operation
 // @Tags Video
 // @Security ApiKeyAuth
 // @Accept json
@@ -376,6 +605,11 @@ func (h *Handler) AddBackgroundMusic(c fiber.Ctx) error {
 // @Success 202 {object} models.JobResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/video/process [post]
 func (h *Handler) ProcessComplete(c fiber.Ctx) error {
@@ -387,132 +621,681 @@ func (h *Handler) ProcessComplete(c fiber.Ctx) error {
 		})
 	}
 
-	// Validate request
-	if len(req.Segments) < 1 {
+	if err := req.Validate(); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Error:   "Invalid request",
-			Message: "At least 1 video segment required",
+			Message: err.Error(),
 		})
 	}
 
-	job, response := h.createAndStartJob()
-	h.jobWG.Add(1)
-	go func() {
-		defer h.jobWG.Done()
-		h.processCompleteJob(job, req)
-	}()
+	if err := h.presets.Resolve(&req.OutputOptions); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := h.checkDelivery(&req.DeliveryOptions); err != nil {
+		return deliveryError(c, err)
+	}
+
+	if err := h.checkInputs(req.InputPaths()...); err != nil {
+		return uploadInputError(c, err)
+	}
+	if err := h.executor.CheckInputLimits(c.Context(), req.Segments); err != nil {
+		return inputLimitError(c, err)
+	}
+
+	job, response := h.createAndStartJob(c, req.OutputOptions, req.DeliveryOptions)
+	h.startJob(job, models.WorkComplete, req)
 
 	return c.Status(fiber.StatusAccepted).JSON(response)
 }
 
-// GetJobStatus godoc
-// @Summary Get job status
-// @Description Get the status of a video processing job
-// @Tags Jobs
+// DetectSilence godoc
+// @Summary Detect silent ranges
+// @Description Report silent ranges in the audio of a video using silencedetect. Runs synchronously.
+// @Tags Video
 // @Security ApiKeyAuth
+// @Accept json
 // @Produce json
-// @Param id path string true "Job ID"
-// @Success 200 {object} models.JobStatusResponse
-// @Failure 404 {object} models.ErrorResponse
+// @Param request body models.SilenceRequest true "Silence detection request"
+// @Success 200 {object} models.SilenceDetectResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
-// @Router /api/v1/jobs/{id} [get]
-func (h *Handler) GetJobStatus(c fiber.Ctx) error {
-	jobID := c.Params("id")
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/video/silence/detect [post]
+func (h *Handler) DetectSilence(c fiber.Ctx) error {
+	req, errResp := h.bindSilenceRequest(c)
+	if errResp != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResp)
+	}
+	if err := h.checkInputs(req.VideoPath); err != nil {
+		return uploadInputError(c, err)
+	}
 
-	job, exists := h.jobStore.Get(jobID)
-	if !exists {
-		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
-			Error:   "Job not found",
-			Message: fmt.Sprintf("Job with ID %s does not exist", jobID),
+	silences, duration, err := h.executor.DetectSilence(c.Context(), *req)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Silence detection failed",
+			Message: err.Error(),
 		})
 	}
 
-	return c.JSON(job.GetStatus())
+	return c.JSON(models.SilenceDetectResponse{
+		VideoPath: req.VideoPath,
+		Duration:  duration,
+		Silences:  silences,
+	})
 }
 
-// DownloadOutput godoc
-// @Summary Download completed job output
-// @Description Download the output file from a completed processing job
-// @Tags Jobs
-// @Produce octet-stream
-// @Param id path string true "Job ID"
-// @Success 200 {file} string
-// @Failure 404 {object} models.ErrorResponse "Job not found"
-// @Failure 202 {object} models.ErrorResponse "Job not yet completed"
-// @Failure 500 {object} models.ErrorResponse "File not accessible"
-// @Router /api/v1/jobs/{id}/download [get]
+// ProbeMedia godoc
+// @Summary Inspect a media file
+// @Description Report the container format, duration, bitrate, and streams of a media file, with their codecs, resolution, frame rate, rotation, and bitrates, as read by ffprobe. Runs synchronously.
+// @Tags Video
 // @Security ApiKeyAuth
-func (h *Handler) DownloadOutput(c fiber.Ctx) error {
-	jobID := c.Params("id")
+// @Accept json
+// @Produce json
+// @Param request body models.ProbeRequest true "Probe request"
+// @Success 200 {object} models.MediaProbe
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Router /api/v1/video/probe [post]
+func (h *Handler) ProbeMedia(c fiber.Ctx) error {
+	var req models.ProbeRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+	if req.FilePath == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "file_path is required",
+		})
+	}
 
-	job, exists := h.jobStore.Get(jobID)
-	if !exists {
+	if err := h.checkInputs(req.FilePath); err != nil {
+		return uploadInputError(c, err)
+	}
+	if err := ffmpeg.ValidateFile(req.FilePath); err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
-			Error:   "Job not found",
-			Message: fmt.Sprintf("Job with ID %s does not exist", jobID),
+			Error:   "File not found",
+			Message: err.Error(),
 		})
 	}
 
-	status := job.GetStatus()
-
-	// Check if job is completed
-	if status.Status != models.JobStatusCompleted {
-		return c.Status(fiber.StatusAccepted).JSON(models.ErrorResponse{
-			Error:   "Job not completed",
-			Message: fmt.Sprintf("Job is currently %s. Please wait for it to complete.", status.Status),
+	probe, err := h.executor.Probe(c.Context(), req.FilePath)
+	if err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.ErrorResponse{
+			Error:   "Unreadable media",
+			Message: err.Error(),
 		})
 	}
 
-	// Check if output path is set
-	if status.OutputPath == "" {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   "No output file",
-			Message: "Job completed but no output file was generated",
+	return c.JSON(probe)
+}
+
+// AnalyzeLoudness godoc
+// @Summary Measure the loudness of a file
+// @Description Report the integrated loudness, loudness range, and true peak (EBU R128, ebur128) and the sample peak, RMS levels, and DC offset (astats) of the audio of a file. With target_lufs or max_true_peak, also report whether the file meets them. Runs synchronously.
+// @Tags Video
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.LoudnessRequest true "Loudness request"
+// @Success 200 {object} models.LoudnessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/video/loudness [post]
+func (h *Handler) AnalyzeLoudness(c fiber.Ctx) error {
+	var req models.LoudnessRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
 		})
 	}
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := h.checkInputs(req.FilePath); err != nil {
+		return uploadInputError(c, err)
+	}
 
-	// Verify file exists
-	if _, err := os.Stat(status.OutputPath); os.IsNotExist(err) {
-		logger.Error("Output file not found for job %s: %s", jobID, status.OutputPath)
+	report, err := h.executor.AnalyzeLoudness(c.Context(), req)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   "File not found",
-			Message: "The output file no longer exists on the server",
+			Error:   "Loudness analysis failed",
+			Message: err.Error(),
 		})
 	}
+	report.Compliant, report.Issues = req.Check(report)
 
-	// Get filename from path
-	filename := filepath.Base(status.OutputPath)
+	return c.JSON(report)
+}
 
-	// Set download headers
+// MeasureQuality godoc
+// @Summary Measure the quality of an encoded video
+// @Description Compare an encoded video against its source frame by frame with VMAF (libvmaf), PSNR, and SSIM, and report the scores. The encoded video is scaled to the resolution of the source. Runs synchronously.
+// @Tags Video
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.QualityRequest true "Quality request"
+// @Success 200 {object} models.QualityResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/video/quality [post]
+func (h *Handler) MeasureQuality(c fiber.Ctx) error {
+	var req models.QualityRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := h.checkInputs(req.InputPaths()...); err != nil {
+		return uploadInputError(c, err)
+	}
+
+	result, err := h.executor.MeasureQuality(c.Context(), req)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Quality measurement failed",
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(result)
+}
+
+// RemoveSilence godoc
+// @Summary Remove silent ranges
+// @Description Produce a cut-down video with all silent ranges removed
+// @Tags Video
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.SilenceRequest true "Silence removal request"
+// @Success 202 {object} models.JobResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/video/silence/remove [post]
+func (h *Handler) RemoveSilence(c fiber.Ctx) error {
+	req, errResp := h.bindSilenceRequest(c)
+	if errResp != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResp)
+	}
+	if err := h.checkVideoPath(req.VideoPath); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+
+	if err := h.checkInputs(localPaths(req.VideoPath)...); err != nil {
+		return uploadInputError(c, err)
+	}
+	if err := h.executor.CheckInputLimits(c.Context(), pathSegments(req.VideoPath)); err != nil {
+		return inputLimitError(c, err)
+	}
+
+	if err := h.checkDelivery(&req.DeliveryOptions); err != nil {
+		return deliveryError(c, err)
+	}
+
+	job, response := h.createAndStartJob(c, req.OutputOptions, req.DeliveryOptions)
+	h.startJob(job, models.WorkSilence, *req)
+
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// bindSilenceRequest parses and validates a silence request from the JSON body
+func (h *Handler) bindSilenceRequest(c fiber.Ctx) (*models.SilenceRequest, *models.ErrorResponse) {
+	var req models.SilenceRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return nil, &models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		}
+	}
+
+	if req.VideoPath == "" {
+		return nil, &models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "video_path is required",
+		}
+	}
+
+	if err := h.presets.Resolve(&req.OutputOptions); err != nil {
+		return nil, &models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		return nil, &models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		}
+	}
+
+	return &req, nil
+}
+
+// ConvertToVertical godoc
+// @Summary Convert a video to vertical (9:16)
+// @Description Scale a video into a vertical frame (1080x1920 by default) over a blurred, zoomed copy of itself
+// @Tags Video
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.VerticalRequest true "Vertical conversion request"
+// @Success 202 {object} models.JobResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/video/vertical [post]
+func (h *Handler) ConvertToVertical(c fiber.Ctx) error {
+	var req models.VerticalRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+
+	if req.VideoPath == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "video_path is required",
+		})
+	}
+	if err := h.checkVideoPath(req.VideoPath); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+
+	if err := h.presets.Resolve(&req.OutputOptions); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := h.checkDelivery(&req.DeliveryOptions); err != nil {
+		return deliveryError(c, err)
+	}
+
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+
+	if err := h.checkInputs(localPaths(req.VideoPath)...); err != nil {
+		return uploadInputError(c, err)
+	}
+	if err := h.executor.CheckInputLimits(c.Context(), pathSegments(req.VideoPath)); err != nil {
+		return inputLimitError(c, err)
+	}
+
+	job, response := h.createAndStartJob(c, req.OutputOptions, req.DeliveryOptions)
+	h.startJob(job, models.WorkVertical, req)
+
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// ListPresets godoc
+// @Summary List encoding presets
+// @Description List the named encoding presets that can be selected with preset_name
+// @Tags Presets
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.PresetsResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/presets [get]
+func (h *Handler) ListPresets(c fiber.Ctx) error {
+	return c.JSON(models.PresetsResponse{
+		Presets: h.presets.List(),
+	})
+}
+
+// GetUsage godoc
+// @Summary Get API key usage
+// @Description Get the calling API key's processed video minutes for the current month and its remaining quota
+// @Tags Usage
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.UsageResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/usage [get]
+func (h *Handler) GetUsage(c fiber.Ctx) error {
+	key := requestKey(c)
+	period, record := h.usage.Get(key.Name)
+
+	response := models.UsageResponse{
+		KeyName:       key.Name,
+		Period:        period,
+		Jobs:          record.Jobs,
+		InputMinutes:  record.InputSeconds / 60,
+		OutputMinutes: record.OutputMinutes(),
+		QuotaMinutes:  key.QuotaMinutes,
+	}
+	if key.QuotaMinutes > 0 {
+		remaining := max(key.QuotaMinutes-record.OutputMinutes(), 0)
+		response.RemainingMinutes = &remaining
+	}
+
+	return c.JSON(response)
+}
+
+// GetJobStatus godoc
+// @Summary Get job status
+// @Description Get the status of a video processing job. Jobs waiting for an FFmpeg slot report their queue position, and processing jobs an estimated time to finish.
+// @Tags Jobs
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.JobStatusResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/jobs/{id} [get]
+func (h *Handler) GetJobStatus(c fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	job, exists := h.jobStore.Get(jobID)
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Job not found",
+			Message: fmt.Sprintf("Job with ID %s does not exist", jobID),
+		})
+	}
+
+	return c.JSON(h.jobStore.QueueStatus(job, h.executor.Queue(), h.executor.Slots()))
+}
+
+// CancelJob godoc
+// @Summary Cancel a job
+// @Description Cancel a scheduled, pending or processing job, stopping any running FFmpeg command
+// @Tags Jobs
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.JobStatusResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/jobs/{id}/cancel [post]
+func (h *Handler) CancelJob(c fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	job, exists := h.jobStore.Get(jobID)
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Job not found",
+			Message: fmt.Sprintf("Job with ID %s does not exist", jobID),
+		})
+	}
+
+	if !job.Cancel() {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error:   "Job already finished",
+			Message: fmt.Sprintf("Job is %s and cannot be cancelled", job.GetStatus().Status),
+		})
+	}
+	_ = h.jobStore.Update(job)
+	job.Logger().Info("Job %s cancelled", job.ID)
+
+	return c.JSON(job.GetStatus())
+}
+
+// DownloadOutput godoc
+// @Summary Download completed job output
+// @Description Download the output file from a completed processing job
+// @Tags Jobs
+// @Produce octet-stream
+// @Param id path string true "Job ID"
+// @Success 200 {file} string
+// @Failure 404 {object} models.ErrorResponse "Job not found"
+// @Failure 202 {object} models.ErrorResponse "Job not yet completed"
+// @Failure 410 {object} models.ErrorResponse "Job outputs expired"
+// @Failure 403 {object} models.ErrorResponse "Output outside the output directory"
+// @Failure 500 {object} models.ErrorResponse "File not accessible"
+// @Router /api/v1/jobs/{id}/download [get]
+// @Security ApiKeyAuth
+func (h *Handler) DownloadOutput(c fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	job, exists := h.jobStore.Get(jobID)
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Job not found",
+			Message: fmt.Sprintf("Job with ID %s does not exist", jobID),
+		})
+	}
+
+	jobLog := job.Logger()
+	status := job.GetStatus()
+
+	if status.Status == models.JobStatusExpired {
+		return c.Status(fiber.StatusGone).JSON(models.ErrorResponse{
+			Error:   "Job expired",
+			Message: fmt.Sprintf("The outputs of the job were deleted at %s", status.ExpiresAt.Format(time.RFC3339)),
+		})
+	}
+
+	// Check if job is completed
+	if status.Status != models.JobStatusCompleted {
+		return c.Status(fiber.StatusAccepted).JSON(models.ErrorResponse{
+			Error:   "Job not completed",
+			Message: fmt.Sprintf("Job is currently %s. Please wait for it to complete.", status.Status),
+		})
+	}
+
+	// Check if output path is set
+	if status.OutputPath == "" {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "No output file",
+			Message: "Job completed but no output file was generated",
+		})
+	}
+
+	// Jobs only write to OUTPUT_DIR; anything else in a job file was not written by GoVid
+	if err := h.outputs.Check(status.OutputPath); err != nil {
+		jobLog.Error("Refusing output of job %s: %v", jobID, err)
+		return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Error:   "Path not allowed",
+			Message: "The output file of this job is outside the output directory",
+		})
+	}
+
+	// Verify file exists
+	if _, err := os.Stat(status.OutputPath); os.IsNotExist(err) {
+		jobLog.Error("Output file not found for job %s: %s", jobID, status.OutputPath)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "File not found",
+			Message: "The output file no longer exists on the server",
+		})
+	}
+
+	// Get filename from path
+	filename := filepath.Base(status.OutputPath)
+
+	// Set download headers
 	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
 	c.Set("Content-Type", "application/octet-stream")
 
-	logger.Info("Downloading output for job %s: %s", jobID, status.OutputPath)
+	jobLog.Info("Downloading output for job %s: %s", jobID, status.OutputPath)
 
 	// Send the file
 	return c.SendFile(status.OutputPath)
 }
 
+// ServeStoredFile godoc
+// @Summary Download a file of the local storage backend
+// @Description Serve a combine output or shared job output stored by the local storage backend. The link returned as s3_url carries its own expiry and signature, so no API key is needed.
+// @Tags Jobs
+// @Produce octet-stream
+// @Param key path string true "Stored file key, e.g. combined/{job_id}/{file}"
+// @Param expires query int true "Expiry time of the link (Unix seconds)"
+// @Param signature query string true "Link signature"
+// @Success 200 {file} string
+// @Failure 403 {object} models.ErrorResponse "Invalid or expired link"
+// @Failure 404 {object} models.ErrorResponse "File not found"
+// @Router /api/v1/files/{key} [get]
+func (h *Handler) ServeStoredFile(c fiber.Ctx) error {
+	local, ok := h.uploader.(*storage.LocalUploader)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Not found",
+			Message: "Files are only served by the local storage backend",
+		})
+	}
+
+	path, err := local.Resolve(c.Params("*"), c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Error:   "Invalid link",
+			Message: err.Error(),
+		})
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "File not found",
+			Message: "The file no longer exists on the server",
+		})
+	}
+
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(path)))
+	return c.SendFile(path)
+}
+
+// GetJobLogs godoc
+// @Summary Get job FFmpeg logs
+// @Description Get the command lines and stderr output of the FFmpeg commands a job has run so far
+// @Tags Jobs
+// @Produce plain
+// @Param id path string true "Job ID"
+// @Success 200 {string} string "FFmpeg output; empty if the job has not run FFmpeg yet"
+// @Failure 404 {object} models.ErrorResponse "Job not found"
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/jobs/{id}/logs [get]
+// @Security ApiKeyAuth
+func (h *Handler) GetJobLogs(c fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	job, exists := h.jobStore.Get(jobID)
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Job not found",
+			Message: fmt.Sprintf("Job with ID %s does not exist", jobID),
+		})
+	}
+
+	c.Set("Content-Type", "text/plain; charset=utf-8")
+
+	content, err := os.ReadFile(h.jobStore.LogPath(job.ID))
+	if err != nil && !os.IsNotExist(err) {
+		job.Logger().Error("Failed to read log of job %s: %v", job.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to read job logs",
+			Message: err.Error(),
+		})
+	}
+
+	return c.Send(content)
+}
+
 // CreateS3Link godoc
-// @Summary Upload job output to S3 and get shareable link
-// @Description Upload a completed job's output file to S3 and return the S3 URL. The local file will be deleted after successful upload.
+// @Summary Upload job output to storage and get shareable link
+// @Description Upload a completed job's output file to the storage backend (S3 or GCS) and return its URL as s3_url. The local file will be deleted after successful upload.
 // @Tags Jobs
+// @Accept json
 // @Produce json
 // @Param id path string true "Job ID"
+// @Param request body models.StorageOptions false "Cache-Control and metadata of the uploaded object"
 // @Success 200 {object} models.JobStatusResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse "Job not found"
 // @Failure 202 {object} models.ErrorResponse "Job not yet completed"
-// @Failure 500 {object} models.ErrorResponse "S3 upload failed or file not accessible"
+// @Failure 410 {object} models.ErrorResponse "Job outputs expired"
+// @Failure 403 {object} models.ErrorResponse "Output outside the output directory"
+// @Failure 500 {object} models.ErrorResponse "Upload failed or file not accessible"
 // @Router /api/v1/jobs/{id}/create-link [post]
 // @Security ApiKeyAuth
 func (h *Handler) CreateS3Link(c fiber.Ctx) error {
 	jobID := c.Params("id")
 
-	// Check if S3 uploader is available
-	if h.s3Uploader == nil {
+	// The body with storage options is optional
+	var storageOpts models.StorageOptions
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&storageOpts); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request body",
+				Message: err.Error(),
+			})
+		}
+		if err := storageOpts.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	// Check if the storage backend is available
+	if h.uploader == nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   "S3 uploader not configured",
-			Message: "S3 configuration is missing or invalid",
+			Error:   "Storage not configured",
+			Message: "Storage configuration is missing or invalid",
 		})
 	}
 
@@ -524,8 +1307,16 @@ func (h *Handler) CreateS3Link(c fiber.Ctx) error {
 		})
 	}
 
+	jobLog := job.Logger()
 	status := job.GetStatus()
 
+	if status.Status == models.JobStatusExpired {
+		return c.Status(fiber.StatusGone).JSON(models.ErrorResponse{
+			Error:   "Job expired",
+			Message: fmt.Sprintf("The outputs of the job were deleted at %s", status.ExpiresAt.Format(time.RFC3339)),
+		})
+	}
+
 	// Check if job is completed
 	if status.Status != models.JobStatusCompleted {
 		return c.Status(fiber.StatusAccepted).JSON(models.ErrorResponse{
@@ -536,7 +1327,7 @@ func (h *Handler) CreateS3Link(c fiber.Ctx) error {
 
 	// Check if S3 URL already exists
 	if status.S3URL != "" {
-		logger.Info("S3 URL already exists for job %s: %s", jobID, status.S3URL)
+		jobLog.Info("S3 URL already exists for job %s: %s", jobID, status.S3URL)
 		return c.JSON(status)
 	}
 
@@ -548,226 +1339,715 @@ func (h *Handler) CreateS3Link(c fiber.Ctx) error {
 		})
 	}
 
+	// Jobs only write to OUTPUT_DIR; anything else in a job file was not written by GoVid
+	if err := h.outputs.Check(status.OutputPath); err != nil {
+		jobLog.Error("Refusing output of job %s: %v", jobID, err)
+		return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Error:   "Path not allowed",
+			Message: "The output file of this job is outside the output directory",
+		})
+	}
+
 	// Verify file exists
 	if _, err := os.Stat(status.OutputPath); os.IsNotExist(err) {
-		logger.Error("Output file not found for job %s: %s", jobID, status.OutputPath)
+		jobLog.Error("Output file not found for job %s: %s", jobID, status.OutputPath)
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error:   "File not found",
 			Message: "The output file no longer exists on the server",
 		})
 	}
 
-	// Upload to S3
+	// Upload to storage
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(h.cfg.JobTimeout)*time.Second)
 	defer cancel()
 
-	logger.Info("Uploading output file to S3 for job %s: %s", jobID, status.OutputPath)
-	objectName := storage.GetObjectName(jobID, status.OutputPath)
-	s3URL, err := h.s3Uploader.Upload(ctx, status.OutputPath, objectName)
+	ctx = logger.NewContext(ctx, jobLog)
+
+	jobLog.Info("Uploading output file to %s for job %s: %s", h.cfg.StorageBackend, jobID, status.OutputPath)
+	storageOpts.OriginalName = originalName(status.OutputPath)
+	s3URL, err := h.delivery.Move(ctx, job, status.OutputPath, storageOpts, nil)
 	if err != nil {
-		logger.Error("Failed to upload to S3 for job %s: %v", jobID, err)
+		jobLog.Error("Failed to upload to %s for job %s: %v", h.cfg.StorageBackend, jobID, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   "S3 upload failed",
+			Error:   "Upload failed",
 			Message: err.Error(),
 		})
 	}
 
-	logger.Info("Successfully uploaded to S3 for job %s: %s", jobID, s3URL)
-
-	// Update job with S3 URL
-	job.SetS3URL(s3URL)
+	jobLog.Info("Successfully uploaded to %s for job %s: %s", h.cfg.StorageBackend, jobID, s3URL)
 	_ = h.jobStore.Update(job)
 
-	// Delete local file after successful upload
-	if err := os.Remove(status.OutputPath); err != nil {
-		logger.Error("Failed to delete local file for job %s: %v", jobID, err)
-		// Don't fail the request, just log the error
-	} else {
-		logger.Info("Deleted local file for job %s", jobID)
-		// Clear output path since file is deleted
-		job.SetOutput("")
-		_ = h.jobStore.Update(job)
+	// Return updated status
+	return c.JSON(job.GetStatus())
+}
+
+// PresignLink godoc
+// @Summary Get a fresh presigned link to a stored job output
+// @Description Mint a new presigned URL of a job output uploaded to the storage backend, valid for STORAGE_LINK_TTL_SECONDS, and return it as s3_url, along with fresh thumbnail_url and metadata_url if sidecars were uploaded. Use it when the link in the job status or webhook has expired or the bucket is private.
+// @Tags Jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.JobStatusResponse
+// @Failure 404 {object} models.ErrorResponse "Job not found"
+// @Failure 409 {object} models.ErrorResponse "Job output not in storage"
+// @Failure 500 {object} models.ErrorResponse "Presigning failed"
+// @Router /api/v1/jobs/{id}/presign [post]
+// @Security ApiKeyAuth
+func (h *Handler) PresignLink(c fiber.Ctx) error {
+	jobID := c.Params("id")
+
+	// Check if the storage backend is available
+	if h.uploader == nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Storage not configured",
+			Message: "Storage configuration is missing or invalid",
+		})
 	}
 
-	// Return updated status
+	job, exists := h.jobStore.Get(jobID)
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Job not found",
+			Message: fmt.Sprintf("Job with ID %s does not exist", jobID),
+		})
+	}
+
+	bucket, storageKey := job.GetStorageKey()
+	if storageKey == "" {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error:   "Output not in storage",
+			Message: "The job has no output in the storage backend; combine jobs upload theirs, other jobs need create-link first",
+		})
+	}
+
+	link, expires, err := h.delivery.Presign(c.Context(), bucket, storageKey)
+	if err != nil {
+		job.Logger().Error("Failed to presign link for job %s: %v", jobID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Presigning failed",
+			Message: err.Error(),
+		})
+	}
+
+	job.SetS3URL(link, bucket, storageKey, expires)
+
+	// Refresh the links to sidecars uploaded with the output
+	status := job.GetStatus()
+	if status.ThumbnailURL != "" || status.MetadataURL != "" {
+		thumbnailKey, metadataKey := delivery.SidecarKeys(storageKey)
+		thumbnailURL, metadataURL := status.ThumbnailURL, status.MetadataURL
+		if thumbnailURL != "" {
+			thumbnailURL, _, err = h.delivery.Presign(c.Context(), bucket, thumbnailKey)
+		}
+		if err == nil && metadataURL != "" {
+			metadataURL, _, err = h.delivery.Presign(c.Context(), bucket, metadataKey)
+		}
+		if err != nil {
+			job.Logger().Error("Failed to presign sidecar links for job %s: %v", jobID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "Presigning failed",
+				Message: err.Error(),
+			})
+		}
+		job.SetSidecarURLs(thumbnailURL, metadataURL)
+	}
+	_ = h.jobStore.Update(job)
+
 	return c.JSON(job.GetStatus())
 }
 
-// createAndStartJob is a helper to create a job and return response
-func (h *Handler) createAndStartJob() (*models.Job, models.JobResponse) {
+// createAndStartJob is a helper to create a job owned by the request's API key and return response. A job
+// whose run_at is in the future is scheduled, and startJob leaves it to the scheduler. deliveryOpts sets where
+// the job's result is sent.
+func (h *Handler) createAndStartJob(c fiber.Ctx, opts models.OutputOptions, deliveryOpts models.DeliveryOptions) (*models.Job, models.JobResponse) {
 	jobID := uuid.New().String()
 	job := models.NewJob(jobID)
+	job.CreatedBy = requestKey(c).Name
+	job.RequestID = requestID(c)
+	job.TraceContext = requestSpanContext(c)
+	job.DependsOn = jobParents(c)
+	job.Labels = opts.Labels
+	job.TTL = opts.OutputTTL(h.cfg.OutputTTLSeconds)
+	job.SetDelivery(deliveryOpts)
+	message := "Job created successfully"
+	if runAt := opts.RunAtTime(); runAt.After(time.Now()) {
+		job.RunAt = runAt
+		job.Schedule()
+		message = "Job scheduled successfully"
+	}
 	h.jobStore.Add(job)
 
 	response := models.JobResponse{
 		JobID:     jobID,
-		Status:    models.JobStatusPending,
-		Message:   "Job created successfully",
+		Status:    job.GetStatus().Status,
+		Message:   message,
 		CreatedAt: job.CreatedAt,
 	}
 
 	return job, response
 }
 
-// processJobCommon handles common job processing logic
-func (h *Handler) processJobCommon(job *models.Job, jobType string, processFn func(context.Context, string) error) {
-	job.UpdateStatus(models.JobStatusProcessing)
-	job.UpdateProgress(10)
+// startJob records the work of kind with req on the job and runs it in the background. The work runs again
+// for retries, and on workers, so req must only use inputs that outlive the job.
+func (h *Handler) startJob(job *models.Job, kind string, req any) {
+	work, err := models.NewJobWork(kind, req)
+	if err != nil {
+		job.SetError(err.Error())
+		_ = h.jobStore.Update(job)
+		return
+	}
+	h.startWork(job, work)
+}
+
+// startWork records work on the job and runs it in the background
+func (h *Handler) startWork(job *models.Job, work *models.JobWork) {
+	job.SetWork(work)
 	_ = h.jobStore.Update(job)
+	h.dispatchJob(job)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(h.cfg.JobTimeout)*time.Second)
+// dispatchJob runs the work of a job in the background, right away or, for a scheduled job, once the
+// scheduler promotes the job to pending at its run_at. A scheduled job cancelled before then is not run. In
+// the api run mode, the pending job is left in the job store for a worker to claim.
+func (h *Handler) dispatchJob(job *models.Job) {
+	if job.GetStatus().Status == models.JobStatusScheduled {
+		job.Logger().Info("Job %s is scheduled to start at %s", job.ID, job.RunAt.Format(time.RFC3339))
+		h.scheduler.At(job.RunAt, func() {
+			if !job.Promote() {
+				return
+			}
+			_ = h.jobStore.Update(job)
+			h.dispatchJob(job)
+		})
+		return
+	}
+	if h.leftToWorkers() {
+		job.Logger().Info("Job %s is left to the workers", job.ID)
+		return
+	}
+
+	h.jobWG.Add(1)
+	go func() {
+		defer h.jobWG.Done()
+		h.runJob(job)
+	}()
+}
+
+// withFFmpegLog returns a context whose FFmpeg commands are recorded in the job's log, and a function that closes the log
+func (h *Handler) withFFmpegLog(ctx context.Context, job *models.Job) (context.Context, func()) {
+	file, err := h.jobStore.OpenLog(job.ID)
+	if err != nil {
+		logger.FromContext(ctx).Warn("Failed to open log of job %s: %v", job.ID, err)
+		return ctx, func() {}
+	}
+	return ffmpeg.WithLog(ctx, file), func() { file.Close() }
+}
+
+// processJobCommon handles common job processing logic
+func (h *Handler) processJobCommon(job *models.Job, jobType string, opts models.OutputOptions, inputs []string, processFn func(context.Context, string) error) {
+	timeout := opts.JobTimeout(h.cfg.JobTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
+	jobLog := job.Logger()
+	ctx = logger.NewContext(ctx, jobLog)
+	ctx, span := job.StartSpan(ctx, "job."+jobType)
+	defer job.EndSpan(span)
+	ctx, closeLog := h.withFFmpegLog(ctx, job)
+	defer closeLog()
+
+	if !job.Start(cancel) {
+		jobLog.Info("Job %s was cancelled before it started", job.ID)
+		return
+	}
+	job.UpdateProgress(10)
+	_ = h.jobStore.Update(job)
 
-	outputPath := filepath.Join(h.cfg.OutputDir, fmt.Sprintf("%s.mp4", job.ID))
+	outputPath, err := h.delivery.OutputPath(job, opts)
+	if err != nil {
+		jobLog.Error("Failed to name the output of job %s: %v", job.ID, err)
+		job.SetError(err.Error())
+		_ = h.jobStore.Update(job)
+		h.delivery.Notify(ctx, job)
+		return
+	}
 
-	logger.Info("Starting %s job %s", jobType, job.ID)
+	jobLog.Info("Starting %s job %s", jobType, job.ID)
+	job.SetInput(jobType, h.executor.InputSeconds(ctx, inputs...))
 	job.UpdateProgress(30)
 	_ = h.jobStore.Update(job)
+	// FFmpeg takes the job from 30% to 90%
+	ctx = ffmpeg.WithProgress(ctx, func(fraction float64) {
+		job.AdvanceProgress(30 + int(fraction*60))
+	})
+	ctx = ffmpeg.WithStart(ctx, job.MarkStarted)
 
 	if err := processFn(ctx, outputPath); err != nil {
-		logger.Error("%s job %s failed: %v", jobType, job.ID, err)
+		err = models.TimeoutError(ctx, timeout, err)
+		jobLog.Error("%s job %s failed: %v", jobType, job.ID, err)
 		job.SetError(err.Error())
 		_ = h.jobStore.Update(job)
+		h.delivery.Notify(ctx, job)
 		return
 	}
 
-	job.UpdateProgress(100)
+	h.recordUsage(ctx, job, outputPath)
+
 	job.SetOutput(outputPath)
+	if err := h.delivery.Upload(ctx, job, outputPath); err != nil {
+		err = models.TimeoutError(ctx, timeout, err)
+		jobLog.Error("Failed to upload output of job %s: %v", job.ID, err)
+		job.SetError(fmt.Sprintf("Failed to upload to %s: %v", h.cfg.StorageBackend, err))
+		_ = h.jobStore.Update(job)
+		h.delivery.Notify(ctx, job)
+		return
+	}
+
+	job.UpdateProgress(100)
 	job.UpdateStatus(models.JobStatusCompleted)
 	_ = h.jobStore.Update(job)
-	logger.Info("%s job %s completed successfully", jobType, job.ID)
+	jobLog.Info("%s job %s completed successfully", jobType, job.ID)
+	h.delivery.Notify(ctx, job)
+}
+
+// recordUsage adds the input duration recorded with SetInput and the output duration of a finished job to its
+// API key's usage. An output that cannot be probed counts as zero seconds.
+func (h *Handler) recordUsage(ctx context.Context, job *models.Job, outputPath string) {
+	jobLog := logger.FromContext(ctx)
+
+	outputSeconds, err := h.executor.MediaDuration(ctx, outputPath)
+	if err != nil {
+		jobLog.Warn("Failed to measure output of job %s for usage: %v", job.ID, err)
+	}
+
+	h.usage.Add(job.CreatedBy, job.InputSeconds, outputSeconds)
+}
+
+// segmentPaths returns the file paths of segments
+func segmentPaths(segments []models.VideoSegment) []string {
+	paths := make([]string, len(segments))
+	for i, seg := range segments {
+		paths[i] = seg.FilePath
+	}
+	return paths
+}
+
+// localPaths returns the paths that are not URLs
+func localPaths(paths ...string) []string {
+	return slices.DeleteFunc(slices.Clone(paths), downloader.IsURL)
+}
+
+// pathSegments wraps file paths as untrimmed segments
+func pathSegments(paths ...string) []models.VideoSegment {
+	segments := make([]models.VideoSegment, len(paths))
+	for i, path := range paths {
+		segments[i] = models.VideoSegment{FilePath: path}
+	}
+	return segments
+}
+
+// checkInputs fails if any of paths is a file that jobs may not use: with pathpolicy.ErrNotAllowed if it is
+// outside the input directories, with uploads.ErrExpired if it is an upload past its TTL, and while uploads are
+// scanned, with uploads.ErrNotScanned or uploads.ErrInfected if it is an upload without a clean scan. URLs are
+// refused, so inputs that jobs download are left out with localPaths. Outputs of jobs that have not completed yet,
+// given as job:<id>, are skipped; they are in the output directory once the job has them.
+func (h *Handler) checkInputs(paths ...string) error {
+	for _, path := range paths {
+		if _, ok := models.JobOutputID(path); ok {
+			continue
+		}
+		if err := h.paths.Check(path); err != nil {
+			return err
+		}
+		if err := uploads.CheckExpiry(h.cfg.UploadDir, path); err != nil {
+			return err
+		}
+		if h.scanner != nil {
+			if err := uploads.CheckScanned(h.cfg.UploadDir, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// uploadInputError sends the error response for a job input refused by checkInputs
+func uploadInputError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, pathpolicy.ErrNotAllowed):
+		return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Error:   "Path not allowed",
+			Message: err.Error(),
+		})
+	case errors.Is(err, uploads.ErrExpired):
+		return c.Status(fiber.StatusGone).JSON(models.ErrorResponse{
+			Error:   "Upload expired",
+			Message: err.Error(),
+		})
+	case errors.Is(err, uploads.ErrInfected):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.ErrorResponse{
+			Error:   "Upload quarantined",
+			Message: err.Error(),
+		})
+	default:
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error:   "Upload not scanned",
+			Message: err.Error(),
+		})
+	}
+}
+
+// inputLimitError sends the error response for a request whose inputs exceed the input limits
+func inputLimitError(c fiber.Ctx, err error) error {
+	response := models.ErrorResponse{
+		Error:   "Input exceeds limits",
+		Message: err.Error(),
+	}
+	var limitErr *ffmpeg.InputLimitError
+	if errors.As(err, &limitErr) {
+		response.Input = limitErr.Input
+	}
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(response)
+}
+
+// processMergeJob processes a video merge job
+func (h *Handler) processMergeJob(job *models.Job, req models.MergeVideoRequest) {
+	if !h.awaitJobOutputs(job, segmentPathRefs(req.Segments)...) {
+		return
+	}
+	inputs := segmentPaths(req.Segments)
+	job.AddFiles(inputs...)
+	var downloaded []string
+	defer func() { h.downloader.CleanupFiles(downloaded) }()
+
+	h.processJobCommon(job, "merge", req.OutputOptions, inputs, func(ctx context.Context, outputPath string) error {
+		segments, files, err := h.fetchRemoteSegments(ctx, req.Segments)
+		if err != nil {
+			return err
+		}
+		downloaded = files
+		job.AddFiles(files...)
+		copy(inputs, segmentPaths(segments)) // usage is measured on the local copies
+		return h.executor.MergeVideos(ctx, segments, req.OutputOptions, outputPath)
+	})
+	h.cleanupInputs(job, req.CleanupInputs, segmentPaths(req.Segments)...)
+}
+
+// fetchRemoteSegments downloads the segments given as URLs, returning the segments with local file
+// paths and the downloaded files to remove afterwards
+func (h *Handler) fetchRemoteSegments(ctx context.Context, segments []models.VideoSegment) ([]models.VideoSegment, []string, error) {
+	var urls []string
+	var indexes []int
+	for i, seg := range segments {
+		if downloader.IsURL(seg.FilePath) {
+			urls = append(urls, seg.FilePath)
+			indexes = append(indexes, i)
+		}
+	}
+	if len(urls) == 0 {
+		return segments, nil, nil
+	}
+
+	logger.FromContext(ctx).Info("Downloading %d segments", len(urls))
+	files, err := h.downloader.DownloadVideosInOrder(ctx, urls, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch segments: %w", err)
+	}
+	if err := h.probeDownloads(ctx, urls, files); err != nil {
+		h.downloader.CleanupFiles(files)
+		return nil, nil, err
+	}
+
+	local := slices.Clone(segments)
+	for i, index := range indexes {
+		local[index].FilePath = files[i]
+	}
+	if err := h.executor.CheckInputLimits(ctx, local); err != nil {
+		h.downloader.CleanupFiles(files)
+		return nil, nil, err
+	}
+	return local, files, nil
+}
+
+// probeDownloads checks that every downloaded file is a readable video before FFmpeg gets it
+func (h *Handler) probeDownloads(ctx context.Context, urls, files []string) error {
+	for i, file := range files {
+		if err := h.executor.CheckVideo(ctx, file); err != nil {
+			return fmt.Errorf("downloaded video %d (%s) cannot be read: %w", i, urls[i], err)
+		}
+	}
+	return nil
+}
+
+// checkVideoPath checks that a video_path given as a URL may be downloaded
+func (h *Handler) checkVideoPath(videoPath string) error {
+	if downloader.IsURL(videoPath) {
+		return h.downloader.CheckURL(videoPath)
+	}
+	return nil
 }
 
-// processMergeJob processes a video merge job
-func (h *Handler) processMergeJob(job *models.Job, req models.MergeVideoRequest) {
-	h.processJobCommon(job, "merge", func(ctx context.Context, outputPath string) error {
-		return h.executor.MergeVideos(ctx, req.Segments, outputPath)
+// processVideoJob runs a job on a single input video, downloading it first when videoPath is a URL
+// (such as the input_url of a direct upload). processFn gets the local path of the video.
+func (h *Handler) processVideoJob(job *models.Job, jobType string, opts models.OutputOptions, videoPath string, processFn func(ctx context.Context, videoPath, outputPath string) error) {
+	inputs := []string{videoPath}
+	job.AddFiles(videoPath)
+	var downloaded []string
+	defer func() { h.downloader.CleanupFiles(downloaded) }()
+
+	h.processJobCommon(job, jobType, opts, inputs, func(ctx context.Context, outputPath string) error {
+		segments, files, err := h.fetchRemoteSegments(ctx, []models.VideoSegment{{FilePath: videoPath}})
+		if err != nil {
+			return err
+		}
+		downloaded = files
+		job.AddFiles(files...)
+		inputs[0] = segments[0].FilePath // usage is measured on the local copy
+		return processFn(ctx, inputs[0], outputPath)
 	})
 }
 
 // processOverlayJob processes an image overlay job
 func (h *Handler) processOverlayJob(job *models.Job, req models.OverlayRequest) {
-	h.processJobCommon(job, "overlay", func(ctx context.Context, outputPath string) error {
-		return h.executor.AddImageOverlay(ctx, req.VideoPath, req.Overlay, outputPath)
+	if !h.awaitJobOutputs(job, overlayInputRefs(&req)...) {
+		return
+	}
+	job.AddFiles(req.InputPaths()[1:]...)
+	h.processVideoJob(job, "overlay", req.OutputOptions, req.VideoPath, func(ctx context.Context, videoPath, outputPath string) error {
+		return h.executor.AddMultipleOverlays(ctx, videoPath, req.ImageOverlays(), req.OutputOptions, outputPath)
 	})
+	h.cleanupInputs(job, req.CleanupInputs, req.InputPaths()...)
 }
 
 // processAudioJob processes a background music job
 func (h *Handler) processAudioJob(job *models.Job, req models.AudioRequest) {
-	h.processJobCommon(job, "audio", func(ctx context.Context, outputPath string) error {
-		return h.executor.AddBackgroundMusic(ctx, req.VideoPath, req.Audio, outputPath)
+	if !h.awaitJobOutputs(job, append([]*string{&req.VideoPath}, audioInputRefs(&req.Audio)...)...) {
+		return
+	}
+	job.AddFiles(req.Audio.Tracks()...)
+	h.processVideoJob(job, "audio", req.OutputOptions, req.VideoPath, func(ctx context.Context, videoPath, outputPath string) error {
+		return h.executor.WithLoudnessNormalization(ctx, req.NormalizeAudio, req.OutputOptions, outputPath, func(target string) error {
+			return h.executor.AddBackgroundMusic(ctx, videoPath, req.Audio, req.OutputOptions, target)
+		})
 	})
+	h.cleanupInputs(job, req.CleanupInputs, req.InputPaths()...)
 }
 
 // processCompleteJob processes a complete video processing job
 func (h *Handler) processCompleteJob(job *models.Job, req models.CompleteProcessRequest) {
-	h.processJobCommon(job, "complete process", func(ctx context.Context, outputPath string) error {
+	refs := segmentPathRefs(req.Segments)
+	for i := range req.Overlays {
+		refs = append(refs, &req.Overlays[i].FilePath)
+	}
+	if req.Audio != nil {
+		refs = append(refs, audioInputRefs(req.Audio)...)
+	}
+	if !h.awaitJobOutputs(job, refs...) {
+		return
+	}
+	job.AddFiles(req.InputPaths()...)
+	h.processJobCommon(job, "complete process", req.OutputOptions, segmentPaths(req.Segments), func(ctx context.Context, outputPath string) error {
 		return h.executor.CompleteProcess(ctx, req, outputPath)
 	})
+	h.cleanupInputs(job, req.CleanupInputs, req.InputPaths()...)
+}
+
+// processSilenceRemovalJob processes a silence removal job
+func (h *Handler) processSilenceRemovalJob(job *models.Job, req models.SilenceRequest) {
+	if !h.awaitJobOutputs(job, &req.VideoPath) {
+		return
+	}
+	input := req.VideoPath
+	h.processVideoJob(job, "silence removal", req.OutputOptions, req.VideoPath, func(ctx context.Context, videoPath, outputPath string) error {
+		req.VideoPath = videoPath
+		return h.executor.RemoveSilence(ctx, req, outputPath)
+	})
+	h.cleanupInputs(job, req.CleanupInputs, input)
+}
+
+// processVerticalJob processes a vertical conversion job
+func (h *Handler) processVerticalJob(job *models.Job, req models.VerticalRequest) {
+	if !h.awaitJobOutputs(job, &req.VideoPath) {
+		return
+	}
+	input := req.VideoPath
+	h.processVideoJob(job, "vertical conversion", req.OutputOptions, req.VideoPath, func(ctx context.Context, videoPath, outputPath string) error {
+		req.VideoPath = videoPath
+		return h.executor.ConvertToVertical(ctx, req, outputPath)
+	})
+	h.cleanupInputs(job, req.CleanupInputs, input)
+}
+
+// cleanupInputs deletes the uploads a job read once it has completed, if requested with cleanup_inputs or
+// CLEANUP_INPUTS. Only files in the upload directory are deleted; failed and cancelled jobs keep their inputs,
+// so they can be retried.
+func (h *Handler) cleanupInputs(job *models.Job, requested *bool, paths ...string) {
+	cleanup := h.cfg.CleanupInputs
+	if requested != nil {
+		cleanup = *requested
+	}
+	if !cleanup || job.GetStatus().Status != models.JobStatusCompleted {
+		return
+	}
+
+	jobLog := job.Logger()
+	for _, path := range paths {
+		if !uploads.InDir(h.cfg.UploadDir, path) {
+			continue
+		}
+		if err := uploads.Delete(h.cfg.UploadDir, filepath.Base(path)); err != nil {
+			if !errors.Is(err, uploads.ErrNotFound) {
+				jobLog.Warn("Failed to delete input %s of job %s: %v", filepath.Base(path), job.ID, err)
+			}
+			continue
+		}
+		jobLog.Info("Deleted input %s of job %s", filepath.Base(path), job.ID)
+	}
 }
 
 // UploadFile godoc
 // @Summary Upload a single file
-// @Description Upload a video, image, or audio file
+// @Description Upload a video, image, or audio file of up to MAX_FILE_SIZE_MB
 // @Tags Upload
 // @Security ApiKeyAuth
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "File to upload"
+// @Param ttl_seconds formData int false "Delete the file this many seconds after upload (default UPLOAD_TTL_SECONDS)"
 // @Success 200 {object} models.UploadResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
 // @Router /api/v1/upload [post]
 func (h *Handler) UploadFile(c fiber.Ctx) error {
-	file, err := c.FormFile("file")
+	// Stream the file to the upload directory under a unique name
+	upload, err := h.streamMultipartUpload(c, "file", h.cfg.UploadDir, 1, func(_ int, filename string) string {
+		return uuid.New().String() + filepath.Ext(filename)
+	})
 	if err != nil {
+		if errors.Is(err, errUploadSave) {
+			logger.Error("Failed to save uploaded file: %v", err)
+		}
+		return uploadErrorResponse(c, err)
+	}
+	if len(upload.paths) == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Error:   "Invalid file upload",
 			Message: "No file provided or invalid file",
 		})
 	}
-
-	// Generate unique filename
-	ext := filepath.Ext(file.Filename)
-	filename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
-	savePath := filepath.Join(h.cfg.UploadDir, filename)
-
-	// Save file
-	if err := c.SaveFile(file, savePath); err != nil {
-		logger.Error("Failed to save uploaded file: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   "Failed to save file",
+	ttl, err := h.formUploadTTL(upload.form)
+	if err != nil {
+		upload.remove()
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
 			Message: err.Error(),
 		})
 	}
 
-	logger.Info("File uploaded successfully: %s (%d bytes)", filename, file.Size)
+	existed, err := upload.dedup()
+	if err != nil {
+		logger.Error("Failed to save uploaded file: %v", err)
+		return uploadErrorResponse(c, err)
+	}
+	scans, err := h.scanUploads(c.Context(), upload.paths, existed)
+	if err != nil {
+		return uploadErrorResponse(c, err)
+	}
+
+	savePath := upload.paths[0]
+	filename := filepath.Base(savePath)
+	if existed[0] {
+		logger.Info("File already uploaded: %s (%d bytes)", filename, upload.sizes[0])
+	} else {
+		logger.Info("File uploaded successfully: %s (%d bytes)", filename, upload.sizes[0])
+	}
 
 	return c.JSON(models.UploadResponse{
-		FileName: filename,
-		FilePath: savePath,
-		FileSize: file.Size,
+		FileName:   filename,
+		FilePath:   savePath,
+		FileSize:   upload.sizes[0],
+		SHA256:     upload.sums[0],
+		Existing:   existed[0],
+		ExpiresAt:  h.expireUpload(savePath, ttl, existed[0]),
+		ScanStatus: scans[0],
 	})
 }
 
 // UploadMultipleFiles godoc
 // @Summary Upload multiple files
-// @Description Upload multiple video, image, or audio files
+// @Description Upload up to MAX_MERGE_FILES video, image, or audio files of up to MAX_FILE_SIZE_MB each and MAX_UPLOAD_SIZE_MB in total
 // @Tags Upload
 // @Security ApiKeyAuth
 // @Accept multipart/form-data
 // @Produce json
 // @Param files formData file true "Files to upload (multiple)"
+// @Param ttl_seconds formData int false "Delete the files this many seconds after upload (default UPLOAD_TTL_SECONDS)"
 // @Success 200 {object} models.MultiUploadResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
 // @Router /api/v1/upload/multiple [post]
 func (h *Handler) UploadMultipleFiles(c fiber.Ctx) error {
-	form, err := c.MultipartForm()
+	// Stream the files to the upload directory under unique names
+	upload, err := h.streamMultipartUpload(c, "files", h.cfg.UploadDir, h.cfg.MaxMergeFiles, func(_ int, filename string) string {
+		return uuid.New().String() + filepath.Ext(filename)
+	})
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-			Error:   "Invalid multipart form",
-			Message: err.Error(),
-		})
+		if errors.Is(err, errUploadSave) {
+			logger.Error("Failed to save uploaded files: %v", err)
+		}
+		return uploadErrorResponse(c, err)
 	}
-
-	files := form.File["files"]
-	if len(files) == 0 {
+	if len(upload.paths) == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Error:   "No files provided",
 			Message: "At least one file is required",
 		})
 	}
+	ttl, err := h.formUploadTTL(upload.form)
+	if err != nil {
+		upload.remove()
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
 
-	uploadedFiles := make([]models.UploadResponse, 0, len(files))
-
-	for _, file := range files {
-		// Generate unique filename
-		ext := filepath.Ext(file.Filename)
-		filename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
-		savePath := filepath.Join(h.cfg.UploadDir, filename)
+	existed, err := upload.dedup()
+	if err != nil {
+		logger.Error("Failed to save uploaded files: %v", err)
+		return uploadErrorResponse(c, err)
+	}
+	scans, err := h.scanUploads(c.Context(), upload.paths, existed)
+	if err != nil {
+		return uploadErrorResponse(c, err)
+	}
 
-		// Save file
-		if err := c.SaveFile(file, savePath); err != nil {
-			logger.Error("Failed to save uploaded file %s: %v", file.Filename, err)
-			continue
+	uploadedFiles := make([]models.UploadResponse, 0, len(upload.paths))
+	for i, savePath := range upload.paths {
+		filename := filepath.Base(savePath)
+		if existed[i] {
+			logger.Info("File already uploaded: %s (%d bytes)", filename, upload.sizes[i])
+		} else {
+			logger.Info("File uploaded successfully: %s (%d bytes)", filename, upload.sizes[i])
 		}
 
-		logger.Info("File uploaded successfully: %s (%d bytes)", filename, file.Size)
-
 		uploadedFiles = append(uploadedFiles, models.UploadResponse{
-			FileName: filename,
-			FilePath: savePath,
-			FileSize: file.Size,
-		})
-	}
-
-	if len(uploadedFiles) == 0 {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   "Failed to save files",
-			Message: "All file uploads failed",
+			FileName:   filename,
+			FilePath:   savePath,
+			FileSize:   upload.sizes[i],
+			SHA256:     upload.sums[i],
+			Existing:   existed[i],
+			ExpiresAt:  h.expireUpload(savePath, ttl, existed[i]),
+			ScanStatus: scans[i],
 		})
 	}
 
@@ -777,8 +2057,8 @@ func (h *Handler) UploadMultipleFiles(c fiber.Ctx) error {
 }
 
 // CombineVideos godoc
-// @Summary Combine videos from URLs or file uploads and upload to S3
-// @Description Accepts either JSON with video URLs or multipart/form-data with video files, combines them in order, and uploads to S3
+// @Summary Combine videos from URLs or file uploads and upload to storage
+// @Description Accepts either JSON with video URLs (http, https, or s3:// or gs:// object URLs of the storage backend) or multipart/form-data with video files, combines them in order, and uploads to S3 or GCS
 // @Tags Video
 // @Security ApiKeyAuth
 // @Accept json,multipart/form-data
@@ -788,23 +2068,33 @@ func (h *Handler) UploadMultipleFiles(c fiber.Ctx) error {
 // @Param webhook_url formData string false "Webhook URL for job completion notification (multipart mode)"
 // @Param webhook_header_key formData string false "Webhook header key for custom headers (multipart mode)"
 // @Param webhook_header_value formData string false "Webhook header value for custom headers (multipart mode)"
+// @Param output_format formData string false "Output container: mp4, mkv, webm, or mov (multipart mode)"
+// @Param output_key formData string false "Object key of the uploaded output, with the placeholders of S3_KEY_TEMPLATE (multipart mode)"
+// @Param cache_control formData string false "Cache-Control of the uploaded output (multipart mode)"
+// @Param sidecars formData bool false "Also upload a thumbnail and metadata.json next to the output (multipart mode)"
+// @Param object_metadata formData string false "User metadata of the uploaded output as a JSON object of strings (multipart mode)"
 // @Success 200 {object} models.JobResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
 // @Router /api/v1/video/combine [post]
 func (h *Handler) CombineVideos(c fiber.Ctx) error {
-	// Check if S3 uploader is available
-	if h.s3Uploader == nil {
+	// Check if the storage backend is available
+	if h.uploader == nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   "S3 uploader not configured",
-			Message: "S3 configuration is missing or invalid",
+			Error:   "Storage not configured",
+			Message: "Storage configuration is missing or invalid",
 		})
 	}
 
-	// Try to parse as multipart form first
-	if form, err := c.MultipartForm(); err == nil {
-		return h.handleCombineVideosMultipart(c, form)
+	// Multipart requests upload the files directly
+	contentType := string(c.Request().Header.ContentType())
+	if strings.HasPrefix(contentType, fiber.MIMEMultipartForm) {
+		return h.handleCombineVideosMultipart(c)
 	}
 
 	// Otherwise, handle as JSON (URL mode)
@@ -828,41 +2118,61 @@ func (h *Handler) handleCombineVideosJSON(c fiber.Ctx) error {
 			Message: "At least 2 video URLs are required",
 		})
 	}
+	for _, url := range req.Videos {
+		if err := h.downloader.CheckURL(url); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: err.Error(),
+			})
+		}
+	}
 
-	// Create job
-	job, response := h.createAndStartJob()
-
-	// Set webhook URL if provided
-	if req.WebhookURL != "" {
-		// Validate webhook header if provided
-		if req.WebhookHeader != nil {
-			if req.WebhookHeader.Key == "" || len(req.WebhookHeader.Key) > 100 || len(req.WebhookHeader.Value) > 1000 {
-				return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-					Error:   "Invalid webhook header",
-					Message: "Header key must be non-empty and less than 100 characters, value less than 1000 characters",
-				})
-			}
+	if err := h.executor.CheckInputLimits(c.Context(), pathSegments(req.Videos...)); err != nil {
+		return inputLimitError(c, err)
+	}
 
-			// Prevent overriding critical headers
-			if strings.ToLower(req.WebhookHeader.Key) == "host" || strings.ToLower(req.WebhookHeader.Key) == "content-length" {
-				return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-					Error:   "Invalid webhook header",
-					Message: "Cannot override Host or Content-Length headers",
-				})
-			}
+	if len(req.Checksums) > len(req.Videos) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "checksums has more entries than videos",
+		})
+	}
+	checksums := make([]downloader.Checksum, len(req.Checksums))
+	for i, value := range req.Checksums {
+		checksum, err := downloader.ParseChecksum(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: err.Error(),
+			})
 		}
+		checksums[i] = checksum
+	}
 
-		job.WebhookURL = req.WebhookURL
-		job.WebhookHeader = req.WebhookHeader
-		_ = h.jobStore.Update(job)
+	if err := h.presets.Resolve(&req.OutputOptions); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := h.checkDelivery(&req.DeliveryOptions); err != nil {
+		return deliveryError(c, err)
 	}
+	req.StorageOptions.OriginalName = originalName(req.Videos[0])
+	// Combine uploads its output unless the destination is local
+	req.UploadToS3 = req.Destination != models.DestinationLocal
+
+	// Create job
+	job, response := h.createAndStartJob(c, req.OutputOptions, req.DeliveryOptions)
 
 	// Start async processing from URLs
-	h.jobWG.Add(1)
-	go func() {
-		defer h.jobWG.Done()
-		h.processCombineJobFromURLs(job, req.Videos)
-	}()
+	h.startJob(job, models.WorkCombine, combineWork{
+		Videos:       req.Videos,
+		Checksums:    checksums,
+		Output:       req.OutputOptions,
+		Storage:      req.StorageOptions,
+		OriginalName: req.StorageOptions.OriginalName,
+	})
 
 	logger.Info("Created combine videos job %s with %d URLs", job.ID, len(req.Videos))
 
@@ -870,97 +2180,73 @@ func (h *Handler) handleCombineVideosJSON(c fiber.Ctx) error {
 }
 
 // handleCombineVideosMultipart handles multipart/form-data request with file uploads
-func (h *Handler) handleCombineVideosMultipart(c fiber.Ctx, form *multipart.Form) error {
-	// Get uploaded files
-	files := form.File["videos"]
-	if len(files) < 2 {
+func (h *Handler) handleCombineVideosMultipart(c fiber.Ctx) error {
+	// Stream uploaded files to the temp directory in order
+	var firstFilename string
+	upload, err := h.streamMultipartUpload(c, "videos", h.cfg.TempDir, h.cfg.MaxMergeFiles, func(i int, filename string) string {
+		if i == 0 {
+			firstFilename = filename
+		}
+		return fmt.Sprintf("%s_%d_%s", uuid.New().String(), i, filepath.Base(filename))
+	})
+	if err != nil {
+		return uploadErrorResponse(c, err)
+	}
+	form := upload.form
+	uploadedPaths := upload.paths
+
+	if len(uploadedPaths) < 2 {
+		upload.remove()
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Error:   "Invalid request",
 			Message: "At least 2 video files are required",
 		})
 	}
+	if _, err := h.scanUploads(c.Context(), uploadedPaths, nil); err != nil {
+		return uploadErrorResponse(c, err)
+	}
 
-	// Limit maximum files
-	if len(files) > 10 {
+	outputOptions, err := outputOptionsFromForm(form)
+	if err == nil {
+		err = h.presets.Resolve(&outputOptions)
+	}
+	var deliveryOptions models.DeliveryOptions
+	if err == nil {
+		deliveryOptions, err = deliveryOptionsFromForm(form)
+		deliveryOptions.OriginalName = originalName(firstFilename)
+	}
+	if err != nil {
+		upload.remove()
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-			Error:   "Too many files",
-			Message: "Maximum 10 video files allowed",
+			Error:   "Invalid request",
+			Message: err.Error(),
 		})
 	}
-
-	// Save uploaded files to temp directory in order
-	uploadedPaths := make([]string, 0, len(files))
-	for i, file := range files {
-		filename := fmt.Sprintf("%s_%d_%s", uuid.New().String(), i, filepath.Base(file.Filename))
-		savePath := filepath.Join(h.cfg.TempDir, filename)
-
-		if err := c.SaveFile(file, savePath); err != nil {
-			// Clean up already saved files
-			for _, path := range uploadedPaths {
-				os.Remove(path)
-			}
-			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-				Error:   "Failed to save file",
-				Message: err.Error(),
-			})
-		}
-
-		uploadedPaths = append(uploadedPaths, savePath)
-		logger.Info("Saved uploaded file %d: %s", i, savePath)
+	if err := h.checkDelivery(&deliveryOptions); err != nil {
+		upload.remove()
+		return deliveryError(c, err)
 	}
-
-	// Get optional webhook URL from form
-	webhookURL := ""
-	if webhookValues, ok := form.Value["webhook_url"]; ok && len(webhookValues) > 0 {
-		webhookURL = webhookValues[0]
+	// Combine uploads its output unless the destination is local
+	deliveryOptions.UploadToS3 = deliveryOptions.Destination != models.DestinationLocal
+	if err := h.executor.CheckInputLimits(c.Context(), pathSegments(uploadedPaths...)); err != nil {
+		upload.remove()
+		return inputLimitError(c, err)
 	}
 
-	// Get optional webhook header from form
-	var webhookHeader *models.WebhookHeader
-	if headerKeyValues, ok := form.Value["webhook_header_key"]; ok && len(headerKeyValues) > 0 {
-		if headerValueValues, ok := form.Value["webhook_header_value"]; ok && len(headerValueValues) > 0 {
-			key := headerKeyValues[0]
-			value := headerValueValues[0]
-
-			// Basic validation
-			if key == "" || len(key) > 100 || len(value) > 1000 {
-				return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-					Error:   "Invalid webhook header",
-					Message: "Header key must be non-empty and less than 100 characters, value less than 1000 characters",
-				})
-			}
-
-			// Prevent overriding critical headers
-			if strings.ToLower(key) == "host" || strings.ToLower(key) == "content-length" {
-				return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-					Error:   "Invalid webhook header",
-					Message: "Cannot override Host or Content-Length headers",
-				})
-			}
-
-			webhookHeader = &models.WebhookHeader{
-				Key:   key,
-				Value: value,
-			}
-		}
+	for i, path := range uploadedPaths {
+		logger.Info("Saved uploaded file %d: %s", i, path)
 	}
 
 	// Create job
-	job, response := h.createAndStartJob()
-
-	// Set webhook URL and header if provided
-	if webhookURL != "" {
-		job.WebhookURL = webhookURL
-		job.WebhookHeader = webhookHeader
-		_ = h.jobStore.Update(job)
-	}
-
-	// Start async processing from uploaded files
-	h.jobWG.Add(1)
-	go func() {
-		defer h.jobWG.Done()
-		h.processCombineJobFromFiles(job, uploadedPaths)
-	}()
+	job, response := h.createAndStartJob(c, outputOptions, deliveryOptions)
+
+	// Start async processing from uploaded files. The job removes its inputs, so it is not retryable.
+	h.startJob(job, models.WorkCombineFiles, combineFilesWork{
+		Files:        uploadedPaths,
+		Output:       outputOptions,
+		Storage:      deliveryOptions.StorageOptions,
+		OriginalName: deliveryOptions.OriginalName,
+	})
 
 	logger.Info("Created combine videos job %s with %d uploaded files", job.ID, len(uploadedPaths))
 
@@ -968,140 +2254,339 @@ func (h *Handler) handleCombineVideosMultipart(c fiber.Ctx, form *multipart.Form
 }
 
 // processCombineJobFromURLs processes a video combine job from URLs
-func (h *Handler) processCombineJobFromURLs(job *models.Job, videoURLs []string) {
-	job.UpdateStatus(models.JobStatusProcessing)
+func (h *Handler) processCombineJobFromURLs(job *models.Job, videoURLs []string, checksums []downloader.Checksum, opts models.OutputOptions, storageOpts models.StorageOptions) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.JobTimeout(h.cfg.JobTimeout))
+	defer cancel()
+	jobLog := job.Logger()
+	ctx = logger.NewContext(ctx, jobLog)
+	ctx, span := job.StartSpan(ctx, "job.combine")
+	defer job.EndSpan(span)
+	ctx, closeLog := h.withFFmpegLog(ctx, job)
+	defer closeLog()
+
+	if !job.Start(cancel) {
+		jobLog.Info("Job %s was cancelled before it started", job.ID)
+		return
+	}
 	job.UpdateProgress(10)
 	_ = h.jobStore.Update(job)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(h.cfg.JobTimeout)*time.Second)
-	defer cancel()
-
-	logger.Info("Starting combine videos job %s from URLs", job.ID)
+	jobLog.Info("Starting combine videos job %s from URLs", job.ID)
 
 	// Download videos in order
-	logger.Info("Downloading %d videos for job %s", len(videoURLs), job.ID)
+	jobLog.Info("Downloading %d videos for job %s", len(videoURLs), job.ID)
 	job.UpdateProgress(20)
 	_ = h.jobStore.Update(job)
 
-	downloadedFiles, err := h.downloader.DownloadVideosInOrder(videoURLs)
+	downloadedFiles, err := h.downloader.DownloadVideosInOrder(ctx, videoURLs, checksums)
 	if err != nil {
-		logger.Error("Failed to download videos for job %s: %v", job.ID, err)
+		err = models.TimeoutError(ctx, opts.JobTimeout(h.cfg.JobTimeout), err)
+		jobLog.Error("Failed to download videos for job %s: %v", job.ID, err)
 		job.SetError(fmt.Sprintf("Failed to download videos: %v", err))
 		_ = h.jobStore.Update(job)
-		h.sendWebhookIfConfigured(job)
+		h.delivery.Notify(ctx, job)
 		return
 	}
 	defer h.downloader.CleanupFiles(downloadedFiles)
+	job.AddFiles(downloadedFiles...)
+
+	err = h.probeDownloads(ctx, videoURLs, downloadedFiles)
+	if err == nil {
+		err = h.executor.CheckInputLimits(ctx, pathSegments(downloadedFiles...))
+	}
+	if err != nil {
+		err = models.TimeoutError(ctx, opts.JobTimeout(h.cfg.JobTimeout), err)
+		jobLog.Error("Downloaded videos for job %s are not usable: %v", job.ID, err)
+		job.SetError(err.Error())
+		_ = h.jobStore.Update(job)
+		h.delivery.Notify(ctx, job)
+		return
+	}
 
-	logger.Info("Downloaded %d videos for job %s", len(downloadedFiles), job.ID)
+	jobLog.Info("Downloaded %d videos for job %s", len(downloadedFiles), job.ID)
 	job.UpdateProgress(40)
 	_ = h.jobStore.Update(job)
 
 	// Continue with common processing
-	h.processCombineJobCommon(job, ctx, downloadedFiles, true)
+	h.processCombineJobCommon(job, ctx, downloadedFiles, opts, storageOpts, true)
 }
 
 // processCombineJobFromFiles processes a video combine job from uploaded files
-func (h *Handler) processCombineJobFromFiles(job *models.Job, uploadedFiles []string) {
-	job.UpdateStatus(models.JobStatusProcessing)
+func (h *Handler) processCombineJobFromFiles(job *models.Job, uploadedFiles []string, opts models.OutputOptions, storageOpts models.StorageOptions) {
+	job.AddFiles(uploadedFiles...)
+	ctx, cancel := context.WithTimeout(context.Background(), opts.JobTimeout(h.cfg.JobTimeout))
+	defer cancel()
+	jobLog := job.Logger()
+	ctx = logger.NewContext(ctx, jobLog)
+	ctx, span := job.StartSpan(ctx, "job.combine")
+	defer job.EndSpan(span)
+	ctx, closeLog := h.withFFmpegLog(ctx, job)
+	defer closeLog()
+
+	if !job.Start(cancel) {
+		jobLog.Info("Job %s was cancelled before it started", job.ID)
+		return
+	}
 	job.UpdateProgress(10)
 	_ = h.jobStore.Update(job)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(h.cfg.JobTimeout)*time.Second)
-	defer cancel()
-
-	logger.Info("Starting combine videos job %s from uploaded files", job.ID)
+	jobLog.Info("Starting combine videos job %s from uploaded files", job.ID)
 
 	// Files are already uploaded, skip to merge
 	job.UpdateProgress(40)
 	_ = h.jobStore.Update(job)
 
 	// Continue with common processing
-	h.processCombineJobCommon(job, ctx, uploadedFiles, true)
+	h.processCombineJobCommon(job, ctx, uploadedFiles, opts, storageOpts, true)
 }
 
-// processCombineJobCommon handles the common video merge and S3 upload logic
-func (h *Handler) processCombineJobCommon(job *models.Job, ctx context.Context, inputFiles []string, cleanupFiles bool) {
+// processCombineJobCommon handles the common video merge and storage upload logic
+func (h *Handler) processCombineJobCommon(job *models.Job, ctx context.Context, inputFiles []string, opts models.OutputOptions, storageOpts models.StorageOptions, cleanupFiles bool) {
+	jobLog := logger.FromContext(ctx)
+	timeout := opts.JobTimeout(h.cfg.JobTimeout)
 	// Cleanup files at the end if requested
 	if cleanupFiles {
 		defer h.downloader.CleanupFiles(inputFiles)
 	}
 
 	// Merge videos
-	outputPath := filepath.Join(h.cfg.OutputDir, fmt.Sprintf("%s.mp4", job.ID))
-	logger.Info("Merging %d videos for job %s", len(inputFiles), job.ID)
+	outputPath, err := h.delivery.OutputPath(job, opts)
+	if err != nil {
+		jobLog.Error("Failed to name the output of job %s: %v", job.ID, err)
+		job.SetError(err.Error())
+		_ = h.jobStore.Update(job)
+		h.delivery.Notify(ctx, job)
+		return
+	}
+	jobLog.Info("Merging %d videos for job %s", len(inputFiles), job.ID)
 	job.UpdateProgress(60)
 	_ = h.jobStore.Update(job)
+	job.SetInput("combine", h.executor.InputSeconds(ctx, inputFiles...))
+	// The merge takes the job from 60% to 80%
+	ctx = ffmpeg.WithProgress(ctx, func(fraction float64) {
+		job.AdvanceProgress(60 + int(fraction*20))
+	})
+	ctx = ffmpeg.WithStart(ctx, job.MarkStarted)
 
-	if err := h.executor.MergeVideosSimple(ctx, inputFiles, outputPath); err != nil {
-		logger.Error("Failed to merge videos for job %s: %v", job.ID, err)
+	if err := h.executor.MergeVideosSimple(ctx, inputFiles, opts, outputPath); err != nil {
+		err = models.TimeoutError(ctx, timeout, err)
+		jobLog.Error("Failed to merge videos for job %s: %v", job.ID, err)
 		job.SetError(fmt.Sprintf("Failed to merge videos: %v", err))
 		_ = h.jobStore.Update(job)
-		h.sendWebhookIfConfigured(job)
+		h.delivery.Notify(ctx, job)
 		return
 	}
 
-	logger.Info("Videos merged successfully for job %s", job.ID)
+	jobLog.Info("Videos merged successfully for job %s", job.ID)
+	h.recordUsage(ctx, job, outputPath)
 	job.UpdateProgress(80)
 	job.SetOutput(outputPath)
 	_ = h.jobStore.Update(job)
 
-	// Upload to S3
-	logger.Info("Uploading to S3 for job %s", job.ID)
-	objectName := storage.GetObjectName(job.ID, outputPath)
-	s3URL, err := h.s3Uploader.Upload(ctx, outputPath, objectName)
-	if err != nil {
-		logger.Error("Failed to upload to S3 for job %s: %v", job.ID, err)
-		job.SetError(fmt.Sprintf("Failed to upload to S3: %v", err))
-		_ = h.jobStore.Update(job)
-		h.sendWebhookIfConfigured(job)
-		return
-	}
-
-	logger.Info("Uploaded to S3 for job %s: %s", job.ID, s3URL)
-	job.SetS3URL(s3URL)
-	job.UpdateProgress(90)
-	_ = h.jobStore.Update(job)
-
-	// Delete local file after successful upload
-	if err := os.Remove(outputPath); err != nil {
-		logger.Error("Failed to delete local file for job %s: %v", job.ID, err)
-		// Don't fail the job, just log the error
+	// With destination local the output stays in OUTPUT_DIR
+	if job.Upload == nil {
+		jobLog.Info("Keeping the output of job %s in %s", job.ID, h.cfg.OutputDir)
 	} else {
-		logger.Info("Deleted local file for job %s", job.ID)
-		// Clear output path since file is deleted
-		job.SetOutput("")
+		jobLog.Info("Uploading to %s for job %s", h.cfg.StorageBackend, job.ID)
+		// The upload takes the job from 80% to 90%
+		s3URL, err := h.delivery.Move(ctx, job, outputPath, storageOpts, func(uploaded, total int64) {
+			if total > 0 {
+				job.UpdateProgress(80 + int(min(uploaded, total)*10/total))
+			}
+		})
+		if err != nil {
+			err = models.TimeoutError(ctx, timeout, err)
+			jobLog.Error("Failed to upload to %s for job %s: %v", h.cfg.StorageBackend, job.ID, err)
+			job.SetError(fmt.Sprintf("Failed to upload to %s: %v", h.cfg.StorageBackend, err))
+			_ = h.jobStore.Update(job)
+			h.delivery.Notify(ctx, job)
+			return
+		}
+
+		jobLog.Info("Uploaded to %s for job %s: %s", h.cfg.StorageBackend, job.ID, s3URL)
+		job.UpdateProgress(90)
+		_ = h.jobStore.Update(job)
 	}
 
 	// Mark job as completed
 	job.UpdateProgress(100)
 	job.UpdateStatus(models.JobStatusCompleted)
 	_ = h.jobStore.Update(job)
-	logger.Info("Combine videos job %s completed successfully", job.ID)
+	jobLog.Info("Combine videos job %s completed successfully", job.ID)
 
 	// Send webhook notification
-	h.sendWebhookIfConfigured(job)
+	h.delivery.Notify(ctx, job)
 }
 
-// sendWebhookIfConfigured sends a webhook notification if webhook URL is configured
-func (h *Handler) sendWebhookIfConfigured(job *models.Job) {
-	if job.WebhookURL == "" {
-		return
+// outputOptionsFromForm reads the output format and encoding options from multipart form fields
+func outputOptionsFromForm(form *multipart.Form) (models.OutputOptions, error) {
+	opts := models.OutputOptions{
+		PresetName:   formValue(form, "preset_name"),
+		OutputFormat: models.OutputFormat(formValue(form, "output_format")),
+		Preset:       formValue(form, "preset"),
+		Profile:      formValue(form, "profile"),
+		Level:        formValue(form, "level"),
+		PixFmt:       formValue(form, "pix_fmt"),
+		Fit:          models.FitMode(formValue(form, "fit")),
+		Background:   formValue(form, "background"),
+		Interpolate:  models.InterpolateMode(formValue(form, "interpolate")),
+		OutputName:   formValue(form, "output_name"),
+		OnConflict:   models.ConflictMode(formValue(form, "on_conflict")),
 	}
 
-	status := job.GetStatus()
-	payload := webhook.JobCompletionPayload{
-		JobID:  job.ID,
-		Status: string(status.Status),
-		S3URL:  status.S3URL,
-		Error:  status.Error,
+	if crf := formValue(form, "crf"); crf != "" {
+		value, err := strconv.Atoi(crf)
+		if err != nil {
+			return opts, fmt.Errorf("crf must be an integer")
+		}
+		opts.CRF = &value
+	}
+
+	for key, target := range map[string]*int{"width": &opts.Width, "height": &opts.Height} {
+		if value := formValue(form, key); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return opts, fmt.Errorf("%s must be an integer", key)
+			}
+			*target = parsed
+		}
+	}
+
+	if fps := formValue(form, "fps"); fps != "" {
+		value, err := strconv.ParseFloat(fps, 64)
+		if err != nil {
+			return opts, fmt.Errorf("fps must be a number")
+		}
+		opts.FPS = value
+	}
+
+	if metadata := formValue(form, "metadata"); metadata != "" {
+		opts.Metadata = &models.OutputMetadata{}
+		if err := sonic.UnmarshalString(metadata, opts.Metadata); err != nil {
+			return opts, fmt.Errorf("metadata must be a JSON object: %w", err)
+		}
+	}
+	opts.StripMetadata = formValue(form, "strip_metadata") == "true"
+
+	if cleanup := formValue(form, "cleanup_inputs"); cleanup != "" {
+		value, err := strconv.ParseBool(cleanup)
+		if err != nil {
+			return opts, fmt.Errorf("cleanup_inputs must be true or false")
+		}
+		opts.CleanupInputs = &value
+	}
+	opts.RunAt = formValue(form, "run_at")
+	if labels := formValue(form, "labels"); labels != "" {
+		if err := sonic.UnmarshalString(labels, &opts.Labels); err != nil {
+			return opts, fmt.Errorf("labels must be a JSON object of strings: %w", err)
+		}
+	}
+	if timeout := formValue(form, "timeout_seconds"); timeout != "" {
+		value, err := strconv.Atoi(timeout)
+		if err != nil {
+			return opts, fmt.Errorf("timeout_seconds must be a whole number")
+		}
+		opts.TimeoutSeconds = value
+	}
+	if ttl := formValue(form, "ttl_seconds"); ttl != "" {
+		value, err := strconv.Atoi(ttl)
+		if err != nil {
+			return opts, fmt.Errorf("ttl_seconds must be a whole number")
+		}
+		opts.TTLSeconds = value
+	}
+
+	return opts, nil
+}
+
+// originalName returns the name without extension of a file path or of the path of a URL
+func originalName(input string) string {
+	if u, err := neturl.Parse(input); err == nil && u.Scheme != "" {
+		input = u.Path
+	}
+	name := path.Base(filepath.ToSlash(input))
+	if name == "." || name == "/" {
+		return ""
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// storageOptionsFromForm reads the storage options from multipart form fields
+func storageOptionsFromForm(form *multipart.Form) (models.StorageOptions, error) {
+	opts := models.StorageOptions{
+		OutputKey:    formValue(form, "output_key"),
+		CacheControl: formValue(form, "cache_control"),
+		Bucket:       formValue(form, "bucket"),
+	}
+	if sidecars := formValue(form, "sidecars"); sidecars != "" {
+		value, err := strconv.ParseBool(sidecars)
+		if err != nil {
+			return opts, fmt.Errorf("sidecars must be true or false")
+		}
+		opts.Sidecars = &value
+	}
+	if metadata := formValue(form, "object_metadata"); metadata != "" {
+		if err := sonic.UnmarshalString(metadata, &opts.ObjectMetadata); err != nil {
+			return opts, fmt.Errorf("object_metadata must be a JSON object of strings: %w", err)
+		}
+	}
+	return opts, opts.Validate()
+}
+
+// deliveryOptionsFromForm reads the webhook and storage upload options from multipart form fields. A single
+// webhook header may be given as webhook_header_key and webhook_header_value.
+func deliveryOptionsFromForm(form *multipart.Form) (models.DeliveryOptions, error) {
+	opts := models.DeliveryOptions{
+		WebhookURL:  formValue(form, "webhook_url"),
+		Destination: models.Destination(formValue(form, "destination")),
+	}
+	if key := formValue(form, "webhook_header_key"); key != "" {
+		opts.WebhookHeader = &models.WebhookHeader{Key: key, Value: formValue(form, "webhook_header_value")}
+	}
+	if headers := formValue(form, "webhook_headers"); headers != "" {
+		if err := sonic.UnmarshalString(headers, &opts.WebhookHeaders); err != nil {
+			return opts, fmt.Errorf("webhook_headers must be a JSON object of strings: %w", err)
+		}
+	}
+	if upload := formValue(form, "upload_to_s3"); upload != "" {
+		value, err := strconv.ParseBool(upload)
+		if err != nil {
+			return opts, fmt.Errorf("upload_to_s3 must be true or false")
+		}
+		opts.UploadToS3 = value
+	}
+	storageOpts, err := storageOptionsFromForm(form)
+	opts.StorageOptions = storageOpts
+	return opts, err
+}
+
+// checkDelivery validates the delivery options of a request, and checks that storage is configured when
+// the request asks for an upload
+func (h *Handler) checkDelivery(opts *models.DeliveryOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
 	}
+	return h.delivery.Check(*opts)
+}
 
-	// Convert WebhookHeader to headers map
-	headers := make(map[string]string)
-	if job.WebhookHeader != nil {
-		headers[job.WebhookHeader.Key] = job.WebhookHeader.Value
+// deliveryError writes the response for delivery options that checkDelivery rejected
+func deliveryError(c fiber.Ctx, err error) error {
+	if errors.Is(err, delivery.ErrNoStorage) {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(models.ErrorResponse{
+			Error:   "Storage not configured",
+			Message: err.Error(),
+		})
 	}
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error:   "Invalid request",
+		Message: err.Error(),
+	})
+}
 
-	h.webhook.SendJobCompleteAsync(job.WebhookURL, headers, payload)
+// formValue returns the first value of a multipart form field, or an empty string
+func formValue(form *multipart.Form, key string) string {
+	if values := form.Value[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
 }
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"govid/internal/models"
+	"govid/pkg/health"
+	"govid/pkg/logger"
+)
+
+// readinessTimeout bounds the whole readiness check, so a hanging dependency cannot stall the probe
+const readinessTimeout = 5 * time.Second
+
+// ReadinessCheck godoc
+// @Summary Readiness check
+// @Description Check that FFmpeg and ffprobe run, the storage directories are writable with enough free space, the storage backend is reachable, and the instance is not draining. Also reports the FFmpeg version, encoders and filters detected at startup.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} models.ReadinessResponse
+// @Failure 503 {object} models.ReadinessResponse
+// @Router /api/v1/health/ready [get]
+func (h *Handler) ReadinessCheck(c fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) error{
+		"ffmpeg":     func(ctx context.Context) error { return health.Binary(ctx, h.cfg.FFmpegBinary) },
+		"ffprobe":    func(ctx context.Context) error { return health.Binary(ctx, h.cfg.FFprobeBinary) },
+		"upload_dir": func(context.Context) error { return health.WritableDir(h.cfg.UploadDir) },
+		"output_dir": func(context.Context) error { return health.WritableDir(h.cfg.OutputDir) },
+		"temp_dir":   func(context.Context) error { return health.WritableDir(h.cfg.TempDir) },
+		"disk_space": func(context.Context) error { return h.checkDiskSpace() },
+		"storage":    h.checkStorage,
+		"draining":   func(context.Context) error { return h.checkDraining() },
+	}
+
+	response := models.ReadinessResponse{
+		Status: "ok",
+		Checks: make(map[string]models.CheckResult, len(checks)),
+		FFmpeg: h.executor.Capabilities(),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := models.CheckResult{Status: "ok"}
+			if err := check(ctx); err != nil {
+				result = models.CheckResult{Status: "fail", Message: err.Error()}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			response.Checks[name] = result
+			if result.Status != "ok" {
+				response.Status = "fail"
+			}
+		}()
+	}
+	wg.Wait()
+
+	if response.Status != "ok" {
+		logger.Warn("Readiness check failed: %v", response.Checks)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(response)
+	}
+	return c.JSON(response)
+}
+
+// checkDiskSpace fails when a storage directory has less than MinFreeDiskMB free
+func (h *Handler) checkDiskSpace() error {
+	minFree := uint64(h.cfg.MinFreeDiskMB) << 20
+	for _, dir := range []string{h.cfg.UploadDir, h.cfg.OutputDir, h.cfg.TempDir} {
+		free, err := health.FreeSpace(dir)
+		if err != nil {
+			return fmt.Errorf("failed to read free space of %s: %w", dir, err)
+		}
+		if free < minFree {
+			return fmt.Errorf("%s has %d MB free, below the minimum of %d MB", dir, free>>20, h.cfg.MinFreeDiskMB)
+		}
+	}
+	return nil
+}
+
+// checkDraining fails once the instance drains, so that load balancers stop sending it requests
+func (h *Handler) checkDraining() error {
+	if h.drain.Draining() {
+		return errors.New("the instance is draining")
+	}
+	return nil
+}
+
+// checkStorage checks that the output bucket of the storage backend is reachable
+func (h *Handler) checkStorage(ctx context.Context) error {
+	if h.uploader == nil {
+		return errors.New("storage backend not configured")
+	}
+	return h.uploader.Ping(ctx)
+}
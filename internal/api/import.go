@@ -0,0 +1,146 @@
+package api
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/gofiber/fiber/v3"
+
+	"govid/internal/delivery"
+	"govid/internal/models"
+	"govid/internal/uploads"
+	"govid/pkg/downloader"
+	"govid/pkg/logger"
+)
+
+// UploadFromURL godoc
+// @Summary Upload a file from a URL
+// @Description Download a video, image, or audio file from a public http(s) URL into the upload directory. The response is the same as for /api/v1/upload, so the file_path can be used by any endpoint, not only combine. Downloads are limited by MAX_DOWNLOAD_SIZE_MB and MAX_FILE_SIZE_MB.
+// @Tags Upload
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.URLUploadRequest true "URL to download"
+// @Success 200 {object} models.UploadResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /api/v1/upload/from-url [post]
+func (h *Handler) UploadFromURL(c fiber.Ctx) error {
+	var req models.URLUploadRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+	if req.URL == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "url is required",
+		})
+	}
+	expected, err := downloader.ParseChecksum(req.Checksum)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	ttl, err := h.uploadTTL(req.TTLSeconds)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+
+	maxBytes := int64(h.cfg.MaxFileSizeMB) << 20
+	if h.cfg.MaxDownloadSizeMB > 0 {
+		maxBytes = min(maxBytes, int64(h.cfg.MaxDownloadSizeMB)<<20)
+	}
+	file, err := downloader.DownloadMedia(c.Context(), h.urls, req.URL, h.cfg.UploadDir, maxBytes)
+	if err != nil {
+		return urlUploadError(c, req.URL, err)
+	}
+
+	if err := h.fileTypes.CheckFile(file.FileName, file.FilePath); err != nil {
+		os.Remove(file.FilePath)
+		return uploadErrorResponse(c, err)
+	}
+	if err := expected.Verify(file.FilePath); err != nil {
+		os.Remove(file.FilePath)
+		return urlUploadError(c, req.URL, err)
+	}
+
+	_, sum, err := delivery.FileSHA256(file.FilePath)
+	var storedPath string
+	var existed bool
+	if err == nil {
+		storedPath, existed, err = uploads.Dedup(file.FilePath, sum, file.FileName)
+	}
+	if err != nil {
+		os.Remove(file.FilePath)
+		logger.Error("Failed to save download of %s: %v", req.URL, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to save uploaded file",
+			Message: err.Error(),
+		})
+	}
+
+	scans, err := h.scanUploads(c.Context(), []string{storedPath}, []bool{existed})
+	if err != nil {
+		return uploadErrorResponse(c, err)
+	}
+
+	logger.Info("Downloaded %s as upload %s (%d bytes)", req.URL, filepath.Base(storedPath), file.FileSize)
+
+	return c.JSON(models.UploadResponse{
+		FileName:   filepath.Base(storedPath),
+		FilePath:   storedPath,
+		FileSize:   file.FileSize,
+		SHA256:     sum,
+		Existing:   existed,
+		Checksum:   req.Checksum,
+		ExpiresAt:  h.expireUpload(storedPath, ttl, existed),
+		ScanStatus: scans[0],
+	})
+}
+
+// urlUploadError sends the error response for a failed URL upload
+func urlUploadError(c fiber.Ctx, url string, err error) error {
+	switch {
+	case errors.Is(err, downloader.ErrURLNotAllowed):
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	case errors.Is(err, downloader.ErrChecksumMismatch):
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Checksum mismatch",
+			Message: err.Error(),
+		})
+	case errors.Is(err, downloader.ErrTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(models.ErrorResponse{
+			Error:   "Download too large",
+			Message: err.Error(),
+		})
+	case errors.Is(err, downloader.ErrNotMedia):
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(models.ErrorResponse{
+			Error:   "Unsupported file type",
+			Message: err.Error(),
+		})
+	default:
+		logger.Error("Failed to download %s: %v", url, err)
+		return c.Status(fiber.StatusBadGateway).JSON(models.ErrorResponse{
+			Error:   "Download failed",
+			Message: err.Error(),
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+
+	"govid/internal/models"
+	"govid/pkg/auth"
+	"govid/pkg/logger"
+)
+
+// ListAPIKeys godoc
+// @Summary List API keys
+// @Description List every API key with its scope and quota. Key values are never returned.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.APIKeysResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/keys [get]
+func (h *Handler) ListAPIKeys(c fiber.Ctx) error {
+	keys := h.keys.List()
+	response := models.APIKeysResponse{
+		Keys: make([]models.APIKeyResponse, len(keys)),
+	}
+	for i, key := range keys {
+		response.Keys[i] = apiKeyResponse(key)
+	}
+	return c.JSON(response)
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Create a managed API key. The generated key value is only returned in this response.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.CreateAPIKeyRequest true "Key name, scope, and quota"
+// @Success 201 {object} models.APIKeyResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/admin/keys [post]
+func (h *Handler) CreateAPIKey(c fiber.Ctx) error {
+	var req models.CreateAPIKeyRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+
+	key, err := h.keys.Create(req.Name, auth.Scope(req.Scope), req.QuotaMinutes)
+	if err != nil {
+		return apiKeyErrorResponse(c, err)
+	}
+
+	logger.Info("API key %s created by %s (scope %s)", key.Name, requestKey(c).Name, key.Scope)
+	return c.Status(fiber.StatusCreated).JSON(apiKeyResponse(key))
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Delete a managed API key. Keys defined in configuration cannot be revoked.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param name path string true "Key name"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/admin/keys/{name} [delete]
+func (h *Handler) RevokeAPIKey(c fiber.Ctx) error {
+	name := c.Params("name")
+	if err := h.keys.Revoke(name); err != nil {
+		return apiKeyErrorResponse(c, err)
+	}
+
+	logger.Info("API key %s revoked by %s", name, requestKey(c).Name)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RotateAPIKey godoc
+// @Summary Rotate an API key
+// @Description Replace the value of a managed API key. The old value stops working immediately and the new one is only returned in this response.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param name path string true "Key name"
+// @Success 200 {object} models.APIKeyResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/admin/keys/{name}/rotate [post]
+func (h *Handler) RotateAPIKey(c fiber.Ctx) error {
+	key, err := h.keys.Rotate(c.Params("name"))
+	if err != nil {
+		return apiKeyErrorResponse(c, err)
+	}
+
+	logger.Info("API key %s rotated by %s", key.Name, requestKey(c).Name)
+	return c.JSON(apiKeyResponse(key))
+}
+
+// apiKeyResponse converts a key to its API representation
+func apiKeyResponse(key auth.Key) models.APIKeyResponse {
+	return models.APIKeyResponse{
+		Name:         key.Name,
+		Scope:        string(key.Scope),
+		QuotaMinutes: key.QuotaMinutes,
+		Managed:      key.Managed,
+		Key:          key.Key,
+	}
+}
+
+// apiKeyErrorResponse sends the error response for a failed key management operation
+func apiKeyErrorResponse(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, auth.ErrKeyNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "API key not found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, auth.ErrKeyExists), errors.Is(err, auth.ErrKeyNotManaged):
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error:   "API key conflict",
+			Message: err.Error(),
+		})
+	case errors.Is(err, auth.ErrKeyStorage):
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to store API key",
+			Message: err.Error(),
+		})
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid API key request",
+			Message: err.Error(),
+		})
+	}
+}
@@ -1,21 +1,63 @@
 package api
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 
 	"govid/internal/models"
+	"govid/internal/usage"
 	"govid/pkg/auth"
 	"govid/pkg/logger"
+	"govid/pkg/tracing"
 )
 
+// apiKeyLocal is the locals key holding the authenticated auth.Key
+const apiKeyLocal = "api_key"
+
+// requestIDLocal is the locals key holding the request ID
+const requestIDLocal = "request_id"
+
+// requestIDPattern limits client-supplied request IDs to short tokens that are safe to log and echo
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware accepts the X-Request-ID header or generates a new ID, and echoes it in the response
+func RequestIDMiddleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		requestID := c.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		c.Locals(requestIDLocal, requestID)
+		c.Set("X-Request-ID", requestID)
+		return c.Next()
+	}
+}
+
+// requestID returns the ID assigned by RequestIDMiddleware
+func requestID(c fiber.Ctx) string {
+	id, _ := c.Locals(requestIDLocal).(string)
+	return id
+}
+
 // AuthMiddleware creates a middleware for API key authentication
-func AuthMiddleware(validator *auth.Validator) fiber.Handler {
+func AuthMiddleware(keys *auth.KeyStore) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		apiKey := c.Get("X-API-Key")
 
-		if err := validator.ValidateAPIKey(apiKey); err != nil {
+		key, err := keys.Lookup(apiKey)
+		if err != nil {
 			logger.Warn("Authentication failed: %v", err)
 			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
 				Error:   "Unauthorized",
@@ -23,14 +65,147 @@ func AuthMiddleware(validator *auth.Validator) fiber.Handler {
 			})
 		}
 
+		c.Locals(apiKeyLocal, key)
+		return c.Next()
+	}
+}
+
+// DashboardAuthMiddleware creates a middleware for the API key authentication of the admin dashboard page.
+// Browsers cannot send X-API-Key when opening a page, so the key may also be the password of HTTP Basic auth,
+// which makes them ask for it. Only the page accepts Basic auth: the credentials a browser keeps would
+// otherwise authenticate cross-site requests to the API.
+func DashboardAuthMiddleware(keys *auth.KeyStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		apiKey := c.Get("X-API-Key")
+		if apiKey == "" {
+			apiKey = basicAuthPassword(c.Get(fiber.HeaderAuthorization))
+		}
+
+		key, err := keys.Lookup(apiKey)
+		if err != nil {
+			logger.Warn("Dashboard authentication failed: %v", err)
+			c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="GoVid dashboard", charset="UTF-8"`)
+			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+				Error:   "Unauthorized",
+				Message: "Sign in with an admin API key as the password",
+			})
+		}
+
+		c.Locals(apiKeyLocal, key)
+		return c.Next()
+	}
+}
+
+// basicAuthPassword returns the password of an HTTP Basic Authorization header, or "" if there is none
+func basicAuthPassword(header string) string {
+	encoded, ok := strings.CutPrefix(header, "Basic ")
+	if !ok {
+		return ""
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return ""
+	}
+	_, password, _ := strings.Cut(string(decoded), ":")
+	return password
+}
+
+// RequireScope creates a middleware that rejects keys without the required scope.
+// It must run after AuthMiddleware.
+func RequireScope(scope auth.Scope) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		key := requestKey(c)
+		if !key.Scope.Allows(scope) {
+			logger.Warn("API key %s (scope %s) denied %s %s", key.Name, key.Scope, c.Method(), c.Path())
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error:   "Forbidden",
+				Message: fmt.Sprintf("This endpoint requires the %s scope", scope),
+			})
+		}
+
 		return c.Next()
 	}
 }
 
+// RequireQuota creates a middleware that rejects keys that have used up their monthly processing quota.
+// It must run after AuthMiddleware.
+func RequireQuota(tracker *usage.Tracker) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		key := requestKey(c)
+		if key.QuotaMinutes <= 0 {
+			return c.Next()
+		}
+
+		period, record := tracker.Get(key.Name)
+		if record.OutputMinutes() >= key.QuotaMinutes {
+			logger.Warn("API key %s exceeded its quota for %s", key.Name, period)
+			return c.Status(fiber.StatusTooManyRequests).JSON(models.ErrorResponse{
+				Error:   "Quota exceeded",
+				Message: fmt.Sprintf("Monthly quota of %.0f processed minutes used up for %s", key.QuotaMinutes, period),
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// BodyLimitMiddleware rejects requests that announce a body larger than limit bytes before it is read.
+// Streamed uploads also count the bytes they receive, which covers bodies sent without a Content-Length.
+func BodyLimitMiddleware(limit int64) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if length := int64(c.Request().Header.ContentLength()); length > limit {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(models.ErrorResponse{
+				Error:   "Request too large",
+				Message: fmt.Sprintf("Request body of %d bytes exceeds the limit of %d MB (MAX_UPLOAD_SIZE_MB); upload large files in chunks with /api/v1/upload/init", length, limit>>20),
+			})
+		}
+		return c.Next()
+	}
+}
+
+// requestKey returns the API key that authenticated the request
+func requestKey(c fiber.Ctx) auth.Key {
+	key, _ := c.Locals(apiKeyLocal).(auth.Key)
+	return key
+}
+
+// TracingMiddleware starts a server span for each request, continuing a trace from the traceparent header.
+// The span is stored in the request context, so jobs created by the request join its trace.
+func TracingMiddleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		ctx := tracing.Extract(c.Context(), propagation.HeaderCarrier(http.Header(c.GetReqHeaders())))
+		ctx, span := tracing.Start(ctx, c.Method()+" "+c.Path(),
+			attribute.String("http.request.method", c.Method()),
+			attribute.String("url.path", c.Path()),
+			attribute.String("request_id", requestID(c)),
+		)
+		defer span.End()
+		c.SetContext(ctx)
+
+		err := c.Next()
+
+		span.SetName(c.Method() + " " + c.Route().Path)
+		status := c.Response().StatusCode()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if err != nil {
+			span.RecordError(err)
+		}
+		if err != nil || status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		return err
+	}
+}
+
+// requestSpanContext returns the span context of the request, if it is traced
+func requestSpanContext(c fiber.Ctx) trace.SpanContext {
+	return trace.SpanContextFromContext(c.Context())
+}
+
 // LoggingMiddleware logs incoming requests
 func LoggingMiddleware() fiber.Handler {
 	return func(c fiber.Ctx) error {
-		logger.Info("Request: %s %s from %s", c.Method(), c.Path(), c.IP())
+		logger.With("request_id", requestID(c)).Info("Request: %s %s from %s", c.Method(), c.Path(), c.IP())
 		return c.Next()
 	}
 }
@@ -57,7 +232,8 @@ func CORSMiddleware() fiber.Handler {
 	return func(c fiber.Ctx) error {
 		c.Set("Access-Control-Allow-Origin", "*")
 		c.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key")
+		c.Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key, X-Request-ID, traceparent")
+		c.Set("Access-Control-Expose-Headers", "X-Request-ID")
 
 		if c.Method() == "OPTIONS" {
 			return c.SendStatus(fiber.StatusNoContent)
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+
+	"govid/internal/ffmpeg"
+	"govid/internal/models"
+)
+
+// RunPipeline godoc
+// @Summary Run a pipeline of steps
+// @Description Run an ordered list of trim, merge, overlay, text, audio, subtitles, effect, transcode and upload steps as one job. Steps refer to the outputs of earlier steps as step:<id>, and default to the output of the previous step. The job status reports the progress of each step.
+// @Tags Video
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.PipelineRequest true "Pipeline request"
+// @Success 202 {object} models.JobResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/pipeline [post]
+func (h *Handler) RunPipeline(c fiber.Ctx) error {
+	var req models.PipelineRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+	return h.startPipeline(c, req)
+}
+
+// startPipeline validates a pipeline request and its inputs, and starts its job
+func (h *Handler) startPipeline(c fiber.Ctx, req models.PipelineRequest) error {
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid pipeline",
+			Message: err.Error(),
+		})
+	}
+
+	if err := h.presets.Resolve(&req.OutputOptions); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := h.checkDelivery(&req.DeliveryOptions); err != nil {
+		return deliveryError(c, err)
+	}
+	for _, step := range req.Steps {
+		if font := stepFont(step); font != "" {
+			if _, err := h.fonts.Path(font); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+					Error:   "Invalid pipeline",
+					Message: fmt.Sprintf("step %s: %v", step.ID, err),
+				})
+			}
+		}
+		if step.Output == nil {
+			continue
+		}
+		if err := h.presets.Resolve(step.Output); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid pipeline",
+				Message: fmt.Sprintf("step %s: %v", step.ID, err),
+			})
+		}
+	}
+
+	if err := h.checkInputs(req.InputPaths()...); err != nil {
+		return uploadInputError(c, err)
+	}
+	if err := h.executor.CheckInputLimits(c.Context(), req.Segments()); err != nil {
+		return inputLimitError(c, err)
+	}
+
+	job, response := h.createAndStartJob(c, req.OutputOptions, req.DeliveryOptions)
+	h.startJob(job, models.WorkPipeline, req)
+
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// processPipelineJob runs the steps of a pipeline in order. The final step writes the job's output; the other
+// steps write to the temporary directory, and those files are removed when the job ends.
+func (h *Handler) processPipelineJob(job *models.Job, req models.PipelineRequest) {
+	if !h.awaitJobOutputs(job, pipelineInputRefs(&req)...) {
+		return
+	}
+	inputs := req.InputPaths()
+	job.AddFiles(inputs...)
+	job.SetSteps(req.StepStatuses())
+	final := req.FinalStep()
+
+	var intermediates []string
+	defer func() {
+		for _, path := range intermediates {
+			os.Remove(path)
+		}
+	}()
+
+	h.processJobCommon(job, "pipeline", req.StepOptions(final), segmentPaths(req.Segments()), func(ctx context.Context, outputPath string) error {
+		outputs := make(map[string]string, len(req.Steps))
+		for i, step := range req.Steps {
+			stepInputs := make([]string, len(step.Inputs))
+			for j, input := range step.Inputs {
+				if ref, ok := strings.CutPrefix(input, models.StepRefPrefix); ok {
+					input = outputs[ref]
+				}
+				stepInputs[j] = input
+			}
+
+			stepOutput := outputPath
+			switch {
+			case step.Type == models.StepUpload:
+				stepOutput = stepInputs[0]
+			case i != final:
+				stepOutput = filepath.Join(h.cfg.TempDir, fmt.Sprintf("%s-%s%s", job.ID, step.ID, req.StepOptions(i).OutputFormat.Extension()))
+				intermediates = append(intermediates, stepOutput)
+				job.AddFiles(stepOutput)
+			}
+
+			// Each step takes the job its share of the way from 30% to 90%
+			stepCtx := ffmpeg.WithProgress(ctx, func(fraction float64) {
+				job.UpdateStep(i, models.JobStatusProcessing, int(fraction*100), "")
+				job.AdvanceProgress(30 + int((float64(i)+fraction)*60/float64(len(req.Steps))))
+			})
+			job.UpdateStep(i, models.JobStatusProcessing, 0, "")
+			_ = h.jobStore.Update(job)
+			if err := h.runPipelineStep(stepCtx, job, req, i, stepInputs, stepOutput); err != nil {
+				job.UpdateStep(i, models.JobStatusFailed, 0, err.Error())
+				return fmt.Errorf("step %s (%s): %w", step.ID, step.Type, err)
+			}
+			job.UpdateStep(i, models.JobStatusCompleted, 100, "")
+			_ = h.jobStore.Update(job)
+			outputs[step.ID] = stepOutput
+		}
+		return nil
+	})
+	h.cleanupInputs(job, req.CleanupInputs, inputs...)
+}
+
+// stepFont returns the name of the uploaded font a text or effect step draws with, or "" for none
+func stepFont(step models.PipelineStep) string {
+	switch {
+	case step.Text != nil:
+		return step.Text.Font
+	case step.TemplateEffect != nil:
+		return step.TemplateEffect.Font
+	default:
+		return ""
+	}
+}
+
+// runPipelineStep runs step i of a pipeline on its inputs, resolved to file paths, writing outputPath
+func (h *Handler) runPipelineStep(ctx context.Context, job *models.Job, req models.PipelineRequest, i int, inputs []string, outputPath string) error {
+	step := req.Steps[i]
+	opts := req.StepOptions(i)
+	switch step.Type {
+	case models.StepTrim:
+		segment := models.VideoSegment{FilePath: inputs[0], StartTime: step.StartTime, EndTime: step.EndTime}
+		return h.executor.Transcode(ctx, segment, opts, outputPath)
+	case models.StepMerge:
+		return h.executor.MergeVideos(ctx, pathSegments(inputs...), opts, outputPath)
+	case models.StepOverlay:
+		return h.executor.AddImageOverlay(ctx, inputs[0], *step.Overlay, opts, outputPath)
+	case models.StepText:
+		text := *step.Text
+		if text.Font != "" {
+			var err error
+			if text.FontFile, err = h.fonts.Path(text.Font); err != nil {
+				return err
+			}
+		}
+		return h.executor.AddText(ctx, inputs[0], text, opts, outputPath)
+	case models.StepAudio:
+		return h.executor.AddBackgroundMusic(ctx, inputs[0], *step.Audio, opts, outputPath)
+	case models.StepEffect:
+		effect := *step.TemplateEffect
+		if effect.Font != "" {
+			var err error
+			if effect.FontFile, err = h.fonts.Path(effect.Font); err != nil {
+				return err
+			}
+		}
+		return h.executor.AddTemplateEffect(ctx, inputs[0], effect, opts, outputPath)
+	case models.StepSubtitles:
+		return h.executor.BurnSubtitles(ctx, inputs[0], step.Subtitles.FilePath, opts, outputPath)
+	case models.StepTranscode:
+		return h.executor.Transcode(ctx, models.VideoSegment{FilePath: inputs[0]}, opts, outputPath)
+	case models.StepUpload:
+		var storageOpts models.StorageOptions
+		if step.Storage != nil {
+			storageOpts = *step.Storage
+		}
+		if paths := req.InputPaths(); len(paths) > 0 {
+			storageOpts.OriginalName = originalName(paths[0])
+		}
+		_, err := h.delivery.Store(ctx, job, inputs[0], storageOpts, func(uploaded, total int64) {
+			if total > 0 {
+				job.UpdateStep(i, models.JobStatusProcessing, int(min(uploaded, total)*100/total), "")
+			}
+		})
+		return err
+	default:
+		return fmt.Errorf("unknown step type %s", step.Type)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+
+	"govid/internal/models"
+	"govid/internal/templates"
+	"govid/pkg/logger"
+	"govid/pkg/queue"
+)
+
+// queuedJobOwner is the owner of the jobs started from the job queue, under which their usage is counted
+const queuedJobOwner = "queue"
+
+// ConsumeQueue starts the jobs of the requests consumed from the job queue until ctx is done
+func (h *Handler) ConsumeQueue(ctx context.Context, broker queue.Broker) {
+	logger.Info("Consuming job requests from %s %s", h.cfg.QueueBackend, h.cfg.QueueRequests)
+	// Draining stops the consumer, leaving the requests to the other instances
+	if err := broker.Consume(h.drain.IntakeContext(ctx), h.handleQueueRequest); err != nil {
+		logger.Error("Job queue consumer stopped: %v", err)
+	}
+}
+
+// handleQueueRequest starts the job of a queued request, checked as the pipeline and template endpoints check
+// theirs, and replies with the JobResponse. A request that fails the checks is dropped: it is replied to with
+// an ErrorResponse and published as a failed event.
+func (h *Handler) handleQueueRequest(ctx context.Context, body []byte) ([]byte, error) {
+	var msg models.QueueRequest
+	req, err := h.queuedPipeline(body, &msg)
+	if err != nil {
+		logger.Warn("Rejected queued request %s: %v", msg.RequestID, err)
+		h.delivery.Reject(ctx, msg.RequestID, err)
+		return sonic.Marshal(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+
+	job := models.NewJob(uuid.New().String())
+	job.CreatedBy = queuedJobOwner
+	job.RequestID = msg.RequestID
+	job.Labels = req.Labels
+	job.TTL = req.OutputTTL(h.cfg.OutputTTLSeconds)
+	job.SetDelivery(req.DeliveryOptions)
+	message := "Job created successfully"
+	if runAt := req.RunAtTime(); runAt.After(time.Now()) {
+		job.RunAt = runAt
+		job.Schedule()
+		message = "Job scheduled successfully"
+	}
+	h.jobStore.Add(job)
+	h.startJob(job, models.WorkPipeline, req)
+	job.Logger().Info("Job %s started from the job queue", job.ID)
+
+	return sonic.Marshal(models.JobResponse{
+		JobID:     job.ID,
+		Status:    job.GetStatus().Status,
+		Message:   message,
+		CreatedAt: job.CreatedAt,
+	})
+}
+
+// queuedPipeline decodes a queued request into msg and returns its pipeline, rendered from its template if it
+// names one, once checked
+func (h *Handler) queuedPipeline(body []byte, msg *models.QueueRequest) (models.PipelineRequest, error) {
+	if err := sonic.Unmarshal(body, msg); err != nil {
+		return models.PipelineRequest{}, err
+	}
+	if err := msg.Validate(); err != nil {
+		return models.PipelineRequest{}, err
+	}
+
+	var req models.PipelineRequest
+	if msg.Pipeline != nil {
+		req = *msg.Pipeline
+	} else {
+		template, exists := h.templates.Get(msg.Template)
+		if !exists {
+			return req, templates.ErrNotFound
+		}
+		var err error
+		if req, err = templates.Render(template, msg.Variables); err != nil {
+			return req, err
+		}
+	}
+	return req, h.checkPipeline(&req)
+}
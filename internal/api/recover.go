@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"govid/internal/models"
+	"govid/pkg/config"
+	"govid/pkg/logger"
+)
+
+// interruptedError is the error of a job that was running when the process stopped
+const interruptedError = "interrupted by restart"
+
+// workInputs returns the files that the work of a job reads, by its kind
+var workInputs = map[string]func(work *models.JobWork) ([]string, error){
+	models.WorkMerge:        inputsOf[models.MergeVideoRequest],
+	models.WorkOverlay:      inputsOf[models.OverlayRequest],
+	models.WorkAudio:        inputsOf[models.AudioRequest],
+	models.WorkComplete:     inputsOf[models.CompleteProcessRequest],
+	models.WorkSilence:      inputsOf[models.SilenceRequest],
+	models.WorkVertical:     inputsOf[models.VerticalRequest],
+	models.WorkPipeline:     inputsOf[models.PipelineRequest],
+	models.WorkTranscribe:   inputsOf[models.TranscribeRequest],
+	models.WorkAutoSubtitle: inputsOf[models.AutoSubtitleRequest],
+	models.WorkDefects:      inputsOf[models.DefectsRequest],
+	models.WorkCombine:      inputsOf[combineWork],
+	models.WorkCombineFiles: inputsOf[combineFilesWork],
+}
+
+// inputsOf decodes the work of a job into its request type and returns the request's inputs
+func inputsOf[T any, P interface {
+	*T
+	InputPaths() []string
+}](work *models.JobWork) ([]string, error) {
+	var req T
+	if err := work.Decode(&req); err != nil {
+		return nil, err
+	}
+	return P(&req).InputPaths(), nil
+}
+
+// InputPaths returns nothing, as the videos of a combine are downloaded again
+func (w *combineWork) InputPaths() []string {
+	return nil
+}
+
+// InputPaths returns the uploaded files
+func (w *combineFilesWork) InputPaths() []string {
+	return w.Files
+}
+
+// recoverJobs picks up the jobs that the process left unfinished when it stopped. Scheduled jobs are
+// scheduled again. Pending and processing jobs were interrupted: they fail, or run again from the start with
+// INTERRUPTED_JOBS=requeue if their local inputs still exist. Jobs recorded by versions that did not store
+// their request cannot run again, and fail.
+func (h *Handler) recoverJobs() {
+	requeued, failed := 0, 0
+	for _, job := range h.jobStore.List() {
+		status := job.GetStatus().Status
+		if status != models.JobStatusScheduled && status != models.JobStatusPending && status != models.JobStatusProcessing {
+			continue
+		}
+
+		work := job.GetWork()
+		switch {
+		case work == nil && status == models.JobStatusScheduled:
+			h.failInterrupted(job, "the server restarted before the job was due")
+			failed++
+		case work == nil:
+			h.failInterrupted(job, interruptedError)
+			failed++
+		case status == models.JobStatusScheduled:
+			h.dispatchJob(job)
+		case h.cfg.InterruptedJobs != config.InterruptedRequeue:
+			h.failInterrupted(job, interruptedError)
+			failed++
+		default:
+			if missing, err := missingInput(work); err != nil || missing != "" {
+				reason := fmt.Sprintf("%s; input %s no longer exists", interruptedError, missing)
+				if err != nil {
+					reason = fmt.Sprintf("%s; %v", interruptedError, err)
+				}
+				h.failInterrupted(job, reason)
+				failed++
+				continue
+			}
+			job.Requeue()
+			_ = h.jobStore.Update(job)
+			job.Logger().Info("Job %s requeued after a restart", job.ID)
+			h.dispatchJob(job)
+			requeued++
+		}
+	}
+	if requeued > 0 || failed > 0 {
+		logger.Info("Recovered the jobs interrupted by the restart: %d requeued, %d failed", requeued, failed)
+	}
+}
+
+// failInterrupted fails a job that could not be recovered after a restart and notifies its webhook
+func (h *Handler) failInterrupted(job *models.Job, reason string) {
+	job.Logger().Warn("Job %s failed: %s", job.ID, reason)
+	job.SetError(reason)
+	_ = h.jobStore.Update(job)
+	h.delivery.Notify(context.Background(), job)
+}
+
+// missingInput returns the first local input of work that no longer exists, or an empty string. Inputs
+// given as URLs are fetched again, so they are not checked.
+func missingInput(work *models.JobWork) (string, error) {
+	inputs, ok := workInputs[work.Kind]
+	if !ok {
+		return "", fmt.Errorf("unknown kind of job %q", work.Kind)
+	}
+	paths, err := inputs(work)
+	if err != nil {
+		return "", err
+	}
+	for _, path := range paths {
+		if path == "" || strings.Contains(path, "://") {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return path, nil
+		}
+	}
+	return "", nil
+}
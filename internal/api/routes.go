@@ -8,38 +8,131 @@ import (
 )
 
 // SetupRoutes configures all API routes
-func SetupRoutes(app *fiber.App, handler *Handler, validator *auth.Validator) {
+func SetupRoutes(app *fiber.App, handler *Handler, keys *auth.KeyStore) {
 	// Apply global middleware
+	app.Use(RequestIDMiddleware())
+	app.Use(TracingMiddleware())
 	app.Use(LoggingMiddleware())
 	app.Use(CORSMiddleware())
 
 	// API v1 routes
 	v1 := app.Group("/api/v1")
 
-	// Health check (no auth required)
+	// Health checks (no auth required)
 	v1.Get("/health", handler.HealthCheck)
+	v1.Get("/health/live", handler.HealthCheck)
+	v1.Get("/health/ready", handler.ReadinessCheck)
+
+	// Files of the local storage backend (authorized by the signature of the link)
+	v1.Get("/files/*", handler.ServeStoredFile)
 
 	// Protected routes
 	protected := v1.Group("")
-	protected.Use(AuthMiddleware(validator))
+	protected.Use(AuthMiddleware(keys))
+	protected.Use(BodyLimitMiddleware(int64(handler.cfg.MaxUploadSizeMB) << 20))
+
+	// Inputs given as the output of another job: jobs wait for it, requests answered right away need it completed
+	chained := JobOutputMiddleware(handler.jobStore, true)
+	completed := JobOutputMiddleware(handler.jobStore, false)
+
+	// Requests that create jobs are refused once the instance drains
+	accepting := RejectWhileDraining(handler)
 
 	// Video processing endpoints
-	video := protected.Group("/video")
-	video.Post("/merge", handler.MergeVideos)
-	video.Post("/overlay", handler.AddImageOverlay)
-	video.Post("/audio", handler.AddBackgroundMusic)
-	video.Post("/process", handler.ProcessComplete)
-	video.Post("/combine", handler.CombineVideos)
+	video := protected.Group("/video", RequireScope(auth.ScopeProcess), RequireQuota(handler.usage))
+	video.Post("/merge", accepting, chained, handler.MergeVideos)
+	video.Post("/overlay", accepting, chained, handler.AddImageOverlay)
+	video.Post("/audio", accepting, chained, handler.AddBackgroundMusic)
+	video.Post("/process", accepting, chained, handler.ProcessComplete)
+	video.Post("/combine", accepting, handler.CombineVideos)
+	video.Post("/silence/detect", completed, handler.DetectSilence)
+	video.Post("/probe", completed, handler.ProbeMedia)
+	video.Post("/quality", completed, handler.MeasureQuality)
+	video.Post("/loudness", completed, handler.AnalyzeLoudness)
+	video.Post("/fingerprint", completed, handler.FingerprintVideo)
+	video.Post("/compare", completed, handler.CompareVideos)
+	video.Post("/silence/remove", accepting, chained, handler.RemoveSilence)
+	video.Post("/vertical", accepting, chained, handler.ConvertToVertical)
+	video.Post("/transcribe", accepting, chained, handler.TranscribeVideo)
+	video.Post("/auto-subtitle", accepting, chained, handler.AutoSubtitle)
+	video.Post("/defects", accepting, chained, handler.DetectDefects)
+
+	// Multi-step pipelines
+	protected.Post("/pipeline", RequireScope(auth.ScopeProcess), RequireQuota(handler.usage), accepting, chained, handler.RunPipeline)
+
+	// Job templates
+	protected.Get("/templates", RequireScope(auth.ScopeRead), handler.ListTemplates)
+	protected.Get("/templates/:name", RequireScope(auth.ScopeRead), handler.GetTemplate)
+	protected.Post("/templates/:name/run", RequireScope(auth.ScopeProcess), RequireQuota(handler.usage), accepting, chained, handler.RunTemplate)
+
+	// Fonts for text overlays
+	protected.Get("/fonts", RequireScope(auth.ScopeRead), handler.ListFonts)
+
+	// Encoding presets
+	protected.Get("/presets", RequireScope(auth.ScopeRead), handler.ListPresets)
+
+	// Usage and quota of the calling key
+	protected.Get("/usage", RequireScope(auth.ScopeRead), handler.GetUsage)
 
 	// Job status endpoints
 	jobs := protected.Group("/jobs")
-	jobs.Get("/:id", handler.GetJobStatus)
-	jobs.Get("/:id/download", handler.DownloadOutput)
-	jobs.Post("/:id/create-link", handler.CreateS3Link)
+	jobs.Get("/:id", RequireScope(auth.ScopeRead), handler.GetJobStatus)
+	jobs.Post("/:id/cancel", RequireScope(auth.ScopeProcess), handler.CancelJob)
+	jobs.Get("/:id/download", RequireScope(auth.ScopeRead), handler.DownloadOutput)
+	jobs.Get("/:id/logs", RequireScope(auth.ScopeRead), handler.GetJobLogs)
+	jobs.Post("/:id/create-link", RequireScope(auth.ScopeProcess), handler.CreateS3Link)
+	jobs.Post("/:id/presign", RequireScope(auth.ScopeRead), handler.PresignLink)
 
 	// Upload endpoints
-	protected.Post("/upload", handler.UploadFile)
-	protected.Post("/upload/multiple", handler.UploadMultipleFiles)
+	protected.Post("/upload", RequireScope(auth.ScopeProcess), handler.UploadFile)
+	protected.Post("/upload/multiple", RequireScope(auth.ScopeProcess), handler.UploadMultipleFiles)
+	protected.Post("/upload/from-url", RequireScope(auth.ScopeProcess), handler.UploadFromURL)
+
+	// Direct uploads to the storage bucket
+	protected.Post("/upload/direct", RequireScope(auth.ScopeProcess), handler.CreateDirectUpload)
+
+	// Uploaded files
+	protected.Get("/uploads", RequireScope(auth.ScopeRead), handler.ListUploads)
+	protected.Get("/uploads/:id", RequireScope(auth.ScopeRead), handler.GetUpload)
+	protected.Delete("/uploads/:id", RequireScope(auth.ScopeProcess), handler.DeleteUpload)
+
+	// Saved video fingerprints
+	protected.Get("/fingerprints", RequireScope(auth.ScopeRead), handler.ListFingerprints)
+	protected.Delete("/fingerprints/:id", RequireScope(auth.ScopeProcess), handler.DeleteFingerprint)
+
+	// Chunked uploads
+	protected.Post("/upload/init", RequireScope(auth.ScopeProcess), handler.InitChunkedUpload)
+	protected.Put("/upload/:id/chunk/:n", RequireScope(auth.ScopeProcess), handler.UploadChunk)
+	protected.Post("/upload/:id/complete", RequireScope(auth.ScopeProcess), handler.CompleteChunkedUpload)
+
+	// Admin endpoints
+	admin := protected.Group("/admin", RequireScope(auth.ScopeAdmin))
+
+	// API key management
+	admin.Get("/keys", handler.ListAPIKeys)
+	admin.Post("/keys", handler.CreateAPIKey)
+	admin.Delete("/keys/:name", handler.RevokeAPIKey)
+	admin.Post("/keys/:name/rotate", handler.RotateAPIKey)
+
+	// Job template management
+	admin.Put("/templates/:name", handler.PutTemplate)
+	admin.Delete("/templates/:name", handler.DeleteTemplate)
+
+	// Font management
+	admin.Put("/fonts/:name", handler.PutFont)
+	admin.Delete("/fonts/:name", handler.DeleteFont)
+
+	// Dashboard data and job administration
+	admin.Get("/jobs", handler.ListJobs)
+	admin.Post("/jobs/:id/retry", accepting, handler.RetryJob)
+	admin.Get("/stats", handler.GetStats)
+
+	// Draining ahead of a shutdown
+	admin.Post("/drain", handler.Drain)
+	admin.Get("/drain", handler.GetDrain)
+
+	// Admin dashboard page; the browser asks for an admin key
+	app.Get("/dashboard", DashboardAuthMiddleware(keys), RequireScope(auth.ScopeAdmin), handler.Dashboard)
 
 	// API documentation with Scalar (publicly accessible, no auth required)
 	app.Get("/docs", func(c fiber.Ctx) error {
@@ -63,3 +156,14 @@ func SetupRoutes(app *fiber.App, handler *Handler, validator *auth.Validator) {
 		return c.SendFile("/docs/swagger.yaml")
 	})
 }
+
+// SetupWorkerRoutes configures the routes of a worker, which only answers health checks
+func SetupWorkerRoutes(app *fiber.App, handler *Handler) {
+	app.Use(RequestIDMiddleware())
+	app.Use(LoggingMiddleware())
+
+	v1 := app.Group("/api/v1")
+	v1.Get("/health", handler.HealthCheck)
+	v1.Get("/health/live", handler.HealthCheck)
+	v1.Get("/health/ready", handler.ReadinessCheck)
+}
@@ -0,0 +1,283 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"govid/internal/models"
+	"govid/internal/scheduler"
+	"govid/internal/templates"
+	"govid/pkg/logger"
+)
+
+// ListTemplates godoc
+// @Summary List job templates
+// @Description List the job templates, pipelines with {{name}} placeholders that can be run with only their parameters
+// @Tags Templates
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.TemplatesResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/templates [get]
+func (h *Handler) ListTemplates(c fiber.Ctx) error {
+	return c.JSON(models.TemplatesResponse{
+		Templates: h.templates.List(),
+	})
+}
+
+// GetTemplate godoc
+// @Summary Get a job template
+// @Tags Templates
+// @Security ApiKeyAuth
+// @Produce json
+// @Param name path string true "Template name"
+// @Success 200 {object} models.JobTemplate
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/templates/{name} [get]
+func (h *Handler) GetTemplate(c fiber.Ctx) error {
+	template, exists := h.templates.Get(c.Params("name"))
+	if !exists {
+		return templateErrorResponse(c, templates.ErrNotFound)
+	}
+	return c.JSON(template)
+}
+
+// RunTemplate godoc
+// @Summary Run a job template
+// @Description Start a pipeline job from a template, with the given values for its parameters. Parameters left out take their defaults; those without a default are required.
+// @Tags Templates
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param name path string true "Template name"
+// @Param request body models.TemplateRunRequest true "Parameter values"
+// @Success 202 {object} models.JobResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/templates/{name}/run [post]
+func (h *Handler) RunTemplate(c fiber.Ctx) error {
+	template, exists := h.templates.Get(c.Params("name"))
+	if !exists {
+		return templateErrorResponse(c, templates.ErrNotFound)
+	}
+
+	var req models.TemplateRunRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().JSON(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request body",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	pipeline, err := templates.Render(template, req.Variables)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid template parameters",
+			Message: err.Error(),
+		})
+	}
+	return h.startPipeline(c, pipeline)
+}
+
+// PutTemplate godoc
+// @Summary Create or replace a job template
+// @Description Save a job template under the name in the path. The template is written to TEMPLATES_DIR. A template with a schedule runs on it with the defaults of its parameters.
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param name path string true "Template name"
+// @Param request body models.JobTemplate true "Template"
+// @Success 200 {object} models.JobTemplate
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/admin/templates/{name} [put]
+func (h *Handler) PutTemplate(c fiber.Ctx) error {
+	var template models.JobTemplate
+	if err := c.Bind().JSON(&template); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+	template.Name = strings.Clone(c.Params("name")) // the parameter's memory is reused after the request
+
+	if err := h.templates.Put(template); err != nil {
+		return templateErrorResponse(c, err)
+	}
+
+	h.scheduleTemplate(template)
+
+	logger.Info("Job template %s saved by %s", template.Name, requestKey(c).Name)
+	return c.JSON(template)
+}
+
+// DeleteTemplate godoc
+// @Summary Delete a job template
+// @Description Delete a job template and its file in TEMPLATES_DIR
+// @Tags Admin
+// @Security ApiKeyAuth
+// @Param name path string true "Template name"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/admin/templates/{name} [delete]
+func (h *Handler) DeleteTemplate(c fiber.Ctx) error {
+	name := c.Params("name")
+	if err := h.templates.Delete(name); err != nil {
+		return templateErrorResponse(c, err)
+	}
+	h.scheduler.Remove(templateScheduleName(name))
+
+	logger.Info("Job template %s deleted by %s", name, requestKey(c).Name)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// templateErrorResponse sends the error response for a failed template operation
+func templateErrorResponse(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, templates.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Template not found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, templates.ErrStorage):
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to store template",
+			Message: err.Error(),
+		})
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid template",
+			Message: err.Error(),
+		})
+	}
+}
+
+// scheduledJobOwner is the owner of the jobs started by template schedules, under which their usage is counted
+const scheduledJobOwner = "scheduler"
+
+// templateScheduleName returns the name of the recurring schedule of a template
+func templateScheduleName(name string) string {
+	return "template:" + name
+}
+
+// templatesRefreshSchedule is the name of the schedule that loads the template changes of other instances
+const templatesRefreshSchedule = "templates-refresh"
+
+// scheduleTemplates schedules the runs of the templates that have a schedule, and follows the changes that
+// other instances sharing the templates directory make to them
+func (h *Handler) scheduleTemplates() {
+	for _, template := range h.templates.List() {
+		h.scheduleTemplate(template)
+	}
+	h.templates.OnChange(func(name string) {
+		if template, exists := h.templates.Get(name); exists {
+			h.scheduleTemplate(template)
+		} else {
+			h.scheduler.Remove(templateScheduleName(name))
+		}
+	})
+	everyMinute, _ := scheduler.ParseCron("* * * * *")
+	h.scheduler.Every(templatesRefreshSchedule, everyMinute, h.templates.Refresh)
+}
+
+// scheduleTemplate schedules the runs of a template on its schedule, replacing those of an earlier version of
+// it, or removes them if it no longer has a schedule
+func (h *Handler) scheduleTemplate(template models.JobTemplate) {
+	scheduleName := templateScheduleName(template.Name)
+	if template.Schedule == "" {
+		h.scheduler.Remove(scheduleName)
+		return
+	}
+	spec, err := scheduler.ParseCron(template.Schedule) // checked by templates.Validate
+	if err != nil {
+		logger.Error("Failed to schedule job template %s: %v", template.Name, err)
+		return
+	}
+
+	name := template.Name
+	h.scheduler.Every(scheduleName, spec, func() {
+		h.runScheduledTemplate(name)
+	})
+	if next, ok := h.scheduler.NextRun(scheduleName); ok {
+		logger.Info("Job template %s runs on schedule %q, next at %s", name, template.Schedule, next.Format(time.RFC3339))
+	} else {
+		logger.Warn("Job template %s has schedule %q, which never matches", name, template.Schedule)
+	}
+}
+
+// runScheduledTemplate starts a pipeline job from a template with the defaults of its parameters. The pipeline
+// is checked as for a run through the API; a run that fails the checks is logged and skipped.
+func (h *Handler) runScheduledTemplate(name string) {
+	template, exists := h.templates.Get(name)
+	if !exists {
+		return
+	}
+	if h.drain.Draining() {
+		logger.Warn("Scheduled run of job template %s skipped: the instance is draining", name)
+		return
+	}
+	req, err := templates.Render(template, nil)
+	if err == nil {
+		err = h.checkPipeline(&req)
+	}
+	if err != nil {
+		logger.Error("Scheduled run of job template %s failed: %v", name, err)
+		return
+	}
+
+	job := models.NewJob(uuid.New().String())
+	job.CreatedBy = scheduledJobOwner
+	job.Labels = req.Labels
+	job.TTL = req.OutputTTL(h.cfg.OutputTTLSeconds)
+	job.SetDelivery(req.DeliveryOptions)
+	h.jobStore.Add(job)
+	h.startJob(job, models.WorkPipeline, req)
+	job.Logger().Info("Job %s started by the schedule of job template %s", job.ID, name)
+}
+
+// checkPipeline validates a pipeline that is not started by an API request, such as a scheduled template run,
+// and checks its inputs, as startPipeline does for a request
+func (h *Handler) checkPipeline(req *models.PipelineRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	if err := h.presets.Resolve(&req.OutputOptions); err != nil {
+		return err
+	}
+	for _, step := range req.Steps {
+		if step.Output == nil {
+			continue
+		}
+		if err := h.presets.Resolve(step.Output); err != nil {
+			return fmt.Errorf("step %s: %w", step.ID, err)
+		}
+	}
+	if err := h.checkDelivery(&req.DeliveryOptions); err != nil {
+		return err
+	}
+	if err := h.checkInputs(req.InputPaths()...); err != nil {
+		return err
+	}
+	return h.executor.CheckInputLimits(context.Background(), req.Segments())
+}
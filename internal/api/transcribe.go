@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gofiber/fiber/v3"
+
+	"govid/internal/models"
+	"govid/pkg/transcribe"
+)
+
+// TranscribeVideo godoc
+// @Summary Transcribe the speech of a video
+// @Description Transcribe the speech of a video into SRT or VTT subtitles with the configured speech-to-text backend (TRANSCRIBE_BACKEND). The subtitles are the job's output, which a pipeline subtitles step can burn in through {"job_output": "<id>"}.
+// @Tags Video
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.TranscribeRequest true "Transcription request"
+// @Success 202 {object} models.JobResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /api/v1/video/transcribe [post]
+func (h *Handler) TranscribeVideo(c fiber.Ctx) error {
+	if h.transcriber == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(models.ErrorResponse{
+			Error:   "Transcription not configured",
+			Message: "set TRANSCRIBE_BACKEND to whisper or http to transcribe videos",
+		})
+	}
+
+	var req models.TranscribeRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+	if req.VideoPath == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "video_path is required",
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := h.checkVideoPath(req.VideoPath); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+
+	if err := h.checkInputs(localPaths(req.VideoPath)...); err != nil {
+		return uploadInputError(c, err)
+	}
+	if err := h.executor.CheckInputLimits(c.Context(), pathSegments(req.VideoPath)); err != nil {
+		return inputLimitError(c, err)
+	}
+
+	if err := h.checkDelivery(&req.DeliveryOptions); err != nil {
+		return deliveryError(c, err)
+	}
+
+	job, response := h.createAndStartJob(c, req.OutputOptions, req.DeliveryOptions)
+	h.startJob(job, models.WorkTranscribe, req)
+
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// AutoSubtitle godoc
+// @Summary Caption a video with its transcribed speech
+// @Description Transcribe the speech of a video with the configured speech-to-text backend (TRANSCRIBE_BACKEND) and burn it in as captions in a style: font, size, colors, position, words per caption, and karaoke highlighting of the spoken word
+// @Tags Video
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.AutoSubtitleRequest true "Auto-subtitle request"
+// @Success 202 {object} models.JobResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /api/v1/video/auto-subtitle [post]
+func (h *Handler) AutoSubtitle(c fiber.Ctx) error {
+	if h.transcriber == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(models.ErrorResponse{
+			Error:   "Transcription not configured",
+			Message: "set TRANSCRIBE_BACKEND to whisper or http to caption videos",
+		})
+	}
+
+	var req models.AutoSubtitleRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+	}
+	if req.VideoPath == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: "video_path is required",
+		})
+	}
+	if err := h.presets.Resolve(&req.OutputOptions); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+	if err := h.checkVideoPath(req.VideoPath); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	}
+
+	if err := h.checkInputs(localPaths(req.VideoPath)...); err != nil {
+		return uploadInputError(c, err)
+	}
+	if err := h.executor.CheckInputLimits(c.Context(), pathSegments(req.VideoPath)); err != nil {
+		return inputLimitError(c, err)
+	}
+
+	if err := h.checkDelivery(&req.DeliveryOptions); err != nil {
+		return deliveryError(c, err)
+	}
+
+	job, response := h.createAndStartJob(c, req.OutputOptions, req.DeliveryOptions)
+	h.startJob(job, models.WorkAutoSubtitle, req)
+
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// processTranscribeJob transcribes the speech of a video and writes the subtitles as the job's output
+func (h *Handler) processTranscribeJob(job *models.Job, req models.TranscribeRequest) {
+	if !h.awaitJobOutputs(job, &req.VideoPath) {
+		return
+	}
+	input := req.VideoPath
+	h.processVideoJob(job, "transcription", req.SubtitleOptions(), req.VideoPath, func(ctx context.Context, videoPath, outputPath string) error {
+		cues, err := h.transcribeSpeech(ctx, job, videoPath, req.Language)
+		if err != nil {
+			return err
+		}
+		return transcribe.WriteFile(outputPath, cues)
+	})
+	h.cleanupInputs(job, req.CleanupInputs, input)
+}
+
+// processAutoSubtitleJob transcribes the speech of a video and burns it into the video as styled captions
+func (h *Handler) processAutoSubtitleJob(job *models.Job, req models.AutoSubtitleRequest) {
+	if !h.awaitJobOutputs(job, &req.VideoPath) {
+		return
+	}
+	input := req.VideoPath
+	captionsPath := filepath.Join(h.cfg.TempDir, job.ID+"-captions.ass")
+	defer os.Remove(captionsPath)
+	h.processVideoJob(job, "auto-subtitle", req.OutputOptions, req.VideoPath, func(ctx context.Context, videoPath, outputPath string) error {
+		cues, err := h.transcribeSpeech(ctx, job, videoPath, req.Language)
+		if err != nil {
+			return err
+		}
+		job.AddFiles(captionsPath)
+		return h.executor.BurnCaptions(ctx, videoPath, cues, req.Style, captionsPath, req.OutputOptions, outputPath)
+	})
+	h.cleanupInputs(job, req.CleanupInputs, input)
+}
+
+// transcribeSpeech extracts the speech of videoPath to the temporary directory and transcribes it
+func (h *Handler) transcribeSpeech(ctx context.Context, job *models.Job, videoPath, language string) ([]transcribe.Cue, error) {
+	// A worker without a transcriber cannot run jobs taken by an API instance with one
+	if h.transcriber == nil {
+		return nil, fmt.Errorf("transcription is not configured: TRANSCRIBE_BACKEND is not set")
+	}
+
+	speechPath := filepath.Join(h.cfg.TempDir, job.ID+"-speech.wav")
+	job.AddFiles(speechPath)
+	defer os.Remove(speechPath)
+	if err := h.executor.ExtractSpeech(ctx, videoPath, speechPath); err != nil {
+		return nil, err
+	}
+
+	cues, err := h.transcriber.Transcribe(ctx, speechPath, language)
+	if err != nil {
+		return nil, fmt.Errorf("%s transcription failed: %w", h.transcriber.Name(), err)
+	}
+	job.Logger().Info("Transcribed %d subtitles for job %s", len(cues), job.ID)
+	return cues, nil
+}
@@ -0,0 +1,309 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"govid/internal/models"
+	"govid/internal/uploads"
+	"govid/pkg/filetype"
+	"govid/pkg/logger"
+)
+
+// maxFormValueSize limits the size of a single non-file multipart field
+const maxFormValueSize = 1 << 20
+
+// Errors returned by streamMultipartUpload
+var (
+	errTooManyFiles   = errors.New("too many files")
+	errUploadTooLarge = errors.New("upload too large")
+	errUploadSave     = errors.New("failed to save uploaded file")
+)
+
+// streamedUpload holds the files and form fields read from a streamed multipart request
+type streamedUpload struct {
+	paths []string        // saved files in upload order
+	sizes []int64         // sizes of the saved files
+	sums  []string        // hex SHA-256 of the saved files
+	form  *multipart.Form // non-file fields only
+}
+
+// remove deletes every file saved by the upload
+func (u *streamedUpload) remove() {
+	for _, path := range u.paths {
+		os.Remove(path)
+	}
+}
+
+// streamMultipartUpload reads a multipart request part by part and writes each file in field straight to dir,
+// so large batches are never held in memory. name returns the file name for the i-th upload. Requests with more
+// than maxFiles files, a file over MaxFileSizeMB, more than MaxUploadSizeMB of file data, or files the upload
+// policy rejects fail and saved files are removed.
+func (h *Handler) streamMultipartUpload(c fiber.Ctx, field, dir string, maxFiles int, name func(i int, filename string) string) (*streamedUpload, error) {
+	body := c.Request().BodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+	reader := multipart.NewReader(body, string(c.Request().Header.MultipartFormBoundary()))
+
+	upload := &streamedUpload{
+		form: &multipart.Form{Value: make(map[string][]string)},
+	}
+	remaining := int64(h.cfg.MaxUploadSizeMB) << 20
+	maxFileSize := int64(h.cfg.MaxFileSizeMB) << 20
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return upload, nil
+		}
+		if err != nil {
+			upload.remove()
+			return nil, err
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, maxFormValueSize))
+			part.Close()
+			if err != nil {
+				upload.remove()
+				return nil, err
+			}
+			upload.form.Value[part.FormName()] = append(upload.form.Value[part.FormName()], string(value))
+			continue
+		}
+
+		if part.FormName() != field {
+			part.Close()
+			continue
+		}
+
+		if err := h.fileTypes.CheckName(part.FileName()); err != nil {
+			part.Close()
+			upload.remove()
+			return nil, err
+		}
+
+		if len(upload.paths) >= maxFiles {
+			part.Close()
+			upload.remove()
+			return nil, fmt.Errorf("%w: maximum %d files allowed", errTooManyFiles, maxFiles)
+		}
+
+		savePath := filepath.Join(dir, name(len(upload.paths), part.FileName()))
+		written, sum, err := saveUploadPart(part, savePath, min(remaining, maxFileSize))
+		part.Close()
+		if err != nil {
+			upload.remove()
+			if errors.Is(err, errUploadTooLarge) {
+				err = h.uploadTooLarge(part.FileName(), maxFileSize < remaining)
+			}
+			return nil, err
+		}
+		upload.paths = append(upload.paths, savePath)
+		upload.sizes = append(upload.sizes, written)
+		upload.sums = append(upload.sums, sum)
+		remaining -= written
+
+		// Reject files whose content does not match their extension before they reach ffmpeg
+		if err := h.fileTypes.CheckFile(part.FileName(), savePath); err != nil {
+			upload.remove()
+			return nil, err
+		}
+	}
+}
+
+// saveUploadPart copies a file part to path, failing once more than limit bytes have been written, and returns
+// its size and hex SHA-256. The file is removed on failure.
+func saveUploadPart(part io.Reader, path string, limit int64) (int64, string, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %v", errUploadSave, err)
+	}
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(part, limit+1))
+	file.Close()
+	switch {
+	case err != nil:
+		err = fmt.Errorf("%w: %v", errUploadSave, err)
+	case written > limit:
+		err = errUploadTooLarge
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, "", err
+	}
+	return written, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// dedup replaces the saved files with earlier identical uploads where there are any, and gives new ones their
+// content-addressed names. It reports which files were already stored. On failure the files not yet renamed
+// are removed.
+func (u *streamedUpload) dedup() ([]bool, error) {
+	existed := make([]bool, len(u.paths))
+	for i, path := range u.paths {
+		deduped, ok, err := uploads.Dedup(path, u.sums[i], path)
+		if err != nil {
+			for _, rest := range u.paths[i:] {
+				os.Remove(rest)
+			}
+			return nil, fmt.Errorf("%w: %v", errUploadSave, err)
+		}
+		u.paths[i] = deduped
+		existed[i] = ok
+	}
+	return existed, nil
+}
+
+// uploadTooLarge returns the error for an upload over the size limits: of a single file, or of the request
+func (h *Handler) uploadTooLarge(filename string, fileLimit bool) error {
+	if fileLimit {
+		return fmt.Errorf("%w: %q exceeds the limit of %d MB per file (MAX_FILE_SIZE_MB); use a chunked upload or split the video",
+			errUploadTooLarge, filename, h.cfg.MaxFileSizeMB)
+	}
+	return fmt.Errorf("%w: files exceed the limit of %d MB per request (MAX_UPLOAD_SIZE_MB); send them in several requests",
+		errUploadTooLarge, h.cfg.MaxUploadSizeMB)
+}
+
+// checkFormFiles checks the size, extension and content of multipart files before they are saved
+func (h *Handler) checkFormFiles(files ...*multipart.FileHeader) error {
+	var total int64
+	for _, fileHeader := range files {
+		total += fileHeader.Size
+		if fileHeader.Size > int64(h.cfg.MaxFileSizeMB)<<20 {
+			return h.uploadTooLarge(fileHeader.Filename, true)
+		}
+		if total > int64(h.cfg.MaxUploadSizeMB)<<20 {
+			return h.uploadTooLarge(fileHeader.Filename, false)
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			return err
+		}
+		header := make([]byte, filetype.HeaderSize)
+		n, _ := io.ReadFull(file, header)
+		file.Close()
+		if err := h.fileTypes.CheckContent(fileHeader.Filename, header[:n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadErrorResponse sends the error response for a failed upload
+func uploadErrorResponse(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, filetype.ErrNotAllowed):
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(models.ErrorResponse{
+			Error:   "Unsupported file type",
+			Message: err.Error(),
+		})
+	case errors.Is(err, errTooManyFiles):
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Too many files",
+			Message: err.Error(),
+		})
+	case errors.Is(err, errUploadTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(models.ErrorResponse{
+			Error:   "Upload too large",
+			Message: err.Error(),
+		})
+	case errors.Is(err, uploads.ErrInfected):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.ErrorResponse{
+			Error:   "Upload quarantined",
+			Message: err.Error(),
+		})
+	case errors.Is(err, uploads.ErrScanFailed):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(models.ErrorResponse{
+			Error:   "Upload scan failed",
+			Message: err.Error(),
+		})
+	case errors.Is(err, errUploadSave):
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to save uploaded file",
+			Message: err.Error(),
+		})
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "Invalid multipart form",
+			Message: err.Error(),
+		})
+	}
+}
+
+// uploadTTL returns the TTL of an upload requested with ttl_seconds, where 0 selects UPLOAD_TTL_SECONDS
+func (h *Handler) uploadTTL(seconds int) (time.Duration, error) {
+	if seconds < 0 {
+		return 0, fmt.Errorf("ttl_seconds must not be negative")
+	}
+	if seconds == 0 {
+		seconds = h.cfg.UploadTTLSeconds
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// formUploadTTL reads the TTL of an upload from the ttl_seconds field of a multipart form
+func (h *Handler) formUploadTTL(form *multipart.Form) (time.Duration, error) {
+	seconds := 0
+	if value := formValue(form, "ttl_seconds"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("ttl_seconds must be an integer")
+		}
+		seconds = n
+	}
+	return h.uploadTTL(seconds)
+}
+
+// scanUploads scans stored uploads when UPLOAD_SCAN_BACKEND is set and returns their scan statuses, which are
+// empty when scanning is disabled. existed tells which uploads were already stored, and may be nil if none were.
+// If an upload is infected or cannot be scanned, the new uploads of the request are removed as well, so no
+// unscanned file is left behind.
+func (h *Handler) scanUploads(ctx context.Context, paths []string, existed []bool) ([]string, error) {
+	statuses := make([]string, len(paths))
+	if h.scanner == nil {
+		return statuses, nil
+	}
+	for i, path := range paths {
+		record, err := uploads.Scan(ctx, h.scanner, path, h.cfg.QuarantineDir, existed != nil && existed[i])
+		if err != nil {
+			if errors.Is(err, uploads.ErrInfected) {
+				logger.Warn("Quarantined upload: %v", err)
+			} else {
+				logger.Error("Failed to scan upload %s: %v", filepath.Base(path), err)
+			}
+			for j, other := range paths {
+				if existed == nil || !existed[j] {
+					os.Remove(other)
+				}
+			}
+			return nil, err
+		}
+		statuses[i] = record.Status
+	}
+	return statuses, nil
+}
+
+// expireUpload gives a stored upload its TTL and returns its expiry, if it has one. A failure is only logged:
+// the upload then stays until the retention cleanup.
+func (h *Handler) expireUpload(path string, ttl time.Duration, existed bool) *time.Time {
+	expires, err := uploads.ApplyTTL(h.cfg.UploadDir, path, ttl, existed)
+	if err != nil {
+		logger.Warn("Failed to set the expiry of upload %s: %v", filepath.Base(path), err)
+	}
+	return expires
+}
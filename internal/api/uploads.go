@@ -0,0 +1,140 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"govid/internal/models"
+	"govid/internal/uploads"
+	"govid/pkg/logger"
+)
+
+// ListUploads godoc
+// @Summary List uploaded files
+// @Description List the files in the upload directory, newest first, with their size, age, and when cleanup removes them
+// @Tags Upload
+// @Security ApiKeyAuth
+// @Produce json
+// @Param limit query int false "Maximum number of files to return (default 100)"
+// @Success 200 {object} models.UploadListResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/uploads [get]
+func (h *Handler) ListUploads(c fiber.Ctx) error {
+	limit := 100
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "Invalid request",
+				Message: "limit must be a positive integer",
+			})
+		}
+		limit = n
+	}
+
+	files, err := uploads.List(h.cfg.UploadDir)
+	if err != nil {
+		logger.Error("Failed to list uploads: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "Failed to list uploads",
+			Message: err.Error(),
+		})
+	}
+
+	now := time.Now()
+	response := models.UploadListResponse{
+		Uploads: make([]models.UploadedFile, 0, min(limit, len(files))),
+		Total:   len(files),
+	}
+	for _, file := range files {
+		response.TotalSize += file.Size
+		if len(response.Uploads) < limit {
+			response.Uploads = append(response.Uploads, h.uploadedFile(file, now))
+		}
+	}
+
+	return c.JSON(response)
+}
+
+// GetUpload godoc
+// @Summary Get an uploaded file
+// @Description Get the size, age, and cleanup time of a file in the upload directory
+// @Tags Upload
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path string true "Upload ID (the file name)"
+// @Success 200 {object} models.UploadedFile
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/uploads/{id} [get]
+func (h *Handler) GetUpload(c fiber.Ctx) error {
+	file, err := uploads.Stat(h.cfg.UploadDir, c.Params("id"))
+	if err != nil {
+		return uploadedFileError(c, err)
+	}
+	return c.JSON(h.uploadedFile(file, time.Now()))
+}
+
+// DeleteUpload godoc
+// @Summary Delete an uploaded file
+// @Description Delete a file from the upload directory. Uploads are deduplicated, so the file may also be in use by other clients, and jobs still reading it fail.
+// @Tags Upload
+// @Security ApiKeyAuth
+// @Param id path string true "Upload ID (the file name)"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/uploads/{id} [delete]
+func (h *Handler) DeleteUpload(c fiber.Ctx) error {
+	if err := uploads.Delete(h.cfg.UploadDir, c.Params("id")); err != nil {
+		return uploadedFileError(c, err)
+	}
+	logger.Info("Upload %s deleted by %s", c.Params("id"), requestKey(c).Name)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// uploadedFile describes an uploaded file at time now
+func (h *Handler) uploadedFile(file uploads.File, now time.Time) models.UploadedFile {
+	uploaded := models.UploadedFile{
+		ID:         file.Name,
+		FilePath:   file.Path,
+		FileSize:   file.Size,
+		ModifiedAt: file.ModTime,
+		AgeSeconds: int64(now.Sub(file.ModTime).Seconds()),
+	}
+	if expires, ok := uploads.Expiry(h.cfg.UploadDir, file.Name); ok {
+		uploaded.ExpiresAt = &expires
+	} else if h.cfg.CleanupEnabled {
+		expires := file.ModTime.AddDate(0, 0, h.cfg.CleanupRetentionDays)
+		uploaded.ExpiresAt = &expires
+	}
+	if record, ok := uploads.ScanStatus(h.cfg.UploadDir, file.Name); ok {
+		uploaded.ScanStatus, uploaded.ScannedAt = record.Status, &record.ScannedAt
+	} else if h.scanner != nil {
+		uploaded.ScanStatus = uploads.ScanUnscanned
+	}
+	return uploaded
+}
+
+// uploadedFileError sends the error response for a failed lookup or removal of an uploaded file
+func uploadedFileError(c fiber.Ctx, err error) error {
+	if errors.Is(err, uploads.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "Upload not found",
+			Message: fmt.Sprintf("Upload with ID %s does not exist", c.Params("id")),
+		})
+	}
+	logger.Error("Failed to access upload %s: %v", c.Params("id"), err)
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error:   "Failed to access upload",
+		Message: err.Error(),
+	})
+}
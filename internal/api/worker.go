@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"govid/internal/models"
+	"govid/pkg/config"
+	"govid/pkg/downloader"
+	"govid/pkg/logger"
+)
+
+// workerPollInterval is how often a worker looks for pending jobs in the job store
+const workerPollInterval = time.Second
+
+// jobWork runs the recorded work of a job by its kind
+var jobWork = map[string]func(h *Handler, job *models.Job, work *models.JobWork) error{
+	models.WorkMerge:        runWork((*Handler).processMergeJob),
+	models.WorkOverlay:      runWork((*Handler).processOverlayJob),
+	models.WorkAudio:        runWork((*Handler).processAudioJob),
+	models.WorkComplete:     runWork((*Handler).processCompleteJob),
+	models.WorkSilence:      runWork((*Handler).processSilenceRemovalJob),
+	models.WorkVertical:     runWork((*Handler).processVerticalJob),
+	models.WorkPipeline:     runWork((*Handler).processPipelineJob),
+	models.WorkTranscribe:   runWork((*Handler).processTranscribeJob),
+	models.WorkAutoSubtitle: runWork((*Handler).processAutoSubtitleJob),
+	models.WorkDefects:      runWork((*Handler).processDefectsJob),
+	models.WorkCombine:      runWork((*Handler).processCombineWork),
+	models.WorkCombineFiles: runWork((*Handler).processCombineFilesWork),
+}
+
+// runWork adapts the process function of a request type to run the work of a job
+func runWork[T any](process func(*Handler, *models.Job, T)) func(*Handler, *models.Job, *models.JobWork) error {
+	return func(h *Handler, job *models.Job, work *models.JobWork) error {
+		var req T
+		if err := work.Decode(&req); err != nil {
+			return err
+		}
+		process(h, job, req)
+		return nil
+	}
+}
+
+// combineWork is the work of a combine job from URLs
+type combineWork struct {
+	Videos       []string              `json:"videos"`
+	Checksums    []downloader.Checksum `json:"checksums"`
+	Output       models.OutputOptions  `json:"output"`
+	Storage      models.StorageOptions `json:"storage"`
+	OriginalName string                `json:"original_name"` // of Storage, which has no JSON field
+}
+
+// combineFilesWork is the work of a combine job from uploaded files
+type combineFilesWork struct {
+	Files        []string              `json:"files"`
+	Output       models.OutputOptions  `json:"output"`
+	Storage      models.StorageOptions `json:"storage"`
+	OriginalName string                `json:"original_name"`
+}
+
+// processCombineWork runs a combine job from URLs
+func (h *Handler) processCombineWork(job *models.Job, work combineWork) {
+	work.Storage.OriginalName = work.OriginalName
+	h.processCombineJobFromURLs(job, work.Videos, work.Checksums, work.Output, work.Storage)
+}
+
+// processCombineFilesWork runs a combine job from uploaded files
+func (h *Handler) processCombineFilesWork(job *models.Job, work combineFilesWork) {
+	work.Storage.OriginalName = work.OriginalName
+	h.processCombineJobFromFiles(job, work.Files, work.Output, work.Storage)
+}
+
+// runJob runs the recorded work of a job, failing the job if the work cannot run
+func (h *Handler) runJob(job *models.Job) {
+	work := job.GetWork()
+	run, ok := jobWork[work.Kind]
+	var err error
+	if ok {
+		err = run(h, job, work)
+	} else {
+		err = fmt.Errorf("unknown kind of job %q", work.Kind)
+	}
+	if err != nil {
+		job.Logger().Error("Job %s failed: %v", job.ID, err)
+		job.SetError(err.Error())
+		_ = h.jobStore.Update(job)
+		h.delivery.Notify(context.Background(), job)
+	}
+}
+
+// RunWorker claims the pending jobs that API instances leave in the shared job store and runs them, at most
+// MAX_CONCURRENT_JOBS at a time, until ctx is done. Jobs run under jobWG, which the shutdown waits for.
+func (h *Handler) RunWorker(ctx context.Context) {
+	logger.Info("Worker claiming jobs from %s", h.jobStore.GetJobsDir())
+	slots := make(chan struct{}, h.cfg.MaxConcurrentJobs)
+	ticker := time.NewTicker(workerPollInterval)
+	defer ticker.Stop()
+	for {
+		h.claimJobs(slots)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimJobs claims pending jobs, oldest first, while a slot is free, and runs each in its slot
+func (h *Handler) claimJobs(slots chan struct{}) {
+	jobs := h.jobStore.List()
+	slices.Reverse(jobs)
+	for _, job := range jobs {
+		if job.GetStatus().Status != models.JobStatusPending || job.GetWork() == nil || job.Claimed() {
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			return
+		}
+		claimed, err := h.jobStore.Claim(job)
+		if !claimed {
+			if err != nil {
+				logger.Error("Failed to claim job %s: %v", job.ID, err)
+			}
+			<-slots
+			continue
+		}
+
+		job.Logger().Info("Job %s claimed by this worker", job.ID)
+		h.jobWG.Add(1)
+		go func() {
+			defer h.jobWG.Done()
+			defer func() { <-slots }()
+			h.runJob(job)
+		}()
+	}
+}
+
+// leftToWorkers reports whether jobs are left in the job store for workers instead of running here
+func (h *Handler) leftToWorkers() bool {
+	return h.cfg.RunMode == config.ModeAPI
+}
@@ -0,0 +1,317 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+
+	"govid/internal/ffmpeg"
+	"govid/internal/models"
+	"govid/pkg/config"
+	"govid/pkg/logger"
+	"govid/pkg/queue"
+	"govid/pkg/storage"
+	"govid/pkg/webhook"
+)
+
+// ErrNoStorage is returned when an output is to be uploaded but the storage backend failed to initialize
+var ErrNoStorage = errors.New("storage configuration is missing or invalid")
+
+// maxOutputRenames is how many numbered names OutputPath tries for a named output before giving up
+const maxOutputRenames = 1000
+
+// Service hands the results of jobs over: it uploads outputs to the storage backend, with their sidecars, and
+// notifies the webhooks of jobs that end and the events destination of the job queue
+type Service struct {
+	cfg      *config.Config
+	executor *ffmpeg.Executor
+	uploader storage.Uploader // nil when the storage backend failed to initialize
+	webhook  *webhook.Client
+	events   queue.Broker // nil without a job queue
+}
+
+// New creates a service for the configured storage backend. A backend that fails to initialize is logged,
+// and uploads then fail with ErrNoStorage. events, which may be nil, is the job queue that the completion
+// events of jobs are published to.
+func New(cfg *config.Config, executor *ffmpeg.Executor, events queue.Broker) *Service {
+	uploader, err := storage.New(context.Background(), storage.Config{
+		Backend: cfg.StorageBackend,
+		S3: storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			UseSSL:    cfg.S3UseSSL,
+			PartSize:  uint64(cfg.S3PartSizeMB) << 20,
+			Threads:   uint(cfg.S3UploadThreads),
+			Retries:   cfg.S3UploadRetries,
+		},
+		GCS: storage.GCSConfig{
+			Bucket:          cfg.GCSBucket,
+			CredentialsFile: cfg.GCSCredentialsFile,
+		},
+		Local: storage.LocalConfig{
+			Dir:     cfg.OutputDir,
+			BaseURL: strings.TrimSuffix(cfg.PublicBaseURL, "/") + "/api/v1/files",
+			Secret:  cfg.LocalStorageSecret,
+			LinkTTL: time.Duration(cfg.StorageLinkTTLSeconds) * time.Second,
+		},
+	})
+	if err != nil {
+		logger.Error("Failed to initialize %s storage: %v", cfg.StorageBackend, err)
+	}
+
+	return &Service{
+		cfg:      cfg,
+		executor: executor,
+		uploader: uploader,
+		webhook:  webhook.NewClient(),
+		events:   events,
+	}
+}
+
+// Uploader returns the storage backend, or nil if it failed to initialize
+func (s *Service) Uploader() storage.Uploader {
+	return s.uploader
+}
+
+// Check fails if the options ask for an upload while the storage backend is not available, or for a bucket
+// that is not allowed
+func (s *Service) Check(opts models.DeliveryOptions) error {
+	if opts.Uploads() && s.uploader == nil {
+		return ErrNoStorage
+	}
+	return s.checkBucket(opts.Bucket)
+}
+
+// checkBucket fails for a bucket other than the configured one that is not listed in STORAGE_ALLOWED_BUCKETS
+func (s *Service) checkBucket(bucket string) error {
+	if bucket == "" || slices.Contains(s.cfg.StorageAllowedBuckets, bucket) {
+		return nil
+	}
+	if s.uploader != nil && bucket == s.uploader.Bucket() {
+		return nil
+	}
+	return fmt.Errorf("bucket %s is not listed in STORAGE_ALLOWED_BUCKETS", bucket)
+}
+
+// bucketUploader returns the uploader of a bucket: the configured one for an empty bucket
+func (s *Service) bucketUploader(bucket string) (storage.Uploader, error) {
+	if s.uploader == nil {
+		return nil, ErrNoStorage
+	}
+	if bucket == "" || bucket == s.uploader.Bucket() {
+		return s.uploader, nil
+	}
+	if err := s.checkBucket(bucket); err != nil {
+		return nil, err
+	}
+	return s.uploader.WithBucket(bucket)
+}
+
+// OutputPath returns the file in OUTPUT_DIR a job writes its output to, named by output_name or the job ID.
+// A named output is created empty to claim its name: with on_conflict rename, an existing file makes it
+// take the first free name with -1, -2, ... added, and with fail the job fails.
+func (s *Service) OutputPath(job *models.Job, opts models.OutputOptions) (string, error) {
+	outputPath := filepath.Join(s.cfg.OutputDir, opts.FileName(job.ID))
+	if opts.OutputName == "" || opts.OnConflict == models.ConflictOverwrite {
+		return outputPath, nil
+	}
+
+	ext := filepath.Ext(outputPath)
+	base := strings.TrimSuffix(outputPath, ext)
+	for i := 0; i <= maxOutputRenames; i++ {
+		path := outputPath
+		if i > 0 {
+			path = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			file.Close()
+			return path, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("failed to create output file: %w", err)
+		}
+		if opts.OnConflict == models.ConflictFail {
+			return "", fmt.Errorf("output file %s already exists", filepath.Base(outputPath))
+		}
+	}
+	return "", fmt.Errorf("output file %s and %d renamed copies already exist", filepath.Base(outputPath), maxOutputRenames)
+}
+
+// Store uploads a job output to the storage backend under the request's output_key or S3_KEY_TEMPLATE
+// and records its link on the job. With STORAGE_PRESIGN, and always for the local backend, the link is a
+// presigned URL that expires. progress may be nil.
+func (s *Service) Store(ctx context.Context, job *models.Job, outputPath string, storageOpts models.StorageOptions, progress func(uploaded, total int64)) (string, error) {
+	uploader, err := s.bucketUploader(storageOpts.Bucket)
+	if err != nil {
+		return "", err
+	}
+	template := storageOpts.OutputKey
+	if template == "" {
+		template = s.cfg.S3KeyTemplate
+	}
+	objectName, err := storage.ObjectKey(template, storage.KeyFields{
+		JobID:        job.ID,
+		APIKey:       job.CreatedBy,
+		OriginalName: storageOpts.OriginalName,
+		FilePath:     outputPath,
+		Time:         time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	cacheControl := storageOpts.CacheControl
+	if cacheControl == "" {
+		cacheControl = s.cfg.StorageCacheControl
+	}
+	upload := storage.UploadOptions{
+		CacheControl: cacheControl,
+		Metadata:     storageOpts.ObjectMetadata,
+		Progress:     progress,
+	}
+	uploadURL, err := uploader.Upload(ctx, outputPath, objectName, upload)
+	if err != nil {
+		return "", err
+	}
+
+	link, expires, err := s.shareLink(ctx, storageOpts.Bucket, objectName, uploadURL)
+	if err != nil {
+		return "", err
+	}
+	job.SetS3URL(link, storageOpts.Bucket, objectName, expires)
+
+	if s.sidecarsEnabled(storageOpts) {
+		s.storeSidecars(ctx, job, outputPath, storageOpts.Bucket, objectName, upload)
+	}
+	return link, nil
+}
+
+// Move uploads a job output like Store, then deletes the local file and clears the job's output path. A file
+// that cannot be deleted is logged and left alone.
+func (s *Service) Move(ctx context.Context, job *models.Job, outputPath string, storageOpts models.StorageOptions, progress func(uploaded, total int64)) (string, error) {
+	link, err := s.Store(ctx, job, outputPath, storageOpts, progress)
+	if err != nil {
+		return "", err
+	}
+
+	jobLog := logger.FromContext(ctx)
+	if err := os.Remove(outputPath); err != nil {
+		jobLog.Error("Failed to delete local file for job %s: %v", job.ID, err)
+	} else {
+		jobLog.Info("Deleted local file for job %s", job.ID)
+		job.SetOutput("")
+	}
+	return link, nil
+}
+
+// Upload moves the output of a job created with upload_to_s3 to the storage backend, taking the job from 90%
+// to 99%. It does nothing for other jobs.
+func (s *Service) Upload(ctx context.Context, job *models.Job, outputPath string) error {
+	if job.Upload == nil {
+		return nil
+	}
+	jobLog := logger.FromContext(ctx)
+	jobLog.Info("Uploading to %s for job %s", s.cfg.StorageBackend, job.ID)
+	link, err := s.Move(ctx, job, outputPath, *job.Upload, func(uploaded, total int64) {
+		if total > 0 {
+			job.UpdateProgress(90 + int(min(uploaded, total)*9/total))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	jobLog.Info("Uploaded to %s for job %s: %s", s.cfg.StorageBackend, job.ID, link)
+	return nil
+}
+
+// shareLink returns the link to hand out for an uploaded object and its expiry: the upload URL, or a
+// presigned URL with STORAGE_PRESIGN and always for the local backend
+func (s *Service) shareLink(ctx context.Context, bucket, objectName, uploadURL string) (string, time.Time, error) {
+	if s.cfg.StoragePresign || s.cfg.StorageBackend == storage.BackendLocal {
+		return s.Presign(ctx, bucket, objectName)
+	}
+	return uploadURL, time.Time{}, nil
+}
+
+// Presign returns a presigned URL of an object stored in bucket (empty for the configured one) valid for
+// STORAGE_LINK_TTL_SECONDS, and its expiry
+func (s *Service) Presign(ctx context.Context, bucket, objectName string) (string, time.Time, error) {
+	uploader, err := s.bucketUploader(bucket)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	ttl := time.Duration(s.cfg.StorageLinkTTLSeconds) * time.Second
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	link, err := uploader.Presign(ctx, objectName, ttl)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return link, expires, nil
+}
+
+// Notify sends the job's result to its webhook, if it has one, and publishes it to the job queue
+func (s *Service) Notify(ctx context.Context, job *models.Job) {
+	if job.WebhookURL == "" && s.events == nil {
+		return
+	}
+
+	status := job.GetStatus()
+	payload := webhook.JobCompletionPayload{
+		JobID:     job.ID,
+		Status:    string(status.Status),
+		S3URL:     status.S3URL,
+		Error:     status.Error,
+		RequestID: status.RequestID,
+		Labels:    status.Labels,
+	}
+	if status.S3URLExpiresAt != nil {
+		payload.S3URLExpiresAt = status.S3URLExpiresAt.Format(time.RFC3339)
+	}
+	if status.ExpiresAt != nil {
+		payload.ExpiresAt = status.ExpiresAt.Format(time.RFC3339)
+	}
+	payload.ThumbnailURL = status.ThumbnailURL
+	payload.MetadataURL = status.MetadataURL
+
+	s.publish(ctx, payload)
+	if job.WebhookURL != "" {
+		s.webhook.SendJobCompleteAsync(ctx, job.WebhookURL, job.WebhookHeaders, payload)
+	}
+}
+
+// Reject publishes a failed event without a job for a queued request that was not accepted, so that senders
+// that get no reply learn of it. requestID is the request_id of the request, if it has one.
+func (s *Service) Reject(ctx context.Context, requestID string, err error) {
+	s.publish(ctx, webhook.JobCompletionPayload{
+		Status:    string(models.JobStatusFailed),
+		Error:     err.Error(),
+		RequestID: requestID,
+	})
+}
+
+// publish publishes an event to the job queue, if there is one. Failures are logged; the event is not retried.
+func (s *Service) publish(ctx context.Context, payload webhook.JobCompletionPayload) {
+	if s.events == nil {
+		return
+	}
+	payload.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	event, err := sonic.Marshal(payload)
+	if err == nil {
+		err = s.events.Publish(context.WithoutCancel(ctx), event)
+	}
+	if err != nil {
+		logger.Error("Failed to publish event of job %s: %v", payload.JobID, err)
+	}
+}
@@ -0,0 +1,75 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"govid/internal/models"
+	"govid/pkg/logger"
+)
+
+// ExpireJobs expires the completed jobs of a store whose outputs are past their expires_at, deleting the
+// outputs and notifying their webhooks
+func (s *Service) ExpireJobs(ctx context.Context, jobStore *models.JobStore) {
+	now := time.Now()
+	for _, job := range jobStore.List() {
+		if !job.Due(now) {
+			continue
+		}
+		s.Expire(ctx, job)
+		if err := jobStore.Update(job); err != nil {
+			logger.Error("Failed to persist expired job %s: %v", job.ID, err)
+		}
+	}
+}
+
+// Expire marks a completed job as expired and deletes its outputs: the local file, and the object in the
+// storage backend with its sidecars. Files that cannot be deleted are logged and left for the cleanup.
+// The job's webhook is told that the job expired.
+func (s *Service) Expire(ctx context.Context, job *models.Job) {
+	status := job.GetStatus()
+	bucket, key := job.GetStorageKey()
+	if !job.Expire() {
+		return
+	}
+
+	jobLog := job.Logger()
+	if status.OutputPath != "" {
+		if err := os.Remove(status.OutputPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			jobLog.Error("Failed to delete expired output of job %s: %v", job.ID, err)
+		}
+	}
+	if key != "" {
+		s.deleteObjects(ctx, job, bucket, key, status)
+	}
+	jobLog.Info("Job %s expired", job.ID)
+
+	s.Notify(ctx, job)
+}
+
+// deleteObjects deletes the output of a job from the storage backend, with the sidecars it has links to
+func (s *Service) deleteObjects(ctx context.Context, job *models.Job, bucket, key string, status models.JobStatusResponse) {
+	jobLog := job.Logger()
+	uploader, err := s.bucketUploader(bucket)
+	if err != nil {
+		jobLog.Error("Failed to delete expired output of job %s from storage: %v", job.ID, err)
+		return
+	}
+
+	keys := []string{key}
+	thumbnailKey, metadataKey := SidecarKeys(key)
+	if status.ThumbnailURL != "" {
+		keys = append(keys, thumbnailKey)
+	}
+	if status.MetadataURL != "" {
+		keys = append(keys, metadataKey)
+	}
+	for _, objectName := range keys {
+		if err := uploader.Delete(ctx, objectName); err != nil {
+			jobLog.Error("Failed to delete %s of expired job %s from storage: %v", objectName, job.ID, err)
+		}
+	}
+}
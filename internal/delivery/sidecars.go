@@ -0,0 +1,123 @@
+package delivery
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+
+	"govid/internal/models"
+	"govid/pkg/logger"
+	"govid/pkg/storage"
+)
+
+// Suffixes of the sidecar objects, which replace the extension of the output's key
+const (
+	thumbnailSuffix = ".jpg"
+	metadataSuffix  = ".metadata.json"
+)
+
+// SidecarKeys returns the object keys of the thumbnail and metadata.json next to an output's key
+func SidecarKeys(objectName string) (thumbnailKey, metadataKey string) {
+	base := strings.TrimSuffix(objectName, filepath.Ext(objectName))
+	return base + thumbnailSuffix, base + metadataSuffix
+}
+
+// sidecarsEnabled reports whether a request wants sidecars, falling back to STORAGE_SIDECARS
+func (s *Service) sidecarsEnabled(opts models.StorageOptions) bool {
+	if opts.Sidecars != nil {
+		return *opts.Sidecars
+	}
+	return s.cfg.StorageSidecars
+}
+
+// storeSidecars generates a poster thumbnail and a metadata.json for an output and uploads them next to it,
+// recording their links on the job. Sidecars are extras: failures are logged and leave the job alone.
+func (s *Service) storeSidecars(ctx context.Context, job *models.Job, outputPath, bucket, objectName string, upload storage.UploadOptions) {
+	jobLog := logger.FromContext(ctx)
+	thumbnailKey, metadataKey := SidecarKeys(objectName)
+
+	meta, err := s.executor.DescribeMedia(ctx, outputPath)
+	if err != nil {
+		jobLog.Warn("Skipping sidecars for job %s: %v", job.ID, err)
+		return
+	}
+	meta.JobID = job.ID
+	meta.Key = objectName
+	meta.CreatedAt = time.Now().UTC()
+	if meta.SizeBytes, meta.SHA256, err = FileSHA256(outputPath); err != nil {
+		jobLog.Warn("Skipping sidecars for job %s: %v", job.ID, err)
+		return
+	}
+	meta.ContentType = storage.ContentType(outputPath)
+
+	upload.Progress = nil
+	upload.ContentType = ""
+	tempBase := filepath.Join(s.cfg.TempDir, uuid.New().String())
+
+	var thumbnailURL string
+	thumbnailPath := tempBase + thumbnailSuffix
+	defer os.Remove(thumbnailPath)
+	if meta.Width > 0 {
+		// A frame from the first second, or from the middle of shorter videos
+		if err := s.executor.ExtractThumbnail(ctx, outputPath, min(1, meta.Duration/2), thumbnailPath); err != nil {
+			jobLog.Warn("Failed to extract thumbnail for job %s: %v", job.ID, err)
+		} else if thumbnailURL, err = s.uploadSidecar(ctx, thumbnailPath, bucket, thumbnailKey, upload); err != nil {
+			jobLog.Warn("Failed to upload thumbnail for job %s: %v", job.ID, err)
+		}
+	}
+
+	var metadataURL string
+	metadataPath := tempBase + metadataSuffix
+	defer os.Remove(metadataPath)
+	content, err := sonic.MarshalIndent(meta, "", "  ")
+	if err == nil {
+		err = os.WriteFile(metadataPath, content, 0o644)
+	}
+	if err == nil {
+		metadataURL, err = s.uploadSidecar(ctx, metadataPath, bucket, metadataKey, upload)
+	}
+	if err != nil {
+		jobLog.Warn("Failed to upload metadata for job %s: %v", job.ID, err)
+	}
+
+	job.SetSidecarURLs(thumbnailURL, metadataURL)
+}
+
+// uploadSidecar uploads a sidecar file to bucket and returns its link
+func (s *Service) uploadSidecar(ctx context.Context, filePath, bucket, objectName string, upload storage.UploadOptions) (string, error) {
+	uploader, err := s.bucketUploader(bucket)
+	if err != nil {
+		return "", err
+	}
+	uploadURL, err := uploader.Upload(ctx, filePath, objectName, upload)
+	if err != nil {
+		return "", err
+	}
+	link, _, err := s.shareLink(ctx, bucket, objectName, uploadURL)
+	return link, err
+}
+
+// FileSHA256 returns the size and hex SHA-256 of a file
+func FileSHA256(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -3,32 +3,88 @@ package ffmpeg
 import (
 	"context"
 	"fmt"
+	"math"
+	"os"
+	"strings"
 
 	"govid/internal/models"
 
+	"github.com/bytedance/sonic"
+
 	ffmpeg "github.com/u2takey/ffmpeg-go"
 )
 
-// AddBackgroundMusic adds background music to a video with volume control and fade effects
-func (e *Executor) AddBackgroundMusic(ctx context.Context, videoPath string, audio models.AudioConfig, outputPath string) error {
+// Default EBU R128 targets used when a normalization request leaves them unset
+const (
+	defaultTargetLUFS = -16.0
+	defaultTruePeak   = -1.5
+	defaultLRA        = 11.0
+)
+
+// Default sidechaincompress settings used when ducking leaves them unset
+const (
+	defaultDuckThreshold = 0.05
+	defaultDuckRatio     = 8.0
+	defaultDuckAttack    = 20.0
+	defaultDuckRelease   = 300.0
+)
+
+// defaultLoopFadeOut is the fade out in seconds of looped music that leaves fade_out unset
+const defaultLoopFadeOut = 2.0
+
+// AddBackgroundMusic adds background music to a video with volume control, fade effects, and optional ducking.
+// The music replaces the audio of the video in replace mode.
+func (e *Executor) AddBackgroundMusic(ctx context.Context, videoPath string, audio models.AudioConfig, opts models.OutputOptions, outputPath string) error {
+	if audio.Mode == models.AudioModeReplace {
+		return e.ReplaceAudio(ctx, videoPath, audio, opts, outputPath)
+	}
+
 	// Validate files
-	if err := ValidateFile(videoPath); err != nil {
+	info, err := e.checkInput(ctx, videoPath, "video")
+	if err != nil {
 		return fmt.Errorf("video file: %w", err)
 	}
-	if err := ValidateFile(audio.FilePath); err != nil {
-		return fmt.Errorf("audio file: %w", err)
+	if err := e.checkMusic(ctx, audio); err != nil {
+		return err
+	}
+	if err := e.checkInterpolationLength(ctx, opts, pathSegments(videoPath)); err != nil {
+		return err
 	}
+	ctx = e.withInputDuration(ctx, videoPath)
 
-	// Load video and audio
+	// Load video and mix the music with its audio, or with silence if it has none
 	videoStream := ffmpeg.Input(videoPath)
-	audioStream := ffmpeg.Input(audio.FilePath).Audio()
+	originalAudio := videoStream.Audio()
+	if info.AudioStream() == nil {
+		originalAudio = silentAudio(info.Duration)
+	}
+	mixedAudio := mixMusic(originalAudio, audio, info.Duration)
 
-	// Apply audio filters
-	audioStream = applyAudioFilters(audioStream, audio)
+	// Output with video and mixed audio
+	output := ffmpeg.Output(
+		[]*ffmpeg.Stream{filterVideo(videoStream.Video(), opts), mixedAudio},
+		outputPath,
+		ffmpeg.MergeKwArgs([]ffmpeg.KwArgs{copyVideoKwArgs(outputPath, opts), audioEncodeKwArgs(outputPath), metadataKwArgs(opts)}),
+	).OverWriteOutput()
+
+	return e.runStream(ctx, output)
+}
+
+// mixMusic mixes the music of audio, trimmed, faded and at its volume, into an audio stream of duration
+// seconds (0 if unknown), ducking it under the original audio if requested
+func mixMusic(originalAudio *ffmpeg.Stream, audio models.AudioConfig, duration float64) *ffmpeg.Stream {
+	audioStream := applyAudioFilters(musicInput(audio), audio, duration)
+
+	// Duck the music under the original audio if requested
+	if audio.Duck != nil {
+		split := originalAudio.ASplit()
+		originalAudio = split.Get("0")
+		audioStream = duckAudio(audioStream, split.Get("1"), *audio.Duck)
+	}
 
 	// Mix with original video audio
-	mixedAudio := ffmpeg.Filter(
-		[]*ffmpeg.Stream{videoStream.Audio(), audioStream},
+	return ffmpeg.Filter(
+		[]*ffmpeg.Stream{originalAudio, audioStream},
 		"amix",
 		ffmpeg.Args{},
 		ffmpeg.KwArgs{
@@ -37,23 +93,99 @@ func (e *Executor) AddBackgroundMusic(ctx context.Context, videoPath string, aud
 			"dropout_transition": 2,
 		},
 	)
+}
 
-	// Output with video and mixed audio
-	output := ffmpeg.Output(
-		[]*ffmpeg.Stream{videoStream.Video(), mixedAudio},
-		outputPath,
+// replaceMusic returns the music of audio, trimmed, faded and at its volume, padded with silence and cut to
+// duration seconds so that it lasts as long as the video. It is left as is when duration is unknown.
+func replaceMusic(audio models.AudioConfig, duration float64) *ffmpeg.Stream {
+	audioStream := applyAudioFilters(musicInput(audio), audio, duration)
+	if duration <= 0 {
+		return audioStream
+	}
+	return audioStream.Filter("apad", ffmpeg.Args{}).
+		Filter("atrim", ffmpeg.Args{}, ffmpeg.KwArgs{"end": fmt.Sprintf("%.3f", duration)})
+}
+
+// checkMusic checks the music files of audio
+func (e *Executor) checkMusic(ctx context.Context, audio models.AudioConfig) error {
+	if _, err := e.checkInput(ctx, audio.FilePath, "audio"); err != nil {
+		return fmt.Errorf("audio file: %w", err)
+	}
+	for i, track := range audio.Playlist {
+		if _, err := e.checkInput(ctx, track, "audio"); err != nil {
+			return fmt.Errorf("audio playlist %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// musicInput reads the music of audio: its file, or the tracks of its playlist one after another, each
+// crossfading into the next or, without a crossfade, cut to the next
+func musicInput(audio models.AudioConfig) *ffmpeg.Stream {
+	if len(audio.Playlist) == 0 {
+		return ffmpeg.Input(audio.FilePath).Audio()
+	}
+
+	// Tracks of different sample rates and layouts are joined at those of concatenated segments
+	tracks := make([]*ffmpeg.Stream, 0, len(audio.Playlist)+1)
+	for _, track := range audio.Tracks() {
+		tracks = append(tracks, ffmpeg.Input(track).Audio().Filter("aformat", ffmpeg.Args{}, ffmpeg.KwArgs{
+			"sample_rates":    concatSampleRate,
+			"channel_layouts": "stereo",
+		}))
+	}
+	crossfade := audio.CrossfadeSeconds()
+	if crossfade == 0 {
+		return ffmpeg.Filter(tracks, "concat", ffmpeg.Args{}, ffmpeg.KwArgs{"n": len(tracks), "v": 0, "a": 1})
+	}
+	stream := tracks[0]
+	for _, next := range tracks[1:] {
+		stream = ffmpeg.Filter([]*ffmpeg.Stream{stream, next}, "acrossfade", ffmpeg.Args{}, ffmpeg.KwArgs{
+			"d":  crossfade,
+			"c1": "tri",
+			"c2": "tri",
+		})
+	}
+	return stream
+}
+
+// duckAudio compresses the music stream whenever the sidechain (original audio) is active
+func duckAudio(music, sidechain *ffmpeg.Stream, duck models.DuckingConfig) *ffmpeg.Stream {
+	threshold, ratio, attack, release := defaultDuckThreshold, defaultDuckRatio, defaultDuckAttack, defaultDuckRelease
+	if duck.Threshold != nil {
+		threshold = *duck.Threshold
+	}
+	if duck.Ratio != nil {
+		ratio = *duck.Ratio
+	}
+	if duck.Attack != nil {
+		attack = *duck.Attack
+	}
+	if duck.Release != nil {
+		release = *duck.Release
+	}
+
+	return ffmpeg.Filter(
+		[]*ffmpeg.Stream{music, sidechain},
+		"sidechaincompress",
+		ffmpeg.Args{},
 		ffmpeg.KwArgs{
-			"c:v": "copy",
-			"c:a": "aac",
-			"b:a": "192k",
+			"threshold": threshold,
+			"ratio":     ratio,
+			"attack":    attack,
+			"release":   release,
 		},
-	).OverWriteOutput()
-
-	return output.Run()
+	)
 }
 
-// applyAudioFilters applies trim, fade, and volume filters to audio stream
-func applyAudioFilters(audioStream *ffmpeg.Stream, audio models.AudioConfig) *ffmpeg.Stream {
+// applyAudioFilters applies trim, loop, fade, volume, and offset filters to audio stream. Looped music fades
+// out at the end of the video, duration seconds long; it is not faded out when that is unknown.
+func applyAudioFilters(audioStream *ffmpeg.Stream, audio models.AudioConfig, duration float64) *ffmpeg.Stream {
+	offset := 0.0
+	if audio.Offset != nil {
+		offset = *audio.Offset
+	}
+
 	// Apply trim filter if specified
 	if audio.StartTime != nil || audio.EndTime != nil {
 		trimKwArgs := ffmpeg.KwArgs{}
@@ -67,6 +199,12 @@ func applyAudioFilters(audioStream *ffmpeg.Stream, audio models.AudioConfig) *ff
 		audioStream = audioStream.Filter("asetpts", ffmpeg.Args{"PTS-STARTPTS"})
 	}
 
+	// Repeat the trimmed music endlessly; the mix or -shortest ends it with the video
+	if audio.Loop {
+		audioStream = audioStream.Filter("aloop", ffmpeg.Args{}, ffmpeg.KwArgs{"loop": -1, "size": math.MaxInt32}).
+			Filter("asetpts", ffmpeg.Args{"N/SR/TB"})
+	}
+
 	// Add fade in effect
 	if audio.FadeIn != nil && *audio.FadeIn > 0 {
 		audioStream = audioStream.Filter("afade", ffmpeg.Args{}, ffmpeg.KwArgs{
@@ -77,15 +215,27 @@ func applyAudioFilters(audioStream *ffmpeg.Stream, audio models.AudioConfig) *ff
 	}
 
 	// Add fade out effect
-	if audio.FadeOut != nil && *audio.FadeOut > 0 {
+	fadeOut := 0.0
+	if audio.FadeOut != nil {
+		fadeOut = *audio.FadeOut
+	} else if audio.Loop {
+		fadeOut = defaultLoopFadeOut
+	}
+	if audio.Loop && duration > 0 && fadeOut > 0 {
+		audioStream = audioStream.Filter("afade", ffmpeg.Args{}, ffmpeg.KwArgs{
+			"t":  "out",
+			"st": fmt.Sprintf("%.3f", max(duration-offset-fadeOut, 0)),
+			"d":  fadeOut,
+		})
+	} else if !audio.Loop && fadeOut > 0 {
 		fadeKwArgs := ffmpeg.KwArgs{
 			"t": "out",
-			"d": *audio.FadeOut,
+			"d": fadeOut,
 		}
 
 		// Calculate fade out start time if we have end time
 		if audio.EndTime != nil && audio.StartTime != nil {
-			fadeOutStart := *audio.EndTime - *audio.StartTime - *audio.FadeOut
+			fadeOutStart := *audio.EndTime - *audio.StartTime - fadeOut
 			if fadeOutStart > 0 {
 				fadeKwArgs["st"] = fadeOutStart
 			}
@@ -97,85 +247,232 @@ func applyAudioFilters(audioStream *ffmpeg.Stream, audio models.AudioConfig) *ff
 	// Add volume control
 	audioStream = audioStream.Filter("volume", ffmpeg.Args{fmt.Sprintf("%.2f", audio.Volume)})
 
+	// Start the music later in the video, preceded by silence
+	if offset > 0 {
+		audioStream = audioStream.Filter("adelay", ffmpeg.Args{}, ffmpeg.KwArgs{
+			"delays": fmt.Sprintf("%.0f", offset*1000),
+			"all":    1,
+		})
+	}
+
 	return audioStream
 }
 
 // ReplaceAudio replaces video audio completely with background music (no mixing)
-func (e *Executor) ReplaceAudio(ctx context.Context, videoPath string, audio models.AudioConfig, outputPath string) error {
+func (e *Executor) ReplaceAudio(ctx context.Context, videoPath string, audio models.AudioConfig, opts models.OutputOptions, outputPath string) error {
 	// Validate files
-	if err := ValidateFile(videoPath); err != nil {
+	info, err := e.checkInput(ctx, videoPath, "video")
+	if err != nil {
 		return fmt.Errorf("video file: %w", err)
 	}
-	if err := ValidateFile(audio.FilePath); err != nil {
-		return fmt.Errorf("audio file: %w", err)
+	if err := e.checkMusic(ctx, audio); err != nil {
+		return err
 	}
+	if err := e.checkInterpolationLength(ctx, opts, pathSegments(videoPath)); err != nil {
+		return err
+	}
+	ctx = e.withInputDuration(ctx, videoPath)
 
 	// Load video and audio
-	videoStream := ffmpeg.Input(videoPath).Video()
-	audioStream := ffmpeg.Input(audio.FilePath).Audio()
-
-	// Apply audio filters
-	audioStream = applyAudioFilters(audioStream, audio)
+	videoStream := filterVideo(ffmpeg.Input(videoPath).Video(), opts)
+	audioStream := replaceMusic(audio, info.Duration)
 
 	// Output with video and replacement audio
 	output := ffmpeg.Output(
 		[]*ffmpeg.Stream{videoStream, audioStream},
 		outputPath,
-		ffmpeg.KwArgs{
-			"c:v":      "copy",
-			"c:a":      "aac",
-			"b:a":      "192k",
-			"shortest": nil, // Use shortest input duration
-		},
+		ffmpeg.MergeKwArgs([]ffmpeg.KwArgs{
+			copyVideoKwArgs(outputPath, opts),
+			audioEncodeKwArgs(outputPath),
+			metadataKwArgs(opts),
+			{"shortest": nil}, // Use shortest input duration
+		}),
 	).OverWriteOutput()
 
-	return output.Run()
+	return e.runStream(ctx, output)
 }
 
-// CompleteProcess performs complete video processing with merge, overlay, and audio
-func (e *Executor) CompleteProcess(ctx context.Context, req models.CompleteProcessRequest, outputPath string) error {
-	// For simplicity, we'll process in stages using temp files
-	// In production, you might want to combine everything into one filter_complex
-
-	// Stage 1: Merge videos if multiple segments
-	var currentVideo string
-	switch {
-	case len(req.Segments) > 1:
-		tempMerged := outputPath + ".merged.mp4"
-		if err := e.MergeVideos(ctx, req.Segments, tempMerged); err != nil {
-			return fmt.Errorf("merge videos: %w", err)
+// loudnessMeasurement holds the values reported by the loudnorm analysis pass
+type loudnessMeasurement struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// NormalizeLoudness normalizes the audio track of a video to an EBU R128 loudness target.
+// The video stream is copied; only the audio is re-encoded.
+func (e *Executor) NormalizeLoudness(ctx context.Context, inputPath string, norm models.LoudnessNormalization, opts models.OutputOptions, outputPath string) error {
+	if _, err := e.checkInput(ctx, inputPath, "audio"); err != nil {
+		return fmt.Errorf("input file: %w", err)
+	}
+	if err := norm.Validate(); err != nil {
+		return err
+	}
+	ctx = e.withInputDuration(ctx, inputPath)
+
+	var measured *loudnessMeasurement
+	if norm.TwoPass {
+		m, err := e.measureLoudness(ctx, inputPath, norm)
+		if err != nil {
+			return fmt.Errorf("loudness analysis: %w", err)
 		}
-		currentVideo = tempMerged
-	case len(req.Segments) == 1:
-		currentVideo = req.Segments[0].FilePath
-	default:
-		return fmt.Errorf("at least one video segment required")
+		measured = m
 	}
 
-	// Stage 2: Add overlays if specified
-	if len(req.Overlays) > 0 {
-		tempOverlay := outputPath + ".overlay.mp4"
-		if err := e.AddMultipleOverlays(ctx, currentVideo, req.Overlays, tempOverlay); err != nil {
-			return fmt.Errorf("add overlays: %w", err)
+	args := []string{
+		"-y",
+		"-i", inputPath,
+		"-map", "0:v?",
+		"-map", "0:a",
+		"-af", loudnormFilter(norm, measured),
+		"-ar", "48000",
+	}
+	args = append(args, ffmpeg.ConvertKwargsToCmdLineArgs(copyVideoKwArgs(outputPath, opts))...)
+	args = append(args, ffmpeg.ConvertKwargsToCmdLineArgs(audioEncodeKwArgs(outputPath))...)
+	args = append(args, ffmpeg.ConvertKwargsToCmdLineArgs(metadataKwArgs(opts))...)
+	args = append(args, outputPath)
+
+	return e.Execute(ctx, args)
+}
+
+// measureLoudness runs the first loudnorm pass and parses the measured values from stderr
+func (e *Executor) measureLoudness(ctx context.Context, inputPath string, norm models.LoudnessNormalization) (*loudnessMeasurement, error) {
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", inputPath,
+		"-vn",
+		"-af", loudnormFilter(norm, nil) + ":print_format=json",
+		"-f", "null",
+		"-",
+	}
+
+	stderr, err := e.ExecuteWithOutput(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// loudnorm prints its JSON report as the last block of the output
+	start := strings.LastIndex(stderr, "{")
+	end := strings.LastIndex(stderr, "}")
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("loudnorm report not found in ffmpeg output")
+	}
+
+	var m loudnessMeasurement
+	if err := sonic.UnmarshalString(stderr[start:end+1], &m); err != nil {
+		return nil, fmt.Errorf("failed to parse loudnorm report: %w", err)
+	}
+
+	return &m, nil
+}
+
+// loudnormFilter builds the loudnorm filter string, including measured values for the second pass
+func loudnormFilter(norm models.LoudnessNormalization, measured *loudnessMeasurement) string {
+	return "loudnorm=" + loudnormParams(norm, measured)
+}
+
+// loudnormParams builds the options of the loudnorm filter
+func loudnormParams(norm models.LoudnessNormalization, measured *loudnessMeasurement) string {
+	target, truePeak, lra := defaultTargetLUFS, defaultTruePeak, defaultLRA
+	if norm.TargetLUFS != nil {
+		target = *norm.TargetLUFS
+	}
+	if norm.TruePeak != nil {
+		truePeak = *norm.TruePeak
+	}
+	if norm.LRA != nil {
+		lra = *norm.LRA
+	}
+
+	params := fmt.Sprintf("I=%.1f:TP=%.1f:LRA=%.1f", target, truePeak, lra)
+	if measured != nil {
+		params += fmt.Sprintf(":measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true",
+			measured.InputI, measured.InputTP, measured.InputLRA, measured.InputThresh, measured.TargetOffset)
+	}
+	return params
+}
+
+// WithLoudnessNormalization runs processFn and, when norm is set, normalizes its result into outputPath
+func (e *Executor) WithLoudnessNormalization(ctx context.Context, norm *models.LoudnessNormalization, opts models.OutputOptions, outputPath string, processFn func(string) error) error {
+	if norm == nil {
+		return processFn(outputPath)
+	}
+
+	tempOutput := outputPath + ".prenorm.mp4"
+	defer os.Remove(tempOutput)
+
+	if err := processFn(tempOutput); err != nil {
+		return err
+	}
+
+	// processFn has already resized and retimed the video
+	if err := e.NormalizeLoudness(ctx, tempOutput, *norm, withoutVideoFilters(opts), outputPath); err != nil {
+		return fmt.Errorf("normalize audio: %w", err)
+	}
+
+	return nil
+}
+
+// CompleteProcess merges the segments, lays the overlays over them and mixes in the music in a single encode.
+// Single-pass loudness normalization is part of that encode; two-pass normalization measures its result and
+// corrects the audio in a second pass that copies the video.
+func (e *Executor) CompleteProcess(ctx context.Context, req models.CompleteProcessRequest, outputPath string) error {
+	if norm := req.NormalizeAudio; norm != nil && !norm.TwoPass {
+		if err := norm.Validate(); err != nil {
+			return err
 		}
-		currentVideo = tempOverlay
+		return e.completeProcess(ctx, req, outputPath, loudnessStage(*norm))
 	}
+	return e.WithLoudnessNormalization(ctx, req.NormalizeAudio, req.OutputOptions, outputPath, func(target string) error {
+		return e.completeProcess(ctx, req, target)
+	})
+}
 
-	// Stage 3: Add audio if specified
+// completeProcess builds the graph of the complete process, ending with the extra stages, and encodes it
+func (e *Executor) completeProcess(ctx context.Context, req models.CompleteProcessRequest, outputPath string, extra ...graphStage) error {
+	if len(req.Segments) == 0 {
+		return fmt.Errorf("at least one video segment required")
+	}
+	sources, err := e.overlaySources(ctx, req.Overlays)
+	if err != nil {
+		return err
+	}
 	if req.Audio != nil {
-		if err := e.AddBackgroundMusic(ctx, currentVideo, *req.Audio, outputPath); err != nil {
-			return fmt.Errorf("add audio: %w", err)
+		if err := e.checkMusic(ctx, *req.Audio); err != nil {
+			return err
 		}
-	} else {
-		// Just copy the current video to output
-		output := ffmpeg.Input(currentVideo).Output(outputPath, ffmpeg.KwArgs{
-			"c": "copy",
-		}).OverWriteOutput()
-
-		if err := output.Run(); err != nil {
-			return fmt.Errorf("copy video: %w", err)
+	}
+
+	// A single whole segment with nothing to add is copied rather than re-encoded
+	opts := req.OutputOptions
+	if seg := req.Segments[0]; len(req.Segments) == 1 && seg.StartTime == 0 && seg.EndTime == 0 &&
+		len(req.Overlays) == 0 && req.Audio == nil && len(extra) == 0 && !hasVideoFilters(opts) {
+		if _, err := e.checkInput(ctx, seg.FilePath, "video"); err != nil {
+			return fmt.Errorf("segment 0: %w", err)
 		}
+		output := ffmpeg.Input(seg.FilePath).Output(outputPath, ffmpeg.MergeKwArgs([]ffmpeg.KwArgs{
+			copyVideoKwArgs(outputPath, opts),
+			copyAudioKwArgs(outputPath),
+			metadataKwArgs(opts),
+		})).OverWriteOutput()
+		return e.runStream(e.withInputDuration(ctx, seg.FilePath), output)
 	}
 
-	return nil
+	g, err := e.segmentGraph(ctx, req.Segments, opts)
+	if err != nil {
+		return err
+	}
+	var stages []graphStage
+	if len(req.Overlays) > 0 {
+		stages = append(stages, overlayStage(sources))
+	}
+	if req.Audio != nil {
+		stages = append(stages, musicStage(*req.Audio))
+	}
+	g.apply(append(stages, extra...)...)
+
+	return e.runGraph(ctx, g, outputPath, encodeKwArgs(outputPath, opts))
 }
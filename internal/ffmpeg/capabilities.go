@@ -0,0 +1,186 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"govid/internal/models"
+)
+
+// detectTimeout bounds each of the commands that detect the capabilities of the FFmpeg binary
+const detectTimeout = 10 * time.Second
+
+// requiredEncoders are the encoders of the default mp4, mkv and mov outputs
+var requiredEncoders = []string{"libx264", "aac"}
+
+// optionalEncoders are the encoders of the features that are turned off when the binary lacks them
+var optionalEncoders = map[string]string{
+	"libvpx-vp9": "webm output",
+	"libopus":    "webm output",
+}
+
+// optionalFilters are the filters of the features that are turned off when the binary lacks them
+var optionalFilters = map[string]string{
+	"drawtext":  "text overlays",
+	"subtitles": "burned-in subtitles",
+	"libvmaf":   "VMAF scores",
+}
+
+// requiredFilters are the filters the operations build their filter graphs from
+var requiredFilters = []string{
+	"acrossfade", "adelay", "afade", "aformat", "aloop", "amix", "anullsrc", "apad", "asetpts", "astats", "atrim",
+	"blackdetect", "boxblur", "colorchannelmixer", "concat", "crop", "drawbox", "ebur128", "fade", "format", "fps",
+	"freezedetect", "loudnorm", "minterpolate", "overlay", "pad", "psnr", "rotate", "scale", "setpts", "setsar",
+	"silencedetect", "split", "ssim", "tpad", "trim", "volume", "zoompan",
+}
+
+// DetectCapabilities runs the FFmpeg binary to find its version and which of the encoders and filters GoVid
+// uses it has, and keeps the result for Capabilities. It fails if a required encoder or filter is missing, so
+// that a misconfigured installation is found at startup rather than by the first job. Features whose optional
+// encoders or filters are missing are turned off: commands using them fail with an error naming them.
+func (e *Executor) DetectCapabilities(ctx context.Context) (*models.FFmpegCapabilities, error) {
+	version, err := e.detect(ctx, "-version")
+	if err != nil {
+		return nil, err
+	}
+	encoders, err := e.detect(ctx, "-hide_banner", "-encoders")
+	if err != nil {
+		return nil, err
+	}
+	filters, err := e.detect(ctx, "-hide_banner", "-filters")
+	if err != nil {
+		return nil, err
+	}
+
+	caps := &models.FFmpegCapabilities{
+		Version:  parseVersion(version),
+		Encoders: make(map[string]bool),
+		Filters:  make(map[string]bool),
+	}
+	available := parseEncoders(encoders)
+	for _, name := range slices.Concat(requiredEncoders, slices.Collect(maps.Keys(optionalEncoders))) {
+		caps.Encoders[name] = available[name]
+	}
+	available = parseFilters(filters)
+	for _, name := range slices.Concat(requiredFilters, slices.Collect(maps.Keys(optionalFilters))) {
+		caps.Filters[name] = available[name]
+	}
+
+	var missing []string
+	if names := slices.DeleteFunc(slices.Clone(requiredEncoders), func(name string) bool { return caps.Encoders[name] }); len(names) > 0 {
+		missing = append(missing, "encoders "+strings.Join(names, ", "))
+	}
+	if names := slices.DeleteFunc(slices.Clone(requiredFilters), func(name string) bool { return caps.Filters[name] }); len(names) > 0 {
+		missing = append(missing, "filters "+strings.Join(names, ", "))
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s lacks the required %s", e.binary, strings.Join(missing, "; "))
+	}
+
+	for name, feature := range optionalEncoders {
+		if !caps.Encoders[name] && !slices.Contains(caps.Disabled, feature) {
+			caps.Disabled = append(caps.Disabled, feature)
+		}
+	}
+	for name, feature := range optionalFilters {
+		if !caps.Filters[name] && !slices.Contains(caps.Disabled, feature) {
+			caps.Disabled = append(caps.Disabled, feature)
+		}
+	}
+	slices.Sort(caps.Disabled)
+
+	e.caps = caps
+	return caps, nil
+}
+
+// Capabilities returns the capabilities found by DetectCapabilities, or nil if they were not detected
+func (e *Executor) Capabilities() *models.FFmpegCapabilities {
+	return e.caps
+}
+
+// checkEncoders fails if args select an encoder that the binary was found to lack
+func (e *Executor) checkEncoders(args []string) error {
+	if e.caps == nil {
+		return nil
+	}
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "-c:v", "-c:a", "-vcodec", "-acodec":
+			name := args[i+1]
+			if available, known := e.caps.Encoders[name]; known && !available {
+				return fmt.Errorf("%s is unavailable: %s lacks the %s encoder", optionalEncoders[name], e.binary, name)
+			}
+		}
+	}
+	return nil
+}
+
+// checkFilter fails if the binary was found to lack the optional filter name
+func (e *Executor) checkFilter(name string) error {
+	if e.caps == nil {
+		return nil
+	}
+	if available, known := e.caps.Filters[name]; known && !available {
+		return fmt.Errorf("%s are unavailable: %s lacks the %s filter", optionalFilters[name], e.binary, name)
+	}
+	return nil
+}
+
+// detect runs the FFmpeg binary with args and returns its output
+func (e *Executor) detect(ctx context.Context, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, detectTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, e.binary, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s %s: %w", e.binary, strings.Join(args, " "), err)
+	}
+	return string(output), nil
+}
+
+// parseVersion returns the version from the first line of ffmpeg -version, "ffmpeg version <version> ..."
+func parseVersion(output string) string {
+	line, _, _ := strings.Cut(output, "\n")
+	fields := strings.Fields(line)
+	if len(fields) >= 3 && fields[1] == "version" {
+		return fields[2]
+	}
+	return strings.TrimSpace(line)
+}
+
+// parseEncoders returns the names in the output of ffmpeg -encoders, which lists one encoder per line, as
+// flags followed by the name, after a legend that ends with a dashed line
+func parseEncoders(output string) map[string]bool {
+	names := make(map[string]bool)
+	listing := false
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 1 && strings.HasPrefix(fields[0], "---"):
+			listing = true
+		case listing && len(fields) >= 2:
+			names[fields[1]] = true
+		}
+	}
+	return names
+}
+
+// parseFilters returns the names in the output of ffmpeg -filters, which lists one filter per line, as flags,
+// the name and its inputs and outputs such as "V->V". The legend lines have no such column.
+func parseFilters(output string) map[string]bool {
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && strings.Contains(fields[2], "->") {
+			names[fields[1]] = true
+		}
+	}
+	return names
+}
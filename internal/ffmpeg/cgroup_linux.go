@@ -0,0 +1,123 @@
+//go:build linux
+
+package ffmpeg
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// cpuPeriod is the cpu.max period, in microseconds, that CPU limits are expressed in
+const cpuPeriod = 100000
+
+// prepareCgroupDir checks that dir is a cgroup v2 directory and enables the controllers the limits need
+// for its children. dir must not contain processes itself, such as the server's own.
+func prepareCgroupDir(dir string, l Limits) error {
+	content, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return fmt.Errorf("%s is not a cgroup v2 directory: %w", dir, err)
+	}
+	available := strings.Fields(string(content))
+
+	var enable []string
+	if l.MemoryMax > 0 {
+		enable = append(enable, "memory")
+	}
+	if l.CPUs > 0 {
+		enable = append(enable, "cpu")
+	}
+	for _, controller := range enable {
+		found := false
+		for _, name := range available {
+			found = found || name == controller
+		}
+		if !found {
+			return fmt.Errorf("the %s controller is not available in cgroup %s", controller, dir)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+"+controller), 0); err != nil {
+			return fmt.Errorf("failed to enable the %s controller in cgroup %s: %w", controller, dir, err)
+		}
+	}
+	return nil
+}
+
+// cgroup is the cgroup one ffmpeg process runs in
+type cgroup struct {
+	dir string
+	fd  *os.File
+}
+
+// newCgroup creates a cgroup under l.CgroupDir with the memory and CPU limits of l
+func newCgroup(l Limits) (*cgroup, error) {
+	dir, err := os.MkdirTemp(l.CgroupDir, "ffmpeg-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cgroup: %w", err)
+	}
+	c := &cgroup{dir: dir}
+
+	if l.MemoryMax > 0 {
+		if err := c.write("memory.max", strconv.FormatInt(l.MemoryMax, 10)); err != nil {
+			c.remove()
+			return nil, err
+		}
+		// Swapping would only slow the host down instead of stopping the process; fails without swap accounting
+		_ = c.write("memory.swap.max", "0")
+	}
+	if l.CPUs > 0 {
+		quota := max(int(l.CPUs*cpuPeriod), 1000)
+		if err := c.write("cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod)); err != nil {
+			c.remove()
+			return nil, err
+		}
+	}
+
+	c.fd, err = os.Open(dir)
+	if err != nil {
+		c.remove()
+		return nil, fmt.Errorf("failed to open cgroup: %w", err)
+	}
+	return c, nil
+}
+
+// apply starts cmd in the cgroup
+func (c *cgroup) apply(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{UseCgroupFD: true, CgroupFD: int(c.fd.Fd())}
+}
+
+// oomKilled reports whether a process in the cgroup was killed for exceeding the memory limit
+func (c *cgroup) oomKilled() bool {
+	file, err := os.Open(filepath.Join(c.dir, "memory.events"))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if count, ok := strings.CutPrefix(scanner.Text(), "oom_kill "); ok {
+			return count != "0"
+		}
+	}
+	return false
+}
+
+// remove deletes the cgroup once its process has exited
+func (c *cgroup) remove() {
+	if c.fd != nil {
+		c.fd.Close()
+	}
+	os.Remove(c.dir)
+}
+
+// write sets a control file of the cgroup
+func (c *cgroup) write(name, value string) error {
+	if err := os.WriteFile(filepath.Join(c.dir, name), []byte(value), 0); err != nil {
+		return fmt.Errorf("failed to set %s of cgroup %s: %w", name, c.dir, err)
+	}
+	return nil
+}
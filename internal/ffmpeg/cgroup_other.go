@@ -0,0 +1,26 @@
+//go:build !linux
+
+package ffmpeg
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errCgroupUnsupported = errors.New("FFmpeg memory and CPU limits need cgroups, which are only supported on Linux")
+
+func prepareCgroupDir(string, Limits) error {
+	return errCgroupUnsupported
+}
+
+type cgroup struct{}
+
+func newCgroup(Limits) (*cgroup, error) {
+	return nil, errCgroupUnsupported
+}
+
+func (c *cgroup) apply(*exec.Cmd) {}
+
+func (c *cgroup) oomKilled() bool { return false }
+
+func (c *cgroup) remove() {}
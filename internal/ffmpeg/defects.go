@@ -0,0 +1,138 @@
+package ffmpeg
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+
+	"govid/internal/models"
+)
+
+// Default blackdetect and freezedetect settings used when a request leaves them unset, those of FFmpeg
+const (
+	defaultBlackMinDuration  = 2.0
+	defaultBlackThreshold    = 0.1
+	defaultFreezeMinDuration = 2.0
+	defaultFreezeNoiseDB     = -60.0
+)
+
+var (
+	blackPattern       = regexp.MustCompile(`black_start:(\S+) black_end:(\S+)`)
+	freezeStartPattern = regexp.MustCompile(`freeze_start: (\S+)`)
+	freezeEndPattern   = regexp.MustCompile(`freeze_end: (\S+)`)
+)
+
+// DetectDefects reports the black and frozen ranges of a video, decoding it once, and its total duration
+func (e *Executor) DetectDefects(ctx context.Context, req models.DefectsRequest) (*models.DefectReport, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := e.checkInput(ctx, req.VideoPath, "video"); err != nil {
+		return nil, fmt.Errorf("video file: %w", err)
+	}
+	ctx = e.withInputDuration(ctx, req.VideoPath)
+
+	blackDuration, blackThreshold := defaultBlackMinDuration, defaultBlackThreshold
+	if req.BlackMinDuration != nil {
+		blackDuration = *req.BlackMinDuration
+	}
+	if req.BlackThreshold != nil {
+		blackThreshold = *req.BlackThreshold
+	}
+	freezeDuration, freezeNoise := defaultFreezeMinDuration, defaultFreezeNoiseDB
+	if req.FreezeMinDuration != nil {
+		freezeDuration = *req.FreezeMinDuration
+	}
+	if req.FreezeNoiseDB != nil {
+		freezeNoise = *req.FreezeNoiseDB
+	}
+
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", req.VideoPath,
+		"-an",
+		"-sn",
+		"-vf", fmt.Sprintf("blackdetect=d=%.3f:pix_th=%.3f,freezedetect=n=%.1fdB:d=%.3f", blackDuration, blackThreshold, freezeNoise, freezeDuration),
+		"-f", "null",
+		"-",
+	}
+
+	stderr, err := e.ExecuteWithOutput(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := parseDuration(stderr)
+	defects := make([]models.DefectRange, 0)
+	defects = append(defects, parseBlackRanges(stderr)...)
+	defects = append(defects, parseFreezeRanges(stderr, duration)...)
+	slices.SortStableFunc(defects, func(a, b models.DefectRange) int { return cmp.Compare(a.Start, b.Start) })
+	return &models.DefectReport{VideoPath: req.VideoPath, Duration: duration, Defects: defects}, nil
+}
+
+// ReportDefects writes the report of DetectDefects to outputPath as JSON. With fail_on_defects, a video with
+// defects fails instead, with an error listing them.
+func (e *Executor) ReportDefects(ctx context.Context, req models.DefectsRequest, outputPath string) error {
+	report, err := e.DetectDefects(ctx, req)
+	if err != nil {
+		return err
+	}
+	if req.FailOnDefects && len(report.Defects) > 0 {
+		ranges := make([]string, len(report.Defects))
+		for i, defect := range report.Defects {
+			ranges[i] = fmt.Sprintf("%s %.2f-%.2fs", defect.Type, defect.Start, defect.End)
+		}
+		return fmt.Errorf("video has %d defective ranges: %s", len(ranges), strings.Join(ranges, ", "))
+	}
+
+	content, err := sonic.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode defect report: %w", err)
+	}
+	if err := os.WriteFile(outputPath, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write defect report: %w", err)
+	}
+	return nil
+}
+
+// parseBlackRanges reads the "black_start:... black_end:... black_duration:..." lines of blackdetect, which
+// also closes a range still open at the end of the input
+func parseBlackRanges(stderr string) []models.DefectRange {
+	var ranges []models.DefectRange
+	for _, match := range blackPattern.FindAllStringSubmatch(stderr, -1) {
+		start, _ := strconv.ParseFloat(match[1], 64)
+		end, _ := strconv.ParseFloat(match[2], 64)
+		if end > start {
+			ranges = append(ranges, models.DefectRange{Type: models.DefectBlack, Start: start, End: end, Duration: end - start})
+		}
+	}
+	return ranges
+}
+
+// parseFreezeRanges pairs the freeze_start and freeze_end metadata lines of freezedetect. A range still open at
+// the end of the input is closed at duration.
+func parseFreezeRanges(stderr string, duration float64) []models.DefectRange {
+	starts := freezeStartPattern.FindAllStringSubmatch(stderr, -1)
+	ends := freezeEndPattern.FindAllStringSubmatch(stderr, -1)
+
+	var ranges []models.DefectRange
+	for i, match := range starts {
+		start, _ := strconv.ParseFloat(match[1], 64)
+		end := duration
+		if i < len(ends) {
+			end, _ = strconv.ParseFloat(ends[i][1], 64)
+		}
+		if end > start {
+			ranges = append(ranges, models.DefectRange{Type: models.DefectFreeze, Start: start, End: end, Duration: end - start})
+		}
+	}
+	return ranges
+}
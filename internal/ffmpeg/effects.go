@@ -0,0 +1,176 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+
+	"govid/internal/models"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// Default template effect settings. Text sizes are fractions of the frame height.
+const (
+	defaultEffectDuration = 5.0
+	defaultLogoWidth      = 320
+	defaultAccentColor    = "#1E88E5"
+	effectSlideSeconds    = 0.6  // lower third lines sliding in
+	effectFadeSeconds     = 0.5  // lower thirds fading out and end cards fading in
+	effectLineDelay       = 0.15 // subtitle following the title
+)
+
+// AddTemplateEffect draws a built-in lower third or end card over a video
+func (e *Executor) AddTemplateEffect(ctx context.Context, videoPath string, effect models.TemplateEffect, opts models.OutputOptions, outputPath string) error {
+	if err := e.checkFilter("drawtext"); err != nil {
+		return err
+	}
+	var logo *overlaySource
+	if effect.LogoPath != "" {
+		source, err := e.overlaySource(ctx, models.ImageOverlay{FilePath: effect.LogoPath})
+		if err != nil {
+			return fmt.Errorf("logo: %w", err)
+		}
+		logo = &source
+	}
+	g, err := e.segmentGraph(ctx, []models.VideoSegment{{FilePath: videoPath}}, opts)
+	if err != nil {
+		return err
+	}
+	g.apply(effectStage(effect, logo))
+	return e.runGraph(ctx, g, outputPath, encodeKwArgs(outputPath, opts))
+}
+
+// effectDuration returns how long an effect is shown, in seconds
+func effectDuration(effect models.TemplateEffect) float64 {
+	if effect.Duration > 0 {
+		return effect.Duration
+	}
+	return defaultEffectDuration
+}
+
+// drawLowerThird draws the title of a lower third on a box of the accent color, and its subtitle on a dark
+// box under it. The lines slide in from their side of the frame, the subtitle just after the title, and fade
+// out together.
+func drawLowerThird(stream *ffmpeg.Stream, effect models.TemplateEffect) *ffmpeg.Stream {
+	start := effect.StartTime
+	end := start + effectDuration(effect)
+	alpha := fmt.Sprintf("clip((%.2f-t)/%.2f,0,1)", end, effectFadeSeconds)
+
+	lines := []struct {
+		text, size, y, box string
+		border             int
+		delay              float64
+	}{
+		{effect.Title, "h/18", "h*0.72", effectAccentColor(effect), 16, 0},
+		{effect.Subtitle, "h/30", "h*0.72+h/18*1.7", "black@0.6", 10, effectLineDelay},
+	}
+	for _, line := range lines {
+		if line.text == "" {
+			continue
+		}
+		progress := ease(models.EasingOut, fmt.Sprintf("clip((t-%.2f)/%.2f,0,1)", start+line.delay, effectSlideSeconds))
+		// The box reaches border pixels beyond the text, so the line starts that far off the frame
+		x := fmt.Sprintf("-text_w-%[1]d+%[2]s*(w*0.05+text_w+%[1]d)", line.border, progress)
+		if effect.Position == models.PositionBottomRight {
+			x = fmt.Sprintf("w+%[1]d-%[2]s*(w*0.05+text_w+%[1]d)", line.border, progress)
+		}
+		stream = drawEffectText(stream, effect, line.text, ffmpeg.KwArgs{
+			"fontsize":   line.size,
+			"x":          x,
+			"y":          line.y,
+			"alpha":      alpha,
+			"box":        1,
+			"boxcolor":   line.box,
+			"boxborderw": line.border,
+			"enable":     fmt.Sprintf("between(t,%.2f,%.2f)", start, end),
+		})
+	}
+	return stream
+}
+
+// drawEndCard dims the end of a video and fades in a logo at the center of the frame, with the call to action
+// on a box of the accent color under it and the subtitle below. duration is the length of the video, or 0 if
+// unknown, in which case the end card starts with the video.
+func drawEndCard(stream *ffmpeg.Stream, effect models.TemplateEffect, logo *overlaySource, duration float64) *ffmpeg.Stream {
+	start := max(duration-effectDuration(effect), 0)
+	enable := fmt.Sprintf("gte(t,%.2f)", start)
+
+	stream = stream.Filter("drawbox", ffmpeg.Args{}, ffmpeg.KwArgs{
+		"x":      0,
+		"y":      0,
+		"w":      "iw",
+		"h":      "ih",
+		"color":  "black@0.6",
+		"t":      "fill",
+		"enable": enable,
+	})
+	if logo != nil {
+		width := defaultLogoWidth
+		if effect.LogoWidth > 0 {
+			width = effect.LogoWidth
+		}
+		fade := effectFadeSeconds
+		source := *logo
+		source.ImageOverlay = models.ImageOverlay{
+			FilePath:      effect.LogoPath,
+			Position:      models.PositionCenter,
+			StartTime:     start,
+			EndTime:       max(duration, start+effectDuration(effect)),
+			Animation:     models.AnimationFade,
+			FadeDuration:  &fade,
+			ExitAnimation: models.AnimationNone,
+			Width:         &width,
+		}
+		stream = layOverlay(stream, source)
+	}
+
+	lines := []struct {
+		text, size, y string
+		box           bool
+	}{
+		{effect.Title, "h/16", "h*0.78", true},
+		{effect.Subtitle, "h/28", "h*0.78+h/16*1.8", false},
+	}
+	for i, line := range lines {
+		if line.text == "" {
+			continue
+		}
+		kwArgs := ffmpeg.KwArgs{
+			"fontsize": line.size,
+			"x":        "(w-text_w)/2",
+			"y":        line.y,
+			"alpha":    fmt.Sprintf("clip((t-%.2f)/%.2f,0,1)", start+float64(i)*effectLineDelay, effectFadeSeconds),
+			"enable":   enable,
+		}
+		if line.box {
+			kwArgs["box"] = 1
+			kwArgs["boxcolor"] = effectAccentColor(effect)
+			kwArgs["boxborderw"] = 20
+		}
+		stream = drawEffectText(stream, effect, line.text, kwArgs)
+	}
+	return stream
+}
+
+// drawEffectText draws a line of an effect with drawtext, in its font and color, adding the layout in kwArgs
+func drawEffectText(stream *ffmpeg.Stream, effect models.TemplateEffect, text string, kwArgs ffmpeg.KwArgs) *ffmpeg.Stream {
+	fontColor := defaultFontColor
+	if effect.FontColor != "" {
+		fontColor = effect.FontColor
+	}
+	kwArgs["text"] = escapeOptionValue(text)
+	kwArgs["expansion"] = "none"
+	kwArgs["fontcolor"] = fontColor
+	if effect.FontFile != "" {
+		kwArgs["fontfile"] = escapeOptionValue(effect.FontFile)
+	}
+	return stream.Filter("drawtext", ffmpeg.Args{}, kwArgs)
+}
+
+// effectAccentColor returns the color of the box behind the title of an effect
+func effectAccentColor(effect models.TemplateEffect) string {
+	if effect.AccentColor != "" {
+		return effect.AccentColor
+	}
+	return defaultAccentColor
+}
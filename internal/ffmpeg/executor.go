@@ -9,66 +9,160 @@ import (
 	"strings"
 	"time"
 
+	"govid/internal/models"
 	"govid/pkg/logger"
+	"govid/pkg/tracing"
 
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/sync/semaphore"
 )
 
 // Executor handles FFmpeg command execution
 type Executor struct {
-	binary  string
-	timeout time.Duration
-	sem     *semaphore.Weighted
+	binary                 string
+	probeBinary            string
+	timeout                time.Duration
+	maxInterpolateDuration float64
+	limits                 Limits
+	inputLimits            InputLimits
+	sem                    *semaphore.Weighted
+	slots                  int
+	queue                  *queue
+	caps                   *models.FFmpegCapabilities
 }
 
-// NewExecutor creates a new FFmpeg executor
-func NewExecutor(binary string, timeout time.Duration, maxConcurrent int64) *Executor {
-	return &Executor{
-		binary:  binary,
-		timeout: timeout,
-		sem:     semaphore.NewWeighted(maxConcurrent),
+// Config holds the settings for an Executor
+type Config struct {
+	Binary                 string        // ffmpeg binary
+	ProbeBinary            string        // ffprobe binary
+	Timeout                time.Duration // per-command timeout
+	MaxConcurrent          int64         // maximum concurrent ffmpeg commands
+	MaxInterpolateDuration float64       // maximum input length in seconds for motion interpolation (0 disables the limit)
+	Limits                 Limits        // resource limits of each ffmpeg process
+	InputLimits            InputLimits   // caps on the input videos of a request
+}
+
+// NewExecutor creates a new FFmpeg executor. It fails if the resource limits cannot be applied on this host.
+func NewExecutor(cfg Config) (*Executor, error) {
+	if err := checkLimitsTools(cfg.Limits); err != nil {
+		return nil, err
 	}
+	if cfg.Limits.usesCgroup() {
+		if err := prepareCgroupDir(cfg.Limits.CgroupDir, cfg.Limits); err != nil {
+			return nil, err
+		}
+	}
+	return &Executor{
+		binary:                 cfg.Binary,
+		probeBinary:            cfg.ProbeBinary,
+		timeout:                cfg.Timeout,
+		maxInterpolateDuration: cfg.MaxInterpolateDuration,
+		limits:                 cfg.Limits,
+		inputLimits:            cfg.InputLimits,
+		sem:                    semaphore.NewWeighted(cfg.MaxConcurrent),
+		slots:                  int(cfg.MaxConcurrent),
+		queue:                  &queue{},
+	}, nil
 }
 
 // Execute runs an FFmpeg command
 func (e *Executor) Execute(ctx context.Context, args []string) error {
-	// Acquire semaphore slot
-	if err := e.sem.Acquire(ctx, 1); err != nil {
-		return fmt.Errorf("failed to acquire ffmpeg slot: %w", err)
+	_, err := e.ExecuteWithOutput(ctx, args)
+	return err
+}
+
+// ExecuteWithOutput runs an FFmpeg command and returns its stderr output.
+// FFmpeg writes analysis filter results (loudnorm, silencedetect, ...) to stderr.
+func (e *Executor) ExecuteWithOutput(ctx context.Context, args []string) (output string, err error) {
+	ctx, span := tracing.Start(ctx, "ffmpeg.execute", attribute.StringSlice("ffmpeg.args", args))
+	defer func() { tracing.End(span, err) }()
+
+	if err := e.checkEncoders(args); err != nil {
+		return "", err
+	}
+
+	// Acquire semaphore slot; waiters get one in the order they asked
+	jobID := tracing.JobID(ctx)
+	e.queue.push(jobID)
+	err = e.sem.Acquire(ctx, 1)
+	e.queue.remove(jobID)
+	if err != nil {
+		return "", fmt.Errorf("failed to acquire ffmpeg slot: %w", err)
 	}
 	defer e.sem.Release(1)
+	if started, ok := ctx.Value(startKey{}).(func()); ok {
+		started()
+	}
 
 	// Create context with timeout
 	cmdCtx, cancel := context.WithTimeout(ctx, e.timeout)
 	defer cancel()
 
 	// Build command
-	cmd := exec.CommandContext(cmdCtx, e.binary, args...)
+	progress := progressWriterFor(ctx)
+	if progress != nil {
+		args = append([]string{"-progress", "pipe:1"}, args...)
+	}
+	binary, cmdArgs := e.limits.command(e.binary, args)
+	cmd := exec.CommandContext(cmdCtx, binary, cmdArgs...)
+	// Don't wait for processes that inherited the output pipes once ffmpeg is killed
+	cmd.WaitDelay = 5 * time.Second
+	if e.limits.usesCgroup() {
+		group, cgroupErr := newCgroup(e.limits)
+		if cgroupErr != nil {
+			return "", cgroupErr
+		}
+		defer group.remove()
+		group.apply(cmd)
+		defer func() {
+			if err != nil && group.oomKilled() {
+				err = fmt.Errorf("ffmpeg exceeded its memory limit of %d MB: %w", e.limits.MemoryMax>>20, err)
+			}
+		}()
+	}
 
 	// Capture output
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	if progress != nil {
+		cmd.Stdout = progress
+	}
 
 	// Log command
-	logger.Info("Executing FFmpeg command: %s %s", e.binary, strings.Join(args, " "))
+	logger.FromContext(ctx).Info("Executing FFmpeg command: %s %s", binary, strings.Join(cmdArgs, " "))
 
 	// Execute command
-	err := cmd.Run()
+	err = cmd.Run()
+	writeCommandLog(ctx, cmd.Args, stderr.String(), err)
 
 	// Log output
 	if stdout.Len() > 0 {
-		logger.Debug("FFmpeg stdout: %s", stdout.String())
+		logger.FromContext(ctx).Debug("FFmpeg stdout: %s", stdout.String())
 	}
 	if stderr.Len() > 0 {
-		logger.Debug("FFmpeg stderr: %s", stderr.String())
+		logger.FromContext(ctx).Debug("FFmpeg stderr: %s", stderr.String())
 	}
 
-	if err != nil {
-		return fmt.Errorf("ffmpeg execution failed: %w (stderr: %s)", err, stderr.String())
+	switch {
+	case err == nil:
+		return stderr.String(), nil
+	case ctx.Err() != nil:
+		// Cancelled, or the job timed out
+		return stderr.String(), fmt.Errorf("ffmpeg stopped: %w", ctx.Err())
+	case cmdCtx.Err() != nil:
+		return stderr.String(), fmt.Errorf("ffmpeg timed out after %s: %w", e.timeout, cmdCtx.Err())
+	default:
+		return stderr.String(), fmt.Errorf("ffmpeg execution failed: %w (stderr: %s)", err, stderr.String())
 	}
+}
 
-	return nil
+// runStream runs a command built with ffmpeg-go. Only its arguments are taken from ffmpeg-go: the command
+// runs like any other through Execute, so it waits for a slot, is limited to the command timeout, and is
+// killed when ctx is cancelled.
+func (e *Executor) runStream(ctx context.Context, output *ffmpeg.Stream) error {
+	return e.Execute(ctx, output.GetArgs())
 }
 
 // ValidateFile checks if a file exists
@@ -0,0 +1,51 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"govid/internal/fingerprint"
+)
+
+// Fingerprint hashes one frame per second of the first fingerprint.MaxSeconds of a video, from its frames
+// scaled down to gray thumbnails
+func (e *Executor) Fingerprint(ctx context.Context, videoPath string) (fingerprint.Fingerprint, error) {
+	if _, err := e.checkInput(ctx, videoPath, "video"); err != nil {
+		return nil, fmt.Errorf("video file: %w", err)
+	}
+
+	framesFile, err := os.CreateTemp("", "frames-*.gray")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create frames file: %w", err)
+	}
+	framesFile.Close()
+	defer os.Remove(framesFile.Name())
+
+	args := []string{
+		"-y",
+		"-hide_banner",
+		"-nostats",
+		"-t", strconv.Itoa(fingerprint.MaxSeconds),
+		"-i", videoPath,
+		"-an",
+		"-sn",
+		"-vf", fmt.Sprintf("fps=1,scale=%d:%d:flags=area,format=gray", fingerprint.FrameWidth, fingerprint.FrameHeight),
+		"-f", "rawvideo",
+		framesFile.Name(),
+	}
+	if err := e.Execute(ctx, args); err != nil {
+		return nil, err
+	}
+
+	frames, err := os.ReadFile(framesFile.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frames: %w", err)
+	}
+	f := fingerprint.FromFrames(frames)
+	if len(f) == 0 {
+		return nil, fmt.Errorf("%s has no frames to fingerprint", videoPath)
+	}
+	return f, nil
+}
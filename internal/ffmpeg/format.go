@@ -0,0 +1,206 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"govid/internal/models"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// isWebM reports whether the output path uses the WebM container, which only accepts VP8/VP9/AV1 video and Vorbis/Opus audio
+func isWebM(outputPath string) bool {
+	return strings.EqualFold(filepath.Ext(outputPath), ".webm")
+}
+
+// videoEncodeKwArgs returns the video encoder settings for the output container, applying any per-request overrides
+func videoEncodeKwArgs(outputPath string, opts models.OutputOptions) ffmpeg.KwArgs {
+	var kwArgs ffmpeg.KwArgs
+	if isWebM(outputPath) {
+		kwArgs = ffmpeg.KwArgs{
+			"c:v": "libvpx-vp9",
+			"crf": "32",
+			"b:v": "0",
+		}
+	} else {
+		kwArgs = ffmpeg.KwArgs{
+			"c:v":    "libx264",
+			"preset": "medium",
+			"crf":    "23",
+		}
+		if opts.Preset != "" {
+			kwArgs["preset"] = opts.Preset
+		}
+		if opts.Profile != "" {
+			kwArgs["profile:v"] = opts.Profile
+		}
+		if opts.Level != "" {
+			kwArgs["level:v"] = opts.Level
+		}
+	}
+
+	if opts.CRF != nil {
+		kwArgs["crf"] = *opts.CRF
+	}
+	if opts.PixFmt != "" {
+		kwArgs["pix_fmt"] = opts.PixFmt
+	}
+
+	return kwArgs
+}
+
+// audioEncodeKwArgs returns the default audio encoder settings for the output container
+func audioEncodeKwArgs(outputPath string) ffmpeg.KwArgs {
+	if isWebM(outputPath) {
+		return ffmpeg.KwArgs{
+			"c:a": "libopus",
+			"b:a": "128k",
+		}
+	}
+	return ffmpeg.KwArgs{
+		"c:a": "aac",
+		"b:a": "192k",
+	}
+}
+
+// encodeKwArgs returns the video and audio encoder settings and metadata options for the output container
+func encodeKwArgs(outputPath string, opts models.OutputOptions) ffmpeg.KwArgs {
+	return ffmpeg.MergeKwArgs([]ffmpeg.KwArgs{videoEncodeKwArgs(outputPath, opts), audioEncodeKwArgs(outputPath), metadataKwArgs(opts)})
+}
+
+// metadataKwArgs returns the options that strip and/or write container metadata
+func metadataKwArgs(opts models.OutputOptions) ffmpeg.KwArgs {
+	kwArgs := ffmpeg.KwArgs{}
+
+	if opts.StripMetadata {
+		kwArgs["map_metadata"] = "-1"
+		kwArgs["map_metadata:s:v"] = "-1"
+		kwArgs["map_metadata:s:a"] = "-1"
+		kwArgs["map_chapters"] = "-1"
+	}
+
+	if opts.Metadata != nil {
+		var tags []string
+		for _, tag := range []struct{ key, value string }{
+			{"title", opts.Metadata.Title},
+			{"artist", opts.Metadata.Artist},
+			{"comment", opts.Metadata.Comment},
+			{"creation_time", opts.Metadata.CreationTime},
+		} {
+			if tag.value != "" {
+				tags = append(tags, tag.key+"="+tag.value)
+			}
+		}
+		if len(tags) > 0 {
+			kwArgs["metadata"] = tags
+		}
+	}
+
+	return kwArgs
+}
+
+// copyVideoKwArgs copies the video stream when the container allows it (intermediates are H.264)
+// and no resize or frame rate change is requested, and re-encodes it otherwise
+func copyVideoKwArgs(outputPath string, opts models.OutputOptions) ffmpeg.KwArgs {
+	if isWebM(outputPath) || hasVideoFilters(opts) {
+		return videoEncodeKwArgs(outputPath, opts)
+	}
+	return ffmpeg.KwArgs{"c:v": "copy"}
+}
+
+// copyAudioKwArgs copies the audio stream when the container allows it and re-encodes it otherwise
+func copyAudioKwArgs(outputPath string) ffmpeg.KwArgs {
+	if isWebM(outputPath) {
+		return audioEncodeKwArgs(outputPath)
+	}
+	return ffmpeg.KwArgs{"c:a": "copy"}
+}
+
+// hasResize reports whether the options request a fixed output resolution
+func hasResize(opts models.OutputOptions) bool {
+	return opts.Width > 0 && opts.Height > 0
+}
+
+// hasVideoFilters reports whether the options require filtering (and so re-encoding) the video stream
+func hasVideoFilters(opts models.OutputOptions) bool {
+	return hasResize(opts) || opts.FPS > 0
+}
+
+// withoutVideoFilters returns the options with resizing and frame rate conversion cleared,
+// for stages whose input has already been filtered
+func withoutVideoFilters(opts models.OutputOptions) models.OutputOptions {
+	opts.Width, opts.Height, opts.Fit, opts.Background = 0, 0, "", ""
+	opts.FPS, opts.Interpolate = 0, ""
+	return opts
+}
+
+// filterVideo applies the requested resolution fitting and frame rate conversion to a video stream
+func filterVideo(stream *ffmpeg.Stream, opts models.OutputOptions) *ffmpeg.Stream {
+	return convertFrameRate(fitVideo(stream, opts), opts)
+}
+
+// convertFrameRate changes the frame rate of a video stream, interpolating new frames when requested.
+// The stream is returned unchanged when no frame rate is set.
+func convertFrameRate(stream *ffmpeg.Stream, opts models.OutputOptions) *ffmpeg.Stream {
+	if opts.FPS <= 0 {
+		return stream
+	}
+
+	fps := strconv.FormatFloat(opts.FPS, 'f', -1, 64)
+	switch opts.Interpolate {
+	case models.InterpolateBlend:
+		return stream.Filter("minterpolate", ffmpeg.Args{}, ffmpeg.KwArgs{"fps": fps, "mi_mode": "blend"})
+	case models.InterpolateMotion:
+		return stream.Filter("minterpolate", ffmpeg.Args{}, ffmpeg.KwArgs{
+			"fps":     fps,
+			"mi_mode": "mci",
+			"mc_mode": "aobmc",
+			"me_mode": "bidir",
+			"vsbmc":   1,
+		})
+	default:
+		return stream.Filter("fps", ffmpeg.Args{fps})
+	}
+}
+
+// fitVideo scales a video stream to the requested output resolution using the fit mode and background fill.
+// The stream is returned unchanged when no resolution is set.
+func fitVideo(stream *ffmpeg.Stream, opts models.OutputOptions) *ffmpeg.Stream {
+	if !hasResize(opts) {
+		return stream
+	}
+
+	size := fmt.Sprintf("%d:%d", opts.Width, opts.Height)
+	scale := func(s *ffmpeg.Stream, mode string) *ffmpeg.Stream {
+		return s.Filter("scale", ffmpeg.Args{size}, ffmpeg.KwArgs{"force_original_aspect_ratio": mode})
+	}
+
+	switch opts.Fit {
+	case models.FitStretch:
+		stream = stream.Filter("scale", ffmpeg.Args{size})
+	case models.FitCover:
+		stream = scale(stream, "increase").Filter("crop", ffmpeg.Args{size})
+	default:
+		if opts.Background == models.BackgroundBlur {
+			// Blurred, cropped copy fills the frame behind the scaled-down original
+			split := stream.Split()
+			background := scale(split.Get("0"), "increase").
+				Filter("crop", ffmpeg.Args{size}).
+				Filter("boxblur", ffmpeg.Args{"20:5"})
+			foreground := scale(split.Get("1"), "decrease")
+			stream = ffmpeg.Filter([]*ffmpeg.Stream{background, foreground}, "overlay", ffmpeg.Args{"(W-w)/2:(H-h)/2"})
+		} else {
+			color := opts.Background
+			if color == "" {
+				color = "black"
+			}
+			stream = scale(stream, "decrease").
+				Filter("pad", ffmpeg.Args{size + ":(ow-iw)/2:(oh-ih)/2"}, ffmpeg.KwArgs{"color": color})
+		}
+	}
+
+	return stream.Filter("setsar", ffmpeg.Args{"1"})
+}
@@ -0,0 +1,89 @@
+package ffmpeg
+
+import (
+	"context"
+
+	"govid/internal/models"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// graph is a filter graph under construction: one video and one audio stream that stages transform in turn,
+// so that a multi-step operation is encoded once instead of once per step
+type graph struct {
+	video    *ffmpeg.Stream
+	audio    *ffmpeg.Stream
+	duration float64 // expected output length in seconds; 0 if unknown
+}
+
+// graphStage appends the filters of one step to a graph. New steps, such as text or transitions, are added
+// by writing a stage and listing it where the graph is built.
+type graphStage func(g *graph)
+
+// apply runs the stages on the graph in order
+func (g *graph) apply(stages ...graphStage) {
+	for _, stage := range stages {
+		stage(g)
+	}
+}
+
+// runGraph encodes the graph into outputPath with kwArgs, reporting progress against its duration
+func (e *Executor) runGraph(ctx context.Context, g *graph, outputPath string, kwArgs ffmpeg.KwArgs) error {
+	if g.duration > 0 {
+		ctx = withDuration(ctx, g.duration)
+	}
+	output := ffmpeg.Output([]*ffmpeg.Stream{g.video, g.audio}, outputPath, kwArgs).OverWriteOutput()
+	return e.runStream(ctx, output)
+}
+
+// overlayStage lays images over the video
+func overlayStage(sources []overlaySource) graphStage {
+	return func(g *graph) {
+		g.video = overlayImages(g.video, sources)
+	}
+}
+
+// textStage draws text over the video
+func textStage(text models.TextOverlay) graphStage {
+	return func(g *graph) {
+		g.video = drawText(g.video, text)
+	}
+}
+
+// effectStage draws a built-in lower third or end card over the video, the end card over its last seconds
+func effectStage(effect models.TemplateEffect, logo *overlaySource) graphStage {
+	return func(g *graph) {
+		if effect.Type == models.EffectEndCard {
+			g.video = drawEndCard(g.video, effect, logo, g.duration)
+		} else {
+			g.video = drawLowerThird(g.video, effect)
+		}
+	}
+}
+
+// subtitlesStage burns a subtitle file into the video
+func subtitlesStage(subtitlesPath string) graphStage {
+	return func(g *graph) {
+		g.video = burnSubtitles(g.video, subtitlesPath)
+	}
+}
+
+// musicStage mixes background music into the audio, or replaces the audio with it in replace mode
+func musicStage(audio models.AudioConfig) graphStage {
+	return func(g *graph) {
+		if audio.Mode == models.AudioModeReplace {
+			g.audio = replaceMusic(audio, g.duration)
+		} else {
+			g.audio = mixMusic(g.audio, audio, g.duration)
+		}
+	}
+}
+
+// loudnessStage normalizes the loudness of the audio in a single pass. loudnorm upsamples to 192 kHz, so the
+// result is resampled to 48 kHz as after a normalization pass of its own.
+func loudnessStage(norm models.LoudnessNormalization) graphStage {
+	return func(g *graph) {
+		g.audio = g.audio.Filter("loudnorm", ffmpeg.Args{loudnormParams(norm, nil)}).
+			Filter("aformat", ffmpeg.Args{}, ffmpeg.KwArgs{"sample_rates": 48000})
+	}
+}
@@ -0,0 +1,72 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+
+	"govid/internal/models"
+	"govid/pkg/downloader"
+)
+
+// InputLimits caps the input videos of a request, so that a single request cannot occupy a worker for hours.
+// Zero fields disable a cap.
+type InputLimits struct {
+	MaxVideos   int     // videos per request
+	MaxDuration float64 // combined length of the videos in seconds, after trimming
+	MaxWidth    int     // longer side of any video in pixels
+	MaxHeight   int     // shorter side of any video in pixels
+}
+
+// InputLimitError reports an input that exceeds the input limits
+type InputLimitError struct {
+	Input  string // the offending input; empty when the number of videos is over the limit
+	Reason string
+}
+
+func (e *InputLimitError) Error() string {
+	if e.Input == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s: %s", e.Input, e.Reason)
+}
+
+// CheckInputLimits probes the videos of segments against the input limits before they are processed, failing
+// with an *InputLimitError naming the first video over a limit. Videos given as URLs only count towards the
+// number of videos; they are checked once downloaded. Videos that cannot be probed are left to fail in the job.
+func (e *Executor) CheckInputLimits(ctx context.Context, segments []models.VideoSegment) error {
+	limits := e.inputLimits
+	if limits.MaxVideos > 0 && len(segments) > limits.MaxVideos {
+		return &InputLimitError{Reason: fmt.Sprintf("%d videos exceed the limit of %d per request", len(segments), limits.MaxVideos)}
+	}
+	if limits.MaxDuration <= 0 && limits.MaxWidth <= 0 && limits.MaxHeight <= 0 {
+		return nil
+	}
+
+	var total float64
+	for _, seg := range segments {
+		if downloader.IsURL(seg.FilePath) {
+			continue
+		}
+		probe, err := e.Probe(ctx, seg.FilePath)
+		if err != nil {
+			continue
+		}
+
+		if video := probe.VideoStream(); video != nil {
+			long, short := max(video.Width, video.Height), min(video.Width, video.Height)
+			if (limits.MaxWidth > 0 && long > limits.MaxWidth) || (limits.MaxHeight > 0 && short > limits.MaxHeight) {
+				return &InputLimitError{Input: seg.FilePath, Reason: fmt.Sprintf("resolution %dx%d exceeds the limit of %dx%d", video.Width, video.Height, limits.MaxWidth, limits.MaxHeight)}
+			}
+		}
+
+		if seg.EndTime > 0 {
+			total += seg.EndTime - seg.StartTime
+		} else {
+			total += max(probe.Duration-seg.StartTime, 0)
+		}
+		if limits.MaxDuration > 0 && total > limits.MaxDuration {
+			return &InputLimitError{Input: seg.FilePath, Reason: fmt.Sprintf("input duration reaches %.1f seconds, over the limit of %.0f seconds", total, limits.MaxDuration)}
+		}
+	}
+	return nil
+}
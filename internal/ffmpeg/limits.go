@@ -0,0 +1,84 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// I/O scheduling classes for Limits.IOClass
+const (
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+)
+
+// Limits restricts the resources of each ffmpeg process, so that one large encode cannot starve the API
+// server and other jobs on the same host
+type Limits struct {
+	Threads   int     // threads of each decoder and encoder, and of the filters; 0 leaves it to ffmpeg
+	Nice      int     // niceness the process runs at (0-19), set with nice
+	IOClass   string  // I/O scheduling class, best-effort or idle, set with ionice; empty inherits it
+	CgroupDir string  // cgroup v2 directory under which each process gets a cgroup with the limits below
+	MemoryMax int64   // memory limit in bytes; 0 for no limit
+	CPUs      float64 // CPU limit in cores; 0 for no limit
+}
+
+// usesCgroup reports whether the limits need a cgroup per process
+func (l Limits) usesCgroup() bool {
+	return l.MemoryMax > 0 || l.CPUs > 0
+}
+
+// command builds the command that runs binary with args under the limits. The cgroup limits are applied
+// separately, since they need the process to be placed in a cgroup.
+func (l Limits) command(binary string, args []string) (string, []string) {
+	if l.Threads > 0 {
+		args = withThreads(args, l.Threads)
+	}
+	if l.IOClass != "" {
+		class := "2"
+		if l.IOClass == IOClassIdle {
+			class = "3"
+		}
+		args = append([]string{"-c", class, binary}, args...)
+		binary = "ionice"
+	}
+	if l.Nice > 0 {
+		args = append([]string{"-n", strconv.Itoa(l.Nice), binary}, args...)
+		binary = "nice"
+	}
+	return binary, args
+}
+
+// withThreads limits the threads of every input and of the output, and those of the filter graphs. Commands
+// have a single output, given as the last argument, or before the -y that ffmpeg-go appends.
+func withThreads(args []string, threads int) []string {
+	n := strconv.Itoa(threads)
+	limited := []string{"-filter_threads", n, "-filter_complex_threads", n}
+
+	output := len(args) - 1
+	if output > 0 && args[output] == "-y" {
+		output--
+	}
+	for i, arg := range args {
+		if i == output || arg == "-i" {
+			limited = append(limited, "-threads", n)
+		}
+		limited = append(limited, arg)
+	}
+	return limited
+}
+
+// checkLimitsTools checks that the tools the limits run ffmpeg with are installed
+func checkLimitsTools(l Limits) error {
+	if l.IOClass != "" {
+		if _, err := exec.LookPath("ionice"); err != nil {
+			return fmt.Errorf("FFmpeg I/O class needs ionice: %w", err)
+		}
+	}
+	if l.Nice > 0 {
+		if _, err := exec.LookPath("nice"); err != nil {
+			return fmt.Errorf("FFmpeg niceness needs nice: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+type logKey struct{}
+
+// commandLog receives the command lines and stderr output of the FFmpeg commands run for one job
+type commandLog struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// WithLog returns a context whose FFmpeg commands append their command line and stderr output to w
+func WithLog(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, logKey{}, &commandLog{w: w})
+}
+
+// writeCommandLog appends a finished command to the log in ctx, if any
+func writeCommandLog(ctx context.Context, args []string, stderr string, err error) {
+	log, ok := ctx.Value(logKey{}).(*commandLog)
+	if !ok {
+		return
+	}
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	fmt.Fprintf(log.w, "$ %s\n%s", strings.Join(args, " "), stderr)
+	if err != nil {
+		fmt.Fprintf(log.w, "[error: %v]\n", err)
+	}
+	fmt.Fprintln(log.w)
+}
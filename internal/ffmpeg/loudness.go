@@ -0,0 +1,105 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"govid/internal/models"
+)
+
+// minLevelDB is reported for the levels of silence, which FFmpeg logs as -inf
+const minLevelDB = -144.0
+
+var (
+	integratedPattern = regexp.MustCompile(`\sI:\s+(\S+) LUFS`)
+	lraPattern        = regexp.MustCompile(`\sLRA:\s+(\S+) LU`)
+	truePeakPattern   = regexp.MustCompile(`True peak:\s+Peak:\s+(\S+) dBFS`)
+	samplePeakPattern = regexp.MustCompile(`Peak level dB: (\S+)`)
+	rmsLevelPattern   = regexp.MustCompile(`RMS level dB: (\S+)`)
+	rmsPeakPattern    = regexp.MustCompile(`RMS peak dB: (\S+)`)
+	dcOffsetPattern   = regexp.MustCompile(`DC offset: (\S+)`)
+)
+
+// AnalyzeLoudness measures the audio of a file in one pass: its EBU R128 loudness with ebur128 and its levels
+// with astats. Whether the file meets the specification of the request is left to LoudnessRequest.Check.
+func (e *Executor) AnalyzeLoudness(ctx context.Context, req models.LoudnessRequest) (*models.LoudnessResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := e.checkInput(ctx, req.FilePath, "audio"); err != nil {
+		return nil, fmt.Errorf("input file: %w", err)
+	}
+
+	// Per-frame ebur128 lines go to the verbose log, leaving only the summary
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", req.FilePath,
+		"-vn",
+		"-sn",
+		"-af", "astats,ebur128=peak=true:framelog=verbose",
+		"-f", "null",
+		"-",
+	}
+	stderr, err := e.ExecuteWithOutput(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := parseLoudness(stderr)
+	if err != nil {
+		return nil, err
+	}
+	report.FilePath = req.FilePath
+	report.Duration = parseDuration(stderr)
+	return report, nil
+}
+
+// parseLoudness reads the summary of ebur128 and the overall statistics of astats, which both filters log
+// when they close
+func parseLoudness(stderr string) (*models.LoudnessResponse, error) {
+	start := strings.LastIndex(stderr, "Summary:")
+	if start == -1 {
+		return nil, fmt.Errorf("ebur128 summary not found in ffmpeg output")
+	}
+	summary := stderr[start:]
+
+	// astats lists each channel, then the channels together
+	start = strings.LastIndex(stderr, "] Overall")
+	if start == -1 {
+		return nil, fmt.Errorf("astats summary not found in ffmpeg output")
+	}
+	overall := stderr[start:]
+
+	var report models.LoudnessResponse
+	fields := []struct {
+		name    string
+		text    string
+		pattern *regexp.Regexp
+		value   *float64
+	}{
+		{"integrated loudness", summary, integratedPattern, &report.IntegratedLUFS},
+		{"loudness range", summary, lraPattern, &report.LoudnessRange},
+		{"true peak", summary, truePeakPattern, &report.TruePeak},
+		{"peak level", overall, samplePeakPattern, &report.SamplePeak},
+		{"RMS level", overall, rmsLevelPattern, &report.RMSLevel},
+		{"RMS peak", overall, rmsPeakPattern, &report.RMSPeak},
+		{"DC offset", overall, dcOffsetPattern, &report.DCOffset},
+	}
+	for _, field := range fields {
+		match := field.pattern.FindStringSubmatch(field.text)
+		if match == nil {
+			return nil, fmt.Errorf("%s not found in ffmpeg output", field.name)
+		}
+		value, err := strconv.ParseFloat(match[1], 64)
+		if err != nil || math.IsNaN(value) {
+			return nil, fmt.Errorf("invalid %s %q in ffmpeg output", field.name, match[1])
+		}
+		*field.value = max(value, minLevelDB)
+	}
+	return &report, nil
+}
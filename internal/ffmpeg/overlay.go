@@ -3,6 +3,7 @@ package ffmpeg
 import (
 	"context"
 	"fmt"
+	"math"
 
 	"govid/internal/models"
 
@@ -10,20 +11,32 @@ import (
 )
 
 // AddImageOverlay adds an image overlay to a video with animations
-func (e *Executor) AddImageOverlay(ctx context.Context, videoPath string, overlay models.ImageOverlay, outputPath string) error {
+func (e *Executor) AddImageOverlay(ctx context.Context, videoPath string, overlay models.ImageOverlay, opts models.OutputOptions, outputPath string) error {
 	// Validate files
-	if err := ValidateFile(videoPath); err != nil {
+	if _, err := e.checkInput(ctx, videoPath, "video"); err != nil {
 		return fmt.Errorf("video file: %w", err)
 	}
-	if err := ValidateFile(overlay.FilePath); err != nil {
+	source, err := e.overlaySource(ctx, overlay)
+	if err != nil {
 		return fmt.Errorf("overlay image: %w", err)
 	}
+	if err := e.checkInterpolationLength(ctx, opts, pathSegments(videoPath)); err != nil {
+		return err
+	}
+	ctx = e.withInputDuration(ctx, videoPath)
 
-	// Build overlay stream with filters
-	overlayStream := ffmpeg.Input(overlay.FilePath)
+	// Positions are relative to the fitted frame
+	videoStream := layOverlay(filterVideo(ffmpeg.Input(videoPath), opts), source)
+	output := videoStream.Output(outputPath, ffmpeg.MergeKwArgs([]ffmpeg.KwArgs{videoEncodeKwArgs(outputPath, opts), copyAudioKwArgs(outputPath), metadataKwArgs(opts)})).OverWriteOutput()
+
+	return e.runStream(ctx, output)
+}
 
-	// Always apply format for transparency
-	overlayStream = overlayStream.Filter("format", ffmpeg.Args{"rgba"})
+// layOverlay lays an overlay over a video stream, with its animation, position and timing
+func layOverlay(videoStream *ffmpeg.Stream, source overlaySource) *ffmpeg.Stream {
+	// Build overlay stream with filters
+	overlay := source.ImageOverlay
+	overlayStream := overlayInput(source)
 
 	// Apply animation filters
 	switch overlay.Animation {
@@ -32,20 +45,11 @@ func (e *Executor) AddImageOverlay(ctx context.Context, videoPath string, overla
 		if overlay.FadeDuration != nil {
 			duration = *overlay.FadeDuration
 		}
-		totalDuration := overlay.EndTime - overlay.StartTime
 
 		// Fade in
 		overlayStream = overlayStream.Filter("fade", ffmpeg.Args{}, ffmpeg.KwArgs{
 			"t":     "in",
-			"st":    0,
-			"d":     duration,
-			"alpha": 1,
-		})
-
-		// Fade out
-		overlayStream = overlayStream.Filter("fade", ffmpeg.Args{}, ffmpeg.KwArgs{
-			"t":     "out",
-			"st":    totalDuration - duration,
+			"st":    overlay.StartTime,
 			"d":     duration,
 			"alpha": 1,
 		})
@@ -59,16 +63,26 @@ func (e *Executor) AddImageOverlay(ctx context.Context, videoPath string, overla
 		if overlay.ZoomTo != nil {
 			zoomTo = *overlay.ZoomTo
 		}
-		duration := overlay.EndTime - overlay.StartTime
-		zoomRate := (zoomTo - zoomFrom) / duration
+		progress := fmt.Sprintf("clip((it-%.2f)/%.2f,0,1)", overlay.StartTime, overlay.EndTime-overlay.StartTime)
 
 		overlayStream = overlayStream.Filter("zoompan", ffmpeg.Args{}, ffmpeg.KwArgs{
-			"z": fmt.Sprintf("if(lte(zoom,%.2f),zoom+%.6f,%.2f)", zoomTo, zoomRate, zoomTo),
+			"z": fmt.Sprintf("%.2f+%.2f*%s", zoomFrom, zoomTo-zoomFrom, ease(overlay.Easing, progress)),
 			"d": 1,
 			"s": "1280x720",
 		})
 	}
 
+	// Fade out, ending when the overlay disappears
+	if overlay.Exit() == models.AnimationFade {
+		duration := overlay.ExitSeconds()
+		overlayStream = overlayStream.Filter("fade", ffmpeg.Args{}, ffmpeg.KwArgs{
+			"t":     "out",
+			"st":    overlay.EndTime - duration,
+			"d":     duration,
+			"alpha": 1,
+		})
+	}
+
 	// Calculate position
 	x, y := calculatePosition(overlay)
 
@@ -80,29 +94,173 @@ func (e *Executor) AddImageOverlay(ctx context.Context, videoPath string, overla
 		}
 		x, y = calculateSlidePosition(overlay, x, y, duration)
 	}
+	if overlay.Exit() == models.AnimationSlide {
+		x, y = calculateSlideOutPosition(overlay, x, y)
+	}
 
-	// Build overlay with position and timing
-	videoStream := ffmpeg.Input(videoPath)
-
-	// Apply overlay using Filter method
-	// Position goes in Args as "x:y", enable goes in KwArgs
-	positionArg := fmt.Sprintf("%s:%s", x, y)
-
-	output := ffmpeg.Filter(
+	return ffmpeg.Filter(
 		[]*ffmpeg.Stream{videoStream, overlayStream},
 		"overlay",
-		ffmpeg.Args{positionArg},
-		ffmpeg.KwArgs{
-			"enable": fmt.Sprintf("between(t,%.2f,%.2f)", overlay.StartTime, overlay.EndTime),
-		},
-	).Output(outputPath, ffmpeg.KwArgs{
-		"c:v":    "libx264",
-		"preset": "medium",
-		"crf":    "23",
-		"c:a":    "copy",
-	}).OverWriteOutput()
+		ffmpeg.Args{},
+		ffmpeg.MergeKwArgs([]ffmpeg.KwArgs{{"x": x, "y": y}, overlayTiming(source)}),
+	)
+}
 
-	return output.Run()
+// overlaySource is an overlay with how its file is read
+type overlaySource struct {
+	models.ImageOverlay
+	animated bool   // the file has more than one frame, such as an animated GIF or a video
+	decoder  string // decoder keeping the alpha channel of the file, or "" for the default one
+}
+
+// stillCodecs are the codecs of overlay files read as a single frame
+var stillCodecs = map[string]bool{"png": true, "mjpeg": true, "bmp": true, "tiff": true, "webp": true}
+
+// overlaySource checks the file of an overlay and finds how to read it
+func (e *Executor) overlaySource(ctx context.Context, overlay models.ImageOverlay) (overlaySource, error) {
+	probe, err := e.checkInput(ctx, overlay.FilePath, "video")
+	if err != nil {
+		return overlaySource{}, err
+	}
+	codec := probe.VideoStream().Codec
+	return overlaySource{ImageOverlay: overlay, animated: !stillCodecs[codec], decoder: e.alphaDecoder(codec)}, nil
+}
+
+// overlaySources checks the files of overlays and finds how to read them
+func (e *Executor) overlaySources(ctx context.Context, overlays []models.ImageOverlay) ([]overlaySource, error) {
+	sources := make([]overlaySource, len(overlays))
+	for i, overlay := range overlays {
+		source, err := e.overlaySource(ctx, overlay)
+		if err != nil {
+			return nil, fmt.Errorf("overlay %d image: %w", i, err)
+		}
+		sources[i] = source
+	}
+	return sources, nil
+}
+
+// alphaDecoder returns the libvpx decoder for VP8 and VP9 video, whose alpha channel FFmpeg's own decoders
+// drop, or "" for other codecs and for binaries built without libvpx, found by its VP9 encoder
+func (e *Executor) alphaDecoder(codec string) string {
+	if e.caps != nil && !e.caps.Encoders["libvpx-vp9"] {
+		return ""
+	}
+	switch codec {
+	case "vp9":
+		return "libvpx-vp9"
+	case "vp8":
+		return "libvpx"
+	default:
+		return ""
+	}
+}
+
+// loopsOverlay reports whether an overlay is read over and over rather than once: an animation with loop, or
+// a still image that rotates, fades or zooms over time, since a single frame cannot
+func loopsOverlay(source overlaySource) bool {
+	if source.animated {
+		return source.Loop
+	}
+	switch {
+	case source.RotationAnimated(), source.Exit() == models.AnimationFade:
+		return true
+	default:
+		return source.Animation == models.AnimationFade || source.Animation == models.AnimationZoom
+	}
+}
+
+// overlayInput reads an overlay with an alpha channel, at its requested size, angle and opacity. Animations
+// start playing when the overlay appears, and without loop their last frame stays until it disappears. Fades
+// multiply the alpha channel, so they start from the opacity.
+func overlayInput(source overlaySource) *ffmpeg.Stream {
+	kwArgs := ffmpeg.KwArgs{}
+	if source.decoder != "" {
+		kwArgs["c:v"] = source.decoder
+	}
+	if loopsOverlay(source) {
+		if source.animated {
+			kwArgs["stream_loop"] = -1
+		} else {
+			kwArgs["loop"] = 1
+		}
+	}
+	stream := ffmpeg.Input(source.FilePath, kwArgs)
+	if source.animated {
+		stream = stream.Filter("setpts", ffmpeg.Args{fmt.Sprintf("PTS-STARTPTS+%.3f/TB", source.StartTime)})
+		if !source.Loop {
+			stream = stream.Filter("tpad", ffmpeg.Args{}, ffmpeg.KwArgs{"stop": -1, "stop_mode": "clone"})
+		}
+	}
+
+	overlay := source.ImageOverlay
+	stream = rotateOverlay(scaleOverlay(stream.Filter("format", ffmpeg.Args{"rgba"}), overlay), overlay)
+	if overlay.Opacity != nil && *overlay.Opacity < 1 {
+		stream = stream.Filter("colorchannelmixer", ffmpeg.Args{}, ffmpeg.KwArgs{"aa": fmt.Sprintf("%.3f", *overlay.Opacity)})
+	}
+	return stream
+}
+
+// scaleOverlay resizes an overlay image to its width, height or scale_percent, keeping its aspect ratio
+func scaleOverlay(stream *ffmpeg.Stream, overlay models.ImageOverlay) *ffmpeg.Stream {
+	switch {
+	case overlay.ScalePercent != nil:
+		return stream.Filter("scale", ffmpeg.Args{}, ffmpeg.KwArgs{
+			"w": fmt.Sprintf("max(1,round(iw*%g))", *overlay.ScalePercent/100),
+			"h": -1,
+		})
+	case overlay.Width != nil && overlay.Height != nil:
+		return stream.Filter("scale", ffmpeg.Args{}, ffmpeg.KwArgs{
+			"w":                           *overlay.Width,
+			"h":                           *overlay.Height,
+			"force_original_aspect_ratio": "decrease",
+		})
+	case overlay.Width != nil:
+		return stream.Filter("scale", ffmpeg.Args{}, ffmpeg.KwArgs{"w": *overlay.Width, "h": -1})
+	case overlay.Height != nil:
+		return stream.Filter("scale", ffmpeg.Args{}, ffmpeg.KwArgs{"w": -1, "h": *overlay.Height})
+	default:
+		return stream
+	}
+}
+
+// rotateOverlay turns an overlay image clockwise, leaving the corners it uncovers transparent. A fixed angle
+// grows the frame to fit the rotated image; a changing one makes it large enough for any angle, so that the
+// image turns around its center.
+func rotateOverlay(stream *ffmpeg.Stream, overlay models.ImageOverlay) *ffmpeg.Stream {
+	if !overlay.RotationAnimated() {
+		if overlay.RotationDegrees == nil || math.Mod(*overlay.RotationDegrees, 360) == 0 {
+			return stream
+		}
+		angle := fmt.Sprintf("%.6f", *overlay.RotationDegrees*math.Pi/180)
+		return stream.Filter("rotate", ffmpeg.Args{}, ffmpeg.KwArgs{
+			"a":  angle,
+			"ow": fmt.Sprintf("rotw(%s)", angle),
+			"oh": fmt.Sprintf("roth(%s)", angle),
+			"c":  "none",
+		})
+	}
+
+	from := 0.0
+	if overlay.RotationDegrees != nil {
+		from = *overlay.RotationDegrees
+	}
+	to := *overlay.RotationEndDegrees
+	return stream.Filter("rotate", ffmpeg.Args{}, ffmpeg.KwArgs{
+		"a":  fmt.Sprintf("(%g+%g*clip((t-%.2f)/%.2f,0,1))*PI/180", from, to-from, overlay.StartTime, overlay.EndTime-overlay.StartTime),
+		"ow": "hypot(iw,ih)",
+		"oh": "hypot(iw,ih)",
+		"c":  "none",
+	})
+}
+
+// overlayTiming returns the options of the overlay filter showing an overlay between its start and end time.
+// Animations and looped images never end, so the output ends with the video instead.
+func overlayTiming(source overlaySource) ffmpeg.KwArgs {
+	kwArgs := ffmpeg.KwArgs{"enable": fmt.Sprintf("between(t,%.2f,%.2f)", source.StartTime, source.EndTime)}
+	if source.animated || loopsOverlay(source) {
+		kwArgs["shortest"] = 1
+	}
+	return kwArgs
 }
 
 // calculatePosition calculates x,y position based on preset or custom values
@@ -133,8 +291,7 @@ func calculatePosition(overlay models.ImageOverlay) (string, string) {
 
 // calculateSlidePosition calculates position for slide animation
 func calculateSlidePosition(overlay models.ImageOverlay, baseX, baseY string, duration float64) (string, string) {
-	t := fmt.Sprintf("(t-%.2f)", overlay.StartTime)
-	progress := fmt.Sprintf("min(%s/%.2f,1)", t, duration)
+	progress := ease(overlay.Easing, fmt.Sprintf("clip((t-%.2f)/%.2f,0,1)", overlay.StartTime, duration))
 
 	switch *overlay.SlideDirection {
 	case models.SlideFromLeft:
@@ -166,70 +323,84 @@ func calculateSlidePosition(overlay models.ImageOverlay, baseX, baseY string, du
 	}
 }
 
+// calculateSlideOutPosition moves the x,y position of an overlay off the frame by its exit direction, over
+// its exit duration before it disappears
+func calculateSlideOutPosition(overlay models.ImageOverlay, x, y string) (string, string) {
+	start := overlay.EndTime - overlay.ExitSeconds()
+	progress := ease(overlay.Easing, fmt.Sprintf("clip((t-%.2f)/%.2f,0,1)", start, overlay.ExitSeconds()))
+	slide := func(base, offset string) string {
+		return fmt.Sprintf("if(gt(t,%.2f),%s+(%s)*(%s),%s)", start, base, progress, offset, base)
+	}
+
+	switch overlay.ExitSlideDirection() {
+	case models.SlideFromLeft:
+		// Slide from x to -overlay_w
+		return slide(x, "-overlay_w-("+x+")"), y
+	case models.SlideFromRight:
+		// Slide from x to main_w
+		return slide(x, "main_w-("+x+")"), y
+	case models.SlideFromTop:
+		// Slide from y to -overlay_h
+		return x, slide(y, "-overlay_h-("+y+")")
+	case models.SlideFromBottom:
+		// Slide from y to main_h
+		return x, slide(y, "main_h-("+y+")")
+	default:
+		return x, y
+	}
+}
+
+// ease returns an FFmpeg expression bending the progress of an animation, an expression going from 0 to 1,
+// by an easing curve: cubic for ease-in and ease-out, and a ball dropping and bouncing to rest for bounce
+func ease(easing models.Easing, progress string) string {
+	p := "(" + progress + ")"
+	switch easing {
+	case models.EasingIn:
+		return fmt.Sprintf("pow(%s,3)", p)
+	case models.EasingOut:
+		return fmt.Sprintf("(1-pow(1-%s,3))", p)
+	case models.EasingInOut:
+		return fmt.Sprintf("if(lt(%[1]s,0.5),4*pow(%[1]s,3),1-pow(2-2*%[1]s,3)/2)", p)
+	case models.EasingBounce:
+		return fmt.Sprintf("if(lt(%[1]s,1/2.75),7.5625*pow(%[1]s,2),if(lt(%[1]s,2/2.75),7.5625*pow(%[1]s-1.5/2.75,2)+0.75,"+
+			"if(lt(%[1]s,2.5/2.75),7.5625*pow(%[1]s-2.25/2.75,2)+0.9375,7.5625*pow(%[1]s-2.625/2.75,2)+0.984375)))", p)
+	default:
+		return p
+	}
+}
+
 // AddMultipleOverlays adds multiple image overlays to a video
-func (e *Executor) AddMultipleOverlays(ctx context.Context, videoPath string, overlays []models.ImageOverlay, outputPath string) error {
+func (e *Executor) AddMultipleOverlays(ctx context.Context, videoPath string, overlays []models.ImageOverlay, opts models.OutputOptions, outputPath string) error {
 	if len(overlays) == 0 {
 		return fmt.Errorf("no overlays provided")
 	}
 
 	// Validate files
-	if err := ValidateFile(videoPath); err != nil {
+	if _, err := e.checkInput(ctx, videoPath, "video"); err != nil {
 		return fmt.Errorf("video file: %w", err)
 	}
-	for i, overlay := range overlays {
-		if err := ValidateFile(overlay.FilePath); err != nil {
-			return fmt.Errorf("overlay %d image: %w", i, err)
-		}
+	sources, err := e.overlaySources(ctx, overlays)
+	if err != nil {
+		return err
+	}
+	if err := e.checkInterpolationLength(ctx, opts, pathSegments(videoPath)); err != nil {
+		return err
 	}
+	ctx = e.withInputDuration(ctx, videoPath)
 
-	// Start with video input
-	currentStream := ffmpeg.Input(videoPath)
-
-	// Apply each overlay sequentially
-	for _, overlay := range overlays {
-		overlayStream := ffmpeg.Input(overlay.FilePath).Filter("format", ffmpeg.Args{"rgba"})
-
-		// Apply fade animation if specified
-		if overlay.Animation == models.AnimationFade && overlay.FadeDuration != nil {
-			duration := *overlay.FadeDuration
-			totalDuration := overlay.EndTime - overlay.StartTime
-
-			overlayStream = overlayStream.Filter("fade", ffmpeg.Args{}, ffmpeg.KwArgs{
-				"t":     "in",
-				"st":    0,
-				"d":     duration,
-				"alpha": 1,
-			}).Filter("fade", ffmpeg.Args{}, ffmpeg.KwArgs{
-				"t":     "out",
-				"st":    totalDuration - duration,
-				"d":     duration,
-				"alpha": 1,
-			})
-		}
+	// Start with video input, fitted to the output resolution and frame rate
+	currentStream := overlayImages(filterVideo(ffmpeg.Input(videoPath), opts), sources)
 
-		// Calculate position
-		x, y := calculatePosition(overlay)
+	// Output
+	output := currentStream.Output(outputPath, ffmpeg.MergeKwArgs([]ffmpeg.KwArgs{videoEncodeKwArgs(outputPath, opts), copyAudioKwArgs(outputPath), metadataKwArgs(opts)})).OverWriteOutput()
 
-		// Apply overlay
-		currentStream = ffmpeg.Filter(
-			[]*ffmpeg.Stream{currentStream, overlayStream},
-			"overlay",
-			ffmpeg.Args{},
-			ffmpeg.KwArgs{
-				"x":      x,
-				"y":      y,
-				"enable": fmt.Sprintf("between(t,%.2f,%.2f)", overlay.StartTime, overlay.EndTime),
-			},
-		)
-	}
+	return e.runStream(ctx, output)
+}
 
-	// Output
-	output := currentStream.Output(outputPath, ffmpeg.KwArgs{
-		"c:v":    "libx264",
-		"preset": "medium",
-		"crf":    "23",
-		"c:a":    "copy",
-	}).OverWriteOutput()
-
-	return output.Run()
+// overlayImages lays the overlays over a video stream one after another
+func overlayImages(currentStream *ffmpeg.Stream, sources []overlaySource) *ffmpeg.Stream {
+	for _, source := range sources {
+		currentStream = layOverlay(currentStream, source)
+	}
+	return currentStream
 }
@@ -0,0 +1,37 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"govid/internal/models"
+)
+
+// checkInput probes an input before the encode starts, so that a missing, empty, corrupted, or non-media file
+// fails the job at once with the file and the reason, instead of minutes into the encode with FFmpeg's stderr.
+// kind is the type of stream the input must have, video or audio; images count as video. It returns the probe.
+func (e *Executor) checkInput(ctx context.Context, path, kind string) (*models.MediaProbe, error) {
+	if err := ValidateFile(path); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+
+	probe, err := e.Probe(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("not a readable media file: %w", err)
+	}
+	if len(probe.Streams) == 0 {
+		return nil, fmt.Errorf("%s contains no media streams", path)
+	}
+	stream := probe.VideoStream()
+	if kind == "audio" {
+		stream = probe.AudioStream()
+	}
+	if stream == nil {
+		return nil, fmt.Errorf("%s has no %s stream", path, kind)
+	}
+	return probe, nil
+}
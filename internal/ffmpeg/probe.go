@@ -0,0 +1,244 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"govid/internal/models"
+	"govid/pkg/tracing"
+
+	"github.com/bytedance/sonic"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// probeOutput is the layout of ffprobe's JSON output. ffprobe reports most numbers as strings.
+type probeOutput struct {
+	Streams []struct {
+		Index             int    `json:"index"`
+		CodecType         string `json:"codec_type"`
+		CodecName         string `json:"codec_name"`
+		Profile           string `json:"profile"`
+		BitRate           string `json:"bit_rate"`
+		Duration          string `json:"duration"`
+		Width             int    `json:"width"`
+		Height            int    `json:"height"`
+		PixFmt            string `json:"pix_fmt"`
+		SampleAspectRatio string `json:"sample_aspect_ratio"`
+		FrameRate         string `json:"r_frame_rate"`
+		SampleRate        string `json:"sample_rate"`
+		Channels          int    `json:"channels"`
+		ChannelLayout     string `json:"channel_layout"`
+		Tags              struct {
+			Language string `json:"language"`
+			Rotate   string `json:"rotate"` // written by older muxers
+		} `json:"tags"`
+		SideDataList []struct {
+			Rotation *int `json:"rotation"` // display matrix rotation, counterclockwise
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		Size       string `json:"size"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// Probe reads the container format, duration, bitrate, and streams of a media file with ffprobe
+func (e *Executor) Probe(ctx context.Context, path string) (probe *models.MediaProbe, err error) {
+	ctx, span := tracing.Start(ctx, "ffprobe", attribute.String("file.path", path))
+	defer func() { tracing.End(span, err) }()
+
+	cmd := exec.CommandContext(ctx, e.probeBinary,
+		"-v", "error",
+		"-show_format",
+		"-show_streams",
+		"-of", "json",
+		path,
+	)
+
+	out, err := cmd.Output()
+	if err != nil {
+		// ffprobe explains the failure on stderr, as "<path>: <reason>"
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(bytes.TrimSpace(exitErr.Stderr)) > 0 {
+			reason := strings.TrimPrefix(strings.TrimSpace(string(exitErr.Stderr)), path+": ")
+			return nil, fmt.Errorf("ffprobe failed for %s: %s", path, reason)
+		}
+		return nil, fmt.Errorf("ffprobe failed for %s: %w", path, err)
+	}
+
+	var raw probeOutput
+	if err := sonic.Unmarshal(out, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output for %s: %w", path, err)
+	}
+
+	probe = &models.MediaProbe{
+		FilePath:   path,
+		FormatName: raw.Format.FormatName,
+		Size:       parseInt(raw.Format.Size),
+		BitRate:    parseInt(raw.Format.BitRate),
+		Streams:    make([]models.MediaStream, 0, len(raw.Streams)),
+	}
+	if raw.Format.Duration != "" {
+		if probe.Duration, err = strconv.ParseFloat(raw.Format.Duration, 64); err != nil {
+			return nil, fmt.Errorf("failed to parse duration of %s: %w", path, err)
+		}
+	}
+	for _, s := range raw.Streams {
+		stream := models.MediaStream{
+			Index:             s.Index,
+			Type:              s.CodecType,
+			Codec:             s.CodecName,
+			Profile:           s.Profile,
+			BitRate:           parseInt(s.BitRate),
+			Duration:          parseFloat(s.Duration),
+			Language:          s.Tags.Language,
+			Width:             s.Width,
+			Height:            s.Height,
+			PixFmt:            s.PixFmt,
+			SampleAspectRatio: s.SampleAspectRatio,
+			FrameRate:         parseFrameRate(s.FrameRate),
+			SampleRate:        int(parseInt(s.SampleRate)),
+			Channels:          s.Channels,
+			ChannelLayout:     s.ChannelLayout,
+		}
+		if s.CodecType == "video" {
+			rotation := int(parseInt(s.Tags.Rotate))
+			for _, side := range s.SideDataList {
+				if side.Rotation != nil {
+					rotation = -*side.Rotation
+				}
+			}
+			stream.Rotation = ((rotation % 360) + 360) % 360
+		}
+		probe.Streams = append(probe.Streams, stream)
+	}
+
+	return probe, nil
+}
+
+// parseInt parses an integer reported by ffprobe, returning 0 when it is missing or "N/A"
+func parseInt(value string) int64 {
+	n, _ := strconv.ParseInt(value, 10, 64)
+	return n
+}
+
+// parseFloat parses a decimal reported by ffprobe, returning 0 when it is missing or "N/A"
+func parseFloat(value string) float64 {
+	n, _ := strconv.ParseFloat(value, 64)
+	return n
+}
+
+// MediaDuration returns the duration of a media file in seconds
+func (e *Executor) MediaDuration(ctx context.Context, path string) (float64, error) {
+	probe, err := e.Probe(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+	return probe.Duration, nil
+}
+
+// InputSeconds returns the combined duration of the media files at paths. Files that cannot be probed, such as
+// images, count as zero seconds.
+func (e *Executor) InputSeconds(ctx context.Context, paths ...string) float64 {
+	var total float64
+	for _, path := range paths {
+		if duration, err := e.MediaDuration(ctx, path); err == nil {
+			total += duration
+		}
+	}
+	return total
+}
+
+// DescribeMedia returns the duration, resolution, frame rate, and codecs of a media file for its metadata sidecar
+func (e *Executor) DescribeMedia(ctx context.Context, path string) (models.AssetMetadata, error) {
+	probe, err := e.Probe(ctx, path)
+	if err != nil {
+		return models.AssetMetadata{}, err
+	}
+
+	meta := models.AssetMetadata{Duration: probe.Duration}
+	if video := probe.VideoStream(); video != nil {
+		meta.Width = video.Width
+		meta.Height = video.Height
+		meta.FrameRate = video.FrameRate
+		meta.VideoCodec = video.Codec
+	}
+	if audio := probe.AudioStream(); audio != nil {
+		meta.AudioCodec = audio.Codec
+	}
+	return meta, nil
+}
+
+// FrameSize returns the size of the frames of a video encoded with opts: the output resolution if opts sets
+// one, or else the size of the video as displayed, since FFmpeg applies its rotation
+func (e *Executor) FrameSize(ctx context.Context, videoPath string, opts models.OutputOptions) (int, int, error) {
+	if opts.Width > 0 && opts.Height > 0 {
+		return opts.Width, opts.Height, nil
+	}
+	probe, err := e.Probe(ctx, videoPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	video := probe.VideoStream()
+	if video == nil {
+		return 0, 0, fmt.Errorf("%s has no video stream", videoPath)
+	}
+	if video.Rotation == 90 || video.Rotation == 270 {
+		return video.Height, video.Width, nil
+	}
+	return video.Width, video.Height, nil
+}
+
+// CheckVideo fails unless ffprobe can read a file and finds a video stream in it
+func (e *Executor) CheckVideo(ctx context.Context, path string) error {
+	probe, err := e.Probe(ctx, path)
+	if err != nil {
+		return err
+	}
+	if probe.VideoStream() == nil {
+		return fmt.Errorf("%s has no video stream", path)
+	}
+	return nil
+}
+
+// checkInterpolationLength rejects motion interpolation when the combined input is longer than the
+// configured limit, since minterpolate is orders of magnitude slower than a plain encode
+func (e *Executor) checkInterpolationLength(ctx context.Context, opts models.OutputOptions, segments []models.VideoSegment) error {
+	if opts.Interpolate == "" || opts.FPS <= 0 || e.maxInterpolateDuration <= 0 {
+		return nil
+	}
+
+	var total float64
+	for _, seg := range segments {
+		if seg.EndTime > 0 {
+			total += seg.EndTime - seg.StartTime
+			continue
+		}
+		duration, err := e.MediaDuration(ctx, seg.FilePath)
+		if err != nil {
+			return err
+		}
+		total += duration - seg.StartTime
+	}
+
+	if total > e.maxInterpolateDuration {
+		return fmt.Errorf("interpolation is limited to %.0f seconds of input, got %.1f seconds", e.maxInterpolateDuration, total)
+	}
+	return nil
+}
+
+// pathSegments wraps input paths as untrimmed segments
+func pathSegments(paths ...string) []models.VideoSegment {
+	segments := make([]models.VideoSegment, len(paths))
+	for i, path := range paths {
+		segments[i] = models.VideoSegment{FilePath: path}
+	}
+	return segments
+}
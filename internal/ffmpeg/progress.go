@@ -0,0 +1,79 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+)
+
+type progressKey struct{}
+
+type durationKey struct{}
+
+// WithProgress returns a context whose FFmpeg commands report how far they are, as a fraction from 0 to 1,
+// to report. Only commands whose output duration is known from probing their inputs report progress; a job
+// running several commands sees each of them go from 0 to 1.
+func WithProgress(ctx context.Context, report func(fraction float64)) context.Context {
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+// withDuration returns a context whose FFmpeg commands are expected to write seconds of output
+func withDuration(ctx context.Context, seconds float64) context.Context {
+	return context.WithValue(ctx, durationKey{}, seconds)
+}
+
+// withInputDuration returns a context whose FFmpeg commands are expected to write as much output as the media
+// files at paths hold together. It returns ctx unchanged if nobody follows the progress or a file cannot be probed.
+func (e *Executor) withInputDuration(ctx context.Context, paths ...string) context.Context {
+	if _, ok := ctx.Value(progressKey{}).(func(float64)); !ok {
+		return ctx
+	}
+	var total float64
+	for _, path := range paths {
+		duration, err := e.MediaDuration(ctx, path)
+		if err != nil || duration <= 0 {
+			return ctx
+		}
+		total += duration
+	}
+	return withDuration(ctx, total)
+}
+
+// progressWriter parses the key=value lines that ffmpeg writes with -progress and reports the output time
+// as a fraction of the expected duration
+type progressWriter struct {
+	duration float64
+	report   func(float64)
+	buf      []byte
+}
+
+// progressWriterFor returns the writer for the -progress output of a command run with ctx, or nil if the
+// command does not report progress
+func progressWriterFor(ctx context.Context) *progressWriter {
+	report, ok := ctx.Value(progressKey{}).(func(float64))
+	if !ok {
+		return nil
+	}
+	duration, _ := ctx.Value(durationKey{}).(float64)
+	if duration <= 0 {
+		return nil
+	}
+	return &progressWriter{duration: duration, report: report}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		line, rest, found := bytes.Cut(w.buf, []byte("\n"))
+		if !found {
+			return len(p), nil
+		}
+		w.buf = rest
+		// out_time_us is the position in the output; out_time_ms is the same value, despite its name
+		if value, ok := bytes.CutPrefix(line, []byte("out_time_us=")); ok {
+			if us, err := strconv.ParseInt(string(bytes.TrimSpace(value)), 10, 64); err == nil && us >= 0 {
+				w.report(min(float64(us)/1e6/w.duration, 1))
+			}
+		}
+	}
+}
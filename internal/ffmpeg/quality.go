@@ -0,0 +1,205 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+
+	"govid/internal/models"
+)
+
+// maxPSNR is reported for identical frames, whose PSNR is infinite
+const maxPSNR = 100.0
+
+var (
+	psnrPattern = regexp.MustCompile(`PSNR y:(\S+) u:(\S+) v:(\S+) average:(\S+) min:(\S+) max:(\S+)`)
+	ssimPattern = regexp.MustCompile(`SSIM Y:(\S+) \(\S+\) U:(\S+) \(\S+\) V:(\S+) \(\S+\) All:(\S+)`)
+)
+
+// vmafLog is the part of the JSON log of libvmaf with the scores pooled over the frames
+type vmafLog struct {
+	PooledMetrics struct {
+		VMAF struct {
+			Min          float64 `json:"min"`
+			Max          float64 `json:"max"`
+			Mean         float64 `json:"mean"`
+			HarmonicMean float64 `json:"harmonic_mean"`
+		} `json:"vmaf"`
+	} `json:"pooled_metrics"`
+}
+
+// MeasureQuality compares a distorted video, such as an encoded output, against its reference frame by frame.
+// The distorted video is scaled to the size of the reference, and both start at 0, so that the frames are
+// matched by their timestamps. Without metrics, it measures all of VMAF, PSNR and SSIM that the binary supports.
+func (e *Executor) MeasureQuality(ctx context.Context, req models.QualityRequest) (*models.QualityResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := e.checkInput(ctx, req.ReferencePath, "video"); err != nil {
+		return nil, fmt.Errorf("reference file: %w", err)
+	}
+	if _, err := e.checkInput(ctx, req.DistortedPath, "video"); err != nil {
+		return nil, fmt.Errorf("distorted file: %w", err)
+	}
+
+	metrics := req.Metrics
+	if len(metrics) == 0 {
+		metrics = []models.QualityMetric{models.QualityPSNR, models.QualitySSIM}
+		if e.checkFilter("libvmaf") == nil {
+			metrics = append([]models.QualityMetric{models.QualityVMAF}, metrics...)
+		}
+	}
+
+	width, height, err := e.FrameSize(ctx, req.ReferencePath, models.OutputOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("reference file: %w", err)
+	}
+
+	// libvmaf writes its pooled scores only to its log
+	var vmafPath string
+	for _, metric := range metrics {
+		if metric != models.QualityVMAF {
+			continue
+		}
+		if err := e.checkFilter("libvmaf"); err != nil {
+			return nil, err
+		}
+		logFile, err := os.CreateTemp("", "vmaf-*.json")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create vmaf log: %w", err)
+		}
+		logFile.Close()
+		vmafPath = logFile.Name()
+		defer os.Remove(vmafPath)
+	}
+
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", req.DistortedPath,
+		"-i", req.ReferencePath,
+		"-filter_complex", qualityGraph(metrics, width, height, vmafPath, e.limits.Threads),
+		"-an",
+		"-f", "null",
+		"-",
+	}
+	stderr, err := e.ExecuteWithOutput(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.QualityResponse{
+		ReferencePath: req.ReferencePath,
+		DistortedPath: req.DistortedPath,
+		Width:         width,
+		Height:        height,
+	}
+	for _, metric := range metrics {
+		switch metric {
+		case models.QualityVMAF:
+			result.VMAF, err = readVMAFLog(vmafPath)
+		case models.QualityPSNR:
+			result.PSNR, err = parsePSNR(stderr)
+		case models.QualitySSIM:
+			result.SSIM, err = parseSSIM(stderr)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// qualityGraph builds the filter graph comparing input 0, the distorted video, with input 1, the reference,
+// once per metric. The scores are logged, and the compared frames are discarded.
+func qualityGraph(metrics []models.QualityMetric, width, height int, vmafPath string, threads int) string {
+	n := len(metrics)
+	var graph strings.Builder
+	fmt.Fprintf(&graph, "[0:v]scale=%d:%d:flags=bicubic,setsar=1,setpts=PTS-STARTPTS,format=yuv420p,split=%d", width, height, n)
+	for i := range n {
+		fmt.Fprintf(&graph, "[d%d]", i)
+	}
+	fmt.Fprintf(&graph, ";[1:v]setsar=1,setpts=PTS-STARTPTS,format=yuv420p,split=%d", n)
+	for i := range n {
+		fmt.Fprintf(&graph, "[r%d]", i)
+	}
+
+	for i, metric := range metrics {
+		fmt.Fprintf(&graph, ";[d%d][r%d]", i, i)
+		switch metric {
+		case models.QualityVMAF:
+			fmt.Fprintf(&graph, "libvmaf=log_fmt=json:log_path=%s", escapeOptionValue(vmafPath))
+			if threads > 0 {
+				fmt.Fprintf(&graph, ":n_threads=%d", threads)
+			}
+		default:
+			graph.WriteString(string(metric))
+		}
+	}
+	return graph.String()
+}
+
+// readVMAFLog reads the pooled scores from the JSON log of libvmaf
+func readVMAFLog(path string) (*models.VMAFScore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vmaf log: %w", err)
+	}
+	var log vmafLog
+	if err := sonic.Unmarshal(data, &log); err != nil {
+		return nil, fmt.Errorf("failed to parse vmaf log: %w", err)
+	}
+	vmaf := log.PooledMetrics.VMAF
+	return &models.VMAFScore{
+		Mean:         vmaf.Mean,
+		HarmonicMean: vmaf.HarmonicMean,
+		Min:          vmaf.Min,
+		Max:          vmaf.Max,
+	}, nil
+}
+
+// parsePSNR reads the summary the psnr filter logs, "PSNR y:... u:... v:... average:... min:... max:..."
+func parsePSNR(stderr string) (*models.PSNRScore, error) {
+	match := psnrPattern.FindStringSubmatch(stderr)
+	if match == nil {
+		return nil, fmt.Errorf("no psnr summary in ffmpeg output")
+	}
+	values := parseScores(match[1:])
+	return &models.PSNRScore{
+		Y:       min(values[0], maxPSNR),
+		U:       min(values[1], maxPSNR),
+		V:       min(values[2], maxPSNR),
+		Average: min(values[3], maxPSNR),
+		Min:     min(values[4], maxPSNR),
+		Max:     min(values[5], maxPSNR),
+	}, nil
+}
+
+// parseSSIM reads the summary the ssim filter logs, "SSIM Y:... (dB) U:... (dB) V:... (dB) All:... (dB)"
+func parseSSIM(stderr string) (*models.SSIMScore, error) {
+	match := ssimPattern.FindStringSubmatch(stderr)
+	if match == nil {
+		return nil, fmt.Errorf("no ssim summary in ffmpeg output")
+	}
+	values := parseScores(match[1:])
+	return &models.SSIMScore{Y: values[0], U: values[1], V: values[2], All: values[3]}, nil
+}
+
+// parseScores parses the numbers of a filter summary, where "inf" stands for identical frames
+func parseScores(fields []string) []float64 {
+	values := make([]float64, len(fields))
+	for i, field := range fields {
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil || math.IsNaN(value) {
+			value = 0
+		}
+		values[i] = value
+	}
+	return values
+}
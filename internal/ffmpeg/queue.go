@@ -0,0 +1,54 @@
+package ffmpeg
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+type startKey struct{}
+
+// WithStart returns a context whose FFmpeg commands call started when they get a slot and start running
+func WithStart(ctx context.Context, started func()) context.Context {
+	return context.WithValue(ctx, startKey{}, started)
+}
+
+// queue holds the IDs of the jobs whose FFmpeg commands wait for a slot, in the order they get one
+type queue struct {
+	jobIDs []string
+	mu     sync.Mutex
+}
+
+// push adds a job at the end of the queue. Commands that do not belong to a job are not listed.
+func (q *queue) push(jobID string) {
+	if jobID == "" {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobIDs = append(q.jobIDs, jobID)
+}
+
+// remove takes a job off the queue once its command got a slot or stopped waiting
+func (q *queue) remove(jobID string) {
+	if jobID == "" {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i := slices.Index(q.jobIDs, jobID); i >= 0 {
+		q.jobIDs = slices.Delete(q.jobIDs, i, i+1)
+	}
+}
+
+// Queue returns the IDs of the jobs waiting for an FFmpeg slot, in the order they get one
+func (e *Executor) Queue() []string {
+	e.queue.mu.Lock()
+	defer e.queue.mu.Unlock()
+	return slices.Clone(e.queue.jobIDs)
+}
+
+// Slots returns how many FFmpeg commands run at once
+func (e *Executor) Slots() int {
+	return e.slots
+}
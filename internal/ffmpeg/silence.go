@@ -0,0 +1,147 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"govid/internal/models"
+)
+
+// Default silencedetect settings used when a request leaves them unset
+const (
+	defaultSilenceNoiseDB     = -30.0
+	defaultSilenceMinDuration = 0.5
+)
+
+// minKeepDuration is the shortest non-silent section kept when removing silences
+const minKeepDuration = 0.05
+
+var (
+	durationPattern     = regexp.MustCompile(`Duration: (\d+):(\d+):(\d+(?:\.\d+)?)`)
+	silenceStartPattern = regexp.MustCompile(`silence_start: (-?\d+(?:\.\d+)?)`)
+	silenceEndPattern   = regexp.MustCompile(`silence_end: (-?\d+(?:\.\d+)?)`)
+)
+
+// DetectSilence reports silent ranges in the audio of a video and its total duration
+func (e *Executor) DetectSilence(ctx context.Context, req models.SilenceRequest) ([]models.SilenceRange, float64, error) {
+	if _, err := e.checkInput(ctx, req.VideoPath, "audio"); err != nil {
+		return nil, 0, fmt.Errorf("video file: %w", err)
+	}
+	if err := req.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	noise, minDuration := defaultSilenceNoiseDB, defaultSilenceMinDuration
+	if req.NoiseDB != nil {
+		noise = *req.NoiseDB
+	}
+	if req.MinDuration != nil {
+		minDuration = *req.MinDuration
+	}
+
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", req.VideoPath,
+		"-vn",
+		"-af", fmt.Sprintf("silencedetect=noise=%.1fdB:d=%.3f", noise, minDuration),
+		"-f", "null",
+		"-",
+	}
+
+	stderr, err := e.ExecuteWithOutput(ctx, args)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	duration := parseDuration(stderr)
+	return parseSilences(stderr, duration), duration, nil
+}
+
+// RemoveSilence produces a copy of the video with all detected silent ranges cut out
+func (e *Executor) RemoveSilence(ctx context.Context, req models.SilenceRequest, outputPath string) error {
+	silences, duration, err := e.DetectSilence(ctx, req)
+	if err != nil {
+		return fmt.Errorf("silence detection: %w", err)
+	}
+
+	segments := nonSilentSegments(req.VideoPath, silences, duration)
+	if len(segments) == 0 {
+		return fmt.Errorf("video contains no non-silent content")
+	}
+
+	return e.concatSegments(ctx, segments, req.OutputOptions, outputPath)
+}
+
+// parseDuration extracts the input duration in seconds from ffmpeg output
+func parseDuration(stderr string) float64 {
+	match := durationPattern.FindStringSubmatch(stderr)
+	if match == nil {
+		return 0
+	}
+
+	hours, _ := strconv.ParseFloat(match[1], 64)
+	minutes, _ := strconv.ParseFloat(match[2], 64)
+	seconds, _ := strconv.ParseFloat(match[3], 64)
+	return hours*3600 + minutes*60 + seconds
+}
+
+// parseSilences pairs silence_start and silence_end lines from silencedetect output.
+// A silence still open at the end of the input is closed at duration.
+func parseSilences(stderr string, duration float64) []models.SilenceRange {
+	starts := silenceStartPattern.FindAllStringSubmatch(stderr, -1)
+	ends := silenceEndPattern.FindAllStringSubmatch(stderr, -1)
+
+	silences := make([]models.SilenceRange, 0, len(starts))
+	for i, match := range starts {
+		start, _ := strconv.ParseFloat(match[1], 64)
+		if start < 0 {
+			start = 0
+		}
+
+		end := duration
+		if i < len(ends) {
+			end, _ = strconv.ParseFloat(ends[i][1], 64)
+		}
+		if end <= start {
+			continue
+		}
+
+		silences = append(silences, models.SilenceRange{
+			Start:    start,
+			End:      end,
+			Duration: end - start,
+		})
+	}
+
+	return silences
+}
+
+// nonSilentSegments returns the sections of the video between the given silences
+func nonSilentSegments(videoPath string, silences []models.SilenceRange, duration float64) []models.VideoSegment {
+	segments := make([]models.VideoSegment, 0, len(silences)+1)
+	cursor := 0.0
+
+	for _, silence := range silences {
+		if silence.Start-cursor >= minKeepDuration {
+			segments = append(segments, models.VideoSegment{
+				FilePath:  videoPath,
+				StartTime: cursor,
+				EndTime:   silence.Start,
+			})
+		}
+		cursor = silence.End
+	}
+
+	// Keep the tail after the last silence; an EndTime of 0 means until the end of the input
+	if duration == 0 || duration-cursor >= minKeepDuration {
+		segments = append(segments, models.VideoSegment{
+			FilePath:  videoPath,
+			StartTime: cursor,
+		})
+	}
+
+	return segments
+}
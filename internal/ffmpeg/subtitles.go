@@ -0,0 +1,88 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+
+	"govid/internal/models"
+	"govid/pkg/transcribe"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// ExtractSpeech writes the audio of a video as the 16 kHz mono WAV file that speech-to-text models read
+func (e *Executor) ExtractSpeech(ctx context.Context, videoPath, outputPath string) error {
+	if _, err := e.checkInput(ctx, videoPath, "audio"); err != nil {
+		return fmt.Errorf("video file: %w", err)
+	}
+
+	args := []string{
+		"-y",
+		"-i", videoPath,
+		"-vn",
+		"-ac", "1",
+		"-ar", "16000",
+		"-c:a", "pcm_s16le",
+		outputPath,
+	}
+
+	return e.Execute(ctx, args)
+}
+
+// BurnSubtitles renders an SRT, VTT or ASS subtitle file into the frames of a video
+func (e *Executor) BurnSubtitles(ctx context.Context, videoPath, subtitlesPath string, opts models.OutputOptions, outputPath string) error {
+	if err := e.checkFilter("subtitles"); err != nil {
+		return err
+	}
+	if err := ValidateFile(subtitlesPath); err != nil {
+		return fmt.Errorf("subtitle file: %w", err)
+	}
+	g, err := e.segmentGraph(ctx, []models.VideoSegment{{FilePath: videoPath}}, opts)
+	if err != nil {
+		return err
+	}
+	g.apply(subtitlesStage(subtitlesPath))
+	return e.runGraph(ctx, g, outputPath, encodeKwArgs(outputPath, opts))
+}
+
+// captionAlignments are the ASS alignments of the caption positions
+var captionAlignments = map[models.CaptionPosition]int{
+	models.CaptionTop:    transcribe.AlignTop,
+	models.CaptionCenter: transcribe.AlignCenter,
+	models.CaptionBottom: transcribe.AlignBottom,
+}
+
+// BurnCaptions draws transcribed cues over a video in a caption style. The captions are written to captionsPath
+// as ASS subtitles laid out for the output frames, then burned in.
+func (e *Executor) BurnCaptions(ctx context.Context, videoPath string, cues []transcribe.Cue, style models.CaptionStyle, captionsPath string, opts models.OutputOptions, outputPath string) error {
+	width, height, err := e.FrameSize(ctx, videoPath, opts)
+	if err != nil {
+		return fmt.Errorf("video file: %w", err)
+	}
+	style = style.WithDefaults()
+	color := func(name string) string {
+		hex, _ := models.CaptionColor(name)
+		return hex
+	}
+	assStyle := transcribe.Style{
+		Font:           style.Font,
+		FontSize:       style.FontSize,
+		Bold:           style.Bold,
+		Color:          color(style.FontColor),
+		OutlineColor:   color(style.OutlineColor),
+		HighlightColor: color(style.HighlightColor),
+		Alignment:      captionAlignments[style.Position],
+		Karaoke:        style.Karaoke,
+		Width:          width,
+		Height:         height,
+	}
+	if err := transcribe.WriteASSFile(captionsPath, transcribe.SplitCues(cues, style.MaxWords), assStyle); err != nil {
+		return err
+	}
+	return e.BurnSubtitles(ctx, videoPath, captionsPath, opts, outputPath)
+}
+
+// burnSubtitles renders a subtitle file over a video stream with the libass subtitles filter
+func burnSubtitles(stream *ffmpeg.Stream, subtitlesPath string) *ffmpeg.Stream {
+	return stream.Filter("subtitles", ffmpeg.Args{}, ffmpeg.KwArgs{"filename": escapeOptionValue(subtitlesPath)})
+}
@@ -0,0 +1,90 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"govid/internal/models"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// Default text overlay font settings
+const (
+	defaultFontSize  = 48
+	defaultFontColor = "white"
+)
+
+// AddText draws text over a video
+func (e *Executor) AddText(ctx context.Context, videoPath string, text models.TextOverlay, opts models.OutputOptions, outputPath string) error {
+	if err := e.checkFilter("drawtext"); err != nil {
+		return err
+	}
+	g, err := e.segmentGraph(ctx, []models.VideoSegment{{FilePath: videoPath}}, opts)
+	if err != nil {
+		return err
+	}
+	g.apply(textStage(text))
+	return e.runGraph(ctx, g, outputPath, encodeKwArgs(outputPath, opts))
+}
+
+// drawText draws text over a video stream with drawtext. Expansion is off, so % in the text is drawn as is.
+func drawText(stream *ffmpeg.Stream, text models.TextOverlay) *ffmpeg.Stream {
+	fontSize := defaultFontSize
+	if text.FontSize > 0 {
+		fontSize = text.FontSize
+	}
+	fontColor := defaultFontColor
+	if text.FontColor != "" {
+		fontColor = text.FontColor
+	}
+	x, y := textPosition(text)
+
+	kwArgs := ffmpeg.KwArgs{
+		"text":      escapeOptionValue(text.Text),
+		"expansion": "none",
+		"fontsize":  fontSize,
+		"fontcolor": fontColor,
+		"x":         x,
+		"y":         y,
+	}
+	if text.FontFile != "" {
+		kwArgs["fontfile"] = escapeOptionValue(text.FontFile)
+	}
+	if text.EndTime > 0 {
+		kwArgs["enable"] = fmt.Sprintf("between(t,%.2f,%.2f)", text.StartTime, text.EndTime)
+	} else if text.StartTime > 0 {
+		kwArgs["enable"] = fmt.Sprintf("gte(t,%.2f)", text.StartTime)
+	}
+	return stream.Filter("drawtext", ffmpeg.Args{}, kwArgs)
+}
+
+// optionValueEscaper escapes the characters special in filter option values. ffmpeg-go escapes the filter
+// graph but not the option values, so values with a colon or quote would otherwise end the option.
+var optionValueEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`)
+
+// escapeOptionValue escapes a free-form filter option value, such as the text of drawtext
+func escapeOptionValue(value string) string {
+	return optionValueEscaper.Replace(value)
+}
+
+// textPosition calculates the x,y position of text, like calculatePosition does for images
+func textPosition(text models.TextOverlay) (string, string) {
+	if text.Position == models.PositionCustom && text.X != nil && text.Y != nil {
+		return fmt.Sprintf("%d", *text.X), fmt.Sprintf("%d", *text.Y)
+	}
+
+	switch text.Position {
+	case models.PositionTopRight:
+		return "w-text_w-10", "10"
+	case models.PositionBottomLeft:
+		return "10", "h-text_h-10"
+	case models.PositionBottomRight:
+		return "w-text_w-10", "h-text_h-10"
+	case models.PositionCenter:
+		return "(w-text_w)/2", "(h-text_h)/2"
+	default:
+		return "10", "10" // Default to top-left
+	}
+}
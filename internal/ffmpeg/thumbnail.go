@@ -0,0 +1,25 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// ExtractThumbnail writes the frame at the given time (in seconds) of a video as a JPEG poster image
+func (e *Executor) ExtractThumbnail(ctx context.Context, videoPath string, at float64, outputPath string) error {
+	if err := ValidateFile(videoPath); err != nil {
+		return fmt.Errorf("input file: %w", err)
+	}
+
+	args := []string{
+		"-y",
+		"-ss", strconv.FormatFloat(at, 'f', 3, 64),
+		"-i", videoPath,
+		"-frames:v", "1",
+		"-q:v", "2",
+		outputPath,
+	}
+
+	return e.Execute(ctx, args)
+}
@@ -0,0 +1,41 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+
+	"govid/internal/models"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// ConvertToVertical converts a video to a vertical frame by scaling it to fit and filling the
+// remaining space with a blurred, zoomed copy of itself (or a solid background color)
+func (e *Executor) ConvertToVertical(ctx context.Context, req models.VerticalRequest, outputPath string) error {
+	if _, err := e.checkInput(ctx, req.VideoPath, "video"); err != nil {
+		return fmt.Errorf("video file: %w", err)
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	if err := e.checkInterpolationLength(ctx, req.OutputOptions, pathSegments(req.VideoPath)); err != nil {
+		return err
+	}
+	ctx = e.withInputDuration(ctx, req.VideoPath)
+
+	opts := req.VerticalOptions()
+	input := ffmpeg.Input(req.VideoPath)
+
+	// Audio is optional so silent clips can be converted too
+	output := ffmpeg.Output(
+		[]*ffmpeg.Stream{filterVideo(input.Video(), opts), input.Get("a?")},
+		outputPath,
+		ffmpeg.MergeKwArgs([]ffmpeg.KwArgs{
+			videoEncodeKwArgs(outputPath, opts),
+			copyAudioKwArgs(outputPath),
+			metadataKwArgs(opts),
+		}),
+	).OverWriteOutput()
+
+	return e.runStream(ctx, output)
+}
@@ -5,30 +5,68 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
 	"govid/internal/models"
+	"govid/pkg/logger"
 
 	ffmpeg "github.com/u2takey/ffmpeg-go"
 )
 
 // MergeVideos merges multiple video segments with custom timeframes
-func (e *Executor) MergeVideos(ctx context.Context, segments []models.VideoSegment, outputPath string) error {
+func (e *Executor) MergeVideos(ctx context.Context, segments []models.VideoSegment, opts models.OutputOptions, outputPath string) error {
 	if len(segments) < 2 {
 		return fmt.Errorf("at least 2 video segments required for merging")
 	}
 
-	// Validate all input files
+	return e.concatSegments(ctx, segments, opts, outputPath)
+}
+
+// Transcode re-encodes a segment of a video with opts, trimming it to its timeframe
+func (e *Executor) Transcode(ctx context.Context, segment models.VideoSegment, opts models.OutputOptions, outputPath string) error {
+	return e.concatSegments(ctx, []models.VideoSegment{segment}, opts, outputPath)
+}
+
+// concatSegments trims each segment and concatenates them into a single re-encoded output
+func (e *Executor) concatSegments(ctx context.Context, segments []models.VideoSegment, opts models.OutputOptions, outputPath string) error {
+	g, err := e.segmentGraph(ctx, segments, opts)
+	if err != nil {
+		return err
+	}
+	return e.runGraph(ctx, g, outputPath, encodeKwArgs(outputPath, opts))
+}
+
+// segmentGraph starts a graph that trims each segment, normalizes it to the output resolution and frame rate,
+// and concatenates the segments
+func (e *Executor) segmentGraph(ctx context.Context, segments []models.VideoSegment, opts models.OutputOptions) (*graph, error) {
+	// Probe every segment up front, failing before the encode if one cannot be used
+	infos := make([]*models.MediaProbe, len(segments))
 	for i, seg := range segments {
-		if err := ValidateFile(seg.FilePath); err != nil {
-			return fmt.Errorf("segment %d: %w", i, err)
+		info, err := e.checkInput(ctx, seg.FilePath, "video")
+		if err != nil {
+			return nil, fmt.Errorf("segment %d: %w", i, err)
 		}
+		infos[i] = info
+	}
+	if err := e.checkInterpolationLength(ctx, opts, segments); err != nil {
+		return nil, err
+	}
+
+	// The concat filter needs identical stream parameters, so every segment is normalized to a common target
+	target := concatTarget(infos[0], opts)
+
+	// Progress is measured against the combined length of the trimmed segments
+	var total float64
+	for i, seg := range segments {
+		total += segmentDuration(seg, infos[i])
 	}
 
 	// Process each segment with trim and setpts
 	streams := make([]*ffmpeg.Stream, 0, len(segments)*2)
 
-	for _, seg := range segments {
+	for i, seg := range segments {
 		input := ffmpeg.Input(seg.FilePath)
 
 		// Trim video stream
@@ -48,9 +86,11 @@ func (e *Executor) MergeVideos(ctx context.Context, segments []models.VideoSegme
 			}
 		}
 
-		// Trim audio stream
+		// Trim audio stream, or generate silence for segments without audio so concat always has a=1 inputs
 		var audioStream *ffmpeg.Stream
-		if seg.EndTime > 0 {
+		if infos[i].AudioStream() == nil {
+			audioStream = silentAudio(segmentDuration(seg, infos[i]))
+		} else if seg.EndTime > 0 {
 			audioStream = input.Audio().Filter("atrim", ffmpeg.Args{}, ffmpeg.KwArgs{
 				"start": seg.StartTime,
 				"end":   seg.EndTime,
@@ -65,30 +105,104 @@ func (e *Executor) MergeVideos(ctx context.Context, segments []models.VideoSegme
 			}
 		}
 
+		// Normalize resolution, SAR, frame rate and formats so mixed clips can be concatenated
+		videoStream = filterVideo(videoStream, target)
+		if target.PixFmt != "" {
+			videoStream = videoStream.Filter("format", ffmpeg.Args{target.PixFmt})
+		}
+		audioStream = audioStream.Filter("aformat", ffmpeg.Args{}, ffmpeg.KwArgs{
+			"sample_rates":    concatSampleRate,
+			"channel_layouts": "stereo",
+		})
+
 		streams = append(streams, videoStream, audioStream)
 	}
 
-	// Concatenate all streams
-	output := ffmpeg.Concat(streams, ffmpeg.KwArgs{
+	// Concatenate all streams; the concat filter outputs the video first, then the audio
+	concat := ffmpeg.FilterMultiOutput(streams, "concat", ffmpeg.Args{}, ffmpeg.KwArgs{
 		"n": len(segments),
 		"v": 1,
 		"a": 1,
-	}).Output(outputPath, ffmpeg.KwArgs{
-		"c:v":    "libx264",
-		"preset": "medium",
-		"crf":    "23",
-		"c:a":    "aac",
-		"b:a":    "192k",
-	}).OverWriteOutput()
-
-	return output.Run()
+	})
+
+	return &graph{video: concat.Get("0"), audio: concat.Get("1"), duration: total}, nil
+}
+
+// concatSampleRate is the audio sample rate every segment is resampled to before concatenation
+const concatSampleRate = 48000
+
+// concatTarget returns the options every segment is normalized to before concatenation. Resolution,
+// frame rate and pixel format come from the request when set and from the first segment otherwise.
+func concatTarget(first *models.MediaProbe, opts models.OutputOptions) models.OutputOptions {
+	target := opts
+	if first == nil || first.VideoStream() == nil {
+		return target
+	}
+	video := first.VideoStream()
+
+	if !hasResize(target) && video.Width > 0 && video.Height > 0 {
+		// Scaled sizes must be even for yuv420p encoding
+		target.Width, target.Height = video.Width&^1, video.Height&^1
+		target.Fit, target.Background = models.FitContain, ""
+	}
+	if fps := video.FrameRate; target.FPS == 0 && fps >= 1 && fps <= 240 {
+		target.FPS = fps
+	}
+	if target.PixFmt == "" {
+		target.PixFmt = video.PixFmt
+	}
+
+	return target
+}
+
+// segmentDuration returns the length of a segment after trimming
+func segmentDuration(seg models.VideoSegment, info *models.MediaProbe) float64 {
+	if seg.EndTime > 0 {
+		return seg.EndTime - seg.StartTime
+	}
+	return max(info.Duration-seg.StartTime, 0)
+}
+
+// silentAudio generates a silent stereo track of the given duration in seconds
+func silentAudio(duration float64) *ffmpeg.Stream {
+	return ffmpeg.Input(fmt.Sprintf("anullsrc=channel_layout=stereo:sample_rate=%d", concatSampleRate), ffmpeg.KwArgs{
+		"f": "lavfi",
+		"t": fmt.Sprintf("%.3f", duration),
+	}).Audio()
+}
+
+// parseFrameRate converts an ffprobe rational frame rate such as 30000/1001 to frames per second.
+// It returns 0 when the rate is missing or invalid.
+func parseFrameRate(rate string) float64 {
+	num, den, found := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !found {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
 }
 
 // MergeVideosSimple merges videos without timeframe trimming (concatenation only)
-func (e *Executor) MergeVideosSimple(ctx context.Context, inputPaths []string, outputPath string) error {
+func (e *Executor) MergeVideosSimple(ctx context.Context, inputPaths []string, opts models.OutputOptions, outputPath string) error {
 	if len(inputPaths) < 2 {
 		return fmt.Errorf("at least 2 video files required for merging")
 	}
+	for i, path := range inputPaths {
+		if _, err := e.checkInput(ctx, path, "video"); err != nil {
+			return fmt.Errorf("video %d: %w", i, err)
+		}
+	}
+	if err := e.checkInterpolationLength(ctx, opts, pathSegments(inputPaths...)); err != nil {
+		return err
+	}
+	ctx = e.withInputDuration(ctx, inputPaths...)
 
 	// Create temporary concat file list
 	concatFile, err := os.CreateTemp("", "concat-*.txt")
@@ -114,16 +228,90 @@ func (e *Executor) MergeVideosSimple(ctx context.Context, inputPaths []string, o
 	concatFile.Close()
 
 	// Use concat demuxer protocol
-	output := ffmpeg.Input(concatFile.Name(), ffmpeg.KwArgs{
+	input := ffmpeg.Input(concatFile.Name(), ffmpeg.KwArgs{
 		"f":    "concat",
 		"safe": "0",
-	}).Output(outputPath, ffmpeg.KwArgs{
-		"c:v":    "libx264",
-		"preset": "medium",
-		"crf":    "23",
-		"c:a":    "aac",
-		"b:a":    "192k",
-	}).OverWriteOutput()
-
-	return output.Run()
+	})
+
+	var output *ffmpeg.Stream
+	switch {
+	case e.streamCopyCompatible(ctx, inputPaths, opts, outputPath):
+		logger.FromContext(ctx).Info("Inputs share codecs and stream parameters, concatenating %d files with stream copy", len(inputPaths))
+		output = input.Output(outputPath, ffmpeg.MergeKwArgs([]ffmpeg.KwArgs{{"c": "copy"}, metadataKwArgs(opts)}))
+	case hasVideoFilters(opts):
+		output = ffmpeg.Output([]*ffmpeg.Stream{filterVideo(input.Video(), opts), input.Audio()}, outputPath, encodeKwArgs(outputPath, opts))
+	default:
+		output = input.Output(outputPath, encodeKwArgs(outputPath, opts))
+	}
+
+	return e.runStream(ctx, output.OverWriteOutput())
+}
+
+// copyCodecs lists the codecs each container accepts for stream copy; containers not listed accept any codec
+var copyCodecs = map[string]struct{ video, audio []string }{
+	".mp4":  {video: []string{"h264", "hevc", "av1", "mpeg4"}, audio: []string{"aac", "mp3", "ac3", "eac3", "opus"}},
+	".mov":  {video: []string{"h264", "hevc", "mpeg4", "prores"}, audio: []string{"aac", "mp3", "ac3", "alac", "pcm_s16le"}},
+	".webm": {video: []string{"vp8", "vp9", "av1"}, audio: []string{"opus", "vorbis"}},
+}
+
+// streamCopyCompatible reports whether the inputs can be concatenated without re-encoding: every input must
+// share the same codecs and stream parameters, the output container must accept those codecs, and no option
+// that requires encoding may be set. Probe failures fall back to re-encoding.
+func (e *Executor) streamCopyCompatible(ctx context.Context, inputPaths []string, opts models.OutputOptions, outputPath string) bool {
+	if hasVideoFilters(opts) || opts.CRF != nil || opts.Preset != "" || opts.Profile != "" || opts.Level != "" || opts.PixFmt != "" {
+		return false
+	}
+
+	var first *models.MediaProbe
+	for _, path := range inputPaths {
+		info, err := e.Probe(ctx, path)
+		if err != nil {
+			logger.FromContext(ctx).Warn("Falling back to re-encoding merge: %v", err)
+			return false
+		}
+		video, audio := info.VideoStream(), info.AudioStream()
+		if video == nil {
+			return false
+		}
+
+		if first == nil {
+			first = info
+			if codecs, limited := copyCodecs[strings.ToLower(filepath.Ext(outputPath))]; limited {
+				if !slices.Contains(codecs.video, video.Codec) {
+					return false
+				}
+				if audio != nil && !slices.Contains(codecs.audio, audio.Codec) {
+					return false
+				}
+			}
+			continue
+		}
+
+		if !sameVideoParams(first.VideoStream(), video) || !sameAudioParams(first.AudioStream(), audio) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sameVideoParams reports whether two video streams can be joined without re-encoding
+func sameVideoParams(a, b *models.MediaStream) bool {
+	return a.Codec == b.Codec &&
+		a.Width == b.Width &&
+		a.Height == b.Height &&
+		a.PixFmt == b.PixFmt &&
+		a.SampleAspectRatio == b.SampleAspectRatio &&
+		a.FrameRate == b.FrameRate &&
+		a.Rotation == b.Rotation
+}
+
+// sameAudioParams reports whether two audio streams (or their absence) can be joined without re-encoding
+func sameAudioParams(a, b *models.MediaStream) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Codec == b.Codec &&
+		a.SampleRate == b.SampleRate &&
+		a.Channels == b.Channels
 }
@@ -0,0 +1,101 @@
+package fingerprint
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// Size of the gray frames a video is hashed from: each row of 9 pixels gives 8 differences, and 8 rows give
+// the 64 bits of a hash
+const (
+	FrameWidth  = 9
+	FrameHeight = 8
+)
+
+// MaxSeconds bounds the part of a video that is hashed, from its start, which keeps comparisons fast
+const MaxSeconds = 600
+
+// Fingerprint is the perceptual hashes of the frames of a video, one per second. Each is a difference hash
+// (dHash) of the frame scaled down to 9x8 gray pixels, whose bits tell which pixels are brighter than their
+// right neighbor. Re-encoding, resizing and light color changes flip few bits.
+type Fingerprint []uint64
+
+// FromFrames hashes raw 8-bit gray frames of FrameWidth x FrameHeight pixels
+func FromFrames(data []byte) Fingerprint {
+	const frameSize = FrameWidth * FrameHeight
+	f := make(Fingerprint, 0, len(data)/frameSize)
+	for len(data) >= frameSize {
+		var hash uint64
+		for y := range FrameHeight {
+			row := data[y*FrameWidth : (y+1)*FrameWidth]
+			for x := range FrameWidth - 1 {
+				hash <<= 1
+				if row[x] > row[x+1] {
+					hash |= 1
+				}
+			}
+		}
+		f = append(f, hash)
+		data = data[frameSize:]
+	}
+	return f
+}
+
+// String encodes the fingerprint as base64 of its hashes, big-endian
+func (f Fingerprint) String() string {
+	data := make([]byte, 0, 8*len(f))
+	for _, hash := range f {
+		data = binary.BigEndian.AppendUint64(data, hash)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// Parse decodes a fingerprint encoded by String
+func Parse(s string) (Fingerprint, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(data)%8 != 0 {
+		return nil, fmt.Errorf("invalid fingerprint")
+	}
+	f := make(Fingerprint, len(data)/8)
+	for i := range f {
+		f[i] = binary.BigEndian.Uint64(data[8*i:])
+	}
+	return f, nil
+}
+
+// Match is the best alignment of two fingerprints
+type Match struct {
+	Similarity float64 // share of equal hash bits over the compared seconds, from 0 to 1; unrelated videos score around 0.5
+	Offset     int     // seconds into the first video at which the second starts, negative if it starts before
+	Overlap    int     // seconds compared
+}
+
+// Compare slides b along a and returns the alignment whose hashes are most alike, so that a video matches a
+// trimmed or extended copy of itself. Alignments covering less than half of the shorter video are not
+// considered. Flat frames, such as black ones, hash to 0 whatever their content, so they are not compared.
+func Compare(a, b Fingerprint) Match {
+	var best Match
+	minOverlap := max(1, (min(len(a), len(b))+1)/2)
+	for offset := minOverlap - len(b); offset <= len(a)-minOverlap; offset++ {
+		start, end := max(0, -offset), min(len(b), len(a)-offset)
+		equal, compared := 0, 0
+		for j := start; j < end; j++ {
+			x, y := a[j+offset], b[j]
+			if x == 0 || y == 0 {
+				continue
+			}
+			equal += 64 - bits.OnesCount64(x^y)
+			compared++
+		}
+		if end-start < minOverlap || compared == 0 {
+			continue
+		}
+		similarity := float64(equal) / float64(64*compared)
+		if similarity > best.Similarity || (similarity == best.Similarity && end-start > best.Overlap) {
+			best = Match{Similarity: similarity, Offset: offset, Overlap: end - start}
+		}
+	}
+	return best
+}
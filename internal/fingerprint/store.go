@@ -0,0 +1,192 @@
+package fingerprint
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+
+	"govid/internal/models"
+	"govid/pkg/logger"
+)
+
+// ErrNotFound is returned when no saved fingerprint has the requested ID
+var ErrNotFound = errors.New("fingerprint not found")
+
+// idPattern matches the IDs of saved fingerprints, which name their files
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+
+// Store holds the saved fingerprints, one JSON file per fingerprint in a directory. Instances sharing the
+// directory see each other's fingerprints: lookups read the files added since the last one.
+type Store struct {
+	dir     string
+	entries map[string]savedEntry // by ID
+	mu      sync.Mutex
+}
+
+// savedEntry is a saved fingerprint with its hashes decoded
+type savedEntry struct {
+	models.FingerprintEntry
+	hashes Fingerprint
+}
+
+// NewStore creates a store of the fingerprints in dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, entries: make(map[string]savedEntry)}
+}
+
+// Add saves the fingerprint of a video and returns its entry
+func (s *Store) Add(f Fingerprint, filePath, name, createdBy string) (models.FingerprintEntry, error) {
+	entry := models.FingerprintEntry{
+		ID:          uuid.New().String(),
+		Name:        name,
+		FilePath:    filePath,
+		Seconds:     len(f),
+		Fingerprint: f.String(),
+		CreatedBy:   createdBy,
+		CreatedAt:   time.Now().UTC(),
+	}
+	content, err := sonic.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return entry, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := s.path(entry.ID)
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, content, 0o644); err != nil {
+		return entry, fmt.Errorf("failed to save fingerprint: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return entry, fmt.Errorf("failed to save fingerprint: %w", err)
+	}
+	s.entries[entry.ID] = savedEntry{FingerprintEntry: entry, hashes: f}
+	return entry, nil
+}
+
+// Search compares a fingerprint with the saved ones and returns those at least threshold similar, most
+// similar first
+func (s *Store) Search(f Fingerprint, threshold float64) ([]models.FingerprintMatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	matches := make([]models.FingerprintMatch, 0)
+	for _, entry := range s.entries {
+		match := Compare(entry.hashes, f)
+		if match.Similarity < threshold {
+			continue
+		}
+		matches = append(matches, models.FingerprintMatch{
+			ID:         entry.ID,
+			Name:       entry.Name,
+			FilePath:   entry.FilePath,
+			Similarity: match.Similarity,
+			Offset:     match.Offset,
+			Overlap:    match.Overlap,
+		})
+	}
+	slices.SortFunc(matches, func(a, b models.FingerprintMatch) int { return cmp.Compare(b.Similarity, a.Similarity) })
+	return matches, nil
+}
+
+// List returns the saved fingerprints without their hashes, newest first
+func (s *Store) List() ([]models.FingerprintEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	list := make([]models.FingerprintEntry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		listed := entry.FingerprintEntry
+		listed.Fingerprint = ""
+		list = append(list, listed)
+	}
+	slices.SortFunc(list, func(a, b models.FingerprintEntry) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return list, nil
+}
+
+// Delete removes a saved fingerprint
+func (s *Store) Delete(id string) error {
+	if !idPattern.MatchString(id) {
+		return ErrNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(id)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to delete fingerprint: %w", err)
+	}
+	delete(s.entries, id)
+	return nil
+}
+
+// refresh loads the files added to the directory since the last call and forgets those removed. A file that
+// cannot be read is skipped. Callers must hold mu.
+func (s *Store) refresh() error {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read fingerprints directory: %w", err)
+	}
+
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		id, ok := strings.CutSuffix(file.Name(), ".json")
+		if file.IsDir() || !ok || !idPattern.MatchString(id) {
+			continue
+		}
+		present[id] = true
+		if _, loaded := s.entries[id]; loaded {
+			continue
+		}
+		entry, err := readEntry(filepath.Join(s.dir, file.Name()))
+		if err != nil {
+			logger.Warn("Skipping fingerprint %s: %v", file.Name(), err)
+			continue
+		}
+		s.entries[id] = entry
+	}
+	for id := range s.entries {
+		if !present[id] {
+			delete(s.entries, id)
+		}
+	}
+	return nil
+}
+
+// readEntry reads a saved fingerprint file
+func readEntry(path string) (savedEntry, error) {
+	var entry savedEntry
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return entry, err
+	}
+	if err := sonic.Unmarshal(content, &entry.FingerprintEntry); err != nil {
+		return entry, err
+	}
+	entry.hashes, err = Parse(entry.Fingerprint)
+	return entry, err
+}
+
+// path returns the file of the fingerprint with id
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
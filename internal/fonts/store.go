@@ -0,0 +1,202 @@
+package fonts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"govid/internal/models"
+)
+
+var (
+	// ErrNotFound is returned when no font has the requested name
+	ErrNotFound = errors.New("font not found")
+	// ErrStorage is returned when a font cannot be written to or removed from the fonts directory
+	ErrStorage = errors.New("failed to store font")
+)
+
+// namePattern restricts font names to characters that are safe in URLs, file names and filter options
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// extensions are the extensions of font files, the first found used when a font has several files
+var extensions = []string{".ttf", ".otf", ".ttc"}
+
+// signatures are the first bytes of the font files accepted, with the extension they are stored with
+var signatures = []struct {
+	magic []byte
+	ext   string
+}{
+	{[]byte{0x00, 0x01, 0x00, 0x00}, ".ttf"},
+	{[]byte("true"), ".ttf"},
+	{[]byte("OTTO"), ".otf"},
+	{[]byte("ttcf"), ".ttc"},
+}
+
+// Store holds the fonts text overlays can be drawn with, one file per font in a directory, named after the
+// font. The directory is read on each call, so instances sharing it see each other's fonts, and fonts copied
+// there by hand are found as well.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewStore creates a store of the fonts in dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Put creates or replaces the font with the given name from the content of a TrueType or OpenType file, read
+// up to maxSize bytes
+func (s *Store) Put(name string, content io.Reader, maxSize int64) (models.Font, error) {
+	if !namePattern.MatchString(name) {
+		return models.Font{}, fmt.Errorf("name must be 1-64 letters, digits, '.', '_', or '-'")
+	}
+	data, err := io.ReadAll(io.LimitReader(content, maxSize+1))
+	if err != nil {
+		return models.Font{}, fmt.Errorf("failed to read font: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return models.Font{}, fmt.Errorf("font exceeds the limit of %d MB", maxSize>>20)
+	}
+	ext := fontExtension(data)
+	if ext == "" {
+		return models.Font{}, fmt.Errorf("file is not a TrueType or OpenType font")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := filepath.Join(s.dir, name+ext)
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0o644); err != nil {
+		return models.Font{}, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return models.Font{}, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	// A font replaced by one of another format leaves no file behind
+	for _, other := range s.files(name) {
+		if other != path {
+			os.Remove(other)
+		}
+	}
+	font, err := fontInfo(path)
+	if err != nil {
+		return font, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	return font, nil
+}
+
+// Path returns the file of the font with the given name
+func (s *Store) Path(name string) (string, error) {
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := s.files(name)
+	if len(files) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return files[0], nil
+}
+
+// List returns the fonts sorted by name
+func (s *Store) List() ([]models.Font, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fonts directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if name, ok := fontName(entry.Name()); ok && !entry.IsDir() && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	fonts := make([]models.Font, 0, len(names))
+	for _, name := range names {
+		if files := s.files(name); len(files) > 0 {
+			if font, err := fontInfo(files[0]); err == nil {
+				fonts = append(fonts, font)
+			}
+		}
+	}
+	return fonts, nil
+}
+
+// Delete removes the font with the given name
+func (s *Store) Delete(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := s.files(name)
+	if len(files) == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	for _, path := range files {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("%w: %v", ErrStorage, err)
+		}
+	}
+	return nil
+}
+
+// files returns the files of the font with the given name, in the order of extensions. Callers must hold mu.
+func (s *Store) files(name string) []string {
+	var files []string
+	for _, ext := range extensions {
+		path := filepath.Join(s.dir, name+ext)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// fontExtension returns the extension of a font file with the given content, or "" if it is not a font
+func fontExtension(data []byte) string {
+	for _, signature := range signatures {
+		if bytes.HasPrefix(data, signature.magic) {
+			return signature.ext
+		}
+	}
+	return ""
+}
+
+// fontName returns the name of the font stored in a file, if the file is one
+func fontName(filename string) (string, bool) {
+	ext := filepath.Ext(filename)
+	if !slices.Contains(extensions, ext) {
+		return "", false
+	}
+	name := strings.TrimSuffix(filename, ext)
+	return name, namePattern.MatchString(name)
+}
+
+// fontInfo describes the font stored in a file
+func fontInfo(path string) (models.Font, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return models.Font{}, err
+	}
+	name, _ := fontName(info.Name())
+	return models.Font{
+		Name:      name,
+		Format:    strings.TrimPrefix(filepath.Ext(path), "."),
+		Size:      info.Size(),
+		UpdatedAt: info.ModTime().UTC(),
+	}, nil
+}
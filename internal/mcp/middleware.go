@@ -1,6 +1,7 @@
 package mcp
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net/http"
 
@@ -9,12 +10,12 @@ import (
 )
 
 // AuthMiddleware creates HTTP middleware for MCP server authentication
-func AuthMiddleware(validator *auth.Validator) func(http.Handler) http.Handler {
+func AuthMiddleware(keys *auth.KeyStore) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
 
-			if err := validator.ValidateToken(authHeader); err != nil {
+			if _, err := keys.LookupBearer(authHeader); err != nil {
 				logger.Warn("MCP authentication failed: %v", err)
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusUnauthorized)
@@ -27,6 +28,26 @@ func AuthMiddleware(validator *auth.Validator) func(http.Handler) http.Handler {
 	}
 }
 
+// BodyLimitMiddleware limits MCP request bodies to the base64 encoding of maxUploadBytes, plus room for the
+// JSON-RPC envelope. Larger announced bodies get 413 before they are read; others fail once they pass the limit.
+func BodyLimitMiddleware(maxUploadBytes int64) func(http.Handler) http.Handler {
+	limit := base64.StdEncoding.EncodedLen(int(maxUploadBytes)) + 1<<20
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > int64(limit) {
+				logger.Warn("MCP request body of %d bytes rejected", r.ContentLength)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				fmt.Fprintf(w, `{"error":"Request too large","message":"request body of %d bytes exceeds the limit of %d MB of files (MAX_UPLOAD_SIZE_MB)"}`, r.ContentLength, maxUploadBytes>>20)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, int64(limit))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // LoggingMiddleware logs incoming MCP requests
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,144 @@
+package mcp
+
+import (
+	"fmt"
+
+	"github.com/bytedance/sonic"
+)
+
+// JSON schemas for the structured tool parameters. Field names match the JSON tags of the models
+// the arguments are decoded into.
+
+func stringProperty(description string, enum ...string) map[string]any {
+	schema := map[string]any{"type": "string", "description": description}
+	if len(enum) > 0 {
+		schema["enum"] = enum
+	}
+	return schema
+}
+
+func numberProperty(description string) map[string]any {
+	return map[string]any{"type": "number", "description": description}
+}
+
+func objectSchema(properties map[string]any, required ...string) map[string]any {
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+var segmentProperties = map[string]any{
+	"file_path":  stringProperty("Path to the video file"),
+	"start_time": numberProperty("Segment start in seconds (default 0)"),
+	"end_time":   numberProperty("Segment end in seconds; 0 means end of video"),
+}
+
+var overlayProperties = map[string]any{
+	"file_path":            stringProperty("Path to the image file (required)"),
+	"position":             stringProperty("Overlay position (default top-left)", "top-left", "top-right", "bottom-left", "bottom-right", "center", "custom"),
+	"x":                    numberProperty("X offset in pixels; required for custom position"),
+	"y":                    numberProperty("Y offset in pixels; required for custom position"),
+	"start_time":           numberProperty("When the overlay appears, in seconds"),
+	"end_time":             numberProperty("When the overlay disappears, in seconds (required)"),
+	"animation":            stringProperty("Entrance animation (default none)", "fade", "slide", "zoom", "none"),
+	"fade_duration":        numberProperty("Fade in/out duration in seconds"),
+	"slide_direction":      stringProperty("Direction the overlay slides in from", "left", "right", "top", "bottom"),
+	"slide_duration":       numberProperty("Slide duration in seconds"),
+	"zoom_from":            numberProperty("Initial zoom level"),
+	"zoom_to":              numberProperty("Final zoom level"),
+	"easing":               stringProperty("Curve of slide and zoom motion (default linear)", "linear", "ease-in", "ease-out", "ease-in-out", "bounce"),
+	"exit_animation":       stringProperty("Exit animation ending at end_time (default fade after a fade animation, otherwise none)", "fade", "slide", "none"),
+	"exit_direction":       stringProperty("Direction the overlay slides out to (default slide_direction, or left)", "left", "right", "top", "bottom"),
+	"exit_duration":        numberProperty("Exit animation duration in seconds (default fade_duration or slide_duration, or 1)"),
+	"width":                numberProperty("Width of the image in pixels, keeping its aspect ratio; with height, the image fits within both"),
+	"height":               numberProperty("Height of the image in pixels, keeping its aspect ratio; with width, the image fits within both"),
+	"scale_percent":        numberProperty("Size of the image as a percentage of its own, instead of width and height"),
+	"opacity":              numberProperty("Opacity of the image, 0 to 1 (default 1), such as 0.5 for a watermark"),
+	"rotation_degrees":     numberProperty("Clockwise rotation of the image in degrees, or its starting angle with rotation_end_degrees"),
+	"rotation_end_degrees": numberProperty("Angle the image turns to by end_time, such as 360 for one spin"),
+	"loop":                 map[string]any{"type": "boolean", "description": "Play an animated GIF or video overlay over and over until end_time, instead of once"},
+}
+
+var duckProperties = map[string]any{
+	"threshold": numberProperty("Sidechain level that triggers ducking, 0.001 to 1 (default 0.05)"),
+	"ratio":     numberProperty("Compression ratio, 1 to 20 (default 8)"),
+	"attack":    numberProperty("Attack time in milliseconds, 0.01 to 2000 (default 20)"),
+	"release":   numberProperty("Release time in milliseconds, 0.01 to 9000 (default 300)"),
+}
+
+var audioProperties = map[string]any{
+	"file_path":  stringProperty("Path to the music file (required)"),
+	"volume":     numberProperty("Music volume, 0.0 to 1.0"),
+	"start_time": numberProperty("Trim the music from this time, in seconds"),
+	"end_time":   numberProperty("Trim the music to this time, in seconds"),
+	"fade_in":    numberProperty("Fade in duration in seconds"),
+	"fade_out":   numberProperty("Fade out duration in seconds"),
+	"duck":       objectSchema(duckProperties),
+	"playlist":   map[string]any{"type": "array", "items": stringProperty("Path to a music file"), "description": "More music files played after file_path, in order, at most 19"},
+	"crossfade":  numberProperty("Seconds each track crossfades into the next, 0 to 10 (default 2)"),
+	"loop":       map[string]any{"type": "boolean", "description": "Repeat the music until the video ends, fading it out over fade_out seconds (default 2)"},
+	"offset":     numberProperty("Seconds into the video the music starts, e.g. after a spoken intro (default 0)"),
+	"mode":       stringProperty("Mix the music with the video's audio, or replace the audio with it (default mix)", "mix", "replace"),
+}
+
+var loudnessProperties = map[string]any{
+	"target_lufs": numberProperty("Integrated loudness target, -70 to -5 (default -16)"),
+	"true_peak":   numberProperty("Maximum true peak in dBTP, -9 to 0 (default -1.5)"),
+	"lra":         numberProperty("Loudness range target, 1 to 50 (default 11)"),
+	"two_pass":    map[string]any{"type": "boolean", "description": "Measure first, then normalize using the measured values"},
+}
+
+var captionStyleProperties = map[string]any{
+	"font":            stringProperty("Font family (default Arial)"),
+	"font_size":       numberProperty("Font size in pixels of a 1080-pixel-high frame, scaled with the video; 8-300 (default 64)"),
+	"bold":            map[string]any{"type": "boolean", "description": "Draw the captions in bold"},
+	"font_color":      stringProperty("Color name or #RRGGBB (default white)"),
+	"outline_color":   stringProperty("Color name or #RRGGBB (default black)"),
+	"position":        stringProperty("Where the captions are drawn (default bottom)", "top", "center", "bottom"),
+	"karaoke":         map[string]any{"type": "boolean", "description": "Highlight each word while it is spoken"},
+	"highlight_color": stringProperty("Color name or #RRGGBB of the spoken word with karaoke (default yellow)"),
+	"max_words":       numberProperty("Split captions into at most this many words, 1-20; 0 keeps the transcribed lines"),
+}
+
+var metadataProperties = map[string]any{
+	"title":         stringProperty("Title tag"),
+	"artist":        stringProperty("Artist tag"),
+	"comment":       stringProperty("Comment tag"),
+	"creation_time": stringProperty("Creation time as an RFC 3339 timestamp"),
+}
+
+var uploadFileProperties = map[string]any{
+	"filename":       stringProperty("Original filename with extension (e.g., video.mp4)"),
+	"content_base64": stringProperty("Base64-encoded file content"),
+}
+
+// decodeArg converts the structured argument name into dst. A JSON-encoded string is accepted as well,
+// since some clients still send nested values that way.
+func decodeArg(args map[string]any, name string, dst any) error {
+	value, ok := args[name]
+	if !ok || value == nil {
+		return fmt.Errorf("%s is required", name)
+	}
+
+	data, ok := value.(string)
+	if !ok {
+		encoded, err := sonic.MarshalString(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		data = encoded
+	}
+
+	if err := sonic.UnmarshalString(data, dst); err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return nil
+}
+
+// hasArg reports whether the optional argument name was given
+func hasArg(args map[string]any, name string) bool {
+	value, ok := args[name]
+	return ok && value != nil
+}
@@ -2,7 +2,10 @@ package mcp
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,23 +17,41 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 
+	"govid/internal/delivery"
 	"govid/internal/ffmpeg"
+	"govid/internal/fingerprint"
 	"govid/internal/models"
+	"govid/internal/presets"
+	"govid/internal/uploads"
 	"govid/pkg/config"
+	"govid/pkg/downloader"
+	"govid/pkg/filetype"
 	"govid/pkg/logger"
+	"govid/pkg/pathpolicy"
+	"govid/pkg/scanner"
+	"govid/pkg/transcribe"
 )
 
 // MCPServer wraps MCP server with dependencies
 type MCPServer struct {
-	server   *server.MCPServer
-	executor *ffmpeg.Executor
-	jobStore *models.JobStore
-	cfg      *config.Config
-	jobWG    *sync.WaitGroup
+	server       *server.MCPServer
+	executor     *ffmpeg.Executor
+	delivery     *delivery.Service
+	jobStore     *models.JobStore
+	presets      *presets.Registry
+	cfg          *config.Config
+	jobWG        *sync.WaitGroup
+	urls         *downloader.URLPolicy
+	fileTypes    *filetype.Policy
+	paths        *pathpolicy.Policy
+	fingerprints *fingerprint.Store
+	scanner      scanner.Scanner        // nil when uploads are not scanned
+	transcriber  transcribe.Transcriber // nil when transcription is disabled
+	drain        *models.Drain
 }
 
 // NewMCPServer creates a new MCP server with video processing tools
-func NewMCPServer(executor *ffmpeg.Executor, jobStore *models.JobStore, cfg *config.Config, jobWG *sync.WaitGroup) *MCPServer {
+func NewMCPServer(executor *ffmpeg.Executor, jobStore *models.JobStore, deliverer *delivery.Service, presetRegistry *presets.Registry, drain *models.Drain, cfg *config.Config, jobWG *sync.WaitGroup) *MCPServer {
 	mcpServer := server.NewMCPServer(
 		"govid-mcp-server",
 		"1.0.0",
@@ -38,11 +59,29 @@ func NewMCPServer(executor *ffmpeg.Executor, jobStore *models.JobStore, cfg *con
 	)
 
 	ms := &MCPServer{
-		server:   mcpServer,
-		executor: executor,
-		jobStore: jobStore,
-		cfg:      cfg,
-		jobWG:    jobWG,
+		server:       mcpServer,
+		executor:     executor,
+		delivery:     deliverer,
+		jobStore:     jobStore,
+		presets:      presetRegistry,
+		cfg:          cfg,
+		jobWG:        jobWG,
+		urls:         downloader.NewURLPolicy(cfg.DownloadAllowPrivate, cfg.DownloadAllowedHosts),
+		fileTypes:    filetype.NewPolicy(cfg.UploadAllowedExtensions),
+		paths:        pathpolicy.NewPolicy(cfg.InputRoots()...),
+		fingerprints: fingerprint.NewStore(cfg.FingerprintsDir),
+		drain:        drain,
+	}
+	// The settings were validated by config.Load
+	if uploadScanner, err := scanner.New(cfg.ScannerConfig()); err != nil {
+		logger.Error("Failed to initialize the upload scanner: %v", err)
+	} else {
+		ms.scanner = uploadScanner
+	}
+	if transcriber, err := transcribe.New(cfg.TranscribeConfig()); err != nil {
+		logger.Error("Failed to initialize the transcriber: %v", err)
+	} else {
+		ms.transcriber = transcriber
 	}
 
 	// Register tools
@@ -59,56 +98,276 @@ func (ms *MCPServer) GetServer() *server.MCPServer {
 // registerTools registers all video processing tools
 func (ms *MCPServer) registerTools() {
 	// Merge videos tool
-	mergeVideosTool := mcp.NewTool("merge_videos",
+	mergeVideosTool := withDeliveryOptions(withOutputOptions(mcp.NewTool("merge_videos",
 		mcp.WithDescription("Merge multiple video segments with customizable timeframes per segment"),
-		mcp.WithString("segments_json",
+		mcp.WithArray("segments",
 			mcp.Required(),
-			mcp.Description("JSON array of video segments with file_path, start_time, and end_time"),
+			mcp.Description("Video segments to merge, in order"),
+			mcp.MinItems(2),
+			mcp.Items(objectSchema(segmentProperties, "file_path")),
 		),
-	)
-	ms.server.AddTool(mergeVideosTool, ms.handleMergeVideos)
+	)))
+	ms.server.AddTool(mergeVideosTool, ms.accepting(ms.handleMergeVideos))
 
 	// Add image overlay tool
-	overlayTool := mcp.NewTool("add_image_overlay",
+	overlayTool := withDeliveryOptions(withOutputOptions(mcp.NewTool("add_image_overlay",
 		mcp.WithDescription("Add image overlay to video with position, duration, and animations (fade, slide, zoom)"),
 		mcp.WithString("video_path",
 			mcp.Required(),
 			mcp.Description("Path to the input video file"),
 		),
-		mcp.WithString("overlay_json",
+		mcp.WithObject("overlay",
 			mcp.Required(),
-			mcp.Description("JSON object with overlay configuration including file_path, position, start_time, end_time, and animation settings"),
+			mcp.Description("Overlay image, position, timeframe, and animation settings"),
+			mcp.Properties(overlayProperties),
 		),
-	)
-	ms.server.AddTool(overlayTool, ms.handleAddImageOverlay)
+	)))
+	ms.server.AddTool(overlayTool, ms.accepting(ms.handleAddImageOverlay))
 
 	// Add background music tool
-	audioTool := mcp.NewTool("add_background_music",
+	audioTool := withDeliveryOptions(withOutputOptions(mcp.NewTool("add_background_music",
 		mcp.WithDescription("Add background music with volume control, fade effects, and timeframe selection"),
 		mcp.WithString("video_path",
 			mcp.Required(),
 			mcp.Description("Path to the input video file"),
 		),
-		mcp.WithString("audio_json",
+		mcp.WithObject("audio",
 			mcp.Required(),
-			mcp.Description("JSON object with audio configuration including file_path, volume (0.0-1.0), start_time, end_time, fade_in, and fade_out"),
+			mcp.Description("Music file, volume, trim, fades, and optional ducking under the original audio"),
+			mcp.Properties(audioProperties),
 		),
-	)
-	ms.server.AddTool(audioTool, ms.handleAddBackgroundMusic)
+		mcp.WithObject("normalize_audio",
+			mcp.Description("Enable EBU R128 loudness normalization of the output"),
+			mcp.Properties(loudnessProperties),
+		),
+	)))
+	ms.server.AddTool(audioTool, ms.accepting(ms.handleAddBackgroundMusic))
 
 	// Complete process tool
-	completeTool := mcp.NewTool("process_video_complete",
+	completeTool := withDeliveryOptions(withOutputOptions(mcp.NewTool("process_video_complete",
 		mcp.WithDescription("Complete video processing with merge, overlay, and audio in one operation"),
-		mcp.WithString("request_json",
+		mcp.WithArray("segments",
+			mcp.Required(),
+			mcp.Description("Video segments to merge, in order"),
+			mcp.MinItems(1),
+			mcp.Items(objectSchema(segmentProperties, "file_path")),
+		),
+		mcp.WithArray("overlays",
+			mcp.Description("Image overlays applied to the merged video"),
+			mcp.Items(objectSchema(overlayProperties, "file_path", "end_time")),
+		),
+		mcp.WithObject("audio",
+			mcp.Description("Background music mixed into the merged video"),
+			mcp.Properties(audioProperties),
+		),
+		mcp.WithObject("normalize_audio",
+			mcp.Description("Enable EBU R128 loudness normalization of the output"),
+			mcp.Properties(loudnessProperties),
+		),
+	)))
+	ms.server.AddTool(completeTool, ms.accepting(ms.handleProcessComplete))
+
+	// Silence detection tool
+	detectSilenceTool := mcp.NewTool("detect_silence",
+		mcp.WithDescription("Report silent ranges in the audio of a video"),
+		mcp.WithString("video_path",
+			mcp.Required(),
+			mcp.Description("Path to input video"),
+		),
+		mcp.WithNumber("noise_db",
+			mcp.Description("Level below which audio counts as silence, -90 to 0 dB (default -30)"),
+		),
+		mcp.WithNumber("min_duration",
+			mcp.Description("Minimum silence length in seconds (default 0.5)"),
+		),
+	)
+	ms.server.AddTool(detectSilenceTool, ms.handleDetectSilence)
+
+	// Probe tool
+	probeTool := mcp.NewTool("probe_media",
+		mcp.WithDescription("Report the format, duration, bitrate, and streams (codecs, resolution, frame rate, rotation, bitrates) of a media file"),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the media file"),
+		),
+	)
+	ms.server.AddTool(probeTool, ms.handleProbeMedia)
+
+	// Quality measurement tool
+	qualityTool := mcp.NewTool("measure_quality",
+		mcp.WithDescription("Compare an encoded video against its source frame by frame and report VMAF, PSNR, and SSIM scores"),
+		mcp.WithString("reference_path",
+			mcp.Required(),
+			mcp.Description("Path to the source video"),
+		),
+		mcp.WithString("distorted_path",
+			mcp.Required(),
+			mcp.Description("Path to the encoded video, scaled to the resolution of the source for the comparison"),
+		),
+		mcp.WithArray("metrics",
+			mcp.Description("Metrics to measure; defaults to all that FFmpeg supports (VMAF needs libvmaf)"),
+			mcp.Items(map[string]any{
+				"type": "string",
+				"enum": []string{string(models.QualityVMAF), string(models.QualityPSNR), string(models.QualitySSIM)},
+			}),
+		),
+	)
+	ms.server.AddTool(qualityTool, ms.handleMeasureQuality)
+
+	// Loudness analysis tool
+	loudnessTool := mcp.NewTool("analyze_loudness",
+		mcp.WithDescription("Report the integrated loudness (LUFS), loudness range, true peak, and levels of the audio of a file, and optionally whether it meets a loudness target such as EBU R128"),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the media file"),
+		),
+		mcp.WithNumber("target_lufs",
+			mcp.Description("Integrated loudness the file must have, -70 to -5, such as -23 for EBU R128 or -24 for ATSC A/85"),
+		),
+		mcp.WithNumber("tolerance",
+			mcp.Description("Allowed deviation from target_lufs in LU, 0 to 10 (default 1)"),
+		),
+		mcp.WithNumber("max_true_peak",
+			mcp.Description("Highest true peak the file may have in dBTP, -9 to 0"),
+		),
+	)
+	ms.server.AddTool(loudnessTool, ms.handleAnalyzeLoudness)
+
+	// Fingerprint tools
+	fingerprintTool := mcp.NewTool("fingerprint_video",
+		mcp.WithDescription("Hash one frame per second of the first 10 minutes of a video and check it against the saved fingerprints, to detect re-uploads of content already processed. Optionally save the fingerprint after the lookup."),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the video"),
+		),
+		mcp.WithNumber("threshold",
+			mcp.Description("Similarity from which a saved fingerprint matches, 0.5 to 1 (default 0.9); unrelated videos score around 0.5"),
+		),
+		mcp.WithBoolean("save",
+			mcp.Description("Save the fingerprint so that later videos are checked against it"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Label saved with the fingerprint"),
+		),
+	)
+	ms.server.AddTool(fingerprintTool, ms.handleFingerprintVideo)
+
+	compareTool := mcp.NewTool("compare_videos",
+		mcp.WithDescription("Fingerprint two videos and report their similarity at the alignment where they match best, and whether they are duplicates"),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the first video"),
+		),
+		mcp.WithString("other_path",
+			mcp.Required(),
+			mcp.Description("Path to the second video"),
+		),
+		mcp.WithNumber("threshold",
+			mcp.Description("Similarity from which the videos are duplicates, 0.5 to 1 (default 0.9)"),
+		),
+	)
+	ms.server.AddTool(compareTool, ms.handleCompareVideos)
+
+	// Silence removal tool
+	removeSilenceTool := withDeliveryOptions(withOutputOptions(mcp.NewTool("remove_silence",
+		mcp.WithDescription("Produce a cut-down video with all silent ranges removed"),
+		mcp.WithString("video_path",
+			mcp.Required(),
+			mcp.Description("Path to input video"),
+		),
+		mcp.WithNumber("noise_db",
+			mcp.Description("Level below which audio counts as silence, -90 to 0 dB (default -30)"),
+		),
+		mcp.WithNumber("min_duration",
+			mcp.Description("Minimum silence length in seconds (default 0.5)"),
+		),
+	)))
+	ms.server.AddTool(removeSilenceTool, ms.accepting(ms.handleRemoveSilence))
+
+	// Vertical conversion tool
+	verticalTool := withDeliveryOptions(withOutputOptions(mcp.NewTool("convert_to_vertical",
+		mcp.WithDescription("Convert a video to a vertical 9:16 frame (1080x1920 by default) over a blurred, zoomed copy of itself. Set background to a color for solid bars instead."),
+		mcp.WithString("video_path",
+			mcp.Required(),
+			mcp.Description("Path to input video"),
+		),
+	)))
+	ms.server.AddTool(verticalTool, ms.accepting(ms.handleConvertToVertical))
+
+	// Transcription tool
+	transcribeTool := withDeliveryOptions(mcp.NewTool("transcribe_video",
+		mcp.WithDescription("Transcribe the speech of a video into SRT or VTT subtitles. The job's output is the subtitle file, which the HTTP API's pipeline subtitles step can burn into a video."),
+		mcp.WithString("video_path",
+			mcp.Required(),
+			mcp.Description("Path to input video"),
+		),
+		mcp.WithString("language",
+			mcp.Description("ISO 639-1 code of the spoken language, such as en; detected when omitted"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Subtitle format: srt (default) or vtt"),
+			mcp.Enum(string(models.SubtitleSRT), string(models.SubtitleVTT)),
+		),
+		mcp.WithString("output_name",
+			mcp.Description("Name of the subtitle file in the output directory, without extension; defaults to the job ID"),
+		),
+	))
+	ms.server.AddTool(transcribeTool, ms.accepting(ms.handleTranscribeVideo))
+
+	// Auto-subtitle tool
+	autoSubtitleTool := withDeliveryOptions(withOutputOptions(mcp.NewTool("auto_subtitle_video",
+		mcp.WithDescription("Transcribe the speech of a video and burn it in as styled captions, optionally highlighting each word as it is spoken"),
+		mcp.WithString("video_path",
+			mcp.Required(),
+			mcp.Description("Path to input video"),
+		),
+		mcp.WithString("language",
+			mcp.Description("ISO 639-1 code of the spoken language, such as en; detected when omitted"),
+		),
+		mcp.WithObject("style",
+			mcp.Description("Caption font, size, colors, position, words per caption, and karaoke highlighting"),
+			mcp.Properties(captionStyleProperties),
+		),
+	)))
+	ms.server.AddTool(autoSubtitleTool, ms.accepting(ms.handleAutoSubtitleVideo))
+
+	// Defect detection tool
+	defectsTool := withDeliveryOptions(mcp.NewTool("detect_defects",
+		mcp.WithDescription("Find the ranges of black frames and frozen picture of a video, to reject broken source material before encoding it. The job's output is a JSON report of the ranges."),
+		mcp.WithString("video_path",
 			mcp.Required(),
-			mcp.Description("JSON object with segments array, optional overlays array, and optional audio object"),
+			mcp.Description("Path to input video"),
+		),
+		mcp.WithNumber("black_min_duration",
+			mcp.Description("Shortest black range reported, in seconds (default 2)"),
+		),
+		mcp.WithNumber("black_threshold",
+			mcp.Description("Luminance below which a pixel counts as black, 0 to 1 (default 0.1)"),
+		),
+		mcp.WithNumber("freeze_min_duration",
+			mcp.Description("Shortest frozen range reported, in seconds (default 2)"),
+		),
+		mcp.WithNumber("freeze_noise_db",
+			mcp.Description("Difference between frames below which the picture counts as frozen, -90 to 0 dB (default -60)"),
+		),
+		mcp.WithBoolean("fail_on_defects",
+			mcp.Description("Fail the job, listing the ranges, if the video has any"),
 		),
+		mcp.WithString("output_name",
+			mcp.Description("Name of the report in the output directory, without extension; defaults to the job ID"),
+		),
+	))
+	ms.server.AddTool(defectsTool, ms.accepting(ms.handleDetectDefects))
+
+	// List presets tool
+	listPresetsTool := mcp.NewTool("list_presets",
+		mcp.WithDescription("List the named encoding presets that can be selected with preset_name"),
 	)
-	ms.server.AddTool(completeTool, ms.handleProcessComplete)
+	ms.server.AddTool(listPresetsTool, ms.handleListPresets)
 
 	// Get job status tool
 	jobStatusTool := mcp.NewTool("get_job_status",
-		mcp.WithDescription("Get the status of a video processing job"),
+		mcp.WithDescription("Get the status of a video processing job, with its queue position while it waits for FFmpeg and an estimated time to finish"),
 		mcp.WithString("job_id",
 			mcp.Required(),
 			mcp.Description("The job ID to check"),
@@ -116,6 +375,16 @@ func (ms *MCPServer) registerTools() {
 	)
 	ms.server.AddTool(jobStatusTool, ms.handleGetJobStatus)
 
+	// Cancel job tool
+	cancelJobTool := mcp.NewTool("cancel_job",
+		mcp.WithDescription("Cancel a pending or processing job, stopping any running FFmpeg command"),
+		mcp.WithString("job_id",
+			mcp.Required(),
+			mcp.Description("The job ID to cancel"),
+		),
+	)
+	ms.server.AddTool(cancelJobTool, ms.handleCancelJob)
+
 	// Upload file tool
 	uploadFileTool := mcp.NewTool("upload_file",
 		mcp.WithDescription("Upload a single file (video, image, or audio) using base64 encoding"),
@@ -133,18 +402,64 @@ func (ms *MCPServer) registerTools() {
 	// Upload multiple files tool
 	uploadMultipleFilesTool := mcp.NewTool("upload_multiple_files",
 		mcp.WithDescription("Upload multiple files at once using base64 encoding"),
-		mcp.WithString("files_json",
+		mcp.WithArray("files",
 			mcp.Required(),
-			mcp.Description("JSON array of objects with 'filename' and 'content_base64' fields"),
+			mcp.Description("Files to upload"),
+			mcp.MinItems(1),
+			mcp.Items(objectSchema(uploadFileProperties, "filename", "content_base64")),
 		),
 	)
 	ms.server.AddTool(uploadMultipleFilesTool, ms.handleUploadMultipleFiles)
+
+	// Download media tool
+	downloadMediaTool := mcp.NewTool("download_media",
+		mcp.WithDescription("Download a video, image, or audio file from a URL into the upload directory and return its path"),
+		mcp.WithString("url",
+			mcp.Required(),
+			mcp.Description("http or https URL of the media file"),
+		),
+	)
+	ms.server.AddTool(downloadMediaTool, ms.handleDownloadMedia)
+}
+
+// checkInputs fails if any of paths is outside the input directories, an upload past its TTL or, while uploads
+// are scanned, an upload without a clean scan
+func (ms *MCPServer) checkInputs(paths ...string) error {
+	for _, path := range paths {
+		if err := ms.paths.Check(path); err != nil {
+			return err
+		}
+		if err := uploads.CheckExpiry(ms.cfg.UploadDir, path); err != nil {
+			return err
+		}
+		if ms.scanner != nil {
+			if err := uploads.CheckScanned(ms.cfg.UploadDir, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// accepting wraps the handler of a tool that creates jobs, refusing its calls once the instance drains, as the
+// HTTP API refuses requests creating jobs
+func (ms *MCPServer) accepting(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if ms.drain.Draining() {
+			return mcp.NewToolResultError(models.ErrDraining.Error()), nil
+		}
+		return handler(ctx, request)
+	}
 }
 
-// createJobResponse creates a standard job response
-func (ms *MCPServer) createJobResponse() (*models.Job, string) {
+// createJobResponse creates a job with the labels and output TTL of opts, delivered as deliveryOpts sets, and a standard job
+// response
+func (ms *MCPServer) createJobResponse(opts models.OutputOptions, deliveryOpts models.DeliveryOptions) (*models.Job, string) {
 	jobID := uuid.New().String()
 	job := models.NewJob(jobID)
+	job.Labels = opts.Labels
+	job.TTL = opts.OutputTTL(ms.cfg.OutputTTLSeconds)
+	job.SetDelivery(deliveryOpts)
 	ms.jobStore.Add(job)
 
 	response := map[string]any{
@@ -157,8 +472,226 @@ func (ms *MCPServer) createJobResponse() (*models.Job, string) {
 	return job, responseJSON
 }
 
+// startJob records the work of kind with req on the job, so that it can be retried, and runs process in
+// the background. In the api run mode, the job is left in the job store for a worker to run.
+func (ms *MCPServer) startJob(job *models.Job, kind string, req any, process func()) {
+	work, err := models.NewJobWork(kind, req)
+	if err != nil {
+		job.SetError(err.Error())
+		_ = ms.jobStore.Update(job)
+		return
+	}
+	job.SetWork(work)
+	_ = ms.jobStore.Update(job)
+	if ms.cfg.RunMode == config.ModeAPI {
+		job.Logger().Info("Job %s is left to the workers", job.ID)
+		return
+	}
+
+	ms.jobWG.Add(1)
+	go func() {
+		defer ms.jobWG.Done()
+		process()
+	}()
+}
+
+// withOutputOptions adds the optional output format and encoding parameters to a tool
+func withOutputOptions(tool mcp.Tool) mcp.Tool {
+	options := []mcp.ToolOption{
+		mcp.WithString("preset_name",
+			mcp.Description("Named encoding preset (see list_presets); explicitly set options override it"),
+		),
+		mcp.WithString("output_format",
+			mcp.Description("Output container: mp4 (default), mkv, webm, or mov"),
+		),
+		mcp.WithNumber("crf",
+			mcp.Description("Constant rate factor, 0-51 (0-63 for webm); lower is better quality"),
+		),
+		mcp.WithString("preset",
+			mcp.Description("x264 speed preset, ultrafast to veryslow (default medium)"),
+		),
+		mcp.WithString("profile",
+			mcp.Description("H.264 profile: baseline, main, high, high10, high422, or high444"),
+		),
+		mcp.WithString("level",
+			mcp.Description("H.264 level, 3.0 to 6.2"),
+		),
+		mcp.WithString("pix_fmt",
+			mcp.Description("Pixel format, e.g. yuv420p"),
+		),
+		mcp.WithNumber("width",
+			mcp.Description("Output width in pixels (even); requires height"),
+		),
+		mcp.WithNumber("height",
+			mcp.Description("Output height in pixels (even); requires width"),
+		),
+		mcp.WithString("fit",
+			mcp.Description("How the video is fitted to width x height: contain (default, pads), cover (crops), or stretch"),
+		),
+		mcp.WithString("background",
+			mcp.Description("Padding fill for fit contain: a color name, #RRGGBB, or blur (default black)"),
+		),
+		mcp.WithNumber("fps",
+			mcp.Description("Output frame rate, 1-240 (default: keep the source rate)"),
+		),
+		mcp.WithString("interpolate",
+			mcp.Description("Synthesize frames when changing fps: blend (fast) or motion (smooth, slow; limited input length)"),
+		),
+		mcp.WithObject("metadata",
+			mcp.Description("Container metadata tags to write to the output"),
+			mcp.Properties(metadataProperties),
+		),
+		mcp.WithBoolean("strip_metadata",
+			mcp.Description("Remove all existing metadata tags and chapters from the output"),
+		),
+		mcp.WithBoolean("cleanup_inputs",
+			mcp.Description("Delete the uploaded input files once the job completes (default: CLEANUP_INPUTS)"),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Stop the job after this many seconds (default: JOB_TIMEOUT, at most MAX_JOB_TIMEOUT)"),
+		),
+		mcp.WithNumber("ttl_seconds",
+			mcp.Description("Delete the outputs this many seconds after the job completes, which then becomes expired (default: OUTPUT_TTL_SECONDS)"),
+		),
+		mcp.WithString("output_name",
+			mcp.Description("Name of the output file in OUTPUT_DIR, without extension (default: the job ID)"),
+		),
+		mcp.WithString("on_conflict",
+			mcp.Description("When a file named output_name exists: rename (default, adds -1, -2, ...), overwrite, or fail"),
+		),
+		mcp.WithObject("labels",
+			mcp.Description("Key/value labels kept with the job and returned in its status, such as an order ID"),
+			mcp.AdditionalProperties(map[string]any{"type": "string"}),
+		),
+	}
+	for _, option := range options {
+		option(&tool)
+	}
+	return tool
+}
+
+// withDeliveryOptions adds the optional webhook and storage upload parameters to a tool that creates a job
+func withDeliveryOptions(tool mcp.Tool) mcp.Tool {
+	options := []mcp.ToolOption{
+		mcp.WithString("webhook_url",
+			mcp.Description("URL that receives the job status when the job completes or fails"),
+		),
+		mcp.WithObject("webhook_headers",
+			mcp.Description("Headers sent with the webhook request, as an object of strings"),
+			mcp.AdditionalProperties(map[string]any{"type": "string"}),
+		),
+		mcp.WithBoolean("upload_to_s3",
+			mcp.Description("Upload the output to the storage backend and delete the local file once the job completes"),
+		),
+		mcp.WithString("destination",
+			mcp.Description("Where the output ends up: local (OUTPUT_DIR, default) or storage (same as upload_to_s3)"),
+		),
+		mcp.WithString("output_key",
+			mcp.Description("Object key of an uploaded output, with the placeholders of S3_KEY_TEMPLATE (default: S3_KEY_TEMPLATE)"),
+		),
+		mcp.WithString("bucket",
+			mcp.Description("Bucket to upload the output to instead of the configured one; must be listed in STORAGE_ALLOWED_BUCKETS"),
+		),
+	}
+	for _, option := range options {
+		option(&tool)
+	}
+	return tool
+}
+
+// deliveryOptionsFromArgs reads and validates the webhook and storage upload parameters from tool arguments
+func (ms *MCPServer) deliveryOptionsFromArgs(request mcp.CallToolRequest) (models.DeliveryOptions, error) {
+	opts := models.DeliveryOptions{
+		WebhookURL:  request.GetString("webhook_url", ""),
+		UploadToS3:  request.GetBool("upload_to_s3", false),
+		Destination: models.Destination(request.GetString("destination", "")),
+		StorageOptions: models.StorageOptions{
+			OutputKey: request.GetString("output_key", ""),
+			Bucket:    request.GetString("bucket", ""),
+		},
+	}
+	if args, ok := request.Params.Arguments.(map[string]any); ok && hasArg(args, "webhook_headers") {
+		if err := decodeArg(args, "webhook_headers", &opts.WebhookHeaders); err != nil {
+			return opts, err
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		return opts, err
+	}
+	return opts, ms.delivery.Check(opts)
+}
+
+// outputOptionsFromArgs reads the output format and encoding parameters from tool arguments
+// and applies the selected preset
+func (ms *MCPServer) outputOptionsFromArgs(request mcp.CallToolRequest) (models.OutputOptions, error) {
+	opts, err := parseOutputOptions(request)
+	if err != nil {
+		return opts, err
+	}
+	return opts, ms.presets.Resolve(&opts)
+}
+
+// parseOutputOptions reads the output format and encoding parameters from tool arguments without validating them
+func parseOutputOptions(request mcp.CallToolRequest) (models.OutputOptions, error) {
+	opts := models.OutputOptions{
+		PresetName:   request.GetString("preset_name", ""),
+		OutputFormat: models.OutputFormat(request.GetString("output_format", "")),
+		Preset:       request.GetString("preset", ""),
+		Profile:      request.GetString("profile", ""),
+		Level:        request.GetString("level", ""),
+		PixFmt:       request.GetString("pix_fmt", ""),
+		Fit:          models.FitMode(request.GetString("fit", "")),
+		Background:   request.GetString("background", ""),
+		Interpolate:  models.InterpolateMode(request.GetString("interpolate", "")),
+		OutputName:   request.GetString("output_name", ""),
+		OnConflict:   models.ConflictMode(request.GetString("on_conflict", "")),
+	}
+
+	if args, ok := request.Params.Arguments.(map[string]any); ok {
+		if crf, ok := args["crf"].(float64); ok {
+			value := int(crf)
+			opts.CRF = &value
+		}
+		if width, ok := args["width"].(float64); ok {
+			opts.Width = int(width)
+		}
+		if height, ok := args["height"].(float64); ok {
+			opts.Height = int(height)
+		}
+		if fps, ok := args["fps"].(float64); ok {
+			opts.FPS = fps
+		}
+	}
+
+	if args, ok := request.Params.Arguments.(map[string]any); ok && hasArg(args, "metadata") {
+		opts.Metadata = &models.OutputMetadata{}
+		if err := decodeArg(args, "metadata", opts.Metadata); err != nil {
+			return opts, err
+		}
+	}
+	opts.StripMetadata = request.GetBool("strip_metadata", false)
+	if args, ok := request.Params.Arguments.(map[string]any); ok {
+		if cleanup, ok := args["cleanup_inputs"].(bool); ok {
+			opts.CleanupInputs = &cleanup
+		}
+		if timeout, ok := args["timeout_seconds"].(float64); ok {
+			opts.TimeoutSeconds = int(timeout)
+		}
+		if ttl, ok := args["ttl_seconds"].(float64); ok {
+			opts.TTLSeconds = int(ttl)
+		}
+	}
+	if args, ok := request.Params.Arguments.(map[string]any); ok && hasArg(args, "labels") {
+		if err := decodeArg(args, "labels", &opts.Labels); err != nil {
+			return opts, err
+		}
+	}
+
+	return opts, nil
+}
+
 // handleVideoProcessingTool handles common video processing tool logic
-func (ms *MCPServer) handleVideoProcessingTool(_ context.Context, request mcp.CallToolRequest, jsonKey string, unmarshalFn func(string) (any, error), processFn func(*models.Job, string, any)) (*mcp.CallToolResult, error) {
+func (ms *MCPServer) handleVideoProcessingTool(ctx context.Context, request mcp.CallToolRequest, decodeFn func(map[string]any) (any, error), startFn func(*models.Job, string, models.OutputOptions, any)) (*mcp.CallToolResult, error) {
 	args, ok := request.Params.Arguments.(map[string]any)
 	if !ok {
 		return mcp.NewToolResultError("invalid arguments format"), nil
@@ -168,23 +701,30 @@ func (ms *MCPServer) handleVideoProcessingTool(_ context.Context, request mcp.Ca
 	if !ok {
 		return mcp.NewToolResultError("video_path must be a string"), nil
 	}
+	if err := ms.checkInputs(videoPath); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.executor.CheckInputLimits(ctx, []models.VideoSegment{{FilePath: videoPath}}); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
-	jsonStr, ok := args[jsonKey].(string)
-	if !ok {
-		return mcp.NewToolResultError(fmt.Sprintf("%s must be a string", jsonKey)), nil
+	config, err := decodeFn(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	config, err := unmarshalFn(jsonStr)
+	opts, err := ms.outputOptionsFromArgs(request)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse %s: %v", jsonKey, err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	job, responseJSON := ms.createJobResponse()
-	ms.jobWG.Add(1)
-	go func() {
-		defer ms.jobWG.Done()
-		processFn(job, videoPath, config)
-	}()
+	deliveryOpts, err := ms.deliveryOptionsFromArgs(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	job, responseJSON := ms.createJobResponse(opts, deliveryOpts)
+	startFn(job, videoPath, opts, config)
 
 	return mcp.NewToolResultText(responseJSON), nil
 }
@@ -196,53 +736,108 @@ func (ms *MCPServer) handleMergeVideos(ctx context.Context, request mcp.CallTool
 		return mcp.NewToolResultError("invalid arguments format"), nil
 	}
 
-	segmentsJSON, ok := args["segments_json"].(string)
-	if !ok {
-		return mcp.NewToolResultError("segments_json must be a string"), nil
-	}
-
 	var segments []models.VideoSegment
-	if err := sonic.UnmarshalString(segmentsJSON, &segments); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse segments_json: %v", err)), nil
+	if err := decodeArg(args, "segments", &segments); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
 	if len(segments) < 2 {
 		return mcp.NewToolResultError("At least 2 video segments required"), nil
 	}
+	if err := validateSegments(segments); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	for _, seg := range segments {
+		if err := ms.checkInputs(seg.FilePath); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+	if err := ms.executor.CheckInputLimits(ctx, segments); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
-	job, responseJSON := ms.createJobResponse()
-	ms.jobWG.Add(1)
-	go func() {
-		defer ms.jobWG.Done()
-		ms.processMergeJob(job, segments)
-	}()
+	opts, err := ms.outputOptionsFromArgs(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	req := models.MergeVideoRequest{
+		Segments:      segments,
+		OutputOptions: opts,
+	}
+
+	deliveryOpts, err := ms.deliveryOptionsFromArgs(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	job, responseJSON := ms.createJobResponse(opts, deliveryOpts)
+	ms.startJob(job, models.WorkMerge, req, func() { ms.processMergeJob(job, req) })
 
 	return mcp.NewToolResultText(responseJSON), nil
 }
 
 // handleAddImageOverlay handles image overlay requests
 func (ms *MCPServer) handleAddImageOverlay(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	return ms.handleVideoProcessingTool(ctx, request, "overlay_json",
-		func(jsonStr string) (any, error) {
+	return ms.handleVideoProcessingTool(ctx, request,
+		func(args map[string]any) (any, error) {
 			var overlay models.ImageOverlay
-			err := sonic.UnmarshalString(jsonStr, &overlay)
-			return overlay, err
+			if err := decodeArg(args, "overlay", &overlay); err != nil {
+				return nil, err
+			}
+			if err := overlay.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid overlay: %w", err)
+			}
+			if err := ms.checkInputs(overlay.FilePath); err != nil {
+				return nil, err
+			}
+			return overlay, nil
 		},
-		func(job *models.Job, videoPath string, config any) {
-			ms.processOverlayJob(job, videoPath, config.(models.ImageOverlay))
+		func(job *models.Job, videoPath string, opts models.OutputOptions, config any) {
+			req := models.OverlayRequest{
+				VideoPath:     videoPath,
+				Overlay:       config.(models.ImageOverlay),
+				OutputOptions: opts,
+			}
+			ms.startJob(job, models.WorkOverlay, req, func() { ms.processOverlayJob(job, req) })
 		})
 }
 
 // handleAddBackgroundMusic handles background music requests
 func (ms *MCPServer) handleAddBackgroundMusic(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	return ms.handleVideoProcessingTool(ctx, request, "audio_json",
-		func(jsonStr string) (any, error) {
+	var normalize *models.LoudnessNormalization
+
+	return ms.handleVideoProcessingTool(ctx, request,
+		func(args map[string]any) (any, error) {
 			var audio models.AudioConfig
-			err := sonic.UnmarshalString(jsonStr, &audio)
-			return audio, err
+			if err := decodeArg(args, "audio", &audio); err != nil {
+				return nil, err
+			}
+			if err := audio.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid audio: %w", err)
+			}
+			if err := ms.checkInputs(audio.Tracks()...); err != nil {
+				return nil, err
+			}
+
+			if hasArg(args, "normalize_audio") {
+				normalize = &models.LoudnessNormalization{}
+				if err := decodeArg(args, "normalize_audio", normalize); err != nil {
+					return nil, err
+				}
+				if err := normalize.Validate(); err != nil {
+					return nil, fmt.Errorf("invalid normalize_audio: %w", err)
+				}
+			}
+			return audio, nil
 		},
-		func(job *models.Job, videoPath string, config any) {
-			ms.processAudioJob(job, videoPath, config.(models.AudioConfig))
+		func(job *models.Job, videoPath string, opts models.OutputOptions, config any) {
+			req := models.AudioRequest{
+				VideoPath:      videoPath,
+				Audio:          config.(models.AudioConfig),
+				NormalizeAudio: normalize,
+				OutputOptions:  opts,
+			}
+			ms.startJob(job, models.WorkAudio, req, func() { ms.processAudioJob(job, req) })
 		})
 }
 
@@ -253,30 +848,74 @@ func (ms *MCPServer) handleProcessComplete(ctx context.Context, request mcp.Call
 		return mcp.NewToolResultError("invalid arguments format"), nil
 	}
 
-	requestJSON, ok := args["request_json"].(string)
-	if !ok {
-		return mcp.NewToolResultError("request_json must be a string"), nil
+	req, err := completeRequestFromArgs(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.checkInputs(req.InputPaths()...); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.executor.CheckInputLimits(ctx, req.Segments); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	var req models.CompleteProcessRequest
-	if err := sonic.UnmarshalString(requestJSON, &req); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse request_json: %v", err)), nil
+	opts, err := ms.outputOptionsFromArgs(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
+	req.OutputOptions = opts
 
-	if len(req.Segments) < 1 {
-		return mcp.NewToolResultError("At least 1 video segment required"), nil
+	deliveryOpts, err := ms.deliveryOptionsFromArgs(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	job, responseJSON := ms.createJobResponse()
-	ms.jobWG.Add(1)
-	go func() {
-		defer ms.jobWG.Done()
-		ms.processCompleteJob(job, req)
-	}()
+	job, responseJSON := ms.createJobResponse(req.OutputOptions, deliveryOpts)
+	ms.startJob(job, models.WorkComplete, req, func() { ms.processCompleteJob(job, req) })
 
 	return mcp.NewToolResultText(responseJSON), nil
 }
 
+// completeRequestFromArgs decodes and validates the segments, overlays, and audio of a complete process request
+func completeRequestFromArgs(args map[string]any) (models.CompleteProcessRequest, error) {
+	var req models.CompleteProcessRequest
+	if err := decodeArg(args, "segments", &req.Segments); err != nil {
+		return req, err
+	}
+	if hasArg(args, "overlays") {
+		if err := decodeArg(args, "overlays", &req.Overlays); err != nil {
+			return req, err
+		}
+	}
+	if hasArg(args, "audio") {
+		req.Audio = &models.AudioConfig{}
+		if err := decodeArg(args, "audio", req.Audio); err != nil {
+			return req, err
+		}
+	}
+	if hasArg(args, "normalize_audio") {
+		req.NormalizeAudio = &models.LoudnessNormalization{}
+		if err := decodeArg(args, "normalize_audio", req.NormalizeAudio); err != nil {
+			return req, err
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		return req, fmt.Errorf("invalid request: %w", err)
+	}
+	return req, nil
+}
+
+// validateSegments validates every segment, naming the first invalid one
+func validateSegments(segments []models.VideoSegment) error {
+	for i := range segments {
+		if err := segments[i].Validate(); err != nil {
+			return fmt.Errorf("invalid segments[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
 // handleGetJobStatus handles job status requests
 func (ms *MCPServer) handleGetJobStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args, ok := request.Params.Arguments.(map[string]any)
@@ -294,60 +933,704 @@ func (ms *MCPServer) handleGetJobStatus(ctx context.Context, request mcp.CallToo
 		return mcp.NewToolResultError(fmt.Sprintf("Job with ID %s does not exist", jobID)), nil
 	}
 
-	status := job.GetStatus()
+	status := ms.jobStore.QueueStatus(job, ms.executor.Queue(), ms.executor.Slots())
 	responseJSON, _ := sonic.MarshalString(status)
 	return mcp.NewToolResultText(responseJSON), nil
 }
 
-// Job processing methods (similar to API handlers)
+// handleCancelJob handles job cancellation requests
+func (ms *MCPServer) handleCancelJob(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, ok := request.Params.Arguments.(map[string]any)
+	if !ok {
+		return mcp.NewToolResultError("invalid arguments format"), nil
+	}
 
-// processJobCommon handles common job processing logic for MCP
-func (ms *MCPServer) processJobCommon(job *models.Job, jobType string, processFn func(context.Context, string) error) {
-	job.UpdateStatus(models.JobStatusProcessing)
-	job.UpdateProgress(10)
+	jobID, ok := args["job_id"].(string)
+	if !ok {
+		return mcp.NewToolResultError("job_id must be a string"), nil
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ms.cfg.JobTimeout)*time.Second)
-	defer cancel()
+	job, exists := ms.jobStore.Get(jobID)
+	if !exists {
+		return mcp.NewToolResultError(fmt.Sprintf("Job with ID %s does not exist", jobID)), nil
+	}
 
-	outputPath := filepath.Join(ms.cfg.OutputDir, fmt.Sprintf("%s.mp4", job.ID))
+	if !job.Cancel() {
+		return mcp.NewToolResultError(fmt.Sprintf("Job is %s and cannot be cancelled", job.GetStatus().Status)), nil
+	}
+	_ = ms.jobStore.Update(job)
+	logger.Info("Job %s cancelled (MCP)", job.ID)
 
-	logger.Info("Starting %s job %s (MCP)", jobType, job.ID)
-	job.UpdateProgress(30)
+	responseJSON, _ := sonic.MarshalString(job.GetStatus())
+	return mcp.NewToolResultText(responseJSON), nil
+}
+
+// silenceRequestFromArgs builds a silence request from tool arguments
+func (ms *MCPServer) silenceRequestFromArgs(request mcp.CallToolRequest) (models.SilenceRequest, error) {
+	var req models.SilenceRequest
+
+	args, ok := request.Params.Arguments.(map[string]any)
+	if !ok {
+		return req, fmt.Errorf("invalid arguments format")
+	}
+
+	req.VideoPath, ok = args["video_path"].(string)
+	if !ok || req.VideoPath == "" {
+		return req, fmt.Errorf("video_path must be a string")
+	}
+
+	if noise, ok := args["noise_db"].(float64); ok {
+		req.NoiseDB = &noise
+	}
+	if minDuration, ok := args["min_duration"].(float64); ok {
+		req.MinDuration = &minDuration
+	}
+
+	opts, err := ms.outputOptionsFromArgs(request)
+	if err != nil {
+		return req, err
+	}
+	req.OutputOptions = opts
+
+	return req, req.Validate()
+}
+
+// handleDetectSilence handles silence detection requests
+func (ms *MCPServer) handleDetectSilence(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := ms.silenceRequestFromArgs(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.checkInputs(req.VideoPath); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	silences, duration, err := ms.executor.DetectSilence(ctx, req)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Silence detection failed: %v", err)), nil
+	}
+
+	responseJSON, _ := sonic.MarshalString(models.SilenceDetectResponse{
+		VideoPath: req.VideoPath,
+		Duration:  duration,
+		Silences:  silences,
+	})
+	return mcp.NewToolResultText(responseJSON), nil
+}
+
+// handleProbeMedia handles media probe requests
+func (ms *MCPServer) handleProbeMedia(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filePath := request.GetString("file_path", "")
+	if filePath == "" {
+		return mcp.NewToolResultError("file_path must be a string"), nil
+	}
+	if err := ms.checkInputs(filePath); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ffmpeg.ValidateFile(filePath); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	probe, err := ms.executor.Probe(ctx, filePath)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Probe failed: %v", err)), nil
+	}
+
+	responseJSON, _ := sonic.MarshalString(probe)
+	return mcp.NewToolResultText(responseJSON), nil
+}
+
+// handleAnalyzeLoudness handles loudness analysis requests
+func (ms *MCPServer) handleAnalyzeLoudness(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, ok := request.Params.Arguments.(map[string]any)
+	if !ok {
+		return mcp.NewToolResultError("invalid arguments format"), nil
+	}
+	req := models.LoudnessRequest{FilePath: request.GetString("file_path", "")}
+	if target, ok := args["target_lufs"].(float64); ok {
+		req.TargetLUFS = &target
+	}
+	if tolerance, ok := args["tolerance"].(float64); ok {
+		req.Tolerance = &tolerance
+	}
+	if truePeak, ok := args["max_true_peak"].(float64); ok {
+		req.MaxTruePeak = &truePeak
+	}
+	if err := req.Validate(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.checkInputs(req.FilePath); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	report, err := ms.executor.AnalyzeLoudness(ctx, req)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Loudness analysis failed: %v", err)), nil
+	}
+	report.Compliant, report.Issues = req.Check(report)
+
+	responseJSON, _ := sonic.MarshalString(report)
+	return mcp.NewToolResultText(responseJSON), nil
+}
+
+// handleMeasureQuality handles quality measurement requests
+func (ms *MCPServer) handleMeasureQuality(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req := models.QualityRequest{
+		ReferencePath: request.GetString("reference_path", ""),
+		DistortedPath: request.GetString("distorted_path", ""),
+	}
+	if args, ok := request.Params.Arguments.(map[string]any); ok && hasArg(args, "metrics") {
+		if err := decodeArg(args, "metrics", &req.Metrics); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+	if err := req.Validate(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.checkInputs(req.InputPaths()...); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	result, err := ms.executor.MeasureQuality(ctx, req)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Quality measurement failed: %v", err)), nil
+	}
+
+	responseJSON, _ := sonic.MarshalString(result)
+	return mcp.NewToolResultText(responseJSON), nil
+}
+
+// handleFingerprintVideo handles fingerprint and duplicate lookup requests
+func (ms *MCPServer) handleFingerprintVideo(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, ok := request.Params.Arguments.(map[string]any)
+	if !ok {
+		return mcp.NewToolResultError("invalid arguments format"), nil
+	}
+	req := models.FingerprintRequest{
+		FilePath: request.GetString("file_path", ""),
+		Save:     request.GetBool("save", false),
+		Name:     request.GetString("name", ""),
+	}
+	if threshold, ok := args["threshold"].(float64); ok {
+		req.Threshold = &threshold
+	}
+	if err := req.Validate(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.checkInputs(req.FilePath); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	hashes, err := ms.executor.Fingerprint(ctx, req.FilePath)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Fingerprinting failed: %v", err)), nil
+	}
+	matches, err := ms.fingerprints.Search(hashes, models.DuplicateThreshold(req.Threshold))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Fingerprint lookup failed: %v", err)), nil
+	}
+
+	response := models.FingerprintResponse{
+		FilePath:    req.FilePath,
+		Seconds:     len(hashes),
+		Fingerprint: hashes.String(),
+		Duplicate:   len(matches) > 0,
+		Matches:     matches,
+	}
+	if req.Save {
+		entry, err := ms.fingerprints.Add(hashes, req.FilePath, req.Name, "")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to save fingerprint: %v", err)), nil
+		}
+		response.ID = entry.ID
+	}
+
+	responseJSON, _ := sonic.MarshalString(response)
+	return mcp.NewToolResultText(responseJSON), nil
+}
+
+// handleCompareVideos handles video comparison requests
+func (ms *MCPServer) handleCompareVideos(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, ok := request.Params.Arguments.(map[string]any)
+	if !ok {
+		return mcp.NewToolResultError("invalid arguments format"), nil
+	}
+	req := models.CompareRequest{
+		FilePath:  request.GetString("file_path", ""),
+		OtherPath: request.GetString("other_path", ""),
+	}
+	if threshold, ok := args["threshold"].(float64); ok {
+		req.Threshold = &threshold
+	}
+	if err := req.Validate(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.checkInputs(req.FilePath, req.OtherPath); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	hashes := make([]fingerprint.Fingerprint, 2)
+	for i, path := range []string{req.FilePath, req.OtherPath} {
+		var err error
+		if hashes[i], err = ms.executor.Fingerprint(ctx, path); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Fingerprinting failed: %v", err)), nil
+		}
+	}
+
+	match := fingerprint.Compare(hashes[0], hashes[1])
+	response := models.CompareResponse{
+		FilePath:   req.FilePath,
+		OtherPath:  req.OtherPath,
+		Similarity: match.Similarity,
+		Offset:     match.Offset,
+		Overlap:    match.Overlap,
+		Duplicate:  match.Similarity >= models.DuplicateThreshold(req.Threshold),
+	}
+	responseJSON, _ := sonic.MarshalString(response)
+	return mcp.NewToolResultText(responseJSON), nil
+}
+
+// handleRemoveSilence handles silence removal requests
+func (ms *MCPServer) handleRemoveSilence(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req, err := ms.silenceRequestFromArgs(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.checkInputs(req.VideoPath); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.executor.CheckInputLimits(ctx, []models.VideoSegment{{FilePath: req.VideoPath}}); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	deliveryOpts, err := ms.deliveryOptionsFromArgs(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	job, responseJSON := ms.createJobResponse(req.OutputOptions, deliveryOpts)
+	ms.startJob(job, models.WorkSilence, req, func() { ms.processSilenceRemovalJob(job, req) })
+
+	return mcp.NewToolResultText(responseJSON), nil
+}
+
+// handleConvertToVertical handles vertical conversion requests
+func (ms *MCPServer) handleConvertToVertical(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	req := models.VerticalRequest{
+		VideoPath: request.GetString("video_path", ""),
+	}
+	if req.VideoPath == "" {
+		return mcp.NewToolResultError("video_path must be a string"), nil
+	}
+	if err := ms.checkInputs(req.VideoPath); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.executor.CheckInputLimits(ctx, []models.VideoSegment{{FilePath: req.VideoPath}}); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	opts, err := parseOutputOptions(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// The background applies to the default vertical resolution, so it is kept out of preset validation
+	req.Background, opts.Background = opts.Background, ""
+	if err := ms.presets.Resolve(&opts); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	req.OutputOptions = opts
+
+	if err := req.Validate(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	deliveryOpts, err := ms.deliveryOptionsFromArgs(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	job, responseJSON := ms.createJobResponse(req.OutputOptions, deliveryOpts)
+	ms.startJob(job, models.WorkVertical, req, func() { ms.processVerticalJob(job, req) })
+
+	return mcp.NewToolResultText(responseJSON), nil
+}
+
+// handleListPresets handles preset listing requests
+func (ms *MCPServer) handleListPresets(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	responseJSON, _ := sonic.MarshalString(models.PresetsResponse{
+		Presets: ms.presets.List(),
+	})
+	return mcp.NewToolResultText(responseJSON), nil
+}
+
+// Job processing methods (similar to API handlers)
+
+// handleTranscribeVideo handles transcription requests
+func (ms *MCPServer) handleTranscribeVideo(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if ms.transcriber == nil {
+		return mcp.NewToolResultError("transcription is not configured: set TRANSCRIBE_BACKEND to whisper or http"), nil
+	}
+	req := models.TranscribeRequest{
+		VideoPath: request.GetString("video_path", ""),
+		Language:  request.GetString("language", ""),
+		Format:    models.SubtitleFormat(request.GetString("format", "")),
+	}
+	req.OutputName = request.GetString("output_name", "")
+	if req.VideoPath == "" {
+		return mcp.NewToolResultError("video_path must be a string"), nil
+	}
+	if err := req.Validate(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.checkInputs(req.VideoPath); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.executor.CheckInputLimits(ctx, []models.VideoSegment{{FilePath: req.VideoPath}}); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	deliveryOpts, err := ms.deliveryOptionsFromArgs(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	job, responseJSON := ms.createJobResponse(req.OutputOptions, deliveryOpts)
+	ms.startJob(job, models.WorkTranscribe, req, func() { ms.processTranscribeJob(job, req) })
+
+	return mcp.NewToolResultText(responseJSON), nil
+}
+
+// handleDetectDefects handles defect detection requests
+func (ms *MCPServer) handleDetectDefects(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, ok := request.Params.Arguments.(map[string]any)
+	if !ok {
+		return mcp.NewToolResultError("invalid arguments format"), nil
+	}
+	req := models.DefectsRequest{
+		VideoPath:     request.GetString("video_path", ""),
+		FailOnDefects: request.GetBool("fail_on_defects", false),
+	}
+	req.OutputName = request.GetString("output_name", "")
+	if req.VideoPath == "" {
+		return mcp.NewToolResultError("video_path must be a string"), nil
+	}
+	if duration, ok := args["black_min_duration"].(float64); ok {
+		req.BlackMinDuration = &duration
+	}
+	if threshold, ok := args["black_threshold"].(float64); ok {
+		req.BlackThreshold = &threshold
+	}
+	if duration, ok := args["freeze_min_duration"].(float64); ok {
+		req.FreezeMinDuration = &duration
+	}
+	if noise, ok := args["freeze_noise_db"].(float64); ok {
+		req.FreezeNoiseDB = &noise
+	}
+	if err := req.Validate(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.checkInputs(req.VideoPath); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.executor.CheckInputLimits(ctx, []models.VideoSegment{{FilePath: req.VideoPath}}); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	deliveryOpts, err := ms.deliveryOptionsFromArgs(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	job, responseJSON := ms.createJobResponse(req.OutputOptions, deliveryOpts)
+	ms.startJob(job, models.WorkDefects, req, func() { ms.processDefectsJob(job, req) })
+
+	return mcp.NewToolResultText(responseJSON), nil
+}
+
+// handleAutoSubtitleVideo handles auto-subtitle requests
+func (ms *MCPServer) handleAutoSubtitleVideo(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if ms.transcriber == nil {
+		return mcp.NewToolResultError("transcription is not configured: set TRANSCRIBE_BACKEND to whisper or http"), nil
+	}
+	args, ok := request.Params.Arguments.(map[string]any)
+	if !ok {
+		return mcp.NewToolResultError("invalid arguments format"), nil
+	}
+	req := models.AutoSubtitleRequest{
+		VideoPath: request.GetString("video_path", ""),
+		Language:  request.GetString("language", ""),
+	}
+	if req.VideoPath == "" {
+		return mcp.NewToolResultError("video_path must be a string"), nil
+	}
+	if hasArg(args, "style") {
+		if err := decodeArg(args, "style", &req.Style); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+
+	opts, err := ms.outputOptionsFromArgs(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	req.OutputOptions = opts
+	if err := req.Validate(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.checkInputs(req.VideoPath); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := ms.executor.CheckInputLimits(ctx, []models.VideoSegment{{FilePath: req.VideoPath}}); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	deliveryOpts, err := ms.deliveryOptionsFromArgs(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	job, responseJSON := ms.createJobResponse(req.OutputOptions, deliveryOpts)
+	ms.startJob(job, models.WorkAutoSubtitle, req, func() { ms.processAutoSubtitleJob(job, req) })
+
+	return mcp.NewToolResultText(responseJSON), nil
+}
+
+// processJobCommon handles common job processing logic for MCP
+func (ms *MCPServer) processJobCommon(job *models.Job, jobType string, opts models.OutputOptions, processFn func(context.Context, string) error) {
+	timeout := opts.JobTimeout(ms.cfg.JobTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	jobLog := job.Logger()
+	ctx = logger.NewContext(ctx, jobLog)
+	ctx, span := job.StartSpan(ctx, "job."+jobType)
+	defer job.EndSpan(span)
+	if logFile, err := ms.jobStore.OpenLog(job.ID); err != nil {
+		jobLog.Warn("Failed to open log of job %s: %v", job.ID, err)
+	} else {
+		defer logFile.Close()
+		ctx = ffmpeg.WithLog(ctx, logFile)
+	}
+
+	if !job.Start(cancel) {
+		jobLog.Info("Job %s was cancelled before it started (MCP)", job.ID)
+		return
+	}
+	job.UpdateProgress(10)
+
+	outputPath, err := ms.delivery.OutputPath(job, opts)
+	if err != nil {
+		jobLog.Error("Failed to name the output of job %s: %v", job.ID, err)
+		job.SetError(err.Error())
+		ms.delivery.Notify(ctx, job)
+		return
+	}
+
+	jobLog.Info("Starting %s job %s (MCP)", jobType, job.ID)
+	job.SetInput(jobType, ms.executor.InputSeconds(ctx, job.Files()...))
+	job.UpdateProgress(30)
+	// FFmpeg takes the job from 30% to 90%
+	ctx = ffmpeg.WithProgress(ctx, func(fraction float64) {
+		job.AdvanceProgress(30 + int(fraction*60))
+	})
+	ctx = ffmpeg.WithStart(ctx, job.MarkStarted)
 
 	if err := processFn(ctx, outputPath); err != nil {
-		logger.Error("%s job %s failed: %v", jobType, job.ID, err)
+		err = models.TimeoutError(ctx, timeout, err)
+		jobLog.Error("%s job %s failed: %v", jobType, job.ID, err)
 		job.SetError(err.Error())
+		ms.delivery.Notify(ctx, job)
 		return
 	}
 
-	job.UpdateProgress(100)
 	job.SetOutput(outputPath)
+	if err := ms.delivery.Upload(ctx, job, outputPath); err != nil {
+		err = models.TimeoutError(ctx, timeout, err)
+		jobLog.Error("Failed to upload output of job %s: %v", job.ID, err)
+		job.SetError(fmt.Sprintf("Failed to upload to %s: %v", ms.cfg.StorageBackend, err))
+		ms.delivery.Notify(ctx, job)
+		return
+	}
+
+	job.UpdateProgress(100)
 	job.UpdateStatus(models.JobStatusCompleted)
-	logger.Info("%s job %s completed successfully (MCP)", jobType, job.ID)
+	jobLog.Info("%s job %s completed successfully (MCP)", jobType, job.ID)
+	ms.delivery.Notify(ctx, job)
 }
 
-func (ms *MCPServer) processMergeJob(job *models.Job, segments []models.VideoSegment) {
-	ms.processJobCommon(job, "merge", func(ctx context.Context, outputPath string) error {
-		return ms.executor.MergeVideos(ctx, segments, outputPath)
+func (ms *MCPServer) processMergeJob(job *models.Job, req models.MergeVideoRequest) {
+	inputs := make([]string, 0, len(req.Segments))
+	for _, seg := range req.Segments {
+		inputs = append(inputs, seg.FilePath)
+	}
+	job.AddFiles(inputs...)
+	ms.processJobCommon(job, "merge", req.OutputOptions, func(ctx context.Context, outputPath string) error {
+		return ms.executor.MergeVideos(ctx, req.Segments, req.OutputOptions, outputPath)
 	})
+	ms.cleanupInputs(job, req.CleanupInputs, inputs...)
 }
 
-func (ms *MCPServer) processOverlayJob(job *models.Job, videoPath string, overlay models.ImageOverlay) {
-	ms.processJobCommon(job, "overlay", func(ctx context.Context, outputPath string) error {
-		return ms.executor.AddImageOverlay(ctx, videoPath, overlay, outputPath)
+func (ms *MCPServer) processOverlayJob(job *models.Job, req models.OverlayRequest) {
+	job.AddFiles(req.VideoPath, req.Overlay.FilePath)
+	ms.processJobCommon(job, "overlay", req.OutputOptions, func(ctx context.Context, outputPath string) error {
+		return ms.executor.AddImageOverlay(ctx, req.VideoPath, req.Overlay, req.OutputOptions, outputPath)
 	})
+	ms.cleanupInputs(job, req.CleanupInputs, req.VideoPath, req.Overlay.FilePath)
 }
 
-func (ms *MCPServer) processAudioJob(job *models.Job, videoPath string, audio models.AudioConfig) {
-	ms.processJobCommon(job, "audio", func(ctx context.Context, outputPath string) error {
-		return ms.executor.AddBackgroundMusic(ctx, videoPath, audio, outputPath)
+func (ms *MCPServer) processAudioJob(job *models.Job, req models.AudioRequest) {
+	job.AddFiles(req.InputPaths()...)
+	ms.processJobCommon(job, "audio", req.OutputOptions, func(ctx context.Context, outputPath string) error {
+		return ms.executor.WithLoudnessNormalization(ctx, req.NormalizeAudio, req.OutputOptions, outputPath, func(target string) error {
+			return ms.executor.AddBackgroundMusic(ctx, req.VideoPath, req.Audio, req.OutputOptions, target)
+		})
 	})
+	ms.cleanupInputs(job, req.CleanupInputs, req.InputPaths()...)
 }
 
 func (ms *MCPServer) processCompleteJob(job *models.Job, req models.CompleteProcessRequest) {
-	ms.processJobCommon(job, "complete process", func(ctx context.Context, outputPath string) error {
+	job.AddFiles(req.InputPaths()...)
+	ms.processJobCommon(job, "complete process", req.OutputOptions, func(ctx context.Context, outputPath string) error {
 		return ms.executor.CompleteProcess(ctx, req, outputPath)
 	})
+	ms.cleanupInputs(job, req.CleanupInputs, req.InputPaths()...)
+}
+
+func (ms *MCPServer) processSilenceRemovalJob(job *models.Job, req models.SilenceRequest) {
+	job.AddFiles(req.VideoPath)
+	ms.processJobCommon(job, "silence removal", req.OutputOptions, func(ctx context.Context, outputPath string) error {
+		return ms.executor.RemoveSilence(ctx, req, outputPath)
+	})
+	ms.cleanupInputs(job, req.CleanupInputs, req.VideoPath)
+}
+
+func (ms *MCPServer) processVerticalJob(job *models.Job, req models.VerticalRequest) {
+	job.AddFiles(req.VideoPath)
+	ms.processJobCommon(job, "vertical conversion", req.OutputOptions, func(ctx context.Context, outputPath string) error {
+		return ms.executor.ConvertToVertical(ctx, req, outputPath)
+	})
+	ms.cleanupInputs(job, req.CleanupInputs, req.VideoPath)
+}
+
+func (ms *MCPServer) processTranscribeJob(job *models.Job, req models.TranscribeRequest) {
+	job.AddFiles(req.VideoPath)
+	ms.processJobCommon(job, "transcription", req.SubtitleOptions(), func(ctx context.Context, outputPath string) error {
+		cues, err := ms.transcribeSpeech(ctx, job, req.VideoPath, req.Language)
+		if err != nil {
+			return err
+		}
+		return transcribe.WriteFile(outputPath, cues)
+	})
+	ms.cleanupInputs(job, req.CleanupInputs, req.VideoPath)
+}
+
+func (ms *MCPServer) processDefectsJob(job *models.Job, req models.DefectsRequest) {
+	job.AddFiles(req.VideoPath)
+	ms.processJobCommon(job, "defect detection", req.ReportOptions(), func(ctx context.Context, outputPath string) error {
+		return ms.executor.ReportDefects(ctx, req, outputPath)
+	})
+	ms.cleanupInputs(job, req.CleanupInputs, req.VideoPath)
+}
+
+func (ms *MCPServer) processAutoSubtitleJob(job *models.Job, req models.AutoSubtitleRequest) {
+	job.AddFiles(req.VideoPath)
+	captionsPath := filepath.Join(ms.cfg.TempDir, job.ID+"-captions.ass")
+	defer os.Remove(captionsPath)
+	ms.processJobCommon(job, "auto-subtitle", req.OutputOptions, func(ctx context.Context, outputPath string) error {
+		cues, err := ms.transcribeSpeech(ctx, job, req.VideoPath, req.Language)
+		if err != nil {
+			return err
+		}
+		job.AddFiles(captionsPath)
+		return ms.executor.BurnCaptions(ctx, req.VideoPath, cues, req.Style, captionsPath, req.OutputOptions, outputPath)
+	})
+	ms.cleanupInputs(job, req.CleanupInputs, req.VideoPath)
+}
+
+// transcribeSpeech extracts the speech of videoPath to the temporary directory and transcribes it
+func (ms *MCPServer) transcribeSpeech(ctx context.Context, job *models.Job, videoPath, language string) ([]transcribe.Cue, error) {
+	speechPath := filepath.Join(ms.cfg.TempDir, job.ID+"-speech.wav")
+	job.AddFiles(speechPath)
+	defer os.Remove(speechPath)
+	if err := ms.executor.ExtractSpeech(ctx, videoPath, speechPath); err != nil {
+		return nil, err
+	}
+	cues, err := ms.transcriber.Transcribe(ctx, speechPath, language)
+	if err != nil {
+		return nil, fmt.Errorf("%s transcription failed: %w", ms.transcriber.Name(), err)
+	}
+	return cues, nil
+}
+
+// cleanupInputs deletes the uploads a completed job read, if requested with cleanup_inputs or CLEANUP_INPUTS
+func (ms *MCPServer) cleanupInputs(job *models.Job, requested *bool, paths ...string) {
+	cleanup := ms.cfg.CleanupInputs
+	if requested != nil {
+		cleanup = *requested
+	}
+	if !cleanup || job.GetStatus().Status != models.JobStatusCompleted {
+		return
+	}
+
+	jobLog := job.Logger()
+	for _, path := range paths {
+		if !uploads.InDir(ms.cfg.UploadDir, path) {
+			continue
+		}
+		if err := uploads.Delete(ms.cfg.UploadDir, filepath.Base(path)); err != nil {
+			if !errors.Is(err, uploads.ErrNotFound) {
+				jobLog.Warn("Failed to delete input %s of job %s: %v", filepath.Base(path), job.ID, err)
+			}
+			continue
+		}
+		jobLog.Info("Deleted input %s of job %s (MCP)", filepath.Base(path), job.ID)
+	}
+}
+
+// checkUploadSize rejects a base64 file whose decoded size would exceed MAX_FILE_SIZE_MB or MAX_UPLOAD_SIZE_MB
+func (ms *MCPServer) checkUploadSize(filename, contentBase64 string) error {
+	size := base64.StdEncoding.DecodedLen(len(contentBase64))
+	if size > ms.cfg.MaxFileSizeMB<<20 {
+		return fmt.Errorf("%s is too large: files may be up to %d MB (MAX_FILE_SIZE_MB)", filename, ms.cfg.MaxFileSizeMB)
+	}
+	if size > ms.cfg.MaxUploadSizeMB<<20 {
+		return fmt.Errorf("%s is too large: uploads may be up to %d MB (MAX_UPLOAD_SIZE_MB)", filename, ms.cfg.MaxUploadSizeMB)
+	}
+	return nil
+}
+
+// saveUpload stores uploaded content in the upload directory under its content-addressed name, with the
+// UPLOAD_TTL_SECONDS expiry, and returns its path, hex SHA-256 and scan status. Content identical to an earlier
+// upload is not written again; existed reports that case. Content that fails the scan is quarantined.
+func (ms *MCPServer) saveUpload(ctx context.Context, filename string, content []byte) (path, sum string, existed bool, scanStatus string, err error) {
+	hash := sha256.Sum256(content)
+	sum = hex.EncodeToString(hash[:])
+	path, existed = uploads.Existing(ms.cfg.UploadDir, sum, filename)
+	if !existed {
+		tempPath := filepath.Join(ms.cfg.UploadDir, uuid.New().String()+filepath.Ext(filename))
+		if err := os.WriteFile(tempPath, content, 0o644); err != nil {
+			return "", "", false, "", err
+		}
+		path, existed, err = uploads.Dedup(tempPath, sum, filename)
+		if err != nil {
+			os.Remove(tempPath)
+			return "", "", false, "", err
+		}
+	}
+
+	if ms.scanner != nil {
+		record, err := uploads.Scan(ctx, ms.scanner, path, ms.cfg.QuarantineDir, existed)
+		if err != nil {
+			return "", "", false, "", err
+		}
+		scanStatus = record.Status
+	}
+
+	ttl := time.Duration(ms.cfg.UploadTTLSeconds) * time.Second
+	if _, err := uploads.ApplyTTL(ms.cfg.UploadDir, path, ttl, existed); err != nil {
+		logger.Warn("Failed to set the expiry of upload %s: %v", filepath.Base(path), err)
+	}
+	return path, sum, existed, scanStatus, nil
 }
 
 // handleUploadFile handles single file upload
@@ -366,31 +1649,42 @@ func (ms *MCPServer) handleUploadFile(ctx context.Context, request mcp.CallToolR
 	if !ok {
 		return mcp.NewToolResultError("content_base64 must be a string"), nil
 	}
+	if err := ms.checkUploadSize(filename, contentBase64); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
 	// Decode base64 content
 	content, err := base64.StdEncoding.DecodeString(contentBase64)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to decode base64: %v", err)), nil
 	}
+	if err := ms.fileTypes.CheckContent(filename, content); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
-	// Generate unique filename
-	ext := filepath.Ext(filename)
-	uniqueFilename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
-	savePath := filepath.Join(ms.cfg.UploadDir, uniqueFilename)
-
-	// Save file
-	if err := os.WriteFile(savePath, content, 0644); err != nil {
+	// Save file, or reuse an identical earlier upload
+	savePath, sum, existed, scanStatus, err := ms.saveUpload(ctx, filename, content)
+	if err != nil {
 		logger.Error("Failed to save uploaded file: %v", err)
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to save file: %v", err)), nil
 	}
 
-	logger.Info("File uploaded successfully via MCP: %s (%d bytes)", uniqueFilename, len(content))
+	message := "File uploaded successfully"
+	if existed {
+		message = "File already uploaded"
+	}
+	logger.Info("%s via MCP: %s (%d bytes)", message, filepath.Base(savePath), len(content))
 
 	response := map[string]any{
-		"file_name": uniqueFilename,
+		"file_name": filepath.Base(savePath),
 		"file_path": savePath,
 		"file_size": len(content),
-		"message":   "File uploaded successfully",
+		"sha256":    sum,
+		"existing":  existed,
+		"message":   message,
+	}
+	if scanStatus != "" {
+		response["scan_status"] = scanStatus
 	}
 
 	responseJSON, _ := sonic.MarshalString(response)
@@ -404,25 +1698,31 @@ func (ms *MCPServer) handleUploadMultipleFiles(ctx context.Context, request mcp.
 		return mcp.NewToolResultError("invalid arguments format"), nil
 	}
 
-	filesJSON, ok := args["files_json"].(string)
-	if !ok {
-		return mcp.NewToolResultError("files_json must be a string"), nil
-	}
-
-	// Parse files JSON
 	type FileUpload struct {
 		Filename      string `json:"filename"`
 		ContentBase64 string `json:"content_base64"`
 	}
 	var files []FileUpload
-	if err := sonic.UnmarshalString(filesJSON, &files); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse files_json: %v", err)), nil
+	if err := decodeArg(args, "files", &files); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
 	if len(files) == 0 {
 		return mcp.NewToolResultError("At least one file is required"), nil
 	}
 
+	// Reject the whole call when a file or all files together exceed the limits
+	var total int
+	for _, file := range files {
+		if err := ms.checkUploadSize(file.Filename, file.ContentBase64); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		total += base64.StdEncoding.DecodedLen(len(file.ContentBase64))
+	}
+	if total > ms.cfg.MaxUploadSizeMB<<20 {
+		return mcp.NewToolResultError(fmt.Sprintf("files exceed the limit of %d MB per request (MAX_UPLOAD_SIZE_MB); upload them in several calls", ms.cfg.MaxUploadSizeMB)), nil
+	}
+
 	uploadedFiles := make([]map[string]any, 0, len(files))
 
 	for _, file := range files {
@@ -432,25 +1732,31 @@ func (ms *MCPServer) handleUploadMultipleFiles(ctx context.Context, request mcp.
 			logger.Error("Failed to decode base64 for file %s: %v", file.Filename, err)
 			continue
 		}
+		if err := ms.fileTypes.CheckContent(file.Filename, content); err != nil {
+			logger.Error("Rejected uploaded file %s: %v", file.Filename, err)
+			continue
+		}
 
-		// Generate unique filename
-		ext := filepath.Ext(file.Filename)
-		uniqueFilename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
-		savePath := filepath.Join(ms.cfg.UploadDir, uniqueFilename)
-
-		// Save file
-		if err := os.WriteFile(savePath, content, 0644); err != nil {
+		// Save file, or reuse an identical earlier upload
+		savePath, sum, existed, scanStatus, err := ms.saveUpload(ctx, file.Filename, content)
+		if err != nil {
 			logger.Error("Failed to save uploaded file %s: %v", file.Filename, err)
 			continue
 		}
 
-		logger.Info("File uploaded successfully via MCP: %s (%d bytes)", uniqueFilename, len(content))
+		logger.Info("File uploaded via MCP: %s (%d bytes, existing: %t)", filepath.Base(savePath), len(content), existed)
 
-		uploadedFiles = append(uploadedFiles, map[string]any{
-			"file_name": uniqueFilename,
+		uploaded := map[string]any{
+			"file_name": filepath.Base(savePath),
 			"file_path": savePath,
 			"file_size": len(content),
-		})
+			"sha256":    sum,
+			"existing":  existed,
+		}
+		if scanStatus != "" {
+			uploaded["scan_status"] = scanStatus
+		}
+		uploadedFiles = append(uploadedFiles, uploaded)
 	}
 
 	if len(uploadedFiles) == 0 {
@@ -465,3 +1771,35 @@ func (ms *MCPServer) handleUploadMultipleFiles(ctx context.Context, request mcp.
 	responseJSON, _ := sonic.MarshalString(response)
 	return mcp.NewToolResultText(responseJSON), nil
 }
+
+// handleDownloadMedia fetches a media file from a URL into the upload directory
+func (ms *MCPServer) handleDownloadMedia(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, ok := request.Params.Arguments.(map[string]any)
+	if !ok {
+		return mcp.NewToolResultError("invalid arguments format"), nil
+	}
+
+	url, ok := args["url"].(string)
+	if !ok {
+		return mcp.NewToolResultError("url must be a string"), nil
+	}
+
+	file, err := downloader.DownloadMedia(ctx, ms.urls, url, ms.cfg.UploadDir, int64(ms.cfg.MaxDownloadSizeMB)<<20)
+	if err != nil {
+		logger.Error("Failed to download media from %s: %v", url, err)
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to download media: %v", err)), nil
+	}
+
+	logger.Info("Media downloaded successfully via MCP: %s (%d bytes)", file.FileName, file.FileSize)
+
+	response := map[string]any{
+		"file_name":    file.FileName,
+		"file_path":    file.FilePath,
+		"file_size":    file.FileSize,
+		"content_type": file.ContentType,
+		"message":      "Media downloaded successfully",
+	}
+
+	responseJSON, _ := sonic.MarshalString(response)
+	return mcp.NewToolResultText(responseJSON), nil
+}
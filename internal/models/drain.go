@@ -0,0 +1,61 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"govid/pkg/logger"
+)
+
+// ErrDraining is returned for requests that would create a job once the instance drains
+var ErrDraining = errors.New("this instance is shutting down and no longer accepts jobs; retry the request")
+
+// Drain records whether the instance stopped accepting jobs, ahead of a shutdown. The HTTP API and the MCP
+// server of an instance share it, so that neither takes in jobs once it drains. The zero value accepts jobs.
+type Drain struct {
+	mu         sync.Mutex
+	since      time.Time            // zero while jobs are accepted
+	stopIntake []context.CancelFunc // stop the consumers of the job queue
+}
+
+// Draining reports whether the instance stopped accepting jobs
+func (d *Drain) Draining() bool {
+	return !d.Since().IsZero()
+}
+
+// Since returns when the instance started draining, or the zero time while it accepts jobs
+func (d *Drain) Since() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.since
+}
+
+// IntakeContext returns a context for taking in jobs that is cancelled when the instance starts draining
+func (d *Drain) IntakeContext(ctx context.Context) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.since.IsZero() {
+		cancel()
+	} else {
+		d.stopIntake = append(d.stopIntake, cancel)
+	}
+	return ctx
+}
+
+// Start stops accepting jobs, if it had not already, and returns since when the instance drains
+func (d *Drain) Start(by string) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.since.IsZero() {
+		d.since = time.Now()
+		for _, cancel := range d.stopIntake {
+			cancel()
+		}
+		d.stopIntake = nil
+		logger.Info("Draining: no longer accepting jobs (requested by %s)", by)
+	}
+	return d.since
+}
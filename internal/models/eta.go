@@ -0,0 +1,101 @@
+package models
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// throughputSamples is how many of the latest completed jobs of a type the ETA of its jobs is estimated from
+const throughputSamples = 20
+
+// QueueStatus returns the status of a job with its place in the FFmpeg queue and its estimated time to finish.
+// queue lists the IDs of the jobs waiting for an FFmpeg slot, in the order they get one, and slots is how many
+// FFmpeg commands run at once.
+func (s *JobStore) QueueStatus(job *Job, queue []string, slots int) JobStatusResponse {
+	status := job.GetStatus()
+	position := slices.Index(queue, job.ID)
+	if position >= 0 {
+		status.QueuePosition = position + 1
+	}
+	if status.Status != JobStatusProcessing {
+		return status
+	}
+
+	now := time.Now()
+	rates := s.throughput()
+	eta, ok := remainingSeconds(job, rates, now)
+	if !ok {
+		return status
+	}
+	if position >= 0 {
+		// A queued job gets a slot once the running jobs and those ahead of it have done enough work to free one
+		var ahead float64
+		for _, other := range s.List() {
+			if other.GetStatus().Status != JobStatusProcessing {
+				continue
+			}
+			if i := slices.Index(queue, other.ID); i >= position {
+				continue
+			}
+			remaining, _ := remainingSeconds(other, rates, now)
+			ahead += remaining
+		}
+		eta += ahead / float64(max(slots, 1))
+	}
+	seconds := int(math.Ceil(eta))
+	status.ETASeconds = &seconds
+	return status
+}
+
+// throughput returns the processing seconds per second of input of each job type, over the latest completed
+// jobs of the type
+func (s *JobStore) throughput() map[string]float64 {
+	type totals struct {
+		jobs                     int
+		processing, inputSeconds float64
+	}
+	byType := make(map[string]*totals)
+	for _, job := range s.List() {
+		job.mu.RLock()
+		jobType, input, processing, status := job.Type, job.InputSeconds, job.Processing, job.Status
+		job.mu.RUnlock()
+		if status != JobStatusCompleted || jobType == "" || input <= 0 || processing <= 0 {
+			continue
+		}
+		t := byType[jobType]
+		if t == nil {
+			t = &totals{}
+			byType[jobType] = t
+		}
+		if t.jobs < throughputSamples {
+			t.jobs++
+			t.processing += processing.Seconds()
+			t.inputSeconds += input
+		}
+	}
+
+	rates := make(map[string]float64, len(byType))
+	for jobType, t := range byType {
+		rates[jobType] = t.processing / t.inputSeconds
+	}
+	return rates
+}
+
+// remainingSeconds estimates how long a processing job still runs from the throughput of its type. It returns
+// false if the job's type has no history or its input duration is unknown.
+func remainingSeconds(job *Job, rates map[string]float64, now time.Time) (float64, bool) {
+	job.mu.RLock()
+	jobType, input, started := job.Type, job.InputSeconds, job.StartedAt
+	job.mu.RUnlock()
+
+	rate, ok := rates[jobType]
+	if !ok || input <= 0 {
+		return 0, false
+	}
+	estimate := rate * input
+	if !started.IsZero() {
+		estimate -= now.Sub(started).Seconds()
+	}
+	return max(estimate, 0), true
+}
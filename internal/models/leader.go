@@ -0,0 +1,243 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+
+	"govid/pkg/logger"
+)
+
+// Background tasks that must run in one instance only, such as the cleanup and the cron schedules, are led by
+// the holder of a lease in the job store's directory. The holder renews the lease a few times per TTL; when it
+// stops, another instance takes the lease over once it expires. Instances must keep their clocks in sync.
+//
+// Every change of a lease, whether taking, renewing or releasing it, publishes the next generation of its
+// record as a new file, hard-linked into place so that it fails if that generation exists. Of instances
+// changing the same generation at once, exactly one succeeds; the others see its record on their next try.
+// Links are atomic on local file systems and on NFS alike.
+
+// lease is the record of a lease in the job store's directory
+type lease struct {
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Leader competes for a named lease of the job store, to lead the singleton background tasks
+type Leader struct {
+	jp     *JobPersistence // nil for a store without persistence, where this instance always leads
+	name   string
+	holder string
+	ttl    time.Duration
+
+	mu    sync.RWMutex
+	until time.Time // when the lease this instance holds runs out
+}
+
+// NewLeader creates a contender for the lease called name, held for ttl at a time. Start starts competing.
+func (s *JobStore) NewLeader(name string, ttl time.Duration) *Leader {
+	return &Leader{
+		jp:     s.persistence,
+		name:   name,
+		holder: uuid.New().String(),
+		ttl:    ttl,
+	}
+}
+
+// leaseDir returns the directory of the lease records
+func (jp *JobPersistence) leaseDir() string {
+	return filepath.Join(jp.jobsDir, "leases")
+}
+
+// leasePath returns the file of a generation of the lease called name
+func (jp *JobPersistence) leasePath(name string, generation uint64) string {
+	return filepath.Join(jp.leaseDir(), fmt.Sprintf("%s.%d.json", name, generation))
+}
+
+// IsLeader reports whether this instance holds the lease
+func (l *Leader) IsLeader() bool {
+	if l.jp == nil {
+		return true
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return time.Now().Before(l.until)
+}
+
+// Start takes the lease if it is free, then renews or competes for it every third of its TTL in the
+// background until ctx is done, when it releases the lease
+func (l *Leader) Start(ctx context.Context) {
+	if l.jp == nil {
+		return
+	}
+	l.compete()
+	go func() {
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				l.release()
+				return
+			case <-ticker.C:
+				l.compete()
+			}
+		}
+	}()
+}
+
+// compete takes the lease if it is free or expired, or renews it if this instance holds it
+func (l *Leader) compete() {
+	wasLeader := l.IsLeader()
+	start := time.Now()
+	held, err := l.acquire(start)
+	if err != nil {
+		logger.Error("Failed to take the %s lease: %v", l.name, err)
+	}
+
+	l.mu.Lock()
+	if held {
+		l.until = start.Add(l.ttl)
+	} else {
+		l.until = time.Time{}
+	}
+	l.mu.Unlock()
+
+	switch {
+	case held && !wasLeader:
+		logger.Info("This instance now leads %s", l.name)
+	case !held && wasLeader:
+		logger.Warn("This instance no longer leads %s", l.name)
+	}
+}
+
+// acquire publishes the next generation of the lease for this instance unless another holds it. It fails to
+// lead if another instance changed the lease first.
+func (l *Leader) acquire(now time.Time) (bool, error) {
+	generation, current, err := l.jp.readLease(l.name)
+	if err != nil {
+		return false, err
+	}
+	if current != nil && current.Holder != l.holder && now.Before(current.ExpiresAt) {
+		return false, nil
+	}
+	return l.jp.writeLease(l.name, generation+1, lease{Holder: l.holder, ExpiresAt: now.Add(l.ttl)})
+}
+
+// release gives the lease up, if this instance holds it, so another instance can take it at once
+func (l *Leader) release() {
+	l.mu.Lock()
+	l.until = time.Time{}
+	l.mu.Unlock()
+
+	generation, current, err := l.jp.readLease(l.name)
+	if err == nil && current != nil && current.Holder == l.holder {
+		// An expired record frees the lease without letting its generation start over
+		_, err = l.jp.writeLease(l.name, generation+1, lease{})
+	}
+	if err != nil {
+		logger.Error("Failed to release the %s lease: %v", l.name, err)
+	}
+}
+
+// readLease returns the latest generation of the lease called name and its record, or 0 and nil if there is
+// none. A corrupt record is returned as nil, to be taken over like an expired one.
+func (jp *JobPersistence) readLease(name string) (uint64, *lease, error) {
+	// The latest generation is removed once a newer one is published, so it is looked up again if it vanishes
+	for range 3 {
+		entries, err := os.ReadDir(jp.leaseDir())
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil, nil
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		var latest uint64
+		for _, entry := range entries {
+			if generation, ok := leaseGeneration(name, entry.Name()); ok && generation > latest {
+				latest = generation
+			}
+		}
+		if latest == 0 {
+			return 0, nil, nil
+		}
+
+		content, err := os.ReadFile(jp.leasePath(name, latest))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		var current lease
+		if err := sonic.Unmarshal(content, &current); err != nil {
+			return latest, nil, nil
+		}
+		return latest, &current, nil
+	}
+	return 0, nil, fmt.Errorf("lease %s changed while it was read", name)
+}
+
+// writeLease publishes a generation of the lease called name, and reports false if it exists already. The
+// generations before it are removed.
+func (jp *JobPersistence) writeLease(name string, generation uint64, record lease) (bool, error) {
+	content, err := sonic.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(jp.leaseDir(), 0o755); err != nil {
+		return false, err
+	}
+	// The record is complete before it is linked, so readers never see a partial one
+	temp, err := os.CreateTemp(jp.leaseDir(), name+".*.tmp")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(temp.Name())
+	_, err = temp.Write(content)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := os.Link(temp.Name(), jp.leasePath(name, generation)); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if entries, err := os.ReadDir(jp.leaseDir()); err == nil {
+		for _, entry := range entries {
+			if older, ok := leaseGeneration(name, entry.Name()); ok && older < generation {
+				os.Remove(filepath.Join(jp.leaseDir(), entry.Name()))
+			}
+		}
+	}
+	return true, nil
+}
+
+// leaseGeneration returns the generation of a record of the lease called name from its file name
+func leaseGeneration(name, filename string) (uint64, bool) {
+	middle, ok := strings.CutPrefix(filename, name+".")
+	if !ok {
+		return 0, false
+	}
+	middle, ok = strings.CutSuffix(middle, ".json")
+	if !ok {
+		return 0, false
+	}
+	generation, err := strconv.ParseUint(middle, 10, 64)
+	return generation, err == nil && generation > 0
+}
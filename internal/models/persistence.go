@@ -27,16 +27,83 @@ func NewJobPersistence(jobsDir string) *JobPersistence {
 
 // jobData is the serializable representation of a job
 type jobData struct {
-	ID            string         `json:"id"`
-	Status        JobStatus      `json:"status"`
-	Progress      int            `json:"progress"`
-	OutputPath    string         `json:"output_path"`
-	S3URL         string         `json:"s3_url"`
-	WebhookURL    string         `json:"webhook_url"`
-	WebhookHeader *WebhookHeader `json:"webhook_header,omitempty"`
-	Error         string         `json:"error"`
-	CreatedAt     string         `json:"created_at"`
-	UpdatedAt     string         `json:"updated_at"`
+	ID             string            `json:"id"`
+	Status         JobStatus         `json:"status"`
+	Progress       int               `json:"progress"`
+	OutputPath     string            `json:"output_path"`
+	S3URL          string            `json:"s3_url"`
+	S3URLExpires   string            `json:"s3_url_expires,omitempty"`
+	StorageKey     string            `json:"storage_key,omitempty"`
+	StorageBucket  string            `json:"storage_bucket,omitempty"`
+	ThumbnailURL   string            `json:"thumbnail_url,omitempty"`
+	MetadataURL    string            `json:"metadata_url,omitempty"`
+	WebhookURL     string            `json:"webhook_url"`
+	WebhookHeader  *WebhookHeader    `json:"webhook_header,omitempty"` // written by earlier versions, read into WebhookHeaders
+	WebhookHeaders map[string]string `json:"webhook_headers,omitempty"`
+	Upload         *StorageOptions   `json:"upload,omitempty"`
+	UploadName     string            `json:"upload_original_name,omitempty"` // OriginalName of Upload, which has no JSON field
+	CreatedBy      string            `json:"created_by,omitempty"`
+	RequestID      string            `json:"request_id,omitempty"`
+	RetryOf        string            `json:"retry_of,omitempty"`
+	Type           string            `json:"type,omitempty"`
+	InputSeconds   float64           `json:"input_seconds,omitempty"`
+	StartedAt      string            `json:"started_at,omitempty"`
+	Processing     float64           `json:"processing_seconds,omitempty"`
+	Steps          []StepStatus      `json:"steps,omitempty"`
+	DependsOn      []string          `json:"depends_on,omitempty"`
+	RunAt          string            `json:"run_at,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	ExpiresAt      string            `json:"expires_at,omitempty"`
+	TTLSeconds     float64           `json:"ttl_seconds,omitempty"`
+	Work           *JobWork          `json:"work,omitempty"`
+	Error          string            `json:"error"`
+	CreatedAt      string            `json:"created_at"`
+	UpdatedAt      string            `json:"updated_at"`
+}
+
+// webhookHeaders returns the webhook headers of a job record, including the single header of earlier versions
+func (d *jobData) webhookHeaders() map[string]string {
+	options := DeliveryOptions{WebhookHeaders: d.WebhookHeaders, WebhookHeader: d.WebhookHeader}
+	return options.Headers()
+}
+
+// job reconstructs the job of a record
+func (d *jobData) job() *Job {
+	job := NewJob(d.ID)
+	job.Status = d.Status
+	job.Progress = d.Progress
+	job.OutputPath = d.OutputPath
+	job.S3URL = d.S3URL
+	job.StorageKey = d.StorageKey
+	job.StorageBucket = d.StorageBucket
+	job.ThumbnailURL = d.ThumbnailURL
+	job.MetadataURL = d.MetadataURL
+	job.S3URLExpires, _ = time.Parse("2006-01-02T15:04:05Z07:00", d.S3URLExpires)
+	job.WebhookURL = d.WebhookURL
+	job.WebhookHeaders = d.webhookHeaders()
+	if d.Upload != nil {
+		upload := *d.Upload
+		upload.OriginalName = d.UploadName
+		job.Upload = &upload
+	}
+	job.CreatedBy = d.CreatedBy
+	job.RequestID = d.RequestID
+	job.RetryOf = d.RetryOf
+	job.Type = d.Type
+	job.InputSeconds = d.InputSeconds
+	job.StartedAt, _ = time.Parse("2006-01-02T15:04:05Z07:00", d.StartedAt)
+	job.Processing = time.Duration(d.Processing * float64(time.Second))
+	job.Steps = d.Steps
+	job.DependsOn = d.DependsOn
+	job.RunAt, _ = time.Parse("2006-01-02T15:04:05Z07:00", d.RunAt)
+	job.Labels = d.Labels
+	job.ExpiresAt, _ = time.Parse("2006-01-02T15:04:05Z07:00", d.ExpiresAt)
+	job.TTL = time.Duration(d.TTLSeconds * float64(time.Second))
+	job.Work = d.Work
+	job.Error = d.Error
+	job.CreatedAt, _ = time.Parse("2006-01-02T15:04:05Z07:00", d.CreatedAt)
+	job.UpdatedAt, _ = time.Parse("2006-01-02T15:04:05Z07:00", d.UpdatedAt)
+	return job
 }
 
 // SaveJob saves a job to disk
@@ -47,16 +114,48 @@ func (jp *JobPersistence) SaveJob(job *Job) error {
 	status := job.GetStatus()
 
 	data := jobData{
-		ID:            status.JobID,
-		Status:        status.Status,
-		Progress:      status.Progress,
-		OutputPath:    status.OutputPath,
-		S3URL:         status.S3URL,
-		WebhookURL:    job.WebhookURL,
-		WebhookHeader: job.WebhookHeader,
-		Error:         status.Error,
-		CreatedAt:     status.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		UpdatedAt:     status.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		ID:             status.JobID,
+		Status:         status.Status,
+		Progress:       status.Progress,
+		OutputPath:     status.OutputPath,
+		S3URL:          status.S3URL,
+		StorageKey:     job.StorageKey,
+		StorageBucket:  job.StorageBucket,
+		ThumbnailURL:   status.ThumbnailURL,
+		MetadataURL:    status.MetadataURL,
+		WebhookURL:     job.WebhookURL,
+		WebhookHeaders: job.WebhookHeaders,
+		Upload:         job.Upload,
+		CreatedBy:      status.CreatedBy,
+		RequestID:      status.RequestID,
+		RetryOf:        status.RetryOf,
+		Type:           job.Type,
+		InputSeconds:   job.InputSeconds,
+		Processing:     job.Processing.Seconds(),
+		Steps:          status.Steps,
+		DependsOn:      status.DependsOn,
+		Labels:         status.Labels,
+		TTLSeconds:     job.TTL.Seconds(),
+		Work:           job.Work,
+		Error:          status.Error,
+		CreatedAt:      status.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:      status.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	if status.S3URLExpiresAt != nil {
+		data.S3URLExpires = status.S3URLExpiresAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	if status.StartedAt != nil {
+		data.StartedAt = status.StartedAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	if status.RunAt != nil {
+		data.RunAt = status.RunAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	if job.Upload != nil {
+		data.UploadName = job.Upload.OriginalName
+	}
+	if status.ExpiresAt != nil {
+		data.ExpiresAt = status.ExpiresAt.Format("2006-01-02T15:04:05Z07:00")
 	}
 
 	filePath := filepath.Join(jp.jobsDir, fmt.Sprintf("%s.json", status.JobID))
@@ -85,6 +184,11 @@ func (jp *JobPersistence) SaveJob(job *Job) error {
 
 // LoadJob loads a single job from disk
 func (jp *JobPersistence) LoadJob(jobID string) (*Job, error) {
+	return jp.readJob(jobID)
+}
+
+// readJob reads the record of a job as it is
+func (jp *JobPersistence) readJob(jobID string) (*Job, error) {
 	jp.mu.RLock()
 	defer jp.mu.RUnlock()
 
@@ -101,19 +205,7 @@ func (jp *JobPersistence) LoadJob(jobID string) (*Job, error) {
 		return nil, err
 	}
 
-	// Reconstruct the job
-	job := NewJob(data.ID)
-	job.Status = data.Status
-	job.Progress = data.Progress
-	job.OutputPath = data.OutputPath
-	job.S3URL = data.S3URL
-	job.WebhookURL = data.WebhookURL
-	job.WebhookHeader = data.WebhookHeader
-	job.Error = data.Error
-	job.CreatedAt, _ = time.Parse("2006-01-02T15:04:05Z07:00", data.CreatedAt)
-	job.UpdatedAt, _ = time.Parse("2006-01-02T15:04:05Z07:00", data.UpdatedAt)
-
-	return job, nil
+	return data.job(), nil
 }
 
 // LoadAllJobs loads all jobs from disk
@@ -149,17 +241,7 @@ func (jp *JobPersistence) LoadAllJobs() map[string]*Job {
 			continue
 		}
 
-		job := NewJob(data.ID)
-		job.Status = data.Status
-		job.Progress = data.Progress
-		job.OutputPath = data.OutputPath
-		job.S3URL = data.S3URL
-		job.WebhookURL = data.WebhookURL
-		job.WebhookHeader = data.WebhookHeader
-		job.Error = data.Error
-		job.CreatedAt, _ = time.Parse("2006-01-02T15:04:05Z07:00", data.CreatedAt)
-		job.UpdatedAt, _ = time.Parse("2006-01-02T15:04:05Z07:00", data.UpdatedAt)
-
+		job := data.job()
 		jobs[job.ID] = job
 		logger.Debug("Loaded job from disk: %s", job.ID)
 	}
@@ -179,11 +261,24 @@ func (jp *JobPersistence) DeleteJob(jobID string) error {
 		logger.Error("Failed to delete job file %s: %v", filePath, err)
 		return err
 	}
+	if err := os.Remove(jp.LogPath(jobID)); err != nil && !os.IsNotExist(err) {
+		logger.Error("Failed to delete log of job %s: %v", jobID, err)
+	}
+	for _, marker := range []string{jp.claimPath(jobID), jp.cancelPath(jobID)} {
+		if err := os.Remove(marker); err != nil && !os.IsNotExist(err) {
+			logger.Error("Failed to delete %s: %v", marker, err)
+		}
+	}
 
 	logger.Debug("Job %s deleted from disk", jobID)
 	return nil
 }
 
+// LogPath returns the file holding the FFmpeg output of a job
+func (jp *JobPersistence) LogPath(jobID string) string {
+	return filepath.Join(jp.jobsDir, "logs", jobID+".log")
+}
+
 // GetJobsDir returns the jobs directory path
 func (jp *JobPersistence) GetJobsDir() string {
 	return jp.jobsDir
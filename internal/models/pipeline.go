@@ -0,0 +1,376 @@
+package models
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// PipelineStepType represents the kind of work a pipeline step does
+type PipelineStepType string
+
+const (
+	StepTrim      PipelineStepType = "trim"
+	StepMerge     PipelineStepType = "merge"
+	StepOverlay   PipelineStepType = "overlay"
+	StepText      PipelineStepType = "text"
+	StepAudio     PipelineStepType = "audio"
+	StepSubtitles PipelineStepType = "subtitles"
+	StepTranscode PipelineStepType = "transcode"
+	StepUpload    PipelineStepType = "upload"
+	StepEffect    PipelineStepType = "effect"
+)
+
+// StepRefPrefix marks a step input that is the output of an earlier step, as in step:merged
+const StepRefPrefix = "step:"
+
+// MaxPipelineSteps bounds the number of steps of a pipeline
+const MaxPipelineSteps = 20
+
+// stepIDPattern matches the ids steps may be given
+var stepIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// PipelineStep represents one step of a pipeline. Each step reads its inputs, which are files or the outputs
+// of earlier steps, and writes one output; an upload step stores its input and passes it on as its output.
+type PipelineStep struct {
+	ID     string           `json:"id,omitempty" example:"merged"`           // name other steps refer to the output by; defaults to step<n>, counting from 1
+	Type   PipelineStepType `json:"type" example:"merge"`                    // trim, merge, overlay, text, audio, subtitles, effect, transcode, or upload
+	Inputs []string         `json:"inputs,omitempty" example:"step:trimmed"` // files, or step:<id> for the output of an earlier step; defaults to the output of the previous step
+
+	// Trim
+	StartTime float64 `json:"start_time,omitempty" example:"0"` // in seconds
+	EndTime   float64 `json:"end_time,omitempty" example:"10"`  // in seconds, 0 means end of video
+
+	Overlay   *ImageOverlay   `json:"overlay,omitempty"`   // image of an overlay step
+	Text      *TextOverlay    `json:"text,omitempty"`      // text of a text step
+	Audio     *AudioConfig    `json:"audio,omitempty"`     // music of an audio step
+	Subtitles *SubtitleFile   `json:"subtitles,omitempty"` // subtitles a subtitles step burns in
+	Output    *OutputOptions  `json:"output,omitempty"`    // format and encoding of a transcode step; defaults to the request's
+	Storage   *StorageOptions `json:"storage,omitempty"`   // object key and headers of an upload step
+
+	TemplateEffect *TemplateEffect `json:"template_effect,omitempty"` // built-in graphics of an effect step
+}
+
+// SubtitleFile represents a subtitle file burned into a video, such as the output of a transcription job
+type SubtitleFile struct {
+	FilePath string `json:"file_path" example:"/outputs/captions.srt"` // local .srt, .vtt or .ass file
+}
+
+// subtitleExtensions are the subtitle files that can be burned in
+var subtitleExtensions = []string{".srt", ".vtt", ".ass"}
+
+// Validate checks that the subtitle file is of a supported format
+func (s *SubtitleFile) Validate() error {
+	if s.FilePath == "" {
+		return fmt.Errorf("subtitles.file_path is required")
+	}
+	// A job_output reference is resolved once the transcription job completes
+	if _, ok := JobOutputID(s.FilePath); ok {
+		return nil
+	}
+	if !slices.Contains(subtitleExtensions, strings.ToLower(filepath.Ext(s.FilePath))) {
+		return fmt.Errorf("subtitles.file_path must be an .srt, .vtt or .ass file")
+	}
+	return nil
+}
+
+// EffectType represents a built-in graphic of an effect step
+type EffectType string
+
+const (
+	EffectLowerThird EffectType = "lower_third" // name and subtitle sliding in near the bottom of the frame
+	EffectEndCard    EffectType = "end_card"    // logo and call to action over the dimmed end of the video
+)
+
+// TemplateEffect represents a built-in composition drawn over a video, laid out relative to the frame size so
+// that it looks the same at any resolution
+type TemplateEffect struct {
+	Type        EffectType      `json:"type" example:"lower_third"`                      // lower_third or end_card
+	Title       string          `json:"title" example:"Jane Doe"`                        // name of a lower third, or call to action of an end card
+	Subtitle    string          `json:"subtitle,omitempty" example:"Head of Research"`   // smaller line under the title
+	Position    OverlayPosition `json:"position,omitempty" example:"bottom-left"`        // side of a lower third, bottom-left or bottom-right (default bottom-left)
+	StartTime   float64         `json:"start_time,omitempty" example:"1"`                // when a lower third appears (seconds)
+	Duration    float64         `json:"duration,omitempty" example:"5"`                  // seconds shown, 1-60 (default 5); an end card covers the end of the video
+	LogoPath    string          `json:"logo_path,omitempty" example:"/uploads/logo.png"` // image shown above the call to action of an end card
+	LogoWidth   int             `json:"logo_width,omitempty" example:"320"`              // in pixels, 16-3840 (default 320)
+	AccentColor string          `json:"accent_color,omitempty" example:"#1E88E5"`        // color name or #RRGGBB of the box behind the title (default #1E88E5)
+	FontColor   string          `json:"font_color,omitempty" example:"white"`            // color name or #RRGGBB (default white)
+	Font        string          `json:"font,omitempty" example:"Montserrat-Bold"`        // name of an uploaded font (default FFmpeg's font)
+	FontFile    string          `json:"-"`                                               // file of Font, found when the effect is drawn
+}
+
+// Validate checks the type, text, layout and timing of the effect
+func (e *TemplateEffect) Validate() error {
+	switch e.Type {
+	case EffectLowerThird:
+		if e.LogoPath != "" {
+			return fmt.Errorf("logo_path is only used by end_card")
+		}
+	case EffectEndCard:
+		if e.Position != "" || e.StartTime != 0 {
+			return fmt.Errorf("position and start_time are only used by lower_third")
+		}
+	default:
+		return fmt.Errorf("type must be lower_third or end_card")
+	}
+	if strings.TrimSpace(e.Title) == "" {
+		return fmt.Errorf("title is required")
+	}
+	for _, line := range []string{e.Title, e.Subtitle} {
+		if len(line) > maxTextLength || strings.ContainsFunc(line, unicode.IsControl) {
+			return fmt.Errorf("title and subtitle must be at most %d characters on a single line", maxTextLength)
+		}
+	}
+	switch e.Position {
+	case "", PositionBottomLeft, PositionBottomRight:
+	default:
+		return fmt.Errorf("position must be bottom-left or bottom-right")
+	}
+	if e.StartTime < 0 {
+		return fmt.Errorf("start_time must not be negative")
+	}
+	if e.Duration != 0 && (e.Duration < 1 || e.Duration > 60) {
+		return fmt.Errorf("duration must be between 1 and 60")
+	}
+	if e.LogoWidth != 0 && (e.LogoWidth < 16 || e.LogoWidth > 3840) {
+		return fmt.Errorf("logo_width must be between 16 and 3840")
+	}
+	for _, color := range []string{e.AccentColor, e.FontColor} {
+		if color != "" && !backgroundColorPattern.MatchString(color) {
+			return fmt.Errorf("accent_color and font_color must be a color name or #RRGGBB")
+		}
+	}
+	return nil
+}
+
+// PipelineRequest represents an ordered list of steps run as one job. The output options encode every step
+// except transcode steps that set their own; the job's output is that of the last step that is not an upload.
+type PipelineRequest struct {
+	Steps []PipelineStep `json:"steps" binding:"required,min=1"`
+	OutputOptions
+	DeliveryOptions
+}
+
+// Validate checks the steps and their inputs, filling in default step ids and inputs. Steps may only refer to
+// earlier steps, so the pipeline runs in order.
+func (r *PipelineRequest) Validate() error {
+	if len(r.Steps) == 0 {
+		return fmt.Errorf("at least 1 step required")
+	}
+	if len(r.Steps) > MaxPipelineSteps {
+		return fmt.Errorf("at most %d steps allowed", MaxPipelineSteps)
+	}
+
+	seen := make(map[string]bool, len(r.Steps))
+	encodes := false
+	for i := range r.Steps {
+		step := &r.Steps[i]
+		if step.ID == "" {
+			step.ID = fmt.Sprintf("step%d", i+1)
+		}
+		if err := r.validateStep(i, seen); err != nil {
+			return fmt.Errorf("step %s: %w", step.ID, err)
+		}
+		seen[step.ID] = true
+		encodes = encodes || step.Type != StepUpload
+	}
+	if !encodes {
+		return fmt.Errorf("at least 1 step other than upload required")
+	}
+	return nil
+}
+
+// validateStep checks step i, given the ids of the steps before it
+func (r *PipelineRequest) validateStep(i int, earlier map[string]bool) error {
+	step := &r.Steps[i]
+	if !stepIDPattern.MatchString(step.ID) {
+		return fmt.Errorf("id must be 1-64 letters, digits, '-' or '_'")
+	}
+	if earlier[step.ID] {
+		return fmt.Errorf("id is used by an earlier step")
+	}
+
+	if len(step.Inputs) == 0 {
+		if i == 0 {
+			return fmt.Errorf("inputs is required for the first step")
+		}
+		step.Inputs = []string{StepRefPrefix + r.Steps[i-1].ID}
+	}
+	for _, input := range step.Inputs {
+		ref, isRef := strings.CutPrefix(input, StepRefPrefix)
+		switch {
+		case input == "":
+			return fmt.Errorf("inputs must not be empty")
+		case isRef && !earlier[ref]:
+			return fmt.Errorf("input %s does not name an earlier step", input)
+		}
+	}
+
+	if step.Type == StepMerge {
+		if len(step.Inputs) < 2 {
+			return fmt.Errorf("merge needs at least 2 inputs")
+		}
+	} else if len(step.Inputs) != 1 {
+		return fmt.Errorf("%s takes exactly 1 input", step.Type)
+	}
+
+	switch step.Type {
+	case StepTrim:
+		segment := VideoSegment{FilePath: step.Inputs[0], StartTime: step.StartTime, EndTime: step.EndTime}
+		return segment.Validate()
+	case StepMerge, StepTranscode:
+		return nil
+	case StepOverlay:
+		if step.Overlay == nil {
+			return fmt.Errorf("overlay is required")
+		}
+		return step.Overlay.Validate()
+	case StepText:
+		if step.Text == nil {
+			return fmt.Errorf("text is required")
+		}
+		return step.Text.Validate()
+	case StepAudio:
+		if step.Audio == nil {
+			return fmt.Errorf("audio is required")
+		}
+		return step.Audio.Validate()
+	case StepSubtitles:
+		if step.Subtitles == nil {
+			return fmt.Errorf("subtitles is required")
+		}
+		return step.Subtitles.Validate()
+	case StepEffect:
+		if step.TemplateEffect == nil {
+			return fmt.Errorf("template_effect is required")
+		}
+		return step.TemplateEffect.Validate()
+	case StepUpload:
+		if step.Storage != nil {
+			return step.Storage.Validate()
+		}
+		return nil
+	default:
+		return fmt.Errorf("type must be trim, merge, overlay, text, audio, subtitles, effect, transcode, or upload")
+	}
+}
+
+// StepOptions returns the output options step i is encoded with
+func (r *PipelineRequest) StepOptions(i int) OutputOptions {
+	if step := r.Steps[i]; step.Type == StepTranscode && step.Output != nil {
+		// The request names the job's output file
+		opts := *step.Output
+		opts.OutputName, opts.OnConflict = r.OutputName, r.OnConflict
+		return opts
+	}
+	return r.OutputOptions
+}
+
+// FinalStep returns the index of the step whose output is the job's output: the last that is not an upload
+func (r *PipelineRequest) FinalStep() int {
+	for i := len(r.Steps) - 1; i > 0; i-- {
+		if r.Steps[i].Type != StepUpload {
+			return i
+		}
+	}
+	return 0
+}
+
+// InputPaths returns the files the steps read: their inputs that are not step outputs, and the overlay
+// images, music, subtitles and end card logos
+func (r *PipelineRequest) InputPaths() []string {
+	var paths []string
+	for _, step := range r.Steps {
+		for _, input := range step.Inputs {
+			if !strings.HasPrefix(input, StepRefPrefix) {
+				paths = append(paths, input)
+			}
+		}
+		if step.Overlay != nil {
+			paths = append(paths, step.Overlay.FilePath)
+		}
+		if step.Audio != nil {
+			paths = append(paths, step.Audio.Tracks()...)
+		}
+		if step.Subtitles != nil {
+			paths = append(paths, step.Subtitles.FilePath)
+		}
+		if step.TemplateEffect != nil && step.TemplateEffect.LogoPath != "" {
+			paths = append(paths, step.TemplateEffect.LogoPath)
+		}
+	}
+	return paths
+}
+
+// Segments returns the video files the steps read, trimmed as trim steps read them
+func (r *PipelineRequest) Segments() []VideoSegment {
+	var segments []VideoSegment
+	for _, step := range r.Steps {
+		for _, input := range step.Inputs {
+			if strings.HasPrefix(input, StepRefPrefix) {
+				continue
+			}
+			segment := VideoSegment{FilePath: input}
+			if step.Type == StepTrim {
+				segment.StartTime, segment.EndTime = step.StartTime, step.EndTime
+			}
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// StepStatuses returns the statuses of the steps before the pipeline runs
+func (r *PipelineRequest) StepStatuses() []StepStatus {
+	statuses := make([]StepStatus, len(r.Steps))
+	for i, step := range r.Steps {
+		statuses[i] = StepStatus{ID: step.ID, Type: step.Type, Status: JobStatusPending}
+	}
+	return statuses
+}
+
+// JobTemplate represents a named pipeline whose string values may contain {{name}} placeholders, run with
+// only the values of its parameters
+type JobTemplate struct {
+	Name        string                       `json:"name" example:"episode"`
+	Description string                       `json:"description,omitempty" example:"Intro, episode and watermark, transcoded for the web"`
+	Parameters  map[string]TemplateParameter `json:"parameters,omitempty"`                   // placeholders that are not listed are required
+	Pipeline    map[string]any               `json:"pipeline"`                               // a pipeline request, with placeholders
+	Schedule    string                       `json:"schedule,omitempty" example:"0 2 * * *"` // cron expression, in UTC, on which the template runs with the defaults of its parameters
+}
+
+// TemplateParameter represents a placeholder of a job template
+type TemplateParameter struct {
+	Description string `json:"description,omitempty" example:"Uploaded episode video"`
+	Default     any    `json:"default,omitempty"` // value used when a run leaves the parameter out; without one the parameter is required
+}
+
+// TemplatesResponse represents the job templates
+type TemplatesResponse struct {
+	Templates []JobTemplate `json:"templates"`
+}
+
+// TemplateRunRequest represents the values a job template is run with
+type TemplateRunRequest struct {
+	Variables map[string]any `json:"variables"` // parameter values by name
+}
+
+// QueueRequest is a message of the job queue: a pipeline, or a run of a job template with the given values
+// for its parameters
+type QueueRequest struct {
+	RequestID string           `json:"request_id,omitempty"` // set as the request ID of the job, to match its events
+	Pipeline  *PipelineRequest `json:"pipeline,omitempty"`
+	Template  string           `json:"template,omitempty"`
+	Variables map[string]any   `json:"variables,omitempty"`
+}
+
+// Validate checks that the request has either a pipeline or a template
+func (r *QueueRequest) Validate() error {
+	if (r.Pipeline == nil) == (r.Template == "") {
+		return fmt.Errorf("exactly one of pipeline and template required")
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"govid/pkg/logger"
+)
+
+// Instances that run in separate processes, such as API instances and workers, share a job store by sharing
+// its directory. Every job record is written by one instance at a time: the instance that creates a job until
+// a worker claims it, then the worker until the job ends. Other instances reload the records that changed,
+// and ask the worker running a job to cancel it with a marker file.
+
+// claimPath returns the file that marks a job as claimed by a worker
+func (jp *JobPersistence) claimPath(jobID string) string {
+	return filepath.Join(jp.jobsDir, "claims", jobID)
+}
+
+// cancelPath returns the file that asks the worker running a job to cancel it
+func (jp *JobPersistence) cancelPath(jobID string) string {
+	return filepath.Join(jp.jobsDir, "cancel", jobID)
+}
+
+// createMarker creates an empty marker file. It returns false if the file already exists.
+func createMarker(path string) (bool, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return false, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, file.Close()
+}
+
+// modTime returns when the record of a job was last written
+func (jp *JobPersistence) modTime(jobID string) (time.Time, error) {
+	info, err := os.Stat(filepath.Join(jp.jobsDir, fmt.Sprintf("%s.json", jobID)))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// jobIDs returns the IDs of the jobs with a record
+func (jp *JobPersistence) jobIDs() ([]string, error) {
+	entries, err := os.ReadDir(jp.jobsDir)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Claimed reports whether the job runs in this process, which then owns its record
+func (j *Job) Claimed() bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.claimed
+}
+
+// sync copies the persisted fields of a job reloaded from its record
+func (j *Job) sync(from *Job) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Status = from.Status
+	j.Progress = from.Progress
+	j.OutputPath = from.OutputPath
+	j.S3URL = from.S3URL
+	j.S3URLExpires = from.S3URLExpires
+	j.StorageKey = from.StorageKey
+	j.StorageBucket = from.StorageBucket
+	j.ThumbnailURL = from.ThumbnailURL
+	j.MetadataURL = from.MetadataURL
+	j.WebhookURL = from.WebhookURL
+	j.WebhookHeaders = from.WebhookHeaders
+	j.Upload = from.Upload
+	j.Type = from.Type
+	j.InputSeconds = from.InputSeconds
+	j.StartedAt = from.StartedAt
+	j.Processing = from.Processing
+	j.Steps = from.Steps
+	j.RunAt = from.RunAt
+	j.Labels = from.Labels
+	j.TTL = from.TTL
+	j.ExpiresAt = from.ExpiresAt
+	j.Work = from.Work
+	j.Error = from.Error
+	j.UpdatedAt = from.UpdatedAt
+}
+
+// Share makes the store follow the changes that other instances sharing its directory make to the job
+// records: Get and List reload the records that changed, and pick up the cancellation of jobs claimed here.
+func (s *JobStore) Share() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.persistence != nil {
+		s.shared = true
+		s.seen = make(map[string]time.Time)
+	}
+}
+
+// Claim claims a pending job for this process to run. It returns false if another instance claimed it first.
+func (s *JobStore) Claim(job *Job) (bool, error) {
+	if s.persistence != nil {
+		claimed, err := createMarker(s.persistence.claimPath(job.ID))
+		if !claimed {
+			return false, err
+		}
+	}
+	job.mu.Lock()
+	job.claimed = true
+	job.mu.Unlock()
+	return true, nil
+}
+
+// saved records that the record of a job was written by this instance, so it is not reloaded. A job
+// cancelled here that runs elsewhere is asked to cancel.
+func (s *JobStore) saved(job *Job) {
+	if !s.shared {
+		return
+	}
+	if modTime, err := s.persistence.modTime(job.ID); err == nil {
+		s.seen[job.ID] = modTime
+	}
+	if job.GetStatus().Status == JobStatusCancelled && !job.Claimed() {
+		if _, err := createMarker(s.persistence.cancelPath(job.ID)); err != nil {
+			logger.Error("Failed to request the cancellation of job %s: %v", job.ID, err)
+		}
+	}
+}
+
+// refresh reloads the record of a job if another instance changed it, and cancels a job claimed here whose
+// cancellation was requested
+func (s *JobStore) refresh(id string) {
+	s.mu.RLock()
+	job, known := s.jobs[id]
+	seen := s.seen[id]
+	s.mu.RUnlock()
+
+	if known && job.Claimed() {
+		if _, err := os.Stat(s.persistence.cancelPath(id)); err == nil && job.Cancel() {
+			job.Logger().Info("Job %s cancelled by another instance", id)
+			_ = s.Update(job)
+		}
+		return
+	}
+
+	modTime, err := s.persistence.modTime(id)
+	if errors.Is(err, fs.ErrNotExist) {
+		if known {
+			s.mu.Lock()
+			delete(s.jobs, id)
+			delete(s.seen, id)
+			s.mu.Unlock()
+		}
+		return
+	}
+	if err != nil || (known && modTime.Equal(seen)) {
+		return
+	}
+	loaded, err := s.persistence.readJob(id)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.jobs[id]; ok {
+		current.sync(loaded)
+	} else {
+		s.jobs[id] = loaded
+	}
+	s.seen[id] = modTime
+}
+
+// refreshAll reloads the records that other instances created or changed, and drops the jobs whose records
+// they deleted
+func (s *JobStore) refreshAll() {
+	ids, err := s.persistence.jobIDs()
+	if err != nil {
+		logger.Error("Failed to read jobs directory: %v", err)
+		return
+	}
+	found := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		found[id] = true
+		s.refresh(id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, job := range s.jobs {
+		if found[id] || job.Claimed() {
+			continue
+		}
+		// The job may have been added since the directory was read
+		if _, err := s.persistence.modTime(id); errors.Is(err, fs.ErrNotExist) {
+			delete(s.jobs, id)
+			delete(s.seen, id)
+		}
+	}
+}
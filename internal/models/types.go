@@ -1,20 +1,52 @@
 package models
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"sync"
 	"time"
+	"unicode"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"govid/pkg/logger"
+	"govid/pkg/storage"
+	"govid/pkg/tracing"
 )
 
 // JobStatus represents the status of a job
 type JobStatus string
 
 const (
+	JobStatusScheduled  JobStatus = "scheduled" // waiting for its run_at
 	JobStatusPending    JobStatus = "pending"
 	JobStatusProcessing JobStatus = "processing"
 	JobStatusCompleted  JobStatus = "completed"
 	JobStatusFailed     JobStatus = "failed"
+	JobStatusCancelled  JobStatus = "cancelled"
+	JobStatusExpired    JobStatus = "expired" // completed, and its outputs were deleted when its TTL ran out
 )
 
+// JobOutputPrefix marks an input that is the output of another job, as job:<id>. Requests give such an input as
+// {"job_output": "<id>"}; it keeps this form until the job it refers to has completed.
+const JobOutputPrefix = "job:"
+
+// JobOutputID returns the ID of the job whose output path refers to, if it is a job:<id> reference
+func JobOutputID(path string) (string, bool) {
+	return strings.CutPrefix(path, JobOutputPrefix)
+}
+
 // VideoSegment represents a video segment with timeframe
 type VideoSegment struct {
 	FilePath  string  `json:"file_path" example:"/uploads/video1.mp4"`
@@ -22,6 +54,20 @@ type VideoSegment struct {
 	EndTime   float64 `json:"end_time" example:"10.5"` // in seconds, 0 means end of video
 }
 
+// Validate checks that the segment has a file and a usable timeframe
+func (s *VideoSegment) Validate() error {
+	if s.FilePath == "" {
+		return fmt.Errorf("file_path is required")
+	}
+	if s.StartTime < 0 || s.EndTime < 0 {
+		return fmt.Errorf("start_time and end_time must not be negative")
+	}
+	if s.EndTime != 0 && s.EndTime <= s.StartTime {
+		return fmt.Errorf("end_time must be after start_time (or 0 for end of video)")
+	}
+	return nil
+}
+
 // OverlayPosition represents predefined positions
 type OverlayPosition string
 
@@ -34,75 +80,1513 @@ const (
 	PositionCustom      OverlayPosition = "custom"
 )
 
-// AnimationType represents animation types
-type AnimationType string
+// AnimationType represents animation types
+type AnimationType string
+
+const (
+	AnimationFade  AnimationType = "fade"
+	AnimationSlide AnimationType = "slide"
+	AnimationZoom  AnimationType = "zoom"
+	AnimationNone  AnimationType = "none"
+)
+
+// SlideDirection represents slide direction
+type SlideDirection string
+
+const (
+	SlideFromLeft   SlideDirection = "left"
+	SlideFromRight  SlideDirection = "right"
+	SlideFromTop    SlideDirection = "top"
+	SlideFromBottom SlideDirection = "bottom"
+)
+
+// Easing represents how an animation moves between its start and end
+type Easing string
+
+const (
+	EasingLinear Easing = "linear"
+	EasingIn     Easing = "ease-in"
+	EasingOut    Easing = "ease-out"
+	EasingInOut  Easing = "ease-in-out"
+	EasingBounce Easing = "bounce"
+)
+
+// AudioMode represents how background music is combined with the audio of a video
+type AudioMode string
+
+const (
+	AudioModeMix     AudioMode = "mix"
+	AudioModeReplace AudioMode = "replace"
+)
+
+// OutputFormat represents the output container format
+type OutputFormat string
+
+const (
+	FormatMP4  OutputFormat = "mp4"
+	FormatMKV  OutputFormat = "mkv"
+	FormatWebM OutputFormat = "webm"
+	FormatMOV  OutputFormat = "mov"
+)
+
+// Validate checks that the output format is supported; an empty format means mp4
+func (f OutputFormat) Validate() error {
+	switch f {
+	case "", FormatMP4, FormatMKV, FormatWebM, FormatMOV:
+		return nil
+	}
+	return fmt.Errorf("output_format must be one of mp4, mkv, webm, mov")
+}
+
+// Extension returns the file extension for the output format, including the dot
+func (f OutputFormat) Extension() string {
+	if f == "" {
+		return "." + string(FormatMP4)
+	}
+	return "." + string(f)
+}
+
+// x264 speed presets, H.264 profiles and levels, and pixel formats accepted in OutputOptions
+var (
+	validPresets  = []string{"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow"}
+	validProfiles = []string{"baseline", "main", "high", "high10", "high422", "high444"}
+	validLevels   = []string{"3.0", "3.1", "3.2", "4.0", "4.1", "4.2", "5.0", "5.1", "5.2", "6.0", "6.1", "6.2"}
+	validPixFmts  = []string{"yuv420p", "yuv422p", "yuv444p", "yuv420p10le", "yuv422p10le", "yuv444p10le"}
+)
+
+// OutputOptions represents output container and encoding settings shared by processing requests
+type OutputOptions struct {
+	PresetName   string       `json:"preset_name,omitempty" example:"web_standard"` // named preset from GET /api/v1/presets; explicit fields override it
+	OutputFormat OutputFormat `json:"output_format,omitempty" example:"mp4"`
+	CRF          *int         `json:"crf,omitempty" example:"23"`        // constant rate factor, 0-51 (0-63 for webm); lower is better quality
+	Preset       string       `json:"preset,omitempty" example:"medium"` // x264 speed preset, ultrafast to veryslow
+	Profile      string       `json:"profile,omitempty" example:"high"`  // H.264 profile: baseline, main, high, high10, high422, high444
+	Level        string       `json:"level,omitempty" example:"4.1"`     // H.264 level, 3.0 to 6.2
+	PixFmt       string       `json:"pix_fmt,omitempty" example:"yuv420p"`
+
+	// Output resolution
+	Width      int     `json:"width,omitempty" example:"1080"`      // target width in pixels; requires height
+	Height     int     `json:"height,omitempty" example:"1920"`     // target height in pixels; requires width
+	Fit        FitMode `json:"fit,omitempty" example:"contain"`     // how the source is fitted to width x height (default contain)
+	Background string  `json:"background,omitempty" example:"blur"` // contain fill: a color name, #RRGGBB, or "blur" (default black)
+
+	// Frame rate
+	FPS         float64         `json:"fps,omitempty" example:"60"`             // output frame rate, 1-240; unset keeps the source rate
+	Interpolate InterpolateMode `json:"interpolate,omitempty" example:"motion"` // synthesize new frames instead of duplicating/dropping; requires fps
+
+	// Container metadata
+	Metadata      *OutputMetadata `json:"metadata,omitempty"`       // tags written to the output
+	StripMetadata bool            `json:"strip_metadata,omitempty"` // remove all existing tags and chapters
+
+	// Input files
+	CleanupInputs *bool `json:"cleanup_inputs,omitempty" example:"true"` // delete the uploaded inputs once the job completes; defaults to CLEANUP_INPUTS
+
+	// Job limits
+	TimeoutSeconds int `json:"timeout_seconds,omitempty" example:"600"` // stop the job after this many seconds; defaults to JOB_TIMEOUT, at most MAX_JOB_TIMEOUT
+
+	// Job scheduling
+	RunAt string `json:"run_at,omitempty" example:"2025-01-14T02:00:00Z"` // start the job at this time (RFC 3339) instead of right away
+
+	// Output lifetime
+	TTLSeconds int `json:"ttl_seconds,omitempty" example:"86400"` // delete the outputs this many seconds after the job completes; defaults to OUTPUT_TTL_SECONDS
+
+	// Output file
+	OutputName string       `json:"output_name,omitempty" example:"launch-teaser"` // name of the output file in OUTPUT_DIR, without extension; defaults to the job ID
+	OnConflict ConflictMode `json:"on_conflict,omitempty" example:"rename"`        // what happens when a file named output_name exists: rename (default), overwrite, or fail
+
+	// Job labels
+	Labels map[string]string `json:"labels,omitempty"` // kept with the job and returned in its status, such as an order ID to find the job by
+}
+
+// ConflictMode represents what happens when an output file named output_name already exists
+type ConflictMode string
+
+const (
+	ConflictRename    ConflictMode = "rename"    // add -1, -2, ... to the name until it is free
+	ConflictOverwrite ConflictMode = "overwrite" // replace the existing file
+	ConflictFail      ConflictMode = "fail"      // fail the job
+)
+
+// labelKeyPattern matches the label keys accepted in OutputOptions
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,63}$`)
+
+// Limits on the labels of a job
+const (
+	maxLabels          = 20
+	maxLabelValueBytes = 256
+)
+
+// outputNamePattern matches the output names accepted in OutputOptions
+var outputNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,199}$`)
+
+// FitMode represents how a video is fitted to the output resolution
+type FitMode string
+
+const (
+	FitContain FitMode = "contain" // scale to fit inside and pad the remainder
+	FitCover   FitMode = "cover"   // scale to fill and crop the overflow
+	FitStretch FitMode = "stretch" // scale to the exact size, ignoring aspect ratio
+)
+
+// InterpolateMode represents how new frames are synthesized when changing the frame rate
+type InterpolateMode string
+
+const (
+	InterpolateBlend  InterpolateMode = "blend"  // crossfade neighbouring frames (fast)
+	InterpolateMotion InterpolateMode = "motion" // motion-compensated interpolation (smooth, very slow)
+)
+
+// BackgroundBlur fills contain padding with a blurred, enlarged copy of the video
+const BackgroundBlur = "blur"
+
+// backgroundColorPattern matches the color names and hex colors accepted as a contain background and font color
+var backgroundColorPattern = regexp.MustCompile(`^([a-zA-Z]+|(#|0x)[0-9a-fA-F]{6})$`)
+
+// OutputMetadata represents container metadata tags written to an output
+type OutputMetadata struct {
+	Title        string `json:"title,omitempty" example:"Product launch"`
+	Artist       string `json:"artist,omitempty" example:"GoVid"`
+	Comment      string `json:"comment,omitempty" example:"Rendered by GoVid"`
+	CreationTime string `json:"creation_time,omitempty" example:"2025-01-13T10:00:00Z"` // RFC 3339
+}
+
+// Validate checks that the output options are supported by the selected container
+func (o *OutputOptions) Validate() error {
+	if err := o.OutputFormat.Validate(); err != nil {
+		return err
+	}
+
+	maxCRF := 51
+	if o.OutputFormat == FormatWebM {
+		maxCRF = 63
+		if o.Preset != "" || o.Profile != "" || o.Level != "" {
+			return fmt.Errorf("preset, profile, and level are not supported for webm output")
+		}
+	}
+	if o.CRF != nil && (*o.CRF < 0 || *o.CRF > maxCRF) {
+		return fmt.Errorf("crf must be between 0 and %d", maxCRF)
+	}
+	if o.Preset != "" && !slices.Contains(validPresets, o.Preset) {
+		return fmt.Errorf("preset must be one of %s", strings.Join(validPresets, ", "))
+	}
+	if o.Profile != "" && !slices.Contains(validProfiles, o.Profile) {
+		return fmt.Errorf("profile must be one of %s", strings.Join(validProfiles, ", "))
+	}
+	if o.Level != "" && !slices.Contains(validLevels, o.Level) {
+		return fmt.Errorf("level must be one of %s", strings.Join(validLevels, ", "))
+	}
+	if o.PixFmt != "" && !slices.Contains(validPixFmts, o.PixFmt) {
+		return fmt.Errorf("pix_fmt must be one of %s", strings.Join(validPixFmts, ", "))
+	}
+	if err := o.validateResolution(); err != nil {
+		return err
+	}
+	if o.FPS != 0 && (o.FPS < 1 || o.FPS > 240) {
+		return fmt.Errorf("fps must be between 1 and 240")
+	}
+	switch o.Interpolate {
+	case "":
+	case InterpolateBlend, InterpolateMotion:
+		if o.FPS == 0 {
+			return fmt.Errorf("interpolate requires fps")
+		}
+	default:
+		return fmt.Errorf("interpolate must be blend or motion")
+	}
+	if o.Metadata != nil && o.Metadata.CreationTime != "" {
+		if _, err := time.Parse(time.RFC3339, o.Metadata.CreationTime); err != nil {
+			return fmt.Errorf("metadata.creation_time must be an RFC 3339 timestamp")
+		}
+	}
+	if o.TimeoutSeconds < 0 {
+		return fmt.Errorf("timeout_seconds must not be negative")
+	}
+	if o.RunAt != "" {
+		if _, err := time.Parse(time.RFC3339, o.RunAt); err != nil {
+			return fmt.Errorf("run_at must be an RFC 3339 timestamp")
+		}
+	}
+	if o.TTLSeconds < 0 {
+		return fmt.Errorf("ttl_seconds must not be negative")
+	}
+	if o.OutputName != "" && !outputNamePattern.MatchString(o.OutputName) {
+		return fmt.Errorf("output_name must be 1-200 letters, digits, '.', '_' or '-', starting with a letter or digit")
+	}
+	switch o.OnConflict {
+	case "", ConflictRename, ConflictOverwrite, ConflictFail:
+	default:
+		return fmt.Errorf("on_conflict must be rename, overwrite, or fail")
+	}
+	if o.OnConflict != "" && o.OutputName == "" {
+		return fmt.Errorf("on_conflict requires output_name")
+	}
+	if len(o.Labels) > maxLabels {
+		return fmt.Errorf("at most %d labels allowed", maxLabels)
+	}
+	for key, value := range o.Labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("label key %q must be 1-63 letters, digits, '.', '_' or '-'", key)
+		}
+		if len(value) > maxLabelValueBytes || !printableASCII(value) {
+			return fmt.Errorf("label %q must be at most %d printable ASCII characters", key, maxLabelValueBytes)
+		}
+	}
+	return nil
+}
+
+// FileName returns the name of the output file of a job: output_name, or the job ID, with the extension of
+// the output format. An output_name that already ends in that extension does not get it twice.
+func (o OutputOptions) FileName(jobID string) string {
+	ext := o.OutputFormat.Extension()
+	if o.OutputName == "" {
+		return jobID + ext
+	}
+	return strings.TrimSuffix(o.OutputName, ext) + ext
+}
+
+// RunAtTime returns when the job should start: run_at, or the zero time to start right away
+func (o OutputOptions) RunAtTime() time.Time {
+	runAt, _ := time.Parse(time.RFC3339, o.RunAt)
+	return runAt
+}
+
+// JobTimeout returns how long the job may run: the requested timeout_seconds, or defaultSeconds if unset
+func (o OutputOptions) JobTimeout(defaultSeconds int) time.Duration {
+	if o.TimeoutSeconds > 0 {
+		return time.Duration(o.TimeoutSeconds) * time.Second
+	}
+	return time.Duration(defaultSeconds) * time.Second
+}
+
+// OutputTTL returns how long the outputs are kept after the job completes: the requested ttl_seconds, or
+// defaultSeconds if unset. Zero keeps them until the cleanup of OUTPUT_DIR removes them.
+func (o OutputOptions) OutputTTL(defaultSeconds int) time.Duration {
+	if o.TTLSeconds > 0 {
+		return time.Duration(o.TTLSeconds) * time.Second
+	}
+	return time.Duration(defaultSeconds) * time.Second
+}
+
+// validateResolution checks the output size, fit mode and background fill
+func (o *OutputOptions) validateResolution() error {
+	if (o.Width == 0) != (o.Height == 0) {
+		return fmt.Errorf("width and height must be set together")
+	}
+	if o.Width == 0 {
+		if o.Fit != "" || o.Background != "" {
+			return fmt.Errorf("fit and background require width and height")
+		}
+		return nil
+	}
+	if o.Width < 16 || o.Width > 7680 || o.Height < 16 || o.Height > 7680 {
+		return fmt.Errorf("width and height must be between 16 and 7680")
+	}
+	if o.Width%2 != 0 || o.Height%2 != 0 {
+		return fmt.Errorf("width and height must be even")
+	}
+
+	switch o.Fit {
+	case "", FitContain:
+	case FitCover, FitStretch:
+		if o.Background != "" {
+			return fmt.Errorf("background is only supported with fit contain")
+		}
+	default:
+		return fmt.Errorf("fit must be contain, cover, or stretch")
+	}
+	if o.Background != "" && o.Background != BackgroundBlur && !backgroundColorPattern.MatchString(o.Background) {
+		return fmt.Errorf("background must be a color name, #RRGGBB, or blur")
+	}
+	return nil
+}
+
+// WithDefaults returns a copy of the options with every unset field taken from defaults
+func (o OutputOptions) WithDefaults(defaults OutputOptions) OutputOptions {
+	if o.OutputFormat == "" {
+		o.OutputFormat = defaults.OutputFormat
+	}
+	if o.CRF == nil {
+		o.CRF = defaults.CRF
+	}
+	if o.Preset == "" {
+		o.Preset = defaults.Preset
+	}
+	if o.Profile == "" {
+		o.Profile = defaults.Profile
+	}
+	if o.Level == "" {
+		o.Level = defaults.Level
+	}
+	if o.PixFmt == "" {
+		o.PixFmt = defaults.PixFmt
+	}
+	if o.Width == 0 && o.Height == 0 {
+		o.Width, o.Height = defaults.Width, defaults.Height
+	}
+	if o.Fit == "" {
+		o.Fit = defaults.Fit
+	}
+	if o.Background == "" {
+		o.Background = defaults.Background
+	}
+	if o.FPS == 0 {
+		o.FPS = defaults.FPS
+	}
+	if o.Interpolate == "" {
+		o.Interpolate = defaults.Interpolate
+	}
+	if o.Metadata == nil {
+		o.Metadata = defaults.Metadata
+	}
+	o.StripMetadata = o.StripMetadata || defaults.StripMetadata
+	return o
+}
+
+// EncodingPreset represents a named set of output and encoding options
+type EncodingPreset struct {
+	Name        string `json:"name" example:"web_standard"`
+	Description string `json:"description,omitempty" example:"H.264 MP4 for web playback"`
+	OutputOptions
+}
+
+// PresetsResponse represents the list of available encoding presets
+type PresetsResponse struct {
+	Presets []EncodingPreset `json:"presets"`
+}
+
+// ImageOverlay represents image overlay configuration
+type ImageOverlay struct {
+	FilePath  string          `json:"file_path" example:"/uploads/logo.png"`
+	Position  OverlayPosition `json:"position" example:"top-left"`
+	X         *int            `json:"x,omitempty" example:"10"` // custom x position (only if position is "custom")
+	Y         *int            `json:"y,omitempty" example:"10"` // custom y position (only if position is "custom")
+	StartTime float64         `json:"start_time" example:"0"`   // when overlay appears (seconds)
+	EndTime   float64         `json:"end_time" example:"5"`     // when overlay disappears (seconds)
+	Animation AnimationType   `json:"animation" example:"fade"` // entrance animation; fade also fades out unless exit_animation is set
+	// Animation specific options
+	FadeDuration   *float64        `json:"fade_duration,omitempty" example:"1.0"` // fade in/out duration
+	SlideDirection *SlideDirection `json:"slide_direction,omitempty" example:"left"`
+	SlideDuration  *float64        `json:"slide_duration,omitempty" example:"1.0"`
+	ZoomFrom       *float64        `json:"zoom_from,omitempty" example:"0.5"`   // initial zoom level
+	ZoomTo         *float64        `json:"zoom_to,omitempty" example:"1.5"`     // final zoom level
+	Easing         Easing          `json:"easing,omitempty" example:"ease-out"` // curve of slide and zoom motion (default linear)
+	// Exit animation, ending at end_time
+	ExitAnimation AnimationType   `json:"exit_animation,omitempty" example:"slide"` // fade, slide, or none
+	ExitDirection *SlideDirection `json:"exit_direction,omitempty" example:"right"` // edge a slide-out leaves by (default slide_direction, or left)
+	ExitDuration  *float64        `json:"exit_duration,omitempty" example:"0.5"`    // seconds (default fade_duration or slide_duration, or 1)
+	// Size of the image; without these it is laid over the video at its own size
+	Width        *int     `json:"width,omitempty" example:"200"`        // in pixels, keeping the aspect ratio; with height, the image fits within both
+	Height       *int     `json:"height,omitempty" example:"100"`       // in pixels, keeping the aspect ratio; with width, the image fits within both
+	ScalePercent *float64 `json:"scale_percent,omitempty" example:"25"` // percentage of the size of the image, instead of width and height
+	Opacity      *float64 `json:"opacity,omitempty" example:"0.5"`      // 0 (invisible) to 1 (default, as transparent as the image itself)
+	// Rotation clockwise, in degrees; with rotation_end_degrees, the image turns from one angle to the other
+	// between start_time and end_time
+	RotationDegrees    *float64 `json:"rotation_degrees,omitempty" example:"15"`
+	RotationEndDegrees *float64 `json:"rotation_end_degrees,omitempty" example:"375"`
+	// Animated overlays, such as GIFs or WebM and ProRes 4444 videos with alpha, play from start_time once,
+	// then show their last frame, or with loop over and over until end_time
+	Loop bool `json:"loop,omitempty" example:"true"`
+}
+
+// Exit returns the exit animation of the overlay: exit_animation, or a fade-out after a fade-in
+func (o *ImageOverlay) Exit() AnimationType {
+	switch {
+	case o.ExitAnimation != "":
+		return o.ExitAnimation
+	case o.Animation == AnimationFade:
+		return AnimationFade
+	default:
+		return AnimationNone
+	}
+}
+
+// ExitSeconds returns the length of the exit animation in seconds
+func (o *ImageOverlay) ExitSeconds() float64 {
+	switch {
+	case o.ExitDuration != nil:
+		return *o.ExitDuration
+	case o.Exit() == AnimationFade && o.FadeDuration != nil:
+		return *o.FadeDuration
+	case o.Exit() == AnimationSlide && o.SlideDuration != nil:
+		return *o.SlideDuration
+	default:
+		return 1
+	}
+}
+
+// ExitSlideDirection returns the edge the overlay slides out by
+func (o *ImageOverlay) ExitSlideDirection() SlideDirection {
+	switch {
+	case o.ExitDirection != nil:
+		return *o.ExitDirection
+	case o.SlideDirection != nil:
+		return *o.SlideDirection
+	default:
+		return SlideFromLeft
+	}
+}
+
+// maxRotationDegrees bounds the angles of overlay rotations, ten turns either way
+const maxRotationDegrees = 3600
+
+// RotationAnimated reports whether the angle of the overlay changes over time
+func (o *ImageOverlay) RotationAnimated() bool {
+	return o.RotationEndDegrees != nil
+}
+
+// Validate checks the overlay file, position, timeframe and animation settings
+func (o *ImageOverlay) Validate() error {
+	if o.FilePath == "" {
+		return fmt.Errorf("file_path is required")
+	}
+	switch o.Position {
+	case "", PositionTopLeft, PositionTopRight, PositionBottomLeft, PositionBottomRight, PositionCenter:
+	case PositionCustom:
+		if o.X == nil || o.Y == nil {
+			return fmt.Errorf("x and y are required for custom position")
+		}
+	default:
+		return fmt.Errorf("position must be top-left, top-right, bottom-left, bottom-right, center, or custom")
+	}
+	if o.StartTime < 0 || o.EndTime <= o.StartTime {
+		return fmt.Errorf("end_time must be after start_time")
+	}
+	switch o.Animation {
+	case "", AnimationNone, AnimationFade, AnimationSlide, AnimationZoom:
+	default:
+		return fmt.Errorf("animation must be fade, slide, zoom, or none")
+	}
+	for _, direction := range []*SlideDirection{o.SlideDirection, o.ExitDirection} {
+		if direction == nil {
+			continue
+		}
+		switch *direction {
+		case SlideFromLeft, SlideFromRight, SlideFromTop, SlideFromBottom:
+		default:
+			return fmt.Errorf("slide_direction and exit_direction must be left, right, top, or bottom")
+		}
+	}
+	switch o.Easing {
+	case "", EasingLinear, EasingIn, EasingOut, EasingInOut, EasingBounce:
+	default:
+		return fmt.Errorf("easing must be linear, ease-in, ease-out, ease-in-out, or bounce")
+	}
+	switch o.ExitAnimation {
+	case "", AnimationNone, AnimationFade, AnimationSlide:
+	default:
+		return fmt.Errorf("exit_animation must be fade, slide, or none")
+	}
+	if o.ExitDuration != nil && (*o.ExitDuration <= 0 || *o.ExitDuration > o.EndTime-o.StartTime) {
+		return fmt.Errorf("exit_duration must be greater than 0 and at most the time the overlay is shown")
+	}
+	if (o.Width != nil && (*o.Width < 1 || *o.Width > 7680)) || (o.Height != nil && (*o.Height < 1 || *o.Height > 7680)) {
+		return fmt.Errorf("width and height must be between 1 and 7680")
+	}
+	if o.ScalePercent != nil {
+		if o.Width != nil || o.Height != nil {
+			return fmt.Errorf("scale_percent cannot be combined with width or height")
+		}
+		if *o.ScalePercent <= 0 || *o.ScalePercent > 1000 {
+			return fmt.Errorf("scale_percent must be greater than 0 and at most 1000")
+		}
+	}
+	if o.Opacity != nil && (*o.Opacity < 0 || *o.Opacity > 1) {
+		return fmt.Errorf("opacity must be between 0 and 1")
+	}
+	for _, angle := range []*float64{o.RotationDegrees, o.RotationEndDegrees} {
+		if angle != nil && (math.IsNaN(*angle) || math.Abs(*angle) > maxRotationDegrees) {
+			return fmt.Errorf("rotation_degrees and rotation_end_degrees must be between -%d and %d", maxRotationDegrees, maxRotationDegrees)
+		}
+	}
+	return nil
+}
+
+// TextOverlay represents text drawn over a video
+type TextOverlay struct {
+	Text      string          `json:"text" example:"Episode 1"`
+	Position  OverlayPosition `json:"position,omitempty" example:"bottom-left"` // as for image overlays (default top-left)
+	X         *int            `json:"x,omitempty" example:"10"`                 // custom x position (only if position is "custom")
+	Y         *int            `json:"y,omitempty" example:"10"`                 // custom y position (only if position is "custom")
+	FontSize  int             `json:"font_size,omitempty" example:"48"`         // in pixels, 8-500 (default 48)
+	FontColor string          `json:"font_color,omitempty" example:"white"`     // a color name or #RRGGBB (default white)
+	Font      string          `json:"font,omitempty" example:"Montserrat-Bold"` // name of an uploaded font (default FFmpeg's font)
+	FontFile  string          `json:"-"`                                        // file of Font, found when the text is drawn
+	StartTime float64         `json:"start_time" example:"0"`                   // when the text appears (seconds)
+	EndTime   float64         `json:"end_time" example:"5"`                     // when the text disappears (seconds), 0 means end of video
+}
+
+// Font represents a font uploaded for text overlays, which refer to it by name
+type Font struct {
+	Name      string    `json:"name" example:"Montserrat-Bold"`
+	Format    string    `json:"format" example:"ttf"`  // ttf, otf, or ttc
+	Size      int64     `json:"size" example:"198756"` // in bytes
+	UpdatedAt time.Time `json:"updated_at" example:"2025-01-13T10:00:00Z"`
+}
+
+// FontsResponse represents the uploaded fonts, sorted by name
+type FontsResponse struct {
+	Fonts []Font `json:"fonts"`
+}
+
+// maxTextLength bounds the text of a text overlay
+const maxTextLength = 500
+
+// Validate checks the text, position, font and timeframe
+func (t *TextOverlay) Validate() error {
+	if strings.TrimSpace(t.Text) == "" {
+		return fmt.Errorf("text is required")
+	}
+	if len(t.Text) > maxTextLength || strings.ContainsFunc(t.Text, unicode.IsControl) {
+		return fmt.Errorf("text must be at most %d characters on a single line", maxTextLength)
+	}
+	switch t.Position {
+	case "", PositionTopLeft, PositionTopRight, PositionBottomLeft, PositionBottomRight, PositionCenter:
+	case PositionCustom:
+		if t.X == nil || t.Y == nil {
+			return fmt.Errorf("x and y are required for custom position")
+		}
+	default:
+		return fmt.Errorf("position must be top-left, top-right, bottom-left, bottom-right, center, or custom")
+	}
+	if t.FontSize != 0 && (t.FontSize < 8 || t.FontSize > 500) {
+		return fmt.Errorf("font_size must be between 8 and 500")
+	}
+	if t.FontColor != "" && !backgroundColorPattern.MatchString(t.FontColor) {
+		return fmt.Errorf("font_color must be a color name or #RRGGBB")
+	}
+	if t.StartTime < 0 || t.EndTime < 0 {
+		return fmt.Errorf("start_time and end_time must not be negative")
+	}
+	if t.EndTime != 0 && t.EndTime <= t.StartTime {
+		return fmt.Errorf("end_time must be after start_time (or 0 for end of video)")
+	}
+	return nil
+}
+
+// AudioConfig represents background music configuration
+type AudioConfig struct {
+	FilePath  string         `json:"file_path" example:"/uploads/music.mp3"`
+	Volume    float64        `json:"volume" example:"0.3"`             // 0.0 to 1.0
+	StartTime *float64       `json:"start_time,omitempty" example:"0"` // trim audio start (seconds)
+	EndTime   *float64       `json:"end_time,omitempty" example:"30"`  // trim audio end (seconds)
+	FadeIn    *float64       `json:"fade_in,omitempty" example:"2"`    // fade in duration
+	FadeOut   *float64       `json:"fade_out,omitempty" example:"2"`   // fade out duration
+	Duck      *DuckingConfig `json:"duck,omitempty"`                   // dip the music under the original audio
+	Loop      bool           `json:"loop,omitempty" example:"true"`    // repeat the music until the video ends, then fade it out over fade_out (default 2)
+	Offset    *float64       `json:"offset,omitempty" example:"5"`     // seconds into the video the music starts (default 0)
+	Mode      AudioMode      `json:"mode,omitempty" example:"mix"`     // mix the music with the original audio (default) or replace it
+	// Playlist
+	Playlist  []string `json:"playlist,omitempty" example:"/uploads/music2.mp3"` // more music played after file_path, in order
+	Crossfade *float64 `json:"crossfade,omitempty" example:"2"`                  // seconds each track crossfades into the next, 0-10 (default 2)
+}
+
+// MaxPlaylistTracks bounds the number of music files of a playlist, file_path included
+const MaxPlaylistTracks = 20
+
+// maxCrossfade bounds the crossfade between playlist tracks, in seconds
+const maxCrossfade = 10
+
+// Tracks returns the music files in the order they are played: file_path, then the playlist
+func (a *AudioConfig) Tracks() []string {
+	return append([]string{a.FilePath}, a.Playlist...)
+}
+
+// CrossfadeSeconds returns how long each playlist track crossfades into the next
+func (a *AudioConfig) CrossfadeSeconds() float64 {
+	if a.Crossfade != nil {
+		return *a.Crossfade
+	}
+	return 2
+}
+
+// Validate checks the music files, volume and ducking settings
+func (a *AudioConfig) Validate() error {
+	if a.FilePath == "" {
+		return fmt.Errorf("file_path is required")
+	}
+	if len(a.Playlist)+1 > MaxPlaylistTracks {
+		return fmt.Errorf("at most %d music files allowed, file_path included", MaxPlaylistTracks)
+	}
+	if slices.Contains(a.Playlist, "") {
+		return fmt.Errorf("playlist must not contain empty paths")
+	}
+	if a.Crossfade != nil && (*a.Crossfade < 0 || *a.Crossfade > maxCrossfade) {
+		return fmt.Errorf("crossfade must be between 0 and %d seconds", maxCrossfade)
+	}
+	if a.Volume < 0 || a.Volume > 1 {
+		return fmt.Errorf("volume must be between 0.0 and 1.0")
+	}
+	if a.Offset != nil && *a.Offset < 0 {
+		return fmt.Errorf("offset must not be negative")
+	}
+	switch a.Mode {
+	case "", AudioModeMix, AudioModeReplace:
+	default:
+		return fmt.Errorf("mode must be mix or replace")
+	}
+	if a.Duck != nil && a.Mode == AudioModeReplace {
+		return fmt.Errorf("duck requires mix mode")
+	}
+	if a.Duck != nil {
+		if err := a.Duck.Validate(); err != nil {
+			return fmt.Errorf("invalid duck: %w", err)
+		}
+	}
+	return nil
+}
+
+// DuckingConfig represents sidechain compression settings used to duck music under dialogue
+type DuckingConfig struct {
+	Threshold *float64 `json:"threshold,omitempty" example:"0.05"` // sidechain level that triggers ducking, 0.001 to 1 (default 0.05)
+	Ratio     *float64 `json:"ratio,omitempty" example:"8"`        // compression ratio, 1 to 20 (default 8)
+	Attack    *float64 `json:"attack,omitempty" example:"20"`      // attack time in milliseconds, 0.01 to 2000 (default 20)
+	Release   *float64 `json:"release,omitempty" example:"300"`    // release time in milliseconds, 0.01 to 9000 (default 300)
+}
+
+// Validate checks that ducking settings are within sidechaincompress's accepted ranges
+func (d *DuckingConfig) Validate() error {
+	if d.Threshold != nil && (*d.Threshold < 0.001 || *d.Threshold > 1) {
+		return fmt.Errorf("threshold must be between 0.001 and 1")
+	}
+	if d.Ratio != nil && (*d.Ratio < 1 || *d.Ratio > 20) {
+		return fmt.Errorf("ratio must be between 1 and 20")
+	}
+	if d.Attack != nil && (*d.Attack < 0.01 || *d.Attack > 2000) {
+		return fmt.Errorf("attack must be between 0.01 and 2000")
+	}
+	if d.Release != nil && (*d.Release < 0.01 || *d.Release > 9000) {
+		return fmt.Errorf("release must be between 0.01 and 9000")
+	}
+	return nil
+}
+
+// LoudnessNormalization represents EBU R128 loudness normalization (loudnorm) settings
+type LoudnessNormalization struct {
+	TargetLUFS *float64 `json:"target_lufs,omitempty" example:"-16"` // integrated loudness target, -70 to -5 (default -16)
+	TruePeak   *float64 `json:"true_peak,omitempty" example:"-1.5"`  // maximum true peak in dBTP, -9 to 0 (default -1.5)
+	LRA        *float64 `json:"lra,omitempty" example:"11"`          // loudness range target, 1 to 50 (default 11)
+	TwoPass    bool     `json:"two_pass" example:"true"`             // measure first, then normalize using the measured values
+}
+
+// Validate checks that loudness normalization targets are within loudnorm's accepted ranges
+func (n *LoudnessNormalization) Validate() error {
+	if n.TargetLUFS != nil && (*n.TargetLUFS < -70 || *n.TargetLUFS > -5) {
+		return fmt.Errorf("target_lufs must be between -70 and -5")
+	}
+	if n.TruePeak != nil && (*n.TruePeak < -9 || *n.TruePeak > 0) {
+		return fmt.Errorf("true_peak must be between -9 and 0")
+	}
+	if n.LRA != nil && (*n.LRA < 1 || *n.LRA > 50) {
+		return fmt.Errorf("lra must be between 1 and 50")
+	}
+	return nil
+}
+
+// MergeVideoRequest represents video merge request
+type MergeVideoRequest struct {
+	Segments []VideoSegment `json:"segments" binding:"required,min=2"`
+	OutputOptions
+	DeliveryOptions
+}
+
+// Validate checks that the request has at least 2 segments, and checks each segment
+func (r *MergeVideoRequest) Validate() error {
+	if len(r.Segments) < 2 {
+		return fmt.Errorf("at least 2 video segments required")
+	}
+	for i := range r.Segments {
+		if err := r.Segments[i].Validate(); err != nil {
+			return fmt.Errorf("segments[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// InputPaths returns the files of the segments
+func (r *MergeVideoRequest) InputPaths() []string {
+	paths := make([]string, 0, len(r.Segments))
+	for _, seg := range r.Segments {
+		paths = append(paths, seg.FilePath)
+	}
+	return paths
+}
+
+// OverlayRequest represents image overlay request
+type OverlayRequest struct {
+	VideoPath string         `json:"video_path" binding:"required"` // local path, http(s) URL, or s3:// or gs:// object
+	Overlay   ImageOverlay   `json:"overlay"`                       // a single overlay, or
+	Overlays  []ImageOverlay `json:"overlays,omitempty"`            // overlays laid in order, each over the ones before
+	OutputOptions
+	DeliveryOptions
+}
+
+// MaxOverlays bounds the overlays of an overlay request
+const MaxOverlays = 20
+
+// ImageOverlays returns the overlays of the request, whether given as overlays or as overlay
+func (r *OverlayRequest) ImageOverlays() []ImageOverlay {
+	if len(r.Overlays) > 0 {
+		return r.Overlays
+	}
+	return []ImageOverlay{r.Overlay}
+}
+
+// InputPaths returns the video and the overlay images
+func (r *OverlayRequest) InputPaths() []string {
+	paths := []string{r.VideoPath}
+	for _, overlay := range r.ImageOverlays() {
+		paths = append(paths, overlay.FilePath)
+	}
+	return paths
+}
+
+// Validate checks that the request has either overlay or overlays, and checks each overlay
+func (r *OverlayRequest) Validate() error {
+	if len(r.Overlays) == 0 {
+		if err := r.Overlay.Validate(); err != nil {
+			return fmt.Errorf("overlay: %w", err)
+		}
+		return nil
+	}
+	if r.Overlay.FilePath != "" {
+		return fmt.Errorf("overlay and overlays cannot be combined")
+	}
+	if len(r.Overlays) > MaxOverlays {
+		return fmt.Errorf("at most %d overlays are allowed", MaxOverlays)
+	}
+	for i := range r.Overlays {
+		if err := r.Overlays[i].Validate(); err != nil {
+			return fmt.Errorf("overlays[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// AudioRequest represents background music request
+type AudioRequest struct {
+	VideoPath      string                 `json:"video_path" binding:"required"` // local path, http(s) URL, or s3:// or gs:// object
+	Audio          AudioConfig            `json:"audio" binding:"required"`
+	NormalizeAudio *LoudnessNormalization `json:"normalize_audio,omitempty"`
+	OutputOptions
+	DeliveryOptions
+}
+
+// InputPaths returns the video and the music
+func (r *AudioRequest) InputPaths() []string {
+	return append([]string{r.VideoPath}, r.Audio.Tracks()...)
+}
+
+// CompleteProcessRequest represents complete video processing request
+type CompleteProcessRequest struct {
+	Segments       []VideoSegment         `json:"segments" binding:"required,min=1"`
+	Overlays       []ImageOverlay         `json:"overlays,omitempty"`
+	Audio          *AudioConfig           `json:"audio,omitempty"`
+	NormalizeAudio *LoudnessNormalization `json:"normalize_audio,omitempty"`
+	OutputOptions
+	DeliveryOptions
+}
+
+// Validate checks the segments, overlays, music and loudness settings
+func (r *CompleteProcessRequest) Validate() error {
+	if len(r.Segments) < 1 {
+		return fmt.Errorf("at least 1 video segment required")
+	}
+	for i := range r.Segments {
+		if err := r.Segments[i].Validate(); err != nil {
+			return fmt.Errorf("segments[%d]: %w", i, err)
+		}
+	}
+	for i := range r.Overlays {
+		if err := r.Overlays[i].Validate(); err != nil {
+			return fmt.Errorf("overlays[%d]: %w", i, err)
+		}
+	}
+	if r.Audio != nil {
+		if err := r.Audio.Validate(); err != nil {
+			return fmt.Errorf("audio: %w", err)
+		}
+	}
+	if r.NormalizeAudio != nil {
+		if err := r.NormalizeAudio.Validate(); err != nil {
+			return fmt.Errorf("normalize_audio: %w", err)
+		}
+	}
+	return nil
+}
+
+// InputPaths returns the local files of the segments, overlays, and audio, or job:<id> references to the
+// outputs of other jobs; /video/process does not download URLs
+func (r *CompleteProcessRequest) InputPaths() []string {
+	paths := make([]string, 0, len(r.Segments)+len(r.Overlays)+1)
+	for _, seg := range r.Segments {
+		paths = append(paths, seg.FilePath)
+	}
+	for _, overlay := range r.Overlays {
+		paths = append(paths, overlay.FilePath)
+	}
+	if r.Audio != nil {
+		paths = append(paths, r.Audio.Tracks()...)
+	}
+	return paths
+}
+
+// SilenceRequest represents silence detection and removal request
+type SilenceRequest struct {
+	VideoPath       string   `json:"video_path" binding:"required"`        // local path; silence removal also takes an http(s) URL or s3:// or gs:// object
+	NoiseDB         *float64 `json:"noise_db,omitempty" example:"-30"`     // level below which audio counts as silence, -90 to 0 dB (default -30)
+	MinDuration     *float64 `json:"min_duration,omitempty" example:"0.5"` // minimum silence length in seconds (default 0.5)
+	OutputOptions            // used for silence removal output
+	DeliveryOptions          // used for silence removal jobs
+}
+
+// InputPaths returns the video
+func (r *SilenceRequest) InputPaths() []string {
+	return []string{r.VideoPath}
+}
+
+// Validate checks that silence detection settings are within accepted ranges
+func (r *SilenceRequest) Validate() error {
+	if r.NoiseDB != nil && (*r.NoiseDB < -90 || *r.NoiseDB > 0) {
+		return fmt.Errorf("noise_db must be between -90 and 0")
+	}
+	if r.MinDuration != nil && *r.MinDuration <= 0 {
+		return fmt.Errorf("min_duration must be greater than 0")
+	}
+	return r.OutputOptions.Validate()
+}
+
+// Default vertical output resolution (9:16)
+const (
+	DefaultVerticalWidth  = 1080
+	DefaultVerticalHeight = 1920
+)
+
+// VerticalRequest represents a request to convert a video to a vertical (9:16) frame
+type VerticalRequest struct {
+	VideoPath     string `json:"video_path" binding:"required" example:"/uploads/landscape.mp4"` // local path, http(s) URL, or s3:// or gs:// object
+	Background    string `json:"background,omitempty" example:"blur"`                            // blur (default), a color name, or #RRGGBB
+	OutputOptions        // width and height default to 1080x1920
+	DeliveryOptions
+}
+
+// InputPaths returns the video
+func (r *VerticalRequest) InputPaths() []string {
+	return []string{r.VideoPath}
+}
+
+// Validate checks that the vertical conversion settings are supported
+func (r *VerticalRequest) Validate() error {
+	if r.Fit != "" && r.Fit != FitContain {
+		return fmt.Errorf("vertical conversion always uses fit contain")
+	}
+	opts := r.VerticalOptions()
+	return opts.Validate()
+}
+
+// VerticalOptions returns the output options with the vertical resolution and background fill applied
+func (r *VerticalRequest) VerticalOptions() OutputOptions {
+	opts := r.OutputOptions
+	if opts.Width == 0 && opts.Height == 0 {
+		opts.Width, opts.Height = DefaultVerticalWidth, DefaultVerticalHeight
+	}
+	opts.Fit = FitContain
+	switch {
+	case r.Background != "":
+		opts.Background = r.Background
+	case opts.Background == "":
+		opts.Background = BackgroundBlur
+	}
+	return opts
+}
+
+// SubtitleFormat represents the file format of subtitles
+type SubtitleFormat string
+
+const (
+	SubtitleSRT SubtitleFormat = "srt"
+	SubtitleVTT SubtitleFormat = "vtt"
+)
+
+// languagePattern matches the ISO 639-1 language codes accepted for transcription
+var languagePattern = regexp.MustCompile(`^[a-z]{2}$`)
+
+// TranscribeRequest represents a request to transcribe the speech of a video into subtitles
+type TranscribeRequest struct {
+	VideoPath     string         `json:"video_path" binding:"required" example:"/uploads/interview.mp4"` // local path, http(s) URL, or s3:// or gs:// object
+	Language      string         `json:"language,omitempty" example:"en"`                                // ISO 639-1 code of the spoken language; detected when empty
+	Format        SubtitleFormat `json:"format,omitempty" example:"srt"`                                 // srt (default) or vtt
+	OutputOptions                // output name, timeout, scheduling, TTL and labels; the encoding settings do not apply
+	DeliveryOptions
+}
+
+// InputPaths returns the video
+func (r *TranscribeRequest) InputPaths() []string {
+	return []string{r.VideoPath}
+}
+
+// Validate checks the language and subtitle format
+func (r *TranscribeRequest) Validate() error {
+	if err := validateLanguage(r.Language); err != nil {
+		return err
+	}
+	if r.Format != "" && r.Format != SubtitleSRT && r.Format != SubtitleVTT {
+		return fmt.Errorf("format must be srt or vtt")
+	}
+	if r.OutputFormat != "" {
+		return fmt.Errorf("output_format does not apply to transcription; set format to srt or vtt")
+	}
+	return r.OutputOptions.Validate()
+}
+
+// validateLanguage checks the spoken language of a transcription, if one is given
+func validateLanguage(language string) error {
+	if language != "" && !languagePattern.MatchString(language) {
+		return fmt.Errorf("language must be an ISO 639-1 code, such as en")
+	}
+	return nil
+}
+
+// SubtitleOptions returns the output options with the subtitle format as the output format, which names the
+// output file
+func (r *TranscribeRequest) SubtitleOptions() OutputOptions {
+	opts := r.OutputOptions
+	opts.OutputFormat = OutputFormat(SubtitleSRT)
+	if r.Format != "" {
+		opts.OutputFormat = OutputFormat(r.Format)
+	}
+	return opts
+}
+
+// CaptionPosition represents where captions are drawn
+type CaptionPosition string
+
+const (
+	CaptionTop    CaptionPosition = "top"
+	CaptionCenter CaptionPosition = "center"
+	CaptionBottom CaptionPosition = "bottom"
+)
+
+// Default caption style
+const (
+	DefaultCaptionFont           = "Arial"
+	DefaultCaptionFontSize       = 64
+	DefaultCaptionFontColor      = "white"
+	DefaultCaptionOutlineColor   = "black"
+	DefaultCaptionHighlightColor = "yellow"
+)
+
+// MaxCaptionWords bounds the words per caption of a caption style
+const MaxCaptionWords = 20
+
+// captionColors are the color names captions accept besides #RRGGBB, as RRGGBB
+var captionColors = map[string]string{
+	"white":   "FFFFFF",
+	"black":   "000000",
+	"red":     "FF0000",
+	"green":   "00FF00",
+	"blue":    "0000FF",
+	"yellow":  "FFFF00",
+	"cyan":    "00FFFF",
+	"magenta": "FF00FF",
+	"orange":  "FFA500",
+	"gray":    "808080",
+	"grey":    "808080",
+}
+
+// hexColorPattern matches #RRGGBB colors
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// fontNamePattern matches the font family names accepted in caption styles
+var fontNamePattern = regexp.MustCompile(`^[A-Za-z0-9 ._-]{1,64}$`)
+
+// CaptionStyle represents how burned-in captions look
+type CaptionStyle struct {
+	Font           string          `json:"font,omitempty" example:"Arial"`             // font family, matched with fontconfig (default Arial)
+	FontSize       int             `json:"font_size,omitempty" example:"64"`           // in pixels of a 1080-pixel-high frame, scaled with the video; 8-300 (default 64)
+	Bold           bool            `json:"bold,omitempty" example:"true"`              // draw the captions in bold
+	FontColor      string          `json:"font_color,omitempty" example:"white"`       // color name or #RRGGBB (default white)
+	OutlineColor   string          `json:"outline_color,omitempty" example:"black"`    // color name or #RRGGBB (default black)
+	Position       CaptionPosition `json:"position,omitempty" example:"bottom"`        // top, center, or bottom (default)
+	Karaoke        bool            `json:"karaoke,omitempty" example:"true"`           // highlight each word while it is spoken
+	HighlightColor string          `json:"highlight_color,omitempty" example:"yellow"` // color name or #RRGGBB of the spoken word with karaoke (default yellow)
+	MaxWords       int             `json:"max_words,omitempty" example:"4"`            // split captions into at most this many words, 1-20; 0 keeps the transcribed lines
+}
+
+// Validate checks the font, size, colors and position of the captions
+func (s *CaptionStyle) Validate() error {
+	if s.Font != "" && !fontNamePattern.MatchString(s.Font) {
+		return fmt.Errorf("style.font must be 1-64 letters, digits, spaces, '.', '-' or '_'")
+	}
+	if s.FontSize != 0 && (s.FontSize < 8 || s.FontSize > 300) {
+		return fmt.Errorf("style.font_size must be between 8 and 300")
+	}
+	colors := []struct{ name, value string }{
+		{"font_color", s.FontColor},
+		{"outline_color", s.OutlineColor},
+		{"highlight_color", s.HighlightColor},
+	}
+	for _, color := range colors {
+		if _, ok := CaptionColor(color.value); color.value != "" && !ok {
+			return fmt.Errorf("style.%s must be #RRGGBB or one of white, black, red, green, blue, yellow, cyan, magenta, orange, gray", color.name)
+		}
+	}
+	switch s.Position {
+	case "", CaptionTop, CaptionCenter, CaptionBottom:
+	default:
+		return fmt.Errorf("style.position must be top, center, or bottom")
+	}
+	if s.MaxWords < 0 || s.MaxWords > MaxCaptionWords {
+		return fmt.Errorf("style.max_words must be between 0 and %d", MaxCaptionWords)
+	}
+	return nil
+}
+
+// WithDefaults returns the style with the defaults filled in
+func (s CaptionStyle) WithDefaults() CaptionStyle {
+	if s.Font == "" {
+		s.Font = DefaultCaptionFont
+	}
+	if s.FontSize == 0 {
+		s.FontSize = DefaultCaptionFontSize
+	}
+	if s.FontColor == "" {
+		s.FontColor = DefaultCaptionFontColor
+	}
+	if s.OutlineColor == "" {
+		s.OutlineColor = DefaultCaptionOutlineColor
+	}
+	if s.HighlightColor == "" {
+		s.HighlightColor = DefaultCaptionHighlightColor
+	}
+	if s.Position == "" {
+		s.Position = CaptionBottom
+	}
+	return s
+}
+
+// CaptionColor returns a caption color, a name or #RRGGBB, as RRGGBB
+func CaptionColor(color string) (string, bool) {
+	if hexColorPattern.MatchString(color) {
+		return strings.ToUpper(color[1:]), true
+	}
+	hex, ok := captionColors[strings.ToLower(color)]
+	return hex, ok
+}
+
+// AutoSubtitleRequest represents a request to transcribe the speech of a video and burn it in as styled
+// captions
+type AutoSubtitleRequest struct {
+	VideoPath     string       `json:"video_path" binding:"required" example:"/uploads/interview.mp4"` // local path, http(s) URL, or s3:// or gs:// object
+	Language      string       `json:"language,omitempty" example:"en"`                                // ISO 639-1 code of the spoken language; detected when empty
+	Style         CaptionStyle `json:"style,omitempty"`
+	OutputOptions              // used for the captioned video
+	DeliveryOptions
+}
+
+// InputPaths returns the video
+func (r *AutoSubtitleRequest) InputPaths() []string {
+	return []string{r.VideoPath}
+}
+
+// Validate checks the language, caption style and output options
+func (r *AutoSubtitleRequest) Validate() error {
+	if err := validateLanguage(r.Language); err != nil {
+		return err
+	}
+	if err := r.Style.Validate(); err != nil {
+		return err
+	}
+	return r.OutputOptions.Validate()
+}
+
+// SilenceRange represents a detected silent section of a video
+type SilenceRange struct {
+	Start    float64 `json:"start" example:"12.4"`
+	End      float64 `json:"end" example:"14.1"`
+	Duration float64 `json:"duration" example:"1.7"`
+}
+
+// SilenceDetectResponse represents silence detection response
+type SilenceDetectResponse struct {
+	VideoPath string         `json:"video_path" example:"/uploads/video.mp4"`
+	Duration  float64        `json:"duration" example:"62.5"` // total input duration (seconds)
+	Silences  []SilenceRange `json:"silences"`
+}
+
+// DefectKind represents a kind of defect in the picture of a video
+type DefectKind string
+
+const (
+	DefectBlack  DefectKind = "black"  // black frames
+	DefectFreeze DefectKind = "freeze" // frozen picture
+)
+
+// DefectsRequest represents a request to find the black and frozen ranges of a video, with blackdetect and
+// freezedetect. The job's output is a JSON report of the ranges.
+type DefectsRequest struct {
+	VideoPath         string   `json:"video_path" binding:"required" example:"/uploads/source.mp4"` // local path, http(s) URL, or s3:// or gs:// object
+	BlackMinDuration  *float64 `json:"black_min_duration,omitempty" example:"2"`                    // shortest black range reported, in seconds (default 2)
+	BlackThreshold    *float64 `json:"black_threshold,omitempty" example:"0.1"`                     // luminance below which a pixel counts as black, 0 to 1 (default 0.1)
+	FreezeMinDuration *float64 `json:"freeze_min_duration,omitempty" example:"2"`                   // shortest frozen range reported, in seconds (default 2)
+	FreezeNoiseDB     *float64 `json:"freeze_noise_db,omitempty" example:"-60"`                     // difference between frames below which the picture counts as frozen, -90 to 0 dB (default -60)
+	FailOnDefects     bool     `json:"fail_on_defects,omitempty" example:"true"`                    // fail the job, listing the ranges, instead of completing it with a report that has any
+	OutputOptions              // output name, timeout, scheduling, TTL and labels; the encoding settings do not apply
+	DeliveryOptions
+}
+
+// InputPaths returns the video
+func (r *DefectsRequest) InputPaths() []string {
+	return []string{r.VideoPath}
+}
+
+// Validate checks that the detection settings are within accepted ranges
+func (r *DefectsRequest) Validate() error {
+	if r.BlackMinDuration != nil && *r.BlackMinDuration <= 0 {
+		return fmt.Errorf("black_min_duration must be greater than 0")
+	}
+	if r.BlackThreshold != nil && (*r.BlackThreshold < 0 || *r.BlackThreshold > 1) {
+		return fmt.Errorf("black_threshold must be between 0 and 1")
+	}
+	if r.FreezeMinDuration != nil && *r.FreezeMinDuration <= 0 {
+		return fmt.Errorf("freeze_min_duration must be greater than 0")
+	}
+	if r.FreezeNoiseDB != nil && (*r.FreezeNoiseDB < -90 || *r.FreezeNoiseDB > 0) {
+		return fmt.Errorf("freeze_noise_db must be between -90 and 0")
+	}
+	if r.OutputFormat != "" {
+		return fmt.Errorf("output_format does not apply to defect detection, whose output is a JSON report")
+	}
+	return r.OutputOptions.Validate()
+}
+
+// ReportOptions returns the output options with json as the output format, which names the report file
+func (r *DefectsRequest) ReportOptions() OutputOptions {
+	opts := r.OutputOptions
+	opts.OutputFormat = "json"
+	return opts
+}
+
+// DefectRange represents a black or frozen range of a video
+type DefectRange struct {
+	Type     DefectKind `json:"type" example:"black"`
+	Start    float64    `json:"start" example:"0"`
+	End      float64    `json:"end" example:"3.2"`
+	Duration float64    `json:"duration" example:"3.2"`
+}
+
+// DefectReport represents the black and frozen ranges of a video, in the order they start
+type DefectReport struct {
+	VideoPath string        `json:"video_path" example:"/uploads/source.mp4"`
+	Duration  float64       `json:"duration" example:"62.5"` // total input duration (seconds)
+	Defects   []DefectRange `json:"defects"`
+}
+
+// DefaultDuplicateThreshold is the similarity from which two videos count as the same content
+const DefaultDuplicateThreshold = 0.9
+
+// validateThreshold checks the similarity threshold of a fingerprint comparison, if one is given
+func validateThreshold(threshold *float64) error {
+	if threshold != nil && (*threshold < 0.5 || *threshold > 1) {
+		return fmt.Errorf("threshold must be between 0.5 and 1")
+	}
+	return nil
+}
+
+// DuplicateThreshold returns the threshold of a request, or the default
+func DuplicateThreshold(threshold *float64) float64 {
+	if threshold == nil {
+		return DefaultDuplicateThreshold
+	}
+	return *threshold
+}
+
+// FingerprintRequest represents a request to fingerprint a video and look it up among the saved fingerprints
+type FingerprintRequest struct {
+	FilePath  string   `json:"file_path" binding:"required" example:"/uploads/video.mp4"` // local path
+	Threshold *float64 `json:"threshold,omitempty" example:"0.9"`                         // similarity from which a saved fingerprint matches, 0.5 to 1 (default 0.9)
+	Save      bool     `json:"save,omitempty" example:"true"`                             // save the fingerprint, after the lookup, so that later videos are checked against it
+	Name      string   `json:"name,omitempty" example:"Episode 12"`                       // label saved with the fingerprint, up to 200 characters
+}
+
+// Validate checks the path, threshold and name
+func (r *FingerprintRequest) Validate() error {
+	if r.FilePath == "" {
+		return fmt.Errorf("file_path is required")
+	}
+	if len(r.Name) > 200 {
+		return fmt.Errorf("name must be at most 200 characters")
+	}
+	return validateThreshold(r.Threshold)
+}
+
+// FingerprintMatch represents a saved fingerprint that a video matches
+type FingerprintMatch struct {
+	ID         string  `json:"id" example:"6f1c2a9e-3b7d-4c1e-9f2a-8d4b5e6c7a10"`
+	Name       string  `json:"name,omitempty" example:"Episode 12"`
+	FilePath   string  `json:"file_path" example:"/outputs/episode-12.mp4"`
+	Similarity float64 `json:"similarity" example:"0.97"` // share of equal hash bits, 0 to 1; unrelated videos score around 0.5
+	Offset     int     `json:"offset" example:"0"`        // seconds into the saved video at which the video starts, negative if it starts before
+	Overlap    int     `json:"overlap" example:"312"`     // seconds compared
+}
+
+// FingerprintResponse represents the fingerprint of a video and the saved fingerprints it matches, most similar
+// first
+type FingerprintResponse struct {
+	FilePath    string             `json:"file_path" example:"/uploads/video.mp4"`
+	Seconds     int                `json:"seconds" example:"312"`                                       // seconds hashed, from the start of the video
+	Fingerprint string             `json:"fingerprint"`                                                 // one 64-bit hash per second, base64
+	ID          string             `json:"id,omitempty" example:"6f1c2a9e-3b7d-4c1e-9f2a-8d4b5e6c7a10"` // of the saved fingerprint, with save
+	Duplicate   bool               `json:"duplicate" example:"true"`                                    // whether any saved fingerprint matches
+	Matches     []FingerprintMatch `json:"matches"`
+}
+
+// CompareRequest represents a request to compare the fingerprints of two videos
+type CompareRequest struct {
+	FilePath  string   `json:"file_path" binding:"required" example:"/uploads/video.mp4"`    // local path
+	OtherPath string   `json:"other_path" binding:"required" example:"/outputs/episode.mp4"` // local path
+	Threshold *float64 `json:"threshold,omitempty" example:"0.9"`                            // similarity from which the videos are duplicates, 0.5 to 1 (default 0.9)
+}
+
+// Validate checks the paths and threshold
+func (r *CompareRequest) Validate() error {
+	if r.FilePath == "" || r.OtherPath == "" {
+		return fmt.Errorf("file_path and other_path are required")
+	}
+	return validateThreshold(r.Threshold)
+}
+
+// CompareResponse represents the similarity of two videos at their best alignment
+type CompareResponse struct {
+	FilePath   string  `json:"file_path" example:"/uploads/video.mp4"`
+	OtherPath  string  `json:"other_path" example:"/outputs/episode.mp4"`
+	Similarity float64 `json:"similarity" example:"0.97"` // share of equal hash bits, 0 to 1; unrelated videos score around 0.5
+	Offset     int     `json:"offset" example:"-4"`       // seconds into file_path at which other_path starts, negative if it starts before
+	Overlap    int     `json:"overlap" example:"312"`     // seconds compared
+	Duplicate  bool    `json:"duplicate" example:"true"`  // whether the similarity reaches the threshold
+}
+
+// FingerprintEntry represents a saved fingerprint
+type FingerprintEntry struct {
+	ID          string    `json:"id" example:"6f1c2a9e-3b7d-4c1e-9f2a-8d4b5e6c7a10"`
+	Name        string    `json:"name,omitempty" example:"Episode 12"`
+	FilePath    string    `json:"file_path" example:"/outputs/episode-12.mp4"`
+	Seconds     int       `json:"seconds" example:"312"`
+	Fingerprint string    `json:"fingerprint,omitempty"` // left out of listings
+	CreatedBy   string    `json:"created_by,omitempty" example:"ingest"`
+	CreatedAt   time.Time `json:"created_at" example:"2025-01-13T10:00:00Z"`
+}
+
+// FingerprintsResponse represents the saved fingerprints, newest first
+type FingerprintsResponse struct {
+	Fingerprints []FingerprintEntry `json:"fingerprints"`
+}
+
+// ProbeRequest represents a request to inspect a media file
+type ProbeRequest struct {
+	FilePath string `json:"file_path" binding:"required" example:"/uploads/video.mp4"` // local path
+}
+
+// MediaProbe describes a media file as reported by ffprobe
+type MediaProbe struct {
+	FilePath   string        `json:"file_path" example:"/uploads/video.mp4"`
+	FormatName string        `json:"format_name" example:"mov,mp4,m4a,3gp,3g2,mj2"`
+	Duration   float64       `json:"duration" example:"62.5"`              // seconds
+	Size       int64         `json:"size" example:"10485760"`              // bytes
+	BitRate    int64         `json:"bit_rate,omitempty" example:"1342177"` // overall bits per second
+	Streams    []MediaStream `json:"streams"`
+}
+
+// MediaStream describes one stream of a media file
+type MediaStream struct {
+	Index             int     `json:"index" example:"0"`
+	Type              string  `json:"type" example:"video"` // video, audio, subtitle, or data
+	Codec             string  `json:"codec" example:"h264"`
+	Profile           string  `json:"profile,omitempty" example:"High"`
+	BitRate           int64   `json:"bit_rate,omitempty" example:"1200000"` // bits per second
+	Duration          float64 `json:"duration,omitempty" example:"62.5"`    // seconds
+	Language          string  `json:"language,omitempty" example:"eng"`
+	Width             int     `json:"width,omitempty" example:"1920"`
+	Height            int     `json:"height,omitempty" example:"1080"`
+	PixFmt            string  `json:"pix_fmt,omitempty" example:"yuv420p"`
+	SampleAspectRatio string  `json:"sample_aspect_ratio,omitempty" example:"1:1"`
+	FrameRate         float64 `json:"frame_rate,omitempty" example:"29.97"`
+	Rotation          int     `json:"rotation,omitempty" example:"90"` // display rotation in degrees clockwise: 0, 90, 180, or 270
+	SampleRate        int     `json:"sample_rate,omitempty" example:"48000"`
+	Channels          int     `json:"channels,omitempty" example:"2"`
+	ChannelLayout     string  `json:"channel_layout,omitempty" example:"stereo"`
+}
+
+// VideoStream returns the first video stream, or nil if there is none
+func (p *MediaProbe) VideoStream() *MediaStream {
+	return p.firstStream("video")
+}
+
+// AudioStream returns the first audio stream, or nil if there is none
+func (p *MediaProbe) AudioStream() *MediaStream {
+	return p.firstStream("audio")
+}
+
+func (p *MediaProbe) firstStream(kind string) *MediaStream {
+	for i := range p.Streams {
+		if p.Streams[i].Type == kind {
+			return &p.Streams[i]
+		}
+	}
+	return nil
+}
+
+// DefaultLoudnessTolerance is how far in LU the integrated loudness may be from target_lufs of a loudness check
+const DefaultLoudnessTolerance = 1.0
+
+// LoudnessRequest represents a request to measure the loudness of the audio of a file, optionally checking it
+// against a delivery specification such as EBU R128 (-23 LUFS, -1 dBTP) or ATSC A/85 (-24 LUFS, -2 dBTP)
+type LoudnessRequest struct {
+	FilePath    string   `json:"file_path" binding:"required" example:"/outputs/episode.mp4"` // local path
+	TargetLUFS  *float64 `json:"target_lufs,omitempty" example:"-23"`                         // integrated loudness the file must have, -70 to -5
+	Tolerance   *float64 `json:"tolerance,omitempty" example:"1"`                             // allowed deviation from target_lufs in LU, 0 to 10 (default 1)
+	MaxTruePeak *float64 `json:"max_true_peak,omitempty" example:"-1"`                        // highest true peak the file may have in dBTP, -9 to 0
+}
+
+// Validate checks the path and that the specification is within accepted ranges
+func (r *LoudnessRequest) Validate() error {
+	if r.FilePath == "" {
+		return fmt.Errorf("file_path is required")
+	}
+	if r.TargetLUFS != nil && (*r.TargetLUFS < -70 || *r.TargetLUFS > -5) {
+		return fmt.Errorf("target_lufs must be between -70 and -5")
+	}
+	if r.Tolerance != nil {
+		if r.TargetLUFS == nil {
+			return fmt.Errorf("tolerance requires target_lufs")
+		}
+		if *r.Tolerance < 0 || *r.Tolerance > 10 {
+			return fmt.Errorf("tolerance must be between 0 and 10")
+		}
+	}
+	if r.MaxTruePeak != nil && (*r.MaxTruePeak < -9 || *r.MaxTruePeak > 0) {
+		return fmt.Errorf("max_true_peak must be between -9 and 0")
+	}
+	return nil
+}
+
+// Check returns whether the measured loudness meets the specification of the request, and the reasons it
+// does not. Without target_lufs and max_true_peak there is nothing to check, and it returns nil.
+func (r *LoudnessRequest) Check(report *LoudnessResponse) (*bool, []string) {
+	if r.TargetLUFS == nil && r.MaxTruePeak == nil {
+		return nil, nil
+	}
+	var issues []string
+	if r.TargetLUFS != nil {
+		tolerance := DefaultLoudnessTolerance
+		if r.Tolerance != nil {
+			tolerance = *r.Tolerance
+		}
+		if deviation := report.IntegratedLUFS - *r.TargetLUFS; deviation > tolerance || deviation < -tolerance {
+			issues = append(issues, fmt.Sprintf("integrated loudness %.1f LUFS is %+.1f LU from the %.1f LUFS target (tolerance %.1f LU)",
+				report.IntegratedLUFS, deviation, *r.TargetLUFS, tolerance))
+		}
+	}
+	if r.MaxTruePeak != nil && report.TruePeak > *r.MaxTruePeak {
+		issues = append(issues, fmt.Sprintf("true peak %.1f dBTP exceeds %.1f dBTP", report.TruePeak, *r.MaxTruePeak))
+	}
+	compliant := len(issues) == 0
+	return &compliant, issues
+}
 
-const (
-	AnimationFade  AnimationType = "fade"
-	AnimationSlide AnimationType = "slide"
-	AnimationZoom  AnimationType = "zoom"
-	AnimationNone  AnimationType = "none"
-)
+// LoudnessResponse represents the EBU R128 loudness (ebur128) and the levels (astats) of the audio of a file.
+// Levels of silence, which FFmpeg reports as -inf, are given as -144 dB.
+type LoudnessResponse struct {
+	FilePath       string   `json:"file_path" example:"/outputs/episode.mp4"`
+	Duration       float64  `json:"duration" example:"1800.5"`          // seconds
+	IntegratedLUFS float64  `json:"integrated_lufs" example:"-23.1"`    // integrated loudness of the whole file
+	LoudnessRange  float64  `json:"loudness_range" example:"7.4"`       // LRA in LU
+	TruePeak       float64  `json:"true_peak" example:"-1.8"`           // highest true (inter-sample) peak in dBTP
+	SamplePeak     float64  `json:"sample_peak" example:"-2.1"`         // highest sample in dBFS
+	RMSLevel       float64  `json:"rms_level" example:"-26.4"`          // in dBFS
+	RMSPeak        float64  `json:"rms_peak" example:"-12.9"`           // loudest RMS window in dBFS
+	DCOffset       float64  `json:"dc_offset" example:"0.00002"`        // mean sample value, from -1 to 1
+	Compliant      *bool    `json:"compliant,omitempty" example:"true"` // whether the file meets target_lufs and max_true_peak, when either is given
+	Issues         []string `json:"issues,omitempty"`                   // why the file does not meet them
+}
 
-// SlideDirection represents slide direction
-type SlideDirection string
+// QualityMetric represents a measure of how closely an encoded video matches its source
+type QualityMetric string
 
 const (
-	SlideFromLeft   SlideDirection = "left"
-	SlideFromRight  SlideDirection = "right"
-	SlideFromTop    SlideDirection = "top"
-	SlideFromBottom SlideDirection = "bottom"
+	QualityVMAF QualityMetric = "vmaf"
+	QualityPSNR QualityMetric = "psnr"
+	QualitySSIM QualityMetric = "ssim"
 )
 
-// ImageOverlay represents image overlay configuration
-type ImageOverlay struct {
-	FilePath  string          `json:"file_path" example:"/uploads/logo.png"`
-	Position  OverlayPosition `json:"position" example:"top-left"`
-	X         *int            `json:"x,omitempty" example:"10"` // custom x position (only if position is "custom")
-	Y         *int            `json:"y,omitempty" example:"10"` // custom y position (only if position is "custom")
-	StartTime float64         `json:"start_time" example:"0"`   // when overlay appears (seconds)
-	EndTime   float64         `json:"end_time" example:"5"`     // when overlay disappears (seconds)
-	Animation AnimationType   `json:"animation" example:"fade"`
-	// Animation specific options
-	FadeDuration   *float64        `json:"fade_duration,omitempty" example:"1.0"` // fade in/out duration
-	SlideDirection *SlideDirection `json:"slide_direction,omitempty" example:"left"`
-	SlideDuration  *float64        `json:"slide_duration,omitempty" example:"1.0"`
-	ZoomFrom       *float64        `json:"zoom_from,omitempty" example:"0.5"` // initial zoom level
-	ZoomTo         *float64        `json:"zoom_to,omitempty" example:"1.5"`   // final zoom level
+// QualityRequest represents a request to compare an encoded video against its source
+type QualityRequest struct {
+	ReferencePath string          `json:"reference_path" binding:"required" example:"/uploads/source.mp4"`  // local path of the source
+	DistortedPath string          `json:"distorted_path" binding:"required" example:"/outputs/encoded.mp4"` // local path of the encoded video
+	Metrics       []QualityMetric `json:"metrics,omitempty" example:"vmaf,psnr"`                            // vmaf, psnr, ssim; defaults to all that FFmpeg supports
 }
 
-// AudioConfig represents background music configuration
-type AudioConfig struct {
-	FilePath  string   `json:"file_path" example:"/uploads/music.mp3"`
-	Volume    float64  `json:"volume" example:"0.3"`             // 0.0 to 1.0
-	StartTime *float64 `json:"start_time,omitempty" example:"0"` // trim audio start (seconds)
-	EndTime   *float64 `json:"end_time,omitempty" example:"30"`  // trim audio end (seconds)
-	FadeIn    *float64 `json:"fade_in,omitempty" example:"2"`    // fade in duration
-	FadeOut   *float64 `json:"fade_out,omitempty" example:"2"`   // fade out duration
+// InputPaths returns the reference and the distorted video
+func (r *QualityRequest) InputPaths() []string {
+	return []string{r.ReferencePath, r.DistortedPath}
 }
 
-// MergeVideoRequest represents video merge request
-type MergeVideoRequest struct {
-	Segments []VideoSegment `json:"segments" binding:"required,min=2"`
+// Validate checks the paths and the metrics
+func (r *QualityRequest) Validate() error {
+	if r.ReferencePath == "" {
+		return fmt.Errorf("reference_path is required")
+	}
+	if r.DistortedPath == "" {
+		return fmt.Errorf("distorted_path is required")
+	}
+	for i, metric := range r.Metrics {
+		switch metric {
+		case QualityVMAF, QualityPSNR, QualitySSIM:
+		default:
+			return fmt.Errorf("metrics must be vmaf, psnr, or ssim")
+		}
+		if slices.Contains(r.Metrics[:i], metric) {
+			return fmt.Errorf("metrics lists %s twice", metric)
+		}
+	}
+	return nil
 }
 
-// OverlayRequest represents image overlay request
-type OverlayRequest struct {
-	VideoPath string       `json:"video_path" binding:"required"`
-	Overlay   ImageOverlay `json:"overlay" binding:"required"`
+// VMAFScore represents the VMAF scores of the frames, from 0 to 100
+type VMAFScore struct {
+	Mean         float64 `json:"mean" example:"94.2"`
+	HarmonicMean float64 `json:"harmonic_mean" example:"94.1"` // weighs the worst frames more than the mean
+	Min          float64 `json:"min" example:"81.7"`
+	Max          float64 `json:"max" example:"99.3"`
 }
 
-// AudioRequest represents background music request
-type AudioRequest struct {
-	VideoPath string      `json:"video_path" binding:"required"`
-	Audio     AudioConfig `json:"audio" binding:"required"`
+// PSNRScore represents the peak signal-to-noise ratio of the frames, in dB
+type PSNRScore struct {
+	Average float64 `json:"average" example:"41.8"` // of the Y, U and V planes
+	Min     float64 `json:"min" example:"36.2"`     // of the worst frame
+	Max     float64 `json:"max" example:"48.9"`     // of the best frame
+	Y       float64 `json:"y" example:"40.6"`
+	U       float64 `json:"u" example:"46.3"`
+	V       float64 `json:"v" example:"47.1"`
 }
 
-// CompleteProcessRequest represents complete video processing request
-type CompleteProcessRequest struct {
-	Segments []VideoSegment `json:"segments" binding:"required,min=1"`
-	Overlays []ImageOverlay `json:"overlays,omitempty"`
-	Audio    *AudioConfig   `json:"audio,omitempty"`
+// SSIMScore represents the mean structural similarity of the frames, from 0 to 1
+type SSIMScore struct {
+	All float64 `json:"all" example:"0.987"` // of the Y, U and V planes
+	Y   float64 `json:"y" example:"0.982"`
+	U   float64 `json:"u" example:"0.994"`
+	V   float64 `json:"v" example:"0.995"`
+}
+
+// QualityResponse represents the scores of an encoded video against its source. The frames are compared at
+// the resolution of the reference.
+type QualityResponse struct {
+	ReferencePath string     `json:"reference_path" example:"/uploads/source.mp4"`
+	DistortedPath string     `json:"distorted_path" example:"/outputs/encoded.mp4"`
+	Width         int        `json:"width" example:"1920"`
+	Height        int        `json:"height" example:"1080"`
+	VMAF          *VMAFScore `json:"vmaf,omitempty"`
+	PSNR          *PSNRScore `json:"psnr,omitempty"`
+	SSIM          *SSIMScore `json:"ssim,omitempty"`
 }
 
 // WebhookHeader represents a custom header for webhook requests
@@ -111,11 +1595,166 @@ type WebhookHeader struct {
 	Value string `json:"value" example:"loremIPSUM"`
 }
 
-// CombineVideosRequest represents request to combine videos from URLs
+// CombineVideosRequest represents request to combine videos from URLs. The output is uploaded to the storage
+// backend, whether upload_to_s3 is set or not, unless destination is local.
 type CombineVideosRequest struct {
-	Videos        []string       `json:"videos" binding:"required,min=2"`
-	WebhookURL    string         `json:"webhook_url,omitempty"`
-	WebhookHeader *WebhookHeader `json:"webhook_header,omitempty"`
+	Videos    []string `json:"videos" binding:"required,min=2"`
+	Checksums []string `json:"checksums,omitempty" example:"sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"` // expected checksum of each video, as sha256:<hex> or md5:<hex>; empty entries are not verified
+	OutputOptions
+	DeliveryOptions
+}
+
+// DeliveryOptions represents how the result of a job is handed over: a webhook called when the job ends, and an
+// upload of its output to the storage backend
+type DeliveryOptions struct {
+	WebhookURL     string            `json:"webhook_url,omitempty" example:"https://example.com/hooks/govid"` // called with the job's result when it completes or fails
+	WebhookHeaders map[string]string `json:"webhook_headers,omitempty"`                                       // headers sent with the webhook, such as a token
+	WebhookHeader  *WebhookHeader    `json:"webhook_header,omitempty"`                                        // a single webhook header; prefer webhook_headers
+	UploadToS3     bool              `json:"upload_to_s3,omitempty" example:"true"`                           // move the output to the storage backend when the job completes, reporting its link as s3_url
+	Destination    Destination       `json:"destination,omitempty" example:"storage"`                         // local or storage; storage is the same as upload_to_s3, local keeps even a combine output in OUTPUT_DIR
+	StorageOptions
+}
+
+// Destination represents where the output of a job ends up
+type Destination string
+
+const (
+	DestinationLocal   Destination = "local"   // OUTPUT_DIR
+	DestinationStorage Destination = "storage" // the storage backend
+)
+
+// maxWebhookHeaders is the number of webhook headers a request may set
+const maxWebhookHeaders = 20
+
+// Validate checks the webhook URL and headers and the storage options
+func (o *DeliveryOptions) Validate() error {
+	if o.WebhookURL != "" {
+		u, err := url.Parse(o.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook_url must be an http or https URL")
+		}
+	}
+	switch o.Destination {
+	case "", DestinationStorage:
+	case DestinationLocal:
+		if o.UploadToS3 {
+			return fmt.Errorf("upload_to_s3 cannot be combined with destination local")
+		}
+	default:
+		return fmt.Errorf("destination must be local or storage")
+	}
+	headers := o.Headers()
+	if len(headers) > maxWebhookHeaders {
+		return fmt.Errorf("at most %d webhook headers allowed", maxWebhookHeaders)
+	}
+	for key, value := range headers {
+		// Basic validation
+		if key == "" || len(key) > 100 || len(value) > 1000 {
+			return fmt.Errorf("webhook header key must be non-empty and less than 100 characters, value less than 1000 characters")
+		}
+		if !printableASCII(key) || !printableASCII(value) {
+			return fmt.Errorf("webhook header %q must be printable ASCII", key)
+		}
+		// Prevent overriding critical headers
+		if strings.EqualFold(key, "host") || strings.EqualFold(key, "content-length") {
+			return fmt.Errorf("cannot override Host or Content-Length headers")
+		}
+	}
+	return o.StorageOptions.Validate()
+}
+
+// Uploads reports whether the output is to be moved to the storage backend
+func (o *DeliveryOptions) Uploads() bool {
+	return o.UploadToS3 || o.Destination == DestinationStorage
+}
+
+// Headers returns the webhook headers, with the single webhook_header among them
+func (o *DeliveryOptions) Headers() map[string]string {
+	if o.WebhookHeader == nil {
+		return o.WebhookHeaders
+	}
+	headers := maps.Clone(o.WebhookHeaders)
+	if headers == nil {
+		headers = make(map[string]string, 1)
+	}
+	headers[o.WebhookHeader.Key] = o.WebhookHeader.Value
+	return headers
+}
+
+// StorageOptions represents how a job output is stored in the storage backend
+type StorageOptions struct {
+	OutputKey      string            `json:"output_key,omitempty" example:"videos/{date}/{job_id}.{ext}"` // object key, with the placeholders of S3_KEY_TEMPLATE; defaults to S3_KEY_TEMPLATE
+	CacheControl   string            `json:"cache_control,omitempty" example:"public, max-age=31536000"`  // Cache-Control served with the object; defaults to STORAGE_CACHE_CONTROL
+	ObjectMetadata map[string]string `json:"object_metadata,omitempty"`                                   // user metadata stored with the object (x-amz-meta-*, x-goog-meta-*)
+	Sidecars       *bool             `json:"sidecars,omitempty" example:"true"`                           // also upload a thumbnail and metadata.json next to the output; defaults to STORAGE_SIDECARS
+	Bucket         string            `json:"bucket,omitempty" example:"client-exports"`                   // bucket to upload to instead of the configured one; must be listed in STORAGE_ALLOWED_BUCKETS
+
+	// OriginalName is the name of the job's first input for the {original_name} placeholder, set by the handler
+	OriginalName string `json:"-"`
+}
+
+// AssetMetadata is the metadata.json sidecar uploaded next to a job output
+type AssetMetadata struct {
+	JobID       string    `json:"job_id"`
+	Key         string    `json:"key"` // object key of the output
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	SHA256      string    `json:"sha256"`
+	Duration    float64   `json:"duration"` // seconds
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	FrameRate   float64   `json:"frame_rate,omitempty"`
+	VideoCodec  string    `json:"video_codec,omitempty"`
+	AudioCodec  string    `json:"audio_codec,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// objectMetadataKeyPattern matches the user metadata keys accepted by S3 and GCS
+var objectMetadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// maxObjectMetadataBytes is the S3 limit on the total size of user metadata keys and values
+const maxObjectMetadataBytes = 2048
+
+// bucketNamePattern matches the bucket names accepted in StorageOptions, a subset common to S3 and GCS
+var bucketNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{1,61}[a-z0-9]$`)
+
+// Validate checks that the storage options can be sent as object headers
+func (o *StorageOptions) Validate() error {
+	if o.Bucket != "" && !bucketNamePattern.MatchString(o.Bucket) {
+		return fmt.Errorf("bucket must be 3-63 lowercase letters, digits, '.', '_' or '-'")
+	}
+	if o.OutputKey != "" {
+		if err := storage.ValidateKeyTemplate(o.OutputKey); err != nil {
+			return fmt.Errorf("output_key: %w", err)
+		}
+	}
+	if len(o.CacheControl) > 256 || !printableASCII(o.CacheControl) {
+		return fmt.Errorf("cache_control must be at most 256 printable ASCII characters")
+	}
+	size := 0
+	for key, value := range o.ObjectMetadata {
+		if !objectMetadataKeyPattern.MatchString(key) {
+			return fmt.Errorf("object_metadata key %q must be 1-128 letters, digits, '-' or '_'", key)
+		}
+		if !printableASCII(value) {
+			return fmt.Errorf("object_metadata value of %q must be printable ASCII", key)
+		}
+		size += len(key) + len(value)
+	}
+	if size > maxObjectMetadataBytes {
+		return fmt.Errorf("object_metadata must be at most %d bytes in total", maxObjectMetadataBytes)
+	}
+	return nil
+}
+
+// printableASCII reports whether s only contains printable ASCII characters, as HTTP header values need
+func printableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
 }
 
 // JobResponse represents a job response
@@ -128,20 +1767,112 @@ type JobResponse struct {
 
 // JobStatusResponse represents job status response
 type JobStatusResponse struct {
-	JobID      string    `json:"job_id" example:"550e8400-e29b-41d4-a716-446655440000"`
-	Status     JobStatus `json:"status" example:"processing"`
-	Progress   int       `json:"progress" example:"50"` // 0-100
-	OutputPath string    `json:"output_path,omitempty" example:"/outputs/result.mp4"`
-	S3URL      string    `json:"s3_url,omitempty" example:"https://s3.amazonaws.com/bucket/video.mp4"`
-	Error      string    `json:"error,omitempty" example:""`
-	CreatedAt  time.Time `json:"created_at" example:"2025-01-13T10:00:00Z"`
-	UpdatedAt  time.Time `json:"updated_at" example:"2025-01-13T10:05:00Z"`
+	JobID          string            `json:"job_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Status         JobStatus         `json:"status" example:"processing"`
+	Progress       int               `json:"progress" example:"50"` // 0-100
+	OutputPath     string            `json:"output_path,omitempty" example:"/outputs/result.mp4"`
+	S3URL          string            `json:"s3_url,omitempty" example:"https://s3.amazonaws.com/bucket/video.mp4"`
+	S3URLExpiresAt *time.Time        `json:"s3_url_expires_at,omitempty" example:"2025-01-14T10:05:00Z"`                           // set for presigned and signed links
+	ThumbnailURL   string            `json:"thumbnail_url,omitempty" example:"https://s3.amazonaws.com/bucket/video.jpg"`          // poster image uploaded next to the output
+	MetadataURL    string            `json:"metadata_url,omitempty" example:"https://s3.amazonaws.com/bucket/video.metadata.json"` // metadata.json uploaded next to the output
+	Error          string            `json:"error,omitempty" example:""`
+	CreatedBy      string            `json:"created_by,omitempty" example:"ci-pipeline"` // API key name
+	RequestID      string            `json:"request_id,omitempty" example:"7f3c9a2e-1b4d-4e8f-9a6b-2c5d8e1f0a3b"`
+	RetryOf        string            `json:"retry_of,omitempty" example:""`                       // job this job retries
+	QueuePosition  int               `json:"queue_position,omitempty" example:"2"`                // place among the jobs waiting for an FFmpeg slot, from 1; left out when not waiting
+	StartedAt      *time.Time        `json:"started_at,omitempty" example:"2025-01-13T10:00:05Z"` // when the job's first FFmpeg command started
+	ETASeconds     *int              `json:"eta_seconds,omitempty" example:"95"`                  // estimated seconds until a processing job finishes, from the throughput of earlier jobs of its type
+	Steps          []StepStatus      `json:"steps,omitempty"`                                     // progress of each step of a pipeline job
+	DependsOn      []string          `json:"depends_on,omitempty"`                                // jobs whose outputs are inputs of this job; it stays pending until they complete
+	RunAt          *time.Time        `json:"run_at,omitempty" example:"2025-01-14T02:00:00Z"`     // when a scheduled job starts
+	Labels         map[string]string `json:"labels,omitempty"`                                    // labels set when the job was created
+	ExpiresAt      *time.Time        `json:"expires_at,omitempty" example:"2025-01-14T10:05:00Z"` // when the outputs of a completed job are deleted and it becomes expired
+	CreatedAt      time.Time         `json:"created_at" example:"2025-01-13T10:00:00Z"`
+	UpdatedAt      time.Time         `json:"updated_at" example:"2025-01-13T10:05:00Z"`
+}
+
+// StepStatus represents the status of one step of a pipeline job
+type StepStatus struct {
+	ID       string           `json:"id" example:"merged"`
+	Type     PipelineStepType `json:"type" example:"merge"`
+	Status   JobStatus        `json:"status" example:"processing"`
+	Progress int              `json:"progress" example:"40"` // 0-100
+	Error    string           `json:"error,omitempty" example:""`
+}
+
+// UsageResponse represents an API key's processed video minutes for the current month
+type UsageResponse struct {
+	KeyName          string   `json:"key_name" example:"ci-pipeline"`
+	Period           string   `json:"period" example:"2025-01"` // calendar month, UTC
+	Jobs             int      `json:"jobs" example:"42"`
+	InputMinutes     float64  `json:"input_minutes" example:"180.5"`
+	OutputMinutes    float64  `json:"output_minutes" example:"120.25"` // counted against the quota
+	QuotaMinutes     float64  `json:"quota_minutes,omitempty" example:"500"`
+	RemainingMinutes *float64 `json:"remaining_minutes,omitempty" example:"379.75"`
+}
+
+// CreateAPIKeyRequest represents a request to create a managed API key
+type CreateAPIKeyRequest struct {
+	Name         string  `json:"name" binding:"required" example:"ci-pipeline"`
+	Scope        string  `json:"scope" binding:"required" example:"process"` // read, process, or admin
+	QuotaMinutes float64 `json:"quota_minutes,omitempty" example:"500"`      // monthly processed-minutes quota; 0 means unlimited
+}
+
+// APIKeyResponse represents an API key. Key is only returned when the key is created or rotated.
+type APIKeyResponse struct {
+	Name         string  `json:"name" example:"ci-pipeline"`
+	Scope        string  `json:"scope" example:"process"`
+	QuotaMinutes float64 `json:"quota_minutes,omitempty" example:"500"`
+	Managed      bool    `json:"managed" example:"true"` // false for keys defined in configuration
+	Key          string  `json:"key,omitempty" example:"3f9a..."`
+}
+
+// APIKeysResponse represents the list of API keys
+type APIKeysResponse struct {
+	Keys []APIKeyResponse `json:"keys"`
+}
+
+// AdminJob is a job as listed on the admin dashboard
+type AdminJob struct {
+	JobStatusResponse
+	Retryable bool `json:"retryable" example:"true"`
+}
+
+// AdminJobsResponse represents the admin job list
+type AdminJobsResponse struct {
+	Jobs  []AdminJob `json:"jobs"`
+	Total int        `json:"total" example:"120"` // jobs in the store; Jobs may be limited
+}
+
+// DiskStats is the free space of a storage directory
+type DiskStats struct {
+	Name   string `json:"name" example:"output_dir"`
+	Path   string `json:"path" example:"./outputs"`
+	FreeMB uint64 `json:"free_mb" example:"20480"`
+	Error  string `json:"error,omitempty" example:""`
+}
+
+// DrainResponse represents the progress of draining an instance
+type DrainResponse struct {
+	Draining      bool       `json:"draining" example:"true"`
+	Since         *time.Time `json:"since,omitempty"`            // when the drain started
+	ActiveJobs    int        `json:"active_jobs" example:"2"`    // pending and processing jobs the instance still runs
+	ScheduledJobs int        `json:"scheduled_jobs" example:"0"` // jobs with a run_at to come, which do not run once the instance stops
+	Drained       bool       `json:"drained" example:"false"`    // draining with no active jobs left, so the instance can stop
+}
+
+// AdminStatsResponse represents job queue and disk statistics
+type AdminStatsResponse struct {
+	Jobs              map[JobStatus]int `json:"jobs"` // job count by status
+	MaxConcurrentJobs int               `json:"max_concurrent_jobs" example:"3"`
+	Disk              []DiskStats       `json:"disk"`
 }
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error   string `json:"error" example:"Invalid request"`
 	Message string `json:"message,omitempty" example:"Detailed error message"`
+	Input   string `json:"input,omitempty" example:"/uploads/video.mp4"` // the input a request was refused for, if any
 }
 
 // HealthResponse represents health check response
@@ -150,19 +1881,64 @@ type HealthResponse struct {
 	Version string `json:"version" example:"1.0.0"`
 }
 
+// CheckResult is the outcome of one readiness check
+type CheckResult struct {
+	Status  string `json:"status" example:"ok"` // ok or fail
+	Message string `json:"message,omitempty" example:"ffmpeg is not runnable: exec: \"ffmpeg\": executable file not found in $PATH"`
+}
+
+// ReadinessResponse represents readiness check response
+type ReadinessResponse struct {
+	Status string                 `json:"status" example:"ok"` // ok when every check passed, fail otherwise
+	Checks map[string]CheckResult `json:"checks"`
+	FFmpeg *FFmpegCapabilities    `json:"ffmpeg,omitempty"`
+}
+
+// FFmpegCapabilities reports what the FFmpeg binary supports of what GoVid uses, as detected at startup
+type FFmpegCapabilities struct {
+	Version  string          `json:"version" example:"6.1.1"`
+	Encoders map[string]bool `json:"encoders"`           // encoders GoVid uses, and whether the binary has them
+	Filters  map[string]bool `json:"filters"`            // filters GoVid uses, and whether the binary has them
+	Disabled []string        `json:"disabled,omitempty"` // features turned off because the binary lacks their encoders
+}
+
 // Job represents a processing job
 type Job struct {
-	ID            string
-	Status        JobStatus
-	Progress      int
-	OutputPath    string
-	S3URL         string
-	WebhookURL    string
-	WebhookHeader *WebhookHeader
-	Error         string
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	mu            sync.RWMutex
+	ID             string
+	Status         JobStatus
+	Progress       int
+	OutputPath     string
+	S3URL          string
+	S3URLExpires   time.Time // expiry of a presigned S3URL; zero for permanent links
+	StorageKey     string    // object name of the output in the storage backend, used to presign fresh links
+	StorageBucket  string    // bucket of the output when a request chose another than the configured one
+	ThumbnailURL   string    // link to the thumbnail sidecar
+	MetadataURL    string    // link to the metadata.json sidecar
+	WebhookURL     string
+	WebhookHeaders map[string]string
+	Upload         *StorageOptions   // options of the upload of the output to the storage backend; nil to keep it local
+	CreatedBy      string            // name of the API key that created the job
+	RequestID      string            // X-Request-ID of the request that created the job
+	RetryOf        string            // ID of the failed or cancelled job this job retries
+	Type           string            // kind of work, such as merge or overlay, set when the job starts
+	InputSeconds   float64           // probed duration of the job's inputs
+	StartedAt      time.Time         // when the job's first FFmpeg command got a slot
+	Processing     time.Duration     // time from StartedAt until the job completed
+	Steps          []StepStatus      // status of each step of a pipeline job
+	DependsOn      []string          // jobs whose outputs are inputs of this job, which it waits for
+	RunAt          time.Time         // when the job is due to start; zero to start right away
+	Labels         map[string]string // labels set when the job was created
+	TTL            time.Duration     // how long the outputs are kept once the job completes; zero to keep them
+	ExpiresAt      time.Time         // when the outputs are deleted, set when the job completes with a TTL
+	TraceContext   trace.SpanContext // span of the request that created the job; not persisted
+	Work           *JobWork          // kind and request of the job's work, to run it in any instance and retry it
+	Error          string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	cancel         context.CancelFunc // stops the running job; not persisted
+	claimed        bool               // the job runs in this process, which owns its record; not persisted
+	files          []string           // inputs and temporary files the job uses, which cleanup must keep; not persisted
+	mu             sync.RWMutex
 }
 
 // NewJob creates a new job
@@ -177,12 +1953,39 @@ func NewJob(id string) *Job {
 	}
 }
 
-// UpdateStatus updates job status
+// UpdateStatus updates job status. A cancelled job keeps its status.
 func (j *Job) UpdateStatus(status JobStatus) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
+	if j.Status == JobStatusCancelled {
+		return
+	}
 	j.Status = status
 	j.UpdatedAt = time.Now()
+	if status == JobStatusCompleted && !j.StartedAt.IsZero() {
+		j.Processing = j.UpdatedAt.Sub(j.StartedAt)
+	}
+	if status == JobStatusCompleted && j.TTL > 0 {
+		j.ExpiresAt = j.UpdatedAt.Add(j.TTL).Truncate(time.Second)
+	}
+}
+
+// SetInput records the kind of work the job does and the duration of its inputs, which its ETA is estimated from
+func (j *Job) SetInput(jobType string, inputSeconds float64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Type = jobType
+	j.InputSeconds = inputSeconds
+}
+
+// MarkStarted records when the job's work started. Only the first call counts, so time spent waiting for a
+// slot between the job's FFmpeg commands is part of its processing time.
+func (j *Job) MarkStarted() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.StartedAt.IsZero() {
+		j.StartedAt = time.Now()
+	}
 }
 
 // UpdateProgress updates job progress
@@ -193,6 +1996,38 @@ func (j *Job) UpdateProgress(progress int) {
 	j.UpdatedAt = time.Now()
 }
 
+// AdvanceProgress raises job progress to progress. Lower values are ignored, so progress never goes back
+// when a job runs several FFmpeg commands.
+func (j *Job) AdvanceProgress(progress int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if progress > j.Progress {
+		j.Progress = progress
+		j.UpdatedAt = time.Now()
+	}
+}
+
+// SetSteps records the steps of a pipeline job, all pending
+func (j *Job) SetSteps(steps []StepStatus) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Steps = steps
+	j.UpdatedAt = time.Now()
+}
+
+// UpdateStep updates the status and progress of step i of a pipeline job; err is why a failed step failed
+func (j *Job) UpdateStep(i int, status JobStatus, progress int, err string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if i < 0 || i >= len(j.Steps) {
+		return
+	}
+	j.Steps[i].Status = status
+	j.Steps[i].Progress = progress
+	j.Steps[i].Error = err
+	j.UpdatedAt = time.Now()
+}
+
 // SetOutput sets job output path
 func (j *Job) SetOutput(path string) {
 	j.mu.Lock()
@@ -201,37 +2036,257 @@ func (j *Job) SetOutput(path string) {
 	j.UpdatedAt = time.Now()
 }
 
-// SetS3URL sets job S3 URL
-func (j *Job) SetS3URL(url string) {
+// SetS3URL sets the link to the job's output in the storage backend, the bucket (empty for the configured
+// one) and object it points to, and its expiry (zero for links that do not expire)
+func (j *Job) SetS3URL(url, bucket, storageKey string, expires time.Time) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 	j.S3URL = url
+	j.StorageBucket = bucket
+	j.StorageKey = storageKey
+	j.S3URLExpires = expires
+	j.UpdatedAt = time.Now()
+}
+
+// SetSidecarURLs sets the links to the thumbnail and metadata.json uploaded next to the job's output
+func (j *Job) SetSidecarURLs(thumbnailURL, metadataURL string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.ThumbnailURL = thumbnailURL
+	j.MetadataURL = metadataURL
+	j.UpdatedAt = time.Now()
+}
+
+// Expire marks a completed job as expired and clears the paths and links of its outputs, which the caller
+// deletes. It reports false, and changes nothing, for a job that is not completed.
+func (j *Job) Expire() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Status != JobStatusCompleted {
+		return false
+	}
+	j.Status = JobStatusExpired
+	j.OutputPath = ""
+	j.S3URL = ""
+	j.S3URLExpires = time.Time{}
+	j.StorageKey = ""
+	j.StorageBucket = ""
+	j.ThumbnailURL = ""
+	j.MetadataURL = ""
 	j.UpdatedAt = time.Now()
+	return true
+}
+
+// Due reports whether the job is completed and its outputs are past their expiry at now
+func (j *Job) Due(now time.Time) bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.Status == JobStatusCompleted && !j.ExpiresAt.IsZero() && !now.Before(j.ExpiresAt)
+}
+
+// GetStorageKey returns the bucket and object name of the job's output in the storage backend, if it was
+// uploaded. The bucket is empty for the configured bucket.
+func (j *Job) GetStorageKey() (bucket, key string) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.StorageBucket, j.StorageKey
 }
 
-// SetError sets job error
+// SetError sets job error. Errors caused by cancellation do not mark a cancelled job as failed.
 func (j *Job) SetError(err string) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
+	if j.Status == JobStatusCancelled {
+		return
+	}
 	j.Error = err
 	j.Status = JobStatusFailed
 	j.UpdatedAt = time.Now()
 }
 
+// TimeoutError returns err marked as a timeout if the job context ctx ran past its deadline of timeout, so that
+// the job error tells a job that ran out of time from one that failed
+func TimeoutError(ctx context.Context, timeout time.Duration, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("job timed out after %s: %w", timeout, err)
+	}
+	return err
+}
+
+// Start marks the job as processing and registers the function that cancels it.
+// It returns false if the job was cancelled before it started.
+func (j *Job) Start(cancel context.CancelFunc) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Status == JobStatusCancelled {
+		return false
+	}
+	j.cancel = cancel
+	j.Status = JobStatusProcessing
+	j.UpdatedAt = time.Now()
+	return true
+}
+
+// Cancel stops a scheduled, pending or processing job. It returns false if the job has already finished.
+func (j *Job) Cancel() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Status != JobStatusScheduled && j.Status != JobStatusPending && j.Status != JobStatusProcessing {
+		return false
+	}
+	j.Status = JobStatusCancelled
+	j.UpdatedAt = time.Now()
+	if j.cancel != nil {
+		j.cancel()
+	}
+	return true
+}
+
+// Schedule marks the job as waiting for its run_at
+func (j *Job) Schedule() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Status = JobStatusScheduled
+	j.UpdatedAt = time.Now()
+}
+
+// Requeue moves a job that a restart interrupted back to pending, to run it again from the start
+func (j *Job) Requeue() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Status = JobStatusPending
+	j.Progress = 0
+	j.StartedAt = time.Time{}
+	j.UpdatedAt = time.Now()
+}
+
+// Promote moves a scheduled job that is due to pending. It returns false if the job was cancelled meanwhile.
+func (j *Job) Promote() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Status != JobStatusScheduled {
+		return false
+	}
+	j.Status = JobStatusPending
+	j.UpdatedAt = time.Now()
+	return true
+}
+
+// HasLabels reports whether the job has all of labels. An empty value matches any value of its key.
+func (j *Job) HasLabels(labels map[string]string) bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	for key, value := range labels {
+		jobValue, ok := j.Labels[key]
+		if !ok || (value != "" && jobValue != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetDelivery records the webhook of a job and, with upload_to_s3 or destination storage, the options of the
+// upload of its output.
+// It must be called before the job is added to the store.
+func (j *Job) SetDelivery(opts DeliveryOptions) {
+	j.WebhookURL = opts.WebhookURL
+	j.WebhookHeaders = opts.Headers()
+	if opts.Uploads() {
+		upload := opts.StorageOptions
+		j.Upload = &upload
+	}
+}
+
+// AddFiles records files the job reads or creates outside the output directory, such as its uploads and
+// downloaded inputs, so cleanup leaves them alone while the job is pending or processing
+func (j *Job) AddFiles(paths ...string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, path := range paths {
+		if path != "" {
+			j.files = append(j.files, filepath.Clean(path))
+		}
+	}
+}
+
+// Files returns the files recorded with AddFiles
+func (j *Job) Files() []string {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return slices.Clone(j.files)
+}
+
 // GetStatus returns current job status
 func (j *Job) GetStatus() JobStatusResponse {
 	j.mu.RLock()
 	defer j.mu.RUnlock()
+	var s3URLExpiresAt, startedAt, runAt, expiresAt *time.Time
+	if !j.S3URLExpires.IsZero() {
+		expires := j.S3URLExpires
+		s3URLExpiresAt = &expires
+	}
+	if !j.StartedAt.IsZero() {
+		started := j.StartedAt
+		startedAt = &started
+	}
+	if !j.RunAt.IsZero() {
+		due := j.RunAt
+		runAt = &due
+	}
+	if !j.ExpiresAt.IsZero() {
+		expires := j.ExpiresAt
+		expiresAt = &expires
+	}
 	return JobStatusResponse{
-		JobID:      j.ID,
-		Status:     j.Status,
-		Progress:   j.Progress,
-		OutputPath: j.OutputPath,
-		S3URL:      j.S3URL,
-		Error:      j.Error,
-		CreatedAt:  j.CreatedAt,
-		UpdatedAt:  j.UpdatedAt,
+		JobID:          j.ID,
+		Status:         j.Status,
+		Progress:       j.Progress,
+		OutputPath:     j.OutputPath,
+		S3URL:          j.S3URL,
+		S3URLExpiresAt: s3URLExpiresAt,
+		ThumbnailURL:   j.ThumbnailURL,
+		MetadataURL:    j.MetadataURL,
+		Error:          j.Error,
+		CreatedBy:      j.CreatedBy,
+		RequestID:      j.RequestID,
+		RetryOf:        j.RetryOf,
+		StartedAt:      startedAt,
+		Steps:          slices.Clone(j.Steps),
+		DependsOn:      slices.Clone(j.DependsOn),
+		RunAt:          runAt,
+		Labels:         maps.Clone(j.Labels),
+		ExpiresAt:      expiresAt,
+		CreatedAt:      j.CreatedAt,
+		UpdatedAt:      j.UpdatedAt,
+	}
+}
+
+// Logger returns a logger that tags messages with the job ID and, if set, the request ID
+func (j *Job) Logger() *logger.Logger {
+	l := logger.With("job_id", j.ID)
+	if j.RequestID != "" {
+		l = l.With("request_id", j.RequestID)
+	}
+	return l
+}
+
+// StartSpan starts a span for processing the job. It continues the trace of the request that created the job,
+// and the job ID is added to every span started from the returned context.
+func (j *Job) StartSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	if j.TraceContext.IsValid() {
+		ctx = trace.ContextWithSpanContext(ctx, j.TraceContext)
 	}
+	return tracing.Start(tracing.WithJobID(ctx, j.ID), name)
+}
+
+// EndSpan ends a span started with StartSpan, marking it as failed if the job failed
+func (j *Job) EndSpan(span trace.Span) {
+	status := j.GetStatus()
+	span.SetAttributes(attribute.String("govid.job_status", string(status.Status)))
+	if status.Status == JobStatusFailed {
+		span.SetStatus(codes.Error, status.Error)
+	}
+	span.End()
 }
 
 // JobStore manages jobs
@@ -239,6 +2294,8 @@ type JobStore struct {
 	jobs        map[string]*Job
 	mu          sync.RWMutex
 	persistence *JobPersistence
+	shared      bool                 // other instances share the directory, see Share
+	seen        map[string]time.Time // when the record of each job was last read or written by this instance
 }
 
 // NewJobStore creates a new job store
@@ -267,11 +2324,15 @@ func (s *JobStore) Add(job *Job) {
 	// Persist to disk if persistence is enabled
 	if s.persistence != nil {
 		_ = s.persistence.SaveJob(job)
+		s.saved(job)
 	}
 }
 
 // Get retrieves a job by ID
 func (s *JobStore) Get(id string) (*Job, bool) {
+	if s.shared {
+		s.refresh(id)
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	job, ok := s.jobs[id]
@@ -285,22 +2346,63 @@ func (s *JobStore) Update(job *Job) error {
 	s.jobs[job.ID] = job
 	// Persist to disk if persistence is enabled
 	if s.persistence != nil {
-		return s.persistence.SaveJob(job)
+		err := s.persistence.SaveJob(job)
+		s.saved(job)
+		return err
 	}
 	return nil
 }
 
+// List returns all jobs, newest first
+func (s *JobStore) List() []*Job {
+	if s.shared {
+		s.refreshAll()
+	}
+	s.mu.RLock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(jobs, func(a, b *Job) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return jobs
+}
+
 // Delete removes a job from the store
 func (s *JobStore) Delete(id string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	delete(s.jobs, id)
+	delete(s.seen, id)
 	// Delete from disk if persistence is enabled
 	if s.persistence != nil {
 		_ = s.persistence.DeleteJob(id)
 	}
 }
 
+// OpenLog opens the FFmpeg log of a job for appending, creating it if needed
+func (s *JobStore) OpenLog(jobID string) (*os.File, error) {
+	if s.persistence == nil {
+		return nil, fmt.Errorf("job logs require persistence")
+	}
+	path := s.persistence.LogPath(jobID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+}
+
+// LogPath returns the path of a job's FFmpeg log, or an empty string without persistence
+func (s *JobStore) LogPath(jobID string) string {
+	if s.persistence == nil {
+		return ""
+	}
+	return s.persistence.LogPath(jobID)
+}
+
 // GetJobsDir returns the jobs directory path
 func (s *JobStore) GetJobsDir() string {
 	if s.persistence == nil {
@@ -311,12 +2413,103 @@ func (s *JobStore) GetJobsDir() string {
 
 // UploadResponse represents file upload response
 type UploadResponse struct {
-	FileName string `json:"file_name" example:"video.mp4"`
-	FilePath string `json:"file_path" example:"/uploads/video.mp4"`
-	FileSize int64  `json:"file_size" example:"1048576"`
+	FileName   string     `json:"file_name" example:"video.mp4"`
+	FilePath   string     `json:"file_path" example:"/uploads/video.mp4"`
+	FileSize   int64      `json:"file_size" example:"1048576"`
+	SHA256     string     `json:"sha256" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	Existing   bool       `json:"existing,omitempty"`                                                                                   // an identical file was already uploaded and is returned instead
+	Checksum   string     `json:"checksum,omitempty" example:"sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"` // verified checksum of chunked uploads
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" example:"2025-01-15T10:00:00Z"`                                                  // when the file is deleted, for uploads with a TTL
+	ScanStatus string     `json:"scan_status,omitempty" example:"clean"`                                                                // result of the content scan, when UPLOAD_SCAN_BACKEND is set
 } // @name UploadResponse
 
 // MultiUploadResponse represents multiple file upload response
 type MultiUploadResponse struct {
 	Files []UploadResponse `json:"files"`
 } // @name MultiUploadResponse
+
+// UploadedFile describes a file in the upload directory
+type UploadedFile struct {
+	ID         string     `json:"id" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08.mp4"` // file name, used in /api/v1/uploads/{id}
+	FilePath   string     `json:"file_path" example:"/uploads/9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08.mp4"`
+	FileSize   int64      `json:"file_size" example:"1048576"`
+	ModifiedAt time.Time  `json:"modified_at" example:"2025-01-14T10:00:00Z"` // last upload of the file; cleanup age counts from here
+	AgeSeconds int64      `json:"age_seconds" example:"3600"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" example:"2025-01-21T10:00:00Z"` // when the TTL of the file ends, or from when the retention cleanup may remove it; unset without either
+	ScanStatus string     `json:"scan_status,omitempty" example:"clean"`               // clean, or unscanned while UPLOAD_SCAN_BACKEND is set for files jobs cannot use
+	ScannedAt  *time.Time `json:"scanned_at,omitempty" example:"2025-01-14T10:00:01Z"`
+} // @name UploadedFile
+
+// UploadListResponse represents the files in the upload directory
+type UploadListResponse struct {
+	Uploads   []UploadedFile `json:"uploads"`
+	Total     int            `json:"total" example:"42"`           // files in the directory; Uploads may be limited
+	TotalSize int64          `json:"total_size" example:"5242880"` // bytes of all files in the directory
+} // @name UploadListResponse
+
+// ChunkedUploadInitRequest starts a chunked upload
+type ChunkedUploadInitRequest struct {
+	FileName   string `json:"file_name" validate:"required" example:"video.mp4"`
+	FileSize   int64  `json:"file_size" validate:"required" example:"104857600"`
+	ChunkSize  int64  `json:"chunk_size,omitempty" example:"8388608"`                                                               // bytes per chunk; default and maximum MAX_CHUNK_SIZE_MB
+	Checksum   string `json:"checksum,omitempty" example:"sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"` // checksum of the whole file, as sha256:<hex> or md5:<hex>
+	TTLSeconds int    `json:"ttl_seconds,omitempty" example:"86400"`                                                                // delete the file this long after completing the upload; default UPLOAD_TTL_SECONDS
+} // @name ChunkedUploadInitRequest
+
+// Validate checks the file name and sizes; maxChunkSize and maxFileSize are the configured limits in bytes
+func (r *ChunkedUploadInitRequest) Validate(maxChunkSize, maxFileSize int64) error {
+	if r.FileName == "" {
+		return fmt.Errorf("file_name is required")
+	}
+	if r.FileSize <= 0 {
+		return fmt.Errorf("file_size must be positive")
+	}
+	if r.FileSize > maxFileSize {
+		return fmt.Errorf("file_size exceeds %d MB", maxFileSize>>20)
+	}
+	if r.ChunkSize < 0 || r.ChunkSize > maxChunkSize {
+		return fmt.Errorf("chunk_size must be between 1 and %d bytes", maxChunkSize)
+	}
+	if r.TTLSeconds < 0 {
+		return fmt.Errorf("ttl_seconds must not be negative")
+	}
+	return nil
+}
+
+// URLUploadRequest asks the server to download a file into the upload directory
+type URLUploadRequest struct {
+	URL        string `json:"url" validate:"required" example:"https://example.com/intro.mp4"`
+	Checksum   string `json:"checksum,omitempty" example:"sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"` // expected checksum of the file, as sha256:<hex> or md5:<hex>
+	TTLSeconds int    `json:"ttl_seconds,omitempty" example:"86400"`                                                                // delete the file this long after upload; default UPLOAD_TTL_SECONDS
+} // @name URLUploadRequest
+
+// DirectUploadRequest asks for a URL to upload a file straight to the storage bucket
+type DirectUploadRequest struct {
+	FileName    string `json:"file_name" validate:"required" example:"video.mp4"`
+	ContentType string `json:"content_type,omitempty" example:"video/mp4"` // Content-Type the upload must send; default detected from the extension
+} // @name DirectUploadRequest
+
+// DirectUploadResponse tells the client how to upload a file to the storage bucket and how to refer to it in jobs
+type DirectUploadResponse struct {
+	UploadURL string            `json:"upload_url" example:"https://s3.amazonaws.com/my-bucket/uploads/2025-01-14/550e8400-e29b-41d4-a716-446655440000.mp4?X-Amz-Signature=..."`
+	Method    string            `json:"method" example:"PUT"`
+	Headers   map[string]string `json:"headers"` // headers the upload request must send
+	Key       string            `json:"key" example:"uploads/2025-01-14/550e8400-e29b-41d4-a716-446655440000.mp4"`
+	InputURL  string            `json:"input_url" example:"s3://my-bucket/uploads/2025-01-14/550e8400-e29b-41d4-a716-446655440000.mp4"` // use as a file_path or video_path once uploaded
+	ExpiresAt time.Time         `json:"expires_at" example:"2025-01-14T11:00:00Z"`
+} // @name DirectUploadResponse
+
+// ChunkedUploadCompleteRequest finishes a chunked upload
+type ChunkedUploadCompleteRequest struct {
+	Checksum string `json:"checksum,omitempty" example:"sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"` // used when init had none
+} // @name ChunkedUploadCompleteRequest
+
+// ChunkedUploadResponse represents the state of a chunked upload
+type ChunkedUploadResponse struct {
+	UploadID       string `json:"upload_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	FileName       string `json:"file_name" example:"video.mp4"`
+	FileSize       int64  `json:"file_size" example:"104857600"`
+	ChunkSize      int64  `json:"chunk_size" example:"8388608"`
+	TotalChunks    int    `json:"total_chunks" example:"13"`
+	ReceivedChunks []int  `json:"received_chunks"` // numbers of the chunks stored so far
+} // @name ChunkedUploadResponse
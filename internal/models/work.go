@@ -0,0 +1,81 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bytedance/sonic"
+)
+
+// Kinds of job work. The work of a job is recorded with its request, so that any instance sharing the job
+// store can run it, and it can be retried.
+const (
+	WorkMerge        = "merge"
+	WorkOverlay      = "overlay"
+	WorkAudio        = "audio"
+	WorkComplete     = "complete"
+	WorkSilence      = "remove-silence"
+	WorkVertical     = "vertical"
+	WorkPipeline     = "pipeline"
+	WorkTranscribe   = "transcribe"
+	WorkAutoSubtitle = "auto-subtitle"
+	WorkDefects      = "defects"
+	WorkCombine      = "combine"       // combine of videos downloaded from URLs
+	WorkCombineFiles = "combine-files" // combine of uploaded files, which the job removes
+)
+
+// JobWork is the work of a job: its kind and the request it runs
+type JobWork struct {
+	Kind    string          `json:"kind"`
+	Request json.RawMessage `json:"request"`
+}
+
+// NewJobWork records req as the request of work of kind
+func NewJobWork(kind string, req any) (*JobWork, error) {
+	request, err := sonic.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record %s job: %w", kind, err)
+	}
+	return &JobWork{Kind: kind, Request: request}, nil
+}
+
+// Decode decodes the request of the work into req
+func (w *JobWork) Decode(req any) error {
+	if err := sonic.Unmarshal(w.Request, req); err != nil {
+		return fmt.Errorf("invalid request of %s job: %w", w.Kind, err)
+	}
+	return nil
+}
+
+// Retryable reports whether the work can run again. A combine of uploaded files removes them as it runs.
+func (w *JobWork) Retryable() bool {
+	return w.Kind != WorkCombineFiles
+}
+
+// SetWork records the work of the job
+func (j *Job) SetWork(work *JobWork) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Work = work
+}
+
+// GetWork returns the work of the job, or nil if it was not recorded
+func (j *Job) GetWork() *JobWork {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.Work
+}
+
+// RetryWork returns the work to run for a retry of the job, or nil if the job cannot be retried: it has not
+// failed or been cancelled, or its work was not recorded or cannot run again.
+func (j *Job) RetryWork() *JobWork {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if j.Status != JobStatusFailed && j.Status != JobStatusCancelled {
+		return nil
+	}
+	if j.Work == nil || !j.Work.Retryable() {
+		return nil
+	}
+	return j.Work
+}
@@ -0,0 +1,200 @@
+package presets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"govid/internal/models"
+
+	"github.com/bytedance/sonic"
+	"gopkg.in/yaml.v3"
+)
+
+// Registry holds the named encoding presets that requests can select with preset_name.
+// It is populated once at startup and read-only afterwards.
+type Registry struct {
+	presets    map[string]models.EncodingPreset
+	maxTimeout int
+}
+
+// presetsFile is the layout of a YAML or JSON presets file
+type presetsFile struct {
+	Presets []models.EncodingPreset `json:"presets"`
+}
+
+// NewRegistry creates a registry containing the built-in presets
+func NewRegistry() *Registry {
+	r := &Registry{
+		presets: make(map[string]models.EncodingPreset),
+	}
+	for _, preset := range builtinPresets() {
+		r.presets[preset.Name] = preset
+	}
+	return r
+}
+
+// Load creates a registry with the built-in presets plus the presets defined in path.
+// Presets from the file replace built-ins with the same name. An empty path loads only the built-ins.
+func Load(path string) (*Registry, error) {
+	r := NewRegistry()
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presets file: %w", err)
+	}
+
+	// YAML is decoded generically and re-encoded as JSON so presets only need json tags
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		var raw any
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse presets file: %w", err)
+		}
+		if data, err = sonic.Marshal(raw); err != nil {
+			return nil, fmt.Errorf("failed to convert presets file: %w", err)
+		}
+	}
+
+	var file presetsFile
+	if err := sonic.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse presets file: %w", err)
+	}
+
+	for _, preset := range file.Presets {
+		if preset.Name == "" {
+			return nil, fmt.Errorf("preset without a name in %s", path)
+		}
+		preset.PresetName = ""
+		if err := preset.Validate(); err != nil {
+			return nil, fmt.Errorf("preset %s: %w", preset.Name, err)
+		}
+		r.presets[preset.Name] = preset
+	}
+
+	return r, nil
+}
+
+// Get returns the preset with the given name
+func (r *Registry) Get(name string) (models.EncodingPreset, bool) {
+	preset, exists := r.presets[name]
+	return preset, exists
+}
+
+// List returns all presets sorted by name
+func (r *Registry) List() []models.EncodingPreset {
+	presets := make([]models.EncodingPreset, 0, len(r.presets))
+	for _, preset := range r.presets {
+		presets = append(presets, preset)
+	}
+	slices.SortFunc(presets, func(a, b models.EncodingPreset) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return presets
+}
+
+// Resolve fills unset options from the selected preset, if any, and validates the result
+func (r *Registry) Resolve(opts *models.OutputOptions) error {
+	if opts.PresetName != "" {
+		preset, exists := r.Get(opts.PresetName)
+		if !exists {
+			return fmt.Errorf("unknown preset_name: %s", opts.PresetName)
+		}
+		*opts = opts.WithDefaults(preset.OutputOptions)
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	if r.maxTimeout > 0 && opts.TimeoutSeconds > r.maxTimeout {
+		return fmt.Errorf("timeout_seconds must be between 1 and %d", r.maxTimeout)
+	}
+	return nil
+}
+
+// SetMaxTimeout sets the largest timeout_seconds that Resolve accepts; 0 accepts any
+func (r *Registry) SetMaxTimeout(seconds int) {
+	r.maxTimeout = seconds
+}
+
+// builtinPresets returns the presets available without a presets file
+func builtinPresets() []models.EncodingPreset {
+	crf := func(v int) *int { return &v }
+
+	return []models.EncodingPreset{
+		{
+			Name:        "web_standard",
+			Description: "H.264 MP4 for broad web and mobile playback",
+			OutputOptions: models.OutputOptions{
+				OutputFormat: models.FormatMP4,
+				CRF:          crf(23),
+				Preset:       "fast",
+				Profile:      "main",
+				Level:        "4.0",
+				PixFmt:       "yuv420p",
+			},
+		},
+		{
+			Name:        "archive_hq",
+			Description: "High quality H.264 MKV for archiving",
+			OutputOptions: models.OutputOptions{
+				OutputFormat: models.FormatMKV,
+				CRF:          crf(16),
+				Preset:       "slow",
+				Profile:      "high",
+				PixFmt:       "yuv420p",
+			},
+		},
+		{
+			Name:        "webm_web",
+			Description: "VP9/Opus WebM for web embedding",
+			OutputOptions: models.OutputOptions{
+				OutputFormat: models.FormatWebM,
+				CRF:          crf(32),
+			},
+		},
+		{
+			Name:        "web_720p",
+			Description: "H.264 MP4 letterboxed to 1280x720",
+			OutputOptions: models.OutputOptions{
+				OutputFormat: models.FormatMP4,
+				CRF:          crf(23),
+				Preset:       "fast",
+				Profile:      "main",
+				PixFmt:       "yuv420p",
+				Width:        1280,
+				Height:       720,
+				Fit:          models.FitContain,
+			},
+		},
+		{
+			Name:        "instagram_reel",
+			Description: "Vertical 1080x1920 H.264 MP4 with a blurred background fill",
+			OutputOptions: models.OutputOptions{
+				OutputFormat: models.FormatMP4,
+				CRF:          crf(21),
+				Preset:       "medium",
+				Profile:      "high",
+				Level:        "4.1",
+				PixFmt:       "yuv420p",
+				Width:        1080,
+				Height:       1920,
+				Fit:          models.FitContain,
+				Background:   models.BackgroundBlur,
+			},
+		},
+		{
+			Name:        "draft",
+			Description: "Fast, small H.264 MP4 for previews",
+			OutputOptions: models.OutputOptions{
+				OutputFormat: models.FormatMP4,
+				CRF:          crf(30),
+				Preset:       "veryfast",
+				PixFmt:       "yuv420p",
+			},
+		},
+	}
+}
@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"govid/pkg/logger"
+)
+
+// checkInterval is how often the scheduler looks for work that is due
+const checkInterval = time.Second
+
+// Scheduler runs work when it is due: jobs queued with a run_at, which it promotes to the processing queue,
+// and the recurring cron schedules of job templates
+type Scheduler struct {
+	mu        sync.Mutex
+	once      []onceEntry
+	recurring map[string]*recurringEntry
+	isLeader  func() bool // nil when this instance runs the recurring schedules on its own
+	ticker    *time.Ticker
+	stopChan  chan struct{}
+}
+
+// onceEntry is work to run once at a given time
+type onceEntry struct {
+	at  time.Time
+	run func()
+}
+
+// recurringEntry is work to run at each time of a cron schedule
+type recurringEntry struct {
+	spec *Spec
+	next time.Time
+	run  func()
+}
+
+// New creates a scheduler; Start begins running the work added to it
+func New() *Scheduler {
+	return &Scheduler{
+		recurring: make(map[string]*recurringEntry),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// FollowLeader makes the recurring schedules run only while isLeader returns true, so that of the instances
+// sharing the job store, only the leader runs them. Jobs queued with a run_at still run in the instance that
+// queued them.
+func (s *Scheduler) FollowLeader(isLeader func() bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.isLeader = isLeader
+}
+
+// Start begins checking for due work
+func (s *Scheduler) Start() {
+	s.ticker = time.NewTicker(checkInterval)
+	go func() {
+		for {
+			select {
+			case now := <-s.ticker.C:
+				s.runDue(now)
+			case <-s.stopChan:
+				s.ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler. Work that is not due yet is not run.
+func (s *Scheduler) Stop() {
+	logger.Info("Stopping job scheduler")
+	close(s.stopChan)
+}
+
+// At runs run at the given time, or at the next check if it has passed
+func (s *Scheduler) At(at time.Time, run func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.once = append(s.once, onceEntry{at: at, run: run})
+}
+
+// Every runs run at each time matching spec, in UTC, replacing the recurring schedule with the same name
+func (s *Scheduler) Every(name string, spec *Spec, run func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recurring[name] = &recurringEntry{spec: spec, next: spec.Next(time.Now().UTC()), run: run}
+}
+
+// Remove removes the recurring schedule with the given name, if there is one
+func (s *Scheduler) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.recurring, name)
+}
+
+// NextRun returns when the recurring schedule with the given name runs next
+func (s *Scheduler) NextRun(name string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, exists := s.recurring[name]
+	if !exists || entry.next.IsZero() {
+		return time.Time{}, false
+	}
+	return entry.next, true
+}
+
+// runDue runs the work that is due at now. A recurring schedule that missed several times, such as while
+// the server was busy, runs once.
+func (s *Scheduler) runDue(now time.Time) {
+	var due []func()
+
+	s.mu.Lock()
+	pending := s.once[:0]
+	for _, entry := range s.once {
+		if entry.at.After(now) {
+			pending = append(pending, entry)
+		} else {
+			due = append(due, entry.run)
+		}
+	}
+	clear(s.once[len(pending):])
+	s.once = pending
+	leads := s.isLeader == nil || s.isLeader()
+	for _, entry := range s.recurring {
+		if !entry.next.IsZero() && !entry.next.After(now) {
+			if leads {
+				due = append(due, entry.run)
+			}
+			entry.next = entry.spec.Next(now.UTC())
+		}
+	}
+	s.mu.Unlock()
+
+	for _, run := range due {
+		run()
+	}
+}
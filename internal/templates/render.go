@@ -0,0 +1,108 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"govid/internal/models"
+
+	"github.com/bytedance/sonic"
+)
+
+// placeholderPattern matches a {{name}} placeholder, with optional spaces inside the braces
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// Placeholders returns the names of the placeholders in the pipeline of a template, sorted
+func Placeholders(template models.JobTemplate) []string {
+	var names []string
+	walk(template.Pipeline, func(s string) any {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(s, -1) {
+			if !slices.Contains(names, match[1]) {
+				names = append(names, match[1])
+			}
+		}
+		return s
+	})
+	slices.Sort(names)
+	return names
+}
+
+// Render substitutes the variables, and the defaults of the parameters left out, for the placeholders of a
+// template and decodes the result as a pipeline request. A string that is a single placeholder takes the
+// value as is, so numbers, lists and objects can be passed; within a longer string, values must be strings,
+// numbers or booleans. Variables that are not placeholders of the template are refused.
+func Render(template models.JobTemplate, variables map[string]any) (models.PipelineRequest, error) {
+	var req models.PipelineRequest
+	placeholders := Placeholders(template)
+	for name := range variables {
+		if !slices.Contains(placeholders, name) {
+			return req, fmt.Errorf("unknown parameter %s", name)
+		}
+	}
+
+	values := make(map[string]any, len(placeholders))
+	for _, name := range placeholders {
+		value, ok := variables[name]
+		if !ok {
+			value = template.Parameters[name].Default
+		}
+		if value == nil {
+			return req, fmt.Errorf("parameter %s is required", name)
+		}
+		values[name] = value
+	}
+
+	var renderErr error
+	pipeline := walk(template.Pipeline, func(s string) any {
+		if match := placeholderPattern.FindStringSubmatch(s); match != nil && match[0] == s {
+			return values[match[1]]
+		}
+		return placeholderPattern.ReplaceAllStringFunc(s, func(placeholder string) string {
+			name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+			switch value := values[name].(type) {
+			case string:
+				return value
+			case float64, int, int64, bool:
+				return fmt.Sprint(value)
+			default:
+				renderErr = fmt.Errorf("parameter %s must be a string, number or boolean to be used within text", name)
+				return placeholder
+			}
+		})
+	})
+	if renderErr != nil {
+		return req, renderErr
+	}
+
+	data, err := sonic.Marshal(pipeline)
+	if err != nil {
+		return req, fmt.Errorf("failed to encode pipeline: %w", err)
+	}
+	if err := sonic.Unmarshal(data, &req); err != nil {
+		return req, fmt.Errorf("template does not give a valid pipeline request: %w", err)
+	}
+	return req, nil
+}
+
+// walk returns a copy of a decoded JSON value with each string replaced by the result of fn
+func walk(value any, fn func(string) any) any {
+	switch v := value.(type) {
+	case string:
+		return fn(v)
+	case map[string]any:
+		copied := make(map[string]any, len(v))
+		for key, item := range v {
+			copied[key] = walk(item, fn)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(v))
+		for i, item := range v {
+			copied[i] = walk(item, fn)
+		}
+		return copied
+	default:
+		return v
+	}
+}
@@ -0,0 +1,318 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"govid/internal/models"
+	"govid/internal/scheduler"
+	"govid/pkg/logger"
+
+	"github.com/bytedance/sonic"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// ErrNotFound is returned when no template has the requested name
+	ErrNotFound = errors.New("template not found")
+	// ErrStorage is returned when a template change cannot be written to the templates directory
+	ErrStorage = errors.New("failed to store template")
+)
+
+// namePattern restricts template names to characters that are safe in URLs and file names
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Store holds the job templates. Templates are files in a directory, one per template, named after it: files
+// placed there are loaded at startup, and templates saved through the admin API are written there as JSON.
+// Instances sharing the directory share the templates: Get and List load the files that were added, changed or
+// removed since the last call.
+type Store struct {
+	dir       string
+	templates map[string]models.JobTemplate
+	files     map[string]string    // file each template was loaded from or saved to
+	modTimes  map[string]time.Time // by file, when it was last read or written here
+	onChange  func(name string)
+	mu        sync.RWMutex
+}
+
+// Load creates a store with the templates in dir, a directory of .json, .yaml or .yml files. A file's
+// template is named after the file unless it sets a name. It fails if a template is invalid.
+func Load(dir string) (*Store, error) {
+	s := &Store{
+		dir:       dir,
+		templates: make(map[string]models.JobTemplate),
+		files:     make(map[string]string),
+		modTimes:  make(map[string]time.Time),
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !isTemplateFile(entry) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		template, modTime, err := readTemplate(path)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", entry.Name(), err)
+		}
+		if other, exists := s.files[template.Name]; exists {
+			return nil, fmt.Errorf("template %s is defined in both %s and %s", template.Name, filepath.Base(other), entry.Name())
+		}
+		s.templates[template.Name] = template
+		s.files[template.Name] = path
+		s.modTimes[path] = modTime
+	}
+	return s, nil
+}
+
+// OnChange sets a function called with the name of each template that another instance added, changed or
+// removed, once the store loaded the change
+func (s *Store) OnChange(fn func(name string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = fn
+}
+
+// Refresh loads the changes that other instances made to the templates directory
+func (s *Store) Refresh() {
+	s.mu.Lock()
+	changed := s.refresh()
+	onChange := s.onChange
+	s.mu.Unlock()
+
+	if onChange != nil {
+		for _, name := range changed {
+			onChange(name)
+		}
+	}
+}
+
+// refresh loads the template files added or changed since they were last read or written here, and forgets
+// the templates whose files were removed. An invalid file is skipped, and keeps the template it held before.
+// It returns the names of the templates that changed. Callers must hold mu.
+func (s *Store) refresh() []string {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		logger.Error("Failed to read templates directory: %v", err)
+		return nil
+	}
+
+	var changed []string
+	present := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if isTemplateFile(entry) {
+			present[filepath.Join(s.dir, entry.Name())] = true
+		}
+	}
+	for name, path := range s.files {
+		if !present[path] {
+			delete(s.templates, name)
+			delete(s.files, name)
+			delete(s.modTimes, path)
+			changed = append(changed, name)
+		}
+	}
+
+	for _, entry := range entries {
+		if !isTemplateFile(entry) {
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if seen, ok := s.modTimes[path]; ok && seen.Equal(info.ModTime()) {
+			continue
+		}
+		// Not retried until the file changes again
+		s.modTimes[path] = info.ModTime()
+
+		template, _, err := readTemplate(path)
+		if err != nil {
+			logger.Warn("Skipping job template %s: %v", entry.Name(), err)
+			continue
+		}
+		if other, exists := s.files[template.Name]; exists && other != path {
+			logger.Warn("Skipping job template %s: %s is defined in %s too", entry.Name(), template.Name, filepath.Base(other))
+			continue
+		}
+		// The file may have held a template of another name
+		for name, file := range s.files {
+			if file == path && name != template.Name {
+				delete(s.templates, name)
+				delete(s.files, name)
+				changed = append(changed, name)
+			}
+		}
+		s.templates[template.Name] = template
+		s.files[template.Name] = path
+		changed = append(changed, template.Name)
+	}
+	return changed
+}
+
+// isTemplateFile reports whether a directory entry is a .json, .yaml or .yml file
+func isTemplateFile(entry os.DirEntry) bool {
+	ext := strings.ToLower(filepath.Ext(entry.Name()))
+	return !entry.IsDir() && (ext == ".json" || ext == ".yaml" || ext == ".yml")
+}
+
+// readTemplate reads and validates a template file, naming the template after the file unless it sets a
+// name, and returns when the file was written
+func readTemplate(path string) (models.JobTemplate, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return models.JobTemplate{}, time.Time{}, err
+	}
+	template, err := readFile(path)
+	if err != nil {
+		return template, time.Time{}, err
+	}
+	if template.Name == "" {
+		template.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := Validate(template); err != nil {
+		return template, time.Time{}, err
+	}
+	return template, info.ModTime(), nil
+}
+
+// readFile reads a template file. YAML is decoded generically and re-encoded as JSON, as for presets.
+func readFile(path string) (models.JobTemplate, error) {
+	var template models.JobTemplate
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return template, err
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		var raw any
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return template, fmt.Errorf("failed to parse: %w", err)
+		}
+		if data, err = sonic.Marshal(raw); err != nil {
+			return template, fmt.Errorf("failed to convert: %w", err)
+		}
+	}
+	if err := sonic.Unmarshal(data, &template); err != nil {
+		return template, fmt.Errorf("failed to parse: %w", err)
+	}
+	return template, nil
+}
+
+// Get returns the template with the given name
+func (s *Store) Get(name string) (models.JobTemplate, bool) {
+	s.Refresh()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	template, exists := s.templates[name]
+	return template, exists
+}
+
+// List returns all templates sorted by name
+func (s *Store) List() []models.JobTemplate {
+	s.Refresh()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	templates := make([]models.JobTemplate, 0, len(s.templates))
+	for _, template := range s.templates {
+		templates = append(templates, template)
+	}
+	slices.SortFunc(templates, func(a, b models.JobTemplate) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return templates
+}
+
+// Put creates or replaces a template, writing it to <name>.json in the templates directory. A file the
+// template was loaded from under another name, such as a YAML file, is replaced by the JSON file.
+func (s *Store) Put(template models.JobTemplate) error {
+	if err := Validate(template); err != nil {
+		return err
+	}
+
+	s.Refresh()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, err := sonic.MarshalIndent(template, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	path := filepath.Join(s.dir, template.Name+".json")
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, content, 0o644); err != nil {
+		return fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	if old, exists := s.files[template.Name]; exists && old != path {
+		os.Remove(old)
+		delete(s.modTimes, old)
+	}
+	if info, err := os.Stat(path); err == nil {
+		s.modTimes[path] = info.ModTime()
+	}
+
+	s.templates[template.Name] = template
+	s.files[template.Name] = path
+	return nil
+}
+
+// Delete removes a template and its file
+func (s *Store) Delete(name string) error {
+	s.Refresh()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, exists := s.files[name]
+	if !exists {
+		return ErrNotFound
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	delete(s.templates, name)
+	delete(s.files, name)
+	delete(s.modTimes, path)
+	return nil
+}
+
+// Validate checks the name of a template, that it has pipeline steps, and that each of its parameters is a
+// placeholder of the pipeline. A scheduled template runs without variables, so its schedule must be a valid
+// cron expression and each of its placeholders needs a default.
+func Validate(template models.JobTemplate) error {
+	if !namePattern.MatchString(template.Name) {
+		return fmt.Errorf("name must be 1-64 letters, digits, '.', '_', or '-'")
+	}
+	if _, ok := template.Pipeline["steps"].([]any); !ok {
+		return fmt.Errorf("pipeline must have a list of steps")
+	}
+	placeholders := Placeholders(template)
+	for name := range template.Parameters {
+		if !slices.Contains(placeholders, name) {
+			return fmt.Errorf("parameter %s is not used by the pipeline", name)
+		}
+	}
+	if template.Schedule != "" {
+		if _, err := scheduler.ParseCron(template.Schedule); err != nil {
+			return fmt.Errorf("schedule: %w", err)
+		}
+		if _, err := Render(template, nil); err != nil {
+			return fmt.Errorf("a scheduled template runs with the defaults of its parameters: %w", err)
+		}
+	}
+	return nil
+}
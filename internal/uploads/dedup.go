@@ -0,0 +1,45 @@
+package uploads
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// HashName returns the content-addressed file name of an upload: its SHA-256 in hex and the lower-cased
+// extension of its original name
+func HashName(sum, filename string) string {
+	return sum + strings.ToLower(filepath.Ext(filename))
+}
+
+// Existing returns the path of an earlier upload with the given SHA-256 and extension in dir, if there is one.
+// Its modification time is refreshed, so the retention cleanup counts from the latest upload.
+func Existing(dir, sum, filename string) (string, bool) {
+	path := filepath.Join(dir, HashName(sum, filename))
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return path, true
+}
+
+// Dedup gives a newly saved upload its content-addressed name in the same directory. If an identical file is
+// already stored there, the new copy is removed and the path of the existing one is returned instead.
+func Dedup(path, sum, filename string) (string, bool, error) {
+	dir := filepath.Dir(path)
+	if existing, ok := Existing(dir, sum, filename); ok {
+		if existing != path {
+			os.Remove(path)
+		}
+		return existing, true, nil
+	}
+
+	target := filepath.Join(dir, HashName(sum, filename))
+	if err := os.Rename(path, target); err != nil {
+		return "", false, err
+	}
+	return target, false, nil
+}
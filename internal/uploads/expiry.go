@@ -0,0 +1,149 @@
+package uploads
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrExpired is returned for uploads past their expiry time
+var ErrExpired = errors.New("upload expired")
+
+// expiryDir is the hidden subdirectory of the upload directory that holds the expiry time of each upload
+// with a TTL, in a file named like the upload
+const expiryDir = ".expires"
+
+// expiryMu serializes expiry updates, so concurrent uploads of the same file keep the later expiry
+var expiryMu sync.Mutex
+
+// ApplyTTL gives the upload at path in dir an expiry ttl from now and returns the expiry, or nil if the upload
+// has none. Uploads are shared after deduplication, so an upload that already has a later expiry keeps it, and
+// an existing upload without a TTL stays until the retention cleanup. A ttl of 0 sets no expiry.
+func ApplyTTL(dir, path string, ttl time.Duration, existed bool) (*time.Time, error) {
+	name := filepath.Base(path)
+
+	expiryMu.Lock()
+	defer expiryMu.Unlock()
+
+	current, hasExpiry := Expiry(dir, name)
+	if hasExpiry && !time.Now().Before(current) {
+		// Uploading an expired file again starts it over
+		os.Remove(filepath.Join(dir, expiryDir, name))
+		hasExpiry, existed = false, false
+	}
+	if ttl > 0 && (!existed || hasExpiry) {
+		expires := time.Now().Add(ttl).Truncate(time.Second)
+		if !hasExpiry || expires.After(current) {
+			if err := writeExpiry(dir, name, expires); err != nil {
+				return nil, err
+			}
+			current, hasExpiry = expires, true
+		}
+	}
+	if !hasExpiry {
+		return nil, nil
+	}
+	return &current, nil
+}
+
+// Expiry returns when the upload named name in dir expires, if it has a TTL
+func Expiry(dir, name string) (time.Time, bool) {
+	if !validName(name) {
+		return time.Time{}, false
+	}
+	content, err := os.ReadFile(filepath.Join(dir, expiryDir, name))
+	if err != nil {
+		return time.Time{}, false
+	}
+	expires, err := time.Parse(time.RFC3339, string(content))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return expires, true
+}
+
+// CheckExpiry fails with ErrExpired if path is an upload in dir whose expiry has passed. Paths outside dir pass.
+func CheckExpiry(dir, path string) error {
+	if !InDir(dir, path) {
+		return nil
+	}
+	name := filepath.Base(path)
+	if expires, ok := Expiry(dir, name); ok && time.Now().After(expires) {
+		return fmt.Errorf("%w: the TTL of %s ended at %s", ErrExpired, name, expires.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// IsExpiryDir reports whether path is the directory of expiry times in dir, which the retention cleanup must leave alone
+func IsExpiryDir(dir, path string) bool {
+	return filepath.Clean(path) == filepath.Join(dir, expiryDir)
+}
+
+// RemoveExpired deletes the uploads in dir whose expiry is before now, along with expiry times of uploads that are
+// already gone, and returns the number of uploads deleted. Uploads for which keep returns true are left until a
+// later call; keep may be nil.
+func RemoveExpired(dir string, now time.Time, keep func(path string) bool) (int, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, expiryDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	expiryMu.Lock()
+	defer expiryMu.Unlock()
+
+	removed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		upload, marker := filepath.Join(dir, name), filepath.Join(dir, expiryDir, name)
+		expires, ok := Expiry(dir, name)
+		switch {
+		case !ok:
+			// Unreadable or left over from an interrupted write; the upload falls back to the retention cleanup
+		case now.Before(expires):
+			if _, err := os.Stat(upload); !os.IsNotExist(err) {
+				continue
+			}
+		case keep != nil && keep(upload):
+			continue
+		default:
+			if err := os.Remove(upload); err == nil {
+				removeScan(dir, name)
+				removed++
+			} else if !os.IsNotExist(err) {
+				continue // keep the expiry until the upload is gone
+			}
+		}
+		os.Remove(marker)
+	}
+	return removed, nil
+}
+
+// removeExpiry deletes the expiry time of an upload
+func removeExpiry(dir, name string) {
+	expiryMu.Lock()
+	defer expiryMu.Unlock()
+	os.Remove(filepath.Join(dir, expiryDir, name))
+}
+
+// writeExpiry records the expiry time of an upload
+func writeExpiry(dir, name string, expires time.Time) error {
+	if err := os.MkdirAll(filepath.Join(dir, expiryDir), 0o755); err != nil {
+		return fmt.Errorf("failed to save upload expiry: %w", err)
+	}
+	target := filepath.Join(dir, expiryDir, name)
+	temp := target + ".tmp"
+	if err := os.WriteFile(temp, []byte(expires.UTC().Format(time.RFC3339)), 0o644); err != nil {
+		return fmt.Errorf("failed to save upload expiry: %w", err)
+	}
+	if err := os.Rename(temp, target); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("failed to save upload expiry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package uploads
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// File is a file in the upload directory, identified by its name
+type File struct {
+	Name    string
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// List returns the files in dir, newest first. Subdirectories and hidden files are skipped.
+func List(dir string) ([]File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]File, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // removed while listing
+		}
+		files = append(files, fileOf(dir, info))
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+	return files, nil
+}
+
+// Stat returns the file named name in dir
+func Stat(dir, name string) (File, error) {
+	if !validName(name) {
+		return File{}, ErrNotFound
+	}
+	info, err := os.Stat(filepath.Join(dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return File{}, ErrNotFound
+		}
+		return File{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return File{}, ErrNotFound
+	}
+	return fileOf(dir, info), nil
+}
+
+// Delete removes the file named name from dir
+func Delete(dir, name string) error {
+	file, err := Stat(dir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file.Path); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	removeExpiry(dir, name)
+	removeScan(dir, name)
+	return nil
+}
+
+// InDir reports whether path names a file directly in dir, such as an upload referenced by a job
+func InDir(dir, path string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	return err == nil && filepath.Dir(absPath) == absDir
+}
+
+// fileOf describes the file in dir with the given info
+func fileOf(dir string, info os.FileInfo) File {
+	return File{
+		Name:    info.Name(),
+		Path:    filepath.Join(dir, info.Name()),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+}
+
+// validName reports whether name is a plain, visible file name, so names from requests never leave the directory
+func validName(name string) bool {
+	return name != "" && name == filepath.Base(name) && !strings.HasPrefix(name, ".")
+}
@@ -0,0 +1,196 @@
+package uploads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bytedance/sonic"
+
+	"govid/pkg/scanner"
+)
+
+var (
+	// ErrInfected is returned for uploads that failed the content scan and were quarantined
+	ErrInfected = errors.New("upload failed the content scan")
+	// ErrScanFailed is returned when an upload could not be scanned
+	ErrScanFailed = errors.New("upload could not be scanned")
+	// ErrNotScanned is returned for job inputs in the upload directory that have no clean scan
+	ErrNotScanned = errors.New("upload has not been scanned")
+)
+
+// Scan statuses
+const (
+	ScanClean     = "clean"
+	ScanInfected  = "infected"
+	ScanUnscanned = "unscanned" // not scanned yet, or scanned before scanning was enabled
+)
+
+// scanDir is the hidden subdirectory of a directory of uploads that holds the scan record of each upload, in
+// a file named like the upload
+const scanDir = ".scans"
+
+// ScanRecord is the result of scanning an upload
+type ScanRecord struct {
+	Status    string    `json:"status"`
+	Threat    string    `json:"threat,omitempty"`
+	Scanner   string    `json:"scanner"`
+	ScannedAt time.Time `json:"scanned_at"`
+}
+
+// Scan scans the upload at path with s and records the result next to it. An upload that existed before and
+// was already scanned clean is not scanned again. An infected upload is moved to quarantineDir, with its record
+// as <name>.json, and ErrInfected is returned. If the scan fails, a new upload is removed, since uploads are
+// never used unscanned, and ErrScanFailed is returned.
+func Scan(ctx context.Context, s scanner.Scanner, path, quarantineDir string, existed bool) (*ScanRecord, error) {
+	dir, name := filepath.Split(path)
+	if existed {
+		if record, ok := ScanStatus(dir, name); ok && record.Status == ScanClean {
+			return &record, nil
+		}
+	}
+
+	result, err := s.Scan(ctx, path)
+	if err != nil {
+		if !existed {
+			os.Remove(path)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+
+	record := ScanRecord{Status: ScanClean, Scanner: s.Name(), ScannedAt: time.Now().UTC().Truncate(time.Second)}
+	if !result.Clean {
+		record.Status, record.Threat = ScanInfected, result.Threat
+		if err := quarantine(path, quarantineDir, record); err != nil {
+			os.Remove(path)
+			return &record, fmt.Errorf("%w: %s contains %s and could not be quarantined, so it was deleted: %v", ErrInfected, name, record.Threat, err)
+		}
+		removeExpiry(dir, name)
+		writeScan(dir, name, record) // kept so jobs naming the file learn why it is gone
+		return &record, fmt.Errorf("%w: %s contains %s and was quarantined", ErrInfected, name, record.Threat)
+	}
+
+	if err := writeScan(dir, name, record); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+	return &record, nil
+}
+
+// ScanStatus returns the scan record of the upload named name in dir, if it was scanned
+func ScanStatus(dir, name string) (ScanRecord, bool) {
+	if !validName(name) {
+		return ScanRecord{}, false
+	}
+	content, err := os.ReadFile(filepath.Join(dir, scanDir, name))
+	if err != nil {
+		return ScanRecord{}, false
+	}
+	var record ScanRecord
+	if err := sonic.Unmarshal(content, &record); err != nil {
+		return ScanRecord{}, false
+	}
+	return record, true
+}
+
+// CheckScanned fails if path is an upload in dir that was not scanned clean: with ErrInfected if it was
+// quarantined, and with ErrNotScanned otherwise. Paths outside dir pass.
+func CheckScanned(dir, path string) error {
+	if !InDir(dir, path) {
+		return nil
+	}
+	name := filepath.Base(path)
+	record, ok := ScanStatus(dir, name)
+	switch {
+	case ok && record.Status == ScanClean:
+		return nil
+	case ok && record.Status == ScanInfected:
+		if _, err := os.Stat(filepath.Join(dir, name)); os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s contained %s and was quarantined", ErrInfected, name, record.Threat)
+		}
+	}
+	return fmt.Errorf("%w: %s has no clean scan; upload it again", ErrNotScanned, name)
+}
+
+// IsScanRecord reports whether path is the scan record of an upload that still exists in dir, which the
+// retention cleanup must leave alone. Records of removed and quarantined uploads are cleaned up like uploads.
+func IsScanRecord(dir, path string) bool {
+	path = filepath.Clean(path)
+	if filepath.Dir(path) != filepath.Join(dir, scanDir) {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, filepath.Base(path)))
+	return err == nil
+}
+
+// removeScan deletes the scan record of an upload
+func removeScan(dir, name string) {
+	os.Remove(filepath.Join(dir, scanDir, name))
+}
+
+// writeScan records the scan result of an upload
+func writeScan(dir, name string, record ScanRecord) error {
+	content, err := sonic.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, scanDir), 0o755); err != nil {
+		return fmt.Errorf("failed to save scan record: %w", err)
+	}
+	target := filepath.Join(dir, scanDir, name)
+	temp := target + ".tmp"
+	if err := os.WriteFile(temp, content, 0o644); err != nil {
+		return fmt.Errorf("failed to save scan record: %w", err)
+	}
+	if err := os.Rename(temp, target); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("failed to save scan record: %w", err)
+	}
+	return nil
+}
+
+// quarantine moves an infected upload to dir under a timestamped name and writes its scan record next to it
+func quarantine(path, dir string, record ScanRecord) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	target := filepath.Join(dir, record.ScannedAt.Format("20060102T150405Z")+"-"+filepath.Base(path))
+	if err := os.Rename(path, target); err != nil {
+		// The quarantine may be on another filesystem
+		if err := moveFile(path, target); err != nil {
+			return err
+		}
+	}
+	content, err := sonic.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(target+".json", content, 0o644)
+}
+
+// moveFile copies src to dst and removes src
+func moveFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,273 @@
+package uploads
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+
+	"govid/pkg/downloader"
+)
+
+var (
+	// ErrNotFound is returned for unknown or finished upload sessions
+	ErrNotFound = errors.New("upload not found")
+	// ErrBadChunk is returned for chunk indexes out of range and chunks of the wrong size
+	ErrBadChunk = errors.New("invalid chunk")
+	// ErrIncomplete is returned when completing an upload with missing chunks
+	ErrIncomplete = errors.New("upload incomplete")
+	// ErrBusy is returned when an upload is already being completed
+	ErrBusy = errors.New("upload is being completed")
+)
+
+// sessionIDPattern matches the IDs handed out by Create, so IDs from requests never escape the directory
+var sessionIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// Session is a chunked upload in progress. A file of FileSize bytes is sent as TotalChunks chunks numbered
+// from 0; every chunk but the last has ChunkSize bytes.
+type Session struct {
+	ID          string    `json:"id"`
+	FileName    string    `json:"file_name"`
+	FileSize    int64     `json:"file_size"`
+	ChunkSize   int64     `json:"chunk_size"`
+	TotalChunks int       `json:"total_chunks"`
+	Checksum    string    `json:"checksum,omitempty"`    // expected checksum of the assembled file, as sha256:<hex> or md5:<hex>
+	TTLSeconds  int       `json:"ttl_seconds,omitempty"` // requested TTL of the assembled file
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// chunkSize returns the size chunk n must have
+func (s *Session) chunkSize(n int) int64 {
+	if n == s.TotalChunks-1 {
+		return s.FileSize - int64(n)*s.ChunkSize
+	}
+	return s.ChunkSize
+}
+
+// Store keeps chunked upload sessions on disk, one directory per session holding session.json and the
+// chunks received so far, so uploads can resume after a restart
+type Store struct {
+	dir        string
+	mu         sync.Mutex
+	completing map[string]bool
+}
+
+// NewStore creates a store that keeps sessions in dir
+func NewStore(dir string) *Store {
+	return &Store{
+		dir:        dir,
+		completing: make(map[string]bool),
+	}
+}
+
+// Create starts a session for a file of fileSize bytes sent in chunks of chunkSize bytes
+func (s *Store) Create(fileName string, fileSize, chunkSize int64, checksum string, ttlSeconds int) (*Session, error) {
+	session := &Session{
+		ID:          uuid.New().String(),
+		FileName:    fileName,
+		FileSize:    fileSize,
+		ChunkSize:   chunkSize,
+		TotalChunks: int((fileSize + chunkSize - 1) / chunkSize),
+		Checksum:    checksum,
+		TTLSeconds:  ttlSeconds,
+		CreatedAt:   time.Now(),
+	}
+
+	if err := os.MkdirAll(s.sessionDir(session.ID), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	content, err := sonic.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(s.sessionDir(session.ID), "session.json"), content, 0o644); err != nil {
+		os.RemoveAll(s.sessionDir(session.ID))
+		return nil, fmt.Errorf("failed to save upload session: %w", err)
+	}
+	return session, nil
+}
+
+// Get loads a session
+func (s *Store) Get(id string) (*Session, error) {
+	if !sessionIDPattern.MatchString(id) {
+		return nil, ErrNotFound
+	}
+	content, err := os.ReadFile(filepath.Join(s.sessionDir(id), "session.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var session Session
+	if err := sonic.Unmarshal(content, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse upload session: %w", err)
+	}
+	return &session, nil
+}
+
+// Received returns the numbers of the chunks received so far, in order
+func (s *Store) Received(session *Session) ([]int, error) {
+	entries, err := os.ReadDir(s.sessionDir(session.ID))
+	if err != nil {
+		return nil, err
+	}
+	received := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".chunk")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(name); err == nil && n >= 0 && n < session.TotalChunks {
+			received = append(received, n)
+		}
+	}
+	sort.Ints(received)
+	return received, nil
+}
+
+// WriteChunk stores chunk n from r. A chunk sent again replaces the earlier copy, so failed chunks can be retried.
+func (s *Store) WriteChunk(session *Session, n int, r io.Reader) error {
+	if n < 0 || n >= session.TotalChunks {
+		return fmt.Errorf("%w: chunk %d out of range 0-%d", ErrBadChunk, n, session.TotalChunks-1)
+	}
+	size := session.chunkSize(n)
+
+	chunkPath := s.chunkPath(session.ID, n)
+	tempPath := fmt.Sprintf("%s.%s.tmp", chunkPath, uuid.New().String())
+	file, err := os.Create(tempPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to save chunk: %w", err)
+	}
+	written, err := io.Copy(file, io.LimitReader(r, size+1))
+	file.Close()
+	switch {
+	case err != nil:
+		err = fmt.Errorf("failed to save chunk: %w", err)
+	case written != size:
+		err = fmt.Errorf("%w: chunk %d must be %d bytes", ErrBadChunk, n, size)
+	}
+	if err == nil {
+		err = os.Rename(tempPath, chunkPath)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+// Complete assembles the chunks of a session into a new file at path, verifies the checksum of the session
+// (or checksum, if the session has none), removes the session and returns the hex SHA-256 of the file. The
+// file is removed on failure and the session is kept, so missing chunks can still be sent.
+func (s *Store) Complete(session *Session, checksum, path string) (string, error) {
+	s.mu.Lock()
+	if s.completing[session.ID] {
+		s.mu.Unlock()
+		return "", ErrBusy
+	}
+	s.completing[session.ID] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.completing, session.ID)
+		s.mu.Unlock()
+	}()
+
+	if session.Checksum != "" {
+		checksum = session.Checksum
+	}
+	expected, err := downloader.ParseChecksum(checksum)
+	if err != nil {
+		return "", err
+	}
+
+	received, err := s.Received(session)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	if len(received) != session.TotalChunks {
+		return "", fmt.Errorf("%w: received %d of %d chunks", ErrIncomplete, len(received), session.TotalChunks)
+	}
+
+	sum, err := s.assemble(session, expected, path)
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return sum, s.Remove(session.ID)
+}
+
+// assemble concatenates the chunks of a session into path, hashing them on the way, and returns the hex SHA-256
+func (s *Store) assemble(session *Session, expected downloader.Checksum, path string) (string, error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer out.Close()
+
+	sha := sha256.New()
+	w := io.MultiWriter(out, sha)
+	h := expected.NewHash()
+	if h != nil {
+		w = io.MultiWriter(out, sha, h)
+	}
+
+	for n := range session.TotalChunks {
+		chunk, err := os.Open(s.chunkPath(session.ID, n))
+		if err != nil {
+			return "", fmt.Errorf("failed to read chunk %d: %w", n, err)
+		}
+		_, err = io.Copy(w, chunk)
+		chunk.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to assemble file: %w", err)
+		}
+	}
+	if err := out.Close(); err != nil {
+		return "", fmt.Errorf("failed to assemble file: %w", err)
+	}
+
+	if h != nil {
+		if err := expected.Match(h.Sum(nil)); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(sha.Sum(nil)), nil
+}
+
+// Remove deletes a session and its chunks
+func (s *Store) Remove(id string) error {
+	if !sessionIDPattern.MatchString(id) {
+		return ErrNotFound
+	}
+	return os.RemoveAll(s.sessionDir(id))
+}
+
+// sessionDir returns the directory of a session
+func (s *Store) sessionDir(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+// chunkPath returns the file of chunk n of a session
+func (s *Store) chunkPath(id string, n int) string {
+	return filepath.Join(s.sessionDir(id), fmt.Sprintf("%d.chunk", n))
+}
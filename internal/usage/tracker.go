@@ -0,0 +1,160 @@
+package usage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+
+	"govid/pkg/filelock"
+	"govid/pkg/logger"
+)
+
+// Record is the usage of one API key in one month
+type Record struct {
+	Jobs          int     `json:"jobs"`
+	InputSeconds  float64 `json:"input_seconds"`  // duration of the job inputs
+	OutputSeconds float64 `json:"output_seconds"` // duration of the produced outputs; quotas count these
+}
+
+// OutputMinutes returns the processed minutes counted against quotas
+func (r Record) OutputMinutes() float64 {
+	return r.OutputSeconds / 60
+}
+
+// Tracker accumulates processed video durations per API key for the current calendar month (UTC).
+// Each month is persisted to its own JSON file so usage survives restarts. Instances sharing the directory,
+// such as API instances and their workers, share the usage: Add merges into the file under a lock, and Get
+// reloads the file when another instance changed it.
+type Tracker struct {
+	dir     string
+	period  string
+	records map[string]Record
+	modTime time.Time // of the usage file when this instance last read or wrote it
+	mu      sync.Mutex
+}
+
+// NewTracker creates a tracker that stores monthly usage files in dir
+func NewTracker(dir string) *Tracker {
+	t := &Tracker{dir: dir}
+	t.rollover(currentPeriod())
+	return t
+}
+
+// currentPeriod returns the month usage is currently recorded against, e.g. "2025-01"
+func currentPeriod() string {
+	return time.Now().UTC().Format("2006-01")
+}
+
+// Add records a finished job for key
+func (t *Tracker) Add(key string, inputSeconds, outputSeconds float64) {
+	if key == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover(currentPeriod())
+
+	// Add to the usage other instances recorded since it was read
+	unlock, err := filelock.Lock(t.lockPath())
+	if err != nil {
+		logger.Error("Failed to lock usage for %s: %v", t.period, err)
+	} else {
+		defer unlock()
+	}
+	t.load()
+
+	record := t.records[key]
+	record.Jobs++
+	record.InputSeconds += inputSeconds
+	record.OutputSeconds += outputSeconds
+	t.records[key] = record
+
+	if err := t.save(); err != nil {
+		logger.Error("Failed to save usage for %s: %v", t.period, err)
+	}
+}
+
+// Get returns the current period and the usage of key in it
+func (t *Tracker) Get(key string) (string, Record) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover(currentPeriod())
+	t.refresh()
+	return t.period, t.records[key]
+}
+
+// rollover switches to period, loading any usage already recorded for it. Callers must hold mu.
+func (t *Tracker) rollover(period string) {
+	if t.period == period {
+		return
+	}
+	t.period = period
+	t.load()
+}
+
+// refresh reloads the usage file if another instance changed it. Callers must hold mu.
+func (t *Tracker) refresh() {
+	info, err := os.Stat(t.path())
+	if err == nil && !info.ModTime().Equal(t.modTime) {
+		t.load()
+	}
+}
+
+// load reads the usage file of the current period. Callers must hold mu.
+func (t *Tracker) load() {
+	t.records = make(map[string]Record)
+	t.modTime = time.Time{}
+
+	info, err := os.Stat(t.path())
+	if err == nil {
+		t.modTime = info.ModTime()
+	}
+	content, err := os.ReadFile(t.path())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error("Failed to read usage file %s: %v", t.path(), err)
+		}
+		return
+	}
+	if err := sonic.Unmarshal(content, &t.records); err != nil {
+		logger.Error("Failed to parse usage file %s: %v", t.path(), err)
+		t.records = make(map[string]Record)
+	}
+}
+
+// save writes the current period to disk. Callers must hold mu.
+func (t *Tracker) save() error {
+	content, err := sonic.MarshalIndent(t.records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	filePath := t.path()
+	tempPath := filePath + ".tmp"
+	if err := os.WriteFile(tempPath, content, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, filePath); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if info, err := os.Stat(filePath); err == nil {
+		t.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// path returns the usage file of the current period
+func (t *Tracker) path() string {
+	return filepath.Join(t.dir, fmt.Sprintf("%s.json", t.period))
+}
+
+// lockPath returns the lock file serializing the changes to the usage file of the current period
+func (t *Tracker) lockPath() string {
+	return filepath.Join(t.dir, fmt.Sprintf("%s.lock", t.period))
+}
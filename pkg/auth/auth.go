@@ -1,7 +1,18 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -11,36 +22,208 @@ var (
 	ErrMissingAPIKey = errors.New("missing X-API-Key header")
 )
 
-// Validator validates API keys
-type Validator struct {
-	apiKey string
+// Scope controls which endpoints a key may call. Each scope includes the ones below it.
+type Scope string
+
+const (
+	ScopeRead    Scope = "read"    // job status, downloads, and presets
+	ScopeProcess Scope = "process" // uploads and processing jobs
+	ScopeAdmin   Scope = "admin"   // everything
+)
+
+var scopeRank = map[Scope]int{
+	ScopeRead:    1,
+	ScopeProcess: 2,
+	ScopeAdmin:   3,
+}
+
+// Valid reports whether s is a known scope
+func (s Scope) Valid() bool {
+	_, ok := scopeRank[s]
+	return ok
+}
+
+// Allows reports whether a key with scope s may call an endpoint that requires scope required
+func (s Scope) Allows(required Scope) bool {
+	return s.Valid() && scopeRank[s] >= scopeRank[required]
+}
+
+// Key is a named API key with a scope. Keys held by a KeyStore do not keep their key value.
+type Key struct {
+	Name         string  `json:"name"`
+	Key          string  `json:"key,omitempty"`
+	Scope        Scope   `json:"scope"`
+	QuotaMinutes float64 `json:"quota_minutes,omitempty"` // monthly processed-minutes quota; 0 means unlimited
+	Managed      bool    `json:"-"`                       // created at runtime rather than in configuration
+}
+
+// keysFile is the layout of a YAML or JSON API keys file
+type keysFile struct {
+	Keys []Key `json:"keys"`
+}
+
+// KeyStore looks up API keys by the SHA-256 hash of their value
+type KeyStore struct {
+	keys    map[string]Key    // by key hash
+	names   map[string]string // key hash by name
+	path    string            // file persisting managed keys; empty when runtime management is disabled
+	modTime time.Time         // when the managed keys file was last read or written here
+	mu      sync.RWMutex
 }
 
-// NewValidator creates a new API key validator
-func NewValidator(apiKey string) *Validator {
-	return &Validator{
-		apiKey: apiKey,
+// NewKeyStore creates a key store. Names and key values must be unique.
+func NewKeyStore(keys []Key) (*KeyStore, error) {
+	s := &KeyStore{
+		keys:  make(map[string]Key, len(keys)),
+		names: make(map[string]string, len(keys)),
 	}
+
+	for _, key := range keys {
+		if key.Key == "" {
+			return nil, fmt.Errorf("API key %s has no key value", key.Name)
+		}
+		if err := s.check(key.Name, key.Scope, key.QuotaMinutes); err != nil {
+			return nil, err
+		}
+		hash := hashKey(key.Key)
+		if _, exists := s.keys[hash]; exists {
+			return nil, fmt.Errorf("API key %s reuses the key value of another key", key.Name)
+		}
+		s.add(hash, key)
+	}
+
+	return s, nil
 }
 
-// ValidateAPIKey validates an API key from X-API-Key header
-func (v *Validator) ValidateAPIKey(apiKey string) error {
+// check validates the settings of a new key. Callers must hold mu when the store is in use.
+func (s *KeyStore) check(name string, scope Scope, quotaMinutes float64) error {
+	if name == "" {
+		return fmt.Errorf("API key without a name")
+	}
+	if !scope.Valid() {
+		return fmt.Errorf("API key %s has invalid scope %q: must be read, process, or admin", name, scope)
+	}
+	if quotaMinutes < 0 {
+		return fmt.Errorf("API key %s has a negative quota", name)
+	}
+	if _, exists := s.names[name]; exists {
+		return fmt.Errorf("%w: %s", ErrKeyExists, name)
+	}
+	return nil
+}
+
+// add stores key under hash, dropping its key value. Callers must hold mu when the store is in use.
+func (s *KeyStore) add(hash string, key Key) {
+	key.Key = ""
+	s.keys[hash] = key
+	s.names[key.Name] = hash
+}
+
+// remove deletes the key named name. Callers must hold mu.
+func (s *KeyStore) remove(name string) {
+	delete(s.keys, s.names[name])
+	delete(s.names, name)
+}
+
+// hashKey returns the hex SHA-256 hash of a key value
+func hashKey(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// Load builds a key store from a default admin key, an inline key list, and a key file, any of which may be empty.
+// The inline list is comma-separated name:scope:key entries. At least one key must be configured.
+func Load(defaultKey, inline, path string) (*KeyStore, error) {
+	var keys []Key
+
+	if defaultKey != "" {
+		keys = append(keys, Key{Name: "default", Key: defaultKey, Scope: ScopeAdmin})
+	}
+
+	inlineKeys, err := ParseKeys(inline)
+	if err != nil {
+		return nil, err
+	}
+	keys = append(keys, inlineKeys...)
+
+	if path != "" {
+		fileKeys, err := LoadKeysFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, fileKeys...)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no API keys configured")
+	}
+
+	return NewKeyStore(keys)
+}
+
+// ParseKeys parses a comma-separated list of name:scope:key entries
+func ParseKeys(spec string) ([]Key, error) {
+	var keys []Key
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid API key entry %q: expected name:scope:key", parts[0])
+		}
+		keys = append(keys, Key{Name: parts[0], Scope: Scope(parts[1]), Key: parts[2]})
+	}
+	return keys, nil
+}
+
+// LoadKeysFile reads the keys defined in a YAML or JSON file
+func LoadKeysFile(path string) ([]Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys file: %w", err)
+	}
+
+	// YAML is decoded generically and re-encoded as JSON so keys only need json tags
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		var raw any
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse API keys file: %w", err)
+		}
+		if data, err = sonic.Marshal(raw); err != nil {
+			return nil, fmt.Errorf("failed to convert API keys file: %w", err)
+		}
+	}
+
+	var file keysFile
+	if err := sonic.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse API keys file: %w", err)
+	}
+	return file.Keys, nil
+}
+
+// Lookup returns the key for an API key from the X-API-Key header
+func (s *KeyStore) Lookup(apiKey string) (Key, error) {
 	if apiKey == "" {
-		return ErrMissingAPIKey
+		return Key{}, ErrMissingAPIKey
 	}
 
-	if apiKey != v.apiKey {
-		return ErrInvalidToken
+	s.refresh()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[hashKey(apiKey)]
+	if !ok {
+		return Key{}, ErrInvalidToken
 	}
 
-	return nil
+	return key, nil
 }
 
-// ValidateToken is kept for backward compatibility (used by MCP middleware)
-// It validates bearer token from Authorization header
-func (v *Validator) ValidateToken(authHeader string) error {
+// LookupBearer returns the key for a bearer token from the Authorization header (used by MCP middleware)
+func (s *KeyStore) LookupBearer(authHeader string) (Key, error) {
 	if authHeader == "" {
-		return errors.New("missing Authorization header")
+		return Key{}, errors.New("missing Authorization header")
 	}
 
 	// For MCP: Extract token from "Bearer <token>"
@@ -49,9 +232,10 @@ func (v *Validator) ValidateToken(authHeader string) error {
 		token = authHeader[7:]
 	}
 
-	if token != v.apiKey {
-		return ErrInvalidToken
+	key, err := s.Lookup(token)
+	if err != nil {
+		return Key{}, ErrInvalidToken
 	}
 
-	return nil
+	return key, nil
 }
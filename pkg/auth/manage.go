@@ -0,0 +1,322 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+
+	"govid/pkg/filelock"
+	"govid/pkg/logger"
+)
+
+var (
+	// ErrKeyNotFound is returned when no key has the requested name
+	ErrKeyNotFound = errors.New("API key not found")
+	// ErrKeyExists is returned when a key name is already in use
+	ErrKeyExists = errors.New("API key name already in use")
+	// ErrKeyNotManaged is returned when revoking or rotating a key defined in configuration
+	ErrKeyNotManaged = errors.New("API key is defined in configuration and cannot be changed at runtime")
+	// ErrKeyStorage is returned when a key change cannot be generated or persisted
+	ErrKeyStorage = errors.New("failed to store API key")
+)
+
+// keyNamePattern restricts managed key names to characters that are safe in URLs and logs
+var keyNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// managedKey is the persisted form of a key created at runtime. Only the hash of its value is stored.
+type managedKey struct {
+	Name         string  `json:"name"`
+	KeyHash      string  `json:"key_hash"`
+	Scope        Scope   `json:"scope"`
+	QuotaMinutes float64 `json:"quota_minutes,omitempty"`
+}
+
+// managedKeysFile is the layout of the managed keys file
+type managedKeysFile struct {
+	Keys []managedKey `json:"keys"`
+}
+
+// LoadManaged enables runtime key management. Keys created earlier are loaded from path, and every
+// change is written back to it. Managed key names must not clash with configured keys.
+//
+// Instances sharing the file, such as API instances behind a load balancer, share the managed keys: every
+// change is made under a lock on the file, to the keys read from it, and lookups reload the file when another
+// instance changed it.
+func (s *KeyStore) LoadManaged(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	return s.reload()
+}
+
+// reload replaces the managed keys with those of the managed keys file, leaving them unchanged if the file
+// is invalid. Callers must hold mu.
+func (s *KeyStore) reload() error {
+	var modTime time.Time
+	if info, err := os.Stat(s.path); err == nil {
+		modTime = info.ModTime()
+	}
+	var file managedKeysFile
+	content, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read managed API keys: %w", err)
+	}
+	if err == nil {
+		if err := sonic.Unmarshal(content, &file); err != nil {
+			return fmt.Errorf("failed to parse managed API keys: %w", err)
+		}
+	}
+
+	// Build the new key set apart, so that an invalid file leaves the current one in place
+	next := &KeyStore{keys: make(map[string]Key), names: make(map[string]string)}
+	for hash, key := range s.keys {
+		if !key.Managed {
+			next.add(hash, key)
+		}
+	}
+	for _, stored := range file.Keys {
+		if err := next.check(stored.Name, stored.Scope, stored.QuotaMinutes); err != nil {
+			return fmt.Errorf("managed API key: %w", err)
+		}
+		next.add(stored.KeyHash, Key{
+			Name:         stored.Name,
+			Scope:        stored.Scope,
+			QuotaMinutes: stored.QuotaMinutes,
+			Managed:      true,
+		})
+	}
+
+	s.keys, s.names, s.modTime = next.keys, next.names, modTime
+	return nil
+}
+
+// refresh reloads the managed keys if another instance changed their file
+func (s *KeyStore) refresh() {
+	s.mu.RLock()
+	path, seen := s.path, s.modTime
+	s.mu.RUnlock()
+	if path == "" {
+		return
+	}
+
+	var modTime time.Time
+	info, err := os.Stat(path)
+	if err == nil {
+		modTime = info.ModTime()
+	} else if !os.IsNotExist(err) {
+		return
+	}
+	if modTime.Equal(seen) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		logger.Error("Failed to reload managed API keys: %v", err)
+	}
+}
+
+// lockManaged locks the managed keys file for a change and reloads it, so that the change applies to the
+// keys other instances changed. The returned function releases the lock. Callers must hold mu.
+func (s *KeyStore) lockManaged() (func(), error) {
+	if err := s.checkManagement(); err != nil {
+		return nil, err
+	}
+	unlock, err := filelock.Lock(s.path + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyStorage, err)
+	}
+	if err := s.reload(); err != nil {
+		unlock()
+		return nil, fmt.Errorf("%w: %v", ErrKeyStorage, err)
+	}
+	return unlock, nil
+}
+
+// List returns every key, without key values, sorted by name
+func (s *KeyStore) List() []Key {
+	s.refresh()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b Key) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return keys
+}
+
+// Create adds a managed key and returns it with its generated key value, which is not stored
+func (s *KeyStore) Create(name string, scope Scope, quotaMinutes float64) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lockManaged()
+	if err != nil {
+		return Key{}, err
+	}
+	defer unlock()
+	if !keyNamePattern.MatchString(name) {
+		return Key{}, fmt.Errorf("API key name must be 1-64 letters, digits, '.', '_', or '-'")
+	}
+	if err := s.check(name, scope, quotaMinutes); err != nil {
+		return Key{}, err
+	}
+
+	value, err := generateKey()
+	if err != nil {
+		return Key{}, err
+	}
+
+	key := Key{Name: name, Scope: scope, QuotaMinutes: quotaMinutes, Managed: true}
+	s.add(hashKey(value), key)
+	if err := s.save(); err != nil {
+		s.remove(name)
+		return Key{}, err
+	}
+
+	key.Key = value
+	return key, nil
+}
+
+// Revoke deletes a managed key
+func (s *KeyStore) Revoke(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lockManaged()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	key, hash, err := s.managed(name)
+	if err != nil {
+		return err
+	}
+
+	s.remove(name)
+	if err := s.save(); err != nil {
+		s.add(hash, key)
+		return err
+	}
+	return nil
+}
+
+// Rotate replaces the value of a managed key and returns the key with its new value.
+// The old value stops working immediately.
+func (s *KeyStore) Rotate(name string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lockManaged()
+	if err != nil {
+		return Key{}, err
+	}
+	defer unlock()
+	key, hash, err := s.managed(name)
+	if err != nil {
+		return Key{}, err
+	}
+
+	value, err := generateKey()
+	if err != nil {
+		return Key{}, err
+	}
+
+	s.remove(name)
+	s.add(hashKey(value), key)
+	if err := s.save(); err != nil {
+		s.remove(name)
+		s.add(hash, key)
+		return Key{}, err
+	}
+
+	key.Key = value
+	return key, nil
+}
+
+// managed returns the managed key named name and its hash. Callers must hold mu.
+func (s *KeyStore) managed(name string) (Key, string, error) {
+	if err := s.checkManagement(); err != nil {
+		return Key{}, "", err
+	}
+	hash, ok := s.names[name]
+	if !ok {
+		return Key{}, "", fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	key := s.keys[hash]
+	if !key.Managed {
+		return Key{}, "", fmt.Errorf("%w: %s", ErrKeyNotManaged, name)
+	}
+	return key, hash, nil
+}
+
+// checkManagement fails when runtime key management has not been enabled. Callers must hold mu.
+func (s *KeyStore) checkManagement() error {
+	if s.path == "" {
+		return errors.New("runtime API key management is not enabled")
+	}
+	return nil
+}
+
+// save writes the managed keys to disk. Callers must hold mu and the lock of lockManaged.
+func (s *KeyStore) save() error {
+	file := managedKeysFile{Keys: []managedKey{}}
+	for hash, key := range s.keys {
+		if !key.Managed {
+			continue
+		}
+		file.Keys = append(file.Keys, managedKey{
+			Name:         key.Name,
+			KeyHash:      hash,
+			Scope:        key.Scope,
+			QuotaMinutes: key.QuotaMinutes,
+		})
+	}
+	slices.SortFunc(file.Keys, func(a, b managedKey) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	content, err := sonic.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrKeyStorage, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("%w: %v", ErrKeyStorage, err)
+	}
+	tempPath := s.path + ".tmp"
+	if err := os.WriteFile(tempPath, content, 0o600); err != nil {
+		return fmt.Errorf("%w: %v", ErrKeyStorage, err)
+	}
+	if err := os.Rename(tempPath, s.path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("%w: %v", ErrKeyStorage, err)
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// generateKey returns a new random key value
+func generateKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrKeyStorage, err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,111 @@
+package cleanup
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"govid/internal/uploads"
+	"govid/pkg/logger"
+)
+
+// Quotas limits the disk usage of the output and upload directories; 0 leaves a directory unlimited
+type Quotas struct {
+	OutputBytes int64
+	UploadBytes int64
+}
+
+// quotaFile is a file that may be evicted to bring a directory under its quota
+type quotaFile struct {
+	path string
+	info fs.FileInfo
+}
+
+// enforceQuotas evicts the oldest files from directories over their quota, whatever their age
+func (s *Scheduler) enforceQuotas() {
+	if s.quotas.OutputBytes > 0 {
+		// Outputs of running jobs are still being written or uploaded
+		inUse := s.activeFiles(s.outputDir)
+		s.enforceQuota(s.outputDir, s.quotas.OutputBytes, func(path string) bool {
+			return !inUse(path)
+		}, os.Remove)
+	}
+	if s.quotas.UploadBytes > 0 {
+		inUse := s.activeFiles(s.uploadDir)
+		s.enforceQuota(s.uploadDir, s.quotas.UploadBytes, func(path string) bool {
+			return filepath.Dir(path) == filepath.Clean(s.uploadDir) && !inUse(path)
+		}, func(path string) error {
+			// Also drops the expiry and scan record of the upload
+			if err := uploads.Delete(s.uploadDir, filepath.Base(path)); err != nil && !errors.Is(err, uploads.ErrNotFound) {
+				return err
+			}
+			return nil
+		})
+	}
+}
+
+// enforceQuota removes the files of dir for which evictable returns true, oldest first, until the files in dir
+// take up at most maxBytes, and removes subdirectories left empty
+func (s *Scheduler) enforceQuota(dir string, maxBytes int64, evictable func(path string) bool, remove func(path string) error) {
+	var total int64
+	var candidates []quotaFile
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil // removed while walking
+		}
+		total += info.Size()
+		if evictable(path) {
+			candidates = append(candidates, quotaFile{path: path, info: info})
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("Failed to measure directory %s: %v", dir, err)
+		return
+	}
+	if total <= maxBytes {
+		return
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].info.ModTime().Before(candidates[j].info.ModTime())
+	})
+
+	used := total
+	evicted, freed := 0, int64(0)
+	for _, file := range candidates {
+		if used <= maxBytes {
+			break
+		}
+		if err := remove(file.path); err != nil {
+			if !os.IsNotExist(err) {
+				logger.Error("Failed to evict %s: %v", file.path, err)
+			}
+			continue
+		}
+		logger.Debug("Evicted %s (modified: %s)", file.path, file.info.ModTime().Format(time.RFC3339))
+		used -= file.info.Size()
+		evicted++
+		freed += file.info.Size()
+		// Fails harmlessly while the directory still holds other files
+		for parent := filepath.Dir(file.path); parent != filepath.Clean(dir) && strings.HasPrefix(parent, filepath.Clean(dir)); parent = filepath.Dir(parent) {
+			if os.Remove(parent) != nil {
+				break
+			}
+		}
+	}
+
+	logger.Info("Directory %s exceeded its quota of %d MB: evicted %d files (%d MB), %d MB in use",
+		dir, maxBytes>>20, evicted, freed>>20, used>>20)
+	if used > maxBytes {
+		logger.Warn("Directory %s is still over its quota of %d MB; the remaining files are in use", dir, maxBytes>>20)
+	}
+}
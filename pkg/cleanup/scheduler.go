@@ -3,9 +3,11 @@ package cleanup
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"govid/internal/models"
+	"govid/internal/uploads"
 	"govid/pkg/logger"
 )
 
@@ -16,39 +18,75 @@ type Scheduler struct {
 	tempDir       string
 	jobStore      *models.JobStore
 	retentionDays int
+	quotas        Quotas
+	isLeader      func() bool // nil when this instance is the only one cleaning up
 	cleanupTicker *time.Ticker
+	expiryTicker  *time.Ticker
 	stopChan      chan struct{}
 }
 
+// expiryInterval is how often uploads past their TTL are removed, leftover intermediate files are removed and
+// the disk quotas are checked
+const expiryInterval = 5 * time.Minute
+
+// intermediateSuffixes are appended to an output path for the intermediate files of a job. Jobs remove them
+// when they end; those left by a crash, or by versions that processed in stages, are removed by the cleanup.
+var intermediateSuffixes = []string{".merged.mp4", ".overlay.mp4", ".prenorm.mp4"}
+
 // NewScheduler creates a new cleanup scheduler
-func NewScheduler(outputDir, uploadDir, tempDir string, jobStore *models.JobStore, retentionDays int) *Scheduler {
+func NewScheduler(outputDir, uploadDir, tempDir string, jobStore *models.JobStore, retentionDays int, quotas Quotas) *Scheduler {
 	return &Scheduler{
 		outputDir:     outputDir,
 		uploadDir:     uploadDir,
 		tempDir:       tempDir,
 		jobStore:      jobStore,
 		retentionDays: retentionDays,
+		quotas:        quotas,
 		stopChan:      make(chan struct{}),
 	}
 }
 
+// FollowLeader makes the cleanup run only while isLeader returns true, so that of the instances sharing the
+// directories, only the leader sweeps them
+func (s *Scheduler) FollowLeader(isLeader func() bool) {
+	s.isLeader = isLeader
+}
+
+// leads reports whether this instance should clean up now
+func (s *Scheduler) leads() bool {
+	return s.isLeader == nil || s.isLeader()
+}
+
 // Start begins the cleanup scheduler
 func (s *Scheduler) Start() {
 	logger.Info("Starting cleanup scheduler (retention: %d days)", s.retentionDays)
 
 	// Run cleanup immediately on start
-	go s.runCleanup()
+	if s.leads() {
+		go s.runCleanup()
+	}
 
-	// Schedule cleanup every 24 hours
+	// Schedule cleanup every 24 hours, and removal of expired uploads and quota checks more often
 	s.cleanupTicker = time.NewTicker(24 * time.Hour)
+	s.expiryTicker = time.NewTicker(expiryInterval)
 
 	go func() {
 		for {
 			select {
 			case <-s.cleanupTicker.C:
-				s.runCleanup()
+				if s.leads() {
+					s.runCleanup()
+				}
+			case <-s.expiryTicker.C:
+				if !s.leads() {
+					continue
+				}
+				s.removeExpiredUploads()
+				s.removeIntermediates()
+				s.enforceQuotas()
 			case <-s.stopChan:
 				s.cleanupTicker.Stop()
+				s.expiryTicker.Stop()
 				return
 			}
 		}
@@ -72,21 +110,29 @@ func (s *Scheduler) runCleanup() {
 	totalFilesDeleted := 0
 	totalJobsDeleted := 0
 
-	// Clean outputs directory
-	filesDeleted := s.cleanDirectory(s.outputDir, cutoffTime)
+	// Clean outputs directory, starting with intermediate files of jobs that have ended
+	s.removeIntermediates()
+	filesDeleted := s.cleanDirectory(s.outputDir, cutoffTime, s.activeFiles(s.outputDir))
 	totalFilesDeleted += filesDeleted
 	logger.Info("Cleaned %d files from outputs directory", filesDeleted)
 
-	// Clean uploads directory
-	filesDeleted = s.cleanDirectory(s.uploadDir, cutoffTime)
+	// Clean uploads directory. Uploads with a TTL are left to removeExpiredUploads.
+	s.removeExpiredUploads()
+	inUse := s.activeFiles(s.uploadDir)
+	filesDeleted = s.cleanDirectory(s.uploadDir, cutoffTime, func(path string) bool {
+		return s.hasUploadTTL(path) || inUse(path)
+	})
 	totalFilesDeleted += filesDeleted
 	logger.Info("Cleaned %d files from uploads directory", filesDeleted)
 
-	// Clean temp directory (always clean all files older than cutoff)
-	filesDeleted = s.cleanDirectory(s.tempDir, cutoffTime)
+	// Clean temp directory (all files older than cutoff, except those of running jobs)
+	filesDeleted = s.cleanDirectory(s.tempDir, cutoffTime, s.activeFiles(s.tempDir))
 	totalFilesDeleted += filesDeleted
 	logger.Info("Cleaned %d files from temp directory", filesDeleted)
 
+	// Evict more outputs and uploads if the retention cleanup left directories over their quota
+	s.enforceQuotas()
+
 	// Clean old jobs
 	totalJobsDeleted = s.cleanOldJobs(cutoffTime)
 	logger.Info("Cleaned %d old jobs", totalJobsDeleted)
@@ -95,8 +141,103 @@ func (s *Scheduler) runCleanup() {
 	logger.Info("Cleanup completed in %s (deleted %d files, %d jobs)", duration, totalFilesDeleted, totalJobsDeleted)
 }
 
-// cleanDirectory removes files older than cutoffTime from a directory
-func (s *Scheduler) cleanDirectory(dir string, cutoffTime time.Time) int {
+// removeExpiredUploads removes uploads whose TTL has passed, unless a job that started before they expired
+// is still using them
+func (s *Scheduler) removeExpiredUploads() {
+	removed, err := uploads.RemoveExpired(s.uploadDir, time.Now(), s.activeFiles(s.uploadDir))
+	if err != nil {
+		logger.Error("Failed to remove expired uploads: %v", err)
+		return
+	}
+	if removed > 0 {
+		logger.Info("Removed %d expired uploads", removed)
+	}
+}
+
+// removeIntermediates removes the intermediate files in the output directory that no running job uses
+func (s *Scheduler) removeIntermediates() {
+	inUse := s.activeFiles(s.outputDir)
+	removed := 0
+	err := filepath.WalkDir(s.outputDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || inUse(path) {
+			return nil
+		}
+		for _, suffix := range intermediateSuffixes {
+			if strings.HasSuffix(entry.Name(), suffix) {
+				if err := os.Remove(path); err != nil {
+					logger.Error("Failed to delete intermediate file %s: %v", path, err)
+				} else {
+					removed++
+				}
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("Failed to scan %s for intermediate files: %v", s.outputDir, err)
+	}
+	if removed > 0 {
+		logger.Info("Removed %d leftover intermediate files", removed)
+	}
+}
+
+// hasUploadTTL reports whether a path in the upload directory is an upload with a TTL, the directory their
+// expiry times are kept in, or the scan record of an upload that is still there
+func (s *Scheduler) hasUploadTTL(path string) bool {
+	if uploads.IsExpiryDir(s.uploadDir, path) || uploads.IsScanRecord(s.uploadDir, path) {
+		return true
+	}
+	_, ok := uploads.Expiry(s.uploadDir, filepath.Base(path))
+	return ok && filepath.Dir(path) == filepath.Clean(s.uploadDir)
+}
+
+// activeFiles returns a function reporting whether a path in dir belongs to a scheduled, pending or processing job:
+// a file the job recorded with AddFiles, such as an upload it reads or a video it downloaded, or a file or
+// directory named after the job, such as its output. Those are kept however old they are.
+func (s *Scheduler) activeFiles(dir string) func(path string) bool {
+	files := make(map[string]bool)
+	var ids []string
+	for _, job := range s.jobStore.List() {
+		status := job.GetStatus().Status
+		if status != models.JobStatusScheduled && status != models.JobStatusPending && status != models.JobStatusProcessing {
+			continue
+		}
+		ids = append(ids, job.ID)
+		for _, file := range job.Files() {
+			files[absPath(file)] = true
+		}
+	}
+
+	return func(path string) bool {
+		if files[absPath(path)] {
+			return true
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return false
+		}
+		for _, id := range ids {
+			if strings.Contains(rel, id) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// absPath returns the absolute form of path, so that paths given relative to the working directory match
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// cleanDirectory removes files older than cutoffTime from a directory and its subdirectories, such as
+// the per-job directories of the local storage backend, and removes subdirectories left empty. Paths
+// for which keep returns true are left alone; keep may be nil.
+func (s *Scheduler) cleanDirectory(dir string, cutoffTime time.Time, keep func(path string) bool) int {
 	filesDeleted := 0
 
 	entries, err := os.ReadDir(dir)
@@ -106,11 +247,17 @@ func (s *Scheduler) cleanDirectory(dir string, cutoffTime time.Time) int {
 	}
 
 	for _, entry := range entries {
+		filePath := filepath.Join(dir, entry.Name())
+		if keep != nil && keep(filePath) {
+			continue
+		}
 		if entry.IsDir() {
+			filesDeleted += s.cleanDirectory(filePath, cutoffTime, keep)
+			// Fails harmlessly while the directory still holds newer files
+			_ = os.Remove(filePath)
 			continue
 		}
 
-		filePath := filepath.Join(dir, entry.Name())
 		info, err := entry.Info()
 		if err != nil {
 			logger.Error("Failed to get file info for %s: %v", filePath, err)
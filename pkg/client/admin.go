@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// The methods in this file need an API key with the admin scope
+
+// ListJobsOptions filters the jobs listed by ListJobs
+type ListJobsOptions struct {
+	Status JobStatus         // only jobs with this status; empty for all
+	Labels map[string]string // only jobs with all of these labels; an empty value matches any value
+	Limit  int               // at most this many jobs; 0 for the server's default
+}
+
+// ListJobs lists the jobs of all API keys, newest first
+func (c *Client) ListJobs(ctx context.Context, opts ListJobsOptions) (*AdminJobsResponse, error) {
+	query := url.Values{}
+	if opts.Status != "" {
+		query.Set("status", string(opts.Status))
+	}
+	for key, value := range opts.Labels {
+		if value != "" {
+			key += ":" + value
+		}
+		query.Add("label", key)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	path := "/api/v1/admin/jobs"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var result AdminJobsResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RetryJob runs a failed or cancelled job again as a new job
+func (c *Client) RetryJob(ctx context.Context, jobID string) (*JobResponse, error) {
+	return c.createJob(ctx, "/api/v1/admin/jobs/"+escape(jobID)+"/retry", nil)
+}
+
+// Stats returns the job counts by status and the free space of the storage directories
+func (c *Client) Stats(ctx context.Context) (*AdminStatsResponse, error) {
+	var result AdminStatsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/admin/stats", nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Drain makes the server stop accepting jobs ahead of a shutdown, and returns the progress of the drain
+func (c *Client) Drain(ctx context.Context) (*DrainResponse, error) {
+	var result DrainResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/admin/drain", nil, &result, http.StatusAccepted); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DrainStatus returns the progress of the drain, with drained set once the server runs no more jobs
+func (c *Client) DrainStatus(ctx context.Context) (*DrainResponse, error) {
+	var result DrainResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/admin/drain", nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// APIKeys lists the API keys, without their secrets
+func (c *Client) APIKeys(ctx context.Context) (*APIKeysResponse, error) {
+	var result APIKeysResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/admin/keys", nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreateAPIKey creates a managed API key and returns it with its secret
+func (c *Client) CreateAPIKey(ctx context.Context, req CreateAPIKeyRequest) (*APIKeyResponse, error) {
+	var result APIKeyResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/admin/keys", req, &result, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RevokeAPIKey deletes a managed API key
+func (c *Client) RevokeAPIKey(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/admin/keys/"+escape(name), nil, nil, http.StatusNoContent)
+}
+
+// RotateAPIKey replaces the secret of a managed API key and returns the new one
+func (c *Client) RotateAPIKey(ctx context.Context, name string) (*APIKeyResponse, error) {
+	var result APIKeyResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/admin/keys/"+escape(name)+"/rotate", nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PutTemplate creates or replaces the job template named name
+func (c *Client) PutTemplate(ctx context.Context, name string, template JobTemplate) (*JobTemplate, error) {
+	var result JobTemplate
+	if err := c.do(ctx, http.MethodPut, "/api/v1/admin/templates/"+escape(name), template, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteTemplate deletes a job template
+func (c *Client) DeleteTemplate(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/admin/templates/"+escape(name), nil, nil, http.StatusNoContent)
+}
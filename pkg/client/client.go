@@ -0,0 +1,185 @@
+// Package client is a Go client of the GoVid REST API. It sends requests with an API key, decodes the
+// responses into the API's own types, waits for jobs to finish, and streams uploads and downloads.
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+// Config configures a client
+type Config struct {
+	BaseURL    string       // address of the server, such as http://localhost:4101
+	APIKey     string       // sent as X-API-Key
+	HTTPClient *http.Client // defaults to http.DefaultClient; downloads and uploads can take long, so avoid short timeouts
+	UserAgent  string       // defaults to govid-client/1.0
+}
+
+// Client calls the GoVid REST API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	userAgent  string
+}
+
+// APIError is an error response of the API
+type APIError struct {
+	StatusCode int
+	Err        string // short description, the error field of the response
+	Message    string
+	Input      string // the input a request was refused for, if any
+	RequestID  string // X-Request-ID of the request, to find it in the server logs
+}
+
+// Error returns the status code and description of the error
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("govid: %d %s", e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("govid: %d %s: %s", e.StatusCode, e.Err, e.Message)
+}
+
+// StatusCode returns the HTTP status of an *APIError in err's chain, or 0 if there is none
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// New creates a client of the server at cfg.BaseURL
+func New(cfg Config) (*Client, error) {
+	base, err := url.Parse(cfg.BaseURL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", cfg.BaseURL)
+	}
+	c := &Client{
+		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		apiKey:     cfg.APIKey,
+		httpClient: cfg.HTTPClient,
+		userAgent:  cfg.UserAgent,
+	}
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	if c.userAgent == "" {
+		c.userAgent = "govid-client/1.0"
+	}
+	return c, nil
+}
+
+// requestIDKey is the context key of the request ID set with WithRequestID
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose requests send id as X-Request-ID. Jobs keep the ID of the request
+// that created them, in their status, logs and webhook payloads.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// newRequest creates a request to path, which is relative to the base URL
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Accept", "application/json")
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok && id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+	return req, nil
+}
+
+// send sends a request and returns the response if its status is one of ok, or the *APIError it carries
+func (c *Client) send(req *http.Request, ok ...int) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range ok {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+	return nil, responseError(resp)
+}
+
+// responseError reads the error response of the API from resp
+func responseError(resp *http.Response) error {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Err:        http.StatusText(resp.StatusCode),
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+	content, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var body ErrorResponse
+	if err := sonic.Unmarshal(content, &body); err == nil && body.Error != "" {
+		apiErr.Err = body.Error
+		apiErr.Message = body.Message
+		apiErr.Input = body.Input
+	} else if text := strings.TrimSpace(string(content)); text != "" {
+		apiErr.Message = text
+	}
+	return apiErr
+}
+
+// do sends a request with in, if not nil, as its JSON body and decodes the JSON response into out, if not
+// nil. Statuses other than ok fail with an *APIError.
+func (c *Client) do(ctx context.Context, method, path string, in, out any, ok ...int) error {
+	var body io.Reader
+	if in != nil {
+		content, err := sonic.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(content)
+	}
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.send(req, ok...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decode(resp, out)
+}
+
+// decode decodes the JSON body of a response into out, if not nil
+func decode(resp *http.Response, out any) error {
+	if out == nil {
+		return nil
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := sonic.Unmarshal(content, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// escape escapes a path parameter
+func escape(param string) string {
+	return url.PathEscape(param)
+}
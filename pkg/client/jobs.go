@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultWaitInterval is how often Wait polls a job when WaitOptions sets no interval
+const defaultWaitInterval = 2 * time.Second
+
+// JobError is returned by Wait for a job that ended without completing
+type JobError struct {
+	JobID   string
+	Status  JobStatus // failed, cancelled, or expired
+	Message string    // error of the job, if any
+}
+
+// Error returns the status and error of the job
+func (e *JobError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("govid: job %s is %s", e.JobID, e.Status)
+	}
+	return fmt.Sprintf("govid: job %s is %s: %s", e.JobID, e.Status, e.Message)
+}
+
+// Finished reports whether a job with status has ended: completed, failed, cancelled, or expired
+func Finished(status JobStatus) bool {
+	switch status {
+	case JobStatusCompleted, JobStatusFailed, JobStatusCancelled, JobStatusExpired:
+		return true
+	}
+	return false
+}
+
+// Job returns the status of a job
+func (c *Client) Job(ctx context.Context, jobID string) (*JobStatusResponse, error) {
+	var status JobStatusResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/jobs/"+escape(jobID), nil, &status, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// CancelJob cancels a scheduled, pending or processing job and returns its status
+func (c *Client) CancelJob(ctx context.Context, jobID string) (*JobStatusResponse, error) {
+	var status JobStatusResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/jobs/"+escape(jobID)+"/cancel", nil, &status, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// JobLogs returns the FFmpeg output of a job
+func (c *Client) JobLogs(ctx context.Context, jobID string) (string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/api/v1/jobs/"+escape(jobID)+"/logs", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.send(req, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read job logs: %w", err)
+	}
+	return string(content), nil
+}
+
+// CreateLink uploads the output of a completed job to the storage backend and returns the job status with
+// its link. opts may be nil.
+func (c *Client) CreateLink(ctx context.Context, jobID string, opts *StorageOptions) (*JobStatusResponse, error) {
+	var in any
+	if opts != nil {
+		in = opts
+	}
+	var status JobStatusResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/jobs/"+escape(jobID)+"/create-link", in, &status, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Presign returns the status of a job with a fresh presigned link to its output in the storage backend
+func (c *Client) Presign(ctx context.Context, jobID string) (*JobStatusResponse, error) {
+	var status JobStatusResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/jobs/"+escape(jobID)+"/presign", nil, &status, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// WaitOptions configures Wait
+type WaitOptions struct {
+	Interval time.Duration                   // time between polls; defaults to 2 seconds
+	OnStatus func(status *JobStatusResponse) // called with every status polled, such as to report progress; may be nil
+}
+
+// Wait polls a job until it ends or ctx is done, and returns its last status. A job that ends without
+// completing returns its status along with a *JobError.
+func (c *Client) Wait(ctx context.Context, jobID string, opts WaitOptions) (*JobStatusResponse, error) {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultWaitInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		status, err := c.Job(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if opts.OnStatus != nil {
+			opts.OnStatus(status)
+		}
+		if Finished(status.Status) {
+			if status.Status != JobStatusCompleted {
+				return status, &JobError{JobID: jobID, Status: status.Status, Message: status.Error}
+			}
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Download opens the output of a completed job and returns its content and size, or -1 if the size is not
+// known. The caller must close the content. A job that has not completed yet fails with an *APIError with
+// status 202.
+func (c *Client) Download(ctx context.Context, jobID string) (io.ReadCloser, int64, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/api/v1/jobs/"+escape(jobID)+"/download", nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "*/*")
+	resp, err := c.send(req, http.StatusOK)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// DownloadFile downloads the output of a completed job to path and returns its size. The file is written
+// next to path first, so path only appears once the download is complete.
+func (c *Client) DownloadFile(ctx context.Context, jobID, path string) (int64, error) {
+	content, _, err := c.Download(ctx, jobID)
+	if err != nil {
+		return 0, err
+	}
+	defer content.Close()
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	written, err := io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to download job output: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to save job output: %w", err)
+	}
+	return written, nil
+}
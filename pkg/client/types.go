@@ -0,0 +1,125 @@
+package client
+
+import "govid/internal/models"
+
+// The request and response types of the API, shared with the server so they cannot drift apart
+
+// Processing requests
+type (
+	MergeVideoRequest      = models.MergeVideoRequest
+	OverlayRequest         = models.OverlayRequest
+	AudioRequest           = models.AudioRequest
+	CompleteProcessRequest = models.CompleteProcessRequest
+	SilenceRequest         = models.SilenceRequest
+	VerticalRequest        = models.VerticalRequest
+	TranscribeRequest      = models.TranscribeRequest
+	AutoSubtitleRequest    = models.AutoSubtitleRequest
+	CombineVideosRequest   = models.CombineVideosRequest
+	ProbeRequest           = models.ProbeRequest
+	QualityRequest         = models.QualityRequest
+	LoudnessRequest        = models.LoudnessRequest
+	DefectsRequest         = models.DefectsRequest
+	FingerprintRequest     = models.FingerprintRequest
+	CompareRequest         = models.CompareRequest
+	PipelineRequest        = models.PipelineRequest
+	PipelineStep           = models.PipelineStep
+	TemplateRunRequest     = models.TemplateRunRequest
+)
+
+// Parts of processing requests
+type (
+	VideoSegment          = models.VideoSegment
+	ImageOverlay          = models.ImageOverlay
+	TextOverlay           = models.TextOverlay
+	AudioConfig           = models.AudioConfig
+	SubtitleFile          = models.SubtitleFile
+	SubtitleFormat        = models.SubtitleFormat
+	CaptionStyle          = models.CaptionStyle
+	CaptionPosition       = models.CaptionPosition
+	DuckingConfig         = models.DuckingConfig
+	LoudnessNormalization = models.LoudnessNormalization
+	OutputOptions         = models.OutputOptions
+	OutputMetadata        = models.OutputMetadata
+	DeliveryOptions       = models.DeliveryOptions
+	StorageOptions        = models.StorageOptions
+	ConflictMode          = models.ConflictMode
+)
+
+// Jobs
+type (
+	JobStatus         = models.JobStatus
+	JobResponse       = models.JobResponse
+	JobStatusResponse = models.JobStatusResponse
+	StepStatus        = models.StepStatus
+)
+
+// Job statuses
+const (
+	JobStatusScheduled  = models.JobStatusScheduled
+	JobStatusPending    = models.JobStatusPending
+	JobStatusProcessing = models.JobStatusProcessing
+	JobStatusCompleted  = models.JobStatusCompleted
+	JobStatusFailed     = models.JobStatusFailed
+	JobStatusCancelled  = models.JobStatusCancelled
+	JobStatusExpired    = models.JobStatusExpired
+)
+
+// Results of synchronous requests
+type (
+	SilenceDetectResponse = models.SilenceDetectResponse
+	SilenceRange          = models.SilenceRange
+	MediaProbe            = models.MediaProbe
+	MediaStream           = models.MediaStream
+	QualityMetric         = models.QualityMetric
+	QualityResponse       = models.QualityResponse
+	VMAFScore             = models.VMAFScore
+	PSNRScore             = models.PSNRScore
+	SSIMScore             = models.SSIMScore
+	LoudnessResponse      = models.LoudnessResponse
+	DefectReport          = models.DefectReport
+	DefectRange           = models.DefectRange
+	DefectKind            = models.DefectKind
+	FingerprintResponse   = models.FingerprintResponse
+	FingerprintMatch      = models.FingerprintMatch
+	CompareResponse       = models.CompareResponse
+)
+
+// Uploads
+type (
+	UploadResponse               = models.UploadResponse
+	MultiUploadResponse          = models.MultiUploadResponse
+	UploadedFile                 = models.UploadedFile
+	UploadListResponse           = models.UploadListResponse
+	URLUploadRequest             = models.URLUploadRequest
+	DirectUploadRequest          = models.DirectUploadRequest
+	DirectUploadResponse         = models.DirectUploadResponse
+	ChunkedUploadInitRequest     = models.ChunkedUploadInitRequest
+	ChunkedUploadCompleteRequest = models.ChunkedUploadCompleteRequest
+	ChunkedUploadResponse        = models.ChunkedUploadResponse
+)
+
+// Presets, templates, usage, fingerprints and health
+type (
+	PresetsResponse      = models.PresetsResponse
+	EncodingPreset       = models.EncodingPreset
+	JobTemplate          = models.JobTemplate
+	TemplateParameter    = models.TemplateParameter
+	TemplatesResponse    = models.TemplatesResponse
+	UsageResponse        = models.UsageResponse
+	FingerprintsResponse = models.FingerprintsResponse
+	FingerprintEntry     = models.FingerprintEntry
+	HealthResponse       = models.HealthResponse
+	ReadinessResponse    = models.ReadinessResponse
+	ErrorResponse        = models.ErrorResponse
+)
+
+// Administration
+type (
+	CreateAPIKeyRequest = models.CreateAPIKeyRequest
+	APIKeyResponse      = models.APIKeyResponse
+	APIKeysResponse     = models.APIKeysResponse
+	AdminJob            = models.AdminJob
+	AdminJobsResponse   = models.AdminJobsResponse
+	AdminStatsResponse  = models.AdminStatsResponse
+	DrainResponse       = models.DrainResponse
+)
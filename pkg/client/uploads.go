@@ -0,0 +1,156 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// UploadFile uploads a file of the local disk. See Upload.
+func (c *Client) UploadFile(ctx context.Context, path string, ttlSeconds int) (*UploadResponse, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return c.Upload(ctx, filepath.Base(path), file, ttlSeconds)
+}
+
+// Upload streams content to the upload directory as a file named fileName, whose extension decides the
+// accepted file type. The returned file_path is the input of processing requests. A positive ttlSeconds
+// deletes the file that long after upload instead of after UPLOAD_TTL_SECONDS.
+func (c *Client) Upload(ctx context.Context, fileName string, content io.Reader, ttlSeconds int) (*UploadResponse, error) {
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		writer.CloseWithError(writeUploadForm(form, fileName, content, ttlSeconds))
+	}()
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/api/v1/upload", body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := c.send(req, http.StatusOK)
+	// The pipe may still be waiting for the server to read the rest of the file
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result UploadResponse
+	if err := decode(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// writeUploadForm writes the multipart form of an upload
+func writeUploadForm(form *multipart.Writer, fileName string, content io.Reader, ttlSeconds int) error {
+	if ttlSeconds > 0 {
+		if err := form.WriteField("ttl_seconds", strconv.Itoa(ttlSeconds)); err != nil {
+			return err
+		}
+	}
+	part, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, content); err != nil {
+		return fmt.Errorf("failed to read upload: %w", err)
+	}
+	return form.Close()
+}
+
+// UploadFromURL has the server download a file into the upload directory
+func (c *Client) UploadFromURL(ctx context.Context, req URLUploadRequest) (*UploadResponse, error) {
+	var result UploadResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/upload/from-url", req, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreateDirectUpload returns a URL to upload a file straight to the storage bucket
+func (c *Client) CreateDirectUpload(ctx context.Context, req DirectUploadRequest) (*DirectUploadResponse, error) {
+	var result DirectUploadResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/upload/direct", req, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// InitChunkedUpload starts a chunked upload, whose chunks are sent with UploadChunk
+func (c *Client) InitChunkedUpload(ctx context.Context, req ChunkedUploadInitRequest) (*ChunkedUploadResponse, error) {
+	var result ChunkedUploadResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/upload/init", req, &result, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UploadChunk sends chunk n, from 0, of a chunked upload. Sending a chunk again replaces it.
+func (c *Client) UploadChunk(ctx context.Context, uploadID string, n int, chunk io.Reader) (*ChunkedUploadResponse, error) {
+	req, err := c.newRequest(ctx, http.MethodPut, "/api/v1/upload/"+escape(uploadID)+"/chunk/"+strconv.Itoa(n), chunk)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.send(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result ChunkedUploadResponse
+	if err := decode(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CompleteChunkedUpload assembles the chunks of an upload into a file. checksum may be empty if the upload
+// was started with one.
+func (c *Client) CompleteChunkedUpload(ctx context.Context, uploadID, checksum string) (*UploadResponse, error) {
+	var result UploadResponse
+	req := ChunkedUploadCompleteRequest{Checksum: checksum}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/upload/"+escape(uploadID)+"/complete", req, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Uploads lists the files in the upload directory, at most limit of them (0 for the server's default)
+func (c *Client) Uploads(ctx context.Context, limit int) (*UploadListResponse, error) {
+	path := "/api/v1/uploads"
+	if limit > 0 {
+		path += "?" + url.Values{"limit": {strconv.Itoa(limit)}}.Encode()
+	}
+	var result UploadListResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetUpload returns a file in the upload directory by its ID, the file name
+func (c *Client) GetUpload(ctx context.Context, id string) (*UploadedFile, error) {
+	var result UploadedFile
+	if err := c.do(ctx, http.MethodGet, "/api/v1/uploads/"+escape(id), nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteUpload deletes a file in the upload directory
+func (c *Client) DeleteUpload(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/uploads/"+escape(id), nil, nil, http.StatusNoContent)
+}
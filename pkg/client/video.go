@@ -0,0 +1,212 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// createJob posts a processing request and returns the job it created
+func (c *Client) createJob(ctx context.Context, path string, req any) (*JobResponse, error) {
+	var job JobResponse
+	if err := c.do(ctx, http.MethodPost, path, req, &job, http.StatusOK, http.StatusAccepted); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Merge starts a job that merges video segments
+func (c *Client) Merge(ctx context.Context, req MergeVideoRequest) (*JobResponse, error) {
+	return c.createJob(ctx, "/api/v1/video/merge", req)
+}
+
+// Overlay starts a job that adds image and text overlays to a video
+func (c *Client) Overlay(ctx context.Context, req OverlayRequest) (*JobResponse, error) {
+	return c.createJob(ctx, "/api/v1/video/overlay", req)
+}
+
+// Audio starts a job that adds background music to a video
+func (c *Client) Audio(ctx context.Context, req AudioRequest) (*JobResponse, error) {
+	return c.createJob(ctx, "/api/v1/video/audio", req)
+}
+
+// Process starts a job that merges segments, then adds overlays and background music
+func (c *Client) Process(ctx context.Context, req CompleteProcessRequest) (*JobResponse, error) {
+	return c.createJob(ctx, "/api/v1/video/process", req)
+}
+
+// Combine starts a job that combines videos from URLs and uploads the result to the storage backend
+func (c *Client) Combine(ctx context.Context, req CombineVideosRequest) (*JobResponse, error) {
+	return c.createJob(ctx, "/api/v1/video/combine", req)
+}
+
+// RemoveSilence starts a job that cuts the silent parts out of a video
+func (c *Client) RemoveSilence(ctx context.Context, req SilenceRequest) (*JobResponse, error) {
+	return c.createJob(ctx, "/api/v1/video/silence/remove", req)
+}
+
+// Vertical starts a job that converts a video to a vertical format
+func (c *Client) Vertical(ctx context.Context, req VerticalRequest) (*JobResponse, error) {
+	return c.createJob(ctx, "/api/v1/video/vertical", req)
+}
+
+// Transcribe starts a job that transcribes the speech of a video into an SRT or VTT subtitle file
+func (c *Client) Transcribe(ctx context.Context, req TranscribeRequest) (*JobResponse, error) {
+	return c.createJob(ctx, "/api/v1/video/transcribe", req)
+}
+
+// AutoSubtitle starts a job that transcribes the speech of a video and burns it in as styled captions
+func (c *Client) AutoSubtitle(ctx context.Context, req AutoSubtitleRequest) (*JobResponse, error) {
+	return c.createJob(ctx, "/api/v1/video/auto-subtitle", req)
+}
+
+// DetectDefects starts a job that finds the black and frozen ranges of a video; its output is a JSON
+// DefectReport
+func (c *Client) DetectDefects(ctx context.Context, req DefectsRequest) (*JobResponse, error) {
+	return c.createJob(ctx, "/api/v1/video/defects", req)
+}
+
+// Pipeline starts a job that runs the steps of a pipeline
+func (c *Client) Pipeline(ctx context.Context, req PipelineRequest) (*JobResponse, error) {
+	return c.createJob(ctx, "/api/v1/pipeline", req)
+}
+
+// RunTemplate starts a pipeline job from a job template with the given parameter values
+func (c *Client) RunTemplate(ctx context.Context, name string, variables map[string]any) (*JobResponse, error) {
+	return c.createJob(ctx, "/api/v1/templates/"+escape(name)+"/run", TemplateRunRequest{Variables: variables})
+}
+
+// DetectSilence returns the silent parts of a video
+func (c *Client) DetectSilence(ctx context.Context, req SilenceRequest) (*SilenceDetectResponse, error) {
+	var result SilenceDetectResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/video/silence/detect", req, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Probe returns the format and streams of a media file
+func (c *Client) Probe(ctx context.Context, req ProbeRequest) (*MediaProbe, error) {
+	var result MediaProbe
+	if err := c.do(ctx, http.MethodPost, "/api/v1/video/probe", req, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Quality compares an encoded video against its source and returns the VMAF, PSNR and SSIM scores
+func (c *Client) Quality(ctx context.Context, req QualityRequest) (*QualityResponse, error) {
+	var result QualityResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/video/quality", req, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Loudness returns the loudness and levels of the audio of a file, and whether it meets the target of the
+// request, if it has one
+func (c *Client) Loudness(ctx context.Context, req LoudnessRequest) (*LoudnessResponse, error) {
+	var result LoudnessResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/video/loudness", req, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Fingerprint returns the fingerprint of a video and the saved fingerprints it matches, saving it with save
+func (c *Client) Fingerprint(ctx context.Context, req FingerprintRequest) (*FingerprintResponse, error) {
+	var result FingerprintResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/video/fingerprint", req, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CompareVideos returns how similar the content of two videos is
+func (c *Client) CompareVideos(ctx context.Context, req CompareRequest) (*CompareResponse, error) {
+	var result CompareResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/video/compare", req, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Fingerprints returns the saved fingerprints, newest first
+func (c *Client) Fingerprints(ctx context.Context) (*FingerprintsResponse, error) {
+	var result FingerprintsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/fingerprints", nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteFingerprint removes a saved fingerprint
+func (c *Client) DeleteFingerprint(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/fingerprints/"+escape(id), nil, nil, http.StatusNoContent)
+}
+
+// Presets returns the named encoding presets
+func (c *Client) Presets(ctx context.Context) (*PresetsResponse, error) {
+	var result PresetsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/presets", nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Templates returns the job templates
+func (c *Client) Templates(ctx context.Context) (*TemplatesResponse, error) {
+	var result TemplatesResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/templates", nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Template returns a job template
+func (c *Client) Template(ctx context.Context, name string) (*JobTemplate, error) {
+	var result JobTemplate
+	if err := c.do(ctx, http.MethodGet, "/api/v1/templates/"+escape(name), nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Usage returns the usage of the client's API key this month
+func (c *Client) Usage(ctx context.Context) (*UsageResponse, error) {
+	var result UsageResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/usage", nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Health checks that the server is up
+func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
+	var result HealthResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/health", nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Ready returns the readiness checks of the server. A server that is not ready returns its checks along with
+// an *APIError.
+func (c *Client) Ready(ctx context.Context) (*ReadinessResponse, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/api/v1/health/ready", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(req, http.StatusOK, http.StatusServiceUnavailable)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result ReadinessResponse
+	if err := decode(resp, &result); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &result, &APIError{StatusCode: resp.StatusCode, Err: "Not ready", RequestID: resp.Header.Get("X-Request-ID")}
+	}
+	return &result, nil
+}
@@ -3,8 +3,14 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
+
+	"govid/pkg/queue"
+	"govid/pkg/scanner"
+	"govid/pkg/storage"
+	"govid/pkg/transcribe"
 )
 
 // Config holds all application configuration
@@ -13,35 +19,235 @@ type Config struct {
 	HTTPPort string `env:"HTTP_PORT" env-default:"4101"`
 	MCPPort  string `env:"MCP_PORT" env-default:"1106"`
 
-	// Authentication
-	HTTPAPIKey string `env:"HTTP_API_KEY" env-required:"true"`
-	MCPAPIKey  string `env:"MCP_API_KEY" env-required:"true"`
+	// MCP transport: "http" serves StreamableHTTP on MCPPort alongside the HTTP API,
+	// "stdio" serves MCP over stdin/stdout only (for local MCP clients)
+	MCPTransport string `env:"MCP_TRANSPORT" env-default:"http"`
+
+	// What the process runs: "all" takes requests and runs their jobs; "api" only takes requests, serving the
+	// HTTP API, MCP and the job queue, and leaves the jobs in JOBS_DIR for workers; "worker" only runs the jobs
+	// it claims from JOBS_DIR. API instances and workers must share JOBS_DIR, UPLOAD_DIR, OUTPUT_DIR and
+	// TEMP_DIR. The --mode flag overrides it.
+	RunMode string `env:"RUN_MODE" env-default:"all"`
+
+	// API instances sharing JOBS_DIR elect a leader, the only one running the cleanup, output expiry and
+	// template schedules, by holding a lease in JOBS_DIR that is renewed every third of this time. When the
+	// leader stops, another instance takes over within this time.
+	LeaderLeaseSeconds int `env:"LEADER_LEASE_SECONDS" env-default:"15"`
+
+	// What happens on startup to the jobs that were pending or processing when the process stopped: "fail"
+	// fails them as interrupted by the restart; "requeue" runs them again from the start if their local
+	// inputs still exist, and fails the others. Scheduled jobs are scheduled again either way.
+	InterruptedJobs string `env:"INTERRUPTED_JOBS" env-default:"fail"`
+
+	// Authentication. HTTP_API_KEY is an admin key named "default"; API_KEYS (name:scope:key,...)
+	// and API_KEYS_FILE (YAML or JSON) add named keys. At least one HTTP key must be configured.
+	HTTPAPIKey  string `env:"HTTP_API_KEY" env-default:""`
+	APIKeys     string `env:"API_KEYS" env-default:""`
+	APIKeysFile string `env:"API_KEYS_FILE" env-default:""`
+	MCPAPIKey   string `env:"MCP_API_KEY" env-required:"true"`
+
+	// Logging: LOG_LEVEL is trace, debug, info, warn, or error; LOG_FORMAT is console or json
+	LogLevel  string `env:"LOG_LEVEL" env-default:"info"`
+	LogFormat string `env:"LOG_FORMAT" env-default:"console"`
 
 	// FFmpeg configuration
-	FFmpegBinary string `env:"FFMPEG_BINARY" env-default:"ffmpeg"`
+	FFmpegBinary  string `env:"FFMPEG_BINARY" env-default:"ffmpeg"`
+	FFprobeBinary string `env:"FFPROBE_BINARY" env-default:"ffprobe"`
+
+	// Resource limits of each ffmpeg process: threads (0 = ffmpeg decides), niceness (0-19), I/O class
+	// (best-effort, idle, or empty to inherit), and memory and CPU limits enforced in a cgroup created for
+	// each process under FFMPEG_CGROUP_DIR, a cgroup v2 directory (Linux only)
+	FFmpegThreads     int     `env:"FFMPEG_THREADS" env-default:"0"`
+	FFmpegNice        int     `env:"FFMPEG_NICE" env-default:"0"`
+	FFmpegIOClass     string  `env:"FFMPEG_IO_CLASS" env-default:""`
+	FFmpegCgroupDir   string  `env:"FFMPEG_CGROUP_DIR" env-default:""`
+	FFmpegMemoryMaxMB int     `env:"FFMPEG_MEMORY_MAX_MB" env-default:"0"`
+	FFmpegCPUs        float64 `env:"FFMPEG_CPUS" env-default:"0"`
+
+	// Longest input (in seconds) accepted for motion interpolation, which is very slow; 0 disables the limit
+	MaxInterpolateSeconds int `env:"MAX_INTERPOLATE_SECONDS" env-default:"120"`
+
+	// Caps on the input videos of a request, checked before its job is created; 0 disables a cap
+	MaxInputVideos          int `env:"MAX_INPUT_VIDEOS" env-default:"50"`              // videos per request
+	MaxInputDurationSeconds int `env:"MAX_INPUT_DURATION_SECONDS" env-default:"14400"` // combined length after trimming
+	MaxInputWidth           int `env:"MAX_INPUT_WIDTH" env-default:"4096"`             // longer side of each video
+	MaxInputHeight          int `env:"MAX_INPUT_HEIGHT" env-default:"2160"`            // shorter side of each video
+
+	// Encoding presets file (YAML or JSON); built-in presets are always available
+	PresetsFile string `env:"PRESETS_FILE" env-default:""`
+
+	// Job templates, one file per template; templates saved through the admin API are written here
+	TemplatesDir string `env:"TEMPLATES_DIR" env-default:"./templates"`
 
 	// File storage
 	UploadDir string `env:"UPLOAD_DIR" env-default:"./uploads"`
 	OutputDir string `env:"OUTPUT_DIR" env-default:"./outputs"`
 	TempDir   string `env:"TEMP_DIR" env-default:"./temp"`
 	JobsDir   string `env:"JOBS_DIR" env-default:"./jobs"`
+	UsageDir  string `env:"USAGE_DIR" env-default:"./usage"` // monthly per-key usage records
+
+	// Saved video fingerprints, checked for duplicates by /video/fingerprint
+	FingerprintsDir string `env:"FINGERPRINTS_DIR" env-default:"./fingerprints"`
+
+	// Fonts uploaded for text overlays, which refer to them by name
+	FontsDir string `env:"FONTS_DIR" env-default:"./fonts"`
+
+	// Readiness fails when a storage directory has less free space than this
+	MinFreeDiskMB int `env:"MIN_FREE_DISK_MB" env-default:"1024"`
+
+	// Uploads: multipart requests, chunked uploads and MCP base64 files
+	MaxMergeFiles   int `env:"MAX_MERGE_FILES" env-default:"50"`       // files per request
+	MaxUploadSizeMB int `env:"MAX_UPLOAD_SIZE_MB" env-default:"10240"` // request body and total file size per request
+	MaxFileSizeMB   int `env:"MAX_FILE_SIZE_MB" env-default:"4096"`    // size of each uploaded file
+
+	// Extensions accepted for uploads (HTTP and MCP); the content of mp4, m4v, m4a, mov, webm, mkv, png, jpg,
+	// jpeg, mp3 and wav files must match their extension
+	UploadAllowedExtensions []string `env:"UPLOAD_ALLOWED_EXTENSIONS" env-separator:"," env-default:"mp4,m4v,mov,webm,mkv,png,jpg,jpeg,gif,mp3,wav,m4a"`
+
+	// Directories, besides UploadDir, TempDir and OutputDir, whose files requests may name as file_path or
+	// video_path inputs; paths anywhere else are refused
+	InputPathRoots []string `env:"INPUT_PATH_ROOTS" env-separator:","`
+
+	// Default TTL of uploads in seconds, after which they are deleted and jobs can no longer use them; requests
+	// can set their own with ttl_seconds. 0 keeps uploads until the retention cleanup.
+	UploadTTLSeconds int `env:"UPLOAD_TTL_SECONDS" env-default:"0"`
+
+	// Content scanning of uploads: clamd (a ClamAV daemon socket) or http (an external scanner); empty disables it.
+	// Uploads that fail the scan are moved to QUARANTINE_DIR, and while scanning is enabled jobs only accept
+	// uploaded files that were scanned clean.
+	UploadScanBackend        string `env:"UPLOAD_SCAN_BACKEND" env-default:""`
+	ClamdAddress             string `env:"CLAMD_ADDRESS" env-default:"unix:///var/run/clamav/clamd.ctl"` // unix:///path or tcp://host:port
+	UploadScanURL            string `env:"UPLOAD_SCAN_URL" env-default:""`                               // POST endpoint of the http scanner
+	UploadScanTimeoutSeconds int    `env:"UPLOAD_SCAN_TIMEOUT_SECONDS" env-default:"120"`
+	QuarantineDir            string `env:"QUARANTINE_DIR" env-default:"./quarantine"`
+
+	// Speech-to-text of transcription jobs: whisper (whisper.cpp, run on the extracted audio) or http (an
+	// OpenAI-compatible transcription API); empty disables transcription
+	TranscribeBackend string `env:"TRANSCRIBE_BACKEND" env-default:""`
+	WhisperBinary     string `env:"WHISPER_BINARY" env-default:"whisper-cli"` // whisper.cpp command line binary
+	WhisperModel      string `env:"WHISPER_MODEL" env-default:""`             // ggml model file, such as models/ggml-base.bin
+	WhisperThreads    int    `env:"WHISPER_THREADS" env-default:"0"`          // 0 uses the default of whisper.cpp
+	TranscribeURL     string `env:"TRANSCRIBE_URL" env-default:""`            // POST endpoint of the http backend
+	TranscribeAPIKey  string `env:"TRANSCRIBE_API_KEY" env-default:""`        // sent as a bearer token
+	TranscribeModel   string `env:"TRANSCRIBE_MODEL" env-default:"whisper-1"`
+
+	// Largest chunk of chunked uploads, which is also the default chunk size; whole files follow MAX_UPLOAD_SIZE_MB
+	MaxChunkSizeMB int `env:"MAX_CHUNK_SIZE_MB" env-default:"64"`
+
+	// Largest file (in MB) fetched from a URL, and largest total of the downloads of one combine job; 0 disables a limit
+	MaxDownloadSizeMB  int `env:"MAX_DOWNLOAD_SIZE_MB" env-default:"2048"`
+	MaxDownloadTotalMB int `env:"MAX_DOWNLOAD_TOTAL_MB" env-default:"10240"`
+
+	// Downloads from URLs may not reach loopback, private, or link-local addresses unless private networks
+	// are allowed; hosts on the allowlist ("*.example.com" for subdomains) are exempt
+	DownloadAllowPrivate bool     `env:"DOWNLOAD_ALLOW_PRIVATE_NETWORKS" env-default:"false"`
+	DownloadAllowedHosts []string `env:"DOWNLOAD_ALLOWED_HOSTS" env-separator:","`
+
+	// Video downloads running at once and their combined rate, across all jobs; 0 disables a limit
+	MaxParallelDownloads      int   `env:"MAX_PARALLEL_DOWNLOADS" env-default:"4"`
+	DownloadMaxBytesPerSecond int64 `env:"DOWNLOAD_MAX_BYTES_PER_SECOND" env-default:"0"`
+
+	// yt-dlp fetches combine and merge inputs from platform pages (YouTube, Vimeo, TikTok, ...) when YTDLP_BINARY is set
+	YTDLPBinary string   `env:"YTDLP_BINARY" env-default:""`
+	YTDLPFormat string   `env:"YTDLP_FORMAT" env-default:"bv*[ext=mp4]+ba[ext=m4a]/b[ext=mp4]/bv*+ba/b"`
+	YTDLPHosts  []string `env:"YTDLP_HOSTS" env-separator:"," env-default:"youtube.com,youtu.be,vimeo.com,tiktok.com,dailymotion.com"`
+
+	// Retries of failed video downloads for combine jobs, with exponential backoff from the initial delay
+	DownloadRetries      int `env:"DOWNLOAD_RETRIES" env-default:"3"`
+	DownloadRetryDelayMS int `env:"DOWNLOAD_RETRY_DELAY_MS" env-default:"1000"`
 
 	// Job configuration
 	MaxConcurrentJobs      int `env:"MAX_CONCURRENT_JOBS" env-default:"3"`
-	JobTimeout             int `env:"JOB_TIMEOUT" env-default:"3600"` // in seconds
+	JobTimeout             int `env:"JOB_TIMEOUT" env-default:"3600"`  // in seconds
+	MaxJobTimeout          int `env:"MAX_JOB_TIMEOUT" env-default:"0"` // largest timeout_seconds a request may set; 0 means JOB_TIMEOUT
 	ShutdownTimeoutSeconds int `env:"SHUTDOWN_TIMEOUT_SECONDS" env-default:"30"`
 
-	// S3/MinIO configuration
-	S3Endpoint  string `env:"S3_ENDPOINT" env-required:"true"`
-	S3AccessKey string `env:"S3_ACCESS_KEY" env-required:"true"`
-	S3SecretKey string `env:"S3_SECRET_KEY" env-required:"true"`
-	S3Bucket    string `env:"S3_BUCKET" env-required:"true"`
+	// Seconds the outputs of a completed job are kept before they are deleted and the job expires, unless a
+	// request sets ttl_seconds; 0 keeps them until the cleanup removes them
+	OutputTTLSeconds int `env:"OUTPUT_TTL_SECONDS" env-default:"0"`
+
+	// Storage backend for combine outputs and S3 links: "s3" (S3 or MinIO), "gcs" (Google Cloud Storage),
+	// or "local" (OutputDir, served by the API through signed download links)
+	StorageBackend string `env:"STORAGE_BACKEND" env-default:"s3"`
+
+	// S3/MinIO configuration, required by the s3 backend
+	S3Endpoint  string `env:"S3_ENDPOINT" env-default:""`
+	S3AccessKey string `env:"S3_ACCESS_KEY" env-default:""`
+	S3SecretKey string `env:"S3_SECRET_KEY" env-default:""`
+	S3Bucket    string `env:"S3_BUCKET" env-default:""`
 	S3Region    string `env:"S3_REGION" env-default:"us-east-1"`
 	S3UseSSL    bool   `env:"S3_USE_SSL" env-default:"true"`
 
+	// S3 multipart uploads: part size (at least 5 MB; 0 lets the client choose), parts uploaded in parallel,
+	// and retries of a failed upload with exponential backoff
+	S3PartSizeMB    int `env:"S3_PART_SIZE_MB" env-default:"64"`
+	S3UploadThreads int `env:"S3_UPLOAD_THREADS" env-default:"4"`
+	S3UploadRetries int `env:"S3_UPLOAD_RETRIES" env-default:"3"`
+
+	// Google Cloud Storage configuration for the gcs backend; without a credentials file,
+	// Application Default Credentials are used
+	GCSBucket          string `env:"GCS_BUCKET" env-default:""`
+	GCSCredentialsFile string `env:"GCS_CREDENTIALS_FILE" env-default:""`
+
+	// Local backend: external base URL of the API for download links (empty gives relative links) and the key
+	// links are signed with (empty uses a random key, so links stop working on restart; only allowed in the
+	// all run mode, see CheckSharedSettings)
+	PublicBaseURL      string `env:"PUBLIC_BASE_URL" env-default:""`
+	LocalStorageSecret string `env:"LOCAL_STORAGE_SECRET" env-default:""`
+
+	// Return presigned URLs of uploaded outputs instead of plain object URLs, for private S3 or GCS buckets.
+	// The TTL applies to presigned URLs and to the signed links of the local backend.
+	StoragePresign        bool `env:"STORAGE_PRESIGN" env-default:"false"`
+	StorageLinkTTLSeconds int  `env:"STORAGE_LINK_TTL_SECONDS" env-default:"86400"`
+
+	// Object key of uploaded outputs, with the placeholders {job_id}, {api_key}, {original_name}, {filename},
+	// {ext}, {date}, {year}, {month}, and {day}; requests can override it with output_key
+	S3KeyTemplate string `env:"S3_KEY_TEMPLATE" env-default:"combined/{job_id}/{filename}"`
+
+	// Direct uploads to the s3 or gcs bucket: object key of the uploaded inputs, with the placeholders of
+	// S3_KEY_TEMPLATE ({job_id} is the upload ID and {filename} the ID with the file's extension), and how
+	// long upload URLs stay valid
+	S3UploadKeyTemplate     string `env:"S3_UPLOAD_KEY_TEMPLATE" env-default:"uploads/{date}/{filename}"`
+	StorageUploadTTLSeconds int    `env:"STORAGE_UPLOAD_TTL_SECONDS" env-default:"3600"`
+
+	// Upload a poster thumbnail and a metadata.json (duration, resolution, checksum) next to each output;
+	// requests can override it with sidecars
+	StorageSidecars bool `env:"STORAGE_SIDECARS" env-default:"false"`
+
+	// Cache-Control of uploaded outputs when the request sets none (empty sends no header)
+	StorageCacheControl string `env:"STORAGE_CACHE_CONTROL" env-default:""`
+
+	// Buckets of the s3 or gcs backend, besides S3_BUCKET or GCS_BUCKET, that requests may upload outputs to
+	// with bucket; the credentials must be allowed to write them
+	StorageAllowedBuckets []string `env:"STORAGE_ALLOWED_BUCKETS" env-separator:","`
+
+	// OpenTelemetry tracing, exported over OTLP/HTTP
+	TracingEnabled     bool    `env:"TRACING_ENABLED" env-default:"false"`
+	OTLPEndpoint       string  `env:"OTEL_EXPORTER_OTLP_ENDPOINT" env-default:""` // e.g. http://otel-collector:4318
+	TracingServiceName string  `env:"OTEL_SERVICE_NAME" env-default:"govid"`
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO" env-default:"1.0"` // fraction of traces recorded
+
+	// Job intake from a message queue besides HTTP: nats, rabbitmq, or sqs; empty disables it. Requests are
+	// consumed from QUEUE_REQUESTS (a NATS subject, RabbitMQ queue, or SQS queue URL), and the completion event
+	// of every job is published to QUEUE_EVENTS, if set. QUEUE_URL is the server of nats and rabbitmq.
+	QueueBackend  string `env:"QUEUE_BACKEND" env-default:""`
+	QueueURL      string `env:"QUEUE_URL" env-default:""`
+	QueueRequests string `env:"QUEUE_REQUESTS" env-default:""`
+	QueueEvents   string `env:"QUEUE_EVENTS" env-default:""`
+	QueueGroup    string `env:"QUEUE_GROUP" env-default:"govid"` // NATS queue group the instances share requests in
+	QueueRegion   string `env:"QUEUE_REGION" env-default:""`     // AWS region of the SQS queues; empty uses AWS_REGION
+
 	// Cleanup configuration
 	CleanupEnabled       bool `env:"CLEANUP_ENABLED" env-default:"true"`
 	CleanupRetentionDays int  `env:"CLEANUP_RETENTION_DAYS" env-default:"7"`
+
+	// Delete the uploaded inputs of a job as soon as it completes; requests can override it with cleanup_inputs
+	CleanupInputs bool `env:"CLEANUP_INPUTS" env-default:"false"`
+
+	// Disk quotas in GB; every 5 minutes the oldest finished-job outputs and uploads are evicted from a directory
+	// over its quota, whatever their age. 0 disables a quota. Requires CLEANUP_ENABLED.
+	OutputDirMaxGB int `env:"OUTPUT_DIR_MAX_GB" env-default:"0"`
+	UploadDirMaxGB int `env:"UPLOAD_DIR_MAX_GB" env-default:"0"`
 }
 
 // Load loads configuration from environment variables with defaults
@@ -53,8 +259,128 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
+	if err := CheckRunMode(cfg.RunMode); err != nil {
+		return nil, err
+	}
+	if err := cfg.CheckSharedSettings(); err != nil {
+		return nil, err
+	}
+	if cfg.InterruptedJobs != InterruptedFail && cfg.InterruptedJobs != InterruptedRequeue {
+		return nil, fmt.Errorf("invalid INTERRUPTED_JOBS %q: must be fail or requeue", cfg.InterruptedJobs)
+	}
+	if cfg.LeaderLeaseSeconds < 3 {
+		return nil, fmt.Errorf("invalid LEADER_LEASE_SECONDS %d: must be at least 3", cfg.LeaderLeaseSeconds)
+	}
+
+	if cfg.MCPTransport != "http" && cfg.MCPTransport != "stdio" {
+		return nil, fmt.Errorf("invalid MCP_TRANSPORT %q: must be http or stdio", cfg.MCPTransport)
+	}
+
+	if cfg.MaxInputVideos < 0 || cfg.MaxInputDurationSeconds < 0 || cfg.MaxInputWidth < 0 || cfg.MaxInputHeight < 0 {
+		return nil, fmt.Errorf("invalid input limits: MAX_INPUT_VIDEOS, MAX_INPUT_DURATION_SECONDS, MAX_INPUT_WIDTH and MAX_INPUT_HEIGHT must not be negative")
+	}
+
+	if cfg.MaxJobTimeout == 0 {
+		cfg.MaxJobTimeout = cfg.JobTimeout
+	}
+	if cfg.JobTimeout <= 0 || cfg.MaxJobTimeout < cfg.JobTimeout {
+		return nil, fmt.Errorf("invalid job timeouts: JOB_TIMEOUT must be positive and MAX_JOB_TIMEOUT at least JOB_TIMEOUT")
+	}
+	if cfg.OutputTTLSeconds < 0 {
+		return nil, fmt.Errorf("invalid OUTPUT_TTL_SECONDS: must not be negative")
+	}
+
+	if cfg.FFmpegThreads < 0 || cfg.FFmpegNice < 0 || cfg.FFmpegNice > 19 || cfg.FFmpegMemoryMaxMB < 0 || cfg.FFmpegCPUs < 0 {
+		return nil, fmt.Errorf("invalid FFmpeg limits: FFMPEG_NICE must be 0-19, FFMPEG_THREADS, FFMPEG_MEMORY_MAX_MB and FFMPEG_CPUS must not be negative")
+	}
+	if cfg.FFmpegIOClass != "" && cfg.FFmpegIOClass != "best-effort" && cfg.FFmpegIOClass != "idle" {
+		return nil, fmt.Errorf("invalid FFMPEG_IO_CLASS %q: must be best-effort or idle", cfg.FFmpegIOClass)
+	}
+	if (cfg.FFmpegMemoryMaxMB > 0 || cfg.FFmpegCPUs > 0) && cfg.FFmpegCgroupDir == "" {
+		return nil, fmt.Errorf("FFMPEG_MEMORY_MAX_MB and FFMPEG_CPUS require FFMPEG_CGROUP_DIR")
+	}
+
+	switch cfg.StorageBackend {
+	case "s3":
+		if cfg.S3Endpoint == "" || cfg.S3AccessKey == "" || cfg.S3SecretKey == "" || cfg.S3Bucket == "" {
+			return nil, fmt.Errorf("the s3 storage backend requires S3_ENDPOINT, S3_ACCESS_KEY, S3_SECRET_KEY, and S3_BUCKET")
+		}
+		if cfg.S3PartSizeMB < 0 || (cfg.S3PartSizeMB > 0 && cfg.S3PartSizeMB < 5) || cfg.S3UploadThreads < 0 || cfg.S3UploadRetries < 0 {
+			return nil, fmt.Errorf("invalid S3 upload settings: S3_PART_SIZE_MB must be 0 or at least 5, S3_UPLOAD_THREADS and S3_UPLOAD_RETRIES must not be negative")
+		}
+	case "gcs":
+		if cfg.GCSBucket == "" {
+			return nil, fmt.Errorf("the gcs storage backend requires GCS_BUCKET")
+		}
+	case "local":
+	default:
+		return nil, fmt.Errorf("invalid STORAGE_BACKEND %q: must be s3, gcs, or local", cfg.StorageBackend)
+	}
+
+	if err := storage.ValidateKeyTemplate(cfg.S3KeyTemplate); err != nil {
+		return nil, fmt.Errorf("invalid S3_KEY_TEMPLATE %q: %w", cfg.S3KeyTemplate, err)
+	}
+
+	if err := storage.ValidateKeyTemplate(cfg.S3UploadKeyTemplate); err != nil {
+		return nil, fmt.Errorf("invalid S3_UPLOAD_KEY_TEMPLATE %q: %w", cfg.S3UploadKeyTemplate, err)
+	}
+
+	// S3 and GCS presigned URLs are valid for at most 7 days
+	if cfg.StorageUploadTTLSeconds <= 0 || cfg.StorageUploadTTLSeconds > 7*24*60*60 {
+		return nil, fmt.Errorf("invalid STORAGE_UPLOAD_TTL_SECONDS %d: must be positive and at most 604800", cfg.StorageUploadTTLSeconds)
+	}
+	if cfg.StorageLinkTTLSeconds <= 0 || (cfg.StorageBackend != "local" && cfg.StorageLinkTTLSeconds > 7*24*60*60) {
+		return nil, fmt.Errorf("invalid STORAGE_LINK_TTL_SECONDS %d: must be positive and at most 604800 for s3 and gcs", cfg.StorageLinkTTLSeconds)
+	}
+
+	if cfg.TracingSampleRatio < 0 || cfg.TracingSampleRatio > 1 {
+		return nil, fmt.Errorf("invalid TRACING_SAMPLE_RATIO %v: must be between 0 and 1", cfg.TracingSampleRatio)
+	}
+
+	if cfg.DownloadRetries < 0 || cfg.DownloadRetryDelayMS < 0 {
+		return nil, fmt.Errorf("invalid download retry settings: DOWNLOAD_RETRIES and DOWNLOAD_RETRY_DELAY_MS must not be negative")
+	}
+
+	if cfg.MaxParallelDownloads < 0 || cfg.DownloadMaxBytesPerSecond < 0 {
+		return nil, fmt.Errorf("invalid download limits: MAX_PARALLEL_DOWNLOADS and DOWNLOAD_MAX_BYTES_PER_SECOND must not be negative")
+	}
+
+	if cfg.MaxUploadSizeMB <= 0 || cfg.MaxFileSizeMB <= 0 {
+		return nil, fmt.Errorf("invalid upload limits: MAX_UPLOAD_SIZE_MB and MAX_FILE_SIZE_MB must be positive")
+	}
+
+	if cfg.UploadTTLSeconds < 0 {
+		return nil, fmt.Errorf("invalid UPLOAD_TTL_SECONDS %d: must not be negative", cfg.UploadTTLSeconds)
+	}
+
+	if cfg.UploadScanTimeoutSeconds <= 0 {
+		return nil, fmt.Errorf("invalid UPLOAD_SCAN_TIMEOUT_SECONDS %d: must be positive", cfg.UploadScanTimeoutSeconds)
+	}
+	if _, err := scanner.New(cfg.ScannerConfig()); err != nil {
+		return nil, fmt.Errorf("invalid upload scan settings: %w", err)
+	}
+
+	if _, err := transcribe.New(cfg.TranscribeConfig()); err != nil {
+		return nil, fmt.Errorf("invalid transcribe settings: %w", err)
+	}
+
+	if err := cfg.QueueConfig().Validate(); err != nil {
+		return nil, fmt.Errorf("invalid queue settings: %w", err)
+	}
+
+	if cfg.OutputDirMaxGB < 0 || cfg.UploadDirMaxGB < 0 {
+		return nil, fmt.Errorf("invalid disk quotas: OUTPUT_DIR_MAX_GB and UPLOAD_DIR_MAX_GB must not be negative")
+	}
+
+	if cfg.MaxChunkSizeMB <= 0 {
+		return nil, fmt.Errorf("invalid MAX_CHUNK_SIZE_MB %d: must be positive", cfg.MaxChunkSizeMB)
+	}
+
 	// Create necessary directories
-	dirs := []string{cfg.UploadDir, cfg.OutputDir, cfg.TempDir, cfg.JobsDir}
+	dirs := []string{cfg.UploadDir, cfg.OutputDir, cfg.TempDir, cfg.JobsDir, cfg.UsageDir, cfg.TemplatesDir, cfg.FingerprintsDir, cfg.FontsDir}
+	if cfg.UploadScanBackend != "" {
+		dirs = append(dirs, cfg.QuarantineDir)
+	}
 	for _, dir := range dirs {
 		if err := os.MkdirAll(dir, 0o755); err != nil {
 			return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
@@ -63,3 +389,74 @@ func Load() (*Config, error) {
 
 	return &cfg, nil
 }
+
+// Handling of interrupted jobs, see INTERRUPTED_JOBS
+const (
+	InterruptedFail    = "fail"
+	InterruptedRequeue = "requeue"
+)
+
+// Run modes of RUN_MODE and the --mode flag
+const (
+	ModeAll    = "all"
+	ModeAPI    = "api"
+	ModeWorker = "worker"
+)
+
+// CheckRunMode fails for a run mode other than all, api, or worker
+func CheckRunMode(mode string) error {
+	if mode != ModeAll && mode != ModeAPI && mode != ModeWorker {
+		return fmt.Errorf("invalid run mode %q: must be all, api, or worker", mode)
+	}
+	return nil
+}
+
+// CheckSharedSettings fails when a split deployment would not share settings that its processes must agree
+// on. Without LOCAL_STORAGE_SECRET each process signs local download links with a random key of its own, so
+// an API instance would reject the links signed by the worker that delivered a job.
+func (c *Config) CheckSharedSettings() error {
+	if c.RunMode != ModeAll && c.StorageBackend == "local" && c.LocalStorageSecret == "" {
+		return fmt.Errorf("run mode %s with the local storage backend requires LOCAL_STORAGE_SECRET", c.RunMode)
+	}
+	return nil
+}
+
+// ScannerConfig returns the settings of the upload scanner
+func (c *Config) ScannerConfig() scanner.Config {
+	return scanner.Config{
+		Backend:      c.UploadScanBackend,
+		ClamdAddress: c.ClamdAddress,
+		URL:          c.UploadScanURL,
+		Timeout:      time.Duration(c.UploadScanTimeoutSeconds) * time.Second,
+	}
+}
+
+// TranscribeConfig returns the settings of the transcriber
+func (c *Config) TranscribeConfig() transcribe.Config {
+	return transcribe.Config{
+		Backend:        c.TranscribeBackend,
+		WhisperBinary:  c.WhisperBinary,
+		WhisperModel:   c.WhisperModel,
+		WhisperThreads: c.WhisperThreads,
+		URL:            c.TranscribeURL,
+		APIKey:         c.TranscribeAPIKey,
+		Model:          c.TranscribeModel,
+	}
+}
+
+// QueueConfig returns the settings of the job queue
+func (c *Config) QueueConfig() queue.Config {
+	return queue.Config{
+		Backend:  c.QueueBackend,
+		URL:      c.QueueURL,
+		Requests: c.QueueRequests,
+		Events:   c.QueueEvents,
+		Group:    c.QueueGroup,
+		Region:   c.QueueRegion,
+	}
+}
+
+// InputRoots returns the directories whose files requests may name as inputs
+func (c *Config) InputRoots() []string {
+	return append([]string{c.UploadDir, c.TempDir, c.OutputDir}, c.InputPathRoots...)
+}
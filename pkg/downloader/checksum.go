@@ -0,0 +1,90 @@
+package downloader
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned when a downloaded file does not have its expected checksum
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Checksum is the expected digest of a download. The zero value skips verification.
+type Checksum struct {
+	Algorithm string // sha256 or md5
+	Sum       []byte
+}
+
+// checksumSizes maps the supported algorithms to their digest length in bytes
+var checksumSizes = map[string]int{
+	"sha256": sha256.Size,
+	"md5":    md5.Size,
+}
+
+// ParseChecksum parses a checksum written as sha256:<hex> or md5:<hex>. An empty string is the zero Checksum.
+func ParseChecksum(s string) (Checksum, error) {
+	if s == "" {
+		return Checksum{}, nil
+	}
+	algorithm, digest, ok := strings.Cut(s, ":")
+	algorithm = strings.ToLower(algorithm)
+	size, known := checksumSizes[algorithm]
+	if !ok || !known {
+		return Checksum{}, fmt.Errorf("invalid checksum %q: must be sha256:<hex> or md5:<hex>", s)
+	}
+	sum, err := hex.DecodeString(digest)
+	if err != nil || len(sum) != size {
+		return Checksum{}, fmt.Errorf("invalid checksum %q: %s needs %d hex digits", s, algorithm, size*2)
+	}
+	return Checksum{Algorithm: algorithm, Sum: sum}, nil
+}
+
+// String returns the checksum in the form ParseChecksum accepts
+func (c Checksum) String() string {
+	return c.Algorithm + ":" + hex.EncodeToString(c.Sum)
+}
+
+// NewHash returns a hash of the checksum's algorithm, or nil for the zero Checksum
+func (c Checksum) NewHash() hash.Hash {
+	switch c.Algorithm {
+	case "sha256":
+		return sha256.New()
+	case "md5":
+		return md5.New()
+	}
+	return nil
+}
+
+// Match compares a digest computed with NewHash with the expected one
+func (c Checksum) Match(sum []byte) error {
+	if !bytes.Equal(sum, c.Sum) {
+		return fmt.Errorf("%w: expected %s, got %s:%s", ErrChecksumMismatch, c, c.Algorithm, hex.EncodeToString(sum))
+	}
+	return nil
+}
+
+// Verify hashes the file at path and compares it with the expected digest
+func (c Checksum) Verify(path string) error {
+	h := c.NewHash()
+	if h == nil {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read download: %w", err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(h, file); err != nil {
+		return fmt.Errorf("failed to read download: %w", err)
+	}
+
+	return c.Match(h.Sum(nil))
+}
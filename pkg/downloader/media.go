@@ -0,0 +1,162 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+
+	"govid/pkg/tracing"
+)
+
+var (
+	// ErrTooLarge is returned when a download exceeds its size limit
+	ErrTooLarge = errors.New("file too large")
+	// ErrNotMedia is returned by DownloadMedia for responses that are not video, audio, or image files
+	ErrNotMedia = errors.New("not a media file")
+)
+
+// mediaTypes maps accepted content types to the extension used when saving
+var mediaTypes = map[string]string{
+	"video/mp4":        ".mp4",
+	"video/quicktime":  ".mov",
+	"video/webm":       ".webm",
+	"video/x-matroska": ".mkv",
+	"video/x-msvideo":  ".avi",
+	"video/x-flv":      ".flv",
+	"video/mp2t":       ".ts",
+	"video/mpeg":       ".mpg",
+	"video/ogg":        ".ogv",
+	"video/3gpp":       ".3gp",
+	"audio/mpeg":       ".mp3",
+	"audio/mp4":        ".m4a",
+	"audio/aac":        ".aac",
+	"audio/wav":        ".wav",
+	"audio/x-wav":      ".wav",
+	"audio/ogg":        ".ogg",
+	"audio/flac":       ".flac",
+	"image/png":        ".png",
+	"image/jpeg":       ".jpg",
+	"image/gif":        ".gif",
+	"image/webp":       ".webp",
+}
+
+// mediaExtensions lists the file extensions accepted for downloads
+var mediaExtensions = map[string]bool{
+	".mp4": true, ".mov": true, ".webm": true, ".mkv": true, ".avi": true,
+	".flv": true, ".ts": true, ".mpg": true, ".ogv": true, ".3gp": true, ".m4v": true,
+	".mp3": true, ".m4a": true, ".aac": true, ".wav": true, ".ogg": true, ".flac": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true,
+}
+
+// MediaFile describes a downloaded media file
+type MediaFile struct {
+	FileName    string
+	FilePath    string
+	FileSize    int64
+	ContentType string
+}
+
+// DownloadMedia fetches a video, audio, or image file from an http(s) URL into dir under a unique name.
+// The file type is taken from the Content-Type header, falling back to the URL extension for generic
+// binary responses. Downloads larger than maxBytes fail with ErrTooLarge; 0 disables the limit.
+// URLs the policy forbids fail with ErrURLNotAllowed.
+func DownloadMedia(ctx context.Context, policy *URLPolicy, rawURL, dir string, maxBytes int64) (file *MediaFile, err error) {
+	if err := policy.CheckURL(rawURL); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+
+	ctx, span := tracing.Start(ctx, "download.media", attribute.String("url.full", rawURL))
+	defer func() { tracing.End(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := policy.Client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	ext, err := mediaExtension(contentType, u.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxBytes > 0 && resp.ContentLength > maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes exceeds limit of %d bytes", ErrTooLarge, resp.ContentLength, maxBytes)
+	}
+
+	filename := uuid.New().String() + ext
+	filePath := filepath.Join(dir, filename)
+
+	out, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+
+	body := io.Reader(resp.Body)
+	if maxBytes > 0 {
+		body = io.LimitReader(resp.Body, maxBytes+1)
+	}
+	written, err := io.Copy(out, body)
+	out.Close()
+	if err == nil && maxBytes > 0 && written > maxBytes {
+		err = fmt.Errorf("%w: exceeds limit of %d bytes", ErrTooLarge, maxBytes)
+	}
+	if err != nil {
+		os.Remove(filePath)
+		if errors.Is(err, ErrTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int64("download.size_bytes", written))
+	return &MediaFile{
+		FileName:    filename,
+		FilePath:    filePath,
+		FileSize:    written,
+		ContentType: contentType,
+	}, nil
+}
+
+// mediaExtension picks the extension to save a download with, rejecting non-media content types
+func mediaExtension(contentType, urlPath string) (string, error) {
+	urlExt := strings.ToLower(path.Ext(urlPath))
+
+	if ext, ok := mediaTypes[contentType]; ok {
+		return ext, nil
+	}
+
+	switch contentType {
+	case "", "application/octet-stream", "binary/octet-stream":
+		if mediaExtensions[urlExt] {
+			return urlExt, nil
+		}
+		return "", fmt.Errorf("%w: cannot determine media type: no content type and unsupported extension %q", ErrNotMedia, urlExt)
+	}
+
+	return "", fmt.Errorf("%w: unsupported content type %q: only video, audio, and image files can be downloaded", ErrNotMedia, contentType)
+}
@@ -0,0 +1,56 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// objectSchemes are the URL schemes of inputs read from object storage, as in s3://bucket/key
+var objectSchemes = []string{"s3", "gs"}
+
+// ObjectStore reads objects from the configured storage backend with its credentials
+type ObjectStore interface {
+	// Open returns a reader for an object and its size
+	Open(ctx context.Context, bucket, key string) (io.ReadCloser, int64, error)
+	// Scheme is the URL scheme of the store's objects, s3 or gs
+	Scheme() string
+}
+
+// IsObjectURL reports whether rawURL is an s3:// or gs:// object URL rather than an http(s) URL
+func IsObjectURL(rawURL string) bool {
+	for _, scheme := range objectSchemes {
+		if strings.HasPrefix(strings.ToLower(rawURL), scheme+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseObjectURL splits a scheme://bucket/key URL into its scheme, bucket, and key
+func parseObjectURL(rawURL string) (scheme, bucket, key string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || strings.TrimPrefix(u.Path, "/") == "" {
+		return "", "", "", fmt.Errorf("%w: %q must have the form %s://bucket/key", ErrURLNotAllowed, rawURL, strings.SplitN(rawURL, ":", 2)[0])
+	}
+	return strings.ToLower(u.Scheme), u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// fetchObject copies an object into out. The storage clients retry failed requests themselves.
+func (d *VideoDownloader) fetchObject(ctx context.Context, rawURL string, out *os.File, limits *sizeLimits) (int64, error) {
+	_, bucket, key, err := parseObjectURL(rawURL)
+	if err != nil {
+		return 0, err
+	}
+
+	object, size, err := d.opts.Objects.Open(ctx, bucket, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", rawURL, err)
+	}
+	defer object.Close()
+
+	return d.copyBody(ctx, rawURL, object, size, out, 0, limits)
+}
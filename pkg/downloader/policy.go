@@ -0,0 +1,146 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrURLNotAllowed is returned for URLs the policy forbids, such as ones pointing into a private network
+var ErrURLNotAllowed = errors.New("URL not allowed")
+
+// nonPublicRanges are ranges netip does not count as private or local: "this network" (RFC 791)
+// and carrier-grade NAT (RFC 6598)
+var nonPublicRanges = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// URLPolicy decides which URLs downloads may fetch. Only http and https URLs are allowed, and unless
+// private networks are allowed, hosts that resolve to loopback, private, link-local, or other non-public
+// addresses are refused. Hosts on the allowlist skip the address check.
+type URLPolicy struct {
+	allowPrivate bool
+	allowedHosts []string // lowercase host names; "*.example.com" matches subdomains of example.com
+	client       *http.Client
+}
+
+// NewURLPolicy creates a URL policy. allowPrivate disables the address check for all hosts.
+func NewURLPolicy(allowPrivate bool, allowedHosts []string) *URLPolicy {
+	p := &URLPolicy{allowPrivate: allowPrivate}
+	for _, host := range allowedHosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			p.allowedHosts = append(p.allowedHosts, host)
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would make the connection on our behalf, out of reach of the address check
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return p.dial(ctx, dialer, network, addr)
+	}
+	p.client = &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return p.CheckURL(req.URL.String())
+		},
+	}
+	return p
+}
+
+// Client returns an HTTP client that only connects to addresses the policy allows, including after redirects
+func (p *URLPolicy) Client() *http.Client {
+	return p.client
+}
+
+// CheckURL validates a URL before it is fetched. Host names are only checked against the allowlist here;
+// the addresses they resolve to are checked when connecting.
+func (p *URLPolicy) CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%w: %q must be an http or https URL", ErrURLNotAllowed, rawURL)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if p.allowPrivate || p.hostAllowed(host) {
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s is a local host", ErrURLNotAllowed, host)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !publicAddr(addr) {
+		return fmt.Errorf("%w: %s is not a public address", ErrURLNotAllowed, host)
+	}
+	return nil
+}
+
+// hostAllowed reports whether host is on the allowlist
+func (p *URLPolicy) hostAllowed(host string) bool {
+	for _, allowed := range p.allowedHosts {
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// dial connects to addr, refusing hosts that resolve to non-public addresses. The checked address is
+// the one dialled, so a host cannot pass the check and then resolve elsewhere.
+func (p *URLPolicy) dial(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if p.allowPrivate || p.hostAllowed(strings.ToLower(host)) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range addrs {
+		if !publicAddr(ip) {
+			return nil, fmt.Errorf("%w: %s resolves to %s, which is not a public address", ErrURLNotAllowed, host, ip)
+		}
+	}
+
+	var dialErr error
+	for _, ip := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		dialErr = err
+	}
+	return nil, dialErr
+}
+
+// publicAddr reports whether addr is a globally routable unicast address
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicRanges {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,160 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"govid/pkg/logger"
+)
+
+// maxRetryDelay caps the exponential backoff between download attempts
+const maxRetryDelay = 30 * time.Second
+
+// RetryPolicy controls how failed downloads are retried
+type RetryPolicy struct {
+	MaxRetries int           // attempts after the first; 0 disables retries
+	Delay      time.Duration // wait before the first retry, doubled after each further failure
+}
+
+// statusError is a download that failed with an unexpected HTTP status
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return "bad status: " + e.status
+}
+
+// retryable reports whether a failed attempt may succeed when repeated: network errors, timeouts,
+// rate limiting, and server errors are retried, other client errors and cancellation are not
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrTooLarge) || errors.Is(err, ErrNotVideo) || errors.Is(err, ErrURLNotAllowed) {
+		return false
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusTooManyRequests || se.code == http.StatusRequestTimeout
+	}
+	return true
+}
+
+// backoff returns the wait before retry number attempt (starting at 0)
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.Delay
+	for i := 0; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// fetchWithRetry downloads url into out, which must be empty, and returns the number of bytes written.
+// After a failed attempt that already wrote data, the next attempt asks for the rest with a Range request;
+// servers that ignore the range send the whole file again, which replaces the partial one.
+// Responses that are not videos or that exceed limits fail without retrying. contentType receives the
+// Content-Type of the response the file was started from.
+func (d *VideoDownloader) fetchWithRetry(ctx context.Context, url string, out *os.File, limits *sizeLimits, contentType *string) (int64, error) {
+	policy := d.opts.Retry
+	var written int64
+	for attempt := 0; ; attempt++ {
+		var err error
+		written, err = d.fetch(ctx, url, out, written, limits, contentType)
+		if err == nil {
+			return written, nil
+		}
+		if attempt >= policy.MaxRetries || !retryable(ctx, err) {
+			return written, err
+		}
+
+		delay := policy.backoff(attempt)
+		logger.FromContext(ctx).Warn("Download of %s failed (attempt %d of %d), retrying in %s: %v",
+			url, attempt+1, policy.MaxRetries+1, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return written, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// fetch makes one download attempt, resuming at offset when it is not 0, and returns the total size written
+func (d *VideoDownloader) fetch(ctx context.Context, url string, out *os.File, offset int64, limits *sizeLimits, contentType *string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return offset, fmt.Errorf("failed to create request: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := d.opts.URLs.Client().Do(req)
+	if err != nil {
+		return offset, fmt.Errorf("failed to download from %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 &&
+		strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+		// Resumed where the previous attempt stopped
+	case resp.StatusCode == http.StatusOK:
+		// Whole file: start over
+		if offset > 0 {
+			if err := out.Truncate(0); err != nil {
+				return offset, fmt.Errorf("failed to write file: %w", err)
+			}
+			if _, err := out.Seek(0, io.SeekStart); err != nil {
+				return 0, fmt.Errorf("failed to write file: %w", err)
+			}
+			limits.used.Add(-offset)
+			offset = 0
+		}
+	default:
+		return offset, &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	if offset == 0 {
+		if err := checkVideoContentType(resp.Header.Get("Content-Type")); err != nil {
+			return 0, err
+		}
+		*contentType = resp.Header.Get("Content-Type")
+	}
+	return d.copyBody(ctx, url, resp.Body, resp.ContentLength, out, offset, limits)
+}
+
+// copyBody writes a download body of size bytes (-1 if unknown) into out at offset, checking the video
+// signature when the body starts the file and applying the size limits and bandwidth throttle
+func (d *VideoDownloader) copyBody(ctx context.Context, url string, body io.Reader, size int64, out *os.File, offset int64, limits *sizeLimits) (int64, error) {
+	if err := limits.checkLength(offset, size); err != nil {
+		return offset, err
+	}
+
+	if offset == 0 {
+		var err error
+		if body, err = sniffedReader(body); err != nil {
+			if errors.Is(err, ErrNotVideo) {
+				return 0, err
+			}
+			return 0, fmt.Errorf("failed to download from %s: %w", url, err)
+		}
+	}
+
+	if d.throttle != nil {
+		body = &throttledReader{ctx: ctx, r: body, throttle: d.throttle}
+	}
+	n, err := io.Copy(out, &limitedReader{r: body, limits: limits, size: offset})
+	if err != nil {
+		if errors.Is(err, ErrTooLarge) {
+			return offset + n, err
+		}
+		return offset + n, fmt.Errorf("failed to download from %s: %w", url, err)
+	}
+	return offset + n, nil
+}
@@ -0,0 +1,59 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// throttleChunk is the most a throttled read asks for at once, so waits stay short and even
+const throttleChunk = 32 << 10
+
+// throttle limits the combined rate of all reads that share it
+type throttle struct {
+	bytesPerSecond int64
+	mu             sync.Mutex
+	next           time.Time // when the bytes granted so far will have been read at the limit
+}
+
+// wait blocks until n more bytes fit within the limit
+func (t *throttle) wait(ctx context.Context, n int) error {
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	t.next = t.next.Add(time.Duration(int64(n) * int64(time.Second) / t.bytesPerSecond))
+	delay := t.next.Sub(now)
+	t.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledReader reads from r no faster than its throttle allows
+type throttledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	throttle *throttle
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	n, err := tr.r.Read(p)
+	if n > 0 {
+		if werr := tr.throttle.wait(tr.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
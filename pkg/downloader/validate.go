@@ -0,0 +1,150 @@
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrNotVideo is returned when a URL serves something other than a video file, such as an HTML error page
+var ErrNotVideo = errors.New("not a video file")
+
+// sizeLimits bounds the bytes written by the downloads of one DownloadVideosInOrder call
+type sizeLimits struct {
+	perFile int64 // 0 disables the limit
+	total   int64 // 0 disables the limit
+	used    atomic.Int64
+}
+
+// checkLength fails early when a response announces more bytes than the limits allow
+func (l *sizeLimits) checkLength(offset, contentLength int64) error {
+	if contentLength <= 0 {
+		return nil
+	}
+	if l.perFile > 0 && offset+contentLength > l.perFile {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d bytes per file", ErrTooLarge, offset+contentLength, l.perFile)
+	}
+	if l.total > 0 && l.used.Load()+contentLength > l.total {
+		return fmt.Errorf("%w: downloads exceed limit of %d bytes in total", ErrTooLarge, l.total)
+	}
+	return nil
+}
+
+// limitedReader reads a response body, failing with ErrTooLarge once the file or all downloads together pass their limits
+type limitedReader struct {
+	r      io.Reader
+	limits *sizeLimits
+	size   int64 // bytes of the file so far, including earlier attempts
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	lr.size += int64(n)
+	used := lr.limits.used.Add(int64(n))
+	if lr.limits.perFile > 0 && lr.size > lr.limits.perFile {
+		return n, fmt.Errorf("%w: exceeds limit of %d bytes per file", ErrTooLarge, lr.limits.perFile)
+	}
+	if lr.limits.total > 0 && used > lr.limits.total {
+		return n, fmt.Errorf("%w: downloads exceed limit of %d bytes in total", ErrTooLarge, lr.limits.total)
+	}
+	return n, err
+}
+
+// checkVideoContentType rejects responses whose Content-Type cannot be a video. Generic binary and missing
+// types are allowed; the file signature is checked separately.
+func checkVideoContentType(header string) error {
+	contentType, _, _ := mime.ParseMediaType(header)
+	switch {
+	case contentType == "", strings.HasPrefix(contentType, "video/"):
+		return nil
+	case contentType == "application/octet-stream", contentType == "binary/octet-stream", contentType == "application/mp4":
+		return nil
+	}
+	return fmt.Errorf("%w: server sent content type %q", ErrNotVideo, contentType)
+}
+
+// tsPacketSize is the size of an MPEG transport stream packet, each starting with the 0x47 sync byte
+const tsPacketSize = 188
+
+// signatureSize is how many leading bytes sniffVideo needs: enough to reach the sync byte of the third
+// transport stream packet
+const signatureSize = 2*tsPacketSize + 1
+
+// sniffVideo reports whether a file starting with header looks like a video container
+func sniffVideo(header []byte) bool {
+	at := func(offset int, sig string) bool {
+		return len(header) >= offset+len(sig) && bytes.Equal(header[offset:offset+len(sig)], []byte(sig))
+	}
+
+	switch {
+	case at(4, "ftyp"), at(4, "moov"), at(4, "mdat"), at(4, "free"), at(4, "wide"), at(4, "skip"): // MP4, MOV, 3GP
+		return true
+	case at(0, "\x1a\x45\xdf\xa3"): // Matroska, WebM
+		return true
+	case at(0, "RIFF") && at(8, "AVI "):
+		return true
+	case at(0, "FLV"), at(0, "OggS"):
+		return true
+	case at(0, "\x00\x00\x01\xba"): // MPEG program stream
+		return true
+	case isTransportStream(header):
+		return true
+	}
+	return false
+}
+
+// isTransportStream reports whether header holds the sync bytes of the first three MPEG transport stream
+// packets, or of the first two when the file is shorter. A single 0x47 byte is not enough, as text
+// starting with "G" would pass.
+func isTransportStream(header []byte) bool {
+	packets := 0
+	for offset := 0; offset < len(header) && packets < 3; offset += tsPacketSize {
+		if header[offset] != 0x47 {
+			return false
+		}
+		packets++
+	}
+	return packets >= 2
+}
+
+// sniffedReader checks the signature of a body that starts at the beginning of the file, returning
+// a reader that still yields the whole body
+func sniffedReader(body io.Reader) (io.Reader, error) {
+	header := make([]byte, signatureSize)
+	n, err := io.ReadFull(body, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: empty response", ErrNotVideo)
+		}
+		return nil, err
+	}
+	header = header[:n]
+	if !sniffVideo(header) {
+		return nil, fmt.Errorf("%w: content does not start with a known video signature", ErrNotVideo)
+	}
+	return io.MultiReader(bytes.NewReader(header), body), nil
+}
+
+// videoExtensions lists the URL path extensions kept for video downloads
+var videoExtensions = map[string]bool{
+	".mp4": true, ".m4v": true, ".mov": true, ".webm": true, ".mkv": true, ".avi": true,
+	".flv": true, ".ts": true, ".mpg": true, ".ogv": true, ".3gp": true,
+}
+
+// videoExtension picks the extension to save a video download with: the one of its Content-Type, else
+// the one of the URL path, else .mp4
+func videoExtension(contentType, urlPath string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if ext, ok := mediaTypes[mediaType]; ok && strings.HasPrefix(mediaType, "video/") {
+		return ext
+	}
+	if ext := strings.ToLower(path.Ext(urlPath)); videoExtensions[ext] {
+		return ext
+	}
+	return ".mp4"
+}
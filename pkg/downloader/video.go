@@ -1,26 +1,54 @@
 package downloader
 
 import (
+	"context"
 	"fmt"
-	"io"
-	"net/http"
+	neturl "net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/semaphore"
+
+	"govid/pkg/tracing"
 )
 
 // VideoDownloader handles downloading videos from URLs
 type VideoDownloader struct {
-	tempDir string
+	tempDir  string
+	opts     Options
+	sem      *semaphore.Weighted // nil when parallel downloads are not limited
+	throttle *throttle           // nil when bandwidth is not limited
+}
+
+// Options configures retries, size limits, concurrency, bandwidth, and allowed URLs of a VideoDownloader
+type Options struct {
+	URLs              *URLPolicy
+	Objects           ObjectStore // reads s3:// or gs:// URLs of the storage backend; nil rejects them
+	YTDLP             *YTDLP      // fetches platform URLs such as YouTube; nil fetches them over plain HTTP
+	Retry             RetryPolicy
+	MaxFileBytes      int64 // largest single download; 0 disables the limit
+	MaxTotalBytes     int64 // largest total of one DownloadVideosInOrder call; 0 disables the limit
+	MaxParallel       int64 // downloads running at once across all jobs; 0 disables the limit
+	MaxBytesPerSecond int64 // combined download rate across all jobs; 0 disables the limit
 }
 
 // NewVideoDownloader creates a new video downloader
-func NewVideoDownloader(tempDir string) *VideoDownloader {
-	return &VideoDownloader{
+func NewVideoDownloader(tempDir string, opts Options) *VideoDownloader {
+	d := &VideoDownloader{
 		tempDir: tempDir,
+		opts:    opts,
+	}
+	if opts.MaxParallel > 0 {
+		d.sem = semaphore.NewWeighted(opts.MaxParallel)
 	}
+	if opts.MaxBytesPerSecond > 0 {
+		d.throttle = &throttle{bytesPerSecond: opts.MaxBytesPerSecond}
+	}
+	return d
 }
 
 // DownloadResult contains the result of a download operation
@@ -30,11 +58,27 @@ type DownloadResult struct {
 	Error    error
 }
 
-// DownloadVideosInOrder downloads videos from URLs while preserving order
-func (d *VideoDownloader) DownloadVideosInOrder(urls []string) ([]string, error) {
+// DownloadVideosInOrder downloads videos from URLs while preserving order. Responses that are not videos
+// fail with ErrNotVideo and downloads over the size limits fail with ErrTooLarge, cancelling the others.
+// checksums, which may be shorter than urls or nil, holds the expected checksum of each download;
+// downloads that do not match fail with ErrChecksumMismatch.
+func (d *VideoDownloader) DownloadVideosInOrder(ctx context.Context, urls []string, checksums []Checksum) (paths []string, err error) {
 	if len(urls) == 0 {
 		return nil, fmt.Errorf("no URLs provided")
 	}
+	for i, url := range urls {
+		if err := d.CheckURL(url); err != nil {
+			return nil, fmt.Errorf("video %d: %w", i, err)
+		}
+	}
+
+	ctx, span := tracing.Start(ctx, "download.videos", attribute.Int("download.count", len(urls)))
+	defer func() { tracing.End(span, err) }()
+
+	// The first failure stops the remaining downloads
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	limits := &sizeLimits{perFile: d.opts.MaxFileBytes, total: d.opts.MaxTotalBytes}
 
 	// Create a results channel
 	results := make(chan DownloadResult, len(urls))
@@ -46,7 +90,13 @@ func (d *VideoDownloader) DownloadVideosInOrder(urls []string) ([]string, error)
 		go func(index int, videoURL string) {
 			defer wg.Done()
 
-			filePath, err := d.downloadVideo(videoURL, index)
+			filePath, err := d.downloadVideo(ctx, videoURL, index, limits)
+			if err == nil && index < len(checksums) {
+				if err = checksums[index].Verify(filePath); err != nil {
+					os.Remove(filePath)
+					filePath = ""
+				}
+			}
 			results <- DownloadResult{
 				Index:    index,
 				FilePath: filePath,
@@ -63,56 +113,113 @@ func (d *VideoDownloader) DownloadVideosInOrder(urls []string) ([]string, error)
 
 	// Collect results and maintain order
 	downloadedFiles := make([]string, len(urls))
+	var firstErr error
 	for result := range results {
 		if result.Error != nil {
-			// Clean up already downloaded files
-			for _, path := range downloadedFiles {
-				if path != "" {
-					os.Remove(path)
-				}
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to download video %d: %w", result.Index, result.Error)
+				cancel()
 			}
-			return nil, fmt.Errorf("failed to download video %d: %w", result.Index, result.Error)
+			continue
 		}
 		downloadedFiles[result.Index] = result.FilePath
 	}
 
+	if firstErr != nil {
+		// Clean up already downloaded files
+		d.CleanupFiles(downloadedFiles)
+		return nil, firstErr
+	}
+
 	return downloadedFiles, nil
 }
 
 // downloadVideo downloads a single video from a URL
-func (d *VideoDownloader) downloadVideo(url string, index int) (string, error) {
-	// Create HTTP request
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", fmt.Errorf("failed to download from %s: %w", url, err)
+func (d *VideoDownloader) downloadVideo(ctx context.Context, url string, index int, limits *sizeLimits) (filePath string, err error) {
+	ctx, span := tracing.Start(ctx, "download.video", attribute.String("url.full", url), attribute.Int("download.index", index))
+	defer func() { tracing.End(span, err) }()
+
+	// Wait for a download slot
+	if d.sem != nil {
+		if err := d.sem.Acquire(ctx, 1); err != nil {
+			return "", err
+		}
+		defer d.sem.Release(1)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("bad status: %s", resp.Status)
+	if d.isPlatformURL(url) {
+		filePath, err = d.fetchPlatform(ctx, url, index, limits)
+		if err != nil {
+			return "", err
+		}
+		if info, err := os.Stat(filePath); err == nil {
+			span.SetAttributes(attribute.Int64("download.size_bytes", info.Size()))
+		}
+		return filePath, nil
 	}
 
-	// Generate unique filename
-	filename := fmt.Sprintf("%s_%d.mp4", uuid.New().String(), index)
-	filePath := filepath.Join(d.tempDir, filename)
-
-	// Create the file
-	out, err := os.Create(filePath)
+	// Download under a temporary name until the type is known
+	base := filepath.Join(d.tempDir, fmt.Sprintf("%s_%d", uuid.New().String(), index))
+	partPath := base + ".part"
+	out, err := os.Create(partPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to create file: %w", err)
 	}
 	defer out.Close()
 
-	// Write the response body to file
-	_, err = io.Copy(out, resp.Body)
+	// Download into the file, retrying and resuming on transient failures
+	var written int64
+	var contentType string
+	if IsObjectURL(url) {
+		written, err = d.fetchObject(ctx, url, out, limits)
+	} else {
+		written, err = d.fetchWithRetry(ctx, url, out, limits, &contentType)
+	}
+	if err == nil {
+		err = out.Close()
+	}
 	if err != nil {
-		os.Remove(filePath)
-		return "", fmt.Errorf("failed to write file: %w", err)
+		os.Remove(partPath)
+		return "", err
+	}
+	span.SetAttributes(attribute.Int64("download.size_bytes", written))
+
+	// Keep the real extension so FFmpeg and later stages see the right container
+	var urlPath string
+	if u, err := neturl.Parse(url); err == nil {
+		urlPath = u.Path
+	}
+	filePath = base + videoExtension(contentType, urlPath)
+	if err := os.Rename(partPath, filePath); err != nil {
+		os.Remove(partPath)
+		return "", fmt.Errorf("failed to rename download: %w", err)
 	}
 
 	return filePath, nil
 }
 
+// IsURL reports whether path is a URL the downloader fetches rather than a local file path
+func IsURL(path string) bool {
+	lower := strings.ToLower(path)
+	return IsObjectURL(path) || strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// CheckURL reports whether url may be downloaded, returning an error wrapping ErrURLNotAllowed if not.
+// Besides http(s) URLs, object URLs of the configured storage backend (s3:// or gs://) are accepted.
+func (d *VideoDownloader) CheckURL(url string) error {
+	if IsObjectURL(url) {
+		scheme, _, _, err := parseObjectURL(url)
+		if err != nil {
+			return err
+		}
+		if d.opts.Objects == nil || d.opts.Objects.Scheme() != scheme {
+			return fmt.Errorf("%w: %q needs %s storage, which is not configured", ErrURLNotAllowed, url, scheme)
+		}
+		return nil
+	}
+	return d.opts.URLs.CheckURL(url)
+}
+
 // CleanupFiles removes downloaded files
 func (d *VideoDownloader) CleanupFiles(filePaths []string) {
 	for _, path := range filePaths {
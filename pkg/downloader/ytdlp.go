@@ -0,0 +1,103 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// YTDLP configures fetching videos from platform pages such as YouTube, Vimeo, or TikTok with yt-dlp
+type YTDLP struct {
+	Binary string   // yt-dlp binary
+	Format string   // yt-dlp format selector, e.g. bv*+ba/b
+	Hosts  []string // hosts whose URLs go through yt-dlp; subdomains match too
+}
+
+// isPlatformURL reports whether rawURL should be fetched with yt-dlp
+func (d *VideoDownloader) isPlatformURL(rawURL string) bool {
+	if d.opts.YTDLP == nil {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, platform := range d.opts.YTDLP.Hosts {
+		platform = strings.ToLower(strings.TrimSpace(platform))
+		if platform != "" && (host == platform || strings.HasSuffix(host, "."+platform)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchPlatform downloads a platform video with yt-dlp into the temp directory. yt-dlp picks the file
+// extension, so the path is read from its output. Retries, the per-file size limit, and the bandwidth
+// limit are passed on to yt-dlp; the total size limit is checked once the file is complete.
+func (d *VideoDownloader) fetchPlatform(ctx context.Context, rawURL string, index int, limits *sizeLimits) (string, error) {
+	base := filepath.Join(d.tempDir, fmt.Sprintf("%s_%d", uuid.New().String(), index))
+	args := []string{
+		"--no-playlist",
+		"--no-progress",
+		"--no-warnings",
+		"--retries", strconv.Itoa(d.opts.Retry.MaxRetries),
+		"--output", base + ".%(ext)s",
+		"--print", "after_move:filepath",
+	}
+	if d.opts.YTDLP.Format != "" {
+		args = append(args, "--format", d.opts.YTDLP.Format)
+	}
+	if limits.perFile > 0 {
+		args = append(args, "--max-filesize", strconv.FormatInt(limits.perFile, 10))
+	}
+	if d.opts.MaxBytesPerSecond > 0 {
+		args = append(args, "--limit-rate", strconv.FormatInt(d.opts.MaxBytesPerSecond, 10))
+	}
+	args = append(args, "--", rawURL)
+
+	cmd := exec.CommandContext(ctx, d.opts.YTDLP.Binary, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		removeMatching(base + ".*")
+		return "", fmt.Errorf("yt-dlp failed for %s: %w: %s", rawURL, err, strings.TrimSpace(stderr.String()))
+	}
+
+	// yt-dlp exits successfully without a file when --max-filesize rejects the video
+	filePath := strings.TrimSpace(stdout.String())
+	if i := strings.LastIndexByte(filePath, '\n'); i >= 0 {
+		filePath = filePath[i+1:]
+	}
+	info, err := os.Stat(filePath)
+	if filePath == "" || err != nil {
+		removeMatching(base + ".*")
+		if limits.perFile > 0 {
+			return "", fmt.Errorf("yt-dlp produced no file for %s; the video may exceed the limit of %d bytes per file", rawURL, limits.perFile)
+		}
+		return "", fmt.Errorf("yt-dlp produced no file for %s", rawURL)
+	}
+
+	if used := limits.used.Add(info.Size()); limits.total > 0 && used > limits.total {
+		os.Remove(filePath)
+		return "", fmt.Errorf("%w: downloads exceed limit of %d bytes in total", ErrTooLarge, limits.total)
+	}
+	return filePath, nil
+}
+
+// removeMatching removes the files matching a glob pattern, such as the partial files of a failed yt-dlp run
+func removeMatching(pattern string) {
+	matches, _ := filepath.Glob(pattern)
+	for _, path := range matches {
+		os.Remove(path)
+	}
+}
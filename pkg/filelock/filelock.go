@@ -0,0 +1,30 @@
+// Package filelock serializes the read-modify-write of files that processes share, such as instances
+// sharing a directory on a network volume
+package filelock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Lock takes an exclusive lock on the lock file at path, creating it if needed, and waits until it is free.
+// The returned function releases it. The lock is released as well when the process exits, so a crashed
+// process leaves no stale lock behind. The lock file is kept for the next lock.
+func Lock(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	if err := lock(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return func() {
+		unlock(file)
+		file.Close()
+	}, nil
+}
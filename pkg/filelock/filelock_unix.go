@@ -0,0 +1,22 @@
+//go:build linux || darwin
+
+package filelock
+
+import (
+	"os"
+	"syscall"
+)
+
+// lock takes a flock(2) lock, which Linux carries over NFS as a POSIX record lock
+func lock(file *os.File) error {
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+func unlock(file *os.File) {
+	_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
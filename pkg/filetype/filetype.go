@@ -0,0 +1,139 @@
+package filetype
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrNotAllowed is returned for uploads whose extension is not allowed or whose content does not match it
+var ErrNotAllowed = errors.New("file type not allowed")
+
+// HeaderSize is how many leading bytes CheckContent needs to recognize every known format
+const HeaderSize = 12
+
+// formats maps extensions to the container format their content must have. Extensions missing here can be
+// allowed, but their content is not checked.
+var formats = map[string]string{
+	".mp4":  "mp4",
+	".m4v":  "mp4",
+	".m4a":  "mp4",
+	".mov":  "mp4",
+	".webm": "matroska",
+	".mkv":  "matroska",
+	".png":  "png",
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".gif":  "gif",
+	".mp3":  "mp3",
+	".wav":  "wav",
+}
+
+// sniff returns the format of a file starting with header, or "" if it is not a known format
+func sniff(header []byte) string {
+	at := func(offset int, sig string) bool {
+		return len(header) >= offset+len(sig) && bytes.Equal(header[offset:offset+len(sig)], []byte(sig))
+	}
+
+	switch {
+	case at(4, "ftyp"), at(4, "moov"), at(4, "mdat"), at(4, "free"), at(4, "wide"), at(4, "skip"): // MP4, MOV
+		return "mp4"
+	case at(0, "\x1a\x45\xdf\xa3"): // Matroska, WebM
+		return "matroska"
+	case at(0, "\x89PNG\r\n\x1a\n"):
+		return "png"
+	case at(0, "\xff\xd8\xff"):
+		return "jpeg"
+	case at(0, "GIF87a"), at(0, "GIF89a"):
+		return "gif"
+	case at(0, "ID3"), len(header) >= 2 && header[0] == 0xff && header[1]&0xe0 == 0xe0: // ID3 tag or MPEG audio frame sync
+		return "mp3"
+	case at(0, "RIFF") && at(8, "WAVE"):
+		return "wav"
+	}
+	return ""
+}
+
+// Policy decides which uploaded files are accepted, by extension and by their leading magic bytes
+type Policy struct {
+	allowed map[string]bool
+}
+
+// NewPolicy creates a policy allowing the given extensions, written with or without the leading dot
+func NewPolicy(extensions []string) *Policy {
+	allowed := make(map[string]bool, len(extensions))
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		allowed[ext] = true
+	}
+	return &Policy{allowed: allowed}
+}
+
+// Extensions returns the allowed extensions in order
+func (p *Policy) Extensions() []string {
+	extensions := make([]string, 0, len(p.allowed))
+	for ext := range p.allowed {
+		extensions = append(extensions, ext)
+	}
+	slices.Sort(extensions)
+	return extensions
+}
+
+// CheckName checks that the extension of filename is allowed
+func (p *Policy) CheckName(filename string) error {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !p.allowed[ext] {
+		if ext == "" {
+			return fmt.Errorf("%w: %q has no extension; allowed: %s", ErrNotAllowed, filename, strings.Join(p.Extensions(), ", "))
+		}
+		return fmt.Errorf("%w: %s; allowed: %s", ErrNotAllowed, ext, strings.Join(p.Extensions(), ", "))
+	}
+	return nil
+}
+
+// CheckContent checks the extension of filename and that a file starting with header has the format of
+// that extension
+func (p *Policy) CheckContent(filename string, header []byte) error {
+	if err := p.CheckName(filename); err != nil {
+		return err
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	format, known := formats[ext]
+	if !known {
+		return nil
+	}
+	if sniffed := sniff(header); sniffed != format {
+		if sniffed == "" {
+			return fmt.Errorf("%w: content of %q is not a %s file", ErrNotAllowed, filename, ext)
+		}
+		return fmt.Errorf("%w: content of %q is %s, not %s", ErrNotAllowed, filename, sniffed, ext)
+	}
+	return nil
+}
+
+// CheckFile checks an uploaded file saved at path under its original filename
+func (p *Policy) CheckFile(filename, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := make([]byte, HeaderSize)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return p.CheckContent(filename, header[:n])
+}
@@ -0,0 +1,32 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Binary checks that an executable runs, by calling it with -version
+func Binary(ctx context.Context, path string) error {
+	if err := exec.CommandContext(ctx, path, "-version").Run(); err != nil {
+		return fmt.Errorf("%s is not runnable: %w", path, err)
+	}
+	return nil
+}
+
+// WritableDir checks that a file can be created in dir
+func WritableDir(dir string) error {
+	file, err := os.CreateTemp(dir, ".health-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return nil
+}
+
+// FreeSpace returns the bytes available to unprivileged users on the filesystem holding dir
+func FreeSpace(dir string) (uint64, error) {
+	return freeSpace(dir)
+}
@@ -1,25 +1,66 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog"
 )
 
-var logger zerolog.Logger
+var (
+	logger zerolog.Logger
+	output io.Writer = os.Stdout
+	level            = zerolog.InfoLevel
+	format           = "console"
+)
 
 func init() {
 	// Configure zerolog
 	zerolog.TimeFieldFormat = time.RFC3339
 
-	// Use console writer for pretty output
-	output := zerolog.ConsoleWriter{
-		Out:        os.Stdout,
-		TimeFormat: "2006-01-02 15:04:05",
+	build()
+}
+
+// Configure sets the minimum level (trace, debug, info, warn, error) and the format:
+// "console" for human-readable lines or "json" for one JSON object per line.
+func Configure(levelName, formatName string) error {
+	lvl, err := zerolog.ParseLevel(strings.ToLower(levelName))
+	if err != nil || lvl == zerolog.NoLevel {
+		return fmt.Errorf("invalid log level %q: must be trace, debug, info, warn, or error", levelName)
+	}
+	if formatName != "console" && formatName != "json" {
+		return fmt.Errorf("invalid log format %q: must be console or json", formatName)
 	}
 
-	logger = zerolog.New(output).With().Timestamp().Logger()
+	level = lvl
+	format = formatName
+	build()
+	return nil
+}
+
+// SetOutput redirects log output to w, e.g. stderr when stdout carries protocol messages
+func SetOutput(w io.Writer) {
+	output = w
+	build()
+}
+
+// build replaces the global logger with one using the current output, level, and format.
+// Loggers derived earlier with With keep the previous settings.
+func build() {
+	w := output
+	if format == "console" {
+		// Use console writer for pretty output
+		w = zerolog.ConsoleWriter{
+			Out:        output,
+			TimeFormat: "2006-01-02 15:04:05",
+		}
+	}
+
+	logger = zerolog.New(w).Level(level).With().Timestamp().Logger()
 }
 
 // Info logs an info level message
@@ -46,3 +87,53 @@ func Debug(format string, v ...any) {
 func Fatal(format string, v ...any) {
 	logger.Fatal().Msgf(format, v...)
 }
+
+// Logger writes messages that carry a fixed set of context fields, such as the job and request they belong to
+type Logger struct {
+	zl zerolog.Logger
+}
+
+// With returns a logger that adds the field key=value to every message
+func With(key, value string) *Logger {
+	return &Logger{zl: logger.With().Str(key, value).Logger()}
+}
+
+// With returns a copy of l that also adds the field key=value
+func (l *Logger) With(key, value string) *Logger {
+	return &Logger{zl: l.zl.With().Str(key, value).Logger()}
+}
+
+// Info logs an info level message
+func (l *Logger) Info(format string, v ...any) {
+	l.zl.Info().Msgf(format, v...)
+}
+
+// Error logs an error level message
+func (l *Logger) Error(format string, v ...any) {
+	l.zl.Error().Msgf(format, v...)
+}
+
+// Warn logs a warning level message
+func (l *Logger) Warn(format string, v ...any) {
+	l.zl.Warn().Msgf(format, v...)
+}
+
+// Debug logs a debug level message
+func (l *Logger) Debug(format string, v ...any) {
+	l.zl.Debug().Msgf(format, v...)
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying l, so code running on behalf of a job logs with its fields
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger stored in ctx, or one without extra fields
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return &Logger{zl: logger}
+}
@@ -0,0 +1,83 @@
+package pathpolicy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrNotAllowed is returned for paths outside the allowed directories, or that try to leave them with ".."
+var ErrNotAllowed = errors.New("path not allowed")
+
+// Policy decides which local files requests may name as inputs, so that a request cannot have the server read
+// arbitrary files such as /etc/passwd. A path is allowed if, with its symlinks resolved, it is inside one of
+// the root directories.
+type Policy struct {
+	roots []string // absolute, with symlinks resolved
+}
+
+// NewPolicy creates a policy allowing the files under the given directories. Empty entries are ignored.
+func NewPolicy(roots ...string) *Policy {
+	p := &Policy{}
+	for _, root := range roots {
+		if root = strings.TrimSpace(root); root == "" {
+			continue
+		}
+		if resolved, err := resolve(root); err == nil && !slices.Contains(p.roots, resolved) {
+			p.roots = append(p.roots, resolved)
+		}
+	}
+	return p
+}
+
+// Check fails with ErrNotAllowed unless path is inside one of the roots. Paths containing ".." elements are
+// refused outright, whether or not they would end up inside a root.
+func (p *Policy) Check(path string) error {
+	if slices.Contains(strings.Split(filepath.ToSlash(path), "/"), "..") {
+		return fmt.Errorf("%w: %s contains \"..\"", ErrNotAllowed, path)
+	}
+	resolved, err := resolve(path)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrNotAllowed, path, err)
+	}
+	for _, root := range p.roots {
+		if within(root, resolved) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is outside the directories files may be read from", ErrNotAllowed, path)
+}
+
+// resolve returns the absolute form of path with its symlinks resolved. A path that does not exist yet is
+// resolved up to its deepest existing parent, so that it is judged by where it would be created.
+func resolve(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return "", err
+		}
+		missing = append([]string{filepath.Base(abs)}, missing...)
+		abs = parent
+	}
+}
+
+// within reports whether path is root or inside it
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
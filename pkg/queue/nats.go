@@ -0,0 +1,61 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+
+	"govid/pkg/logger"
+)
+
+// NATSBroker consumes requests from a NATS subject in a queue group and publishes events to another subject.
+// Core NATS delivers a message at most once, so a request sent while no instance is subscribed is lost; send
+// it as a request to get the job's reply and retry without one.
+type NATSBroker struct {
+	conn *nats.Conn
+	cfg  Config
+}
+
+// NewNATSBroker connects to the NATS server at cfg.URL, reconnecting for as long as the broker is open
+func NewNATSBroker(cfg Config) (*NATSBroker, error) {
+	conn, err := nats.Connect(cfg.URL, nats.Name("govid"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	return &NATSBroker{conn: conn, cfg: cfg}, nil
+}
+
+// Consume subscribes to the requests subject until ctx is done, then drains the subscription
+func (b *NATSBroker) Consume(ctx context.Context, handle Handler) error {
+	sub, err := b.conn.QueueSubscribe(b.cfg.Requests, b.cfg.Group, func(msg *nats.Msg) {
+		reply, err := handle(ctx, msg.Data)
+		if err != nil {
+			logger.Error("Failed to handle message on %s: %v", msg.Subject, err)
+			return
+		}
+		if msg.Reply != "" && reply != nil {
+			if err := msg.Respond(reply); err != nil {
+				logger.Error("Failed to reply to message on %s: %v", msg.Subject, err)
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", b.cfg.Requests, err)
+	}
+	<-ctx.Done()
+	return sub.Drain()
+}
+
+// Publish publishes an event to the events subject
+func (b *NATSBroker) Publish(_ context.Context, event []byte) error {
+	if b.cfg.Events == "" {
+		return nil
+	}
+	return b.conn.Publish(b.cfg.Events, event)
+}
+
+// Close flushes the published events and closes the connection
+func (b *NATSBroker) Close() error {
+	return b.conn.Drain()
+}
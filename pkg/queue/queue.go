@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+)
+
+// Broker consumes processing requests from a message broker and publishes job events to it
+type Broker interface {
+	// Consume calls handle with the body of every request message until ctx is done, when it returns nil.
+	// The reply handle returns is sent back to messages that ask for one; a message whose handle fails is
+	// left for the broker to redeliver, where the backend supports it.
+	Consume(ctx context.Context, handle Handler) error
+	// Publish sends a job event to the events destination. It does nothing if none is configured.
+	Publish(ctx context.Context, event []byte) error
+	// Close closes the connection to the broker
+	Close() error
+}
+
+// Handler handles the body of a request message and returns the reply to it, or nil for none
+type Handler func(ctx context.Context, body []byte) ([]byte, error)
+
+// Backend names for the QUEUE_BACKEND setting
+const (
+	BackendNATS     = "nats"
+	BackendRabbitMQ = "rabbitmq"
+	BackendSQS      = "sqs"
+)
+
+// Config selects and configures a broker
+type Config struct {
+	Backend  string // nats, rabbitmq, sqs, or empty for no queue
+	URL      string // server URL of nats and rabbitmq
+	Requests string // NATS subject, RabbitMQ queue, or SQS queue URL requests are consumed from
+	Events   string // NATS subject, RabbitMQ queue, or SQS queue URL job events are published to; empty for none
+	Group    string // NATS queue group, which shares the requests between instances
+	Region   string // AWS region of the SQS queues; empty uses the AWS environment
+}
+
+// Validate checks that the settings of the configured backend are complete
+func (c Config) Validate() error {
+	switch c.Backend {
+	case "":
+		return nil
+	case BackendNATS, BackendRabbitMQ:
+		if c.URL == "" || c.Requests == "" {
+			return fmt.Errorf("the %s queue backend requires a server URL and a requests destination", c.Backend)
+		}
+	case BackendSQS:
+		if c.Requests == "" {
+			return fmt.Errorf("the sqs queue backend requires a requests queue URL")
+		}
+	default:
+		return fmt.Errorf("unknown queue backend %q", c.Backend)
+	}
+	return nil
+}
+
+// New connects to the broker of the configured backend, or returns nil if the queue is disabled
+func New(cfg Config) (Broker, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	var broker Broker
+	var err error
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case BackendNATS:
+		broker, err = NewNATSBroker(cfg)
+	case BackendRabbitMQ:
+		broker, err = NewRabbitMQBroker(cfg)
+	case BackendSQS:
+		broker, err = NewSQSBroker(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return broker, nil
+}
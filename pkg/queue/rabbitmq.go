@@ -0,0 +1,102 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"govid/pkg/logger"
+)
+
+// RabbitMQBroker consumes requests from a durable RabbitMQ queue and publishes persistent events to another
+// one. A request is acknowledged once it is handled, so one that was not is redelivered.
+type RabbitMQBroker struct {
+	conn    *amqp.Connection
+	consume *amqp.Channel
+	publish *amqp.Channel // events and replies are published on their own channel, as consumers ack on theirs
+	cfg     Config
+}
+
+// NewRabbitMQBroker connects to the RabbitMQ server at cfg.URL and declares the queues
+func NewRabbitMQBroker(cfg Config) (*RabbitMQBroker, error) {
+	conn, err := amqp.Dial(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+	b := &RabbitMQBroker{conn: conn, cfg: cfg}
+	if err := b.open(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// open opens the channels and declares the queues
+func (b *RabbitMQBroker) open() error {
+	var err error
+	if b.consume, err = b.conn.Channel(); err != nil {
+		return fmt.Errorf("failed to open RabbitMQ channel: %w", err)
+	}
+	if b.publish, err = b.conn.Channel(); err != nil {
+		return fmt.Errorf("failed to open RabbitMQ channel: %w", err)
+	}
+	for _, name := range []string{b.cfg.Requests, b.cfg.Events} {
+		if name == "" {
+			continue
+		}
+		if _, err := b.consume.QueueDeclare(name, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("failed to declare queue %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Consume handles the messages of the requests queue until ctx is done. It fails if the connection is lost.
+func (b *RabbitMQBroker) Consume(ctx context.Context, handle Handler) error {
+	deliveries, err := b.consume.ConsumeWithContext(ctx, b.cfg.Requests, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume %s: %w", b.cfg.Requests, err)
+	}
+	for delivery := range deliveries {
+		reply, err := handle(ctx, delivery.Body)
+		if err != nil {
+			logger.Error("Failed to handle message from %s: %v", b.cfg.Requests, err)
+			_ = delivery.Nack(false, true)
+			continue
+		}
+		if delivery.ReplyTo != "" && reply != nil {
+			if err := b.send(ctx, delivery.ReplyTo, delivery.CorrelationId, reply); err != nil {
+				logger.Error("Failed to reply to message from %s: %v", b.cfg.Requests, err)
+			}
+		}
+		_ = delivery.Ack(false)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return fmt.Errorf("RabbitMQ connection lost while consuming %s", b.cfg.Requests)
+}
+
+// Publish publishes an event to the events queue
+func (b *RabbitMQBroker) Publish(ctx context.Context, event []byte) error {
+	if b.cfg.Events == "" {
+		return nil
+	}
+	return b.send(ctx, b.cfg.Events, "", event)
+}
+
+// send publishes a persistent JSON message to a queue through the default exchange
+func (b *RabbitMQBroker) send(ctx context.Context, queue, correlationID string, body []byte) error {
+	return b.publish.PublishWithContext(ctx, "", queue, false, false, amqp.Publishing{
+		ContentType:   "application/json",
+		DeliveryMode:  amqp.Persistent,
+		CorrelationId: correlationID,
+		Body:          body,
+	})
+}
+
+// Close closes the connection and its channels
+func (b *RabbitMQBroker) Close() error {
+	return b.conn.Close()
+}
@@ -0,0 +1,95 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"govid/pkg/logger"
+)
+
+// sqsRetryDelay is how long Consume waits after a failed receive before polling again
+const sqsRetryDelay = 5 * time.Second
+
+// SQSBroker long-polls requests from an SQS queue and sends events to another one. A request is deleted once
+// it is handled; one that was not becomes visible again after the visibility timeout of the queue. SQS has no
+// replies, so the job of a request is only known from its events.
+type SQSBroker struct {
+	client *sqs.SQS
+	cfg    Config
+}
+
+// NewSQSBroker creates an SQS client with the credentials of the AWS environment
+func NewSQSBroker(cfg Config) (*SQSBroker, error) {
+	awsConfig := aws.NewConfig()
+	if cfg.Region != "" {
+		awsConfig = awsConfig.WithRegion(cfg.Region)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+	return &SQSBroker{client: sqs.New(sess), cfg: cfg}, nil
+}
+
+// Consume polls the requests queue until ctx is done
+func (b *SQSBroker) Consume(ctx context.Context, handle Handler) error {
+	for ctx.Err() == nil {
+		out, err := b.client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(b.cfg.Requests),
+			MaxNumberOfMessages: aws.Int64(10),
+			WaitTimeSeconds:     aws.Int64(20),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			logger.Error("Failed to receive messages from %s: %v", b.cfg.Requests, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(sqsRetryDelay):
+			}
+			continue
+		}
+
+		for _, msg := range out.Messages {
+			if _, err := handle(ctx, []byte(aws.StringValue(msg.Body))); err != nil {
+				logger.Error("Failed to handle message %s: %v", aws.StringValue(msg.MessageId), err)
+				continue
+			}
+			// The message is deleted even if ctx is done by now, as its job was started
+			_, err := b.client.DeleteMessageWithContext(context.WithoutCancel(ctx), &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(b.cfg.Requests),
+				ReceiptHandle: msg.ReceiptHandle,
+			})
+			if err != nil {
+				logger.Error("Failed to delete message %s: %v", aws.StringValue(msg.MessageId), err)
+			}
+		}
+	}
+	return nil
+}
+
+// Publish sends an event to the events queue
+func (b *SQSBroker) Publish(ctx context.Context, event []byte) error {
+	if b.cfg.Events == "" {
+		return nil
+	}
+	_, err := b.client.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(b.cfg.Events),
+		MessageBody: aws.String(string(event)),
+	})
+	return err
+}
+
+// Close does nothing; the client holds no connection
+func (b *SQSBroker) Close() error {
+	return nil
+}
@@ -0,0 +1,88 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// HTTPScanner scans files with an external HTTP service. Each file is POSTed as the raw request body, with
+// its name in the X-File-Name header, and the service answers 200 with {"clean": bool, "threat": "..."}.
+type HTTPScanner struct {
+	url        string
+	httpClient *http.Client
+}
+
+// httpVerdict is the response body of the HTTP scanner
+type httpVerdict struct {
+	Clean  *bool  `json:"clean"`
+	Threat string `json:"threat"`
+}
+
+// NewHTTPScanner creates a scanner that posts files to endpoint
+func NewHTTPScanner(endpoint string, timeout time.Duration) (*HTTPScanner, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid scan URL %q: must be an http or https URL", endpoint)
+	}
+	return &HTTPScanner{
+		url:        endpoint,
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Name identifies the scanner in scan records
+func (s *HTTPScanner) Name() string {
+	return BackendHTTP
+}
+
+// Scan posts the file at path to the scan service and returns its verdict
+func (s *HTTPScanner) Scan(ctx context.Context, path string) (Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return Result{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, file)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to create scan request: %w", err)
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-File-Name", filepath.Base(path))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to reach scan service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read scan response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("scan service returned status %d: %s", resp.StatusCode, body)
+	}
+
+	var verdict httpVerdict
+	if err := sonic.Unmarshal(body, &verdict); err != nil || verdict.Clean == nil {
+		return Result{}, fmt.Errorf("invalid scan response: %s", body)
+	}
+	if !*verdict.Clean && verdict.Threat == "" {
+		verdict.Threat = "unknown"
+	}
+	return Result{Clean: *verdict.Clean, Threat: verdict.Threat}, nil
+}
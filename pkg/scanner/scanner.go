@@ -0,0 +1,49 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Scanner checks uploaded files for malware or other unwanted content
+type Scanner interface {
+	// Scan scans the file at path. An error means the file could not be scanned, not that it is unsafe.
+	Scan(ctx context.Context, path string) (Result, error)
+	// Name identifies the scanner in scan records
+	Name() string
+}
+
+// Result is the verdict of a scan
+type Result struct {
+	Clean  bool
+	Threat string // what was found in a file that is not clean, as reported by the scanner
+}
+
+// Backend names for the UPLOAD_SCAN_BACKEND setting
+const (
+	BackendClamd = "clamd"
+	BackendHTTP  = "http"
+)
+
+// Config selects and configures a scanner
+type Config struct {
+	Backend      string        // clamd, http, or empty for no scanning
+	ClamdAddress string        // clamd socket, as unix:///path or tcp://host:port
+	URL          string        // endpoint of the HTTP scanner
+	Timeout      time.Duration // how long one scan may take
+}
+
+// New creates the scanner of the configured backend, or returns nil if scanning is disabled
+func New(cfg Config) (Scanner, error) {
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case BackendClamd:
+		return NewClamdScanner(cfg.ClamdAddress, cfg.Timeout)
+	case BackendHTTP:
+		return NewHTTPScanner(cfg.URL, cfg.Timeout)
+	default:
+		return nil, fmt.Errorf("unknown scan backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/api/option"
+
+	"govid/pkg/tracing"
+)
+
+// GCSUploader handles file uploads to Google Cloud Storage
+type GCSUploader struct {
+	client *storage.Client
+	bucket string
+}
+
+// GCSConfig contains configuration for the GCS uploader
+type GCSConfig struct {
+	Bucket          string
+	CredentialsFile string // service account key file; empty uses Application Default Credentials
+}
+
+// NewGCSUploader creates a new GCS uploader instance
+func NewGCSUploader(ctx context.Context, config GCSConfig) (*GCSUploader, error) {
+	var opts []option.ClientOption
+	if config.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(config.CredentialsFile))
+	}
+
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	return &GCSUploader{
+		client: client,
+		bucket: config.Bucket,
+	}, nil
+}
+
+// Upload uploads a file to GCS and returns its https://storage.googleapis.com URL
+func (g *GCSUploader) Upload(ctx context.Context, filePath, objectName string, opts UploadOptions) (objectURL string, err error) {
+	ctx, span := tracing.Start(ctx, "gcs.upload",
+		attribute.String("gcs.bucket", g.bucket),
+		attribute.String("gcs.object", objectName),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	writer := g.client.Bucket(g.bucket).Object(objectName).NewWriter(ctx)
+	writer.ContentType = opts.contentTypeOf(filePath)
+	writer.CacheControl = opts.CacheControl
+	writer.Metadata = opts.Metadata
+	if opts.Progress != nil {
+		if stat, err := file.Stat(); err == nil {
+			writer.ProgressFunc = func(uploaded int64) { opts.Progress(uploaded, stat.Size()) }
+		}
+	}
+	written, err := io.Copy(writer, file)
+	if err != nil {
+		writer.Close()
+		return "", fmt.Errorf("failed to upload file: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to upload file: %w", err)
+	}
+	span.SetAttributes(attribute.Int64("gcs.size_bytes", written))
+
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", g.bucket, (&url.URL{Path: objectName}).EscapedPath()), nil
+}
+
+// Open opens an object in any bucket the credentials can read and returns its size
+func (g *GCSUploader) Open(ctx context.Context, bucket, key string) (io.ReadCloser, int64, error) {
+	reader, err := g.client.Bucket(bucket).Object(key).NewReader(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return reader, reader.Attrs.Size, nil
+}
+
+// Presign returns a V4 signed GET URL of an uploaded object, valid for ttl (at most 7 days). Signing needs
+// a service account key or a service account allowed to sign blobs through the IAM API.
+func (g *GCSUploader) Presign(ctx context.Context, objectName string, ttl time.Duration) (string, error) {
+	signed, err := g.client.Bucket(g.bucket).SignedURL(objectName, &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  "GET",
+		Expires: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to presign URL: %w", err)
+	}
+	return signed, nil
+}
+
+// PresignUpload returns a V4 signed PUT URL for objectName, valid for ttl (at most 7 days). A non-empty
+// contentType is signed, so the upload must send it.
+func (g *GCSUploader) PresignUpload(ctx context.Context, objectName, contentType string, ttl time.Duration) (string, error) {
+	signed, err := g.client.Bucket(g.bucket).SignedURL(objectName, &storage.SignedURLOptions{
+		Scheme:      storage.SigningSchemeV4,
+		Method:      "PUT",
+		ContentType: contentType,
+		Expires:     time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to presign upload URL: %w", err)
+	}
+	return signed, nil
+}
+
+// Delete deletes an uploaded object
+func (g *GCSUploader) Delete(ctx context.Context, objectName string) error {
+	err := g.client.Bucket(g.bucket).Object(objectName).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// Bucket returns the bucket outputs are uploaded to
+func (g *GCSUploader) Bucket() string {
+	return g.bucket
+}
+
+// WithBucket returns an uploader for another bucket with the same client
+func (g *GCSUploader) WithBucket(bucket string) (Uploader, error) {
+	return &GCSUploader{client: g.client, bucket: bucket}, nil
+}
+
+// Scheme returns the URL scheme of GCS input objects
+func (g *GCSUploader) Scheme() string {
+	return "gs"
+}
+
+// Ping checks that GCS is reachable with the configured credentials and the bucket exists
+func (g *GCSUploader) Ping(ctx context.Context) error {
+	if _, err := g.client.Bucket(g.bucket).Attrs(ctx); err != nil {
+		if errors.Is(err, storage.ErrBucketNotExist) {
+			return fmt.Errorf("bucket %s does not exist", g.bucket)
+		}
+		return fmt.Errorf("failed to reach GCS: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxKeyLength is the S3 and GCS limit on object key length in bytes
+const maxKeyLength = 1024
+
+// keyPlaceholder matches the placeholders of a key template
+var keyPlaceholder = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// unsafeKeyChars matches characters replaced in values inserted into keys
+var unsafeKeyChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// KeyFields are the values of the placeholders of an object key template
+type KeyFields struct {
+	JobID        string
+	APIKey       string    // name of the API key that created the job
+	OriginalName string    // name of the job's first input, without extension
+	FilePath     string    // the file being uploaded
+	Time         time.Time // upload time
+}
+
+// ObjectKey expands an object key template. The placeholders are {job_id}, {api_key}, {original_name},
+// {filename} (name of the uploaded file), {ext} (its extension without the dot), {date} (YYYY-MM-DD),
+// {year}, {month}, and {day}; dates are in UTC.
+func ObjectKey(template string, fields KeyFields) (string, error) {
+	t := fields.Time.UTC()
+	filename := filepath.Base(fields.FilePath)
+	values := map[string]string{
+		"job_id":        fields.JobID,
+		"api_key":       safeKeyValue(fields.APIKey, "anonymous"),
+		"original_name": safeKeyValue(fields.OriginalName, fields.JobID),
+		"filename":      filename,
+		"ext":           strings.TrimPrefix(filepath.Ext(filename), "."),
+		"date":          t.Format("2006-01-02"),
+		"year":          t.Format("2006"),
+		"month":         t.Format("01"),
+		"day":           t.Format("02"),
+	}
+
+	var unknown string
+	key := keyPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		value, ok := values[name]
+		if !ok && unknown == "" {
+			unknown = placeholder
+		}
+		return value
+	})
+	if unknown != "" {
+		return "", fmt.Errorf("unknown placeholder %s in object key template", unknown)
+	}
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// ValidateKeyTemplate checks that a key template only uses known placeholders and expands to a valid key
+func ValidateKeyTemplate(template string) error {
+	_, err := ObjectKey(template, KeyFields{JobID: "job", FilePath: "job.mp4", Time: time.Now()})
+	return err
+}
+
+// checkKey rejects keys that are empty, too long, absolute, or contain empty, "." or ".." segments,
+// which some backends would normalize away
+func checkKey(key string) error {
+	if key == "" || len(key) > maxKeyLength {
+		return fmt.Errorf("object key must be 1-%d bytes", maxKeyLength)
+	}
+	if strings.HasPrefix(key, "/") || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("object key %q must be a relative path without empty, '.' or '..' segments", key)
+	}
+	for _, r := range key {
+		if r < 0x20 || r == 0x7f {
+			return fmt.Errorf("object key %q must not contain control characters", key)
+		}
+	}
+	return nil
+}
+
+// safeKeyValue makes a value safe to insert into a key, using fallback when nothing is left
+func safeKeyValue(value, fallback string) string {
+	value = strings.Trim(unsafeKeyChars.ReplaceAllString(value, "-"), "-.")
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"govid/pkg/tracing"
+)
+
+var (
+	// ErrLinkExpired is returned for download links past their expiry time
+	ErrLinkExpired = errors.New("download link expired")
+	// ErrInvalidLink is returned for download links with a missing or wrong signature
+	ErrInvalidLink = errors.New("invalid download link")
+)
+
+// LocalUploader keeps job outputs in a local directory and links to them with signed, expiring URLs
+// served by the API itself
+type LocalUploader struct {
+	dir     string
+	baseURL string
+	secret  []byte
+	ttl     time.Duration
+}
+
+// LocalConfig contains configuration for the local uploader
+type LocalConfig struct {
+	Dir     string        // directory the files are stored in
+	BaseURL string        // URL the stored files are served under, e.g. https://govid.example.com/api/v1/files
+	Secret  string        // key the links are signed with; empty uses a random key, so links end with the process
+	LinkTTL time.Duration // how long links stay valid
+}
+
+// NewLocalUploader creates a new local uploader instance
+func NewLocalUploader(config LocalConfig) (*LocalUploader, error) {
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", config.Dir, err)
+	}
+
+	secret := []byte(config.Secret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate link secret: %w", err)
+		}
+	}
+
+	return &LocalUploader{
+		dir:     config.Dir,
+		baseURL: strings.TrimSuffix(config.BaseURL, "/"),
+		secret:  secret,
+		ttl:     config.LinkTTL,
+	}, nil
+}
+
+// Upload stores a file under objectName in the local directory and returns a signed link to it.
+// The file is hard-linked when possible, so the caller may remove the original.
+func (l *LocalUploader) Upload(ctx context.Context, filePath, objectName string, opts UploadOptions) (link string, err error) {
+	_, span := tracing.Start(ctx, "local.store",
+		attribute.String("local.key", objectName),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	target, err := l.path(objectName)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	os.Remove(target)
+	if err := os.Link(filePath, target); err != nil {
+		if err := copyFile(filePath, target); err != nil {
+			os.Remove(target)
+			return "", fmt.Errorf("failed to store file: %w", err)
+		}
+	}
+
+	return l.SignedURL(objectName, l.ttl), nil
+}
+
+// Presign returns a signed link to a stored file, valid for ttl
+func (l *LocalUploader) Presign(ctx context.Context, objectName string, ttl time.Duration) (string, error) {
+	if _, err := l.path(objectName); err != nil {
+		return "", err
+	}
+	return l.SignedURL(objectName, ttl), nil
+}
+
+// PresignUpload is not supported: files reach the local backend through the upload API
+func (l *LocalUploader) PresignUpload(ctx context.Context, objectName, contentType string, ttl time.Duration) (string, error) {
+	return "", fmt.Errorf("direct uploads are %w", ErrNotSupported)
+}
+
+// Delete deletes a stored file
+func (l *LocalUploader) Delete(ctx context.Context, objectName string) error {
+	filePath, err := l.path(objectName)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// Bucket returns an empty name, since local storage has no buckets
+func (l *LocalUploader) Bucket() string {
+	return ""
+}
+
+// WithBucket is not supported, since local storage has no buckets
+func (l *LocalUploader) WithBucket(bucket string) (Uploader, error) {
+	return nil, fmt.Errorf("buckets are %w", ErrNotSupported)
+}
+
+// Open is not supported: the local backend has no input objects
+func (l *LocalUploader) Open(ctx context.Context, bucket, key string) (io.ReadCloser, int64, error) {
+	return nil, 0, errors.New("local storage has no input objects")
+}
+
+// Scheme returns an empty scheme, since no object URLs refer to local storage
+func (l *LocalUploader) Scheme() string {
+	return ""
+}
+
+// Ping checks that the directory exists and is writable
+func (l *LocalUploader) Ping(ctx context.Context) error {
+	file, err := os.CreateTemp(l.dir, ".ping-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", l.dir, err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// SignedURL returns a link to a stored file that is valid for ttl
+func (l *LocalUploader) SignedURL(key string, ttl time.Duration) string {
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{
+		"expires":   {expires},
+		"signature": {l.sign(key, expires)},
+	}
+	return fmt.Sprintf("%s/%s?%s", l.baseURL, (&url.URL{Path: key}).EscapedPath(), query.Encode())
+}
+
+// Resolve checks the expiry and signature of a link to key and returns the path of the stored file
+func (l *LocalUploader) Resolve(key, expires, signature string) (string, error) {
+	expiry, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(l.sign(key, expires))) {
+		return "", ErrInvalidLink
+	}
+	if time.Now().Unix() > expiry {
+		return "", ErrLinkExpired
+	}
+	return l.path(key)
+}
+
+// sign returns the hex HMAC-SHA256 of a key and expiry time
+func (l *LocalUploader) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// path maps a key to its file, refusing keys that would leave the directory
+func (l *LocalUploader) path(key string) (string, error) {
+	if key == "" || path.IsAbs(key) || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return "", fmt.Errorf("%w: bad key %q", ErrInvalidLink, key)
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+// copyFile copies src to a new file dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
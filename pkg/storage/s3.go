@@ -3,10 +3,17 @@ package storage
 import (
 	"context"
 	"fmt"
-	"path/filepath"
+	"io"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.opentelemetry.io/otel/attribute"
+
+	"govid/pkg/logger"
+	"govid/pkg/tracing"
 )
 
 // S3Uploader handles file uploads to S3-compatible storage
@@ -16,6 +23,9 @@ type S3Uploader struct {
 	region   string
 	endpoint string
 	useSSL   bool
+	partSize uint64
+	threads  uint
+	retries  int
 }
 
 // S3Config contains configuration for S3 uploader
@@ -26,6 +36,12 @@ type S3Config struct {
 	Bucket    string
 	Region    string
 	UseSSL    bool
+
+	// Multipart uploads: part size in bytes (0 lets the client choose), parts uploaded at once,
+	// and retries of a failed upload
+	PartSize uint64
+	Threads  uint
+	Retries  int
 }
 
 // NewS3Uploader creates a new S3 uploader instance
@@ -46,22 +62,127 @@ func NewS3Uploader(config S3Config) (*S3Uploader, error) {
 		region:   config.Region,
 		endpoint: config.Endpoint,
 		useSSL:   config.UseSSL,
+		partSize: config.PartSize,
+		threads:  config.Threads,
+		retries:  config.Retries,
 	}, nil
 }
 
-// Upload uploads a file to S3 and returns the HTTPS URL
-func (s *S3Uploader) Upload(ctx context.Context, filePath, objectName string) (string, error) {
-	// Upload the file
-	_, err := s.client.FPutObject(ctx, s.bucket, objectName, filePath, minio.PutObjectOptions{
-		ContentType: "video/mp4",
-	})
+// Upload uploads a file to S3 and returns the HTTPS URL. Large files are sent as a multipart upload
+// with parts in parallel; an upload that fails with a network or server error is retried from the start.
+func (s *S3Uploader) Upload(ctx context.Context, filePath, objectName string, opts UploadOptions) (url string, err error) {
+	ctx, span := tracing.Start(ctx, "s3.upload",
+		attribute.String("s3.bucket", s.bucket),
+		attribute.String("s3.key", objectName),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	stat, err := os.Stat(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to upload file: %w", err)
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+
+	var info minio.UploadInfo
+	for attempt := 0; ; attempt++ {
+		putOpts := minio.PutObjectOptions{
+			ContentType:  opts.contentTypeOf(filePath),
+			CacheControl: opts.CacheControl,
+			UserMetadata: opts.Metadata,
+			PartSize:     s.partSize,
+			NumThreads:   s.threads,
+		}
+		if opts.Progress != nil {
+			putOpts.Progress = &progressReader{total: stat.Size(), report: opts.Progress}
+		}
+
+		info, err = s.client.FPutObject(ctx, s.bucket, objectName, filePath, putOpts)
+		if err == nil {
+			break
+		}
+		if attempt >= s.retries || !retryableS3(ctx, err) {
+			return "", fmt.Errorf("failed to upload file: %w", err)
+		}
+
+		delay := uploadBackoff(attempt)
+		logger.FromContext(ctx).Warn("Upload of %s failed (attempt %d of %d), retrying in %s: %v",
+			objectName, attempt+1, s.retries+1, delay, err)
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("failed to upload file: %w", ctx.Err())
+		case <-time.After(delay):
+		}
 	}
+	span.SetAttributes(attribute.Int64("s3.size_bytes", info.Size))
 
 	// Generate the HTTPS URL
-	url := s.generateHTTPSURL(objectName)
-	return url, nil
+	return s.generateHTTPSURL(objectName), nil
+}
+
+// retryableS3 reports whether a failed upload may succeed when repeated: network errors, throttling, and
+// server errors are retried, other client errors such as missing buckets or bad credentials are not
+func retryableS3(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	code := minio.ToErrorResponse(err).StatusCode
+	return code == 0 || code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout
+}
+
+// Open opens an object in any bucket the credentials can read and returns its size
+func (s *S3Uploader) Open(ctx context.Context, bucket, key string) (io.ReadCloser, int64, error) {
+	object, err := s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, 0, err
+	}
+	return object, info.Size, nil
+}
+
+// Presign returns a presigned GET URL of an uploaded object, valid for ttl (at most 7 days)
+func (s *S3Uploader) Presign(ctx context.Context, objectName string, ttl time.Duration) (string, error) {
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, objectName, ttl, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign URL: %w", err)
+	}
+	return u.String(), nil
+}
+
+// PresignUpload returns a presigned PUT URL for objectName, valid for ttl (at most 7 days). A non-empty
+// contentType is signed, so the upload must send it.
+func (s *S3Uploader) PresignUpload(ctx context.Context, objectName, contentType string, ttl time.Duration) (string, error) {
+	var headers http.Header
+	if contentType != "" {
+		headers = http.Header{"Content-Type": {contentType}}
+	}
+	u, err := s.client.PresignHeader(ctx, http.MethodPut, s.bucket, objectName, ttl, nil, headers)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign upload URL: %w", err)
+	}
+	return u.String(), nil
+}
+
+// Delete deletes an uploaded object
+func (s *S3Uploader) Delete(ctx context.Context, objectName string) error {
+	if err := s.client.RemoveObject(ctx, s.bucket, objectName, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// Bucket returns the bucket outputs are uploaded to
+func (s *S3Uploader) Bucket() string {
+	return s.bucket
+}
+
+// WithBucket returns an uploader for another bucket with the same client and settings
+func (s *S3Uploader) WithBucket(bucket string) (Uploader, error) {
+	other := *s
+	other.bucket = bucket
+	return &other, nil
 }
 
 // generateHTTPSURL creates the HTTPS URL for an object
@@ -75,6 +196,23 @@ func (s *S3Uploader) generateHTTPSURL(objectName string) string {
 	return fmt.Sprintf("%s://%s/%s/%s", protocol, s.endpoint, s.bucket, objectName)
 }
 
+// Scheme returns the URL scheme of S3 input objects
+func (s *S3Uploader) Scheme() string {
+	return "s3"
+}
+
+// Ping checks that the S3 endpoint is reachable with the configured credentials and the bucket exists
+func (s *S3Uploader) Ping(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return fmt.Errorf("failed to reach S3: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s does not exist", s.bucket)
+	}
+	return nil
+}
+
 // EnsureBucket ensures the bucket exists, creates it if it doesn't
 func (s *S3Uploader) EnsureBucket(ctx context.Context) error {
 	exists, err := s.client.BucketExists(ctx, s.bucket)
@@ -93,9 +231,3 @@ func (s *S3Uploader) EnsureBucket(ctx context.Context) error {
 
 	return nil
 }
-
-// GetObjectName generates a unique object name from a file path
-func GetObjectName(jobID, filePath string) string {
-	filename := filepath.Base(filePath)
-	return fmt.Sprintf("combined/%s/%s", jobID, filename)
-}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Uploader stores job outputs in a storage backend and reads input objects from it
+type Uploader interface {
+	// Upload uploads a file under objectName and returns its URL
+	Upload(ctx context.Context, filePath, objectName string, opts UploadOptions) (string, error)
+	// Presign returns a URL that downloads an uploaded object without credentials until ttl passes
+	Presign(ctx context.Context, objectName string, ttl time.Duration) (string, error)
+	// PresignUpload returns a URL that uploads an object with a PUT request without credentials until
+	// ttl passes. A non-empty contentType must be sent as the Content-Type of the request.
+	PresignUpload(ctx context.Context, objectName, contentType string, ttl time.Duration) (string, error)
+	// Bucket is the bucket outputs are uploaded to
+	Bucket() string
+	// WithBucket returns an uploader that uses another bucket with the same client and settings
+	WithBucket(bucket string) (Uploader, error)
+	// Delete deletes an uploaded object. An object that does not exist is not an error.
+	Delete(ctx context.Context, objectName string) error
+	// Open opens an object in any bucket the credentials can read and returns its size
+	Open(ctx context.Context, bucket, key string) (io.ReadCloser, int64, error)
+	// Scheme is the URL scheme of input objects in this backend, as in s3://bucket/key
+	Scheme() string
+	// Ping checks that the backend is reachable with the configured credentials and the bucket exists
+	Ping(ctx context.Context) error
+}
+
+// ErrNotSupported is returned for operations a backend cannot do
+var ErrNotSupported = errors.New("not supported by this storage backend")
+
+// UploadOptions are per-upload settings
+type UploadOptions struct {
+	ContentType  string            // MIME type; empty detects it from the file extension
+	CacheControl string            // Cache-Control header served with the object
+	Metadata     map[string]string // user metadata stored with the object
+
+	// Progress, if set, is called as bytes are uploaded; calls may come from several goroutines
+	Progress func(uploaded, total int64)
+}
+
+// Backend names for the STORAGE_BACKEND setting
+const (
+	BackendS3    = "s3"
+	BackendGCS   = "gcs"
+	BackendLocal = "local"
+)
+
+// Config selects and configures a storage backend
+type Config struct {
+	Backend string // s3, gcs, or local
+	S3      S3Config
+	GCS     GCSConfig
+	Local   LocalConfig
+}
+
+// New creates the uploader of the configured backend
+func New(ctx context.Context, cfg Config) (Uploader, error) {
+	var uploader Uploader
+	var err error
+	switch cfg.Backend {
+	case BackendS3:
+		uploader, err = NewS3Uploader(cfg.S3)
+	case BackendGCS:
+		uploader, err = NewGCSUploader(ctx, cfg.GCS)
+	case BackendLocal:
+		uploader, err = NewLocalUploader(cfg.Local)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return uploader, nil
+}
+
+// maxUploadRetryDelay caps the exponential backoff between upload attempts
+const maxUploadRetryDelay = 30 * time.Second
+
+// uploadBackoff returns the wait before retry number attempt (starting at 0): 1s, doubled after each
+// further failure
+func uploadBackoff(attempt int) time.Duration {
+	delay := time.Second
+	for i := 0; i < attempt && delay < maxUploadRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxUploadRetryDelay)
+}
+
+// progressReader counts the bytes an upload client reports through reads and passes the total on
+type progressReader struct {
+	total    int64
+	uploaded atomic.Int64
+	report   func(uploaded, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	p.report(p.uploaded.Add(int64(len(b))), p.total)
+	return len(b), nil
+}
+
+// contentTypes maps output file extensions to the MIME types objects are stored with
+var contentTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".mov":  "video/quicktime",
+	".webm": "video/webm",
+	".mkv":  "video/x-matroska",
+	".avi":  "video/x-msvideo",
+	".ts":   "video/mp2t",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".wav":  "audio/wav",
+	".ogg":  "audio/ogg",
+	".flac": "audio/flac",
+	".gif":  "image/gif",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".webp": "image/webp",
+	".json": "application/json",
+}
+
+// ContentType returns the MIME type for an output file based on its extension, falling back to the
+// system MIME table and then to application/octet-stream
+func ContentType(filePath string) string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if t, ok := contentTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// contentTypeOf returns the content type of an upload: the one set in the options, else the detected one
+func (o UploadOptions) contentTypeOf(filePath string) string {
+	if o.ContentType != "" {
+		return o.ContentType
+	}
+	return ContentType(filePath)
+}
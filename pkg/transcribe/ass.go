@@ -0,0 +1,176 @@
+package transcribe
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// assPlayResY is the height ASS scripts are laid out for, so that font sizes and margins are in pixels of a
+// 1080-pixel-high frame and scale with the video
+const assPlayResY = 1080
+
+// Alignments of ASS captions, as on a numeric keypad
+const (
+	AlignBottom = 2
+	AlignCenter = 5
+	AlignTop    = 8
+)
+
+// Style is how WriteASS draws captions. Colors are RRGGBB.
+type Style struct {
+	Font           string
+	FontSize       int // in pixels of a 1080-pixel-high frame
+	Bold           bool
+	Color          string
+	OutlineColor   string
+	HighlightColor string // of the word being spoken, with Karaoke
+	Alignment      int    // AlignBottom, AlignCenter, or AlignTop
+	Karaoke        bool   // highlight each word while it is spoken
+	Width, Height  int    // size of the frames the captions are drawn on, for their aspect ratio
+}
+
+// assEscaper keeps caption text from being read as ASS override tags
+var assEscaper = strings.NewReplacer(`\`, `/`, `{`, `(`, `}`, `)`, "\n", `\N`)
+
+// WriteASS writes cues as ASS subtitles drawn in style. With karaoke, each cue is written once per word, with
+// that word highlighted while it is spoken.
+func WriteASS(w io.Writer, cues []Cue, style Style) error {
+	playResX := assPlayResY * 16 / 9
+	if style.Width > 0 && style.Height > 0 {
+		playResX = (assPlayResY*style.Width + style.Height/2) / style.Height
+	}
+	bold := 0
+	if style.Bold {
+		bold = -1
+	}
+	outline := max(style.FontSize/16, 2)
+	margin := playResX / 20
+
+	if _, err := fmt.Fprintf(w, `[Script Info]
+ScriptType: v4.00+
+PlayResX: %d
+PlayResY: %d
+WrapStyle: 0
+ScaledBorderAndShadow: yes
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,%s,%d,%s,%s,%s,&H80000000,%d,0,0,0,100,100,0,0,1,%d,0,%d,%d,%d,%d,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`, playResX, assPlayResY, style.Font, style.FontSize, assColor(style.Color), assColor(style.HighlightColor), assColor(style.OutlineColor),
+		bold, outline, style.Alignment, margin, margin, assPlayResY/18); err != nil {
+		return err
+	}
+
+	for _, cue := range cues {
+		if !style.Karaoke {
+			if err := writeDialogue(w, cue.Start, cue.End, assEscaper.Replace(cue.Text)); err != nil {
+				return err
+			}
+			continue
+		}
+		words := wordCues(cue)
+		for i, word := range words {
+			text := make([]string, len(words))
+			for j := range words {
+				text[j] = assEscaper.Replace(words[j].Text)
+			}
+			text[i] = fmt.Sprintf(`{\c%s&}%s{\r}`, assColor(style.HighlightColor), text[i])
+			if err := writeDialogue(w, word.Start, word.End, strings.Join(text, " ")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteASSFile writes cues as ASS subtitles drawn in style to path
+func WriteASSFile(path string, cues []Cue, style Style) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = WriteASS(file, cues, style)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write subtitles: %w", err)
+	}
+	return nil
+}
+
+// writeDialogue writes one ASS event
+func writeDialogue(w io.Writer, start, end time.Duration, text string) error {
+	_, err := fmt.Fprintf(w, "Dialogue: 0,%s,%s,Default,,0,0,0,,%s\n", assTimestamp(start), assTimestamp(end), text)
+	return err
+}
+
+// assColor converts RRGGBB to the &HBBGGRR& form of ASS
+func assColor(rgb string) string {
+	if len(rgb) != 6 {
+		return "&H00FFFFFF"
+	}
+	return "&H00" + rgb[4:6] + rgb[2:4] + rgb[0:2]
+}
+
+// assTimestamp formats d as h:mm:ss.cc, the centisecond timestamps of ASS
+func assTimestamp(d time.Duration) string {
+	d = max(d, 0).Round(10 * time.Millisecond)
+	return fmt.Sprintf("%d:%02d:%02d.%02d", d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second, d%time.Second/(10*time.Millisecond))
+}
+
+// SplitCues splits cues of more than maxWords words into consecutive cues of at most maxWords words, sharing
+// the time of the cue by the length of their words. A maxWords of 0 keeps the cues as they are.
+func SplitCues(cues []Cue, maxWords int) []Cue {
+	if maxWords <= 0 {
+		return cues
+	}
+	var split []Cue
+	for _, cue := range cues {
+		words := wordCues(cue)
+		if len(words) <= maxWords {
+			split = append(split, cue)
+			continue
+		}
+		for i := 0; i < len(words); i += maxWords {
+			group := words[i:min(i+maxWords, len(words))]
+			text := make([]string, len(group))
+			for j, word := range group {
+				text[j] = word.Text
+			}
+			split = append(split, Cue{Start: group[0].Start, End: group[len(group)-1].End, Text: strings.Join(text, " ")})
+		}
+	}
+	return split
+}
+
+// wordCues splits a cue into its words, each spoken for a share of the cue's time by its length. The
+// transcribers report the time of whole cues only, and longer words take longer to say.
+func wordCues(cue Cue) []Cue {
+	words := strings.Fields(cue.Text)
+	if len(words) == 0 {
+		return nil
+	}
+	total := 0
+	for _, word := range words {
+		total += utf8.RuneCountInString(word) + 1
+	}
+
+	cues := make([]Cue, len(words))
+	duration := cue.End - cue.Start
+	start, elapsed := cue.Start, 0
+	for i, word := range words {
+		elapsed += utf8.RuneCountInString(word) + 1
+		end := cue.Start + duration*time.Duration(elapsed)/time.Duration(total)
+		cues[i] = Cue{Start: start, End: end, Text: word}
+		start = end
+	}
+	return cues
+}
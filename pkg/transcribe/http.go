@@ -0,0 +1,109 @@
+package transcribe
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// maxResponseBytes bounds the subtitles read from the HTTP API
+const maxResponseBytes = 16 << 20
+
+// HTTPTranscriber transcribes audio with an OpenAI-compatible transcription API. Each file is POSTed as the
+// file field of a multipart form, with the model, the language, and response_format=srt, and the API answers
+// 200 with the subtitles as SRT.
+type HTTPTranscriber struct {
+	url        string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// NewHTTPTranscriber creates a transcriber that posts audio to endpoint. The job's context bounds each
+// request, so the client sets no timeout of its own.
+func NewHTTPTranscriber(endpoint, apiKey, model string) (*HTTPTranscriber, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid transcribe URL %q: must be an http or https URL", endpoint)
+	}
+	return &HTTPTranscriber{
+		url:        endpoint,
+		apiKey:     apiKey,
+		model:      model,
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// Name identifies the transcriber in logs
+func (t *HTTPTranscriber) Name() string {
+	return BackendHTTP
+}
+
+// Transcribe posts the audio file at path to the transcription API and parses the subtitles it returns
+func (t *HTTPTranscriber) Transcribe(ctx context.Context, path, language string) ([]Cue, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		writer.CloseWithError(t.writeForm(form, file, language))
+	}()
+	defer body.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transcribe request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach transcribe service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcribe response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transcribe service returned status %d: %s", resp.StatusCode, content)
+	}
+	return ParseSRT(string(content))
+}
+
+// writeForm writes the multipart form of a transcription request
+func (t *HTTPTranscriber) writeForm(form *multipart.Writer, file *os.File, language string) error {
+	fields := map[string]string{"response_format": "srt"}
+	if t.model != "" {
+		fields["model"] = t.model
+	}
+	if language != "" {
+		fields["language"] = language
+	}
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+	part, err := form.CreateFormFile("file", filepath.Base(file.Name()))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return fmt.Errorf("failed to read audio: %w", err)
+	}
+	return form.Close()
+}
@@ -0,0 +1,113 @@
+package transcribe
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timingPattern matches the timing line of an SRT or VTT cue. Hours may be left out, and the milliseconds
+// may follow a comma or a dot, as transcription services differ.
+var timingPattern = regexp.MustCompile(`^(?:(\d+):)?(\d{1,2}):(\d{1,2})[,.](\d{1,3})\s+-->\s+(?:(\d+):)?(\d{1,2}):(\d{1,2})[,.](\d{1,3})`)
+
+// ParseSRT parses SRT subtitles into cues. Cue numbers are optional, and blocks without a timing line are
+// skipped.
+func ParseSRT(content string) ([]Cue, error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	var cues []Cue
+	for _, block := range strings.Split(content, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		for i, line := range lines {
+			match := timingPattern.FindStringSubmatch(strings.TrimSpace(line))
+			if match == nil {
+				continue
+			}
+			text := strings.TrimSpace(strings.Join(lines[i+1:], "\n"))
+			if text != "" {
+				cues = append(cues, Cue{
+					Start: timestamp(match[1:5]),
+					End:   timestamp(match[5:9]),
+					Text:  text,
+				})
+			}
+			break
+		}
+	}
+	if len(cues) == 0 && strings.TrimSpace(content) != "" {
+		return nil, fmt.Errorf("invalid SRT subtitles: no cues found")
+	}
+	return cues, nil
+}
+
+// timestamp converts the hours, minutes, seconds and milliseconds matched by timingPattern
+func timestamp(parts []string) time.Duration {
+	hours, _ := strconv.Atoi(parts[0])
+	minutes, _ := strconv.Atoi(parts[1])
+	seconds, _ := strconv.Atoi(parts[2])
+	// A fraction of fewer than 3 digits counts tenths or hundredths
+	millis, _ := strconv.Atoi((parts[3] + "00")[:3])
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(seconds)*time.Second + time.Duration(millis)*time.Millisecond
+}
+
+// WriteSRT writes cues as SRT subtitles
+func WriteSRT(w io.Writer, cues []Cue) error {
+	for i, cue := range cues {
+		if _, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(cue.Start, ","), formatTimestamp(cue.End, ","), cue.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteVTT writes cues as WebVTT subtitles
+func WriteVTT(w io.Writer, cues []Cue) error {
+	if _, err := io.WriteString(w, "WEBVTT\n\n"); err != nil {
+		return err
+	}
+	for _, cue := range cues {
+		// A blank line would end the cue
+		text := strings.ReplaceAll(cue.Text, "\n\n", "\n")
+		if _, err := fmt.Fprintf(w, "%s --> %s\n%s\n\n", formatTimestamp(cue.Start, "."), formatTimestamp(cue.End, "."), text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFile writes cues to path, as WebVTT for a .vtt file and as SRT otherwise
+func WriteFile(path string, cues []Cue) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".vtt") {
+		err = WriteVTT(file, cues)
+	} else {
+		err = WriteSRT(file, cues)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write subtitles: %w", err)
+	}
+	return nil
+}
+
+// formatTimestamp formats d as hh:mm:ss followed by sep and the milliseconds
+func formatTimestamp(d time.Duration, sep string) string {
+	d = max(d, 0).Round(time.Millisecond)
+	hours := d / time.Hour
+	minutes := d % time.Hour / time.Minute
+	seconds := d % time.Minute / time.Second
+	millis := d % time.Second / time.Millisecond
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", hours, minutes, seconds, sep, millis)
+}
@@ -0,0 +1,54 @@
+package transcribe
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Cue is one subtitle: the text spoken from Start to End
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// Transcriber turns the speech of an audio file into timed subtitles
+type Transcriber interface {
+	// Transcribe transcribes the audio file at path. language is an ISO 639-1 code such as en, or empty to
+	// detect the language.
+	Transcribe(ctx context.Context, path, language string) ([]Cue, error)
+	// Name identifies the transcriber in logs
+	Name() string
+}
+
+// Backend names for the TRANSCRIBE_BACKEND setting
+const (
+	BackendWhisper = "whisper"
+	BackendHTTP    = "http"
+)
+
+// Config selects and configures a transcriber
+type Config struct {
+	Backend        string // whisper, http, or empty for no transcription
+	WhisperBinary  string // whisper.cpp command line binary
+	WhisperModel   string // ggml model file of whisper.cpp
+	WhisperThreads int    // threads of whisper.cpp; 0 for its default
+	URL            string // endpoint of the HTTP transcription API
+	APIKey         string // bearer token of the HTTP API, if it needs one
+	Model          string // model the HTTP API is asked for
+}
+
+// New creates the transcriber of the configured backend, or returns nil if transcription is disabled
+func New(cfg Config) (Transcriber, error) {
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case BackendWhisper:
+		return NewWhisperTranscriber(cfg.WhisperBinary, cfg.WhisperModel, cfg.WhisperThreads)
+	case BackendHTTP:
+		return NewHTTPTranscriber(cfg.URL, cfg.APIKey, cfg.Model)
+	default:
+		return nil, fmt.Errorf("unknown transcribe backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,83 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// WhisperTranscriber transcribes audio with whisper.cpp, which reads 16 kHz WAV files and writes its
+// subtitles next to them as SRT
+type WhisperTranscriber struct {
+	binary  string
+	model   string
+	threads int
+}
+
+// NewWhisperTranscriber creates a transcriber that runs binary with the ggml model file model
+func NewWhisperTranscriber(binary, model string, threads int) (*WhisperTranscriber, error) {
+	if binary == "" {
+		return nil, fmt.Errorf("a whisper.cpp binary is required")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("a whisper.cpp model file is required")
+	}
+	if threads < 0 {
+		return nil, fmt.Errorf("invalid whisper.cpp threads %d: must not be negative", threads)
+	}
+	return &WhisperTranscriber{binary: binary, model: model, threads: threads}, nil
+}
+
+// Name identifies the transcriber in logs
+func (t *WhisperTranscriber) Name() string {
+	return BackendWhisper
+}
+
+// Transcribe runs whisper.cpp on the WAV file at path and parses the SRT file it writes
+func (t *WhisperTranscriber) Transcribe(ctx context.Context, path, language string) ([]Cue, error) {
+	// whisper.cpp assumes English unless it is told to detect the language
+	if language == "" {
+		language = "auto"
+	}
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	args := []string{
+		"-m", t.model,
+		"-f", path,
+		"-l", language,
+		"-osrt",
+		"-of", base,
+		"-np",
+	}
+	if t.threads > 0 {
+		args = append(args, "-t", strconv.Itoa(t.threads))
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.binary, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("whisper.cpp failed: %w: %s", err, lastLine(stderr.String()))
+	}
+
+	srtPath := base + ".srt"
+	defer os.Remove(srtPath)
+	content, err := os.ReadFile(srtPath)
+	if err != nil {
+		return nil, fmt.Errorf("whisper.cpp wrote no subtitles: %w", err)
+	}
+	return ParseSRT(string(content))
+}
+
+// lastLine returns the last non-empty line of output, where command line tools report why they failed
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
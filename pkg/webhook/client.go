@@ -4,20 +4,30 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
 	"github.com/bytedance/sonic"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+
+	"govid/pkg/logger"
+	"govid/pkg/tracing"
 )
 
 // JobCompletionPayload is the payload sent to webhook URLs
 type JobCompletionPayload struct {
-	JobID     string `json:"job_id"`
-	Status    string `json:"status"`
-	S3URL     string `json:"s3_url,omitempty"`
-	Error     string `json:"error,omitempty"`
-	Timestamp string `json:"timestamp"`
+	JobID          string            `json:"job_id"`
+	Status         string            `json:"status"`
+	S3URL          string            `json:"s3_url,omitempty"`
+	S3URLExpiresAt string            `json:"s3_url_expires_at,omitempty"` // RFC 3339; set for presigned and signed links
+	ThumbnailURL   string            `json:"thumbnail_url,omitempty"`
+	MetadataURL    string            `json:"metadata_url,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	RequestID      string            `json:"request_id,omitempty"` // X-Request-ID of the request that created the job
+	Labels         map[string]string `json:"labels,omitempty"`     // labels set when the job was created
+	ExpiresAt      string            `json:"expires_at,omitempty"` // RFC 3339; when the outputs are deleted, set for jobs with a TTL
+	Timestamp      string            `json:"timestamp"`
 }
 
 // Client handles webhook notifications
@@ -35,11 +45,14 @@ func NewClient() *Client {
 }
 
 // SendJobComplete sends a job completion notification to a webhook URL
-func (c *Client) SendJobComplete(ctx context.Context, webhookURL string, headers map[string]string, payload JobCompletionPayload) error {
+func (c *Client) SendJobComplete(ctx context.Context, webhookURL string, headers map[string]string, payload JobCompletionPayload) (err error) {
 	if webhookURL == "" {
 		return nil // No webhook URL provided, nothing to do
 	}
 
+	ctx, span := tracing.Start(ctx, "webhook.send", attribute.String("govid.job_status", payload.Status))
+	defer func() { tracing.End(span, err) }()
+
 	// Add timestamp
 	payload.Timestamp = time.Now().UTC().Format(time.RFC3339)
 
@@ -57,6 +70,12 @@ func (c *Client) SendJobComplete(ctx context.Context, webhookURL string, headers
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "GoVid/1.0")
+	if payload.RequestID != "" {
+		req.Header.Set("X-Request-ID", payload.RequestID)
+	}
+
+	// Let the receiver continue the job's trace
+	tracing.Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	// Set custom headers if provided
 	for key, value := range headers {
@@ -71,6 +90,7 @@ func (c *Client) SendJobComplete(ctx context.Context, webhookURL string, headers
 	defer resp.Body.Close()
 
 	// Check response status
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
 	}
@@ -78,21 +98,22 @@ func (c *Client) SendJobComplete(ctx context.Context, webhookURL string, headers
 	return nil
 }
 
-// SendJobCompleteAsync sends a job completion notification asynchronously
-func (c *Client) SendJobCompleteAsync(webhookURL string, headers map[string]string, payload JobCompletionPayload) {
+// SendJobCompleteAsync sends a job completion notification asynchronously.
+// ctx only supplies the trace the notification belongs to; its cancellation does not stop the send.
+func (c *Client) SendJobCompleteAsync(ctx context.Context, webhookURL string, headers map[string]string, payload JobCompletionPayload) {
 	if webhookURL == "" {
 		return
 	}
 
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 15*time.Second)
 		defer cancel()
 
 		err := c.SendJobComplete(ctx, webhookURL, headers, payload)
 		if err != nil {
-			log.Printf("Failed to send webhook to %s: %v", webhookURL, err)
+			logger.FromContext(ctx).Error("Failed to send webhook to %s: %v", webhookURL, err)
 		} else {
-			log.Printf("Successfully sent webhook to %s for job %s", webhookURL, payload.JobID)
+			logger.FromContext(ctx).Info("Successfully sent webhook to %s for job %s", webhookURL, payload.JobID)
 		}
 	}()
 }